@@ -3,10 +3,18 @@ package dispatcher
 import (
 	"context"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/andy-esch/desirelines/packages/dispatcher"
 )
 
+// terminationFlushTimeout leaves headroom inside the 10s grace period Cloud
+// Run (which hosts 2nd gen functions) allows between SIGTERM and SIGKILL.
+const terminationFlushTimeout = 8 * time.Second
+
 var httpHandler http.Handler
 
 func init() {
@@ -17,6 +25,24 @@ func init() {
 		panic(err)
 	}
 	httpHandler = handler
+
+	go closeOnTermination(handler)
+}
+
+// closeOnTermination flushes buffered Pub/Sub messages when the instance is
+// recycled, then exits in place of the default SIGTERM behavior.
+func closeOnTermination(handler *dispatcher.Handler) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM)
+	<-sigs
+
+	dispatcher.Logger.Info("Termination signal received, flushing publisher")
+	ctx, cancel := context.WithTimeout(context.Background(), terminationFlushTimeout)
+	defer cancel()
+	if err := handler.Close(ctx); err != nil {
+		dispatcher.Logger.Error("Failed to close dispatcher", "error", err)
+	}
+	os.Exit(0)
 }
 
 // ActivityDispatcher is the exported function name that matches Terraform's entry_point.
@@ -0,0 +1,45 @@
+package processor
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/andy-esch/desirelines/packages/processor"
+)
+
+var httpHandler http.Handler
+
+func init() {
+	ctx := context.Background()
+	handler, err := processor.NewHandler(ctx)
+	if err != nil {
+		processor.Logger.Error("Failed to initialize processor", "error", err)
+		panic(err)
+	}
+	httpHandler = handler
+
+	go closeOnTermination(handler)
+}
+
+// closeOnTermination releases the storage client when the instance is
+// recycled, then exits in place of the default SIGTERM behavior.
+func closeOnTermination(handler *processor.Handler) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM)
+	<-sigs
+
+	if err := handler.Close(); err != nil {
+		processor.Logger.Error("Failed to close processor", "error", err)
+	}
+	os.Exit(0)
+}
+
+// ActivityProcessor is the exported function name that matches Terraform's
+// entry_point. It receives the events topic through a push subscription or
+// an Eventarc trigger, both of which deliver Pub/Sub push bodies.
+func ActivityProcessor(w http.ResponseWriter, r *http.Request) {
+	httpHandler.ServeHTTP(w, r)
+}
@@ -0,0 +1,26 @@
+package reconciler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/andy-esch/desirelines/packages/reconciler"
+)
+
+var httpHandler http.Handler
+
+func init() {
+	ctx := context.Background()
+	handler, err := reconciler.NewHandler(ctx)
+	if err != nil {
+		reconciler.Logger.Error("Failed to initialize reconciler", "error", err)
+		panic(err)
+	}
+	httpHandler = handler
+}
+
+// Reconciler is the exported function name that matches Terraform's
+// entry_point. Cloud Scheduler calls it daily with a POST.
+func Reconciler(w http.ResponseWriter, r *http.Request) {
+	httpHandler.ServeHTTP(w, r)
+}
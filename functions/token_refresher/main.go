@@ -0,0 +1,27 @@
+package tokenrefresher
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/andy-esch/desirelines/packages/tokenrefresh"
+)
+
+var httpHandler http.Handler
+
+func init() {
+	ctx := context.Background()
+	handler, err := tokenrefresh.NewHandler(ctx)
+	if err != nil {
+		tokenrefresh.Logger.Error("Failed to initialize token refresher", "error", err)
+		panic(err)
+	}
+	httpHandler = handler
+}
+
+// TokenRefresher is the exported function name that matches Terraform's
+// entry_point. Cloud Scheduler calls it with a POST on a schedule shorter
+// than the refresh window.
+func TokenRefresher(w http.ResponseWriter, r *http.Request) {
+	httpHandler.ServeHTTP(w, r)
+}
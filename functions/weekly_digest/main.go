@@ -0,0 +1,26 @@
+package weeklydigest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/andy-esch/desirelines/packages/digest"
+)
+
+var httpHandler http.Handler
+
+func init() {
+	ctx := context.Background()
+	handler, err := digest.NewHandler(ctx)
+	if err != nil {
+		digest.Logger.Error("Failed to initialize digest generator", "error", err)
+		panic(err)
+	}
+	httpHandler = handler
+}
+
+// WeeklyDigest is the exported function name that matches Terraform's
+// entry_point. Cloud Scheduler calls it every Monday with a POST.
+func WeeklyDigest(w http.ResponseWriter, r *http.Request) {
+	httpHandler.ServeHTTP(w, r)
+}
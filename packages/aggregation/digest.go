@@ -0,0 +1,138 @@
+package aggregation
+
+import (
+	"fmt"
+	"time"
+)
+
+// DigestBlob is the object name of an ISO week's digest, e.g.
+// "digests/2025/07.json". Weeks are zero-padded so a year's digests list
+// in order.
+func DigestBlob(year, week int) string {
+	return fmt.Sprintf("digests/%d/%02d.json", year, week)
+}
+
+// Digest is an ISO week's digests/{year}/{week}.json: what the athlete did
+// that week and how far it moved their goals, for emails and widgets.
+type Digest struct {
+	// GeneratedAt is when the digest was written.
+	GeneratedAt time.Time `json:"generated_at,omitzero"`
+	// StartDate and EndDate are the week's Monday and Sunday.
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	// Goals has the progress of every goal running during the week.
+	Goals []GoalDigest `json:"goals"`
+	// Year and Week are the ISO 8601 week; its first days can be in the
+	// previous calendar year and its last in the next.
+	Year          int     `json:"year"`
+	Week          int     `json:"week"`
+	Activities    int     `json:"activities"`
+	ActiveDays    int     `json:"active_days"`
+	DistanceMiles float64 `json:"distance_miles"`
+	ElevationFeet float64 `json:"elevation_feet"`
+}
+
+// GoalDigest is a goal's progress over a week.
+type GoalDigest struct {
+	Goal
+	// Year is the calendar year the goal belongs to.
+	Year int `json:"year"`
+	// Progress is the goal's total from its start date through the end of
+	// the week, and Delta how much of it the week added, in the unit of
+	// the goal's type.
+	Progress float64 `json:"progress"`
+	Delta    float64 `json:"delta"`
+	// Percent and PercentDelta are Progress and Delta as percentages of
+	// the target.
+	Percent      float64 `json:"percent"`
+	PercentDelta float64 `json:"percent_delta"`
+	// Remaining is how much of the target is left to reach, or zero.
+	Remaining float64 `json:"remaining"`
+}
+
+// WeekStart returns the Monday starting ISO week of year, or an error for
+// a week the year doesn't have.
+func WeekStart(year, week int) (time.Time, error) {
+	// December 28 is always in the year's last week
+	if _, weeks := time.Date(year, time.December, 28, 0, 0, 0, 0, time.UTC).ISOWeek(); week < 1 || week > weeks {
+		return time.Time{}, fmt.Errorf("%d has no week %d", year, week)
+	}
+	// January 4 is always in week 1
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	monday := jan4.AddDate(0, 0, -(int(jan4.Weekday())+6)%7)
+	return monday.AddDate(0, 0, 7*(week-1)), nil
+}
+
+// BuildDigest returns the digest of ISO week of year from the summaries and
+// goals of the calendar years it spans, keyed by year as Summarize returns
+// them. Invalid goals are skipped, as on the chart.
+func BuildDigest(summaries map[int]Summary, goals map[int][]Goal, year, week int) (Digest, error) {
+	start, err := WeekStart(year, week)
+	if err != nil {
+		return Digest{}, err
+	}
+	end := start.AddDate(0, 0, 6)
+	digest := Digest{
+		Year:      year,
+		Week:      week,
+		StartDate: start.Format(time.DateOnly),
+		EndDate:   end.Format(time.DateOnly),
+		Goals:     []GoalDigest{},
+	}
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		entry, ok := summaries[day.Year()][day.Format(time.DateOnly)]
+		if !ok || len(entry.ActivityIDs) == 0 {
+			continue
+		}
+		digest.Activities += len(entry.ActivityIDs)
+		digest.ActiveDays++
+		digest.DistanceMiles += entry.DistanceMiles
+		digest.ElevationFeet += entry.ElevationFeet
+	}
+
+	years := []int{start.Year()}
+	if end.Year() != start.Year() {
+		years = append(years, end.Year())
+	}
+	for _, goalYear := range years {
+		for _, goal := range goals[goalYear] {
+			if goal.validate(goalYear) != nil {
+				continue
+			}
+			if progress, ok := goalDigest(summaries[goalYear], goalYear, start, end, goal); ok {
+				digest.Goals = append(digest.Goals, progress)
+			}
+		}
+	}
+	return digest, nil
+}
+
+// goalDigest returns the goal's progress through the week from start to
+// end, or false if the goal isn't running during it.
+func goalDigest(summary Summary, year int, start, end time.Time, goal Goal) (GoalDigest, bool) {
+	goalStart, goalEnd, _ := goal.Dates(year)
+	if goalStart.After(end) || goalEnd.Before(start) {
+		return GoalDigest{}, false
+	}
+
+	progress := GoalDigest{Goal: goal, Year: year}
+	if progress.Type == "" {
+		progress.Type = GoalDistance
+	}
+	last := min(end.Unix(), goalEnd.Unix())
+	for day := goalStart; day.Unix() <= last; day = day.AddDate(0, 0, 1) {
+		entry, ok := summary[day.Format(time.DateOnly)]
+		if !ok {
+			continue
+		}
+		measure := entry.measure(progress.Type)
+		progress.Progress += measure
+		if !day.Before(start) {
+			progress.Delta += measure
+		}
+	}
+	progress.Percent = 100 * progress.Progress / goal.Target
+	progress.PercentDelta = 100 * progress.Delta / goal.Target
+	progress.Remaining = max(0, goal.Target-progress.Progress)
+	return progress, true
+}
@@ -0,0 +1,96 @@
+package aggregation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWeekStart(t *testing.T) {
+	tests := []struct {
+		year, week int
+		want       string
+	}{
+		{2025, 1, "2024-12-30"},
+		{2025, 7, "2025-02-10"},
+		{2026, 53, "2026-12-28"},
+		{2021, 1, "2021-01-04"},
+	}
+	for _, tt := range tests {
+		start, err := WeekStart(tt.year, tt.week)
+		if err != nil || start.Format(time.DateOnly) != tt.want {
+			t.Errorf("WeekStart(%d, %d) = %v, %v; want %s", tt.year, tt.week, start, err, tt.want)
+		}
+		if year, week := start.ISOWeek(); year != tt.year || week != tt.week {
+			t.Errorf("WeekStart(%d, %d) is in week %d of %d", tt.year, tt.week, week, year)
+		}
+	}
+	for _, week := range []int{0, 53} {
+		if _, err := WeekStart(2025, week); err == nil {
+			t.Errorf("expected 2025 to have no week %d", week)
+		}
+	}
+}
+
+func TestBuildDigest(t *testing.T) {
+	summaries := map[int]Summary{2025: {
+		"2025-02-09": {ActivityIDs: []int64{1}, DistanceMiles: 30, ElevationFeet: 500},
+		"2025-02-10": {ActivityIDs: []int64{2}, DistanceMiles: 20, ElevationFeet: 400},
+		"2025-02-12": {ActivityIDs: []int64{3, 4}, DistanceMiles: 30, ElevationFeet: 100},
+		"2025-02-17": {ActivityIDs: []int64{5}, DistanceMiles: 99},
+	}}
+	goals := map[int][]Goal{2025: {
+		{ID: "year", Target: 1000},
+		{ID: "feb", Type: GoalCount, StartDate: "2025-02-11", EndDate: "2025-02-28", Target: 10},
+		{ID: "jan", StartDate: "2025-01-01", EndDate: "2025-01-31", Target: 100},
+		{ID: "bad", Target: 0},
+	}}
+
+	digest, err := BuildDigest(summaries, goals, 2025, 7)
+	if err != nil {
+		t.Fatalf("BuildDigest failed: %v", err)
+	}
+	if digest.StartDate != "2025-02-10" || digest.EndDate != "2025-02-16" {
+		t.Errorf("expected the week of Monday February 10, got %s to %s", digest.StartDate, digest.EndDate)
+	}
+	if digest.Activities != 3 || digest.ActiveDays != 2 || digest.DistanceMiles != 50 || digest.ElevationFeet != 500 {
+		t.Errorf("unexpected week totals %+v", digest)
+	}
+
+	// January's goal ended before the week, and invalid goals are skipped
+	if len(digest.Goals) != 2 {
+		t.Fatalf("expected the two running goals, got %+v", digest.Goals)
+	}
+	year := digest.Goals[0]
+	if year.Type != GoalDistance || year.Progress != 80 || year.Delta != 50 || year.Percent != 8 || year.PercentDelta != 5 || year.Remaining != 920 {
+		t.Errorf("unexpected yearly goal progress %+v", year)
+	}
+	feb := digest.Goals[1]
+	if feb.Progress != 2 || feb.Delta != 2 || feb.Percent != 20 || feb.Year != 2025 {
+		t.Errorf("expected the goal counted from its start date, got %+v", feb)
+	}
+}
+
+func TestBuildDigest_AcrossYears(t *testing.T) {
+	summaries := map[int]Summary{
+		2024: {"2024-12-31": {ActivityIDs: []int64{1}, DistanceMiles: 10}},
+		2025: {"2025-01-02": {ActivityIDs: []int64{2}, DistanceMiles: 15}},
+	}
+	goals := map[int][]Goal{
+		2024: {{ID: "old", Target: 100}},
+		2025: {{ID: "new", Target: 100}},
+	}
+	digest, err := BuildDigest(summaries, goals, 2025, 1)
+	if err != nil {
+		t.Fatalf("BuildDigest failed: %v", err)
+	}
+	if digest.DistanceMiles != 25 || digest.Activities != 2 {
+		t.Errorf("expected both years' days counted, got %+v", digest)
+	}
+	if len(digest.Goals) != 2 || digest.Goals[0].Year != 2024 || digest.Goals[0].Delta != 10 || digest.Goals[1].Delta != 15 {
+		t.Errorf("expected each year's goal to count its own days, got %+v", digest.Goals)
+	}
+
+	if _, err := BuildDigest(summaries, goals, 2025, 54); err == nil {
+		t.Error("expected an error for a week that doesn't exist")
+	}
+}
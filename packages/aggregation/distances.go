@@ -0,0 +1,150 @@
+// Package aggregation is the chart math shared by the processor and the
+// backfill tools: it turns Strava activities into the per-day
+// summary_activities.json and the distances.json series the web charts.
+//
+//	summaries := aggregation.Summarize(activities, []string{"Ride"})
+//	distances := aggregation.Build(summaries[2025], 2025, today, aggregation.Options{})
+//
+// distances.json holds the cumulative distance traveled and three "desire
+// lines", straight lines from zero on December 31 of the previous year to
+// an end-of-year distance: the projection of the current average pace, and
+// the goals bracketing it at multiples of the goal granularity. Day N of the
+// year is at goal*N/daysInYear on every line, as in the web's own goal
+// calculations. Configured goals (see GoalSet) add a desire line of their
+// own, spread over the goal's dates, and the athlete's progress towards it.
+// The package does no I/O.
+package aggregation
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// DefaultGoalGranularity is the spacing in miles of the goal lines, matching
+// the Python aggregator's pacing granularity.
+const DefaultGoalGranularity = 500
+
+// Point is one day of a distance series.
+type Point struct {
+	X string  `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Distances is a year's distances.json. The goal series are omitted for a
+// year that hasn't started.
+type Distances struct {
+	DistanceTraveled []Point `json:"distance_traveled"`
+	// AvgDistance projects the current average pace to the end of the year.
+	AvgDistance []Point `json:"avg_distance,omitempty"`
+	// UpperDistance and LowerDistance are the goals just above and below
+	// the projection.
+	UpperDistance []Point `json:"upper_distance,omitempty"`
+	LowerDistance []Point `json:"lower_distance,omitempty"`
+	// Summaries notes the distance left to each goal, keyed by the goal in
+	// whole miles.
+	Summaries map[string][]string `json:"summaries,omitempty"`
+	// Goals has a desire line and progress series for each of the
+	// athlete's configured goals.
+	Goals []GoalLine `json:"goals,omitempty"`
+}
+
+// Options configure the goal lines.
+type Options struct {
+	// Goals are the athlete's goals for the year, each given its own
+	// series.
+	Goals []Goal
+	// GoalGranularity is the spacing in miles of the goals bracketing the
+	// projection; DefaultGoalGranularity when zero.
+	GoalGranularity float64
+}
+
+// Build returns year's distances.json from its summary, with the series
+// running through today, or to December 31 once the year is over.
+func Build(summary Summary, year int, today time.Time, opts Options) Distances {
+	granularity := opts.GoalGranularity
+	if granularity <= 0 {
+		granularity = DefaultGoalGranularity
+	}
+
+	traveled := CumulativeDistances(summary, year, today)
+	distances := Distances{DistanceTraveled: traveled}
+	days := len(traveled)
+	if days == 0 {
+		return distances
+	}
+
+	total := traveled[days-1].Y
+	projected := ProjectedDistance(total, year, days)
+	lower, upper := GoalBounds(projected, granularity)
+	distances.AvgDistance = DesireLine(projected, year, days)
+	distances.UpperDistance = DesireLine(upper, year, days)
+	distances.LowerDistance = DesireLine(lower, year, days)
+
+	distances.Summaries = map[string][]string{}
+	for _, goal := range []float64{lower, upper} {
+		notes := []string{}
+		if remaining := goal - total; remaining > 0 {
+			notes = append(notes, fmt.Sprintf("%.0f miles to go", remaining))
+		}
+		distances.Summaries[strconv.Itoa(int(goal))] = notes
+	}
+	distances.Goals = goalLines(summary, year, today, opts.Goals)
+	return distances
+}
+
+// CumulativeDistances returns the running distance total for every day of
+// year up to and including today, or the whole year once it is over. A year
+// that hasn't started has no points.
+func CumulativeDistances(summary Summary, year int, today time.Time) []Point {
+	points := []Point{}
+	var total float64
+	last := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if end := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC); end.Before(last) {
+		last = end
+	}
+	for day := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC); !day.After(last); day = day.AddDate(0, 0, 1) {
+		date := day.Format(time.DateOnly)
+		if entry, ok := summary[date]; ok {
+			total += entry.DistanceMiles
+		}
+		points = append(points, Point{X: date, Y: total})
+	}
+	return points
+}
+
+// DesireLine returns the first days points of the straight line reaching
+// goal miles on December 31 of year.
+func DesireLine(goal float64, year, days int) []Point {
+	daysInYear := DaysInYear(year)
+	days = min(days, daysInYear)
+	points := make([]Point, days)
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i := range days {
+		points[i] = Point{X: start.AddDate(0, 0, i).Format(time.DateOnly), Y: goal * float64(i+1) / float64(daysInYear)}
+	}
+	return points
+}
+
+// ProjectedDistance extrapolates total miles over the first days of year
+// to the whole year at the same average pace.
+func ProjectedDistance(total float64, year, days int) float64 {
+	if days <= 0 {
+		return 0
+	}
+	return total * float64(DaysInYear(year)) / float64(days)
+}
+
+// GoalBounds returns the multiples of granularity just below and above
+// projected. The lower goal is at least granularity, so a slow start still
+// has a line to aim for.
+func GoalBounds(projected, granularity float64) (lower, upper float64) {
+	lower = math.Max(granularity, math.Floor(projected/granularity)*granularity)
+	return lower, lower + granularity
+}
+
+// DaysInYear returns 366 for leap years and 365 otherwise.
+func DaysInYear(year int) int {
+	return time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+}
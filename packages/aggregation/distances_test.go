@@ -0,0 +1,139 @@
+package aggregation
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestCumulativeDistances(t *testing.T) {
+	summary := Summary{
+		"2025-01-01": {ActivityIDs: []int64{1}, DistanceMiles: 10},
+		"2025-01-03": {ActivityIDs: []int64{2}, DistanceMiles: 5},
+	}
+	today := time.Date(2025, time.January, 4, 23, 0, 0, 0, time.UTC)
+
+	got := CumulativeDistances(summary, 2025, today)
+	want := []Point{{"2025-01-01", 10}, {"2025-01-02", 10}, {"2025-01-03", 15}, {"2025-01-04", 15}}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d points, got %v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("point %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCumulativeDistances_PastYear(t *testing.T) {
+	today := time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)
+
+	if got := len(CumulativeDistances(Summary{}, 2024, today)); got != 366 {
+		t.Errorf("expected every day of a finished leap year, got %d", got)
+	}
+	if got := CumulativeDistances(Summary{}, 2026, today); got == nil || len(got) != 0 {
+		t.Errorf("expected an empty series for a future year, got %v", got)
+	}
+}
+
+func TestDesireLine(t *testing.T) {
+	line := DesireLine(365, 2025, 400)
+	if len(line) != 365 {
+		t.Fatalf("expected the line to stop at December 31, got %d points", len(line))
+	}
+	if line[0] != (Point{"2025-01-01", 1}) || line[364] != (Point{"2025-12-31", 365}) {
+		t.Errorf("expected a mile a day from January 1, got %+v ... %+v", line[0], line[364])
+	}
+	if got := DesireLine(732, 2024, 60)[59]; got != (Point{"2024-02-29", 120}) {
+		t.Errorf("expected leap days to be counted, got %+v", got)
+	}
+}
+
+func TestGoalBounds(t *testing.T) {
+	tests := []struct {
+		projected, lower, upper float64
+	}{
+		{projected: 1820, lower: 1500, upper: 2000},
+		{projected: 2000, lower: 2000, upper: 2500},
+		{projected: 120, lower: 500, upper: 1000},
+	}
+	for _, tt := range tests {
+		if lower, upper := GoalBounds(tt.projected, 500); lower != tt.lower || upper != tt.upper {
+			t.Errorf("GoalBounds(%v) = %v, %v; want %v, %v", tt.projected, lower, upper, tt.lower, tt.upper)
+		}
+	}
+}
+
+func TestBuild_NotStarted(t *testing.T) {
+	distances := Build(Summary{}, 2026, time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC), Options{})
+	data, err := json.Marshal(distances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"distance_traveled":[]}` {
+		t.Errorf("expected only an empty distance series, got %s", data)
+	}
+}
+
+// TestBuild_Golden compares Build's output for testdata/activities.json
+// with the golden files. Run with -update after intended changes to the
+// chart math and review the diff.
+func TestBuild_Golden(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "activities.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var activities []strava.Activity
+	if err := json.Unmarshal(data, &activities); err != nil {
+		t.Fatal(err)
+	}
+	summaries := Summarize(activities, []string{"Ride", "VirtualRide"})
+	today := time.Date(2025, time.March, 10, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		opts Options
+		year int
+	}{
+		{name: "2024_finished", year: 2024},
+		{name: "2025_in_progress", year: 2025},
+		{name: "2025_granularity_100", year: 2025, opts: Options{GoalGranularity: 100}},
+		{name: "2025_goals", year: 2025, opts: Options{Goals: []Goal{
+			{ID: "year", Label: "Ride 1500 miles", Target: 1500},
+			{ID: "spring-climbing", Type: GoalElevation, StartDate: "2025-03-01", EndDate: "2025-05-31", Target: 20000},
+			{ID: "rides", Type: GoalCount, StartDate: "2025-02-01", Target: 100},
+			{ID: "invalid", Target: -1},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.MarshalIndent(Build(summaries[tt.year], tt.year, today, tt.opts), "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+
+			path := filepath.Join("testdata", tt.name+".golden.json")
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Build output differs from %s; run with -update and review the diff", path)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+module github.com/andy-esch/desirelines/packages/aggregation
+
+go 1.25
+
+require github.com/andy-esch/desirelines/packages/strava v0.0.0
+
+replace github.com/andy-esch/desirelines/packages/strava => ../strava
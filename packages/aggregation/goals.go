@@ -0,0 +1,202 @@
+package aggregation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Goal types, deciding what a goal's target counts.
+const (
+	// GoalDistance targets miles.
+	GoalDistance = "distance"
+	// GoalElevation targets feet of elevation gain.
+	GoalElevation = "elevation"
+	// GoalCount targets a number of activities.
+	GoalCount = "count"
+)
+
+const (
+	// MaxGoals is the most goals a year can have.
+	MaxGoals = 10
+	// maxGoalLabelLength bounds labels, which are shown in chart legends.
+	maxGoalLabelLength = 100
+	// maxGoalIDLength bounds IDs, which the web generates.
+	maxGoalIDLength = 64
+)
+
+// GoalsBlob is the object name of an athlete's goals for a year.
+func GoalsBlob(athleteID int64, year int) string {
+	return fmt.Sprintf("goals/%d/%d.json", athleteID, year)
+}
+
+// Goal is a target to reach between two dates of a year.
+type Goal struct {
+	ID string `json:"id"`
+	// Type is one of the Goal constants; GoalDistance when empty.
+	Type  string `json:"type,omitempty"`
+	Label string `json:"label,omitempty"`
+	// StartDate and EndDate are YYYY-MM-DD dates bounding the goal,
+	// inclusive; January 1 and December 31 when empty.
+	StartDate string `json:"start_date,omitempty"`
+	EndDate   string `json:"end_date,omitempty"`
+	// Target is in the unit of Type: miles, feet or activities.
+	Target float64 `json:"target"`
+}
+
+// GoalSet is a year's goals file.
+type GoalSet struct {
+	// UpdatedAt is when the goals were last written.
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+	Goals     []Goal    `json:"goals"`
+}
+
+// Validate checks the goals for year, reporting every problem at once.
+func (s GoalSet) Validate(year int) error {
+	var errs []error
+	if len(s.Goals) > MaxGoals {
+		errs = append(errs, fmt.Errorf("at most %d goals are allowed, got %d", MaxGoals, len(s.Goals)))
+	}
+	seen := map[string]bool{}
+	for i, goal := range s.Goals {
+		if goal.ID != "" && seen[goal.ID] {
+			errs = append(errs, fmt.Errorf("goals[%d]: duplicate id %q", i, goal.ID))
+		}
+		seen[goal.ID] = true
+		if err := goal.validate(year); err != nil {
+			errs = append(errs, fmt.Errorf("goals[%d]: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validate checks one goal for year.
+func (g Goal) validate(year int) error {
+	var errs []error
+	if g.ID == "" || len(g.ID) > maxGoalIDLength {
+		errs = append(errs, fmt.Errorf("id must be 1 to %d characters", maxGoalIDLength))
+	}
+	switch g.Type {
+	case "", GoalDistance, GoalElevation, GoalCount:
+	default:
+		errs = append(errs, fmt.Errorf("invalid type %q (want %s, %s or %s)", g.Type, GoalDistance, GoalElevation, GoalCount))
+	}
+	if len(g.Label) > maxGoalLabelLength {
+		errs = append(errs, fmt.Errorf("label exceeds %d characters", maxGoalLabelLength))
+	}
+	if g.Target <= 0 {
+		errs = append(errs, errors.New("target must be positive"))
+	}
+	start, end, err := g.Dates(year)
+	switch {
+	case err != nil:
+		errs = append(errs, err)
+	case start.Year() != year || end.Year() != year:
+		errs = append(errs, fmt.Errorf("dates must be in %d", year))
+	case end.Before(start):
+		errs = append(errs, errors.New("end_date is before start_date"))
+	}
+	return errors.Join(errs...)
+}
+
+// Dates returns the goal's first and last day, defaulting to the whole of
+// year.
+func (g Goal) Dates(year int) (start, end time.Time, err error) {
+	start = time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end = time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	if g.StartDate != "" {
+		if start, err = time.Parse(time.DateOnly, g.StartDate); err != nil {
+			return start, end, fmt.Errorf("invalid start_date %q", g.StartDate)
+		}
+	}
+	if g.EndDate != "" {
+		if end, err = time.Parse(time.DateOnly, g.EndDate); err != nil {
+			return start, end, fmt.Errorf("invalid end_date %q", g.EndDate)
+		}
+	}
+	return start, end, nil
+}
+
+// GoalLine is a goal's series in distances.json.
+type GoalLine struct {
+	Goal
+	// DesireLine rises from zero before the start date to the target on the
+	// end date.
+	DesireLine []Point `json:"desire_line"`
+	// Progress is the running total of the goal's measure from the start
+	// date.
+	Progress []Point `json:"progress"`
+	// Remaining is how much of the target is left to reach, or zero.
+	Remaining float64 `json:"remaining"`
+}
+
+// goalLines returns the series of every valid goal for year through today.
+// Invalid goals are skipped rather than failing the whole chart.
+func goalLines(summary Summary, year int, today time.Time, goals []Goal) []GoalLine {
+	var lines []GoalLine
+	for _, goal := range goals {
+		if goal.validate(year) != nil {
+			continue
+		}
+		lines = append(lines, goalLine(summary, year, today, goal))
+	}
+	return lines
+}
+
+// goalLine returns the goal's series, running from its start date through
+// today or its end date, whichever is first.
+func goalLine(summary Summary, year int, today time.Time, goal Goal) GoalLine {
+	start, end, _ := goal.Dates(year)
+	totalDays := int(end.Sub(start).Hours()/24) + 1
+	last := end
+	if cutoff := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC); cutoff.Before(last) {
+		last = cutoff
+	}
+
+	line := GoalLine{Goal: goal, DesireLine: []Point{}, Progress: []Point{}}
+	if line.Type == "" {
+		line.Type = GoalDistance
+	}
+	var total float64
+	for day, n := start, 1; !day.After(last); day, n = day.AddDate(0, 0, 1), n+1 {
+		date := day.Format(time.DateOnly)
+		if entry, ok := summary[date]; ok {
+			total += entry.measure(line.Type)
+		}
+		line.DesireLine = append(line.DesireLine, Point{X: date, Y: goal.Target * float64(n) / float64(totalDays)})
+		line.Progress = append(line.Progress, Point{X: date, Y: total})
+	}
+	line.Remaining = max(0, goal.Target-total)
+	return line
+}
+
+// GoalProgress returns the goal's total of its measure over its dates in
+// year's summary.
+func GoalProgress(summary Summary, year int, goal Goal) float64 {
+	start, end, _ := goal.Dates(year)
+	goalType := goal.Type
+	if goalType == "" {
+		goalType = GoalDistance
+	}
+	// YYYY-MM-DD dates sort lexically
+	first, last := start.Format(time.DateOnly), end.Format(time.DateOnly)
+	var total float64
+	for date, day := range summary {
+		if date >= first && date <= last {
+			total += day.measure(goalType)
+		}
+	}
+	return total
+}
+
+// measure returns the day's total of what goalType counts.
+func (d *DaySummary) measure(goalType string) float64 {
+	switch goalType {
+	case GoalElevation:
+		return d.ElevationFeet
+	case GoalCount:
+		return float64(len(d.ActivityIDs))
+	default:
+		return d.DistanceMiles
+	}
+}
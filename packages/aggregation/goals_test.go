@@ -0,0 +1,95 @@
+package aggregation
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGoalSet_Validate(t *testing.T) {
+	valid := GoalSet{Goals: []Goal{
+		{ID: "a", Target: 2500},
+		{ID: "b", Type: GoalCount, StartDate: "2025-06-01", EndDate: "2025-06-30", Target: 20},
+	}}
+	if err := valid.Validate(2025); err != nil {
+		t.Errorf("expected valid goals, got %v", err)
+	}
+
+	invalid := GoalSet{Goals: []Goal{
+		{ID: "a", Type: "speed", Target: 20},
+		{ID: "a", Target: 0},
+		{ID: "c", StartDate: "2025-07-01", EndDate: "2025-06-30", Target: 1},
+		{ID: "d", EndDate: "2026-01-31", Target: 1},
+		{ID: "e", StartDate: "June", Target: 1},
+		{Target: 1},
+	}}
+	err := invalid.Validate(2025)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{
+		`goals[0]: invalid type "speed"`,
+		`goals[1]: duplicate id "a"`,
+		"goals[1]: target must be positive",
+		"goals[2]: end_date is before start_date",
+		"goals[3]: dates must be in 2025",
+		`goals[4]: invalid start_date "June"`,
+		"goals[5]: id must be",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err)
+		}
+	}
+
+	if err := (GoalSet{Goals: make([]Goal, MaxGoals+1)}).Validate(2025); err == nil || !strings.Contains(err.Error(), "at most") {
+		t.Errorf("expected too many goals to be reported, got %v", err)
+	}
+}
+
+func TestGoalLine(t *testing.T) {
+	summary := Summary{
+		"2025-01-31": {ActivityIDs: []int64{1}, DistanceMiles: 50, ElevationFeet: 900},
+		"2025-02-02": {ActivityIDs: []int64{2, 3}, DistanceMiles: 20, ElevationFeet: 300},
+	}
+	today := time.Date(2025, time.February, 3, 20, 0, 0, 0, time.UTC)
+	goal := Goal{ID: "feb", Type: GoalCount, StartDate: "2025-02-01", EndDate: "2025-02-28", Target: 28}
+
+	line := goalLine(summary, 2025, today, goal)
+	want := []Point{{"2025-02-01", 0}, {"2025-02-02", 2}, {"2025-02-03", 2}}
+	if len(line.Progress) != len(want) {
+		t.Fatalf("expected progress from the start date to today, got %v", line.Progress)
+	}
+	for i := range want {
+		if line.Progress[i] != want[i] {
+			t.Errorf("progress %d: got %+v, want %+v", i, line.Progress[i], want[i])
+		}
+	}
+	if line.DesireLine[2] != (Point{"2025-02-03", 3}) {
+		t.Errorf("expected a desire line of one a day, got %+v", line.DesireLine[2])
+	}
+	if line.Remaining != 26 {
+		t.Errorf("expected 26 to go, got %v", line.Remaining)
+	}
+
+	elevation := goalLine(summary, 2025, today, Goal{ID: "climb", Type: GoalElevation, Target: 1000})
+	if got := elevation.Progress[len(elevation.Progress)-1].Y; got != 1200 || elevation.Remaining != 0 {
+		t.Errorf("expected 1200 feet climbed and nothing left, got %v and %v", got, elevation.Remaining)
+	}
+	if distance := goalLine(summary, 2025, today, Goal{ID: "far", Target: 100}); distance.Type != GoalDistance {
+		t.Errorf("expected an untyped goal to count distance, got %q", distance.Type)
+	}
+}
+
+func TestGoalProgress(t *testing.T) {
+	summary := Summary{
+		"2025-01-31": {ActivityIDs: []int64{1}, DistanceMiles: 50, ElevationFeet: 900},
+		"2025-02-02": {ActivityIDs: []int64{2, 3}, DistanceMiles: 20, ElevationFeet: 300},
+		"2025-03-01": {ActivityIDs: []int64{4}, DistanceMiles: 5},
+	}
+	if got := GoalProgress(summary, 2025, Goal{ID: "year", Target: 1000}); got != 75 {
+		t.Errorf("expected the year's 75 miles, got %v", got)
+	}
+	if got := GoalProgress(summary, 2025, Goal{ID: "feb", Type: GoalCount, StartDate: "2025-02-01", EndDate: "2025-02-28", Target: 20}); got != 2 {
+		t.Errorf("expected February's 2 activities, got %v", got)
+	}
+}
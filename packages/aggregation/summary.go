@@ -0,0 +1,99 @@
+package aggregation
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+// milesPerKilometer matches the Python aggregator's conversion, so summaries
+// written by either agree to the last digit.
+const milesPerKilometer = 0.62137
+
+// feetPerMeter converts elevation gain for elevation goals.
+const feetPerMeter = 3.28084
+
+// SummaryBlob is the object name of a year's per-day summary, as served by
+// the API gateway.
+func SummaryBlob(year int) string {
+	return fmt.Sprintf("activities/%d/summary_activities.json", year)
+}
+
+// DistancesBlob is the object name of a year's chart series.
+func DistancesBlob(year int) string {
+	return fmt.Sprintf("activities/%d/distances.json", year)
+}
+
+// StreamsBlob is the object name of an activity's recorded streams, e.g.
+// "streams/12345.json": a strava.Streams keyed by stream type.
+func StreamsBlob(activityID int64) string {
+	return fmt.Sprintf("streams/%d.json", activityID)
+}
+
+// DaySummary totals the counted activities that started on one local date.
+type DaySummary struct {
+	ActivityIDs   []int64 `json:"activity_ids"`
+	DistanceMiles float64 `json:"distance_miles"`
+	// ElevationFeet is missing from summaries written before elevation
+	// goals, and from the Python aggregator's.
+	ElevationFeet float64 `json:"elevation_feet,omitempty"`
+}
+
+// Summary is a year's summary_activities.json, keyed by YYYY-MM-DD.
+type Summary map[string]*DaySummary
+
+// Summarize counts activities of the given types, or of every type if none
+// are given, into summaries keyed by the year of their local start date.
+func Summarize(activities []strava.Activity, activityTypes []string) map[int]Summary {
+	summaries := map[int]Summary{}
+	for _, activity := range activities {
+		if len(activityTypes) > 0 && !slices.Contains(activityTypes, activity.Type) {
+			continue
+		}
+		year := activity.StartDateLocal.Year()
+		if summaries[year] == nil {
+			summaries[year] = Summary{}
+		}
+		summaries[year].Add(activity)
+	}
+	return summaries
+}
+
+// Add counts activity on its date. It reports false when the activity is
+// already counted, so redelivered events don't double its distance.
+func (s Summary) Add(activity strava.Activity) bool {
+	date := activity.Date()
+	day, ok := s[date]
+	if !ok {
+		s[date] = &DaySummary{ActivityIDs: []int64{activity.ID}, DistanceMiles: DistanceMiles(activity), ElevationFeet: ElevationFeet(activity)}
+		return true
+	}
+	if slices.Contains(day.ActivityIDs, activity.ID) {
+		return false
+	}
+	day.ActivityIDs = append(day.ActivityIDs, activity.ID)
+	day.DistanceMiles += DistanceMiles(activity)
+	day.ElevationFeet += ElevationFeet(activity)
+	return true
+}
+
+// Find returns the date activity id is counted on.
+func (s Summary) Find(id int64) (string, bool) {
+	for date, day := range s {
+		if slices.Contains(day.ActivityIDs, id) {
+			return date, true
+		}
+	}
+	return "", false
+}
+
+// DistanceMiles converts the activity's distance from meters.
+func DistanceMiles(activity strava.Activity) float64 {
+	return activity.Distance / 1000 * milesPerKilometer
+}
+
+// ElevationFeet converts the activity's elevation gain from meters.
+func ElevationFeet(activity strava.Activity) float64 {
+	return activity.TotalElevationGain * feetPerMeter
+}
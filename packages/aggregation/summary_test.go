@@ -0,0 +1,69 @@
+package aggregation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+func ride(id int64, start string, meters float64) strava.Activity {
+	startDate, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		panic(err)
+	}
+	return strava.Activity{ID: id, Type: "Ride", StartDateLocal: startDate, Distance: meters}
+}
+
+func TestSummary_Add(t *testing.T) {
+	summary := Summary{}
+	first := ride(1, "2025-01-02T07:00:00Z", 10000)
+	second := ride(2, "2025-01-02T18:00:00Z", 5000)
+	second.TotalElevationGain = 100
+
+	if !summary.Add(first) || !summary.Add(second) {
+		t.Fatal("expected new activities to be added")
+	}
+	if summary.Add(first) {
+		t.Error("expected a redelivered activity not to be added again")
+	}
+
+	day := summary["2025-01-02"]
+	if len(day.ActivityIDs) != 2 {
+		t.Errorf("expected 2 activity IDs, got %v", day.ActivityIDs)
+	}
+	if want := DistanceMiles(first) + DistanceMiles(second); day.DistanceMiles != want {
+		t.Errorf("expected %v miles, got %v", want, day.DistanceMiles)
+	}
+	if day.ElevationFeet != ElevationFeet(second) {
+		t.Errorf("expected %v feet, got %v", ElevationFeet(second), day.ElevationFeet)
+	}
+	if date, ok := summary.Find(2); !ok || date != "2025-01-02" {
+		t.Errorf("Find(2) = %q, %v", date, ok)
+	}
+	if _, ok := summary.Find(3); ok {
+		t.Error("expected Find to miss an uncounted activity")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	run := ride(3, "2025-01-03T07:00:00Z", 8000)
+	run.Type = "Run"
+	activities := []strava.Activity{
+		ride(1, "2024-12-31T23:30:00Z", 10000),
+		ride(2, "2025-01-01T00:10:00Z", 20000),
+		run,
+	}
+
+	summaries := Summarize(activities, []string{"Ride"})
+	if len(summaries) != 2 || len(summaries[2024]) != 1 || len(summaries[2025]) != 1 {
+		t.Fatalf("expected one ride in each year by local date, got %v", summaries)
+	}
+	if _, ok := summaries[2025].Find(3); ok {
+		t.Error("expected the run not to be counted")
+	}
+
+	if got := Summarize(activities, nil)[2025]; len(got) != 2 {
+		t.Errorf("expected every type to be counted without a filter, got %v", got)
+	}
+}
@@ -0,0 +1,222 @@
+// Package alert posts pipeline failures to a Slack or Discord incoming
+// webhook, so a broken pipeline is noticed without watching the logs.
+//
+// Each function opens one Alerter with its name and sends through it:
+//
+//	alerter := alert.Open(cfg, "dispatcher")
+//	if err := alerter.Send(ctx, alert.Alert{Key: "dead_letter", Title: "Webhook dead-lettered", Text: err.Error()}); err != nil {
+//		Logger.Warn("Failed to send alert", "error", err)
+//	}
+//
+// A nil Alerter sends nothing, so callers don't check whether alerts are
+// enabled. Alerts with the same key are sent at most once per cooldown;
+// the next one counts those suppressed in between.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Webhook formats.
+const (
+	FormatSlack   = "slack"
+	FormatDiscord = "discord"
+)
+
+// DefaultCooldown is how long alerts with the same key are suppressed
+// after one is sent. Failed events are redelivered every few seconds, and
+// one message per incident is enough.
+const DefaultCooldown = 15 * time.Minute
+
+// sendTimeout bounds each post, so a slow webhook doesn't hold up the
+// request that failed.
+const sendTimeout = 5 * time.Second
+
+// maxErrorBodyBytes bounds how much of a failed response is reported.
+const maxErrorBodyBytes = 512
+
+// Field is a labelled value shown with an alert.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Alert is one failure to report.
+type Alert struct {
+	// Key groups alerts for throttling, e.g. "dead_letter"; the title
+	// when empty.
+	Key   string
+	Title string
+	// Text details the failure, usually the error.
+	Text   string
+	Fields []Field
+}
+
+// Alerter posts alerts to a webhook, throttled per key.
+type Alerter struct {
+	httpClient *http.Client
+	now        func() time.Time
+	// last is when each key was last sent and suppressed how many were
+	// skipped since
+	last       map[string]time.Time
+	suppressed map[string]int
+	url        string
+	format     string
+	source     string
+	cooldown   time.Duration
+	mu         sync.Mutex
+}
+
+// Option configures an Alerter.
+type Option func(*Alerter)
+
+// WithHTTPClient posts with httpClient instead of one with a five second
+// timeout.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(a *Alerter) {
+		a.httpClient = httpClient
+	}
+}
+
+// WithCooldown suppresses repeats of a key for cooldown instead of
+// DefaultCooldown; zero sends every alert.
+func WithCooldown(cooldown time.Duration) Option {
+	return func(a *Alerter) {
+		a.cooldown = cooldown
+	}
+}
+
+// New creates an alerter posting to a Slack or Discord incoming webhook
+// URL in format, naming source (e.g. "processor") in every alert.
+func New(webhookURL, format, source string, opts ...Option) *Alerter {
+	a := &Alerter{
+		httpClient: &http.Client{Timeout: sendTimeout},
+		now:        time.Now,
+		last:       map[string]time.Time{},
+		suppressed: map[string]int{},
+		url:        webhookURL,
+		format:     format,
+		source:     source,
+		cooldown:   DefaultCooldown,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Send posts alert unless one with its key was sent within the cooldown.
+// Callers log a returned error rather than fail on it: an alert is never
+// worth failing the request it reports on.
+func (a *Alerter) Send(ctx context.Context, alert Alert) error {
+	if a == nil {
+		return nil
+	}
+	key := alert.Key
+	if key == "" {
+		key = alert.Title
+	}
+	suppressed, ok := a.reserve(key)
+	if !ok {
+		return nil
+	}
+	if suppressed > 0 {
+		alert.Fields = append(alert.Fields, Field{Name: "Suppressed", Value: fmt.Sprintf("%d more since the last alert", suppressed)})
+	}
+
+	body, err := a.payload(alert)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+	sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+	defer cancel()
+	if err := a.post(sendCtx, body); err != nil {
+		return fmt.Errorf("failed to send %s alert: %w", key, err)
+	}
+	return nil
+}
+
+// reserve records a send of key, returning how many were suppressed since
+// the last, or false if key is still cooling down.
+func (a *Alerter) reserve(key string) (int, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	if last, ok := a.last[key]; ok && now.Sub(last) < a.cooldown {
+		a.suppressed[key]++
+		return 0, false
+	}
+	a.last[key] = now
+	suppressed := a.suppressed[key]
+	delete(a.suppressed, key)
+	return suppressed, true
+}
+
+// payload encodes alert for the webhook's format: a Discord embed, or a
+// Slack message with an attachment.
+func (a *Alerter) payload(alert Alert) ([]byte, error) {
+	title := fmt.Sprintf("[%s] %s", a.source, alert.Title)
+	if a.format == FormatDiscord {
+		type field struct {
+			Name   string `json:"name"`
+			Value  string `json:"value"`
+			Inline bool   `json:"inline"`
+		}
+		fields := make([]field, len(alert.Fields))
+		for i, f := range alert.Fields {
+			fields[i] = field{Name: f.Name, Value: f.Value, Inline: true}
+		}
+		return json.Marshal(map[string]any{"embeds": []map[string]any{{
+			"title":       title,
+			"description": alert.Text,
+			"color":       0xd00000,
+			"fields":      fields,
+		}}})
+	}
+
+	type field struct {
+		Title string `json:"title"`
+		Value string `json:"value"`
+		Short bool   `json:"short"`
+	}
+	fields := make([]field, len(alert.Fields))
+	for i, f := range alert.Fields {
+		fields[i] = field{Title: f.Name, Value: f.Value, Short: true}
+	}
+	return json.Marshal(map[string]any{
+		"text": title,
+		"attachments": []map[string]any{{
+			"color":  "#d00000",
+			"text":   alert.Text,
+			"fields": fields,
+		}},
+	})
+}
+
+// post sends body to the webhook, failing on a non-2xx response.
+func (a *Alerter) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
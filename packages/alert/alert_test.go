@@ -0,0 +1,105 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// webhookServer answers with status and records each request body.
+func webhookServer(t *testing.T, status int) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("invalid body %s: %v", data, err)
+		}
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("invalid_token"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &bodies
+}
+
+func TestAlerter_SendSlack(t *testing.T) {
+	server, bodies := webhookServer(t, http.StatusOK)
+	alerter := New(server.URL, FormatSlack, "processor")
+	err := alerter.Send(context.Background(), Alert{Title: "Event failed", Text: "storage down", Fields: []Field{{Name: "Object", Value: "42"}}})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(*bodies) != 1 {
+		t.Fatalf("expected one post, got %d", len(*bodies))
+	}
+	body := (*bodies)[0]
+	attachment := body["attachments"].([]any)[0].(map[string]any)
+	field := attachment["fields"].([]any)[0].(map[string]any)
+	if body["text"] != "[processor] Event failed" || attachment["text"] != "storage down" || field["title"] != "Object" || field["value"] != "42" {
+		t.Errorf("unexpected Slack message %v", body)
+	}
+}
+
+func TestAlerter_SendDiscord(t *testing.T) {
+	server, bodies := webhookServer(t, http.StatusNoContent)
+	alerter := New(server.URL, FormatDiscord, "reconciler")
+	if err := alerter.Send(context.Background(), Alert{Title: "Gaps found", Text: "12 missing", Fields: []Field{{Name: "Missing", Value: "12"}}}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	embed := (*bodies)[0]["embeds"].([]any)[0].(map[string]any)
+	field := embed["fields"].([]any)[0].(map[string]any)
+	if embed["title"] != "[reconciler] Gaps found" || embed["description"] != "12 missing" || field["name"] != "Missing" {
+		t.Errorf("unexpected Discord embed %v", embed)
+	}
+}
+
+func TestAlerter_Throttles(t *testing.T) {
+	server, bodies := webhookServer(t, http.StatusOK)
+	now := time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+	alerter := New(server.URL, FormatSlack, "processor", WithCooldown(time.Minute))
+	alerter.now = func() time.Time { return now }
+
+	send := func(key string) {
+		t.Helper()
+		if err := alerter.Send(context.Background(), Alert{Key: key, Title: "Event failed"}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	send("failure")
+	send("failure")
+	send("failure")
+	send("other")
+	if len(*bodies) != 2 {
+		t.Fatalf("expected repeats within the cooldown suppressed, got %d posts", len(*bodies))
+	}
+
+	now = now.Add(time.Minute)
+	send("failure")
+	if len(*bodies) != 3 {
+		t.Fatalf("expected a post after the cooldown, got %d", len(*bodies))
+	}
+	fields := (*bodies)[2]["attachments"].([]any)[0].(map[string]any)["fields"].([]any)
+	if len(fields) != 1 || fields[0].(map[string]any)["value"] != "2 more since the last alert" {
+		t.Errorf("expected the suppressed alerts counted, got %v", fields)
+	}
+}
+
+func TestAlerter_SendFailures(t *testing.T) {
+	server, _ := webhookServer(t, http.StatusForbidden)
+	err := New(server.URL, FormatSlack, "dispatcher").Send(context.Background(), Alert{Title: "Dead-lettered"})
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "invalid_token") {
+		t.Errorf("expected the rejection reported, got %v", err)
+	}
+
+	var disabled *Alerter
+	if err := disabled.Send(context.Background(), Alert{Title: "Dead-lettered"}); err != nil {
+		t.Errorf("expected a nil alerter to send nothing, got %v", err)
+	}
+}
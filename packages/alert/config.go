@@ -0,0 +1,80 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/config"
+)
+
+// Config locates the webhook alerts are posted to.
+type Config struct {
+	// WebhookURL is a Slack or Discord incoming webhook; empty disables
+	// alerts.
+	WebhookURL string
+	// Format is FormatSlack or FormatDiscord, inferred from WebhookURL's
+	// host when ALERT_WEBHOOK_FORMAT is unset.
+	Format   string
+	Cooldown time.Duration
+}
+
+// LoadConfig loads the alert settings from ALERT_WEBHOOK_URL,
+// ALERT_WEBHOOK_FORMAT and ALERT_COOLDOWN.
+func LoadConfig() (*Config, error) {
+	cooldown, err := config.Duration("ALERT_COOLDOWN", DefaultCooldown)
+	if err != nil {
+		return nil, err
+	}
+	webhookURL := config.Get("ALERT_WEBHOOK_URL")
+	return &Config{
+		WebhookURL: webhookURL,
+		Format:     config.GetOrDefault("ALERT_WEBHOOK_FORMAT", inferFormat(webhookURL)),
+		Cooldown:   cooldown,
+	}, nil
+}
+
+// inferFormat returns FormatDiscord for Discord's webhook hosts and
+// FormatSlack otherwise.
+func inferFormat(webhookURL string) string {
+	u, err := url.Parse(webhookURL)
+	if err == nil && (u.Hostname() == "discord.com" || strings.HasSuffix(u.Hostname(), ".discord.com") || u.Hostname() == "discordapp.com") {
+		return FormatDiscord
+	}
+	return FormatSlack
+}
+
+// Enabled reports whether alerts are posted; a nil Config posts none.
+func (c *Config) Enabled() bool {
+	return c != nil && c.WebhookURL != ""
+}
+
+// Validate checks the webhook URL and format, reporting every problem at
+// once.
+func (c *Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	var errs []error
+	if u, err := url.Parse(c.WebhookURL); err != nil || u.Scheme != "https" || u.Host == "" {
+		errs = append(errs, errors.New("ALERT_WEBHOOK_URL must be an https URL"))
+	}
+	if c.Format != FormatSlack && c.Format != FormatDiscord {
+		errs = append(errs, fmt.Errorf("invalid ALERT_WEBHOOK_FORMAT %q (want %s or %s)", c.Format, FormatSlack, FormatDiscord))
+	}
+	if c.Cooldown < 0 {
+		errs = append(errs, fmt.Errorf("ALERT_COOLDOWN must not be negative, got %s", c.Cooldown))
+	}
+	return errors.Join(errs...)
+}
+
+// Open creates an alerter for source from cfg, or nil, which sends
+// nothing, when alerts are disabled. Validate must have passed.
+func Open(cfg *Config, source string, opts ...Option) *Alerter {
+	if !cfg.Enabled() {
+		return nil
+	}
+	return New(cfg.WebhookURL, cfg.Format, source, append([]Option{WithCooldown(cfg.Cooldown)}, opts...)...)
+}
@@ -0,0 +1,50 @@
+package alert
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := LoadConfig()
+	if err != nil || cfg.Enabled() || Open(cfg, "processor") != nil {
+		t.Errorf("expected alerts disabled without ALERT_WEBHOOK_URL, got %+v, %v", cfg, err)
+	}
+
+	t.Setenv("ALERT_WEBHOOK_URL", "https://discord.com/api/webhooks/1/abc")
+	t.Setenv("ALERT_COOLDOWN", "5m")
+	cfg, err = LoadConfig()
+	if err != nil || !cfg.Enabled() || cfg.Format != FormatDiscord || cfg.Cooldown != 5*time.Minute {
+		t.Errorf("unexpected config %+v, %v", cfg, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected valid config, got %v", err)
+	}
+	if alerter := Open(cfg, "processor"); alerter == nil || alerter.cooldown != 5*time.Minute {
+		t.Errorf("expected an alerter with the cooldown, got %+v", alerter)
+	}
+
+	t.Setenv("ALERT_WEBHOOK_URL", "https://hooks.slack.com/services/T/B/x")
+	if cfg, _ := LoadConfig(); cfg.Format != FormatSlack {
+		t.Errorf("expected Slack inferred, got %q", cfg.Format)
+	}
+
+	t.Setenv("ALERT_COOLDOWN", "soon")
+	if _, err := LoadConfig(); err == nil {
+		t.Error("expected an invalid ALERT_COOLDOWN to fail")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	err := (&Config{WebhookURL: "http://hooks.example.com", Format: "teams", Cooldown: -time.Second}).Validate()
+	for _, want := range []string{"https URL", `"teams"`, "ALERT_COOLDOWN"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+	var disabled *Config
+	if err := disabled.Validate(); err != nil {
+		t.Errorf("expected a nil config to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+// Package apiclient is a typed Go client for the desirelines API Gateway.
+//
+//	client := apiclient.New("http://localhost:8084")
+//	summary, err := client.GetSummary(ctx, 2024)
+//
+// Requests are retried with exponential backoff on network errors, 429 and
+// 5xx responses; every call honours its context.
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+)
+
+const (
+	// DefaultTimeout bounds a single HTTP attempt when no client is supplied.
+	DefaultTimeout = 30 * time.Second
+	// DefaultMaxRetries is the number of retries after the first attempt.
+	DefaultMaxRetries = 3
+	// DefaultRetryBackoff is the delay before the first retry; it doubles on
+	// each subsequent retry.
+	DefaultRetryBackoff = 500 * time.Millisecond
+
+	// maxRetryDelay caps backoff and server-supplied Retry-After values.
+	maxRetryDelay = 30 * time.Second
+)
+
+// Client calls the API Gateway. It is safe for concurrent use.
+type Client struct {
+	baseURL      string
+	httpClient   *http.Client
+	maxRetries   int
+	retryBackoff time.Duration
+	userAgent    string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient replaces the default HTTP client.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries sets how many times a failed request is retried and the
+// initial backoff between attempts. maxRetries of 0 disables retries.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryBackoff = backoff
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with each request.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// New creates a client for the gateway at baseURL (e.g. "http://localhost:8084").
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		httpClient:   &http.Client{Timeout: DefaultTimeout},
+		maxRetries:   DefaultMaxRetries,
+		retryBackoff: DefaultRetryBackoff,
+		userAgent:    "desirelines-apiclient",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Health calls GET /health. With deep set, the gateway also probes storage.
+func (c *Client) Health(ctx context.Context, deep bool) (*types.HealthResponse, error) {
+	path := "/health"
+	if deep {
+		path += "?deep=true"
+	}
+
+	var response types.HealthResponse
+	if err := c.get(ctx, path, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetSummary fetches the daily activity summary for a year.
+func (c *Client) GetSummary(ctx context.Context, year int) (Summary, error) {
+	var summary Summary
+	if err := c.get(ctx, fmt.Sprintf("/activities/%d/summary", year), &summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// GetDistances fetches the cumulative distance series for a year.
+func (c *Client) GetDistances(ctx context.Context, year int) (*Distances, error) {
+	var distances Distances
+	if err := c.get(ctx, fmt.Sprintf("/activities/%d/distances", year), &distances); err != nil {
+		return nil, err
+	}
+	return &distances, nil
+}
+
+// GetFreshness fetches when a year's data was last written.
+func (c *Client) GetFreshness(ctx context.Context, year int) (*types.FreshnessResponse, error) {
+	var freshness types.FreshnessResponse
+	if err := c.get(ctx, fmt.Sprintf("/activities/%d/freshness", year), &freshness); err != nil {
+		return nil, err
+	}
+	return &freshness, nil
+}
+
+// GetStats fetches a year's summary and reduces it to headline totals.
+func (c *Client) GetStats(ctx context.Context, year int) (*Stats, error) {
+	summary, err := c.GetSummary(ctx, year)
+	if err != nil {
+		return nil, err
+	}
+	stats := summary.Stats()
+	return &stats, nil
+}
+
+// get performs a GET request with retries and decodes a JSON response into out.
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	url := c.baseURL + path
+
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			delay := c.backoff(attempt, lastErr)
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("GET %s: %w (last error: %v)", path, ctx.Err(), lastErr)
+			case <-time.After(delay):
+			}
+		}
+
+		retry, err := c.do(ctx, url, out)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || ctx.Err() != nil {
+			break
+		}
+	}
+	return fmt.Errorf("GET %s: %w", path, lastErr)
+}
+
+// do performs a single attempt and reports whether a failure is retryable.
+func (c *Client) do(ctx context.Context, url string, out interface{}) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := newAPIError(resp)
+		return apiErr.Retryable(), apiErr
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return false, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return false, nil
+}
+
+// backoff returns the delay before the given retry attempt, preferring the
+// server's Retry-After hint when one was sent.
+func (c *Client) backoff(attempt int, lastErr error) time.Duration {
+	var apiErr *APIError
+	if errors.As(lastErr, &apiErr) && apiErr.RetryAfter > 0 {
+		return min(apiErr.RetryAfter, maxRetryDelay)
+	}
+
+	delay := time.Duration(float64(c.retryBackoff) * math.Pow(2, float64(attempt-1)))
+	return min(delay, maxRetryDelay)
+}
+
+// APIError is a non-200 response from the gateway.
+type APIError struct {
+	StatusCode int
+	// Response is the gateway's error envelope; Code is empty if the body
+	// could not be decoded (e.g. a proxy error page).
+	Response   types.ErrorResponse
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Response.Message == "" {
+		return fmt.Sprintf("gateway returned %d", e.StatusCode)
+	}
+	return fmt.Sprintf("gateway returned %d %s: %s (correlation_id=%s)",
+		e.StatusCode, e.Response.Code, e.Response.Message, e.Response.CorrelationID)
+}
+
+// Retryable reports whether the request may succeed if sent again.
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// IsNotFound reports whether err is a 404 from the gateway.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err == nil {
+		_ = json.Unmarshal(body, &apiErr.Response)
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
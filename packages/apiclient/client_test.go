@@ -0,0 +1,155 @@
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+)
+
+func newTestClient(url string) *Client {
+	return New(url, WithRetries(2, time.Millisecond))
+}
+
+func TestClientGetSummary(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/activities/2024/summary" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{
+			"2024-01-01": {"distance_miles": 10.5, "activity_ids": [1]},
+			"2024-01-03": {"distance_miles": 2.5, "activity_ids": [2, 3]}
+		}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL)
+
+	summary, err := client.GetSummary(context.Background(), 2024)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := summary["2024-01-03"].ActivityIDs; len(got) != 2 {
+		t.Errorf("expected 2 activities on 2024-01-03, got %v", got)
+	}
+
+	stats, err := client.GetStats(context.Background(), 2024)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Stats{TotalMiles: 13, ActivityCount: 3, ActiveDays: 2, FirstDate: "2024-01-01", LastDate: "2024-01-03"}
+	if *stats != want {
+		t.Errorf("expected %+v, got %+v", want, *stats)
+	}
+}
+
+func TestClientGetDistances(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{
+			"distance_traveled": [{"x": "2024-01-01", "y": 11.1}],
+			"summaries": {"2000": ["100 miles to go"]}
+		}`))
+	}))
+	defer server.Close()
+
+	distances, err := newTestClient(server.URL).GetDistances(context.Background(), 2024)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(distances.DistanceTraveled) != 1 || distances.DistanceTraveled[0].Y != 11.1 {
+		t.Errorf("unexpected distance_traveled %+v", distances.DistanceTraveled)
+	}
+	if distances.Summaries["2000"][0] != "100 miles to go" {
+		t.Errorf("unexpected summaries %+v", distances.Summaries)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	t.Run("retries server errors then succeeds", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(`{"status": "healthy"}`))
+		}))
+		defer server.Close()
+
+		health, err := newTestClient(server.URL).Health(context.Background(), false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if health.Status != "healthy" {
+			t.Errorf("expected healthy, got %s", health.Status)
+		}
+		if got := calls.Load(); got != 3 {
+			t.Errorf("expected 3 attempts, got %d", got)
+		}
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(types.ErrorResponse{
+				Code:          types.ErrCodeNotFound,
+				Message:       "Data not found for 2019/summary",
+				CorrelationID: "abc",
+			})
+		}))
+		defer server.Close()
+
+		_, err := newTestClient(server.URL).GetSummary(context.Background(), 2019)
+		if !IsNotFound(err) {
+			t.Fatalf("expected not found error, got %v", err)
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Response.Code != types.ErrCodeNotFound {
+			t.Errorf("expected decoded error envelope, got %v", err)
+		}
+		if got := calls.Load(); got != 1 {
+			t.Errorf("expected 1 attempt, got %d", got)
+		}
+	})
+
+	t.Run("gives up after max retries", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		_, err := newTestClient(server.URL).GetDistances(context.Background(), 2024)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if got := calls.Load(); got != 3 {
+			t.Errorf("expected 3 attempts, got %d", got)
+		}
+	})
+
+	t.Run("stops when context is cancelled", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "10")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := newTestClient(server.URL).GetSummary(ctx, 2024)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+	})
+}
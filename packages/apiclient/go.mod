@@ -0,0 +1,9 @@
+module github.com/andy-esch/desirelines/packages/apiclient
+
+go 1.25
+
+require github.com/andy-esch/desirelines/packages/apigateway v0.0.0
+
+replace github.com/andy-esch/desirelines/packages/apigateway => ../apigateway
+
+replace github.com/andy-esch/desirelines/packages/config => ../config
@@ -0,0 +1,55 @@
+package apiclient
+
+// DaySummary is one day's entry in summary_activities.json.
+type DaySummary struct {
+	DistanceMiles float64 `json:"distance_miles"`
+	ActivityIDs   []int64 `json:"activity_ids"`
+}
+
+// Summary maps YYYY-MM-DD dates to that day's activities.
+type Summary map[string]DaySummary
+
+// Point is one date/value sample in a distance series.
+type Point struct {
+	X string  `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Distances is the cumulative distance data behind the year chart.
+// Summaries maps goal distances (as strings) to pacing notes.
+type Distances struct {
+	DistanceTraveled []Point             `json:"distance_traveled"`
+	AvgDistance      []Point             `json:"avg_distance,omitempty"`
+	UpperDistance    []Point             `json:"upper_distance,omitempty"`
+	LowerDistance    []Point             `json:"lower_distance,omitempty"`
+	Summaries        map[string][]string `json:"summaries,omitempty"`
+}
+
+// Stats are headline totals for a year.
+type Stats struct {
+	TotalMiles    float64 `json:"total_miles"`
+	ActivityCount int     `json:"activity_count"`
+	ActiveDays    int     `json:"active_days"`
+	FirstDate     string  `json:"first_date,omitempty"`
+	LastDate      string  `json:"last_date,omitempty"`
+}
+
+// Stats reduces the summary to headline totals.
+func (s Summary) Stats() Stats {
+	var stats Stats
+	for date, day := range s {
+		stats.TotalMiles += day.DistanceMiles
+		stats.ActivityCount += len(day.ActivityIDs)
+		if len(day.ActivityIDs) > 0 || day.DistanceMiles > 0 {
+			stats.ActiveDays++
+		}
+		// YYYY-MM-DD sorts lexically
+		if stats.FirstDate == "" || date < stats.FirstDate {
+			stats.FirstDate = date
+		}
+		if date > stats.LastDate {
+			stats.LastDate = date
+		}
+	}
+	return stats
+}
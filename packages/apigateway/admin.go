@@ -0,0 +1,60 @@
+package apigateway
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/storage"
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+)
+
+// authorizeAdmin checks the request's bearer token against ADMIN_TOKEN.
+// Admin endpoints are disabled (404) when no token is configured.
+func (h *Handler) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if h.adminToken == "" {
+		h.respondError(w, r, http.StatusNotFound, types.ErrCodeNotFound, "Not found", "")
+		return false
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+		h.respondError(w, r, http.StatusUnauthorized, types.ErrCodeUnauthorized, "Invalid admin token", "")
+		return false
+	}
+	return true
+}
+
+// handleCachePurge drops cached storage reads under a path prefix so fresh
+// data is served immediately, e.g. after a backfill. An empty prefix purges
+// everything.
+func (h *Handler) handleCachePurge(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.respondError(w, r, http.StatusMethodNotAllowed, types.ErrCodeMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+
+	var req types.CachePurgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeInvalidPayload, "Invalid JSON payload", "")
+		return
+	}
+
+	purged := 0
+	if inv, ok := h.storage.(storage.PrefixInvalidator); ok {
+		purged = inv.InvalidatePrefix(req.Prefix)
+	}
+
+	log.Printf("Admin: purged %d cache entries with prefix %q", purged, req.Prefix)
+	h.respondJSON(w, r, http.StatusOK, types.CachePurgeResponse{
+		Prefix: req.Prefix,
+		Purged: purged,
+	})
+}
@@ -0,0 +1,86 @@
+package apigateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/storage"
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+)
+
+func TestHandlerCachePurge(t *testing.T) {
+	mock := &mockStorageClient{
+		ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+			return map[string]interface{}{}, nil
+		},
+	}
+	handler := NewHandlerWithStorage(storage.NewMemoryCacheClient(mock, time.Hour))
+	handler.adminToken = "admin-secret"
+
+	// Warm the cache
+	for _, path := range []string{"/activities/2024/distances", "/activities/2024/summary", "/activities/2023/distances"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	t.Run("purges entries under prefix", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/cache/purge", strings.NewReader(`{"prefix":"activities/2024/"}`))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+
+		var response types.CachePurgeResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if response.Purged != 2 {
+			t.Errorf("expected 2 entries purged, got %d", response.Purged)
+		}
+	})
+
+	t.Run("rejects missing token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/admin/cache/purge", strings.NewReader(`{}`))
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status 401, got %d", w.Code)
+		}
+	})
+
+	t.Run("rejects GET", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/admin/cache/purge", nil)
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status 405, got %d", w.Code)
+		}
+	})
+
+	t.Run("disabled without admin token", func(t *testing.T) {
+		handler := NewHandlerWithStorage(&mockStorageClient{})
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/cache/purge", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer ")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", w.Code)
+		}
+	})
+}
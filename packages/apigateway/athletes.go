@@ -0,0 +1,96 @@
+package apigateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/storage"
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+	"github.com/andy-esch/desirelines/packages/athletes"
+)
+
+// openAthleteRegistry opens the registry cfg locates.
+func openAthleteRegistry(ctx context.Context, cfg *athletes.Config) (*athletes.Registry, error) {
+	bucket, object, ok := cfg.GCS()
+	if !ok {
+		return athletes.New(athletes.FileLoader(cfg.Location), cfg.CacheTTL), nil
+	}
+	client, err := storage.NewCloudStorageBucketClient(ctx, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create athlete registry client: %w", err)
+	}
+	load := func(ctx context.Context) ([]byte, error) {
+		blob, err := client.ReadBlob(ctx, object)
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read athlete registry: %w", err)
+		}
+		if blob.ContentEncoding == "gzip" {
+			return gunzip(blob.Data)
+		}
+		return blob.Data, nil
+	}
+	return athletes.New(load, cfg.CacheTTL), nil
+}
+
+// handleAthlete serves a registered athlete's data at
+// /athletes/{athlete_id}/activities/{year}/{type}, which also takes PUTs,
+// and /athletes/{athlete_id}/digests/{year}/{week}, read from the
+// athlete's storage prefix, and exports their activities at
+// /athletes/{athlete_id}/activities/{activity_id}/export.
+func (h *Handler) handleAthlete(w http.ResponseWriter, r *http.Request, path string) {
+	parts := strings.SplitN(path, "/", 3)
+	if len(parts) != 3 || !strings.HasPrefix(parts[2], "activities/") && !strings.HasPrefix(parts[2], "digests/") {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeBadRequest, "Invalid path format. Expected: /athletes/{athlete_id}/activities/{year}/{type} or /athletes/{athlete_id}/digests/{year}/{week}", "")
+		return
+	}
+
+	athleteID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || athleteID <= 0 {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeBadRequest, fmt.Sprintf("Invalid athlete ID: %s", parts[1]), "")
+		return
+	}
+	athlete, ok := h.lookupAthlete(w, r, athleteID)
+	if !ok {
+		return
+	}
+	if strings.HasPrefix(parts[2], "digests/") {
+		h.handleDigest(w, r, athlete.Prefix, parts[2])
+		return
+	}
+	// Exports are read-only; handleActivities rejects a PUT to one
+	if rest := strings.Split(parts[2], "/"); len(rest) == 3 && rest[2] == "export" && r.Method != http.MethodPut {
+		var source activitySource
+		if h.athleteStrava != nil {
+			source = h.athleteStrava(athlete)
+		}
+		h.handleExport(w, r, source, rest[1])
+		return
+	}
+	h.handleActivities(w, r, athlete.Prefix, parts[2])
+}
+
+// lookupAthlete returns the registered athlete, responding with a 404 for
+// an unregistered one or without a registry.
+func (h *Handler) lookupAthlete(w http.ResponseWriter, r *http.Request, athleteID int64) (athletes.Athlete, bool) {
+	if h.athletes == nil {
+		h.respondError(w, r, http.StatusNotFound, types.ErrCodeNotFound, "Not found", "")
+		return athletes.Athlete{}, false
+	}
+	athlete, err := h.athletes.Lookup(r.Context(), athleteID)
+	switch {
+	case errors.Is(err, athletes.ErrUnknownAthlete):
+		h.respondError(w, r, http.StatusNotFound, types.ErrCodeNotFound, fmt.Sprintf("Unknown athlete: %d", athleteID), "")
+		return athletes.Athlete{}, false
+	case err != nil:
+		h.respondStorageError(w, r, err, "athlete registry", fmt.Sprintf("athlete %d", athleteID))
+		return athletes.Athlete{}, false
+	}
+	return athlete, true
+}
@@ -0,0 +1,93 @@
+package apigateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/apigateway/storage"
+	"github.com/andy-esch/desirelines/packages/athletes"
+)
+
+func TestHandlerAthleteRoutes(t *testing.T) {
+	var reads []string
+	mock := &mockStorageClient{
+		ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+			reads = append(reads, blobPath)
+			return map[string]interface{}{}, nil
+		},
+	}
+	handler := NewHandlerWithStorage(mock)
+
+	get := func(path string) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	if code := get("/athletes/7/activities/2025/distances"); code != http.StatusNotFound {
+		t.Errorf("expected 404 without a registry, got %d", code)
+	}
+
+	handler.athletes = athletes.New(func(context.Context) ([]byte, error) {
+		return []byte(`{"athletes": [{"id": 7, "prefix": "athletes/7/"}, {"id": 8, "prefix": ""}]}`), nil
+	}, 0)
+
+	tests := []struct {
+		path string
+		want int
+		read string
+	}{
+		{"/athletes/7/activities/2025/distances", http.StatusOK, "athletes/7/activities/2025/distances.json"},
+		{"/athletes/8/activities/2025/summary", http.StatusOK, "activities/2025/summary_activities.json"},
+		{"/activities/2025/distances", http.StatusOK, "activities/2025/distances.json"},
+		{"/athletes/9/activities/2025/distances", http.StatusNotFound, ""},
+		{"/athletes/x/activities/2025/distances", http.StatusBadRequest, ""},
+		{"/athletes/7/goals/2025", http.StatusBadRequest, ""},
+		{"/athletes/7/activities/2025", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			reads = nil
+			if code := get(tt.path); code != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, code)
+			}
+			if tt.read == "" && len(reads) != 0 || tt.read != "" && (len(reads) != 1 || reads[0] != tt.read) {
+				t.Errorf("expected read of %q, got %v", tt.read, reads)
+			}
+		})
+	}
+}
+
+func TestHandlerAthleteGoals(t *testing.T) {
+	local, err := storage.NewLocalStorageClient(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	handler := NewHandlerWithStorage(local)
+	handler.adminToken = "admin-secret"
+	handler.athletes = athletes.New(func(context.Context) ([]byte, error) {
+		return []byte(`{"athletes": [{"id": 7, "prefix": "athletes/7/"}]}`), nil
+	}, 0)
+
+	put := func(path string) int {
+		req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(`{"goals":[{"id":"year","target":2500}]}`))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := put("/goals/7/2025"); code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if _, err := local.ReadJSON(context.Background(), "athletes/7/"+aggregation.GoalsBlob(7, 2025)); err != nil {
+		t.Errorf("expected goals stored under the athlete's prefix: %v", err)
+	}
+	if code := put("/goals/9/2025"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unregistered athlete, got %d", code)
+	}
+}
@@ -0,0 +1,136 @@
+// Command genfixtures writes synthetic summary_activities.json and
+// distances.json files in the layout served by DATA_SOURCE=local-fixtures,
+// so the web UI can be developed without exported Strava data.
+//
+//	go run ./cmd/genfixtures -years 2023,2024 -out ../../data/fixtures
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+)
+
+// options control the synthetic activity distribution.
+type options struct {
+	through         time.Time
+	frequency       float64
+	meanMiles       float64
+	stddevMiles     float64
+	weekendFactor   float64
+	doubleDayChance float64
+	goalGranularity float64
+	athleteID       int64
+	seed            uint64
+}
+
+func main() {
+	var (
+		years   = flag.String("years", strconv.Itoa(time.Now().Year()), "comma-separated years to generate")
+		out     = flag.String("out", "data/fixtures", "fixtures root; files are written to <out>/activities/<year>/")
+		through = flag.String("through", "", "last date with activities, YYYY-MM-DD (default: today)")
+		opts    options
+	)
+	flag.Int64Var(&opts.athleteID, "athlete", 1, "athlete ID; also seeds the generator unless -seed is set")
+	flag.Uint64Var(&opts.seed, "seed", 0, "random seed (default: derived from -athlete)")
+	flag.Float64Var(&opts.frequency, "frequency", 0.55, "probability of riding on a given weekday")
+	flag.Float64Var(&opts.meanMiles, "mean-miles", 12, "mean activity distance in miles")
+	flag.Float64Var(&opts.stddevMiles, "stddev-miles", 6, "standard deviation of activity distance in miles")
+	flag.Float64Var(&opts.weekendFactor, "weekend-factor", 1.6, "multiplier applied to weekend frequency and distance")
+	flag.Float64Var(&opts.doubleDayChance, "double-chance", 0.1, "probability an active day has a second activity")
+	flag.Float64Var(&opts.goalGranularity, "goal-granularity", 500, "miles between goal lines in distances.json")
+	flag.Parse()
+
+	opts.through = time.Now().UTC().Truncate(24 * time.Hour)
+	if *through != "" {
+		t, err := time.Parse(time.DateOnly, *through)
+		if err != nil {
+			log.Fatalf("Invalid -through date: %v", err)
+		}
+		opts.through = t
+	}
+	if opts.seed == 0 {
+		opts.seed = uint64(opts.athleteID)
+	}
+
+	for _, value := range strings.Split(*years, ",") {
+		year, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			log.Fatalf("Invalid year %q: %v", value, err)
+		}
+
+		summary := generateSummary(year, opts)
+		dist := buildDistances(summary, year, opts)
+
+		dir := filepath.Join(*out, "activities", strconv.Itoa(year))
+		if err := writeJSON(filepath.Join(dir, "summary_activities.json"), summary); err != nil {
+			log.Fatalf("Failed to write summary for %d: %v", year, err)
+		}
+		if err := writeJSON(filepath.Join(dir, "distances.json"), dist); err != nil {
+			log.Fatalf("Failed to write distances for %d: %v", year, err)
+		}
+		log.Printf("Wrote %d active days for %d to %s", len(summary), year, dir)
+	}
+}
+
+// generateSummary synthesizes daily activities for year up to opts.through.
+// The same year, athlete and seed always produce the same output.
+func generateSummary(year int, opts options) aggregation.Summary {
+	rng := rand.New(rand.NewPCG(opts.seed, uint64(year)))
+	summary := aggregation.Summary{}
+
+	// Activity IDs increase through the year like Strava's do
+	nextID := opts.athleteID*1_000_000_000 + int64(year)*100_000
+
+	for day := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC); day.Year() == year && !day.After(opts.through); day = day.AddDate(0, 0, 1) {
+		factor := 1.0
+		if wd := day.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			factor = opts.weekendFactor
+		}
+		if rng.Float64() >= math.Min(opts.frequency*factor, 1) {
+			continue
+		}
+
+		activities := 1
+		if rng.Float64() < opts.doubleDayChance {
+			activities = 2
+		}
+
+		entry := &aggregation.DaySummary{}
+		for range activities {
+			miles := math.Max(0.5, rng.NormFloat64()*opts.stddevMiles+opts.meanMiles*factor)
+			entry.DistanceMiles += miles
+			entry.ActivityIDs = append(entry.ActivityIDs, nextID)
+			nextID++
+		}
+		entry.DistanceMiles = math.Round(entry.DistanceMiles*1e6) / 1e6
+		summary[day.Format(time.DateOnly)] = entry
+	}
+	return summary
+}
+
+// buildDistances derives the chart series from a summary with the same
+// aggregation the processor uses.
+func buildDistances(summary aggregation.Summary, year int, opts options) aggregation.Distances {
+	return aggregation.Build(summary, year, opts.through, aggregation.Options{GoalGranularity: opts.goalGranularity})
+}
+
+func writeJSON(path string, v interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/schema"
+)
+
+func testOptions() options {
+	return options{
+		through:         time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+		frequency:       0.5,
+		meanMiles:       10,
+		stddevMiles:     4,
+		weekendFactor:   1.5,
+		doubleDayChance: 0.1,
+		goalGranularity: 500,
+		athleteID:       7,
+		seed:            7,
+	}
+}
+
+func TestGenerateSummaryDeterministic(t *testing.T) {
+	opts := testOptions()
+
+	first := generateSummary(2024, opts)
+	second := generateSummary(2024, opts)
+	if !reflect.DeepEqual(first, second) {
+		t.Error("expected identical output for the same seed")
+	}
+
+	opts.seed = 8
+	if reflect.DeepEqual(first, generateSummary(2024, opts)) {
+		t.Error("expected different output for a different seed")
+	}
+}
+
+func TestGenerateSummaryStopsAtThrough(t *testing.T) {
+	opts := testOptions()
+	opts.through = time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	opts.frequency = 1
+
+	summary := generateSummary(2024, opts)
+	if len(summary) != 91 {
+		t.Errorf("expected 91 active days through March in a leap year, got %d", len(summary))
+	}
+	if _, ok := summary["2024-04-01"]; ok {
+		t.Error("expected no activities after -through")
+	}
+}
+
+func TestBuildDistances(t *testing.T) {
+	opts := testOptions()
+	summary := generateSummary(2024, opts)
+	dist := buildDistances(summary, 2024, opts)
+
+	if len(dist.DistanceTraveled) != 366 {
+		t.Fatalf("expected 366 points, got %d", len(dist.DistanceTraveled))
+	}
+
+	total := 0.0
+	for _, day := range summary {
+		total += day.DistanceMiles
+	}
+	last := dist.DistanceTraveled[len(dist.DistanceTraveled)-1].Y
+	if math.Abs(last-total) > 1e-6 {
+		t.Errorf("expected cumulative distance %f, got %f", total, last)
+	}
+	if len(dist.Summaries) != 2 {
+		t.Errorf("expected two goal summaries, got %v", dist.Summaries)
+	}
+
+	// Output must pass the gateway's own blob validation
+	for dataType, v := range map[string]interface{}{"summary": summary, "distances": dist} {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("failed to marshal %s: %v", dataType, err)
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			t.Fatalf("failed to decode %s: %v", dataType, err)
+		}
+		if err := schema.Validate(dataType, decoded); err != nil {
+			t.Errorf("generated %s failed validation: %v", dataType, err)
+		}
+	}
+}
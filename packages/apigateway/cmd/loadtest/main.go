@@ -0,0 +1,341 @@
+// Command loadtest sends GET requests to selected API gateway routes at a
+// fixed concurrency and reports latency percentiles, error rates and
+// response sizes per route, for benchmarking cache and streaming changes
+// before they are deployed.
+//
+//	loadtest -url http://localhost:8084 -concurrency 32 -duration 1m
+//	loadtest -routes '/activities/{year}/summary=3,/activities/{year}/distances,/health' -years 2023,2024,2025
+//	loadtest -rate 200 -count 10000 -gzip=false
+//
+// Each of the -concurrency workers sends its next request as soon as the
+// previous one finished, unless -rate caps the total requests per second.
+// Routes are picked by weight, and a {year} in a route is replaced by one
+// of -years. Requests stop after -duration, -count requests or on
+// interrupt. Responses other than 2xx count as errors, as do transport
+// errors like timeouts.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/config"
+)
+
+func main() {
+	log.SetFlags(0)
+	configFile := flag.String("config", "", "Config file of KEY=VALUE settings or a JSON object (default $CONFIG_FILE)")
+	flag.String("url", "", "API gateway base URL (default $APIGATEWAY_URL or http://localhost:8080)")
+	routesFlag := flag.String("routes", "/activities/{year}/summary,/activities/{year}/distances", "Comma-separated routes to request, each optionally weighted as route=weight")
+	yearsFlag := flag.String("years", strconv.Itoa(time.Now().Year()), "Comma-separated years substituted for {year} in routes")
+	concurrency := flag.Int("concurrency", 16, "Concurrent requests")
+	ratePerSecond := flag.Float64("rate", 0, "Requests per second across all workers (0 = as fast as responses return)")
+	duration := flag.Duration("duration", 30*time.Second, "How long to send requests (0 = until -count or interrupted)")
+	count := flag.Int("count", 0, "Requests to send (0 = until -duration or interrupted)")
+	gzip := flag.Bool("gzip", true, "Accept gzip, as browsers do, so gzip-stored objects are served and measured compressed")
+	timeout := flag.Duration("timeout", 10*time.Second, "Per-request timeout")
+	seed := flag.Uint64("seed", 0, "Random seed for picking routes, for repeatable runs (default: random)")
+	flag.Parse()
+	if err := config.Setup(*configFile); err != nil {
+		log.Fatalf("Failed to load config file: %v", err)
+	}
+	config.BindFlags(flag.CommandLine, map[string]string{"url": "APIGATEWAY_URL"})
+
+	baseURL := strings.TrimSuffix(config.GetOrDefault("APIGATEWAY_URL", "http://localhost:8080"), "/")
+	if u, err := url.Parse(baseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		log.Fatalf("Invalid -url %q", baseURL)
+	}
+	routes, err := parseRoutes(*routesFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	years := strings.Split(*yearsFlag, ",")
+	for i, year := range years {
+		years[i] = strings.TrimSpace(year)
+		if _, err := strconv.Atoi(years[i]); err != nil {
+			log.Fatalf("Invalid -years entry %q", year)
+		}
+	}
+	switch {
+	case *concurrency <= 0:
+		log.Fatal("-concurrency must be positive")
+	case *ratePerSecond < 0:
+		log.Fatal("-rate must not be negative")
+	case *duration == 0 && *count == 0:
+		log.Fatal("one of -duration and -count is required")
+	}
+	if *seed == 0 {
+		*seed = rand.Uint64()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	picker := &picker{rand: rand.New(rand.NewPCG(*seed, *seed)), routes: routes, years: years}
+	client := &http.Client{
+		Timeout: *timeout,
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: *concurrency,
+			// Accept-Encoding is set explicitly so compressed sizes are
+			// measured rather than transparently decompressed
+			DisableCompression: true,
+		},
+	}
+	s := &stats{routes: map[string]*routeStats{}}
+
+	// Requests are handed out one at a time so -count and -rate hold across
+	// workers
+	requests := make(chan target)
+	go func() {
+		defer close(requests)
+		var tick <-chan time.Time
+		if *ratePerSecond > 0 {
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / *ratePerSecond))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for sent := 0; *count == 0 || sent < *count; sent++ {
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case requests <- picker.next():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	log.Printf("Load testing %s with %d workers (seed %d)", baseURL, *concurrency, *seed)
+	started := time.Now()
+	var wg sync.WaitGroup
+	for range *concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range requests {
+				s.record(t.route, get(client, baseURL+t.path, *gzip))
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.print(os.Stdout, time.Since(started))
+}
+
+// route is one entry of -routes.
+type route struct {
+	path   string
+	weight int
+}
+
+// parseRoutes parses -routes, e.g. "/activities/{year}/summary=3,/health".
+func parseRoutes(value string) ([]route, error) {
+	var routes []route
+	for entry := range strings.SplitSeq(value, ",") {
+		path, w, weighted := strings.Cut(strings.TrimSpace(entry), "=")
+		weight := 1
+		if weighted {
+			var err error
+			if weight, err = strconv.Atoi(w); err != nil || weight <= 0 {
+				return nil, fmt.Errorf("invalid -routes weight in %q", entry)
+			}
+		}
+		if !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("invalid -routes entry %q (want a path starting with /)", entry)
+		}
+		routes = append(routes, route{path: path, weight: weight})
+	}
+	return routes, nil
+}
+
+// target is one request to send: the route as configured, which results
+// are grouped by, and the path with {year} filled in.
+type target struct {
+	route string
+	path  string
+}
+
+// picker chooses the routes to request. It is used from one goroutine.
+type picker struct {
+	rand   *rand.Rand
+	routes []route
+	years  []string
+}
+
+func (p *picker) next() target {
+	var total int
+	for _, r := range p.routes {
+		total += r.weight
+	}
+	chosen := p.routes[len(p.routes)-1]
+	pick := p.rand.IntN(total)
+	for _, r := range p.routes {
+		if pick < r.weight {
+			chosen = r
+			break
+		}
+		pick -= r.weight
+	}
+	year := p.years[p.rand.IntN(len(p.years))]
+	return target{route: chosen.path, path: strings.ReplaceAll(chosen.path, "{year}", year)}
+}
+
+// result is the outcome of one request.
+type result struct {
+	// status is the HTTP status code, or 0 for a transport error.
+	status  int
+	bytes   int64
+	latency time.Duration
+}
+
+// get requests rawURL and reads the whole body, so latency covers the full
+// response and not just its headers. Requests in flight when the run ends
+// are let finish rather than counted as errors.
+func get(client *http.Client, rawURL string, gzip bool) result {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return result{}
+	}
+	if gzip {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	started := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return result{latency: time.Since(started)}
+	}
+	defer resp.Body.Close()
+	n, err := io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		return result{latency: time.Since(started)}
+	}
+	return result{status: resp.StatusCode, bytes: n, latency: time.Since(started)}
+}
+
+// stats collects results per route.
+type stats struct {
+	mu     sync.Mutex
+	routes map[string]*routeStats
+}
+
+type routeStats struct {
+	statuses  map[int]int
+	bytes     int64
+	latencies []time.Duration
+}
+
+func (s *stats) record(route string, r result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs := s.routes[route]
+	if rs == nil {
+		rs = &routeStats{statuses: map[int]int{}}
+		s.routes[route] = rs
+	}
+	rs.statuses[r.status]++
+	rs.bytes += r.bytes
+	// Transport errors have no meaningful latency
+	if r.status != 0 {
+		rs.latencies = append(rs.latencies, r.latency)
+	}
+}
+
+// print writes a summary per route and across all of them.
+func (s *stats) print(w io.Writer, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.routes))
+	total := &routeStats{statuses: map[int]int{}}
+	for name, rs := range s.routes {
+		names = append(names, name)
+		for status, n := range rs.statuses {
+			total.statuses[status] += n
+		}
+		total.bytes += rs.bytes
+		total.latencies = append(total.latencies, rs.latencies...)
+	}
+	slices.Sort(names)
+
+	requests := total.requests()
+	fmt.Fprintf(w, "Sent %d requests in %s (%.1f/s)\n", requests, elapsed.Round(time.Millisecond), float64(requests)/elapsed.Seconds())
+	for _, name := range names {
+		s.routes[name].print(w, name)
+	}
+	if len(names) > 1 {
+		total.print(w, "all routes")
+	}
+}
+
+func (rs *routeStats) requests() int {
+	var n int
+	for _, count := range rs.statuses {
+		n += count
+	}
+	return n
+}
+
+func (rs *routeStats) print(w io.Writer, name string) {
+	requests := rs.requests()
+	var errors int
+	for status, n := range rs.statuses {
+		if status < 200 || status > 299 {
+			errors += n
+		}
+	}
+	fmt.Fprintf(w, "%s\n", name)
+	fmt.Fprintf(w, "  requests %d, errors %d (%.2f%%), %s\n", requests, errors, 100*float64(errors)/float64(requests), formatStatuses(rs.statuses))
+	if len(rs.latencies) == 0 {
+		return
+	}
+	slices.Sort(rs.latencies)
+	percentile := func(p float64) time.Duration {
+		return rs.latencies[int(p*float64(len(rs.latencies)-1))].Round(time.Microsecond)
+	}
+	fmt.Fprintf(w, "  latency p50 %s, p90 %s, p99 %s, max %s\n", percentile(0.50), percentile(0.90), percentile(0.99), percentile(1))
+	fmt.Fprintf(w, "  mean response %d bytes\n", rs.bytes/int64(len(rs.latencies)))
+}
+
+// formatStatuses lists counts by status, e.g. "200=95 404=5", with
+// transport errors as "error".
+func formatStatuses(statuses map[int]int) string {
+	codes := make([]int, 0, len(statuses))
+	for status := range statuses {
+		codes = append(codes, status)
+	}
+	slices.Sort(codes)
+	parts := make([]string, len(codes))
+	for i, status := range codes {
+		label := strconv.Itoa(status)
+		if status == 0 {
+			label = "error"
+		}
+		parts[i] = fmt.Sprintf("%s=%d", label, statuses[status])
+	}
+	return strings.Join(parts, " ")
+}
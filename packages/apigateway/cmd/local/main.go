@@ -2,32 +2,72 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"net/http"
-	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/andy-esch/desirelines/packages/apigateway"
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/listener"
 )
 
+const defaultShutdownTimeout = 10 * time.Second
+
 func main() {
+	configFile := flag.String("config", "", "Config file of KEY=VALUE settings or a JSON object (default $CONFIG_FILE)")
+	flag.String("port", "", "Port to listen on (default $PORT or 8080)")
+	flag.String("listen", "", "Address to listen on: host:port, unix:/path/to.sock or systemd (default $LISTEN_ADDR or :port)")
+	flag.Parse()
+	if err := config.Setup(*configFile); err != nil {
+		log.Fatalf("Failed to load config file: %v", err)
+	}
+	config.BindFlags(flag.CommandLine, map[string]string{"port": "PORT", "listen": "LISTEN_ADDR"})
+
 	log.Println("Starting API Gateway local development server...")
 
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	handler, err := apigateway.NewHandler(ctx)
 	if err != nil {
 		log.Fatalf("Failed to initialize API Gateway handler: %v", err)
 	}
 
-	http.Handle("/", handler)
+	mux := http.NewServeMux()
+	mux.Handle("/", handler)
 
-	port := getEnvOrDefault("PORT", "8080")
-	log.Printf("Server listening on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
-}
+	server := &http.Server{
+		Handler: mux,
+	}
 
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	// Optional TLS for running behind tunnels that require HTTPS callbacks
+	certFile := config.Get("TLS_CERT_FILE")
+	keyFile := config.Get("TLS_KEY_FILE")
+	if (certFile == "") != (keyFile == "") {
+		log.Fatal("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	shutdownTimeout, err := config.Duration("SHUTDOWN_TIMEOUT", defaultShutdownTimeout)
+	if err != nil {
+		log.Printf("%v, using default %s", err, defaultShutdownTimeout)
+	}
+
+	ln, err := listener.Listen(config.GetOrDefault("LISTEN_ADDR", ":"+config.GetOrDefault("PORT", "8080")))
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+
+	if certFile != "" {
+		log.Printf("Server listening on %s (HTTPS, HTTP/2 enabled)", ln.Addr())
+	} else {
+		log.Printf("Server listening on %s", ln.Addr())
+	}
+	err = listener.Serve(ctx, server, ln, certFile, keyFile, shutdownTimeout)
+	stop()
+	if err != nil {
+		log.Fatalf("Server failed: %v", err)
 	}
-	return defaultValue
+	log.Println("Server stopped")
 }
@@ -0,0 +1,115 @@
+package apigateway
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/storage"
+)
+
+func TestHandlerPrecompressed(t *testing.T) {
+	payload := []byte(`{"distance_traveled":[]}`)
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(payload); err != nil {
+		t.Fatalf("failed to gzip payload: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("failed to close gzip writer: %v", err)
+	}
+
+	mock := &mockStorageClient{
+		ReadBlobFunc: func(ctx context.Context, blobPath string) (*storage.Blob, error) {
+			if blobPath == "activities/2024/distances.json.gz" {
+				return &storage.Blob{Data: compressed.Bytes(), ContentEncoding: "gzip"}, nil
+			}
+			return nil, storage.ErrNotFound
+		},
+		ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+			if blobPath == "activities/2023/distances.json" {
+				return map[string]interface{}{"distance_traveled": []interface{}{}}, nil
+			}
+			return nil, storage.ErrNotFound
+		},
+	}
+
+	handler := NewHandlerWithStorage(mock)
+	handler.servePrecompressed = true
+
+	t.Run("passes gzip through when accepted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/activities/2024/distances", nil)
+		req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+			t.Errorf("expected Content-Encoding gzip, got %q", got)
+		}
+		if !bytes.Equal(w.Body.Bytes(), compressed.Bytes()) {
+			t.Error("expected compressed bytes to be passed through unchanged")
+		}
+	})
+
+	t.Run("decompresses when gzip not accepted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/activities/2024/distances", nil)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		if got := w.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("expected no Content-Encoding, got %q", got)
+		}
+		if !bytes.Equal(w.Body.Bytes(), payload) {
+			t.Errorf("expected decompressed payload, got %s", w.Body.String())
+		}
+	})
+
+	t.Run("falls back to plain JSON blob", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/activities/2023/distances", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		if got := w.Header().Get("Content-Encoding"); got != "" {
+			t.Errorf("expected no Content-Encoding, got %q", got)
+		}
+	})
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.8", true},
+		{"gzip;q=0", false},
+		{"br", false},
+		{"*", true},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", tt.header)
+		if got := acceptsGzip(req); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
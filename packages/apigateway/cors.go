@@ -0,0 +1,216 @@
+package apigateway
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCORSConfigPath is the standard config volume mount path
+	DefaultCORSConfigPath = "/etc/config/cors.json"
+	// DefaultCORSConfigTTL is the default cache TTL for CORS config reloading
+	DefaultCORSConfigTTL = time.Minute
+	// defaultCORSMaxAge is the preflight cache duration when not configured
+	defaultCORSMaxAge = 3600
+)
+
+// defaultCORSAllowedHeaders are the request headers allowed when not configured.
+var defaultCORSAllowedHeaders = []string{"Content-Type", "Authorization"}
+
+// CORSPolicy represents the structure of the mounted CORS config file.
+type CORSPolicy struct {
+	AllowedOrigins []string `json:"allowed_origins"`
+	AllowedHeaders []string `json:"allowed_headers"`
+	MaxAgeSeconds  int      `json:"max_age_seconds"`
+}
+
+// applyDefaults fills in optional fields left empty in the config.
+func (p *CORSPolicy) applyDefaults() {
+	if len(p.AllowedHeaders) == 0 {
+		p.AllowedHeaders = defaultCORSAllowedHeaders
+	}
+	if p.MaxAgeSeconds == 0 {
+		p.MaxAgeSeconds = defaultCORSMaxAge
+	}
+}
+
+// CORSConfigCache provides TTL-based caching with content hash validation for
+// the CORS config file, so origin changes apply without a redeploy. The file
+// is checked at most once per TTL, whether or not it could be read, so a
+// missing or broken file doesn't cost every request a read.
+type CORSConfigCache struct {
+	lastCheck   time.Time
+	policy      *CORSPolicy
+	err         error
+	contentHash string
+	configPath  string
+	ttl         time.Duration
+	mu          sync.RWMutex
+}
+
+// NewCORSConfigCache creates a new CORS config cache with the specified TTL.
+func NewCORSConfigCache(configPath string, ttl time.Duration) *CORSConfigCache {
+	return &CORSConfigCache{
+		configPath: configPath,
+		ttl:        ttl,
+	}
+}
+
+// GetPolicy returns the cached policy or reloads it if TTL expired or content
+// changed. While the file can't be read or parsed the last good policy is
+// kept; before there is one, the error is returned until the next check.
+func (c *CORSConfigCache) GetPolicy() (*CORSPolicy, error) {
+	c.mu.RLock()
+	// Fast path: TTL not expired
+	if !c.lastCheck.IsZero() && time.Since(c.lastCheck) < c.ttl {
+		defer c.mu.RUnlock()
+		return c.cached()
+	}
+	c.mu.RUnlock()
+
+	// Slow path: Check if file content changed
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Another request may have checked while this one waited for the lock
+	now := time.Now()
+	if !c.lastCheck.IsZero() && now.Sub(c.lastCheck) < c.ttl {
+		return c.cached()
+	}
+	c.lastCheck = now
+
+	data, err := c.readFile()
+	if err != nil {
+		// A file that was never mounted is the usual ALLOWED_ORIGINS setup
+		if !errors.Is(err, fs.ErrNotExist) || c.policy != nil {
+			log.Printf("CORS: failed to read config file: %v", err)
+		}
+		c.err = fmt.Errorf("failed to read CORS config file: %w", err)
+		return c.cached()
+	}
+
+	// Content changed or first load
+	currentHash := fmt.Sprintf("%x", sha256.Sum256(data))
+	if currentHash != c.contentHash {
+		var policy CORSPolicy
+		if err := json.Unmarshal(data, &policy); err != nil {
+			log.Printf("CORS: failed to parse config file: %v", err)
+			c.err = fmt.Errorf("failed to parse CORS config file: %w", err)
+			return c.cached()
+		}
+		policy.applyDefaults()
+		c.policy = &policy
+		c.contentHash = currentHash
+		log.Printf("CORS: config reloaded due to content change")
+	}
+
+	c.err = nil
+	return c.policy, nil
+}
+
+// cached returns the last good policy, or the last error before there is
+// one. The caller holds mu.
+func (c *CORSConfigCache) cached() (*CORSPolicy, error) {
+	if c.policy != nil {
+		return c.policy, nil
+	}
+	return nil, c.err
+}
+
+// readFile reads the full content of the CORS config file.
+func (c *CORSConfigCache) readFile() ([]byte, error) {
+	file, err := os.Open(c.configPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			log.Printf("CORS: failed to close config file: %v", closeErr)
+		}
+	}()
+
+	return io.ReadAll(file)
+}
+
+// originAllowed reports whether origin matches any of the allowed patterns.
+func originAllowed(origin string, patterns []string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if matchOrigin(origin, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrigin matches an Origin header value against a single allowlist entry.
+//
+// Entries without "*" must match exactly. Two wildcard forms are supported:
+//   - "https://*.web.app" matches exactly one extra leftmost DNS label, so
+//     "https://proj--pr12-abc.web.app" matches but "https://web.app" and
+//     "https://a.b.web.app" do not.
+//   - "http://localhost:*" matches any numeric port on that host.
+//
+// Scheme must always match exactly; any other use of "*" never matches.
+func matchOrigin(origin, pattern string) bool {
+	if !strings.Contains(pattern, "*") {
+		return origin == pattern
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+		return false
+	}
+
+	scheme, hostPattern, ok := strings.Cut(pattern, "://")
+	if !ok || scheme != u.Scheme {
+		return false
+	}
+
+	hostPart, portPart := hostPattern, ""
+	if i := strings.LastIndex(hostPattern, ":"); i >= 0 {
+		hostPart, portPart = hostPattern[:i], hostPattern[i+1:]
+	}
+
+	return matchHost(u.Hostname(), hostPart) && matchPort(u.Port(), portPart)
+}
+
+// matchHost matches a hostname against "example.com" or "*.example.com".
+func matchHost(host, pattern string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		if strings.Contains(suffix, "*") || suffix == "" {
+			return false
+		}
+		label, ok := strings.CutSuffix(host, "."+suffix)
+		return ok && label != "" && !strings.Contains(label, ".")
+	}
+	return !strings.Contains(pattern, "*") && host == pattern
+}
+
+// matchPort matches a port against "", "8080" or "*" (any numeric port).
+func matchPort(port, pattern string) bool {
+	if pattern != "*" {
+		return port == pattern
+	}
+	if port == "" {
+		return false
+	}
+	for _, c := range port {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package apigateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  string
+		pattern string
+		want    bool
+	}{
+		{"exact match", "https://desirelines-dev.web.app", "https://desirelines-dev.web.app", true},
+		{"exact mismatch", "https://evil.com", "https://desirelines-dev.web.app", false},
+		{"subdomain wildcard", "https://desirelines-dev--pr12-abc.web.app", "https://*.web.app", true},
+		{"subdomain wildcard rejects bare domain", "https://web.app", "https://*.web.app", false},
+		{"subdomain wildcard rejects nested labels", "https://a.b.web.app", "https://*.web.app", false},
+		{"subdomain wildcard rejects suffix trick", "https://evilweb.app", "https://*.web.app", false},
+		{"subdomain wildcard rejects other scheme", "http://preview.web.app", "https://*.web.app", false},
+		{"subdomain wildcard rejects port", "https://preview.web.app:8443", "https://*.web.app", false},
+		{"port wildcard", "http://localhost:5173", "http://localhost:*", true},
+		{"port wildcard requires port", "http://localhost", "http://localhost:*", false},
+		{"port wildcard rejects other host", "http://localhost.evil.com:5173", "http://localhost:*", false},
+		{"origin with path never matches wildcard", "https://preview.web.app/path", "https://*.web.app", false},
+		{"unsupported wildcard position", "https://preview.web.app", "https://pre*.web.app", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchOrigin(tt.origin, tt.pattern); got != tt.want {
+				t.Errorf("matchOrigin(%q, %q) = %v, want %v", tt.origin, tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCORSConfigCache_GetPolicy(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "cors.json")
+	writeCORSConfigFile(t, configPath, `{"allowed_origins": ["https://initial.example.com"]}`)
+
+	cache := NewCORSConfigCache(configPath, 50*time.Millisecond)
+
+	policy, err := cache.GetPolicy()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(policy.AllowedOrigins) != 1 || policy.AllowedOrigins[0] != "https://initial.example.com" {
+		t.Errorf("unexpected allowed origins: %v", policy.AllowedOrigins)
+	}
+	if policy.MaxAgeSeconds != defaultCORSMaxAge {
+		t.Errorf("expected default max age %d, got %d", defaultCORSMaxAge, policy.MaxAgeSeconds)
+	}
+
+	// Update within TTL should still return cached policy
+	writeCORSConfigFile(t, configPath, `{"allowed_origins": ["https://updated.example.com"], "max_age_seconds": 60}`)
+	policy, _ = cache.GetPolicy()
+	if policy.AllowedOrigins[0] != "https://initial.example.com" {
+		t.Errorf("expected cached origin within TTL, got %v", policy.AllowedOrigins)
+	}
+
+	// After TTL the change should be picked up
+	time.Sleep(60 * time.Millisecond)
+	policy, err = cache.GetPolicy()
+	if err != nil {
+		t.Fatalf("expected no error after TTL, got %v", err)
+	}
+	if policy.AllowedOrigins[0] != "https://updated.example.com" || policy.MaxAgeSeconds != 60 {
+		t.Errorf("expected reloaded policy, got %+v", policy)
+	}
+
+	// Invalid content after TTL should keep the last good policy
+	writeCORSConfigFile(t, configPath, "not json")
+	time.Sleep(60 * time.Millisecond)
+	policy, err = cache.GetPolicy()
+	if err != nil {
+		t.Fatalf("expected fallback to cached policy, got %v", err)
+	}
+	if policy.AllowedOrigins[0] != "https://updated.example.com" {
+		t.Errorf("expected cached policy after invalid reload, got %v", policy.AllowedOrigins)
+	}
+}
+
+func TestCORSConfigCache_FileNotFound(t *testing.T) {
+	cache := NewCORSConfigCache("/nonexistent/path/cors.json", time.Minute)
+
+	if _, err := cache.GetPolicy(); err == nil {
+		t.Error("expected error for nonexistent file, got nil")
+	}
+}
+
+func TestCORSConfigCache_ChecksOncePerTTLOnError(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "cors.json")
+	cache := NewCORSConfigCache(configPath, 50*time.Millisecond)
+
+	if _, err := cache.GetPolicy(); err == nil {
+		t.Fatal("expected error before the file exists")
+	}
+
+	// A file mounted within the TTL isn't read until the next check
+	writeCORSConfigFile(t, configPath, `{"allowed_origins": ["https://mounted.example.com"]}`)
+	if _, err := cache.GetPolicy(); err == nil {
+		t.Error("expected the failed check to be remembered within TTL")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	policy, err := cache.GetPolicy()
+	if err != nil {
+		t.Fatalf("expected the mounted file after TTL, got %v", err)
+	}
+	if policy.AllowedOrigins[0] != "https://mounted.example.com" {
+		t.Errorf("unexpected allowed origins: %v", policy.AllowedOrigins)
+	}
+
+	// A broken file is also only re-read after TTL, keeping the last good policy
+	writeCORSConfigFile(t, configPath, "not json")
+	time.Sleep(60 * time.Millisecond)
+	if policy, err := cache.GetPolicy(); err != nil || policy.AllowedOrigins[0] != "https://mounted.example.com" {
+		t.Fatalf("expected the last good policy, got %+v, %v", policy, err)
+	}
+	checked := cache.lastCheck
+	if _, err := cache.GetPolicy(); err != nil {
+		t.Fatal(err)
+	}
+	if !cache.lastCheck.Equal(checked) {
+		t.Error("expected no re-read within TTL after a parse error")
+	}
+}
+
+func TestHandlerCORSConfigFile(t *testing.T) {
+	t.Setenv("ALLOWED_ORIGINS", "")
+
+	configPath := filepath.Join(t.TempDir(), "cors.json")
+	writeCORSConfigFile(t, configPath, `{"allowed_origins": ["https://*.web.app"], "allowed_headers": ["Content-Type"]}`)
+
+	handler := NewHandlerWithStorage(&mockStorageClient{})
+	handler.corsConfig = NewCORSConfigCache(configPath, time.Minute)
+
+	req := httptest.NewRequest(http.MethodOptions, "/health", nil)
+	req.Header.Set("Origin", "https://preview.web.app")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://preview.web.app" {
+		t.Errorf("expected CORS origin from config file, got %s", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type" {
+		t.Errorf("expected Allow-Headers from config file, got %s", got)
+	}
+}
+
+// Helper function to write CORS config file
+func writeCORSConfigFile(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write CORS config file: %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package apigateway
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+)
+
+// handleDigest serves the weekly digest of the athlete whose blobs are
+// under prefix at digests/{year}/{week}, as written by packages/digest.
+// {week} is the ISO week number, with or without a leading zero.
+func (h *Handler) handleDigest(w http.ResponseWriter, r *http.Request, prefix, path string) {
+	parts := strings.Split(path, "/")
+	if len(parts) != 3 {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeBadRequest, "Invalid path format. Expected: /digests/{year}/{week}", "")
+		return
+	}
+	if !validYear(parts[1]) {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeBadRequest, fmt.Sprintf("Invalid year: %s", parts[1]), "")
+		return
+	}
+	year, _ := strconv.Atoi(parts[1])
+	week, err := strconv.Atoi(parts[2])
+	if err != nil || len(parts[2]) > 2 {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeBadRequest, fmt.Sprintf("Invalid week: %s", parts[2]), "")
+		return
+	}
+	if _, err := aggregation.WeekStart(year, week); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeBadRequest, fmt.Sprintf("Invalid week: %s", parts[2]), err.Error())
+		return
+	}
+
+	blobPath := prefix + aggregation.DigestBlob(year, week)
+	data, err := h.storage.ReadJSON(r.Context(), blobPath)
+	if err != nil {
+		h.respondStorageError(w, r, err, blobPath, fmt.Sprintf("digests/%d/%d", year, week))
+		return
+	}
+	h.respondJSONRaw(w, r, http.StatusOK, data)
+}
@@ -0,0 +1,57 @@
+package apigateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/storage"
+	"github.com/andy-esch/desirelines/packages/athletes"
+)
+
+func TestHandlerDigests(t *testing.T) {
+	var reads []string
+	mock := &mockStorageClient{
+		ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+			reads = append(reads, blobPath)
+			if blobPath == "digests/2025/08.json" {
+				return nil, storage.ErrNotFound
+			}
+			return map[string]interface{}{"week": 7}, nil
+		},
+	}
+	handler := NewHandlerWithStorage(mock)
+	handler.athletes = athletes.New(func(context.Context) ([]byte, error) {
+		return []byte(`{"athletes": [{"id": 7, "prefix": "athletes/7/"}]}`), nil
+	}, 0)
+
+	tests := []struct {
+		path string
+		want int
+		read string
+	}{
+		{"/digests/2025/7", http.StatusOK, "digests/2025/07.json"},
+		{"/digests/2025/07", http.StatusOK, "digests/2025/07.json"},
+		{"/athletes/7/digests/2025/7", http.StatusOK, "athletes/7/digests/2025/07.json"},
+		{"/digests/2025/8", http.StatusNotFound, "digests/2025/08.json"},
+		{"/digests/2025/53", http.StatusBadRequest, ""},
+		{"/digests/2025/007", http.StatusBadRequest, ""},
+		{"/digests/25/7", http.StatusBadRequest, ""},
+		{"/digests/2025", http.StatusBadRequest, ""},
+		{"/athletes/9/digests/2025/7", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			reads = nil
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.want {
+				t.Fatalf("expected status %d, got %d: %s", tt.want, w.Code, w.Body)
+			}
+			if tt.read == "" && len(reads) != 0 || tt.read != "" && (len(reads) != 1 || reads[0] != tt.read) {
+				t.Errorf("expected read of %q, got %v", tt.read, reads)
+			}
+		})
+	}
+}
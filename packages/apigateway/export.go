@@ -0,0 +1,163 @@
+package apigateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/strava"
+	"github.com/andy-esch/desirelines/packages/tokenstore"
+)
+
+// DefaultSecretsPath is where the Strava app credentials are mounted.
+const DefaultSecretsPath = "/etc/secrets/strava_auth.json"
+
+// exportContentTypes are the media types of the export formats.
+var exportContentTypes = map[string]string{
+	strava.FormatGPX: "application/gpx+xml",
+	strava.FormatTCX: "application/vnd.garmin.tcx+xml",
+}
+
+// activitySource fetches activities from Strava; *strava.Client
+// implements it.
+type activitySource interface {
+	GetActivity(ctx context.Context, id int64) (*strava.Activity, error)
+	GetStreams(ctx context.Context, id int64, types ...strava.StreamType) (*strava.Streams, error)
+}
+
+// newExportClients creates the Strava clients activity exports are fetched
+// with: one for the bucket root's athlete and, with the athlete registry,
+// one per registered athlete. Either is nil when its credentials aren't
+// configured, which disables its exports.
+func newExportClients(ctx context.Context, perAthlete bool) (activitySource, func(athletes.Athlete) activitySource, error) {
+	credentials, err := strava.LoadCredentials(config.GetOrDefault("STRAVA_AUTH_FILE", DefaultSecretsPath), config.Get)
+	if err != nil {
+		return nil, nil, err
+	}
+	if credentials.ClientID == 0 || credentials.ClientSecret == "" {
+		return nil, nil, nil
+	}
+
+	tokenConfig, err := tokenstore.LoadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	tokenConfig.PerAthlete = perAthlete
+	if err := tokenConfig.Validate(); err != nil {
+		return nil, nil, err
+	}
+	var tokens tokenstore.Store
+	if tokenConfig.Enabled() {
+		tokens, err = tokenstore.Open(ctx, tokenConfig)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open token store: %w", err)
+		}
+		log.Printf("Sharing Strava tokens through the %s token store", tokenConfig.Backend)
+	}
+
+	var root activitySource
+	switch {
+	case tokens != nil && tokenConfig.AthleteID != 0:
+		root = strava.New(credentials.ClientID, credentials.ClientSecret, credentials.RefreshToken, strava.WithTokenStore(tokens, tokenConfig.AthleteID))
+	case credentials.RefreshToken != "":
+		root = strava.New(credentials.ClientID, credentials.ClientSecret, credentials.RefreshToken)
+	}
+	var perAthleteClient func(athletes.Athlete) activitySource
+	if perAthlete {
+		clients := athletes.NewClients(credentials.ClientID, credentials.ClientSecret, tokens)
+		perAthleteClient = func(athlete athletes.Athlete) activitySource {
+			return clients.Client(athlete)
+		}
+	}
+	return root, perAthleteClient, nil
+}
+
+// handleExport serves a Strava activity at activities/{activity_id}/export
+// as a GPX or TCX download, chosen with ?format= (default gpx), fetched
+// through source. Tracks are private, so it needs ADMIN_TOKEN.
+func (h *Handler) handleExport(w http.ResponseWriter, r *http.Request, source activitySource, activityID string) {
+	id, err := strconv.ParseInt(activityID, 10, 64)
+	if err != nil || id <= 0 {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeBadRequest, fmt.Sprintf("Invalid activity ID: %s", activityID), "")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = strava.FormatGPX
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeBadRequest, fmt.Sprintf("Invalid export format: %s", format), "Expected gpx or tcx")
+		return
+	}
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+	if source == nil {
+		h.respondError(w, r, http.StatusNotFound, types.ErrCodeNotFound, "Not found", "")
+		return
+	}
+
+	activity, err := source.GetActivity(r.Context(), id)
+	if err != nil {
+		h.respondStravaError(w, r, err, id)
+		return
+	}
+	streams, err := source.GetStreams(r.Context(), id, strava.ExportStreams...)
+	if err != nil {
+		h.respondStravaError(w, r, err, id)
+		return
+	}
+
+	var buf bytes.Buffer
+	if format == strava.FormatTCX {
+		err = strava.WriteTCX(&buf, *activity, streams)
+	} else {
+		err = strava.WriteGPX(&buf, *activity, streams)
+	}
+	switch {
+	case errors.Is(err, strava.ErrNoRoute):
+		h.respondError(w, r, http.StatusNotFound, types.ErrCodeNotFound, fmt.Sprintf("Activity %d has no GPS track", id), "Use format=tcx to export it without one")
+		return
+	case errors.Is(err, strava.ErrNoTime):
+		h.respondError(w, r, http.StatusNotFound, types.ErrCodeNotFound, fmt.Sprintf("Activity %d has no recorded data", id), "")
+		return
+	case err != nil:
+		log.Printf("[%s] Failed to export activity %d: %v", w.Header().Get(correlationIDHeader), id, err)
+		h.respondError(w, r, http.StatusInternalServerError, types.ErrCodeInternal, "Internal server error", "")
+		return
+	}
+
+	origin := r.Header.Get("Origin")
+	h.setCORSHeaders(w, origin)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="activity-%d.%s"`, id, format))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Error writing export response: %v", err)
+	}
+}
+
+// respondStravaError maps a failed Strava API call to an HTTP error
+// response.
+func (h *Handler) respondStravaError(w http.ResponseWriter, r *http.Request, err error, activityID int64) {
+	switch {
+	case errors.Is(err, strava.ErrNotFound):
+		h.respondError(w, r, http.StatusNotFound, types.ErrCodeNotFound, fmt.Sprintf("Activity not found: %d", activityID), "")
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Printf("[%s] Timed out fetching activity %d from Strava: %v", w.Header().Get(correlationIDHeader), activityID, err)
+		h.respondError(w, r, http.StatusGatewayTimeout, types.ErrCodeUpstream, "Strava request timed out", "")
+	default:
+		log.Printf("[%s] Error fetching activity %d from Strava: %v", w.Header().Get(correlationIDHeader), activityID, err)
+		h.respondError(w, r, http.StatusBadGateway, types.ErrCodeUpstream, "Strava request failed", "")
+	}
+}
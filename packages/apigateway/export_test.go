@@ -0,0 +1,151 @@
+package apigateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+// fakeStrava serves every activity ID with the same streams and records
+// which were fetched.
+type fakeStrava struct {
+	err     error
+	streams *strava.Streams
+	fetched []int64
+}
+
+func (f *fakeStrava) GetActivity(ctx context.Context, id int64) (*strava.Activity, error) {
+	f.fetched = append(f.fetched, id)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &strava.Activity{ID: id, Name: "Lunch Run", Type: "Run", StartDate: time.Date(2025, time.May, 4, 12, 0, 0, 0, time.UTC)}, nil
+}
+
+func (f *fakeStrava) GetStreams(ctx context.Context, id int64, types ...strava.StreamType) (*strava.Streams, error) {
+	return f.streams, nil
+}
+
+func newFakeStrava() *fakeStrava {
+	return &fakeStrava{streams: &strava.Streams{
+		Time:   &strava.Stream[int]{Data: []int{0, 10}},
+		LatLng: &strava.Stream[[2]float64]{Data: [][2]float64{{51.5, -0.1}, {51.6, -0.2}}},
+	}}
+}
+
+func exportRequest(t *testing.T, handler *Handler, path, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandlerExport(t *testing.T) {
+	source := newFakeStrava()
+	handler := NewHandlerWithStorage(&mockStorageClient{})
+	handler.adminToken = "admin-secret"
+	handler.stravaClient = source
+
+	w := exportRequest(t, handler, "/activities/123/export", "admin-secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/gpx+xml" {
+		t.Errorf("expected a GPX content type, got %q", got)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="activity-123.gpx"` {
+		t.Errorf("unexpected Content-Disposition %q", got)
+	}
+	if !strings.Contains(w.Body.String(), `<trkpt lat="51.6" lon="-0.2">`) {
+		t.Errorf("expected the track in the GPX, got %s", w.Body)
+	}
+
+	w = exportRequest(t, handler, "/activities/123/export?format=tcx", "admin-secret")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/vnd.garmin.tcx+xml" || !strings.Contains(w.Body.String(), `<Activity Sport="Running">`) {
+		t.Errorf("expected a TCX export, got %d %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	if len(source.fetched) != 2 || source.fetched[0] != 123 {
+		t.Errorf("expected activity 123 fetched twice, got %v", source.fetched)
+	}
+}
+
+func TestHandlerExportErrors(t *testing.T) {
+	source := newFakeStrava()
+	handler := NewHandlerWithStorage(&mockStorageClient{})
+	handler.adminToken = "admin-secret"
+
+	if w := exportRequest(t, handler, "/activities/123/export", "admin-secret"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without Strava credentials, got %d", w.Code)
+	}
+	handler.stravaClient = source
+
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		err   error
+		noGPS bool
+		want  int
+		code  types.ErrorCode
+	}{
+		{"no token", "/activities/123/export", "", nil, false, http.StatusUnauthorized, types.ErrCodeUnauthorized},
+		{"bad id", "/activities/abc/export", "admin-secret", nil, false, http.StatusBadRequest, types.ErrCodeBadRequest},
+		{"bad format", "/activities/123/export?format=fit", "admin-secret", nil, false, http.StatusBadRequest, types.ErrCodeBadRequest},
+		{"deleted", "/activities/123/export", "admin-secret", &strava.APIError{StatusCode: http.StatusNotFound}, false, http.StatusNotFound, types.ErrCodeNotFound},
+		{"strava down", "/activities/123/export", "admin-secret", errors.New("connection refused"), false, http.StatusBadGateway, types.ErrCodeUpstream},
+		{"trainer ride", "/activities/123/export", "admin-secret", nil, true, http.StatusNotFound, types.ErrCodeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source.err = tt.err
+			source.streams = newFakeStrava().streams
+			if tt.noGPS {
+				source.streams.LatLng = nil
+			}
+			w := exportRequest(t, handler, tt.path, tt.token)
+			var resp types.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid error body %s: %v", w.Body, err)
+			}
+			if w.Code != tt.want || resp.Code != tt.code {
+				t.Errorf("expected %d %s, got %d %s", tt.want, tt.code, w.Code, resp.Code)
+			}
+		})
+	}
+}
+
+func TestHandlerAthleteExport(t *testing.T) {
+	handler := NewHandlerWithStorage(&mockStorageClient{})
+	handler.adminToken = "admin-secret"
+	handler.athletes = athletes.New(func(context.Context) ([]byte, error) {
+		return []byte(`{"athletes": [{"id": 7, "prefix": "athletes/7/"}]}`), nil
+	}, 0)
+	sources := map[int64]*fakeStrava{}
+	handler.athleteStrava = func(athlete athletes.Athlete) activitySource {
+		sources[athlete.ID] = newFakeStrava()
+		return sources[athlete.ID]
+	}
+
+	if w := exportRequest(t, handler, "/athletes/7/activities/55/export", "admin-secret"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if sources[7] == nil || len(sources[7].fetched) != 1 || sources[7].fetched[0] != 55 {
+		t.Errorf("expected activity 55 fetched with athlete 7's client, got %v", sources)
+	}
+	if w := exportRequest(t, handler, "/athletes/9/activities/55/export", "admin-secret"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unregistered athlete, got %d", w.Code)
+	}
+}
@@ -0,0 +1,194 @@
+package apigateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/apigateway/storage"
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+)
+
+// maxGoalsBodyBytes bounds PUT /goals bodies; MaxGoals goals fit easily.
+const maxGoalsBodyBytes = 16 << 10
+
+// handleGoals serves an athlete's goals for a year at
+// /goals/{athlete_id}/{year}. Reads are public so the charts can label goal
+// lines; writes need the admin token. With an athlete registry only
+// registered athletes have goals, stored under their prefix.
+func (h *Handler) handleGoals(w http.ResponseWriter, r *http.Request, path string) {
+	parts := strings.Split(path, "/")
+	if len(parts) != 3 {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeBadRequest, "Invalid path format. Expected: /goals/{athlete_id}/{year}", "")
+		return
+	}
+
+	athleteID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || athleteID <= 0 {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeBadRequest, fmt.Sprintf("Invalid athlete ID: %s", parts[1]), "")
+		return
+	}
+	if !validYear(parts[2]) {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeBadRequest, fmt.Sprintf("Invalid year: %s", parts[2]), "")
+		return
+	}
+	year, _ := strconv.Atoi(parts[2])
+
+	prefix := ""
+	if h.athletes != nil {
+		athlete, ok := h.lookupAthlete(w, r, athleteID)
+		if !ok {
+			return
+		}
+		prefix = athlete.Prefix
+	}
+	blobPath := prefix + aggregation.GoalsBlob(athleteID, year)
+
+	switch r.Method {
+	case http.MethodGet:
+		h.getGoals(w, r, blobPath, athleteID, year)
+	case http.MethodPut:
+		h.putGoals(w, r, prefix, blobPath, athleteID, year)
+	default:
+		h.respondError(w, r, http.StatusMethodNotAllowed, types.ErrCodeMethodNotAllowed, "Method not allowed", "")
+	}
+}
+
+// getGoals responds with the stored goals, or an empty set when the athlete
+// has none for the year.
+func (h *Handler) getGoals(w http.ResponseWriter, r *http.Request, blobPath string, athleteID int64, year int) {
+	data, err := h.storage.ReadJSON(r.Context(), blobPath)
+	if errors.Is(err, storage.ErrNotFound) {
+		h.respondJSON(w, r, http.StatusOK, aggregation.GoalSet{Goals: []aggregation.Goal{}})
+		return
+	}
+	if err != nil {
+		h.respondStorageError(w, r, err, blobPath, fmt.Sprintf("goals/%d/%d", athleteID, year))
+		return
+	}
+	h.respondJSONRaw(w, r, http.StatusOK, data)
+}
+
+// putGoals validates and replaces the goals for the year, then rebuilds the
+// year's distances under prefix so the charts show them straight away.
+func (h *Handler) putGoals(w http.ResponseWriter, r *http.Request, prefix, blobPath string, athleteID int64, year int) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	var goals aggregation.GoalSet
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGoalsBodyBytes)).Decode(&goals); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeInvalidPayload, "Invalid JSON payload", "")
+		return
+	}
+	if goals.Goals == nil {
+		goals.Goals = []aggregation.Goal{}
+	}
+	if err := goals.Validate(year); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeInvalidPayload, "Invalid goals", err.Error())
+		return
+	}
+	goals.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+
+	data, err := json.Marshal(goals)
+	if err != nil {
+		h.respondError(w, r, http.StatusInternalServerError, types.ErrCodeInternal, "Internal server error", "")
+		return
+	}
+
+	if !h.writeBlob(w, r, blobPath, data) {
+		return
+	}
+
+	log.Printf("Goals: stored %d goals for athlete %d in %d", len(goals.Goals), athleteID, year)
+	if err := h.rebuildDistances(r.Context(), prefix, year, goals.Goals); err != nil {
+		// The goals are stored; the processor adds them on its next rebuild.
+		log.Printf("[%s] Goals: failed to rebuild distances for athlete %d in %d: %v", w.Header().Get(correlationIDHeader), athleteID, year, err)
+	}
+	h.respondJSON(w, r, http.StatusOK, goals)
+}
+
+// maxRebuildAttempts bounds how often rebuildDistances starts again after
+// losing a race with the processor.
+const maxRebuildAttempts = 3
+
+// rebuildDistances rebuilds the year's distances under prefix from its
+// stored summary with goals, as the processor does. The stored series' last
+// day stands in for today, so only the goal lines change, and a year the
+// processor hasn't charted yet is left alone. Both blobs are read past the
+// cache and the distances are only replaced at the generation read, so a
+// concurrent processor update is rebuilt on rather than overwritten.
+func (h *Handler) rebuildDistances(ctx context.Context, prefix string, year int, goals []aggregation.Goal) error {
+	writer, ok := h.storage.(storage.ConditionalWriter)
+	if !ok {
+		return storage.ErrReadOnly
+	}
+	blobPath := prefix + aggregation.DistancesBlob(year)
+	for attempt := 1; ; attempt++ {
+		err := h.rebuildDistancesOnce(ctx, writer, prefix, year, goals)
+		if !errors.Is(err, storage.ErrConflict) {
+			return err
+		}
+		if attempt == maxRebuildAttempts {
+			return fmt.Errorf("%s kept changing after %d attempts: %w", blobPath, attempt, err)
+		}
+	}
+}
+
+func (h *Handler) rebuildDistancesOnce(ctx context.Context, writer storage.ConditionalWriter, prefix string, year int, goals []aggregation.Goal) error {
+	// Read before the summary: whoever writes a newer summary rewrites the
+	// distances afterwards, so that write or this one conflicts.
+	var stored aggregation.Distances
+	blobPath := prefix + aggregation.DistancesBlob(year)
+	generation, ok, err := readGenerationInto(ctx, writer, blobPath, &stored)
+	if !ok || err != nil {
+		return err
+	}
+	var summary aggregation.Summary
+	if _, ok, err := readGenerationInto(ctx, writer, prefix+aggregation.SummaryBlob(year), &summary); !ok || err != nil {
+		return err
+	}
+	if len(stored.DistanceTraveled) == 0 {
+		return nil
+	}
+	through, err := time.Parse(time.DateOnly, stored.DistanceTraveled[len(stored.DistanceTraveled)-1].X)
+	if err != nil {
+		return fmt.Errorf("failed to parse last day of %s: %w", blobPath, err)
+	}
+
+	data, err := json.Marshal(aggregation.Build(summary, year, through, aggregation.Options{Goals: goals}))
+	if err != nil {
+		return fmt.Errorf("failed to encode distances: %w", err)
+	}
+	if h.servePrecompressed {
+		// The processor keeps the sibling current, so drop it rather than
+		// write one it would then have to replace.
+		if err := writer.DeleteBlob(ctx, blobPath+".gz"); err != nil {
+			return err
+		}
+	}
+	return writer.WriteBlobIf(ctx, blobPath, data, generation)
+}
+
+// readGenerationInto decodes the JSON at blobPath into v and returns its
+// generation, reporting false if there is none.
+func readGenerationInto(ctx context.Context, writer storage.ConditionalWriter, blobPath string, v any) (int64, bool, error) {
+	data, generation, err := writer.ReadGeneration(ctx, blobPath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return 0, false, fmt.Errorf("failed to decode %s: %w", blobPath, err)
+	}
+	return generation, true, nil
+}
@@ -0,0 +1,220 @@
+package apigateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/apigateway/storage"
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+)
+
+func TestHandlerGoals(t *testing.T) {
+	local, err := storage.NewLocalStorageClient(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	handler := NewHandlerWithStorage(storage.NewMemoryCacheClient(local, time.Hour))
+	handler.adminToken = "admin-secret"
+
+	put := func(path, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	get := func(path string) (*httptest.ResponseRecorder, aggregation.GoalSet) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var goals aggregation.GoalSet
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&goals); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+		return w, goals
+	}
+
+	t.Run("returns an empty set before any goals are stored", func(t *testing.T) {
+		w, goals := get("/goals/7/2025")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		if goals.Goals == nil || len(goals.Goals) != 0 {
+			t.Errorf("expected an empty goals list, got %+v", goals.Goals)
+		}
+	})
+
+	t.Run("stores goals and serves them back", func(t *testing.T) {
+		w := put("/goals/7/2025", `{"goals":[{"id":"year","label":"2,500 miles","target":2500},{"id":"climb","type":"elevation","target":100000}]}`, "admin-secret")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+		}
+
+		w, goals := get("/goals/7/2025")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		if len(goals.Goals) != 2 || goals.Goals[1].Type != aggregation.GoalElevation {
+			t.Errorf("expected the stored goals, got %+v", goals.Goals)
+		}
+		if goals.UpdatedAt.IsZero() {
+			t.Error("expected updated_at to be set")
+		}
+	})
+
+	t.Run("rejects invalid goals", func(t *testing.T) {
+		w := put("/goals/7/2025", `{"goals":[{"id":"next","end_date":"2026-03-01","target":100}]}`, "admin-secret")
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d", w.Code)
+		}
+		var response types.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if !strings.Contains(response.Details, "dates must be in 2025") {
+			t.Errorf("expected validation details, got %q", response.Details)
+		}
+	})
+
+	t.Run("rejects writes without the admin token", func(t *testing.T) {
+		if w := put("/goals/7/2025", `{"goals":[]}`, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status 401, got %d", w.Code)
+		}
+	})
+
+	t.Run("rejects invalid paths", func(t *testing.T) {
+		for _, path := range []string{"/goals/7", "/goals/athlete/2025", "/goals/7/25"} {
+			if w, _ := get(path); w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status 400, got %d", path, w.Code)
+			}
+		}
+	})
+
+	t.Run("rejects PUT outside goals and chart data", func(t *testing.T) {
+		if w := put("/digests/2025/7", `{}`, "admin-secret"); w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status 405, got %d", w.Code)
+		}
+	})
+
+	t.Run("reports read-only storage", func(t *testing.T) {
+		handler := NewHandlerWithStorage(&mockStorageClient{})
+		handler.adminToken = "admin-secret"
+
+		req := httptest.NewRequest(http.MethodPut, "/goals/7/2025", strings.NewReader(`{"goals":[]}`))
+		req.Header.Set("Authorization", "Bearer admin-secret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotImplemented {
+			t.Errorf("expected status 501, got %d", w.Code)
+		}
+	})
+}
+
+func TestHandlerGoalsRebuildDistances(t *testing.T) {
+	handler, dir := newIngestHandler(t)
+	handler.servePrecompressed = true
+
+	summary := aggregation.Summary{
+		"2025-01-02": {ActivityIDs: []int64{1}, DistanceMiles: 20},
+		"2025-01-05": {ActivityIDs: []int64{2}, DistanceMiles: 30},
+	}
+	through := time.Date(2025, time.January, 10, 0, 0, 0, 0, time.UTC)
+	for name, v := range map[string]any{
+		"summary_activities.json": summary,
+		"distances.json":          aggregation.Build(summary, 2025, through, aggregation.Options{}),
+	} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "activities", "2025", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("adds the goal lines through the charted day", func(t *testing.T) {
+		if w := ingestRequest(t, handler, "/goals/7/2025", `{"goals":[{"id":"year","target":2500}]}`, "admin-secret"); w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+		}
+
+		w := exportRequest(t, handler, "/activities/2025/distances", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+		}
+		var distances aggregation.Distances
+		if err := json.NewDecoder(w.Body).Decode(&distances); err != nil {
+			t.Fatalf("failed to decode distances: %v", err)
+		}
+		if len(distances.DistanceTraveled) != 10 {
+			t.Errorf("expected the series to still end on 2025-01-10, got %d days", len(distances.DistanceTraveled))
+		}
+		if len(distances.Goals) != 1 || distances.Goals[0].ID != "year" {
+			t.Fatalf("expected the new goal's line, got %+v", distances.Goals)
+		}
+		if progress := distances.Goals[0].Progress; progress[len(progress)-1].Y != 50 {
+			t.Errorf("expected 50 miles of progress, got %+v", progress[len(progress)-1])
+		}
+	})
+
+	t.Run("rebuilds from the stored summary and drops the .gz sibling", func(t *testing.T) {
+		// Cache the summary, then change it under the cache as the processor would
+		if w := exportRequest(t, handler, "/activities/2025/summary", ""); w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+		}
+		summary["2025-01-08"] = &aggregation.DaySummary{ActivityIDs: []int64{3}, DistanceMiles: 10}
+		data, err := json.Marshal(summary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "activities", "2025", "summary_activities.json"), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		sibling := filepath.Join(dir, "activities", "2025", "distances.json.gz")
+		if err := os.WriteFile(sibling, gzipBytes(t, `{"stale":true}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		if w := ingestRequest(t, handler, "/goals/7/2025", `{"goals":[{"id":"year","target":2500}]}`, "admin-secret"); w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+		}
+
+		if _, err := os.Stat(sibling); !os.IsNotExist(err) {
+			t.Errorf("expected the .gz sibling to be deleted, got %v", err)
+		}
+		stored, err := os.ReadFile(filepath.Join(dir, "activities", "2025", "distances.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var distances aggregation.Distances
+		if err := json.Unmarshal(stored, &distances); err != nil {
+			t.Fatalf("failed to decode distances: %v", err)
+		}
+		if progress := distances.Goals[0].Progress; progress[len(progress)-1].Y != 60 {
+			t.Errorf("expected 60 miles of progress from the uncached summary, got %+v", progress[len(progress)-1])
+		}
+	})
+
+	t.Run("leaves a year without chart data alone", func(t *testing.T) {
+		if w := ingestRequest(t, handler, "/goals/7/2024", `{"goals":[{"id":"year","target":2500}]}`, "admin-secret"); w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "activities", "2024", "distances.json")); !os.IsNotExist(err) {
+			t.Errorf("expected no distances for 2024, got %v", err)
+		}
+	})
+}
@@ -3,80 +3,346 @@
 package apigateway
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/schema"
 	"github.com/andy-esch/desirelines/packages/apigateway/storage"
 	"github.com/andy-esch/desirelines/packages/apigateway/types"
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/ipfilter"
+)
+
+// DefaultRequestTimeout bounds how long a request may spend on storage reads.
+const DefaultRequestTimeout = 10 * time.Second
+
+const (
+	// correlationIDHeader carries the request's correlation ID in both
+	// directions; a caller-supplied value is reused.
+	correlationIDHeader = "X-Correlation-ID"
+
+	// maxCorrelationIDLength caps caller-supplied IDs so they can't bloat logs.
+	maxCorrelationIDLength = 128
+
+	// exposedHeaders are readable by browser clients on CORS responses.
+	exposedHeaders = "X-Correlation-ID, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After"
 )
 
 // Handler orchestrates API Gateway request processing.
 type Handler struct {
-	storage storage.Client
+	storage            storage.Client
+	storageHealth      *storageHealth
+	corsConfig         *CORSConfigCache
+	hub                *Hub
+	adminToken         string
+	ingestToken        string
+	notificationToken  string
+	requestTimeout     time.Duration
+	servePrecompressed bool
+	validateBlobs      bool
+	rateLimiter        *rateLimiter
+	ipFilter           *ipfilter.Filter
+	trustedProxies     ipfilter.TrustedProxies
+	prefetcher         *prefetcher
+	// athletes scopes /athletes/{id}/... routes and goals to registered
+	// athletes; nil serves the bucket root only.
+	athletes *athletes.Registry
+	// stravaClient fetches the bucket root athlete's activities for
+	// exports, and athleteStrava a registered athlete's; nil disables them.
+	stravaClient  activitySource
+	athleteStrava func(athletes.Athlete) activitySource
 }
 
 // NewHandler creates a new API Gateway handler.
 func NewHandler(ctx context.Context) (*Handler, error) {
-	var storageClient storage.Client
-	var err error
+	if err := config.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", config.FileEnv, err)
+	}
+	build := currentBuild()
+	log.Printf("Starting API Gateway version=%s commit=%s build_time=%s", build.Version, build.Commit, build.BuildTime)
+
+	// Check DATA_SOURCE environment variable. A comma-separated list builds a
+	// fallback chain, e.g. "cloud-storage,local-fixtures".
+	dataSource := config.GetOrDefault("DATA_SOURCE", "cloud-storage")
+
+	var clients []storage.Client
+	var fixtures []*storage.LocalStorageClient
+	for _, source := range strings.Split(dataSource, ",") {
+		client, err := newStorageClient(ctx, strings.TrimSpace(source))
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+		if local, ok := client.(*storage.LocalStorageClient); ok {
+			fixtures = append(fixtures, local)
+		}
+	}
+
+	storageClient := clients[0]
+	if len(clients) > 1 {
+		storageClient = storage.NewFallbackClient(clients...)
+		log.Printf("Using storage fallback chain: %s", dataSource)
+	}
+
+	notFoundTTL, err := time.ParseDuration(config.GetOrDefault("NOT_FOUND_CACHE_TTL", storage.DefaultNotFoundCacheTTL.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NOT_FOUND_CACHE_TTL: %w", err)
+	}
+	if notFoundTTL > 0 {
+		storageClient = storage.NewNegativeCacheClient(storageClient, notFoundTTL)
+	}
+
+	// Positive caching is off by default; enable with long TTLs only when GCS
+	// notifications are wired to /notifications/gcs to invalidate entries.
+	cacheTTL, err := time.ParseDuration(config.GetOrDefault("CACHE_TTL", "0s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CACHE_TTL: %w", err)
+	}
+	if cacheTTL > 0 {
+		storageClient = storage.NewMemoryCacheClient(storageClient, cacheTTL)
+		log.Printf("Caching storage reads for %s", cacheTTL)
+	}
+
+	requestTimeout, err := time.ParseDuration(config.GetOrDefault("REQUEST_TIMEOUT", DefaultRequestTimeout.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REQUEST_TIMEOUT: %w", err)
+	}
+
+	// Load CORS policy from a mounted config file while it is present,
+	// otherwise ALLOWED_ORIGINS is read on every request. The file is
+	// checked again every DefaultCORSConfigTTL, so one mounted later is
+	// picked up.
+	corsConfigPath := config.GetOrDefault("CORS_CONFIG_PATH", DefaultCORSConfigPath)
+	corsConfig := NewCORSConfigCache(corsConfigPath, DefaultCORSConfigTTL)
+	if _, err := os.Stat(corsConfigPath); err == nil {
+		log.Printf("Using CORS config file: %s", corsConfigPath)
+	} else {
+		log.Printf("CORS config file %s not found, using ALLOWED_ORIGINS until it appears", corsConfigPath)
+	}
+
+	// Per-client rate limiting is off unless RATE_LIMIT is set
+	var limiter *rateLimiter
+	if value := config.Get("RATE_LIMIT"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid RATE_LIMIT: %q", value)
+		}
+		window, err := time.ParseDuration(config.GetOrDefault("RATE_LIMIT_WINDOW", DefaultRateLimitWindow.String()))
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_WINDOW: %q", config.Get("RATE_LIMIT_WINDOW"))
+		}
+		if limit > 0 {
+			limiter = newRateLimiter(limit, window)
+			log.Printf("Rate limiting to %d requests per %s per client", limit, window)
+		}
+	}
+
+	ipFilter, err := ipfilter.Parse(config.Get("IP_ALLOWLIST"), config.Get("IP_DENYLIST"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP filter: %w", err)
+	}
+	trustedProxies, err := ipfilter.ParseTrustedProxies(config.Get("TRUSTED_PROXIES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
 
-	// Check DATA_SOURCE environment variable
-	dataSource := getEnvOrDefault("DATA_SOURCE", "cloud-storage")
+	servePrecompressed := config.Get("SERVE_PRECOMPRESSED") == "true"
+
+	// Prefetching only pays off when reads are cached
+	var yearPrefetcher *prefetcher
+	if config.Get("PREFETCH_PREVIOUS_YEAR") == "true" {
+		if cacheTTL <= 0 {
+			log.Printf("PREFETCH_PREVIOUS_YEAR has no effect without CACHE_TTL; ignoring")
+		} else {
+			yearPrefetcher = newPrefetcher(storageClient, requestTimeout, servePrecompressed)
+			log.Printf("Prefetching previous year's data on current-year requests")
+		}
+	}
 
+	registryConfig, err := athletes.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := registryConfig.Validate(); err != nil {
+		return nil, err
+	}
+	var registry *athletes.Registry
+	if registryConfig.Enabled() {
+		registry, err = openAthleteRegistry(ctx, registryConfig)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Serving registered athletes from: %s", registryConfig.Location)
+	}
+
+	stravaClient, athleteStrava, err := newExportClients(ctx, registry != nil)
+	if err != nil {
+		return nil, err
+	}
+	if stravaClient != nil || athleteStrava != nil {
+		log.Printf("Serving activity exports from Strava")
+	}
+
+	// Edited fixtures are picked up like GCS notifications, so frontend
+	// development doesn't need a restart or CACHE_TTL=0
+	fixturesWatch := config.GetOrDefault("FIXTURES_WATCH", "fsnotify")
+	if fixturesWatch != "fsnotify" && fixturesWatch != "poll" && fixturesWatch != "off" {
+		return nil, fmt.Errorf("invalid FIXTURES_WATCH %q: expected fsnotify, poll or off", fixturesWatch)
+	}
+	pollInterval, err := time.ParseDuration(config.GetOrDefault("FIXTURES_WATCH_INTERVAL", storage.DefaultPollInterval.String()))
+	if err != nil || (fixturesWatch == "poll" && pollInterval <= 0) {
+		return nil, fmt.Errorf("invalid FIXTURES_WATCH_INTERVAL: %q", config.Get("FIXTURES_WATCH_INTERVAL"))
+	}
+
+	h := &Handler{
+		storage:            storageClient,
+		storageHealth:      newStorageHealth(storageClient),
+		corsConfig:         corsConfig,
+		hub:                NewHub(),
+		adminToken:         config.Get("ADMIN_TOKEN"),
+		ingestToken:        config.Get("INGEST_TOKEN"),
+		notificationToken:  config.Get("NOTIFICATION_TOKEN"),
+		requestTimeout:     requestTimeout,
+		servePrecompressed: servePrecompressed,
+		validateBlobs:      config.Get("VALIDATE_BLOBS") == "true",
+		rateLimiter:        limiter,
+		ipFilter:           ipFilter,
+		trustedProxies:     trustedProxies,
+		prefetcher:         yearPrefetcher,
+		athletes:           registry,
+		stravaClient:       stravaClient,
+		athleteStrava:      athleteStrava,
+	}
+	for _, local := range fixtures {
+		switch fixturesWatch {
+		case "fsnotify":
+			if err := local.Watch(ctx, h.onFixtureChange); err != nil {
+				return nil, fmt.Errorf("failed to watch local fixtures, set FIXTURES_WATCH=poll where file events aren't available: %w", err)
+			}
+			log.Printf("Reloading local fixtures when they change")
+		case "poll":
+			if err := local.Poll(ctx, pollInterval, h.onFixtureChange); err != nil {
+				return nil, fmt.Errorf("failed to watch local fixtures: %w", err)
+			}
+			log.Printf("Reloading local fixtures when they change (checked every %s)", pollInterval)
+		}
+	}
+	return h, nil
+}
+
+// newStorageClient creates the storage client for a single DATA_SOURCE value.
+func newStorageClient(ctx context.Context, dataSource string) (storage.Client, error) {
 	switch dataSource {
 	case "local-fixtures":
-		basePath := getEnvOrDefault("LOCAL_FIXTURES_PATH", "data/fixtures")
-		storageClient, err = storage.NewLocalStorageClient(basePath)
+		basePath := config.GetOrDefault("LOCAL_FIXTURES_PATH", "data/fixtures")
+		client, err := storage.NewLocalStorageClient(basePath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create local storage client: %w", err)
 		}
 		log.Printf("Using local fixtures from: %s", basePath)
+		return client, nil
 	case "cloud-storage":
-		storageClient, err = storage.NewCloudStorageClient(ctx)
+		client, err := storage.NewCloudStorageClient(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create cloud storage client: %w", err)
 		}
 		log.Println("Using Cloud Storage")
+
+		// Optionally snapshot everything served from the bucket into a
+		// directory usable as LOCAL_FIXTURES_PATH
+		if capturePath := config.Get("CAPTURE_FIXTURES_PATH"); capturePath != "" {
+			log.Printf("Capturing cloud storage reads to: %s", capturePath)
+			return storage.NewCaptureClient(client, capturePath), nil
+		}
+		return client, nil
 	default:
 		return nil, fmt.Errorf("invalid DATA_SOURCE: %s (expected: local-fixtures or cloud-storage)", dataSource)
 	}
-
-	return &Handler{
-		storage: storageClient,
-	}, nil
-}
-
-// getEnvOrDefault returns environment variable value or default if not set.
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
 }
 
 // NewHandlerWithStorage is a constructor for testing that allows injecting a mock storage client.
 func NewHandlerWithStorage(storageClient storage.Client) *Handler {
 	return &Handler{
-		storage: storageClient,
+		storage:        storageClient,
+		storageHealth:  newStorageHealth(storageClient),
+		hub:            NewHub(),
+		requestTimeout: DefaultRequestTimeout,
 	}
 }
 
+// clientIP returns the caller's address, believing X-Forwarded-For only
+// from the configured TRUSTED_PROXIES.
+func (h *Handler) clientIP(r *http.Request) string {
+	return h.trustedProxies.ClientIP(r)
+}
+
 // ServeHTTP implements http.Handler interface.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Tag every response so error bodies and logs can be correlated
+	w.Header().Set(correlationIDHeader, correlationID(r))
+
+	// Probes bypass the IP filter so the platform can always reach them
+	if h.ipFilter != nil && r.URL.Path != "/live" && r.URL.Path != "/ready" {
+		if ip := h.clientIP(r); !h.ipFilter.Allowed(ip) {
+			log.Printf("Rejected request from filtered IP %s", ip)
+			h.respondError(w, r, http.StatusForbidden, types.ErrCodeForbidden, "Forbidden", "")
+			return
+		}
+	}
+
+	// WebSocket connections are long-lived and must not inherit the request timeout
+	if r.URL.Path == "/ws" {
+		h.handleWebSocket(w, r)
+		return
+	}
+	withTimeout(h.requestTimeout, http.HandlerFunc(h.route)).ServeHTTP(w, r)
+}
+
+// route dispatches a request to the matching endpoint handler.
+func (h *Handler) route(w http.ResponseWriter, r *http.Request) {
 	// Handle CORS preflight
 	if r.Method == http.MethodOptions {
 		h.handleCORS(w, r)
 		return
 	}
 
-	// Only allow GET requests
-	if r.Method != http.MethodGet {
-		h.respondError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+	// Endpoints that accept POST are routed before the GET-only check
+	switch r.URL.Path {
+	case "/notifications/gcs":
+		// GCS change notifications are pushed by Pub/Sub
+		h.handleGCSNotification(w, r)
+		return
+	case "/admin/cache/purge":
+		h.handleCachePurge(w, r)
+		return
+	}
+
+	if !h.checkRateLimit(w, r) {
+		return
+	}
+
+	// Only allow GET requests, and PUT for goals and chart data
+	if r.Method != http.MethodGet && (r.Method != http.MethodPut || !acceptsPut(r.URL.Path)) {
+		h.respondError(w, r, http.StatusMethodNotAllowed, types.ErrCodeMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+
+	if err := validatePath(r.URL.Path); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeBadRequest, "Invalid request path", err.Error())
 		return
 	}
 
@@ -87,106 +353,276 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch {
 	case path == "health":
 		h.handleHealth(w, r)
+	case path == "live":
+		h.handleLive(w, r)
+	case path == "ready":
+		h.handleReady(w, r)
+	case path == "version":
+		h.handleVersion(w, r)
 	case strings.HasPrefix(path, "activities/"):
-		h.handleActivities(w, r, path)
+		h.handleActivities(w, r, "", path)
+	case strings.HasPrefix(path, "digests/"):
+		h.handleDigest(w, r, "", path)
+	case strings.HasPrefix(path, "athletes/"):
+		h.handleAthlete(w, r, path)
+	case strings.HasPrefix(path, "goals/"):
+		h.handleGoals(w, r, path)
 	default:
-		h.respondError(w, r, http.StatusNotFound, "Not found")
+		h.respondError(w, r, http.StatusNotFound, types.ErrCodeNotFound, "Not found", "")
 	}
 }
 
-// handleHealth returns API health status.
+// handleHealth returns API health status. With ?deep=true it also probes
+// storage so that a mis-configured bucket is reported as unhealthy; the
+// probe error is only logged, since it names the bucket.
 func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
 	response := types.HealthResponse{
 		Status: "healthy",
 	}
-	h.respondJSON(w, r, http.StatusOK, response)
+
+	if r.URL.Query().Get("deep") != "true" {
+		h.respondJSON(w, r, http.StatusOK, response)
+		return
+	}
+
+	status := http.StatusOK
+	response.Checks = map[string]string{}
+
+	if err := h.storage.Ping(r.Context()); err != nil {
+		log.Printf("[%s] Health check: storage probe failed: %v", w.Header().Get(correlationIDHeader), err)
+		response.Checks["storage"] = "unavailable"
+		response.Status = "unhealthy"
+		status = http.StatusServiceUnavailable
+	} else {
+		response.Checks["storage"] = "ok"
+	}
+
+	h.respondJSON(w, r, status, response)
+}
+
+// handleLive reports that the process is up and serving HTTP.
+func (h *Handler) handleLive(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, r, http.StatusOK, types.HealthResponse{Status: "alive"})
 }
 
-// handleActivities routes activity data requests.
-func (h *Handler) handleActivities(w http.ResponseWriter, r *http.Request, path string) {
-	// Parse path: activities/{year}/{data_type}
+// handleReady reports whether the handler is able to serve data requests:
+// NewHandler has already rejected invalid configuration, so this checks
+// that storage answers (see storageHealth) and, with an athlete registry,
+// that it has been read. Failures are logged and reported as
+// "unavailable", since the endpoint is public.
+func (h *Handler) handleReady(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusOK
+	response := types.HealthResponse{Status: "ready", Checks: map[string]string{"storage": "ok"}}
+
+	if err := h.storageHealth.check(r.Context()); err != nil {
+		log.Printf("[%s] Readiness check failed: storage unavailable: %v", w.Header().Get(correlationIDHeader), err)
+		response.Checks["storage"] = "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+	if h.athletes != nil {
+		response.Checks["athletes"] = "ok"
+		// The registry keeps its last good copy, so this only fails
+		// before it has ever been read
+		if _, err := h.athletes.Athletes(r.Context()); err != nil {
+			log.Printf("[%s] Readiness check failed: athlete registry unavailable: %v", w.Header().Get(correlationIDHeader), err)
+			response.Checks["athletes"] = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	if status != http.StatusOK {
+		response.Status = "not ready"
+	}
+	h.respondJSON(w, r, status, response)
+}
+
+// handleActivities routes activity data requests for the athlete whose
+// blobs are under prefix ("" for the bucket root).
+func (h *Handler) handleActivities(w http.ResponseWriter, r *http.Request, prefix, path string) {
+	// Parse path: activities/{year}/{data_type}, or
+	// activities/{activity_id}/{streams,export}
 	parts := strings.Split(path, "/")
 	if len(parts) != 3 {
-		h.respondError(w, r, http.StatusBadRequest, "Invalid path format. Expected: /activities/{year}/{type}")
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeBadRequest, "Invalid path format. Expected: /activities/{year}/{type}", "")
 		return
 	}
 
 	year := parts[1]
 	dataType := parts[2]
 
-	// Validate data type
-	var blobPath string
-	switch dataType {
-	case "summary":
-		blobPath = fmt.Sprintf("activities/%s/summary_activities.json", year)
-	case "distances":
-		blobPath = fmt.Sprintf("activities/%s/distances.json", year)
-	default:
-		h.respondError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid data type: %s", dataType))
+	if r.Method == http.MethodPut {
+		h.putActivities(w, r, prefix, year, dataType)
 		return
 	}
 
+	// Per-activity data is keyed by activity ID rather than year
+	switch {
+	case dataType == "streams":
+		h.handleStreams(w, r, prefix, parts[1])
+		return
+	case dataType == "export" && prefix == "":
+		// Fetched from Strava, not storage
+		h.handleExport(w, r, h.stravaClient, parts[1])
+		return
+	}
+
+	if !validYear(year) {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeBadRequest, fmt.Sprintf("Invalid year: %s", year), "")
+		return
+	}
+
+	if dataType == "freshness" {
+		h.handleFreshness(w, r, prefix, year)
+		return
+	}
+	blobPath, ok := yearDataBlob(prefix, year, dataType)
+	if !ok {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeInvalidDataType, fmt.Sprintf("Invalid data type: %s", dataType), "")
+		return
+	}
+
+	if h.prefetcher != nil {
+		h.prefetcher.requested(prefix, year)
+	}
+
+	// Prefer a precompressed sibling (e.g. distances.json.gz) when enabled
+	if h.servePrecompressed {
+		blob, err := h.storage.ReadBlob(r.Context(), blobPath+".gz")
+		if err == nil {
+			if h.validateBlobs {
+				if err := validateBlob(dataType, blob); err != nil {
+					h.respondInvalidBlob(w, r, err, blobPath+".gz", fmt.Sprintf("%s/%s", year, dataType))
+					return
+				}
+			}
+			h.respondBlob(w, r, http.StatusOK, blob)
+			return
+		}
+		if !errors.Is(err, storage.ErrNotFound) {
+			h.respondStorageError(w, r, err, blobPath+".gz", fmt.Sprintf("%s/%s", year, dataType))
+			return
+		}
+	}
+
 	// Fetch data from storage
 	data, err := h.storage.ReadJSON(r.Context(), blobPath)
 	if err != nil {
-		if err == storage.ErrNotFound {
-			h.respondError(w, r, http.StatusNotFound, fmt.Sprintf("Data not found for %s/%s", year, dataType))
+		h.respondStorageError(w, r, err, blobPath, fmt.Sprintf("%s/%s", year, dataType))
+		return
+	}
+
+	if h.validateBlobs {
+		if err := schema.Validate(dataType, data); err != nil {
+			h.respondInvalidBlob(w, r, err, blobPath, fmt.Sprintf("%s/%s", year, dataType))
 			return
 		}
-		log.Printf("Error reading blob %s: %v", blobPath, err)
-		h.respondError(w, r, http.StatusInternalServerError, "Internal server error")
-		return
 	}
 
 	// Respond with data (already parsed JSON)
 	h.respondJSONRaw(w, r, http.StatusOK, data)
 }
 
+// handleFreshness reports when a year's data was last written and the newest
+// activity date it contains, so clients can detect a stalled pipeline.
+func (h *Handler) handleFreshness(w http.ResponseWriter, r *http.Request, prefix, year string) {
+	blobPath := fmt.Sprintf("%sactivities/%s/summary_activities.json", prefix, year)
+
+	info, err := h.storage.Stat(r.Context(), blobPath)
+	if err != nil {
+		h.respondStorageError(w, r, err, blobPath, year+"/freshness")
+		return
+	}
+
+	data, err := h.storage.ReadJSON(r.Context(), blobPath)
+	if err != nil {
+		h.respondStorageError(w, r, err, blobPath, year+"/freshness")
+		return
+	}
+
+	response := types.FreshnessResponse{
+		Year:               year,
+		LastUpdated:        info.Updated.UTC(),
+		NewestActivityDate: newestActivityDate(data),
+	}
+	h.respondJSON(w, r, http.StatusOK, response)
+}
+
+// newestActivityDate returns the latest date key in a summary_activities blob.
+// Keys are ISO dates (YYYY-MM-DD), so lexical order matches chronological order.
+func newestActivityDate(data interface{}) string {
+	summaries, ok := data.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	newest := ""
+	for date := range summaries {
+		if date > newest {
+			newest = date
+		}
+	}
+	return newest
+}
+
 // handleCORS responds to CORS preflight requests.
 func (h *Handler) handleCORS(w http.ResponseWriter, r *http.Request) {
 	origin := r.Header.Get("Origin")
+	policy := h.corsPolicy()
 
 	// Set CORS headers with origin validation
 	h.setCORSHeaders(w, origin)
 
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	w.Header().Set("Access-Control-Max-Age", "3600")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, PUT, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", strings.Join(policy.AllowedHeaders, ", "))
+	w.Header().Set("Access-Control-Max-Age", strconv.Itoa(policy.MaxAgeSeconds))
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// corsPolicy returns the CORS policy from the mounted config file when one is
+// configured, falling back to the ALLOWED_ORIGINS environment variable.
+func (h *Handler) corsPolicy() *CORSPolicy {
+	if h.corsConfig != nil {
+		// GetPolicy logs read and parse failures when it checks the file
+		if policy, err := h.corsConfig.GetPolicy(); err == nil {
+			return policy
+		}
+	}
+
+	// Get allowed origins from environment variable (comma-separated)
+	// Example: ALLOWED_ORIGINS="https://desirelines-dev.web.app,https://*.web.app,http://localhost:*"
+	policy := &CORSPolicy{}
+	if allowedOriginsEnv := config.Get("ALLOWED_ORIGINS"); allowedOriginsEnv != "" {
+		// Parse comma-separated origins, trimming whitespace from each
+		for _, origin := range strings.Split(allowedOriginsEnv, ",") {
+			policy.AllowedOrigins = append(policy.AllowedOrigins, strings.TrimSpace(origin))
+		}
+	}
+	policy.applyDefaults()
+	return policy
+}
+
 // setCORSHeaders sets appropriate CORS headers based on the request origin.
 func (h *Handler) setCORSHeaders(w http.ResponseWriter, origin string) {
-	// Get allowed origins from environment variable (comma-separated)
-	// Example: ALLOWED_ORIGINS="https://desirelines-dev.web.app,http://localhost:5173"
-	allowedOriginsEnv := os.Getenv("ALLOWED_ORIGINS")
+	allowedOrigins := h.corsPolicy().AllowedOrigins
 
-	if allowedOriginsEnv == "" {
+	if len(allowedOrigins) == 0 {
 		// Secure by default: no CORS headers if not configured
 		// This will cause browser to block cross-origin requests
-		log.Printf("CORS: ALLOWED_ORIGINS not set, blocking all cross-origin requests")
+		log.Printf("CORS: no allowed origins configured, blocking all cross-origin requests")
 		return
 	}
 
-	// Parse comma-separated origins
-	allowedOrigins := strings.Split(allowedOriginsEnv, ",")
-
-	// Trim whitespace from each origin
-	for i := range allowedOrigins {
-		allowedOrigins[i] = strings.TrimSpace(allowedOrigins[i])
-	}
-
-	// Check if origin is in whitelist
-	for _, allowed := range allowedOrigins {
-		if origin == allowed {
-			w.Header().Set("Access-Control-Allow-Origin", origin)
-			w.Header().Set("Access-Control-Allow-Credentials", "true")
-			return
-		}
+	// Check if origin is in whitelist (supports wildcard patterns, see matchOrigin)
+	if originAllowed(origin, allowedOrigins) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+		return
 	}
 
 	// No CORS header if origin not allowed (browser will block)
-	log.Printf("CORS: Origin not allowed: %s (allowed: %s)", origin, allowedOriginsEnv)
+	log.Printf("CORS: Origin not allowed: %s (allowed: %s)", origin, strings.Join(allowedOrigins, ","))
 }
 
 // respondJSON writes a JSON response with CORS headers.
@@ -216,10 +652,136 @@ func (h *Handler) respondJSONRaw(w http.ResponseWriter, r *http.Request, status
 	}
 }
 
-// respondError writes an error response with CORS headers.
-func (h *Handler) respondError(w http.ResponseWriter, r *http.Request, status int, message string) {
-	response := types.ErrorResponse{
-		Error: message,
+// respondBlob writes stored JSON bytes as-is with CORS headers. Gzip-encoded
+// blobs are passed through when the client accepts gzip and decompressed
+// otherwise, so the function never has to recompress large files.
+func (h *Handler) respondBlob(w http.ResponseWriter, r *http.Request, status int, blob *storage.Blob) {
+	data := blob.Data
+	passThrough := blob.ContentEncoding == "gzip" && acceptsGzip(r)
+
+	if blob.ContentEncoding == "gzip" && !passThrough {
+		decompressed, err := gunzip(data)
+		if err != nil {
+			log.Printf("Error decompressing blob: %v", err)
+			h.respondError(w, r, http.StatusInternalServerError, types.ErrCodeInternal, "Internal server error", "")
+			return
+		}
+		data = decompressed
 	}
+
+	origin := r.Header.Get("Origin")
+	h.setCORSHeaders(w, origin)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300") // 5 minutes
+	w.Header().Add("Vary", "Accept-Encoding")
+	if passThrough {
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+	w.WriteHeader(status)
+
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Error writing blob response: %v", err)
+	}
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.TrimSpace(coding)
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		// An explicit q=0 means the coding is not acceptable
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gunzip decompresses gzip data.
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer func() {
+		if closeErr := reader.Close(); closeErr != nil {
+			log.Printf("Error closing gzip reader: %v", closeErr)
+		}
+	}()
+
+	return io.ReadAll(reader)
+}
+
+// validateBlob decodes a raw (possibly gzipped) blob and validates it
+// against the schema registered for dataType.
+func validateBlob(dataType string, blob *storage.Blob) error {
+	data := blob.Data
+	if blob.ContentEncoding == "gzip" {
+		plain, err := gunzip(data)
+		if err != nil {
+			return fmt.Errorf("failed to decompress blob: %w", err)
+		}
+		data = plain
+	}
+
+	var parsed interface{}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return fmt.Errorf("failed to parse blob JSON: %w", err)
+	}
+	return schema.Validate(dataType, parsed)
+}
+
+// respondInvalidBlob reports stored data that failed schema validation. The
+// gateway is acting as a proxy for the processing pipeline, so this is a 502.
+func (h *Handler) respondInvalidBlob(w http.ResponseWriter, r *http.Request, err error, blobPath, resource string) {
+	log.Printf("[%s] Schema validation failed for %s: %v", w.Header().Get(correlationIDHeader), blobPath, err)
+	h.respondError(w, r, http.StatusBadGateway, types.ErrCodeMalformedData, fmt.Sprintf("Stored data for %s is malformed", resource), err.Error())
+}
+
+// respondStorageError maps a storage read failure to an HTTP error response.
+// resource is the user-facing name of what was requested (e.g. "2024/distances").
+func (h *Handler) respondStorageError(w http.ResponseWriter, r *http.Request, err error, blobPath, resource string) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		h.respondError(w, r, http.StatusNotFound, types.ErrCodeNotFound, fmt.Sprintf("Data not found for %s", resource), "")
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Printf("[%s] Timed out reading blob %s: %v", w.Header().Get(correlationIDHeader), blobPath, err)
+		h.respondError(w, r, http.StatusGatewayTimeout, types.ErrCodeStorageTimeout, "Storage request timed out", "")
+	default:
+		log.Printf("[%s] Error reading blob %s: %v", w.Header().Get(correlationIDHeader), blobPath, err)
+		h.respondError(w, r, http.StatusInternalServerError, types.ErrCodeInternal, "Internal server error", "")
+	}
+}
+
+// respondError writes a structured error response with CORS headers. details
+// is optional extra context and is omitted from the body when empty.
+func (h *Handler) respondError(w http.ResponseWriter, r *http.Request, status int, code types.ErrorCode, message, details string) {
+	h.respondErrorResponse(w, r, status, types.ErrorResponse{
+		Code:    code,
+		Message: message,
+		Details: details,
+	})
+}
+
+// respondErrorResponse writes a prepared error envelope, filling in the
+// request's correlation ID.
+func (h *Handler) respondErrorResponse(w http.ResponseWriter, r *http.Request, status int, response types.ErrorResponse) {
+	response.CorrelationID = w.Header().Get(correlationIDHeader)
 	h.respondJSON(w, r, status, response)
 }
+
+// correlationID returns the caller-supplied correlation ID when it is
+// reasonably sized, otherwise a new random one.
+func correlationID(r *http.Request) string {
+	if id := r.Header.Get(correlationIDHeader); id != "" && len(id) <= maxCorrelationIDLength {
+		return id
+	}
+	return uuid.New().String()
+}
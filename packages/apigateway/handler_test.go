@@ -2,16 +2,25 @@ package apigateway
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/andy-esch/desirelines/packages/apigateway/storage"
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+	"github.com/andy-esch/desirelines/packages/ipfilter"
 )
 
 // mockStorageClient is a mock implementation for testing
 type mockStorageClient struct {
 	ReadJSONFunc func(ctx context.Context, blobPath string) (interface{}, error)
+	ReadBlobFunc func(ctx context.Context, blobPath string) (*storage.Blob, error)
+	StatFunc     func(ctx context.Context, blobPath string) (*storage.ObjectInfo, error)
+	PingFunc     func(ctx context.Context) error
 }
 
 func (m *mockStorageClient) ReadJSON(ctx context.Context, blobPath string) (interface{}, error) {
@@ -21,6 +30,27 @@ func (m *mockStorageClient) ReadJSON(ctx context.Context, blobPath string) (inte
 	return nil, storage.ErrNotFound
 }
 
+func (m *mockStorageClient) ReadBlob(ctx context.Context, blobPath string) (*storage.Blob, error) {
+	if m.ReadBlobFunc != nil {
+		return m.ReadBlobFunc(ctx, blobPath)
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (m *mockStorageClient) Stat(ctx context.Context, blobPath string) (*storage.ObjectInfo, error) {
+	if m.StatFunc != nil {
+		return m.StatFunc(ctx, blobPath)
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (m *mockStorageClient) Ping(ctx context.Context) error {
+	if m.PingFunc != nil {
+		return m.PingFunc(ctx)
+	}
+	return nil
+}
+
 func TestHandlerHealth(t *testing.T) {
 	mock := &mockStorageClient{}
 	handler := NewHandlerWithStorage(mock)
@@ -40,6 +70,174 @@ func TestHandlerHealth(t *testing.T) {
 	}
 }
 
+func TestHandlerDeepHealth(t *testing.T) {
+	t.Run("healthy storage", func(t *testing.T) {
+		handler := NewHandlerWithStorage(&mockStorageClient{})
+
+		req := httptest.NewRequest(http.MethodGet, "/health?deep=true", nil)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", w.Code)
+		}
+
+		var response types.HealthResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if response.Checks["storage"] != "ok" {
+			t.Errorf("expected storage check ok, got %q", response.Checks["storage"])
+		}
+	})
+
+	t.Run("unreachable storage", func(t *testing.T) {
+		handler := NewHandlerWithStorage(&mockStorageClient{
+			PingFunc: func(ctx context.Context) error {
+				return errors.New("bucket my-bucket does not exist")
+			},
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/health?deep=true", nil)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected status 503, got %d", w.Code)
+		}
+
+		var response types.HealthResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if response.Status != "unhealthy" {
+			t.Errorf("expected status unhealthy, got %s", response.Status)
+		}
+		if got := response.Checks["storage"]; got != "unavailable" {
+			t.Errorf("expected storage check unavailable without error details, got %q", got)
+		}
+	})
+
+	t.Run("shallow check skips storage", func(t *testing.T) {
+		handler := NewHandlerWithStorage(&mockStorageClient{
+			PingFunc: func(ctx context.Context) error {
+				t.Error("storage should not be probed without deep=true")
+				return nil
+			},
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", w.Code)
+		}
+	})
+}
+
+func TestHandlerLiveAndReady(t *testing.T) {
+	t.Run("live", func(t *testing.T) {
+		handler := NewHandlerWithStorage(&mockStorageClient{})
+
+		req := httptest.NewRequest(http.MethodGet, "/live", nil)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("ready with storage", func(t *testing.T) {
+		handler := NewHandlerWithStorage(&mockStorageClient{})
+
+		req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("not ready while storage is unavailable", func(t *testing.T) {
+		pings := 0
+		handler := NewHandlerWithStorage(&mockStorageClient{
+			PingFunc: func(ctx context.Context) error {
+				pings++
+				return errors.New("bucket my-bucket: permission denied")
+			},
+		})
+
+		for range 2 {
+			req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusServiceUnavailable {
+				t.Errorf("expected status 503, got %d", w.Code)
+			}
+			if strings.Contains(w.Body.String(), "my-bucket") {
+				t.Errorf("expected no storage details in the response, got %s", w.Body)
+			}
+		}
+		if pings != 1 {
+			t.Errorf("expected the probe result to be cached, got %d pings", pings)
+		}
+	})
+
+	t.Run("probes storage again after the cache TTL", func(t *testing.T) {
+		var healthy bool
+		handler := NewHandlerWithStorage(&mockStorageClient{
+			PingFunc: func(ctx context.Context) error {
+				if !healthy {
+					return errors.New("unavailable")
+				}
+				return nil
+			},
+		})
+		now := time.Now()
+		handler.storageHealth.now = func() time.Time { return now }
+		ready := func() int {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+			return w.Code
+		}
+
+		if code := ready(); code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status 503, got %d", code)
+		}
+		healthy = true
+		if code := ready(); code != http.StatusServiceUnavailable {
+			t.Errorf("expected the cached failure within the TTL, got %d", code)
+		}
+		now = now.Add(storageCheckCacheTTL)
+		if code := ready(); code != http.StatusOK {
+			t.Errorf("expected status 200 after the TTL, got %d", code)
+		}
+	})
+
+	t.Run("not ready without storage", func(t *testing.T) {
+		handler := NewHandlerWithStorage(nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected status 503, got %d", w.Code)
+		}
+	})
+}
+
 func TestHandlerCORS(t *testing.T) {
 	mock := &mockStorageClient{}
 	handler := NewHandlerWithStorage(mock)
@@ -64,8 +262,8 @@ func TestHandlerCORS(t *testing.T) {
 		}
 
 		allowMethods := w.Header().Get("Access-Control-Allow-Methods")
-		if allowMethods != "GET, OPTIONS" {
-			t.Errorf("expected Allow-Methods to be GET, OPTIONS, got %s", allowMethods)
+		if allowMethods != "GET, PUT, OPTIONS" {
+			t.Errorf("expected Allow-Methods to be GET, PUT, OPTIONS, got %s", allowMethods)
 		}
 	})
 
@@ -106,6 +304,21 @@ func TestHandlerCORS(t *testing.T) {
 		}
 	})
 
+	t.Run("wildcard preview channel origin", func(t *testing.T) {
+		t.Setenv("ALLOWED_ORIGINS", "https://*.web.app,http://localhost:*")
+
+		req := httptest.NewRequest(http.MethodOptions, "/health", nil)
+		req.Header.Set("Origin", "https://desirelines-dev--pr42-x1y2.web.app")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		allowedOrigin := w.Header().Get("Access-Control-Allow-Origin")
+		if allowedOrigin != "https://desirelines-dev--pr42-x1y2.web.app" {
+			t.Errorf("expected CORS origin to echo preview origin, got %s", allowedOrigin)
+		}
+	})
+
 	t.Run("no ALLOWED_ORIGINS env var blocks all origins", func(t *testing.T) {
 		// Ensure ALLOWED_ORIGINS is not set
 		t.Setenv("ALLOWED_ORIGINS", "")
@@ -186,3 +399,219 @@ func TestHandlerActivities(t *testing.T) {
 		}
 	})
 }
+
+func TestHandlerValidateBlobs(t *testing.T) {
+	mock := &mockStorageClient{
+		ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+			switch blobPath {
+			case "activities/2024/distances.json":
+				return map[string]interface{}{
+					"distance_traveled": []interface{}{
+						map[string]interface{}{"x": "2024-01-01", "y": 10.5},
+					},
+				}, nil
+			case "activities/2023/distances.json":
+				return map[string]interface{}{"distance_traveled": "garbage"}, nil
+			}
+			return nil, storage.ErrNotFound
+		},
+		ReadBlobFunc: func(ctx context.Context, blobPath string) (*storage.Blob, error) {
+			if blobPath == "activities/2022/summary_activities.json.gz" {
+				return &storage.Blob{Data: []byte(`{"not-a-date": {}}`)}, nil
+			}
+			return nil, storage.ErrNotFound
+		},
+	}
+
+	handler := NewHandlerWithStorage(mock)
+	handler.validateBlobs = true
+	handler.servePrecompressed = true
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{"valid blob served", "/activities/2024/distances", http.StatusOK},
+		{"malformed blob rejected", "/activities/2023/distances", http.StatusBadGateway},
+		{"malformed precompressed blob rejected", "/activities/2022/summary", http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		plain := NewHandlerWithStorage(mock)
+		req := httptest.NewRequest(http.MethodGet, "/activities/2023/distances", nil)
+		w := httptest.NewRecorder()
+
+		plain.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", w.Code)
+		}
+	})
+}
+
+func TestHandlerErrorResponse(t *testing.T) {
+	handler := NewHandlerWithStorage(&mockStorageClient{})
+
+	t.Run("structured body with generated correlation ID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/activities/2024/distances", nil)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected status 404, got %d", w.Code)
+		}
+
+		var response types.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if response.Code != types.ErrCodeNotFound {
+			t.Errorf("expected code %s, got %s", types.ErrCodeNotFound, response.Code)
+		}
+		if response.Message != "Data not found for 2024/distances" {
+			t.Errorf("unexpected message %q", response.Message)
+		}
+		if response.CorrelationID == "" {
+			t.Error("expected a correlation ID")
+		}
+		if got := w.Header().Get("X-Correlation-ID"); got != response.CorrelationID {
+			t.Errorf("expected header %q to match body %q", got, response.CorrelationID)
+		}
+	})
+
+	t.Run("reuses caller correlation ID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/activities/2024/invalid", nil)
+		req.Header.Set("X-Correlation-ID", "abc-123")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		var response types.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if response.Code != types.ErrCodeInvalidDataType {
+			t.Errorf("expected code %s, got %s", types.ErrCodeInvalidDataType, response.Code)
+		}
+		if response.CorrelationID != "abc-123" {
+			t.Errorf("expected correlation ID abc-123, got %q", response.CorrelationID)
+		}
+	})
+}
+
+func TestHandlerFreshness(t *testing.T) {
+	updated := time.Date(2024, 6, 15, 12, 30, 0, 0, time.UTC)
+
+	mock := &mockStorageClient{
+		ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+			if blobPath == "activities/2024/summary_activities.json" {
+				return map[string]interface{}{
+					"2024-06-14": map[string]interface{}{"distance_miles": 3.1},
+					"2024-01-02": map[string]interface{}{"distance_miles": 1.1},
+				}, nil
+			}
+			return nil, storage.ErrNotFound
+		},
+		StatFunc: func(ctx context.Context, blobPath string) (*storage.ObjectInfo, error) {
+			if blobPath == "activities/2024/summary_activities.json" {
+				return &storage.ObjectInfo{Updated: updated}, nil
+			}
+			return nil, storage.ErrNotFound
+		},
+	}
+
+	handler := NewHandlerWithStorage(mock)
+
+	t.Run("successful request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/activities/2024/freshness", nil)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+
+		var response types.FreshnessResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+
+		if !response.LastUpdated.Equal(updated) {
+			t.Errorf("expected last_updated %v, got %v", updated, response.LastUpdated)
+		}
+		if response.NewestActivityDate != "2024-06-14" {
+			t.Errorf("expected newest_activity_date 2024-06-14, got %s", response.NewestActivityDate)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/activities/2023/freshness", nil)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", w.Code)
+		}
+	})
+}
+
+func TestHandlerIPFilter(t *testing.T) {
+	filter, err := ipfilter.Parse("192.168.1.0/24", "")
+	if err != nil {
+		t.Fatalf("ipfilter.Parse failed: %v", err)
+	}
+	handler := NewHandlerWithStorage(&mockStorageClient{})
+	handler.ipFilter = filter
+	handler.trustedProxies, err = ipfilter.ParseTrustedProxies("10.0.0.1")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		remoteAddr string
+		forwarded  string
+		wantStatus int
+	}{
+		{"allowed network", "/health", "192.168.1.50:4000", "", http.StatusOK},
+		{"outside allowlist", "/health", "198.51.100.1:4000", "", http.StatusForbidden},
+		{"probes exempt", "/ready", "198.51.100.1:4000", "", http.StatusOK},
+		{"forwarded by a trusted proxy", "/health", "10.0.0.1:4000", "192.168.1.50", http.StatusOK},
+		{"spoofed by a direct client", "/health", "198.51.100.1:4000", "192.168.1.50", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
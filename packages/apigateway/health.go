@@ -0,0 +1,57 @@
+package apigateway
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/storage"
+)
+
+const (
+	// storageCheckCacheTTL is how long a storage probe result is reused, so
+	// frequent readiness probes don't each call the bucket.
+	storageCheckCacheTTL = 10 * time.Second
+
+	// storageCheckTimeout bounds a storage probe.
+	storageCheckTimeout = 5 * time.Second
+)
+
+// errStorageNotInitialized is reported by a handler built without storage.
+var errStorageNotInitialized = errors.New("storage client not initialized")
+
+// storageHealth probes storage with Ping, caching the result for
+// storageCheckCacheTTL.
+type storageHealth struct {
+	checkedAt time.Time
+	err       error
+	now       func() time.Time
+	client    storage.Client
+	mu        sync.Mutex
+}
+
+func newStorageHealth(client storage.Client) *storageHealth {
+	return &storageHealth{client: client, now: time.Now}
+}
+
+// check returns the error of the last probe, probing again once it is
+// older than storageCheckCacheTTL.
+func (s *storageHealth) check(ctx context.Context) error {
+	if s.client == nil {
+		return errStorageNotInitialized
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if !s.checkedAt.IsZero() && now.Sub(s.checkedAt) < storageCheckCacheTTL {
+		return s.err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storageCheckTimeout)
+	defer cancel()
+	s.err = s.client.Ping(ctx)
+	s.checkedAt = now
+	return s.err
+}
@@ -0,0 +1,85 @@
+package apigateway
+
+import (
+	"log"
+	"sync"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+)
+
+// subscriberBufferSize bounds how many events may queue for a slow client
+// before further events are dropped for it.
+const subscriberBufferSize = 16
+
+// Hub fans out data-updated events to connected WebSocket subscribers.
+type Hub struct {
+	subscribers map[*subscriber]struct{}
+	mu          sync.Mutex
+}
+
+// subscriber receives events matching its optional year and athlete filters.
+type subscriber struct {
+	events    chan types.DataUpdatedEvent
+	year      string
+	athleteID string
+}
+
+// NewHub creates an empty hub.
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Subscribe registers a subscriber. Empty filters match every event.
+func (h *Hub) Subscribe(year, athleteID string) *subscriber {
+	sub := &subscriber{
+		events:    make(chan types.DataUpdatedEvent, subscriberBufferSize),
+		year:      year,
+		athleteID: athleteID,
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe removes a subscriber and closes its event channel.
+func (h *Hub) Unsubscribe(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// Broadcast delivers an event to every matching subscriber without blocking.
+func (h *Hub) Broadcast(event types.DataUpdatedEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers {
+		if !sub.matches(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			log.Printf("WebSocket: subscriber buffer full, dropping event for %s", event.Path)
+		}
+	}
+}
+
+// matches reports whether the event passes the subscriber's filters.
+func (s *subscriber) matches(event types.DataUpdatedEvent) bool {
+	if s.year != "" && s.year != event.Year {
+		return false
+	}
+	if s.athleteID != "" && s.athleteID != event.AthleteID {
+		return false
+	}
+	return true
+}
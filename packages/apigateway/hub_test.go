@@ -0,0 +1,53 @@
+package apigateway
+
+import (
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+)
+
+func TestHub(t *testing.T) {
+	t.Run("delivers matching events only", func(t *testing.T) {
+		hub := NewHub()
+		all := hub.Subscribe("", "")
+		year2024 := hub.Subscribe("2024", "")
+		defer hub.Unsubscribe(all)
+		defer hub.Unsubscribe(year2024)
+
+		hub.Broadcast(types.DataUpdatedEvent{Year: "2023", Path: "activities/2023/distances.json"})
+
+		if len(all.events) != 1 {
+			t.Errorf("expected unfiltered subscriber to receive 1 event, got %d", len(all.events))
+		}
+		if len(year2024.events) != 0 {
+			t.Errorf("expected 2024 subscriber to receive no events, got %d", len(year2024.events))
+		}
+	})
+
+	t.Run("filters by athlete", func(t *testing.T) {
+		hub := NewHub()
+		sub := hub.Subscribe("", "42")
+		defer hub.Unsubscribe(sub)
+
+		hub.Broadcast(types.DataUpdatedEvent{Year: "2024", AthleteID: "7"})
+		hub.Broadcast(types.DataUpdatedEvent{Year: "2024", AthleteID: "42"})
+
+		if len(sub.events) != 1 {
+			t.Errorf("expected 1 event for athlete 42, got %d", len(sub.events))
+		}
+	})
+
+	t.Run("drops events for full subscribers without blocking", func(t *testing.T) {
+		hub := NewHub()
+		sub := hub.Subscribe("", "")
+		defer hub.Unsubscribe(sub)
+
+		for i := 0; i < subscriberBufferSize+5; i++ {
+			hub.Broadcast(types.DataUpdatedEvent{Year: "2024"})
+		}
+
+		if len(sub.events) != subscriberBufferSize {
+			t.Errorf("expected buffer to be capped at %d, got %d", subscriberBufferSize, len(sub.events))
+		}
+	})
+}
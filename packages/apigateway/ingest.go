@@ -0,0 +1,135 @@
+package apigateway
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/schema"
+	"github.com/andy-esch/desirelines/packages/apigateway/storage"
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+)
+
+// maxIngestBodyBytes bounds PUT /activities bodies; a year of summaries of
+// daily activities is a few hundred KB.
+const maxIngestBodyBytes = 8 << 20
+
+// acceptsPut reports whether requests to path may use PUT: goals, and
+// chart data at activities/{year}/{type}, also under an athlete's prefix.
+func acceptsPut(path string) bool {
+	if strings.HasPrefix(path, "/goals/") || strings.HasPrefix(path, "/activities/") {
+		return true
+	}
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 4)
+	return len(parts) == 4 && parts[0] == "athletes" && parts[2] == "activities"
+}
+
+// authorizeIngest checks the request's bearer token against INGEST_TOKEN
+// and ADMIN_TOKEN, so an external pipeline can publish chart data without
+// the admin token. Ingest is disabled (404) when neither is configured.
+func (h *Handler) authorizeIngest(w http.ResponseWriter, r *http.Request) bool {
+	if h.ingestToken == "" && h.adminToken == "" {
+		h.respondError(w, r, http.StatusNotFound, types.ErrCodeNotFound, "Not found", "")
+		return false
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	for _, valid := range []string{h.ingestToken, h.adminToken} {
+		if ok && valid != "" && subtle.ConstantTimeCompare([]byte(token), []byte(valid)) == 1 {
+			return true
+		}
+	}
+	h.respondError(w, r, http.StatusUnauthorized, types.ErrCodeUnauthorized, "Invalid ingest token", "")
+	return false
+}
+
+// putActivities replaces the chart data blob of the year and data type
+// under prefix with the request body, once it validates against the data
+// type's schema. It is written as sent, and with SERVE_PRECOMPRESSED the
+// .gz sibling that is served first is deleted, so it doesn't shadow the new
+// data until the processor writes a fresh one.
+func (h *Handler) putActivities(w http.ResponseWriter, r *http.Request, prefix, year, dataType string) {
+	if !h.authorizeIngest(w, r) {
+		return
+	}
+	if !validYear(year) {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeBadRequest, fmt.Sprintf("Invalid year: %s", year), "")
+		return
+	}
+	blobPath, ok := yearDataBlob(prefix, year, dataType)
+	if !ok {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeInvalidDataType, fmt.Sprintf("Invalid data type: %s", dataType), "Expected summary or distances")
+		return
+	}
+
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(http.MaxBytesReader(w, r.Body, maxIngestBodyBytes)); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.respondError(w, r, http.StatusRequestEntityTooLarge, types.ErrCodeInvalidPayload, "Payload too large", fmt.Sprintf("At most %d bytes", maxIngestBodyBytes))
+			return
+		}
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeInvalidPayload, "Failed to read payload", "")
+		return
+	}
+	var data interface{}
+	if err := json.Unmarshal(body.Bytes(), &data); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeInvalidPayload, "Invalid JSON payload", "")
+		return
+	}
+	if err := schema.Validate(dataType, data); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeInvalidPayload, fmt.Sprintf("Invalid %s data", dataType), err.Error())
+		return
+	}
+
+	if h.servePrecompressed && !h.deleteBlob(w, r, blobPath+".gz") {
+		return
+	}
+	if !h.writeBlob(w, r, blobPath, body.Bytes()) {
+		return
+	}
+
+	log.Printf("Ingest: stored %s (%d bytes)", blobPath, body.Len())
+	h.setCORSHeaders(w, r.Header.Get("Origin"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeBlob writes data to blobPath, responding with an error and returning
+// false when storage is read-only or the write fails.
+func (h *Handler) writeBlob(w http.ResponseWriter, r *http.Request, blobPath string, data []byte) bool {
+	return h.modifyBlob(w, r, blobPath, func(writer storage.Writer) error {
+		return writer.WriteBlob(r.Context(), blobPath, data)
+	})
+}
+
+// deleteBlob deletes blobPath, responding like writeBlob on failure.
+func (h *Handler) deleteBlob(w http.ResponseWriter, r *http.Request, blobPath string) bool {
+	return h.modifyBlob(w, r, blobPath, func(writer storage.Writer) error {
+		return writer.DeleteBlob(r.Context(), blobPath)
+	})
+}
+
+// modifyBlob applies modify to the storage writer, responding with an error
+// and returning false when storage is read-only or modify fails.
+func (h *Handler) modifyBlob(w http.ResponseWriter, r *http.Request, blobPath string, modify func(storage.Writer) error) bool {
+	writer, ok := h.storage.(storage.Writer)
+	var err error
+	if ok {
+		err = modify(writer)
+	}
+	switch {
+	case !ok || errors.Is(err, storage.ErrReadOnly):
+		h.respondError(w, r, http.StatusNotImplemented, types.ErrCodeReadOnly, "Storage is read-only", "")
+		return false
+	case err != nil:
+		log.Printf("[%s] Error writing blob %s: %v", w.Header().Get(correlationIDHeader), blobPath, err)
+		h.respondError(w, r, http.StatusInternalServerError, types.ErrCodeInternal, "Internal server error", "")
+		return false
+	}
+	return true
+}
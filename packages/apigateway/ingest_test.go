@@ -0,0 +1,174 @@
+package apigateway
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/storage"
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+	"github.com/andy-esch/desirelines/packages/athletes"
+)
+
+const testDistances = `{"distance_traveled": [{"x": "2025-01-01", "y": 12.5}]}`
+
+func ingestRequest(t *testing.T, handler *Handler, path, body, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func newIngestHandler(t *testing.T) (*Handler, string) {
+	t.Helper()
+	dir := t.TempDir()
+	local, err := storage.NewLocalStorageClient(dir)
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	handler := NewHandlerWithStorage(storage.NewMemoryCacheClient(local, time.Hour))
+	handler.adminToken = "admin-secret"
+	handler.ingestToken = "ingest-secret"
+	return handler, dir
+}
+
+func TestHandlerIngest(t *testing.T) {
+	handler, dir := newIngestHandler(t)
+
+	// Cached before the write, so a stale read would show
+	if w := exportRequest(t, handler, "/activities/2025/distances", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 before ingest, got %d", w.Code)
+	}
+	w := ingestRequest(t, handler, "/activities/2025/distances", testDistances, "ingest-secret")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body)
+	}
+	stored, err := os.ReadFile(filepath.Join(dir, "activities", "2025", "distances.json"))
+	if err != nil || string(stored) != testDistances {
+		t.Errorf("expected the body stored as sent, got %q (%v)", stored, err)
+	}
+	w = exportRequest(t, handler, "/activities/2025/distances", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "12.5") {
+		t.Errorf("expected the ingested data served, got %d: %s", w.Code, w.Body)
+	}
+
+	summary := `{"2025-01-01": {"distance_miles": 12.5, "activity_ids": [1]}}`
+	if w := ingestRequest(t, handler, "/activities/2025/summary", summary, "admin-secret"); w.Code != http.StatusNoContent {
+		t.Errorf("expected the admin token accepted, got %d: %s", w.Code, w.Body)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "activities", "2025", "summary_activities.json")); err != nil {
+		t.Errorf("expected the summary stored: %v", err)
+	}
+}
+
+func TestHandlerIngestErrors(t *testing.T) {
+	handler, _ := newIngestHandler(t)
+
+	tests := []struct {
+		name, path, body, token string
+		want                    int
+		code                    types.ErrorCode
+	}{
+		{"no token", "/activities/2025/distances", testDistances, "", http.StatusUnauthorized, types.ErrCodeUnauthorized},
+		{"wrong token", "/activities/2025/distances", testDistances, "notify-secret", http.StatusUnauthorized, types.ErrCodeUnauthorized},
+		{"bad year", "/activities/25/distances", testDistances, "ingest-secret", http.StatusBadRequest, types.ErrCodeBadRequest},
+		{"derived type", "/activities/2025/freshness", testDistances, "ingest-secret", http.StatusBadRequest, types.ErrCodeInvalidDataType},
+		{"streams", "/activities/123/streams", `{}`, "ingest-secret", http.StatusBadRequest, types.ErrCodeBadRequest},
+		{"invalid JSON", "/activities/2025/distances", `{"distance_traveled":`, "ingest-secret", http.StatusBadRequest, types.ErrCodeInvalidPayload},
+		{"wrong shape", "/activities/2025/distances", `{"distance_traveled": [{"x": "Jan 1", "y": 1}]}`, "ingest-secret", http.StatusBadRequest, types.ErrCodeInvalidPayload},
+		{"too large", "/activities/2025/distances", `"` + strings.Repeat("x", maxIngestBodyBytes) + `"`, "ingest-secret", http.StatusRequestEntityTooLarge, types.ErrCodeInvalidPayload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ingestRequest(t, handler, tt.path, tt.body, tt.token)
+			var resp types.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid error body %s: %v", w.Body, err)
+			}
+			if w.Code != tt.want || resp.Code != tt.code {
+				t.Errorf("expected %d %s, got %d %s: %s", tt.want, tt.code, w.Code, resp.Code, resp.Details)
+			}
+		})
+	}
+
+	handler = NewHandlerWithStorage(&mockStorageClient{})
+	if w := ingestRequest(t, handler, "/activities/2025/distances", testDistances, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without tokens, got %d", w.Code)
+	}
+	handler.ingestToken = "ingest-secret"
+	if w := ingestRequest(t, handler, "/activities/2025/distances", testDistances, "ingest-secret"); w.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 for read-only storage, got %d", w.Code)
+	}
+}
+
+// gzipBytes compresses s.
+func gzipBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write([]byte(s)); err != nil {
+		t.Fatalf("failed to gzip payload: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("failed to close gzip writer: %v", err)
+	}
+	return compressed.Bytes()
+}
+
+func TestHandlerIngestPrecompressed(t *testing.T) {
+	handler, dir := newIngestHandler(t)
+	handler.servePrecompressed = true
+	stale := filepath.Join(dir, "activities", "2025", "distances.json.gz")
+	if err := os.MkdirAll(filepath.Dir(stale), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, gzipBytes(t, `{"distance_traveled": []}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := ingestRequest(t, handler, "/activities/2025/distances", testDistances, "ingest-secret"); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("expected the old .gz to be deleted, got %v", err)
+	}
+	w := exportRequest(t, handler, "/activities/2025/distances", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "12.5") {
+		t.Errorf("expected the ingested data served over the old .gz, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestHandlerAthleteIngest(t *testing.T) {
+	handler, dir := newIngestHandler(t)
+	handler.athletes = athletes.New(func(context.Context) ([]byte, error) {
+		return []byte(`{"athletes": [{"id": 7, "prefix": "athletes/7/"}]}`), nil
+	}, 0)
+
+	if w := ingestRequest(t, handler, "/athletes/7/activities/2025/distances", testDistances, "ingest-secret"); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "athletes", "7", "activities", "2025", "distances.json")); err != nil {
+		t.Errorf("expected the data stored under the athlete's prefix: %v", err)
+	}
+	if w := ingestRequest(t, handler, "/athletes/9/activities/2025/distances", testDistances, "ingest-secret"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unregistered athlete, got %d", w.Code)
+	}
+	if w := ingestRequest(t, handler, "/athletes/7/digests/2025/7", `{}`, "ingest-secret"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for a digest, got %d", w.Code)
+	}
+	if w := ingestRequest(t, handler, "/athletes/7/activities/123/export", `{}`, "admin-secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an export, got %d", w.Code)
+	}
+}
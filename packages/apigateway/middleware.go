@@ -0,0 +1,21 @@
+package apigateway
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// withTimeout bounds the request context so that slow storage reads are
+// cancelled instead of running until the Cloud Function is killed.
+// A non-positive timeout disables the deadline.
+func withTimeout(timeout time.Duration, next http.Handler) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
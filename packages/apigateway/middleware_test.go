@@ -0,0 +1,60 @@
+package apigateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	t.Run("sets deadline on request context", func(t *testing.T) {
+		var hasDeadline bool
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, hasDeadline = r.Context().Deadline()
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		withTimeout(time.Second, next).ServeHTTP(httptest.NewRecorder(), req)
+
+		if !hasDeadline {
+			t.Error("expected request context to have a deadline")
+		}
+	})
+
+	t.Run("zero timeout disables deadline", func(t *testing.T) {
+		var hasDeadline bool
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, hasDeadline = r.Context().Deadline()
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		withTimeout(0, next).ServeHTTP(httptest.NewRecorder(), req)
+
+		if hasDeadline {
+			t.Error("expected no deadline when timeout is zero")
+		}
+	})
+}
+
+func TestHandlerStorageTimeout(t *testing.T) {
+	mock := &mockStorageClient{
+		ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+
+	handler := NewHandlerWithStorage(mock)
+	handler.requestTimeout = 10 * time.Millisecond
+
+	req := httptest.NewRequest(http.MethodGet, "/activities/2024/distances", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status 504, got %d", w.Code)
+	}
+}
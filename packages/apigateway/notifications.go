@@ -0,0 +1,265 @@
+package apigateway
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/storage"
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+	"github.com/andy-esch/desirelines/packages/athletes"
+)
+
+// GCS notification event types that change served data.
+const (
+	gcsEventObjectFinalize = "OBJECT_FINALIZE"
+	gcsEventObjectDelete   = "OBJECT_DELETE"
+)
+
+// pubSubPushEnvelope is the body Pub/Sub sends to push subscription endpoints.
+type pubSubPushEnvelope struct {
+	Message struct {
+		Attributes map[string]string `json:"attributes"`
+		Data       []byte            `json:"data"`
+		MessageID  string            `json:"messageId"`
+	} `json:"message"`
+	Subscription string `json:"subscription"`
+}
+
+// gcsObject is the subset of the GCS object resource carried in notification data.
+type gcsObject struct {
+	Updated time.Time `json:"updated"`
+	Name    string    `json:"name"`
+}
+
+// ObjectChange describes a changed blob reported by a GCS notification.
+type ObjectChange struct {
+	Updated   time.Time
+	EventType string
+	Path      string
+}
+
+// handleGCSNotification receives GCS object-change notifications delivered by
+// a Pub/Sub push subscription and fans them out to interested components.
+//
+// The push endpoint must include ?token=<NOTIFICATION_TOKEN>; the endpoint is
+// disabled when no token is configured.
+func (h *Handler) handleGCSNotification(w http.ResponseWriter, r *http.Request) {
+	if h.notificationToken == "" {
+		h.respondError(w, r, http.StatusNotFound, types.ErrCodeNotFound, "Not found", "")
+		return
+	}
+	if r.Method != http.MethodPost {
+		h.respondError(w, r, http.StatusMethodNotAllowed, types.ErrCodeMethodNotAllowed, "Method not allowed", "")
+		return
+	}
+	token := r.URL.Query().Get("token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.notificationToken)) != 1 {
+		h.respondError(w, r, http.StatusUnauthorized, types.ErrCodeUnauthorized, "Invalid notification token", "")
+		return
+	}
+
+	var envelope pubSubPushEnvelope
+	if err := json.NewDecoder(r.Body).Decode(&envelope); err != nil {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeInvalidPayload, "Invalid Pub/Sub push payload", "")
+		return
+	}
+
+	change, err := parseObjectChange(envelope)
+	if err != nil {
+		// Acknowledge so Pub/Sub does not redeliver a message we can never use
+		log.Printf("Ignoring GCS notification %s: %v", envelope.Message.MessageID, err)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h.onObjectChange(change)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseObjectChange extracts the changed object from a push envelope.
+func parseObjectChange(envelope pubSubPushEnvelope) (ObjectChange, error) {
+	attrs := envelope.Message.Attributes
+	change := ObjectChange{
+		EventType: attrs["eventType"],
+		Path:      attrs["objectId"],
+	}
+
+	if change.EventType != gcsEventObjectFinalize && change.EventType != gcsEventObjectDelete {
+		return change, fmt.Errorf("unsupported event type: %q", change.EventType)
+	}
+	if change.Path == "" {
+		return change, fmt.Errorf("missing objectId attribute")
+	}
+
+	// Object metadata is optional; the attributes alone identify the change
+	if len(envelope.Message.Data) > 0 {
+		var obj gcsObject
+		if err := json.Unmarshal(envelope.Message.Data, &obj); err == nil {
+			change.Updated = obj.Updated
+		}
+	}
+	if change.Updated.IsZero() {
+		change.Updated = time.Now().UTC()
+	}
+
+	return change, nil
+}
+
+// onObjectChange reacts to a changed blob.
+func (h *Handler) onObjectChange(change ObjectChange) {
+	log.Printf("Object changed: %s (%s)", change.Path, change.EventType)
+	h.applyObjectChange(change)
+}
+
+// onFixtureChange reacts to an edited local fixture as to a GCS
+// notification, so it is served fresh and WebSocket clients refetch it.
+func (h *Handler) onFixtureChange(change storage.FileChange) {
+	eventType := gcsEventObjectFinalize
+	if change.Deleted {
+		eventType = gcsEventObjectDelete
+		log.Printf("Fixture removed: %s", change.Path)
+	} else {
+		log.Printf("Fixture changed, reloading: %s", change.Path)
+	}
+	h.applyObjectChange(ObjectChange{Updated: time.Now().UTC(), EventType: eventType, Path: change.Path})
+}
+
+// applyObjectChange drops cached reads of a changed blob and tells
+// WebSocket clients about it.
+func (h *Handler) applyObjectChange(change ObjectChange) {
+	if inv, ok := h.storage.(storage.Invalidator); ok {
+		inv.Invalidate(change.Path)
+	}
+
+	if event, ok := h.dataUpdatedEvent(change); ok {
+		h.hub.Broadcast(event)
+	}
+}
+
+// dataUpdatedEvent converts a change under activities/{year}/, at the
+// bucket root or under a registered athlete's prefix, into a push event;
+// other paths are not served by the API and are skipped.
+func (h *Handler) dataUpdatedEvent(change ObjectChange) (types.DataUpdatedEvent, bool) {
+	name, athleteID := h.athleteObject(change.Path)
+	parts := strings.Split(name, "/")
+	if len(parts) != 3 || parts[0] != "activities" {
+		return types.DataUpdatedEvent{}, false
+	}
+
+	return types.DataUpdatedEvent{
+		Type:      "data_updated",
+		Path:      change.Path,
+		Year:      parts[1],
+		DataType:  dataTypeForFile(parts[2]),
+		AthleteID: athleteID,
+		Updated:   change.Updated,
+	}, true
+}
+
+// athleteObject returns the name of the blob at blobPath relative to the
+// registered athlete whose prefix it is under, and that athlete's ID. A
+// blob under no athlete's prefix, or without a registry, is returned as
+// is with no ID.
+func (h *Handler) athleteObject(blobPath string) (string, string) {
+	if h.athletes == nil {
+		return blobPath, ""
+	}
+	registered, err := h.athletes.Athletes(context.Background())
+	if err != nil {
+		log.Printf("Failed to load the athlete registry for %s: %v", blobPath, err)
+		return blobPath, ""
+	}
+
+	var owner *athletes.Athlete
+	for i, athlete := range registered {
+		// Prefixes are unique, but the longest wins if one holds another
+		if strings.HasPrefix(blobPath, athlete.Prefix) && (owner == nil || len(athlete.Prefix) > len(owner.Prefix)) {
+			owner = &registered[i]
+		}
+	}
+	if owner == nil {
+		return blobPath, ""
+	}
+	return strings.TrimPrefix(blobPath, owner.Prefix), strconv.FormatInt(owner.ID, 10)
+}
+
+// dataTypeForFile maps a stored file name to its API data type.
+func dataTypeForFile(file string) string {
+	name := strings.TrimSuffix(file, ".gz")
+	switch name {
+	case "summary_activities.json":
+		return "summary"
+	default:
+		return strings.TrimSuffix(name, path.Ext(name))
+	}
+}
+
+// handleWebSocket streams data-updated events to the client. Optional
+// ?year= and ?athlete_id= query parameters filter the events received.
+func (h *Handler) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if h.notificationToken == "" {
+		h.respondError(w, r, http.StatusNotFound, types.ErrCodeNotFound, "Not found", "")
+		return
+	}
+
+	year := r.URL.Query().Get("year")
+	athleteID := r.URL.Query().Get("athlete_id")
+
+	server := websocket.Server{
+		Handshake: func(cfg *websocket.Config, req *http.Request) error {
+			// Browsers always send Origin; enforce the CORS allowlist for them
+			origin := req.Header.Get("Origin")
+			if origin != "" && !originAllowed(origin, h.corsPolicy().AllowedOrigins) {
+				return fmt.Errorf("origin not allowed: %s", origin)
+			}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			h.streamEvents(ws, year, athleteID)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// streamEvents forwards hub events to a WebSocket until either side closes.
+func (h *Handler) streamEvents(ws *websocket.Conn, year, athleteID string) {
+	sub := h.hub.Subscribe(year, athleteID)
+	defer h.hub.Unsubscribe(sub)
+
+	// Reads only serve to detect the client going away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var msg string
+		for {
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-sub.events:
+			if !ok {
+				return
+			}
+			if err := websocket.JSON.Send(ws, event); err != nil {
+				log.Printf("WebSocket: failed to send event: %v", err)
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package apigateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/storage"
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+	"github.com/andy-esch/desirelines/packages/athletes"
+)
+
+// gcsPushBody builds a Pub/Sub push body for a GCS notification.
+func gcsPushBody(t *testing.T, eventType, objectID string) string {
+	data, err := json.Marshal(map[string]any{
+		"name":    objectID,
+		"updated": "2024-06-15T12:00:00Z",
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal object data: %v", err)
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"message": map[string]any{
+			"attributes": map[string]string{
+				"eventType": eventType,
+				"objectId":  objectID,
+			},
+			"data":      data,
+			"messageId": "1",
+		},
+		"subscription": "projects/p/subscriptions/s",
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal push body: %v", err)
+	}
+	return string(body)
+}
+
+func TestHandlerGCSNotification(t *testing.T) {
+	handler := NewHandlerWithStorage(&mockStorageClient{})
+	handler.notificationToken = "secret"
+
+	t.Run("broadcasts finalize events", func(t *testing.T) {
+		sub := handler.hub.Subscribe("2024", "")
+		defer handler.hub.Unsubscribe(sub)
+
+		body := gcsPushBody(t, "OBJECT_FINALIZE", "activities/2024/summary_activities.json")
+		req := httptest.NewRequest(http.MethodPost, "/notifications/gcs?token=secret", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", w.Code)
+		}
+
+		select {
+		case event := <-sub.events:
+			if event.DataType != "summary" || event.AthleteID != "" {
+				t.Errorf("unexpected event: %+v", event)
+			}
+		default:
+			t.Error("expected an event to be broadcast")
+		}
+	})
+
+	t.Run("broadcasts per-athlete events", func(t *testing.T) {
+		handler := NewHandlerWithStorage(&mockStorageClient{})
+		handler.notificationToken = "secret"
+		handler.athletes = athletes.New(func(context.Context) ([]byte, error) {
+			return []byte(`{"athletes": [{"id": 7, "prefix": "athletes/7/"}, {"id": 9, "prefix": "athletes/9/"}]}`), nil
+		}, 0)
+		sub := handler.hub.Subscribe("2024", "7")
+		defer handler.hub.Unsubscribe(sub)
+
+		for _, objectID := range []string{"athletes/9/activities/2024/distances.json", "athletes/7/activities/2024/distances.json"} {
+			req := httptest.NewRequest(http.MethodPost, "/notifications/gcs?token=secret", strings.NewReader(gcsPushBody(t, "OBJECT_FINALIZE", objectID)))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		select {
+		case event := <-sub.events:
+			if event.Path != "athletes/7/activities/2024/distances.json" || event.Year != "2024" || event.DataType != "distances" || event.AthleteID != "7" {
+				t.Errorf("unexpected event: %+v", event)
+			}
+		default:
+			t.Fatal("expected the athlete's event to be broadcast")
+		}
+		select {
+		case event := <-sub.events:
+			t.Errorf("expected other athletes' events filtered out, got %+v", event)
+		default:
+		}
+	})
+
+	t.Run("invalidates cached blob", func(t *testing.T) {
+		calls := 0
+		mock := &mockStorageClient{
+			ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+				calls++
+				return map[string]interface{}{}, nil
+			},
+		}
+		handler := NewHandlerWithStorage(storage.NewMemoryCacheClient(mock, time.Hour))
+		handler.notificationToken = "secret"
+
+		get := func() {
+			req := httptest.NewRequest(http.MethodGet, "/activities/2024/distances", nil)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		get()
+		get()
+		if calls != 1 {
+			t.Fatalf("expected cached read, got %d storage calls", calls)
+		}
+
+		body := gcsPushBody(t, "OBJECT_FINALIZE", "activities/2024/distances.json")
+		req := httptest.NewRequest(http.MethodPost, "/notifications/gcs?token=secret", strings.NewReader(body))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		get()
+		if calls != 2 {
+			t.Errorf("expected fresh read after notification, got %d storage calls", calls)
+		}
+	})
+
+	t.Run("rejects invalid token", func(t *testing.T) {
+		body := gcsPushBody(t, "OBJECT_FINALIZE", "activities/2024/distances.json")
+		req := httptest.NewRequest(http.MethodPost, "/notifications/gcs?token=wrong", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status 401, got %d", w.Code)
+		}
+	})
+
+	t.Run("acknowledges unsupported event types", func(t *testing.T) {
+		body := gcsPushBody(t, "OBJECT_ARCHIVE", "activities/2024/distances.json")
+		req := httptest.NewRequest(http.MethodPost, "/notifications/gcs?token=secret", strings.NewReader(body))
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNoContent {
+			t.Errorf("expected status 204, got %d", w.Code)
+		}
+	})
+
+	t.Run("disabled without token", func(t *testing.T) {
+		handler := NewHandlerWithStorage(&mockStorageClient{})
+
+		req := httptest.NewRequest(http.MethodPost, "/notifications/gcs", strings.NewReader("{}"))
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d", w.Code)
+		}
+	})
+}
+
+func TestHandlerFixtureReload(t *testing.T) {
+	dir := t.TempDir()
+	fixture := filepath.Join(dir, "activities", "2024", "distances.json")
+	if err := os.MkdirAll(filepath.Dir(fixture), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fixture, []byte(`{"distance_traveled": [1]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	local, err := storage.NewLocalStorageClient(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewHandlerWithStorage(storage.NewMemoryCacheClient(local, time.Hour))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := local.Watch(ctx, handler.onFixtureChange); err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	sub := handler.hub.Subscribe("2024", "")
+	defer handler.hub.Unsubscribe(sub)
+
+	get := func() string {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/activities/2024/distances", nil))
+		return w.Body.String()
+	}
+	if body := get(); !strings.Contains(body, "[1]") {
+		t.Fatalf("unexpected body %s", body)
+	}
+
+	if err := os.WriteFile(fixture, []byte(`{"distance_traveled": [1, 2]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-sub.events:
+		if event.DataType != "distances" {
+			t.Errorf("unexpected event: %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the edit to be broadcast")
+	}
+	if body := get(); !strings.Contains(body, "[1,2]") {
+		t.Errorf("expected the edited fixture served, got %s", body)
+	}
+}
+
+func TestHandlerWebSocketRejectsDisallowedOrigin(t *testing.T) {
+	t.Setenv("ALLOWED_ORIGINS", "https://desirelines-dev.web.app")
+
+	handler := NewHandlerWithStorage(&mockStorageClient{})
+	handler.notificationToken = "secret"
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	if ws, err := websocket.Dial(wsURL, "", "https://evil.com"); err == nil {
+		_ = ws.Close()
+		t.Error("expected handshake to fail for disallowed origin")
+	}
+}
+
+func TestHandlerWebSocket(t *testing.T) {
+	handler := NewHandlerWithStorage(&mockStorageClient{})
+	handler.notificationToken = "secret"
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	t.Setenv("ALLOWED_ORIGINS", server.URL)
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws?year=2024"
+	ws, err := websocket.Dial(wsURL, "", server.URL)
+	if err != nil {
+		t.Fatalf("failed to dial websocket: %v", err)
+	}
+	defer func() {
+		if err := ws.Close(); err != nil {
+			t.Logf("failed to close websocket: %v", err)
+		}
+	}()
+
+	// Wait for the server side to register the subscriber
+	deadline := time.Now().Add(time.Second)
+	for {
+		handler.hub.mu.Lock()
+		n := len(handler.hub.subscribers)
+		handler.hub.mu.Unlock()
+		if n > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	handler.hub.Broadcast(types.DataUpdatedEvent{Type: "data_updated", Year: "2024", DataType: "distances"})
+
+	if err := ws.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("failed to set read deadline: %v", err)
+	}
+
+	var event types.DataUpdatedEvent
+	if err := websocket.JSON.Receive(ws, &event); err != nil {
+		t.Fatalf("failed to receive event: %v", err)
+	}
+	if event.Year != "2024" || event.DataType != "distances" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+}
@@ -0,0 +1,81 @@
+package apigateway
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxPathLength bounds the full request path; real routes are far shorter.
+	maxPathLength = 256
+	// maxPathSegmentLength bounds each "/"-separated path segment.
+	maxPathSegmentLength = 64
+)
+
+// validatePath rejects request paths that no route could match before they
+// reach storage: overlong paths or segments, empty segments, and characters
+// outside [A-Za-z0-9._-]. Segments of only dots are rejected so "..", which
+// could walk out of the activities prefix with local fixtures, never reaches
+// a blob path.
+func validatePath(path string) error {
+	if len(path) > maxPathLength {
+		return fmt.Errorf("path exceeds %d characters", maxPathLength)
+	}
+
+	trimmed := strings.TrimPrefix(path, "/")
+	if trimmed == "" {
+		return nil
+	}
+
+	for _, segment := range strings.Split(trimmed, "/") {
+		if segment == "" {
+			return fmt.Errorf("path contains an empty segment")
+		}
+		if len(segment) > maxPathSegmentLength {
+			return fmt.Errorf("path segment exceeds %d characters", maxPathSegmentLength)
+		}
+		if strings.Trim(segment, ".") == "" {
+			return fmt.Errorf("path segment %q is not allowed", segment)
+		}
+		for _, c := range segment {
+			if !isPathChar(c) {
+				return fmt.Errorf("path segment contains invalid character %q", c)
+			}
+		}
+	}
+	return nil
+}
+
+func isPathChar(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+		c == '-' || c == '_' || c == '.'
+}
+
+// validYear reports whether s is a four-digit year.
+func validYear(s string) bool {
+	if len(s) != 4 {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// yearDataBlobs are the blob names of the per-year chart data types.
+var yearDataBlobs = map[string]string{
+	"summary":   "summary_activities.json",
+	"distances": "distances.json",
+}
+
+// yearDataBlob returns the blob of dataType's chart data for year under
+// prefix, or false for a data type without one.
+func yearDataBlob(prefix, year, dataType string) (string, bool) {
+	name, ok := yearDataBlobs[dataType]
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%sactivities/%s/%s", prefix, year, name), true
+}
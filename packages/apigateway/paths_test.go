@@ -0,0 +1,56 @@
+package apigateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidatePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"root", "/", false},
+		{"activities route", "/activities/2024/distances", false},
+		{"dotted segment", "/activities/2024/summary.v2", false},
+		{"empty segment", "/activities//distances", true},
+		{"parent directory", "/activities/../secrets", true},
+		{"invalid character", "/activities/2024/<script>", true},
+		{"long segment", "/activities/" + strings.Repeat("a", maxPathSegmentLength+1), true},
+		{"long path", "/" + strings.Repeat("a/", maxPathLength), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validatePath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandlerRejectsInvalidPaths(t *testing.T) {
+	handler := NewHandlerWithStorage(&mockStorageClient{})
+
+	for _, path := range []string{
+		"/activities/2024/" + strings.Repeat("x", 100),
+		"/activities/20x4/summary",
+		"/activities/99999/summary",
+		"/activities/2024/dist%20ances",
+	} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package apigateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/storage"
+)
+
+// prefetchDataFiles are the blobs warmed for the previous year; comparison
+// charts request both.
+var prefetchDataFiles = []string{"summary_activities.json", "distances.json"}
+
+// prefetcher warms the storage cache for the year before the current one
+// whenever the current year is requested. At most one prefetch per athlete
+// and year runs at a time.
+type prefetcher struct {
+	storage            storage.Client
+	inFlight           map[string]bool
+	now                func() time.Time
+	timeout            time.Duration
+	mu                 sync.Mutex
+	servePrecompressed bool
+	// wg lets tests wait for background reads to finish
+	wg sync.WaitGroup
+}
+
+func newPrefetcher(client storage.Client, timeout time.Duration, servePrecompressed bool) *prefetcher {
+	return &prefetcher{
+		storage:            client,
+		inFlight:           make(map[string]bool),
+		now:                time.Now,
+		timeout:            timeout,
+		servePrecompressed: servePrecompressed,
+	}
+}
+
+// requested notes that year was requested for the athlete under prefix
+// ("" for the bucket root) and, if it is the current year, starts a
+// background read of the athlete's previous year's blobs.
+func (p *prefetcher) requested(prefix, year string) {
+	if year != strconv.Itoa(p.now().Year()) {
+		return
+	}
+	previous := strconv.Itoa(p.now().Year() - 1)
+	key := prefix + previous
+
+	p.mu.Lock()
+	if p.inFlight[key] {
+		p.mu.Unlock()
+		return
+	}
+	p.inFlight[key] = true
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer func() {
+			p.mu.Lock()
+			delete(p.inFlight, key)
+			p.mu.Unlock()
+		}()
+		p.prefetch(prefix, previous)
+	}()
+}
+
+// prefetch reads each of year's blobs the way handleActivities would, so the
+// results land in the same cache entries. Failures are only logged; the
+// real request will surface them.
+func (p *prefetcher) prefetch(prefix, year string) {
+	// Detached from the triggering request, which may finish first
+	ctx := context.Background()
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	for _, file := range prefetchDataFiles {
+		blobPath := fmt.Sprintf("%sactivities/%s/%s", prefix, year, file)
+
+		if p.servePrecompressed {
+			_, err := p.storage.ReadBlob(ctx, blobPath+".gz")
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, storage.ErrNotFound) {
+				log.Printf("Prefetch of %s.gz failed: %v", blobPath, err)
+				continue
+			}
+		}
+
+		if _, err := p.storage.ReadJSON(ctx, blobPath); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Prefetch of %s failed: %v", blobPath, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package apigateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/storage"
+)
+
+func TestHandlerPrefetchPreviousYear(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		reads []string
+	)
+	mock := &mockStorageClient{
+		ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+			mu.Lock()
+			reads = append(reads, blobPath)
+			mu.Unlock()
+			return map[string]interface{}{}, nil
+		},
+	}
+
+	handler := NewHandlerWithStorage(mock)
+	handler.prefetcher = newPrefetcher(mock, time.Second, false)
+	handler.prefetcher.now = func() time.Time {
+		return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	request := func(path string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		handler.prefetcher.wg.Wait()
+	}
+
+	t.Run("current year prefetches previous year", func(t *testing.T) {
+		reads = nil
+		request("/activities/2025/distances")
+
+		mu.Lock()
+		defer mu.Unlock()
+		sort.Strings(reads)
+		want := []string{
+			"activities/2024/distances.json",
+			"activities/2024/summary_activities.json",
+			"activities/2025/distances.json",
+		}
+		if len(reads) != len(want) {
+			t.Fatalf("expected reads %v, got %v", want, reads)
+		}
+		for i := range want {
+			if reads[i] != want[i] {
+				t.Errorf("expected reads %v, got %v", want, reads)
+				break
+			}
+		}
+	})
+
+	t.Run("past years do not prefetch", func(t *testing.T) {
+		reads = nil
+		request("/activities/2023/summary")
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(reads) != 1 {
+			t.Errorf("expected only the requested read, got %v", reads)
+		}
+	})
+}
+
+func TestPrefetcherPrecompressed(t *testing.T) {
+	var jsonReads int
+	mock := &mockStorageClient{
+		ReadBlobFunc: func(ctx context.Context, blobPath string) (*storage.Blob, error) {
+			if blobPath == "activities/2024/distances.json.gz" {
+				return &storage.Blob{Data: []byte("{}")}, nil
+			}
+			return nil, storage.ErrNotFound
+		},
+		ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+			jsonReads++
+			return map[string]interface{}{}, nil
+		},
+	}
+
+	p := newPrefetcher(mock, time.Second, true)
+	p.prefetch("", "2024")
+
+	// distances came from the .gz sibling; only summary falls back to JSON
+	if jsonReads != 1 {
+		t.Errorf("expected 1 JSON read, got %d", jsonReads)
+	}
+}
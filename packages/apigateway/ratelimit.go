@@ -0,0 +1,118 @@
+package apigateway
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+)
+
+// DefaultRateLimitWindow is the window RATE_LIMIT is counted over.
+const DefaultRateLimitWindow = time.Minute
+
+// maxRateLimitClients bounds the per-client table; expired windows are
+// swept when it fills so a flood of distinct IPs can't grow it forever.
+const maxRateLimitClients = 10000
+
+// rateLimiter is a fixed-window request counter keyed by client IP. Fixed
+// windows keep the X-RateLimit-Reset header exact and cheap to compute.
+type rateLimiter struct {
+	limit   int
+	window  time.Duration
+	clients map[string]*rateWindow
+	mu      sync.Mutex
+	now     func() time.Time
+}
+
+type rateWindow struct {
+	reset time.Time
+	count int
+}
+
+// rateDecision is the outcome of counting one request.
+type rateDecision struct {
+	resetIn   time.Duration
+	limit     int
+	remaining int
+	allowed   bool
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:   limit,
+		window:  window,
+		clients: make(map[string]*rateWindow),
+		now:     time.Now,
+	}
+}
+
+// allow counts a request from key and reports whether it is within the limit.
+func (l *rateLimiter) allow(key string) rateDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	w, ok := l.clients[key]
+	if !ok || !now.Before(w.reset) {
+		if !ok && len(l.clients) >= maxRateLimitClients {
+			l.sweep(now)
+		}
+		w = &rateWindow{reset: now.Add(l.window)}
+		l.clients[key] = w
+	}
+
+	decision := rateDecision{limit: l.limit, resetIn: w.reset.Sub(now)}
+	if w.count >= l.limit {
+		return decision
+	}
+
+	w.count++
+	decision.allowed = true
+	decision.remaining = l.limit - w.count
+	return decision
+}
+
+// sweep drops expired windows, or every window if none have expired.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, w := range l.clients {
+		if !now.Before(w.reset) {
+			delete(l.clients, key)
+		}
+	}
+	if len(l.clients) >= maxRateLimitClients {
+		l.clients = make(map[string]*rateWindow)
+	}
+}
+
+// checkRateLimit sets X-RateLimit-* headers and writes a 429 when the caller
+// is over the limit. It returns false when the request must not proceed.
+// Probes are exempt so that throttling can't make the service look unhealthy.
+func (h *Handler) checkRateLimit(w http.ResponseWriter, r *http.Request) bool {
+	if h.rateLimiter == nil || r.URL.Path == "/live" || r.URL.Path == "/ready" {
+		return true
+	}
+
+	decision := h.rateLimiter.allow(h.clientIP(r))
+	resetSeconds := int(math.Ceil(decision.resetIn.Seconds()))
+
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(decision.limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(decision.remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(resetSeconds))
+
+	if decision.allowed {
+		return true
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(resetSeconds))
+	h.respondErrorResponse(w, r, http.StatusTooManyRequests, types.ErrorResponse{
+		Code:              types.ErrCodeRateLimited,
+		Message:           "Rate limit exceeded",
+		Details:           fmt.Sprintf("limit is %d requests per %s; retry after %ds", decision.limit, h.rateLimiter.window, resetSeconds),
+		RetryAfterSeconds: resetSeconds,
+	})
+	return false
+}
@@ -0,0 +1,89 @@
+package apigateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	for i, wantRemaining := range []int{1, 0} {
+		decision := limiter.allow("1.2.3.4")
+		if !decision.allowed || decision.remaining != wantRemaining {
+			t.Fatalf("request %d: expected allowed with %d remaining, got %+v", i, wantRemaining, decision)
+		}
+	}
+
+	if decision := limiter.allow("1.2.3.4"); decision.allowed {
+		t.Error("expected third request in window to be rejected")
+	}
+	if decision := limiter.allow("5.6.7.8"); !decision.allowed {
+		t.Error("expected other clients to have their own window")
+	}
+
+	now = now.Add(time.Minute)
+	if decision := limiter.allow("1.2.3.4"); !decision.allowed || decision.remaining != 1 {
+		t.Errorf("expected new window after reset, got %+v", decision)
+	}
+}
+
+func TestHandlerRateLimit(t *testing.T) {
+	handler := NewHandlerWithStorage(&mockStorageClient{})
+	handler.rateLimiter = newRateLimiter(1, time.Minute)
+
+	newRequest := func(path string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "203.0.113.7:5000"
+		return req
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest("/health"))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("X-RateLimit-Limit"); got != "1" {
+		t.Errorf("expected X-RateLimit-Limit 1, got %q", got)
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("expected X-RateLimit-Remaining 0, got %q", got)
+	}
+	if got := w.Header().Get("X-RateLimit-Reset"); got != "60" {
+		t.Errorf("expected X-RateLimit-Reset 60, got %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest("/health"))
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+	var response types.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Code != types.ErrCodeRateLimited || response.RetryAfterSeconds <= 0 {
+		t.Errorf("expected rate_limited with retry hint, got %+v", response)
+	}
+
+	t.Run("probes are exempt", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newRequest("/live"))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,173 @@
+// Package schema validates the shape of stored chart data blobs before they
+// are served, so malformed pipeline output is caught at the gateway instead
+// of breaking the web UI.
+package schema
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Validator checks a parsed JSON blob and returns a descriptive error if it
+// does not match the expected shape.
+type Validator func(data interface{}) error
+
+var (
+	registry   = map[string]Validator{}
+	registryMu sync.RWMutex
+)
+
+func init() {
+	Register("summary", validateSummary)
+	Register("distances", validateDistances)
+	Register("streams", validateStreams)
+}
+
+// Register associates a validator with an API data type, replacing any
+// validator previously registered for it.
+func Register(dataType string, validator Validator) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[dataType] = validator
+}
+
+// Validate runs the validator registered for dataType. Data types without a
+// registered validator are accepted.
+func Validate(dataType string, data interface{}) error {
+	registryMu.RLock()
+	validator, ok := registry[dataType]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil
+	}
+	return validator(data)
+}
+
+// validateSummary checks summary_activities.json:
+//
+//	{"2024-01-02": {"distance_miles": 1.14, "activity_ids": [10481812565]}}
+func validateSummary(data interface{}) error {
+	days, ok := data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("expected object keyed by date, got %s", typeName(data))
+	}
+
+	for date, value := range days {
+		if !isDate(date) {
+			return fmt.Errorf("invalid date key %q", date)
+		}
+		day, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %s", date, typeName(value))
+		}
+		if _, ok := day["distance_miles"].(float64); !ok {
+			return fmt.Errorf("%s.distance_miles: expected number, got %s", date, typeName(day["distance_miles"]))
+		}
+		ids, ok := day["activity_ids"].([]interface{})
+		if !ok {
+			return fmt.Errorf("%s.activity_ids: expected array, got %s", date, typeName(day["activity_ids"]))
+		}
+		for i, id := range ids {
+			if _, ok := id.(float64); !ok {
+				return fmt.Errorf("%s.activity_ids[%d]: expected number, got %s", date, i, typeName(id))
+			}
+		}
+	}
+	return nil
+}
+
+// distanceSeries are the date/value series in distances.json; only
+// distance_traveled is required.
+var distanceSeries = []string{"distance_traveled", "avg_distance", "upper_distance", "lower_distance"}
+
+// validateDistances checks distances.json:
+//
+//	{"distance_traveled": [{"x": "2025-01-01", "y": 0.0}], "avg_distance": [...], ...}
+func validateDistances(data interface{}) error {
+	obj, ok := data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("expected object, got %s", typeName(data))
+	}
+	if _, ok := obj["distance_traveled"]; !ok {
+		return fmt.Errorf("distance_traveled: required field missing")
+	}
+
+	for _, name := range distanceSeries {
+		value, present := obj[name]
+		if !present {
+			continue
+		}
+		points, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %s", name, typeName(value))
+		}
+		for i, p := range points {
+			point, ok := p.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s[%d]: expected object, got %s", name, i, typeName(p))
+			}
+			if x, ok := point["x"].(string); !ok || !isDate(x) {
+				return fmt.Errorf("%s[%d].x: expected YYYY-MM-DD date, got %v", name, i, point["x"])
+			}
+			if _, ok := point["y"].(float64); !ok {
+				return fmt.Errorf("%s[%d].y: expected number, got %s", name, i, typeName(point["y"]))
+			}
+		}
+	}
+
+	if summaries, present := obj["summaries"]; present {
+		if _, ok := summaries.(map[string]interface{}); !ok {
+			return fmt.Errorf("summaries: expected object, got %s", typeName(summaries))
+		}
+	}
+	return nil
+}
+
+// validateStreams checks an activity's streams/{activity_id}.json, keyed by
+// stream type:
+//
+//	{"time": {"data": [0, 1, 2], "series_type": "time"}, "watts": {"data": [...]}}
+func validateStreams(data interface{}) error {
+	streams, ok := data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("expected object keyed by stream type, got %s", typeName(data))
+	}
+	for name, value := range streams {
+		stream, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %s", name, typeName(value))
+		}
+		if _, ok := stream["data"].([]interface{}); !ok {
+			return fmt.Errorf("%s.data: expected array, got %s", name, typeName(stream["data"]))
+		}
+	}
+	return nil
+}
+
+// isDate reports whether s is a YYYY-MM-DD date.
+func isDate(s string) bool {
+	_, err := time.Parse(time.DateOnly, s)
+	return err == nil
+}
+
+// typeName returns the JSON type name of a decoded value for error messages.
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,119 @@
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, raw string) interface{} {
+	var data interface{}
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		t.Fatalf("failed to decode test JSON: %v", err)
+	}
+	return data
+}
+
+func TestValidateSummary(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr string
+	}{
+		{"valid", `{"2024-01-02": {"distance_miles": 1.1, "activity_ids": [1, 2]}}`, ""},
+		{"empty", `{}`, ""},
+		{"not an object", `[]`, "expected object keyed by date"},
+		{"bad date key", `{"yesterday": {"distance_miles": 1, "activity_ids": []}}`, "invalid date key"},
+		{"missing distance", `{"2024-01-02": {"activity_ids": []}}`, "distance_miles"},
+		{"string activity id", `{"2024-01-02": {"distance_miles": 1, "activity_ids": ["x"]}}`, "activity_ids[0]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate("summary", decode(t, tt.raw))
+			checkErr(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestValidateDistances(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr string
+	}{
+		{"valid", `{"distance_traveled": [{"x": "2025-01-01", "y": 0}], "avg_distance": [], "summaries": {}}`, ""},
+		{"missing distance_traveled", `{"avg_distance": []}`, "required field missing"},
+		{"series not array", `{"distance_traveled": {}}`, "expected array"},
+		{"bad x", `{"distance_traveled": [{"x": 1, "y": 0}]}`, "distance_traveled[0].x"},
+		{"null y", `{"distance_traveled": [{"x": "2025-01-01", "y": null}]}`, "distance_traveled[0].y"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate("distances", decode(t, tt.raw))
+			checkErr(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestValidateStreams(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr string
+	}{
+		{"valid", `{"time": {"data": [0, 1], "series_type": "time"}, "latlng": {"data": [[51.5, -0.1], [51.6, -0.2]]}}`, ""},
+		{"not an object", `[]`, "expected object keyed by stream type"},
+		{"stream not object", `{"time": [0, 1]}`, "time: expected object"},
+		{"missing data", `{"watts": {"series_type": "time"}}`, "watts.data"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate("streams", decode(t, tt.raw))
+			checkErr(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestValidateUnregisteredType(t *testing.T) {
+	if err := Validate("unknown", "anything"); err != nil {
+		t.Errorf("expected unregistered type to pass, got %v", err)
+	}
+}
+
+func TestValidateFixtures(t *testing.T) {
+	files := map[string]string{
+		"summary_activities.json": "summary",
+		"distances.json":          "distances",
+	}
+
+	for _, year := range []string{"2023", "2024", "2025"} {
+		for file, dataType := range files {
+			path := filepath.Join("..", "..", "..", "data", "fixtures", "activities", year, file)
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read fixture %s: %v", path, err)
+			}
+			if err := Validate(dataType, decode(t, string(raw))); err != nil {
+				t.Errorf("fixture %s failed validation: %v", path, err)
+			}
+		}
+	}
+}
+
+func checkErr(t *testing.T, err error, want string) {
+	t.Helper()
+	if want == "" {
+		if err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("expected error containing %q, got %v", want, err)
+	}
+}
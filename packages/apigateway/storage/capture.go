@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+)
+
+// CaptureClient wraps a Client and writes every blob it successfully reads
+// into a local directory using the same layout LocalStorageClient reads, so
+// real data can be snapshotted into a fixtures set just by browsing the UI.
+// Capture failures are logged and never fail the read.
+type CaptureClient struct {
+	client  Client
+	dirPath string
+}
+
+// NewCaptureClient creates a client that tees reads from client into dirPath.
+func NewCaptureClient(client Client, dirPath string) *CaptureClient {
+	return &CaptureClient{
+		client:  client,
+		dirPath: dirPath,
+	}
+}
+
+// ReadJSON delegates to the wrapped client and captures the parsed result.
+func (c *CaptureClient) ReadJSON(ctx context.Context, blobPath string) (interface{}, error) {
+	data, err := c.client.ReadJSON(ctx, blobPath)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, marshalErr := json.Marshal(data)
+	if marshalErr != nil {
+		log.Printf("Capture: failed to encode %s: %v", blobPath, marshalErr)
+		return data, nil
+	}
+	c.capture(blobPath, raw)
+	return data, nil
+}
+
+// ReadBlob delegates to the wrapped client and captures the raw bytes.
+// Compressed blobs are stored compressed; LocalStorageClient detects gzip
+// by its magic bytes when serving them back.
+func (c *CaptureClient) ReadBlob(ctx context.Context, blobPath string) (*Blob, error) {
+	blob, err := c.client.ReadBlob(ctx, blobPath)
+	if err != nil {
+		return nil, err
+	}
+
+	c.capture(blobPath, blob.Data)
+	return blob, nil
+}
+
+// Stat delegates to the wrapped client.
+func (c *CaptureClient) Stat(ctx context.Context, blobPath string) (*ObjectInfo, error) {
+	return c.client.Stat(ctx, blobPath)
+}
+
+// Ping delegates to the wrapped client.
+func (c *CaptureClient) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx)
+}
+
+// capture writes data to dirPath/blobPath via a temp file and rename, so a
+// concurrent LocalStorageClient never sees a partial file.
+func (c *CaptureClient) capture(blobPath string, data []byte) {
+	if err := c.write(blobPath, data); err != nil {
+		log.Printf("Capture: failed to write %s: %v", blobPath, err)
+	}
+}
+
+func (c *CaptureClient) write(blobPath string, data []byte) error {
+	if !filepath.IsLocal(blobPath) {
+		return fmt.Errorf("refusing to capture non-local path")
+	}
+	return writeFileAtomic(filepath.Join(c.dirPath, blobPath), data, ".capture-*")
+}
+
+// WriteBlob forwards to the wrapped client.
+func (c *CaptureClient) WriteBlob(ctx context.Context, blobPath string, data []byte) error {
+	return writeThrough(ctx, c.client, blobPath, data)
+}
+
+// DeleteBlob forwards to the wrapped client.
+func (c *CaptureClient) DeleteBlob(ctx context.Context, blobPath string) error {
+	return deleteThrough(ctx, c.client, blobPath)
+}
+
+// ReadGeneration forwards to the wrapped client without capturing, since
+// it is only used by conditional updates.
+func (c *CaptureClient) ReadGeneration(ctx context.Context, blobPath string) ([]byte, int64, error) {
+	return readGenerationThrough(ctx, c.client, blobPath)
+}
+
+// WriteBlobIf forwards to the wrapped client.
+func (c *CaptureClient) WriteBlobIf(ctx context.Context, blobPath string, data []byte, generation int64) error {
+	return writeIfThrough(ctx, c.client, blobPath, data, generation)
+}
+
+// Invalidate forwards to the wrapped client if it caches.
+func (c *CaptureClient) Invalidate(blobPath string) {
+	if inv, ok := c.client.(Invalidator); ok {
+		inv.Invalidate(blobPath)
+	}
+}
+
+// InvalidatePrefix forwards to the wrapped client if it caches.
+func (c *CaptureClient) InvalidatePrefix(prefix string) int {
+	if inv, ok := c.client.(PrefixInvalidator); ok {
+		return inv.InvalidatePrefix(prefix)
+	}
+	return 0
+}
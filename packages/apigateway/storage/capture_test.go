@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCaptureClient(t *testing.T) {
+	ctx := context.Background()
+
+	mock := &MockStorageClient{
+		ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+			if blobPath == "activities/2024/summary_activities.json" {
+				return map[string]interface{}{"2024-01-01": map[string]interface{}{"distance_miles": 1.5}}, nil
+			}
+			return nil, ErrNotFound
+		},
+		ReadBlobFunc: func(ctx context.Context, blobPath string) (*Blob, error) {
+			if blobPath == "activities/2024/distances.json.gz" {
+				return &Blob{Data: []byte{0x1f, 0x8b, 0x01}, ContentEncoding: "gzip"}, nil
+			}
+			return nil, ErrNotFound
+		},
+	}
+
+	t.Run("captured JSON is readable by LocalStorageClient", func(t *testing.T) {
+		dir := t.TempDir()
+		client := NewCaptureClient(mock, dir)
+
+		want, err := client.ReadJSON(ctx, "activities/2024/summary_activities.json")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		local, err := NewLocalStorageClient(dir)
+		if err != nil {
+			t.Fatalf("failed to create local client: %v", err)
+		}
+		got, err := local.ReadJSON(ctx, "activities/2024/summary_activities.json")
+		if err != nil {
+			t.Fatalf("captured file not readable: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected captured %v, got %v", want, got)
+		}
+	})
+
+	t.Run("raw blobs are captured byte for byte", func(t *testing.T) {
+		dir := t.TempDir()
+		client := NewCaptureClient(mock, dir)
+
+		if _, err := client.ReadBlob(ctx, "activities/2024/distances.json.gz"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got, err := os.ReadFile(filepath.Join(dir, "activities/2024/distances.json.gz"))
+		if err != nil {
+			t.Fatalf("captured blob missing: %v", err)
+		}
+		if !bytes.Equal(got, []byte{0x1f, 0x8b, 0x01}) {
+			t.Errorf("unexpected captured bytes %v", got)
+		}
+	})
+
+	t.Run("errors are not captured", func(t *testing.T) {
+		dir := t.TempDir()
+		client := NewCaptureClient(mock, dir)
+
+		if _, err := client.ReadJSON(ctx, "activities/2030/distances.json"); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("failed to read dir: %v", err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("expected nothing captured, found %d entries", len(entries))
+		}
+	})
+
+	t.Run("non-local paths are refused", func(t *testing.T) {
+		dir := t.TempDir()
+		client := NewCaptureClient(mock, filepath.Join(dir, "fixtures"))
+
+		if err := client.write("../escape.json", []byte("{}")); err == nil {
+			t.Error("expected error for path outside capture directory")
+		}
+		if _, err := os.Stat(filepath.Join(dir, "escape.json")); !os.IsNotExist(err) {
+			t.Error("expected no file written outside capture directory")
+		}
+	})
+}
@@ -7,18 +7,107 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+
+	"github.com/andy-esch/desirelines/packages/config"
 )
 
 // ErrNotFound is returned when a blob is not found.
 var ErrNotFound = errors.New("blob not found")
 
+// ErrReadOnly is returned by writes to a storage chain that can't write.
+var ErrReadOnly = errors.New("storage is read-only")
+
+// ErrConflict is returned by a conditional write when the blob has changed
+// since it was read.
+var ErrConflict = errors.New("blob changed concurrently")
+
+// Blob holds the raw stored bytes of an object. ContentEncoding is "gzip" when
+// Data is gzip-compressed and empty otherwise.
+type Blob struct {
+	ContentEncoding string
+	Data            []byte
+}
+
+// ObjectInfo holds metadata about a stored blob.
+type ObjectInfo struct {
+	Updated time.Time
+}
+
 // Client defines the interface for storage operations.
 type Client interface {
 	ReadJSON(ctx context.Context, blobPath string) (interface{}, error)
+	ReadBlob(ctx context.Context, blobPath string) (*Blob, error)
+	Stat(ctx context.Context, blobPath string) (*ObjectInfo, error)
+	Ping(ctx context.Context) error
+}
+
+// Writer is implemented by clients that can store blobs, such as the
+// athlete-editable goals. Caching clients forward writes and deletes and
+// drop their cached state for the blob.
+type Writer interface {
+	WriteBlob(ctx context.Context, blobPath string, data []byte) error
+	// DeleteBlob removes blobPath; a blob that doesn't exist isn't an error.
+	DeleteBlob(ctx context.Context, blobPath string) error
+}
+
+// ConditionalWriter is a Writer that can also read a blob past any cache
+// along with its generation, and replace it only if that generation is
+// unchanged, for read-modify-write updates of blobs others also write.
+type ConditionalWriter interface {
+	Writer
+	// ReadGeneration returns the blob's current data and generation, or
+	// ErrNotFound.
+	ReadGeneration(ctx context.Context, blobPath string) ([]byte, int64, error)
+	// WriteBlobIf replaces the blob if its generation is still generation,
+	// where 0 means it must not exist, and returns ErrConflict otherwise.
+	WriteBlobIf(ctx context.Context, blobPath string, data []byte, generation int64) error
+}
+
+// writeThrough writes to client, or returns ErrReadOnly if it can't write.
+func writeThrough(ctx context.Context, client Client, blobPath string, data []byte) error {
+	writer, ok := client.(Writer)
+	if !ok {
+		return ErrReadOnly
+	}
+	return writer.WriteBlob(ctx, blobPath, data)
+}
+
+// deleteThrough deletes from client, or returns ErrReadOnly if it can't
+// write.
+func deleteThrough(ctx context.Context, client Client, blobPath string) error {
+	writer, ok := client.(Writer)
+	if !ok {
+		return ErrReadOnly
+	}
+	return writer.DeleteBlob(ctx, blobPath)
+}
+
+// readGenerationThrough reads from client with its generation, or returns
+// ErrReadOnly if it can't write conditionally.
+func readGenerationThrough(ctx context.Context, client Client, blobPath string) ([]byte, int64, error) {
+	writer, ok := client.(ConditionalWriter)
+	if !ok {
+		return nil, 0, ErrReadOnly
+	}
+	return writer.ReadGeneration(ctx, blobPath)
+}
+
+// writeIfThrough writes to client conditionally, or returns ErrReadOnly if
+// it can't.
+func writeIfThrough(ctx context.Context, client Client, blobPath string, data []byte, generation int64) error {
+	writer, ok := client.(ConditionalWriter)
+	if !ok {
+		return ErrReadOnly
+	}
+	return writer.WriteBlobIf(ctx, blobPath, data, generation)
 }
 
 // CloudStorageClient implements Client using Google Cloud Storage.
@@ -29,11 +118,16 @@ type CloudStorageClient struct {
 
 // NewCloudStorageClient creates a new Cloud Storage client.
 func NewCloudStorageClient(ctx context.Context) (*CloudStorageClient, error) {
-	bucketName := os.Getenv("GCP_BUCKET_NAME")
+	bucketName := config.Get("GCP_BUCKET_NAME")
 	if bucketName == "" {
 		return nil, fmt.Errorf("GCP_BUCKET_NAME environment variable not set")
 	}
+	return NewCloudStorageBucketClient(ctx, bucketName)
+}
 
+// NewCloudStorageBucketClient creates a Cloud Storage client for bucketName,
+// e.g. for a bucket other than GCP_BUCKET_NAME.
+func NewCloudStorageBucketClient(ctx context.Context, bucketName string) (*CloudStorageClient, error) {
 	client, err := storage.NewClient(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create storage client: %w", err)
@@ -76,9 +170,125 @@ func (c *CloudStorageClient) ReadJSON(ctx context.Context, blobPath string) (int
 	return result, nil
 }
 
+// ReadBlob reads an object's stored bytes without decompressive transcoding,
+// so gzip-encoded objects are returned still compressed.
+func (c *CloudStorageClient) ReadBlob(ctx context.Context, blobPath string) (*Blob, error) {
+	obj := c.client.Bucket(c.bucketName).Object(blobPath).ReadCompressed(true)
+
+	reader, err := obj.NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read object %s: %w", blobPath, err)
+	}
+	defer func() {
+		if closeErr := reader.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close reader: %w", closeErr)
+		}
+	}()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob contents: %w", err)
+	}
+
+	return newBlob(data, reader.Attrs.ContentEncoding), nil
+}
+
+// Stat returns metadata for a blob in Cloud Storage.
+func (c *CloudStorageClient) Stat(ctx context.Context, blobPath string) (*ObjectInfo, error) {
+	attrs, err := c.client.Bucket(c.bucketName).Object(blobPath).Attrs(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get attributes for %s: %w", blobPath, err)
+	}
+
+	return &ObjectInfo{Updated: attrs.Updated}, nil
+}
+
+// WriteBlob stores data as a JSON object in Cloud Storage.
+func (c *CloudStorageClient) WriteBlob(ctx context.Context, blobPath string, data []byte) error {
+	w := c.client.Bucket(c.bucketName).Object(blobPath).NewWriter(ctx)
+	w.ContentType = "application/json"
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return fmt.Errorf("failed to write object %s: %w", blobPath, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write object %s: %w", blobPath, err)
+	}
+	return nil
+}
+
+// DeleteBlob removes an object from Cloud Storage.
+func (c *CloudStorageClient) DeleteBlob(ctx context.Context, blobPath string) error {
+	err := c.client.Bucket(c.bucketName).Object(blobPath).Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("failed to delete object %s: %w", blobPath, err)
+	}
+	return nil
+}
+
+// ReadGeneration reads an object, decompressed, with its generation.
+func (c *CloudStorageClient) ReadGeneration(ctx context.Context, blobPath string) ([]byte, int64, error) {
+	reader, err := c.client.Bucket(c.bucketName).Object(blobPath).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, 0, ErrNotFound
+		}
+		return nil, 0, fmt.Errorf("failed to read object %s: %w", blobPath, err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read blob contents: %w", err)
+	}
+	return data, reader.Attrs.Generation, nil
+}
+
+// WriteBlobIf stores data as a JSON object in Cloud Storage if the object's
+// generation still matches.
+func (c *CloudStorageClient) WriteBlobIf(ctx context.Context, blobPath string, data []byte, generation int64) error {
+	obj := c.client.Bucket(c.bucketName).Object(blobPath)
+	if generation == 0 {
+		obj = obj.If(storage.Conditions{DoesNotExist: true})
+	} else {
+		obj = obj.If(storage.Conditions{GenerationMatch: generation})
+	}
+
+	w := obj.NewWriter(ctx)
+	w.ContentType = "application/json"
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return fmt.Errorf("failed to write object %s: %w", blobPath, err)
+	}
+	if err := w.Close(); err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+			return fmt.Errorf("%s: %w", blobPath, ErrConflict)
+		}
+		return fmt.Errorf("failed to write object %s: %w", blobPath, err)
+	}
+	return nil
+}
+
+// Ping verifies the configured bucket is reachable by fetching its metadata.
+func (c *CloudStorageClient) Ping(ctx context.Context) error {
+	if _, err := c.client.Bucket(c.bucketName).Attrs(ctx); err != nil {
+		return fmt.Errorf("failed to get attributes for bucket %s: %w", c.bucketName, err)
+	}
+	return nil
+}
+
 // LocalStorageClient implements Client using local filesystem.
 type LocalStorageClient struct {
 	basePath string
+	// mu makes WriteBlobIf's check and write atomic within the process
+	mu sync.Mutex
 }
 
 // NewLocalStorageClient creates a new local storage client.
@@ -99,6 +309,10 @@ func NewLocalStorageClient(basePath string) (*LocalStorageClient, error) {
 
 // ReadJSON reads a JSON file from local filesystem and returns parsed data.
 func (c *LocalStorageClient) ReadJSON(ctx context.Context, blobPath string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	filePath := filepath.Join(c.basePath, blobPath)
 
 	data, err := os.ReadFile(filePath)
@@ -116,3 +330,170 @@ func (c *LocalStorageClient) ReadJSON(ctx context.Context, blobPath string) (int
 
 	return result, nil
 }
+
+// ReadBlob reads a file's raw bytes from the local filesystem.
+func (c *LocalStorageClient) ReadBlob(ctx context.Context, blobPath string) (*Blob, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	filePath := filepath.Join(c.basePath, blobPath)
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	return newBlob(data, ""), nil
+}
+
+// Stat returns metadata for a file on the local filesystem.
+func (c *LocalStorageClient) Stat(ctx context.Context, blobPath string) (*ObjectInfo, error) {
+	filePath := filepath.Join(c.basePath, blobPath)
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to stat file %s: %w", filePath, err)
+	}
+
+	return &ObjectInfo{Updated: info.ModTime()}, nil
+}
+
+// WriteBlob stores data under the base path via a temp file and rename, so
+// concurrent reads never see a partial file.
+func (c *LocalStorageClient) WriteBlob(ctx context.Context, blobPath string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !filepath.IsLocal(blobPath) {
+		return fmt.Errorf("refusing to write non-local path %s", blobPath)
+	}
+	if err := writeFileAtomic(filepath.Join(c.basePath, blobPath), data, ".write-*"); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", blobPath, err)
+	}
+	return nil
+}
+
+// DeleteBlob removes a file under the base path.
+func (c *LocalStorageClient) DeleteBlob(ctx context.Context, blobPath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !filepath.IsLocal(blobPath) {
+		return fmt.Errorf("refusing to delete non-local path %s", blobPath)
+	}
+	if err := os.Remove(filepath.Join(c.basePath, blobPath)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete file %s: %w", blobPath, err)
+	}
+	return nil
+}
+
+// ReadGeneration reads a file's raw bytes, using its modification time as
+// the generation.
+func (c *LocalStorageClient) ReadGeneration(ctx context.Context, blobPath string) ([]byte, int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.readGeneration(blobPath)
+}
+
+// WriteBlobIf writes data like WriteBlob if the file's modification time
+// still matches generation. Only writes through this client are excluded
+// while it checks, which is enough for local development.
+func (c *LocalStorageClient) WriteBlobIf(ctx context.Context, blobPath string, data []byte, generation int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !filepath.IsLocal(blobPath) {
+		return fmt.Errorf("refusing to write non-local path %s", blobPath)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, current, err := c.readGeneration(blobPath)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if current != generation {
+		return fmt.Errorf("%s: %w", blobPath, ErrConflict)
+	}
+	if err := writeFileAtomic(filepath.Join(c.basePath, blobPath), data, ".write-*"); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", blobPath, err)
+	}
+	return nil
+}
+
+func (c *LocalStorageClient) readGeneration(blobPath string) ([]byte, int64, error) {
+	filePath := filepath.Join(c.basePath, blobPath)
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, ErrNotFound
+		}
+		return nil, 0, fmt.Errorf("failed to stat file %s: %w", filePath, err)
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+	return data, info.ModTime().UnixNano(), nil
+}
+
+// Ping verifies the base path is still present and is a directory.
+func (c *LocalStorageClient) Ping(ctx context.Context) error {
+	info, err := os.Stat(c.basePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat base path %s: %w", c.basePath, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("local storage base path is not a directory: %s", c.basePath)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to target via a temp file named by pattern
+// and a rename, so a concurrent LocalStorageClient never sees a partial
+// file.
+func writeFileAtomic(target string, data []byte, pattern string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), pattern)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// No-op once the rename has succeeded
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// newBlob builds a Blob, detecting gzip from the declared content encoding or
+// the gzip magic number so objects uploaded without metadata are handled too.
+func newBlob(data []byte, contentEncoding string) *Blob {
+	blob := &Blob{Data: data}
+	if contentEncoding == "gzip" || (len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b) {
+		blob.ContentEncoding = "gzip"
+	}
+	return blob
+}
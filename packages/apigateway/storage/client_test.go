@@ -2,12 +2,18 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
 // MockStorageClient is a mock implementation of the Client interface for testing.
 type MockStorageClient struct {
 	ReadJSONFunc func(ctx context.Context, blobPath string) (interface{}, error)
+	ReadBlobFunc func(ctx context.Context, blobPath string) (*Blob, error)
+	StatFunc     func(ctx context.Context, blobPath string) (*ObjectInfo, error)
+	PingFunc     func(ctx context.Context) error
 }
 
 func (m *MockStorageClient) ReadJSON(ctx context.Context, blobPath string) (interface{}, error) {
@@ -17,6 +23,27 @@ func (m *MockStorageClient) ReadJSON(ctx context.Context, blobPath string) (inte
 	return nil, ErrNotFound
 }
 
+func (m *MockStorageClient) ReadBlob(ctx context.Context, blobPath string) (*Blob, error) {
+	if m.ReadBlobFunc != nil {
+		return m.ReadBlobFunc(ctx, blobPath)
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MockStorageClient) Stat(ctx context.Context, blobPath string) (*ObjectInfo, error) {
+	if m.StatFunc != nil {
+		return m.StatFunc(ctx, blobPath)
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MockStorageClient) Ping(ctx context.Context) error {
+	if m.PingFunc != nil {
+		return m.PingFunc(ctx)
+	}
+	return nil
+}
+
 func TestMockStorageClient(t *testing.T) {
 	ctx := context.Background()
 
@@ -52,3 +79,142 @@ func TestMockStorageClient(t *testing.T) {
 		}
 	})
 }
+
+func TestLocalStorageClientStat(t *testing.T) {
+	ctx := context.Background()
+	basePath := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(basePath, "activities", "2024"), 0755); err != nil {
+		t.Fatalf("failed to create fixture dir: %v", err)
+	}
+	blobPath := filepath.Join("activities", "2024", "summary_activities.json")
+	if err := os.WriteFile(filepath.Join(basePath, blobPath), []byte("{}"), 0644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	client, err := NewLocalStorageClient(basePath)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	t.Run("returns modification time", func(t *testing.T) {
+		info, err := client.Stat(ctx, blobPath)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if info.Updated.IsZero() {
+			t.Error("expected non-zero Updated time")
+		}
+	})
+
+	t.Run("returns ErrNotFound for missing file", func(t *testing.T) {
+		_, err := client.Stat(ctx, "activities/1999/summary_activities.json")
+		if err != ErrNotFound {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestLocalStorageClientPing(t *testing.T) {
+	ctx := context.Background()
+	basePath := t.TempDir()
+
+	client, err := NewLocalStorageClient(basePath)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := client.Ping(ctx); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if err := os.RemoveAll(basePath); err != nil {
+		t.Fatalf("failed to remove base path: %v", err)
+	}
+
+	if err := client.Ping(ctx); err == nil {
+		t.Error("expected error after base path removed, got nil")
+	}
+}
+
+func TestLocalStorageClientWriteBlob(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewLocalStorageClient(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := client.WriteBlob(ctx, "goals/1/2025.json", []byte(`{"goals":[]}`)); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	blob, err := client.ReadBlob(ctx, "goals/1/2025.json")
+	if err != nil {
+		t.Fatalf("expected written blob to be readable, got %v", err)
+	}
+	if string(blob.Data) != `{"goals":[]}` {
+		t.Errorf("expected written data, got %s", blob.Data)
+	}
+
+	if err := client.WriteBlob(ctx, "../escape.json", []byte("{}")); err == nil {
+		t.Error("expected non-local path to be rejected")
+	}
+	if err := writeThrough(ctx, &MockStorageClient{}, "goals/1/2025.json", nil); err != ErrReadOnly {
+		t.Errorf("expected ErrReadOnly from a client that cannot write, got %v", err)
+	}
+}
+
+func TestLocalStorageClientWriteBlobIf(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewLocalStorageClient(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, _, err := client.ReadGeneration(ctx, "activities/2025/distances.json"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := client.WriteBlobIf(ctx, "activities/2025/distances.json", []byte(`{"v":1}`), 0); err != nil {
+		t.Fatalf("expected a generation 0 write to create the blob, got %v", err)
+	}
+	if err := client.WriteBlobIf(ctx, "activities/2025/distances.json", []byte(`{"v":2}`), 0); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict once the blob exists, got %v", err)
+	}
+
+	data, generation, err := client.ReadGeneration(ctx, "activities/2025/distances.json")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if string(data) != `{"v":1}` {
+		t.Errorf("expected the first write's data, got %s", data)
+	}
+	if err := client.WriteBlobIf(ctx, "activities/2025/distances.json", []byte(`{"v":2}`), generation); err != nil {
+		t.Fatalf("expected a write at the current generation to succeed, got %v", err)
+	}
+	if err := client.WriteBlobIf(ctx, "activities/2025/distances.json", []byte(`{"v":3}`), generation); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict at a stale generation, got %v", err)
+	}
+}
+
+func TestLocalStorageClientDeleteBlob(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewLocalStorageClient(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if err := client.WriteBlob(ctx, "activities/2025/distances.json.gz", []byte("stale")); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := client.DeleteBlob(ctx, "activities/2025/distances.json.gz"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := client.ReadBlob(ctx, "activities/2025/distances.json.gz"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the blob to be gone, got %v", err)
+	}
+	if err := client.DeleteBlob(ctx, "activities/2025/distances.json.gz"); err != nil {
+		t.Errorf("expected deleting a missing blob to succeed, got %v", err)
+	}
+	if err := client.DeleteBlob(ctx, "../escape.json"); err == nil {
+		t.Error("expected non-local path to be rejected")
+	}
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFallbackClient(t *testing.T) {
+	ctx := context.Background()
+
+	primary := &MockStorageClient{
+		ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+			if blobPath == "activities/2024/distances.json" {
+				return "primary", nil
+			}
+			return nil, ErrNotFound
+		},
+	}
+	secondary := &MockStorageClient{
+		ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+			if blobPath == "activities/2023/distances.json" {
+				return "secondary", nil
+			}
+			return nil, ErrNotFound
+		},
+	}
+
+	client := NewFallbackClient(primary, secondary)
+
+	t.Run("prefers primary", func(t *testing.T) {
+		data, err := client.ReadJSON(ctx, "activities/2024/distances.json")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if data != "primary" {
+			t.Errorf("expected primary data, got %v", data)
+		}
+	})
+
+	t.Run("falls back on not found", func(t *testing.T) {
+		data, err := client.ReadJSON(ctx, "activities/2023/distances.json")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if data != "secondary" {
+			t.Errorf("expected secondary data, got %v", data)
+		}
+	})
+
+	t.Run("returns ErrNotFound when no client has the blob", func(t *testing.T) {
+		_, err := client.ReadJSON(ctx, "activities/1999/distances.json")
+		if err != ErrNotFound {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("does not fall back on other errors", func(t *testing.T) {
+		failing := &MockStorageClient{
+			ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+				return nil, errors.New("permission denied")
+			},
+		}
+		client := NewFallbackClient(failing, secondary)
+
+		_, err := client.ReadJSON(ctx, "activities/2023/distances.json")
+		if err == nil || err == ErrNotFound {
+			t.Errorf("expected primary error to be returned, got %v", err)
+		}
+	})
+
+	t.Run("ping reports failing clients", func(t *testing.T) {
+		failing := &MockStorageClient{
+			PingFunc: func(ctx context.Context) error {
+				return errors.New("bucket not found")
+			},
+		}
+		client := NewFallbackClient(failing, secondary)
+
+		if err := client.Ping(ctx); err == nil {
+			t.Error("expected error from failing primary, got nil")
+		}
+	})
+}
+
+func TestFallbackClientReadGeneration(t *testing.T) {
+	ctx := context.Background()
+	primary, err := NewLocalStorageClient(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixtures, err := NewLocalStorageClient(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fixtures.WriteBlob(ctx, "activities/2025/distances.json", []byte(`{"v":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	client := NewFallbackClient(primary, fixtures)
+
+	data, generation, err := client.ReadGeneration(ctx, "activities/2025/distances.json")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if string(data) != `{"v":1}` || generation != 0 {
+		t.Fatalf("expected the fixture at generation 0, got %s at %d", data, generation)
+	}
+	if err := client.WriteBlobIf(ctx, "activities/2025/distances.json", []byte(`{"v":2}`), generation); err != nil {
+		t.Fatalf("expected the write to create the blob in the primary, got %v", err)
+	}
+	if blob, err := primary.ReadBlob(ctx, "activities/2025/distances.json"); err != nil || string(blob.Data) != `{"v":2}` {
+		t.Errorf("expected the primary to hold the write, got %v, %v", blob, err)
+	}
+}
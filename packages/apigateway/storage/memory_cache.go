@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCacheEntries caps the number of cached blobs held in memory.
+const maxCacheEntries = 256
+
+// Invalidator is implemented by caching clients that can drop cached state
+// for a blob, e.g. when a GCS notification reports that it changed.
+type Invalidator interface {
+	Invalidate(blobPath string)
+}
+
+// PrefixInvalidator is implemented by caching clients that can drop every
+// cached entry whose blob path starts with a prefix.
+type PrefixInvalidator interface {
+	InvalidatePrefix(prefix string) int
+}
+
+// cacheKey distinguishes parsed and raw reads of the same blob.
+type cacheKey struct {
+	path string
+	raw  bool
+}
+
+// cacheEntry is a cached read result.
+type cacheEntry struct {
+	expires time.Time
+	data    interface{}
+}
+
+// MemoryCacheClient wraps a Client and caches successful reads in memory for
+// a TTL. Pair it with change notifications so long TTLs do not serve stale data.
+type MemoryCacheClient struct {
+	client  Client
+	entries map[cacheKey]cacheEntry
+	ttl     time.Duration
+	mu      sync.RWMutex
+}
+
+// NewMemoryCacheClient creates a client that caches reads for ttl.
+func NewMemoryCacheClient(client Client, ttl time.Duration) *MemoryCacheClient {
+	return &MemoryCacheClient{
+		client:  client,
+		entries: make(map[cacheKey]cacheEntry),
+		ttl:     ttl,
+	}
+}
+
+// ReadJSON returns cached parsed data or reads through to the wrapped client.
+func (c *MemoryCacheClient) ReadJSON(ctx context.Context, blobPath string) (interface{}, error) {
+	key := cacheKey{path: blobPath}
+	if data, ok := c.get(key); ok {
+		return data, nil
+	}
+
+	data, err := c.client.ReadJSON(ctx, blobPath)
+	if err != nil {
+		return nil, err
+	}
+	c.put(key, data)
+	return data, nil
+}
+
+// ReadBlob returns cached raw data or reads through to the wrapped client.
+func (c *MemoryCacheClient) ReadBlob(ctx context.Context, blobPath string) (*Blob, error) {
+	key := cacheKey{path: blobPath, raw: true}
+	if data, ok := c.get(key); ok {
+		return data.(*Blob), nil
+	}
+
+	blob, err := c.client.ReadBlob(ctx, blobPath)
+	if err != nil {
+		return nil, err
+	}
+	c.put(key, blob)
+	return blob, nil
+}
+
+// Stat delegates to the wrapped client; metadata is cheap and not cached.
+func (c *MemoryCacheClient) Stat(ctx context.Context, blobPath string) (*ObjectInfo, error) {
+	return c.client.Stat(ctx, blobPath)
+}
+
+// Ping delegates to the wrapped client.
+func (c *MemoryCacheClient) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx)
+}
+
+// WriteBlob writes through to the wrapped client and drops cached reads of
+// blobPath.
+func (c *MemoryCacheClient) WriteBlob(ctx context.Context, blobPath string, data []byte) error {
+	err := writeThrough(ctx, c.client, blobPath, data)
+	c.Invalidate(blobPath)
+	return err
+}
+
+// DeleteBlob deletes through the wrapped client and drops cached reads of
+// blobPath.
+func (c *MemoryCacheClient) DeleteBlob(ctx context.Context, blobPath string) error {
+	err := deleteThrough(ctx, c.client, blobPath)
+	c.Invalidate(blobPath)
+	return err
+}
+
+// ReadGeneration always reads through to the wrapped client.
+func (c *MemoryCacheClient) ReadGeneration(ctx context.Context, blobPath string) ([]byte, int64, error) {
+	return readGenerationThrough(ctx, c.client, blobPath)
+}
+
+// WriteBlobIf writes conditionally through to the wrapped client and drops
+// cached reads of blobPath.
+func (c *MemoryCacheClient) WriteBlobIf(ctx context.Context, blobPath string, data []byte, generation int64) error {
+	err := writeIfThrough(ctx, c.client, blobPath, data, generation)
+	c.Invalidate(blobPath)
+	return err
+}
+
+// Invalidate drops cached reads for blobPath and forwards to the wrapped
+// client so nested caches are cleared too.
+func (c *MemoryCacheClient) Invalidate(blobPath string) {
+	c.mu.Lock()
+	delete(c.entries, cacheKey{path: blobPath})
+	delete(c.entries, cacheKey{path: blobPath, raw: true})
+	c.mu.Unlock()
+
+	if inv, ok := c.client.(Invalidator); ok {
+		inv.Invalidate(blobPath)
+	}
+}
+
+// InvalidatePrefix drops cached reads under prefix ("" drops everything),
+// including nested caches, and returns the number of entries removed.
+func (c *MemoryCacheClient) InvalidatePrefix(prefix string) int {
+	c.mu.Lock()
+	removed := 0
+	for key := range c.entries {
+		if strings.HasPrefix(key.path, prefix) {
+			delete(c.entries, key)
+			removed++
+		}
+	}
+	c.mu.Unlock()
+
+	if inv, ok := c.client.(PrefixInvalidator); ok {
+		removed += inv.InvalidatePrefix(prefix)
+	}
+	return removed
+}
+
+// get returns an unexpired cached value.
+func (c *MemoryCacheClient) get(key cacheKey) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !time.Now().Before(entry.expires) {
+		return nil, false
+	}
+	return entry.data, true
+}
+
+// put stores a value, pruning expired entries when full.
+func (c *MemoryCacheClient) put(key cacheKey, data interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= maxCacheEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCacheEntries {
+			return
+		}
+	}
+	c.entries[key] = cacheEntry{expires: now.Add(c.ttl), data: data}
+}
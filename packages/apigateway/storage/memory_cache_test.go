@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("serves repeated reads from cache", func(t *testing.T) {
+		calls := 0
+		mock := &MockStorageClient{
+			ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+				calls++
+				return "data", nil
+			},
+		}
+		client := NewMemoryCacheClient(mock, time.Hour)
+
+		for i := 0; i < 3; i++ {
+			if _, err := client.ReadJSON(ctx, "activities/2024/distances.json"); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+
+		if calls != 1 {
+			t.Errorf("expected 1 call to underlying client, got %d", calls)
+		}
+	})
+
+	t.Run("invalidate forces a fresh read", func(t *testing.T) {
+		calls := 0
+		mock := &MockStorageClient{
+			ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+				calls++
+				return calls, nil
+			},
+		}
+		client := NewMemoryCacheClient(mock, time.Hour)
+
+		first, _ := client.ReadJSON(ctx, "activities/2024/distances.json")
+		client.Invalidate("activities/2024/distances.json")
+		second, _ := client.ReadJSON(ctx, "activities/2024/distances.json")
+
+		if first == second {
+			t.Errorf("expected fresh data after invalidation, got %v twice", first)
+		}
+	})
+
+	t.Run("invalidate clears nested negative cache", func(t *testing.T) {
+		exists := false
+		mock := &MockStorageClient{
+			ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+				if exists {
+					return "data", nil
+				}
+				return nil, ErrNotFound
+			},
+		}
+		client := NewMemoryCacheClient(NewNegativeCacheClient(mock, time.Hour), time.Hour)
+
+		if _, err := client.ReadJSON(ctx, "activities/2030/distances.json"); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+
+		exists = true
+		client.Invalidate("activities/2030/distances.json")
+
+		data, err := client.ReadJSON(ctx, "activities/2030/distances.json")
+		if err != nil {
+			t.Fatalf("expected no error after invalidation, got %v", err)
+		}
+		if data != "data" {
+			t.Errorf("expected new data, got %v", data)
+		}
+	})
+
+	t.Run("write drops cached reads", func(t *testing.T) {
+		local, err := NewLocalStorageClient(t.TempDir())
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		client := NewMemoryCacheClient(NewNegativeCacheClient(local, time.Hour), time.Hour)
+
+		if _, err := client.ReadJSON(ctx, "goals/1/2025.json"); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+		if err := client.WriteBlob(ctx, "goals/1/2025.json", []byte(`{"goals":[]}`)); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if _, err := client.ReadJSON(ctx, "goals/1/2025.json"); err != nil {
+			t.Errorf("expected the written blob after a write, got %v", err)
+		}
+	})
+}
+
+func TestMemoryCacheClientInvalidatePrefix(t *testing.T) {
+	ctx := context.Background()
+
+	mock := &MockStorageClient{
+		ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+			if blobPath == "activities/2030/distances.json" {
+				return nil, ErrNotFound
+			}
+			return "data", nil
+		},
+	}
+	client := NewMemoryCacheClient(NewNegativeCacheClient(mock, time.Hour), time.Hour)
+
+	for _, path := range []string{
+		"activities/2024/distances.json",
+		"activities/2024/summary_activities.json",
+		"activities/2023/distances.json",
+		"activities/2030/distances.json",
+	} {
+		_, _ = client.ReadJSON(ctx, path)
+	}
+
+	if removed := client.InvalidatePrefix("activities/2024/"); removed != 2 {
+		t.Errorf("expected 2 entries removed for 2024, got %d", removed)
+	}
+	if removed := client.InvalidatePrefix(""); removed != 2 {
+		t.Errorf("expected remaining 2 entries (1 cached, 1 not-found) removed, got %d", removed)
+	}
+}
+
+func TestMemoryCacheClientReadGeneration(t *testing.T) {
+	ctx := context.Background()
+	local, err := NewLocalStorageClient(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewMemoryCacheClient(local, time.Hour)
+	if err := client.WriteBlob(ctx, "activities/2025/summary_activities.json", []byte(`{"v":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ReadJSON(ctx, "activities/2025/summary_activities.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A write the cache doesn't see, e.g. by the processor
+	if err := local.WriteBlob(ctx, "activities/2025/summary_activities.json", []byte(`{"v":2}`)); err != nil {
+		t.Fatal(err)
+	}
+	data, _, err := client.ReadGeneration(ctx, "activities/2025/summary_activities.json")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if string(data) != `{"v":2}` {
+		t.Errorf("expected ReadGeneration to bypass the cache, got %s", data)
+	}
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultNotFoundCacheTTL is how long an ErrNotFound result is remembered.
+const DefaultNotFoundCacheTTL = time.Minute
+
+// maxNotFoundEntries caps memory use since blob paths are derived from
+// user-supplied URL segments.
+const maxNotFoundEntries = 1024
+
+// NegativeCacheClient wraps a Client and remembers ErrNotFound results for a
+// short TTL, so clients polling for a year that has no data yet do not send
+// every request through to the bucket.
+type NegativeCacheClient struct {
+	client   Client
+	notFound map[string]time.Time
+	ttl      time.Duration
+	mu       sync.RWMutex
+}
+
+// NewNegativeCacheClient creates a client that caches not-found lookups for ttl.
+func NewNegativeCacheClient(client Client, ttl time.Duration) *NegativeCacheClient {
+	return &NegativeCacheClient{
+		client:   client,
+		notFound: make(map[string]time.Time),
+		ttl:      ttl,
+	}
+}
+
+// ReadJSON returns ErrNotFound from cache or delegates to the wrapped client.
+func (c *NegativeCacheClient) ReadJSON(ctx context.Context, blobPath string) (interface{}, error) {
+	if c.isCachedNotFound(blobPath) {
+		return nil, ErrNotFound
+	}
+
+	data, err := c.client.ReadJSON(ctx, blobPath)
+	if errors.Is(err, ErrNotFound) {
+		c.rememberNotFound(blobPath)
+	}
+	return data, err
+}
+
+// ReadBlob returns ErrNotFound from cache or delegates to the wrapped client.
+func (c *NegativeCacheClient) ReadBlob(ctx context.Context, blobPath string) (*Blob, error) {
+	if c.isCachedNotFound(blobPath) {
+		return nil, ErrNotFound
+	}
+
+	blob, err := c.client.ReadBlob(ctx, blobPath)
+	if errors.Is(err, ErrNotFound) {
+		c.rememberNotFound(blobPath)
+	}
+	return blob, err
+}
+
+// Stat returns ErrNotFound from cache or delegates to the wrapped client.
+func (c *NegativeCacheClient) Stat(ctx context.Context, blobPath string) (*ObjectInfo, error) {
+	if c.isCachedNotFound(blobPath) {
+		return nil, ErrNotFound
+	}
+
+	info, err := c.client.Stat(ctx, blobPath)
+	if errors.Is(err, ErrNotFound) {
+		c.rememberNotFound(blobPath)
+	}
+	return info, err
+}
+
+// Ping delegates to the wrapped client.
+func (c *NegativeCacheClient) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx)
+}
+
+// WriteBlob writes through to the wrapped client and forgets a not-found
+// result for blobPath.
+func (c *NegativeCacheClient) WriteBlob(ctx context.Context, blobPath string, data []byte) error {
+	err := writeThrough(ctx, c.client, blobPath, data)
+	c.Invalidate(blobPath)
+	return err
+}
+
+// DeleteBlob deletes through to the wrapped client.
+func (c *NegativeCacheClient) DeleteBlob(ctx context.Context, blobPath string) error {
+	err := deleteThrough(ctx, c.client, blobPath)
+	c.Invalidate(blobPath)
+	return err
+}
+
+// ReadGeneration always reads through to the wrapped client.
+func (c *NegativeCacheClient) ReadGeneration(ctx context.Context, blobPath string) ([]byte, int64, error) {
+	return readGenerationThrough(ctx, c.client, blobPath)
+}
+
+// WriteBlobIf writes conditionally through to the wrapped client and
+// forgets a not-found result for blobPath.
+func (c *NegativeCacheClient) WriteBlobIf(ctx context.Context, blobPath string, data []byte, generation int64) error {
+	err := writeIfThrough(ctx, c.client, blobPath, data, generation)
+	c.Invalidate(blobPath)
+	return err
+}
+
+// Invalidate forgets a not-found result, e.g. when the blob has just been created.
+func (c *NegativeCacheClient) Invalidate(blobPath string) {
+	c.mu.Lock()
+	delete(c.notFound, blobPath)
+	c.mu.Unlock()
+
+	if inv, ok := c.client.(Invalidator); ok {
+		inv.Invalidate(blobPath)
+	}
+}
+
+// InvalidatePrefix forgets not-found results under prefix and returns the
+// number of entries removed.
+func (c *NegativeCacheClient) InvalidatePrefix(prefix string) int {
+	c.mu.Lock()
+	removed := 0
+	for path := range c.notFound {
+		if strings.HasPrefix(path, prefix) {
+			delete(c.notFound, path)
+			removed++
+		}
+	}
+	c.mu.Unlock()
+
+	if inv, ok := c.client.(PrefixInvalidator); ok {
+		removed += inv.InvalidatePrefix(prefix)
+	}
+	return removed
+}
+
+// isCachedNotFound reports whether blobPath has an unexpired not-found entry.
+func (c *NegativeCacheClient) isCachedNotFound(blobPath string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	expires, ok := c.notFound[blobPath]
+	return ok && time.Now().Before(expires)
+}
+
+// rememberNotFound records a not-found result, pruning expired entries when full.
+func (c *NegativeCacheClient) rememberNotFound(blobPath string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.notFound) >= maxNotFoundEntries {
+		for path, expires := range c.notFound {
+			if !now.Before(expires) {
+				delete(c.notFound, path)
+			}
+		}
+		if len(c.notFound) >= maxNotFoundEntries {
+			return
+		}
+	}
+	c.notFound[blobPath] = now.Add(c.ttl)
+}
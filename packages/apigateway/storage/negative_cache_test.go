@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNegativeCacheClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("caches not found results within TTL", func(t *testing.T) {
+		calls := 0
+		mock := &MockStorageClient{
+			ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+				calls++
+				return nil, ErrNotFound
+			},
+		}
+		client := NewNegativeCacheClient(mock, time.Minute)
+
+		for i := 0; i < 3; i++ {
+			if _, err := client.ReadJSON(ctx, "activities/2030/distances.json"); err != ErrNotFound {
+				t.Fatalf("expected ErrNotFound, got %v", err)
+			}
+		}
+
+		if calls != 1 {
+			t.Errorf("expected 1 call to underlying client, got %d", calls)
+		}
+	})
+
+	t.Run("retries after TTL expires", func(t *testing.T) {
+		calls := 0
+		mock := &MockStorageClient{
+			ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+				calls++
+				if calls > 1 {
+					return "data", nil
+				}
+				return nil, ErrNotFound
+			},
+		}
+		client := NewNegativeCacheClient(mock, 20*time.Millisecond)
+
+		if _, err := client.ReadJSON(ctx, "activities/2030/distances.json"); err != ErrNotFound {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+
+		time.Sleep(30 * time.Millisecond)
+
+		data, err := client.ReadJSON(ctx, "activities/2030/distances.json")
+		if err != nil {
+			t.Fatalf("expected no error after TTL, got %v", err)
+		}
+		if data != "data" {
+			t.Errorf("expected fresh data, got %v", data)
+		}
+	})
+
+	t.Run("does not cache successful reads", func(t *testing.T) {
+		calls := 0
+		mock := &MockStorageClient{
+			ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+				calls++
+				return "data", nil
+			},
+		}
+		client := NewNegativeCacheClient(mock, time.Minute)
+
+		for i := 0; i < 2; i++ {
+			if _, err := client.ReadJSON(ctx, "activities/2024/distances.json"); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+
+		if calls != 2 {
+			t.Errorf("expected 2 calls to underlying client, got %d", calls)
+		}
+	})
+}
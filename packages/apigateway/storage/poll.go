@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultPollInterval is how often Poll checks local fixtures for changes.
+const DefaultPollInterval = time.Second
+
+// Poll reports files changed under the base path to onChange, checking
+// every interval until ctx is done. It is the fallback for Watch where
+// inotify events never arrive, as through some Docker bind mounts: it
+// compares modification times and sizes, and editors save in well under
+// an interval, so a change is seen once. Hidden files, such as editor swap
+// files and the temp files of WriteBlob, are skipped.
+func (c *LocalStorageClient) Poll(ctx context.Context, interval time.Duration, onChange func(FileChange)) error {
+	files, err := c.scan(c.basePath, nil)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current, err := c.scan(c.basePath, nil)
+			if err != nil {
+				// The base path can briefly vanish, e.g. during a git
+				// checkout; compare against the last full scan next time
+				continue
+			}
+			for _, change := range diffFiles(files, current) {
+				onChange(change)
+			}
+			files = current
+		}
+	}()
+	return nil
+}
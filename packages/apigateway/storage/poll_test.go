@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLocalStorageClient_Poll(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "activities", "2025"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	distances := filepath.Join(dir, "activities", "2025", "distances.json")
+	summary := filepath.Join(dir, "activities", "2025", "summary.json")
+	for _, path := range []string{distances, summary} {
+		if err := os.WriteFile(path, []byte(`{}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client, err := NewLocalStorageClient(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan FileChange, 10)
+	if err := client.Poll(ctx, 10*time.Millisecond, func(change FileChange) { changes <- change }); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+
+	if err := os.WriteFile(distances, []byte(`{"distance_traveled": [1]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "activities", "2025", ".distances.json.swp"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := waitForChange(t, changes); got != (FileChange{Path: "activities/2025/distances.json"}) {
+		t.Errorf("expected the edited fixture reported, got %+v", got)
+	}
+
+	if err := os.Remove(summary); err != nil {
+		t.Fatal(err)
+	}
+	if got := waitForChange(t, changes); got != (FileChange{Path: "activities/2025/summary.json", Deleted: true}) {
+		t.Errorf("expected the removed fixture reported, got %+v", got)
+	}
+
+	select {
+	case change := <-changes:
+		t.Errorf("unexpected change %+v", change)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchSettle is how long Watch waits after a file event for more, so the
+// several events of one editor save are reported as one change.
+const watchSettle = 100 * time.Millisecond
+
+// FileChange is a file under a LocalStorageClient's base path that was
+// created, modified or removed.
+type FileChange struct {
+	// Path is the blob path of the file, e.g. activities/2025/distances.json.
+	Path    string
+	Deleted bool
+}
+
+// fileState is what Watch and Poll compare to tell a file changed.
+type fileState struct {
+	modTime int64
+	size    int64
+}
+
+// Watch reports files changed under the base path to onChange until ctx
+// is done, using inotify (or the platform's equivalent) through fsnotify.
+// Directories created later are watched too. Events are coalesced for
+// watchSettle and the touched paths compared with what was last seen, so
+// each change is reported once. Hidden files, such as editor swap files
+// and the temp files of WriteBlob, are skipped. Where events never arrive,
+// as through some Docker bind mounts, use Poll instead.
+func (c *LocalStorageClient) Watch(ctx context.Context, onChange func(FileChange)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", c.basePath, err)
+	}
+	files, err := c.scan(c.basePath, watcher.Add)
+	if err != nil {
+		_ = watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", c.basePath, err)
+	}
+
+	go func() {
+		defer func() { _ = watcher.Close() }()
+		pending := map[string]bool{}
+		settle := time.NewTimer(watchSettle)
+		settle.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op == fsnotify.Chmod || c.hidden(event.Name) {
+					continue
+				}
+				pending[event.Name] = true
+				settle.Reset(watchSettle)
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				// Events were lost, e.g. the queue overflowed; compare
+				// everything instead
+				pending[c.basePath] = true
+				settle.Reset(watchSettle)
+			case <-settle.C:
+				var changes []FileChange
+				for path := range pending {
+					changes = append(changes, c.rescan(watcher, files, path)...)
+				}
+				clear(pending)
+				slices.SortFunc(changes, func(a, b FileChange) int {
+					return strings.Compare(a.Path, b.Path)
+				})
+				for _, change := range changes {
+					onChange(change)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// rescan compares the files at or under path with their states in files,
+// updating files and watching any new directories, and returns the
+// changes.
+func (c *LocalStorageClient) rescan(watcher *fsnotify.Watcher, files map[string]fileState, path string) []FileChange {
+	rel, err := filepath.Rel(c.basePath, path)
+	if err != nil {
+		return nil
+	}
+	rel = filepath.ToSlash(rel)
+
+	before := map[string]fileState{}
+	for name, state := range files {
+		if rel == "." || name == rel || strings.HasPrefix(name, rel+"/") {
+			before[name] = state
+		}
+	}
+	after, err := c.scan(path, watcher.Add)
+	if err != nil {
+		// Compare again on the path's next event
+		return nil
+	}
+
+	changes := diffFiles(before, after)
+	for _, change := range changes {
+		if change.Deleted {
+			delete(files, change.Path)
+		} else {
+			files[change.Path] = after[change.Path]
+		}
+	}
+	return changes
+}
+
+// hidden reports whether path, under the base path, is or is inside a
+// hidden file or directory.
+func (c *LocalStorageClient) hidden(path string) bool {
+	rel, err := filepath.Rel(c.basePath, path)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(strings.Split(filepath.ToSlash(rel), "/"), func(name string) bool {
+		return strings.HasPrefix(name, ".") && name != "."
+	})
+}
+
+// scan records the state of every file at or under root, a path under the
+// base path, by blob path, calling visitDir for each directory unless it
+// is nil. A missing root has no files.
+func (c *LocalStorageClient) scan(root string, visitDir func(string) error) (map[string]fileState, error) {
+	files := map[string]fileState{}
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root && root != c.basePath && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if strings.HasPrefix(entry.Name(), ".") && path != c.basePath {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if visitDir != nil {
+				return visitDir(path)
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// Removed since the directory was read
+			return nil
+		}
+		rel, err := filepath.Rel(c.basePath, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = fileState{modTime: info.ModTime().UnixNano(), size: info.Size()}
+		return nil
+	})
+	return files, err
+}
+
+// diffFiles returns the changes from before to after, ordered by path.
+func diffFiles(before, after map[string]fileState) []FileChange {
+	var changes []FileChange
+	for path, state := range after {
+		if previous, ok := before[path]; !ok || previous != state {
+			changes = append(changes, FileChange{Path: path})
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changes = append(changes, FileChange{Path: path, Deleted: true})
+		}
+	}
+	slices.SortFunc(changes, func(a, b FileChange) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return changes
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLocalStorageClient_Watch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "activities", "2025"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	distances := filepath.Join(dir, "activities", "2025", "distances.json")
+	summary := filepath.Join(dir, "activities", "2025", "summary.json")
+	for _, path := range []string{distances, summary} {
+		if err := os.WriteFile(path, []byte(`{}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client, err := NewLocalStorageClient(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan FileChange, 10)
+	if err := client.Watch(ctx, func(change FileChange) { changes <- change }); err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	t.Run("reports an edit once", func(t *testing.T) {
+		if err := os.WriteFile(distances, []byte(`{"distance_traveled": [1]}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "activities", "2025", ".distances.json.swp"), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if got := waitForChange(t, changes); got != (FileChange{Path: "activities/2025/distances.json"}) {
+			t.Errorf("expected the edited fixture reported, got %+v", got)
+		}
+		expectNoChange(t, changes)
+	})
+
+	t.Run("reports a save through a rename", func(t *testing.T) {
+		tmp := filepath.Join(dir, "activities", "2025", ".write-1")
+		if err := os.WriteFile(tmp, []byte(`{"distance_traveled": [1, 2]}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, distances); err != nil {
+			t.Fatal(err)
+		}
+		if got := waitForChange(t, changes); got != (FileChange{Path: "activities/2025/distances.json"}) {
+			t.Errorf("expected the replaced fixture reported, got %+v", got)
+		}
+		expectNoChange(t, changes)
+	})
+
+	t.Run("reports a removal", func(t *testing.T) {
+		if err := os.Remove(summary); err != nil {
+			t.Fatal(err)
+		}
+		if got := waitForChange(t, changes); got != (FileChange{Path: "activities/2025/summary.json", Deleted: true}) {
+			t.Errorf("expected the removed fixture reported, got %+v", got)
+		}
+		expectNoChange(t, changes)
+	})
+
+	t.Run("watches new directories", func(t *testing.T) {
+		year := filepath.Join(dir, "activities", "2026")
+		if err := os.Mkdir(year, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(year, "distances.json"), []byte(`{}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if got := waitForChange(t, changes); got != (FileChange{Path: "activities/2026/distances.json"}) {
+			t.Errorf("expected the new year's fixture reported, got %+v", got)
+		}
+		expectNoChange(t, changes)
+
+		if err := os.WriteFile(filepath.Join(year, "distances.json"), []byte(`{"distance_traveled": [1]}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if got := waitForChange(t, changes); got != (FileChange{Path: "activities/2026/distances.json"}) {
+			t.Errorf("expected the edit in the new directory reported, got %+v", got)
+		}
+
+		if err := os.RemoveAll(year); err != nil {
+			t.Fatal(err)
+		}
+		if got := waitForChange(t, changes); got != (FileChange{Path: "activities/2026/distances.json", Deleted: true}) {
+			t.Errorf("expected the removed directory's fixture reported, got %+v", got)
+		}
+		expectNoChange(t, changes)
+	})
+}
+
+func waitForChange(t *testing.T, changes <-chan FileChange) FileChange {
+	t.Helper()
+	select {
+	case change := <-changes:
+		return change
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a change")
+		return FileChange{}
+	}
+}
+
+func expectNoChange(t *testing.T, changes <-chan FileChange) {
+	t.Helper()
+	select {
+	case change := <-changes:
+		t.Errorf("unexpected change %+v", change)
+	case <-time.After(2 * watchSettle):
+	}
+}
+
+func TestDiffFiles(t *testing.T) {
+	before := map[string]fileState{
+		"a.json":   {modTime: 1, size: 2},
+		"b.json":   {modTime: 1, size: 2},
+		"old.json": {modTime: 1, size: 2},
+	}
+	after := map[string]fileState{
+		"a.json":   {modTime: 1, size: 2},
+		"b.json":   {modTime: 1, size: 3},
+		"new.json": {modTime: 1, size: 2},
+	}
+	want := []FileChange{{Path: "b.json"}, {Path: "new.json"}, {Path: "old.json", Deleted: true}}
+	if got := diffFiles(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,37 @@
+package apigateway
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/apigateway/schema"
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+)
+
+// handleStreams serves the time-series streams of the activity of the
+// athlete whose blobs are under prefix at activities/{activity_id}/streams,
+// as stored by the processor with STORE_STREAMS.
+func (h *Handler) handleStreams(w http.ResponseWriter, r *http.Request, prefix, activityID string) {
+	id, err := strconv.ParseInt(activityID, 10, 64)
+	if err != nil || id <= 0 {
+		h.respondError(w, r, http.StatusBadRequest, types.ErrCodeBadRequest, fmt.Sprintf("Invalid activity ID: %s", activityID), "")
+		return
+	}
+
+	blobPath := prefix + aggregation.StreamsBlob(id)
+	resource := fmt.Sprintf("%d/streams", id)
+	data, err := h.storage.ReadJSON(r.Context(), blobPath)
+	if err != nil {
+		h.respondStorageError(w, r, err, blobPath, resource)
+		return
+	}
+	if h.validateBlobs {
+		if err := schema.Validate("streams", data); err != nil {
+			h.respondInvalidBlob(w, r, err, blobPath, resource)
+			return
+		}
+	}
+	h.respondJSONRaw(w, r, http.StatusOK, data)
+}
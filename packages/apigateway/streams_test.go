@@ -0,0 +1,59 @@
+package apigateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/storage"
+	"github.com/andy-esch/desirelines/packages/athletes"
+)
+
+func TestHandlerStreams(t *testing.T) {
+	var reads []string
+	mock := &mockStorageClient{
+		ReadJSONFunc: func(ctx context.Context, blobPath string) (interface{}, error) {
+			reads = append(reads, blobPath)
+			switch blobPath {
+			case "streams/404.json":
+				return nil, storage.ErrNotFound
+			case "streams/500.json":
+				return map[string]interface{}{"time": []interface{}{0.0}}, nil
+			}
+			return map[string]interface{}{"time": map[string]interface{}{"data": []interface{}{0.0, 1.0}}}, nil
+		},
+	}
+	handler := NewHandlerWithStorage(mock)
+	handler.validateBlobs = true
+	handler.athletes = athletes.New(func(context.Context) ([]byte, error) {
+		return []byte(`{"athletes": [{"id": 7, "prefix": "athletes/7/"}]}`), nil
+	}, 0)
+
+	tests := []struct {
+		path string
+		want int
+		read string
+	}{
+		{"/activities/12345/streams", http.StatusOK, "streams/12345.json"},
+		{"/athletes/7/activities/12345/streams", http.StatusOK, "athletes/7/streams/12345.json"},
+		{"/activities/404/streams", http.StatusNotFound, "streams/404.json"},
+		{"/activities/500/streams", http.StatusBadGateway, "streams/500.json"},
+		{"/activities/-1/streams", http.StatusBadRequest, ""},
+		{"/activities/abc/streams", http.StatusBadRequest, ""},
+		{"/athletes/9/activities/12345/streams", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			reads = nil
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.want {
+				t.Fatalf("expected status %d, got %d: %s", tt.want, w.Code, w.Body)
+			}
+			if tt.read == "" && len(reads) != 0 || tt.read != "" && (len(reads) != 1 || reads[0] != tt.read) {
+				t.Errorf("expected read of %q, got %v", tt.read, reads)
+			}
+		})
+	}
+}
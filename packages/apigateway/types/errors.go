@@ -0,0 +1,35 @@
+package types
+
+// ErrorCode is a stable, machine-readable identifier for an API error.
+// Clients should branch on Code rather than on Message, which may change.
+type ErrorCode string
+
+// Error codes returned in ErrorResponse.Code.
+const (
+	// ErrCodeBadRequest: the request path or query is malformed (400).
+	ErrCodeBadRequest ErrorCode = "bad_request"
+	// ErrCodeInvalidDataType: /activities/{year}/{type} named an unknown type (400).
+	ErrCodeInvalidDataType ErrorCode = "invalid_data_type"
+	// ErrCodeInvalidPayload: a POST or PUT body could not be decoded or validated (400).
+	ErrCodeInvalidPayload ErrorCode = "invalid_payload"
+	// ErrCodeUnauthorized: a required token was missing or wrong (401).
+	ErrCodeUnauthorized ErrorCode = "unauthorized"
+	// ErrCodeForbidden: the client IP is excluded by IP_ALLOWLIST/IP_DENYLIST (403).
+	ErrCodeForbidden ErrorCode = "forbidden"
+	// ErrCodeNotFound: no route or stored data exists for the request (404).
+	ErrCodeNotFound ErrorCode = "not_found"
+	// ErrCodeMethodNotAllowed: the route does not accept this HTTP method (405).
+	ErrCodeMethodNotAllowed ErrorCode = "method_not_allowed"
+	// ErrCodeRateLimited: the client exceeded RATE_LIMIT; see Retry-After (429).
+	ErrCodeRateLimited ErrorCode = "rate_limited"
+	// ErrCodeInternal: an unexpected server-side failure (500).
+	ErrCodeInternal ErrorCode = "internal_error"
+	// ErrCodeReadOnly: the configured storage cannot be written to (501).
+	ErrCodeReadOnly ErrorCode = "read_only"
+	// ErrCodeUpstream: a Strava API call failed (502) or timed out (504).
+	ErrCodeUpstream ErrorCode = "upstream_error"
+	// ErrCodeMalformedData: stored data failed schema validation (502).
+	ErrCodeMalformedData ErrorCode = "malformed_data"
+	// ErrCodeStorageTimeout: the storage read exceeded REQUEST_TIMEOUT (504).
+	ErrCodeStorageTimeout ErrorCode = "storage_timeout"
+)
@@ -1,12 +1,63 @@
 // Package types defines API response structures.
 package types
 
+import "time"
+
 // HealthResponse is the response for the /health endpoint.
+// Checks is only populated for deep health checks (?deep=true) and maps each
+// dependency name to "ok" or an error description.
 type HealthResponse struct {
-	Status string `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+	Status string            `json:"status"`
 }
 
-// ErrorResponse is the response for error cases.
+// ErrorResponse is the response for error cases. Code is one of the
+// ErrorCode constants; CorrelationID matches the X-Correlation-ID response
+// header and the gateway's log line for the request.
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Code          ErrorCode `json:"code"`
+	Message       string    `json:"message"`
+	CorrelationID string    `json:"correlation_id"`
+	Details       string    `json:"details,omitempty"`
+	// RetryAfterSeconds is set on 429 responses and mirrors Retry-After.
+	RetryAfterSeconds int `json:"retry_after_seconds,omitempty"`
+}
+
+// FreshnessResponse is the response for the /activities/{year}/freshness endpoint.
+type FreshnessResponse struct {
+	LastUpdated        time.Time `json:"last_updated"`
+	Year               string    `json:"year"`
+	NewestActivityDate string    `json:"newest_activity_date,omitempty"`
+}
+
+// DataUpdatedEvent is pushed to /ws subscribers when a stored blob changes.
+type DataUpdatedEvent struct {
+	Updated   time.Time `json:"updated"`
+	Type      string    `json:"type"`
+	Path      string    `json:"path"`
+	Year      string    `json:"year"`
+	DataType  string    `json:"data_type"`
+	AthleteID string    `json:"athlete_id,omitempty"`
+}
+
+// CachePurgeRequest is the request body for POST /admin/cache/purge.
+type CachePurgeRequest struct {
+	Prefix string `json:"prefix"`
+}
+
+// CachePurgeResponse is the response for POST /admin/cache/purge.
+type CachePurgeResponse struct {
+	Prefix string `json:"prefix"`
+	Purged int    `json:"purged"`
+}
+
+// VersionResponse is the response for the /version endpoint. Fields the
+// build didn't provide are "unknown".
+type VersionResponse struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+	// Modified is set when the binary was built from an uncommitted tree.
+	Modified bool `json:"modified,omitempty"`
 }
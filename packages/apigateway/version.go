@@ -0,0 +1,75 @@
+package apigateway
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+)
+
+// Build metadata, set at link time:
+//
+//	go build -ldflags "-X github.com/andy-esch/desirelines/packages/apigateway.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/andy-esch/desirelines/packages/apigateway.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Unset values fall back to what the Go toolchain embedded in the binary.
+var (
+	Version   string
+	Commit    string
+	BuildTime string
+)
+
+const modulePath = "github.com/andy-esch/desirelines/packages/apigateway"
+
+// currentBuild returns the build metadata, read once.
+var currentBuild = sync.OnceValue(func() types.VersionResponse {
+	info := types.VersionResponse{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if embedded, ok := debug.ReadBuildInfo(); ok {
+		fillBuildInfo(&info, embedded)
+	}
+	for _, field := range []*string{&info.Version, &info.Commit, &info.BuildTime} {
+		if *field == "" {
+			*field = "unknown"
+		}
+	}
+	return info
+})
+
+// fillBuildInfo completes unset fields from the toolchain's build info: the
+// module version (this package is the main module locally and a dependency
+// in the Cloud Function) and, when built inside a git checkout, the VCS
+// revision and commit time.
+func fillBuildInfo(info *types.VersionResponse, embedded *debug.BuildInfo) {
+	if info.Version == "" {
+		module := &embedded.Main
+		for _, dep := range embedded.Deps {
+			if dep.Path == modulePath {
+				module = dep
+			}
+		}
+		if module.Path == modulePath && module.Version != "(devel)" {
+			info.Version = module.Version
+		}
+	}
+	for _, setting := range embedded.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildTime == "" {
+				info.BuildTime = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+}
+
+// handleVersion reports which build is running.
+func (h *Handler) handleVersion(w http.ResponseWriter, r *http.Request) {
+	h.respondJSON(w, r, http.StatusOK, currentBuild())
+}
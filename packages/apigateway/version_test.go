@@ -0,0 +1,48 @@
+package apigateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/apigateway/types"
+)
+
+func TestFillBuildInfo(t *testing.T) {
+	embedded := &debug.BuildInfo{
+		Main: debug.Module{Path: "github.com/andy-esch/desirelines/api-gateway-function", Version: "(devel)"},
+		Deps: []*debug.Module{{Path: modulePath, Version: "v1.2.3"}},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abc123"},
+			{Key: "vcs.time", Value: "2024-03-05T23:00:00Z"},
+		},
+	}
+
+	info := types.VersionResponse{Commit: "def456"}
+	fillBuildInfo(&info, embedded)
+	want := types.VersionResponse{Version: "v1.2.3", Commit: "def456", BuildTime: "2024-03-05T23:00:00Z"}
+	if info != want {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+}
+
+func TestHandlerVersion(t *testing.T) {
+	handler := NewHandlerWithStorage(&mockStorageClient{})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var response types.VersionResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.GoVersion != runtime.Version() || response.Commit == "" {
+		t.Errorf("unexpected response %+v", response)
+	}
+}
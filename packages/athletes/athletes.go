@@ -0,0 +1,119 @@
+// Package athletes is the registry of the Strava athletes one deployment
+// serves. Each athlete's aggregates live under their own storage prefix,
+// and the registry's refresh token seeds their shared token (see
+// tokenstore), so the functions can serve more than one account:
+//
+//	registry := athletes.New(athletes.FileLoader("athletes.json"), athletes.DefaultCacheTTL)
+//	athlete, err := registry.Lookup(ctx, event.OwnerID)
+//	blob := athlete.Blob(aggregation.SummaryBlob(2025))
+//
+// The registry is one JSON document, kept in Cloud Storage or, for local
+// development, a file:
+//
+//	{"athletes": [{"id": 12345, "name": "Andy", "prefix": "athletes/12345/", "refresh_token": "..."}]}
+package athletes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownAthlete is returned for athletes the registry doesn't list.
+var ErrUnknownAthlete = errors.New("unknown athlete")
+
+// Athlete is a registered Strava account.
+type Athlete struct {
+	Name string `json:"name,omitempty"`
+	// Prefix is prepended to the names of the athlete's objects, e.g.
+	// "athletes/12345/" (see DefaultPrefix). It is empty for an athlete
+	// whose aggregates are at the bucket root, as they were before the
+	// registry.
+	Prefix string `json:"prefix"`
+	// RefreshToken, if set, is used to call Strava while no token is
+	// stored for the athlete; with a token store it only seeds it.
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ID           int64  `json:"id"`
+}
+
+// Blob returns the name of the athlete's copy of the object name, e.g.
+// "athletes/12345/activities/2025/distances.json".
+func (a Athlete) Blob(name string) string {
+	return a.Prefix + name
+}
+
+// DefaultPrefix is the prefix suggested for newly registered athletes.
+func DefaultPrefix(athleteID int64) string {
+	return fmt.Sprintf("athletes/%d/", athleteID)
+}
+
+// document is the registry's JSON format.
+type document struct {
+	Athletes []Athlete `json:"athletes"`
+}
+
+// Parse decodes a registry document, checking that athlete IDs and
+// prefixes are unique and that prefixes are safe object name prefixes.
+// Prefixes are given a trailing "/" if they lack one.
+func Parse(data []byte) ([]Athlete, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse athlete registry: %w", err)
+	}
+
+	var errs []error
+	ids := map[int64]bool{}
+	prefixes := map[string]int64{}
+	for i := range doc.Athletes {
+		athlete := &doc.Athletes[i]
+		if athlete.ID <= 0 {
+			errs = append(errs, fmt.Errorf("athlete %d: invalid ID %d", i, athlete.ID))
+			continue
+		}
+		if ids[athlete.ID] {
+			errs = append(errs, fmt.Errorf("athlete %d is listed twice", athlete.ID))
+		}
+		ids[athlete.ID] = true
+
+		if athlete.Prefix != "" && !strings.HasSuffix(athlete.Prefix, "/") {
+			athlete.Prefix += "/"
+		}
+		if err := validatePrefix(athlete.Prefix); err != nil {
+			errs = append(errs, fmt.Errorf("athlete %d: %w", athlete.ID, err))
+		}
+		if other, ok := prefixes[athlete.Prefix]; ok {
+			errs = append(errs, fmt.Errorf("athletes %d and %d share the prefix %q", other, athlete.ID, athlete.Prefix))
+		}
+		prefixes[athlete.Prefix] = athlete.ID
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid athlete registry:\n%w", err)
+	}
+	return doc.Athletes, nil
+}
+
+// validatePrefix accepts "/"-separated segments of [A-Za-z0-9._-] that
+// aren't only dots, as the API gateway accepts in request paths, so a
+// prefix can't walk out of the local fixtures directory.
+func validatePrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+	for _, segment := range strings.Split(strings.TrimSuffix(prefix, "/"), "/") {
+		if segment == "" || strings.Trim(segment, ".") == "" {
+			return fmt.Errorf("invalid prefix %q", prefix)
+		}
+		for _, c := range segment {
+			if !isPrefixChar(c) {
+				return fmt.Errorf("invalid prefix %q", prefix)
+			}
+		}
+	}
+	return nil
+}
+
+func isPrefixChar(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+		c == '-' || c == '_' || c == '.'
+}
@@ -0,0 +1,214 @@
+package athletes
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	athletes, err := Parse([]byte(`{"athletes": [
+		{"id": 1, "name": "Legacy", "prefix": ""},
+		{"id": 2, "prefix": "athletes/2", "refresh_token": "refresh"}
+	]}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []Athlete{
+		{ID: 1, Name: "Legacy"},
+		{ID: 2, Prefix: "athletes/2/", RefreshToken: "refresh"},
+	}
+	if !reflect.DeepEqual(athletes, want) {
+		t.Errorf("expected %+v, got %+v", want, athletes)
+	}
+	if blob := athletes[1].Blob("activities/2025/distances.json"); blob != "athletes/2/activities/2025/distances.json" {
+		t.Errorf("unexpected blob %q", blob)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	_, err := Parse([]byte(`{"athletes": [
+		{"id": 0},
+		{"id": 3, "prefix": "athletes/3/"},
+		{"id": 3, "prefix": "athletes/x/"},
+		{"id": 4, "prefix": "athletes/3"},
+		{"id": 5, "prefix": "../secrets/"},
+		{"id": 6, "prefix": "a b/"}
+	]}`))
+	if err == nil {
+		t.Fatal("expected the registry to be rejected")
+	}
+	for _, want := range []string{"invalid ID 0", "athlete 3 is listed twice", "share the prefix", `invalid prefix "../secrets/"`, `invalid prefix "a b/"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q reported, got: %v", want, err)
+		}
+	}
+}
+
+func TestRegistry_Lookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "athletes.json")
+	registry := New(FileLoader(path), time.Minute)
+	now := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	registry.now = func() time.Time { return now }
+
+	// No registry yet registers nobody
+	if _, err := registry.Lookup(context.Background(), 1); !errors.Is(err, ErrUnknownAthlete) {
+		t.Fatalf("expected ErrUnknownAthlete, got %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"athletes": [{"id": 1, "prefix": "athletes/1/"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.Lookup(context.Background(), 1); !errors.Is(err, ErrUnknownAthlete) {
+		t.Errorf("expected the cached registry to be used within the TTL, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	athlete, err := registry.Lookup(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if athlete.Prefix != "athletes/1/" {
+		t.Errorf("unexpected athlete %+v", athlete)
+	}
+
+	// A broken update keeps the last good registry
+	if err := os.WriteFile(path, []byte(`{"athletes": [`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := registry.Lookup(context.Background(), 1); err != nil {
+		t.Errorf("expected the previous registry to be kept, got %v", err)
+	}
+}
+
+func TestRegistry_LoadError(t *testing.T) {
+	registry := New(func(context.Context) ([]byte, error) { return []byte("{"), nil }, time.Minute)
+	if _, err := registry.Lookup(context.Background(), 1); err == nil || errors.Is(err, ErrUnknownAthlete) {
+		t.Errorf("expected the parse error, got %v", err)
+	}
+}
+
+func TestRegistry_Athletes(t *testing.T) {
+	registry := New(func(context.Context) ([]byte, error) {
+		return []byte(`{"athletes": [{"id": 9, "prefix": "athletes/9/"}, {"id": 2, "prefix": "athletes/2/"}]}`), nil
+	}, 0)
+	athletes, err := registry.Athletes(context.Background())
+	if err != nil {
+		t.Fatalf("Athletes failed: %v", err)
+	}
+	if len(athletes) != 2 || athletes[0].ID != 2 || athletes[1].ID != 9 {
+		t.Errorf("expected athletes in ID order, got %+v", athletes)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	t.Setenv("ATHLETE_REGISTRY", "gs://bucket/registry/athletes.json")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+	if bucket, object, ok := cfg.GCS(); !ok || bucket != "bucket" || object != "registry/athletes.json" || cfg.CacheTTL != DefaultCacheTTL {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	if (&Config{Location: "athletes.json"}).Validate() != nil {
+		t.Error("expected a local path to be valid")
+	}
+	if (&Config{Location: "gs://bucket"}).Validate() == nil {
+		t.Error("expected a URL without an object to be rejected")
+	}
+	var none *Config
+	if none.Enabled() || none.Validate() != nil {
+		t.Error("expected a nil config to be valid and disabled")
+	}
+}
+
+func TestClients(t *testing.T) {
+	clients := NewClients(1, "secret", nil)
+	athlete := Athlete{ID: 1, RefreshToken: "refresh"}
+	client := clients.Client(athlete)
+	if clients.Client(athlete) != client {
+		t.Error("expected the client to be reused")
+	}
+	if clients.Client(Athlete{ID: 2}) == client {
+		t.Error("expected another athlete to get their own client")
+	}
+	athlete.RefreshToken = "reauthorized"
+	if clients.Client(athlete) == client {
+		t.Error("expected a new client for a changed refresh token")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "athletes.json")
+	update := FileUpdater(path)
+	ctx := context.Background()
+
+	athlete, err := Register(ctx, update, Athlete{ID: 5, Name: "New", RefreshToken: "first"})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if athlete.Prefix != DefaultPrefix(5) {
+		t.Errorf("expected a new athlete to get the default prefix, got %+v", athlete)
+	}
+
+	// Reauthorizing keeps the prefix and name
+	if err := os.WriteFile(path, []byte(`{"athletes": [{"id": 5, "name": "Legacy", "prefix": ""}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	athlete, err = Register(ctx, update, Athlete{ID: 5, RefreshToken: "second"})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	want := Athlete{ID: 5, Name: "Legacy", RefreshToken: "second"}
+	if athlete != want {
+		t.Errorf("expected %+v, got %+v", want, athlete)
+	}
+
+	registry := New(FileLoader(path), time.Hour)
+	if _, err := Register(ctx, update, Athlete{ID: 6}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	list, err := registry.Athletes(ctx)
+	if err != nil {
+		t.Fatalf("Athletes failed: %v", err)
+	}
+	if len(list) != 2 || list[0] != want || list[1].Prefix != DefaultPrefix(6) {
+		t.Errorf("unexpected registry %+v", list)
+	}
+}
+
+func TestRegister_InvalidRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "athletes.json")
+	if err := os.WriteFile(path, []byte(`{"athletes": [`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Register(context.Background(), FileUpdater(path), Athlete{ID: 5}); err == nil {
+		t.Fatal("expected a broken registry not to be overwritten")
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"athletes": [` {
+		t.Errorf("expected the registry to be left alone, got %q", data)
+	}
+}
+
+func TestRegistry_Invalidate(t *testing.T) {
+	data := `{"athletes": []}`
+	registry := New(func(context.Context) ([]byte, error) { return []byte(data), nil }, time.Hour)
+	if _, err := registry.Lookup(context.Background(), 1); !errors.Is(err, ErrUnknownAthlete) {
+		t.Fatalf("expected ErrUnknownAthlete, got %v", err)
+	}
+	data = `{"athletes": [{"id": 1}]}`
+	registry.Invalidate()
+	if _, err := registry.Lookup(context.Background(), 1); err != nil {
+		t.Errorf("expected the registry to be read again, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package athletes
+
+import (
+	"sync"
+
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+// Clients creates a Strava client per athlete, calling the API with one
+// app's credentials and each athlete's tokens. Clients are kept and
+// reused, so each athlete's access token is refreshed once rather than for
+// every event. It is safe for concurrent use.
+type Clients struct {
+	tokens       strava.TokenStore
+	clients      map[int64]*cachedClient
+	clientSecret string
+	opts         []strava.Option
+	clientID     int
+	mu           sync.Mutex
+}
+
+// cachedClient is a client with the refresh token it was created with.
+type cachedClient struct {
+	client       *strava.Client
+	refreshToken string
+}
+
+// NewClients creates clients for the app's credentials, sharing each
+// athlete's tokens through tokens if it isn't nil. opts apply to every
+// client.
+func NewClients(clientID int, clientSecret string, tokens strava.TokenStore, opts ...strava.Option) *Clients {
+	return &Clients{
+		tokens:       tokens,
+		clients:      map[int64]*cachedClient{},
+		clientSecret: clientSecret,
+		opts:         opts,
+		clientID:     clientID,
+	}
+}
+
+// Client returns the client for athlete. A new one is created when the
+// registry's refresh token changed since, e.g. after the athlete
+// reauthorized the app.
+func (c *Clients) Client(athlete Athlete) *strava.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.clients[athlete.ID]; ok && cached.refreshToken == athlete.RefreshToken {
+		return cached.client
+	}
+
+	opts := c.opts
+	if c.tokens != nil {
+		opts = append(append([]strava.Option{}, opts...), strava.WithTokenStore(c.tokens, athlete.ID))
+	}
+	client := strava.New(c.clientID, c.clientSecret, athlete.RefreshToken, opts...)
+	c.clients[athlete.ID] = &cachedClient{client: client, refreshToken: athlete.RefreshToken}
+	return client
+}
@@ -0,0 +1,60 @@
+package athletes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/config"
+)
+
+// Config locates the registry.
+type Config struct {
+	// Location is a "gs://bucket/object" URL or a local path; empty for a
+	// deployment serving the one athlete of its Strava credentials.
+	Location string
+	// CacheTTL is how long the registry is cached (see New).
+	CacheTTL time.Duration
+}
+
+// LoadConfig loads the registry settings from ATHLETE_REGISTRY and
+// ATHLETE_REGISTRY_CACHE_TTL.
+func LoadConfig() (*Config, error) {
+	ttl, err := time.ParseDuration(config.GetOrDefault("ATHLETE_REGISTRY_CACHE_TTL", DefaultCacheTTL.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ATHLETE_REGISTRY_CACHE_TTL: %v", err)
+	}
+	return &Config{Location: config.Get("ATHLETE_REGISTRY"), CacheTTL: ttl}, nil
+}
+
+// Enabled reports whether a registry is configured; a nil Config has none.
+func (c *Config) Enabled() bool {
+	return c != nil && c.Location != ""
+}
+
+// GCS returns the bucket and object of a gs:// Location, with ok false
+// for a local path.
+func (c *Config) GCS() (bucket, object string, ok bool) {
+	rest, ok := strings.CutPrefix(c.Location, "gs://")
+	if !ok {
+		return "", "", false
+	}
+	bucket, object, _ = strings.Cut(rest, "/")
+	return bucket, object, true
+}
+
+// Validate checks the settings, reporting every problem at once.
+func (c *Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	var errs []error
+	if bucket, object, ok := c.GCS(); ok && (bucket == "" || object == "") {
+		errs = append(errs, fmt.Errorf("invalid ATHLETE_REGISTRY %q (want gs://bucket/object or a path)", c.Location))
+	}
+	if c.CacheTTL < 0 {
+		errs = append(errs, fmt.Errorf("ATHLETE_REGISTRY_CACHE_TTL must not be negative, got %s", c.CacheTTL))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,12 @@
+module github.com/andy-esch/desirelines/packages/athletes
+
+go 1.25
+
+require (
+	github.com/andy-esch/desirelines/packages/config v0.0.0
+	github.com/andy-esch/desirelines/packages/strava v0.0.0
+)
+
+replace github.com/andy-esch/desirelines/packages/config => ../config
+
+replace github.com/andy-esch/desirelines/packages/strava => ../strava
@@ -0,0 +1,112 @@
+package athletes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Updater replaces the registry document with the one update returns for
+// the current document, nil if there is none. Implementations serialise
+// updates and may call update again with the newer document when another
+// writer got in first.
+type Updater func(ctx context.Context, update func(data []byte) ([]byte, error)) error
+
+// FileUpdater updates the registry in the file at path, replacing it
+// atomically. Updates are serialised within the process only, which is
+// enough for local development.
+func FileUpdater(path string) Updater {
+	var mu sync.Mutex
+	load := FileLoader(path)
+	return func(ctx context.Context, update func([]byte) ([]byte, error)) error {
+		mu.Lock()
+		defer mu.Unlock()
+		data, err := load(ctx)
+		if err != nil {
+			return err
+		}
+		data, err = update(data)
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(path, data)
+	}
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers never see a partial registry.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".athletes-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write athlete registry: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write athlete registry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write athlete registry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write athlete registry: %w", err)
+	}
+	return nil
+}
+
+// Register adds athlete to the registry document behind update. An athlete
+// already listed keeps their prefix and, if athlete has none, their name;
+// a new athlete without a prefix gets DefaultPrefix. It returns the entry
+// as registered.
+func Register(ctx context.Context, update Updater, athlete Athlete) (Athlete, error) {
+	var registered Athlete
+	err := update(ctx, func(data []byte) ([]byte, error) {
+		var list []Athlete
+		if data != nil {
+			var err error
+			if list, err = Parse(data); err != nil {
+				return nil, err
+			}
+		}
+
+		registered = athlete
+		found := false
+		for i, existing := range list {
+			if existing.ID != athlete.ID {
+				continue
+			}
+			registered.Prefix = existing.Prefix
+			if registered.Name == "" {
+				registered.Name = existing.Name
+			}
+			list[i] = registered
+			found = true
+		}
+		if !found {
+			if registered.Prefix == "" {
+				registered.Prefix = DefaultPrefix(athlete.ID)
+			} else if !strings.HasSuffix(registered.Prefix, "/") {
+				registered.Prefix += "/"
+			}
+			list = append(list, registered)
+		}
+
+		updated, err := json.MarshalIndent(document{Athletes: list}, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode athlete registry: %w", err)
+		}
+		// Reject an entry the registry couldn't be read with afterwards
+		if _, err := Parse(updated); err != nil {
+			return nil, err
+		}
+		return updated, nil
+	})
+	if err != nil {
+		return Athlete{}, fmt.Errorf("failed to register athlete %d: %w", athlete.ID, err)
+	}
+	return registered, nil
+}
@@ -0,0 +1,129 @@
+package athletes
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is how long a loaded registry is used before it is read
+// again, so newly registered athletes are picked up without a restart.
+const DefaultCacheTTL = time.Minute
+
+// Loader reads the registry document, returning nil data if there is none.
+type Loader func(ctx context.Context) ([]byte, error)
+
+// FileLoader reads the registry from the file at path.
+func FileLoader(path string) Loader {
+	return func(context.Context) ([]byte, error) {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read athlete registry: %w", err)
+		}
+		return data, nil
+	}
+}
+
+// Registry looks athletes up in the registry document, reading it again
+// once it is older than the cache TTL. A missing document registers
+// nobody. If reading it again fails, the athletes already loaded are used
+// until the next attempt after another TTL. It is safe for concurrent use.
+type Registry struct {
+	loadedAt time.Time
+	load     Loader
+	now      func() time.Time
+	athletes map[int64]Athlete
+	ttl      time.Duration
+	mu       sync.Mutex
+}
+
+// New creates a registry read with load and cached for ttl; 0 reads it
+// for every lookup.
+func New(load Loader, ttl time.Duration) *Registry {
+	return &Registry{load: load, ttl: ttl, now: time.Now}
+}
+
+// Lookup returns the athlete with athleteID, or ErrUnknownAthlete.
+func (r *Registry) Lookup(ctx context.Context, athleteID int64) (Athlete, error) {
+	athletes, err := r.current(ctx)
+	if err != nil {
+		return Athlete{}, err
+	}
+	athlete, ok := athletes[athleteID]
+	if !ok {
+		return Athlete{}, fmt.Errorf("%w: %d", ErrUnknownAthlete, athleteID)
+	}
+	return athlete, nil
+}
+
+// Athletes returns every registered athlete in ascending ID order.
+func (r *Registry) Athletes(ctx context.Context) ([]Athlete, error) {
+	athletes, err := r.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Athlete, 0, len(athletes))
+	for _, athlete := range athletes {
+		list = append(list, athlete)
+	}
+	slices.SortFunc(list, func(a, b Athlete) int { return cmp.Compare(a.ID, b.ID) })
+	return list, nil
+}
+
+// Invalidate makes the next lookup read the registry again, e.g. after
+// Register changed it.
+func (r *Registry) Invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loadedAt = time.Time{}
+}
+
+// current returns the cached athletes, reloading them when they are stale.
+func (r *Registry) current(ctx context.Context) (map[int64]Athlete, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	if r.athletes != nil && now.Sub(r.loadedAt) < r.ttl {
+		return r.athletes, nil
+	}
+
+	athletes, err := r.read(ctx)
+	if err != nil {
+		if r.athletes == nil {
+			return nil, err
+		}
+		// Keep serving the last good copy rather than dropping every event
+		r.loadedAt = now
+		return r.athletes, nil
+	}
+	r.athletes = athletes
+	r.loadedAt = now
+	return athletes, nil
+}
+
+func (r *Registry) read(ctx context.Context) (map[int64]Athlete, error) {
+	data, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	athletes := map[int64]Athlete{}
+	if data == nil {
+		return athletes, nil
+	}
+	list, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, athlete := range list {
+		athletes[athlete.ID] = athlete
+	}
+	return athletes, nil
+}
@@ -0,0 +1,182 @@
+// Package config resolves service and tool settings by name from, in order
+// of precedence:
+//
+//  1. command-line flags the user set (see BindFlags),
+//  2. environment variables,
+//  3. an optional config file, named by CONFIG_FILE or passed to Setup,
+//  4. the caller's default.
+//
+// Settings are named like environment variables (GCP_PROJECT_ID,
+// RATE_LIMIT, ...) in every source, so a value can move between them
+// without renaming. A config file is either a JSON object of names to
+// values or, for any other extension, KEY=VALUE lines as in a .env file.
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileEnv names the config file read when Setup isn't given a path.
+const FileEnv = "CONFIG_FILE"
+
+// Loader resolves settings from flags, the environment and a config file.
+type Loader struct {
+	lookupEnv func(string) (string, bool)
+	overrides map[string]string
+	file      map[string]string
+	mu        sync.RWMutex
+}
+
+// New creates a loader reading the config file at path, or no file when
+// path is empty.
+func New(path string) (*Loader, error) {
+	l := &Loader{lookupEnv: os.LookupEnv, overrides: map[string]string{}}
+	if path == "" {
+		return l, nil
+	}
+	file, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	l.file = file
+	return l, nil
+}
+
+// Lookup returns the value of key from the highest-precedence source that
+// sets it. Empty values count as unset, as with getEnvOrDefault, so an
+// empty variable doesn't hide the config file.
+func (l *Loader) Lookup(key string) (string, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if value := l.overrides[key]; value != "" {
+		return value, true
+	}
+	if value, _ := l.lookupEnv(key); value != "" {
+		return value, true
+	}
+	if value := l.file[key]; value != "" {
+		return value, true
+	}
+	return "", false
+}
+
+// Get returns the value of key, or "" when no source sets it.
+func (l *Loader) Get(key string) string {
+	value, _ := l.Lookup(key)
+	return value
+}
+
+// GetOrDefault returns the value of key, or defaultValue when no source
+// sets it.
+func (l *Loader) GetOrDefault(key, defaultValue string) string {
+	if value, ok := l.Lookup(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// Duration parses the value of key, returning defaultValue when no source
+// sets it.
+func (l *Loader) Duration(key string, defaultValue time.Duration) (time.Duration, error) {
+	value, ok := l.Lookup(key)
+	if !ok {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return defaultValue, fmt.Errorf("invalid %s: %q", key, value)
+	}
+	return d, nil
+}
+
+// Set overrides key ahead of every other source.
+func (l *Loader) Set(key, value string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.overrides[key] = value
+}
+
+// BindFlags overrides, for each flag in keys that was set on the parsed
+// fs, the setting it names. Flags left at their defaults don't override
+// anything, so the environment and config file still apply.
+func (l *Loader) BindFlags(fs *flag.FlagSet, keys map[string]string) {
+	fs.Visit(func(f *flag.Flag) {
+		if key, ok := keys[f.Name]; ok {
+			l.Set(key, f.Value.String())
+		}
+	})
+}
+
+// readFile parses a JSON or KEY=VALUE config file.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	if filepath.Ext(path) == ".json" {
+		return parseJSON(data)
+	}
+	return parseDotenv(data)
+}
+
+// parseJSON reads a JSON object, stringifying numbers and booleans so
+// {"RATE_LIMIT": 100} means the same as RATE_LIMIT=100.
+func parseJSON(data []byte) (map[string]string, error) {
+	var raw map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case string:
+			values[key] = v
+		case json.Number, bool:
+			values[key] = fmt.Sprint(v)
+		case nil:
+		default:
+			return nil, fmt.Errorf("config file: %s must be a string, number or boolean", key)
+		}
+	}
+	return values, nil
+}
+
+// parseDotenv reads KEY=VALUE lines, skipping blanks and # comments and
+// unquoting quoted values.
+func parseDotenv(data []byte) (map[string]string, error) {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("config file line %d: expected KEY=VALUE", n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		} else if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return values, nil
+}
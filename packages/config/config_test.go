@@ -0,0 +1,132 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoader_Precedence(t *testing.T) {
+	path := writeFile(t, "service.env", `
+# Comments and blank lines are skipped
+GCP_PROJECT_ID=file-project
+export GCP_PUBSUB_TOPIC="file-topic"
+RATE_LIMIT=50   # inline comment
+LOG_LEVEL=DEBUG
+`)
+	t.Setenv("GCP_PUBSUB_TOPIC", "env-topic")
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("PORT", "9090")
+
+	loader, err := New(path)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("port", "8080", "")
+	fs.String("project", "", "")
+	if err := fs.Parse([]string{"-port", "7070"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	loader.BindFlags(fs, map[string]string{"port": "PORT", "project": "GCP_PROJECT_ID"})
+
+	tests := map[string]string{
+		"PORT":             "7070",         // flag beats env
+		"GCP_PUBSUB_TOPIC": "env-topic",    // env beats file
+		"GCP_PROJECT_ID":   "file-project", // unset flag doesn't override
+		"RATE_LIMIT":       "50",
+		"LOG_LEVEL":        "DEBUG", // empty env doesn't hide the file
+	}
+	for key, want := range tests {
+		if got := loader.Get(key); got != want {
+			t.Errorf("Get(%q) = %q, want %q", key, got, want)
+		}
+	}
+	if got := loader.GetOrDefault("UNSET_KEY", "fallback"); got != "fallback" {
+		t.Errorf("GetOrDefault returned %q, want the default", got)
+	}
+}
+
+func TestLoader_JSONFile(t *testing.T) {
+	path := writeFile(t, "service.json", `{"RATE_LIMIT": 100, "ACCESS_LOG": false, "CACHE_TTL": "5m", "UNUSED": null}`)
+
+	loader, err := New(path)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if got := loader.Get("RATE_LIMIT"); got != "100" {
+		t.Errorf("RATE_LIMIT = %q, want %q", got, "100")
+	}
+	if got := loader.Get("ACCESS_LOG"); got != "false" {
+		t.Errorf("ACCESS_LOG = %q, want %q", got, "false")
+	}
+	if d, err := loader.Duration("CACHE_TTL", 0); err != nil || d != 5*time.Minute {
+		t.Errorf("Duration(CACHE_TTL) = %v, %v", d, err)
+	}
+}
+
+func TestNew_InvalidFiles(t *testing.T) {
+	for name, content := range map[string]string{
+		"nested.json": `{"FILTERS": {"owner_id": 1}}`,
+		"broken.json": `{`,
+		"broken.env":  "NOT A SETTING",
+	} {
+		if _, err := New(writeFile(t, name, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := New(filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestLoader_Duration(t *testing.T) {
+	t.Setenv("SHUTDOWN_TIMEOUT", "soon")
+	loader, _ := New("")
+
+	d, err := loader.Duration("SHUTDOWN_TIMEOUT", 10*time.Second)
+	if err == nil {
+		t.Error("expected error for an invalid duration")
+	}
+	if d != 10*time.Second {
+		t.Errorf("expected the default on error, got %s", d)
+	}
+	if d, err := loader.Duration("UNSET_TIMEOUT", time.Second); err != nil || d != time.Second {
+		t.Errorf("Duration for an unset key = %v, %v", d, err)
+	}
+}
+
+func TestSetupAndLoad(t *testing.T) {
+	t.Cleanup(func() { _ = Setup("") })
+	path := writeFile(t, "service.env", "DISPATCHER_TEST_SETTING=from-file\n")
+	t.Setenv(FileEnv, filepath.Join(t.TempDir(), "missing.env"))
+
+	if err := Setup(path); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	// Load keeps the file passed to Setup rather than rereading CONFIG_FILE
+	if err := Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := Get("DISPATCHER_TEST_SETTING"); got != "from-file" {
+		t.Errorf("Get = %q, want the value from the Setup file", got)
+	}
+
+	if err := Setup(""); err == nil {
+		t.Error("expected Setup to fail for a missing CONFIG_FILE")
+	}
+	if err := Load(); err == nil {
+		t.Error("expected Load to report the failed read")
+	}
+}
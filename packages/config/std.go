@@ -0,0 +1,98 @@
+package config
+
+import (
+	"flag"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	std   = &Loader{lookupEnv: os.LookupEnv, overrides: map[string]string{}}
+	stdMu sync.Mutex
+	// stdLoaded records whether the process-wide config file was read,
+	// by Setup or on first use; stdErr is why reading it failed.
+	stdLoaded bool
+	stdErr    error
+)
+
+// Setup reads the process-wide config file at path, or the one named by
+// CONFIG_FILE when path is empty, replacing any read before. Programs call
+// it once at startup, after parsing a -config flag, so a bad file fails
+// fast.
+func Setup(path string) error {
+	stdMu.Lock()
+	defer stdMu.Unlock()
+	if path == "" {
+		path = os.Getenv(FileEnv)
+	}
+	stdLoaded = true
+	stdErr = std.readFile(path)
+	return stdErr
+}
+
+// Load reads CONFIG_FILE unless a file was already read, and returns the
+// error reading it, which Get and the other lookups only log. Libraries
+// call it from their constructors to fail on a bad file without
+// discarding one the program passed to Setup.
+func Load() error {
+	stdMu.Lock()
+	defer stdMu.Unlock()
+	std.ensureLoaded()
+	return stdErr
+}
+
+// ensureLoaded reads CONFIG_FILE the first time; the caller holds stdMu.
+func (l *Loader) ensureLoaded() {
+	if stdLoaded {
+		return
+	}
+	stdLoaded = true
+	if stdErr = l.readFile(os.Getenv(FileEnv)); stdErr != nil {
+		log.Printf("Ignoring %s: %v", FileEnv, stdErr)
+	}
+}
+
+// readFile replaces the loader's config file with the one at path.
+func (l *Loader) readFile(path string) error {
+	loaded, err := New(path)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.file = loaded.file
+	l.mu.Unlock()
+	return nil
+}
+
+// standard returns the process-wide loader, reading CONFIG_FILE the first
+// time if Setup wasn't called.
+func standard() *Loader {
+	stdMu.Lock()
+	defer stdMu.Unlock()
+	std.ensureLoaded()
+	return std
+}
+
+// Lookup calls Lookup on the process-wide loader.
+func Lookup(key string) (string, bool) { return standard().Lookup(key) }
+
+// Get calls Get on the process-wide loader.
+func Get(key string) string { return standard().Get(key) }
+
+// GetOrDefault calls GetOrDefault on the process-wide loader.
+func GetOrDefault(key, defaultValue string) string {
+	return standard().GetOrDefault(key, defaultValue)
+}
+
+// Duration calls Duration on the process-wide loader.
+func Duration(key string, defaultValue time.Duration) (time.Duration, error) {
+	return standard().Duration(key, defaultValue)
+}
+
+// Set calls Set on the process-wide loader.
+func Set(key, value string) { standard().Set(key, value) }
+
+// BindFlags calls BindFlags on the process-wide loader.
+func BindFlags(fs *flag.FlagSet, keys map[string]string) { standard().BindFlags(fs, keys) }
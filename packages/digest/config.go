@@ -0,0 +1,78 @@
+package digest
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	// Embedded so ATHLETE_TIMEZONE resolves in minimal images too
+	_ "time/tzdata"
+
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/config"
+)
+
+// DefaultTimeZone is the athlete's time zone, which decides when a week
+// is over, as for the processor.
+const DefaultTimeZone = "America/New_York"
+
+// Config holds all configuration for the generator.
+type Config struct {
+	// TimeZone decides which week was last week.
+	TimeZone *time.Location
+	// Athletes locates the athlete registry; when it is enabled every
+	// registered athlete's digest is written under their prefix,
+	// otherwise only that of the aggregates at the bucket root.
+	Athletes *athletes.Config
+	// BucketName is the bucket the API gateway serves aggregates from.
+	BucketName string
+	LogLevel   string
+	// AthleteID owns the goals read without a registry; without it the
+	// digest has no goals.
+	AthleteID int64
+}
+
+// LoadConfig loads configuration from environment variables.
+func LoadConfig() (*Config, error) {
+	var errs []error
+
+	timeZone, err := time.LoadLocation(config.GetOrDefault("ATHLETE_TIMEZONE", DefaultTimeZone))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid ATHLETE_TIMEZONE: %v", err))
+	}
+	registry, err := athletes.LoadConfig()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	var athleteID int64
+	if v := config.Get("STRAVA_ATHLETE_ID"); v != "" {
+		if athleteID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			errs = append(errs, fmt.Errorf("invalid STRAVA_ATHLETE_ID: %q", v))
+		}
+	}
+
+	cfg := &Config{
+		TimeZone:   timeZone,
+		Athletes:   registry,
+		BucketName: config.Get("GCP_BUCKET_NAME"),
+		LogLevel:   config.GetOrDefault("LOG_LEVEL", "INFO"),
+		AthleteID:  athleteID,
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks that the settings needed to write digests are present,
+// reporting every problem at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.BucketName == "" {
+		errs = append(errs, errors.New("GCP_BUCKET_NAME is required"))
+	}
+	if err := c.Athletes.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,38 @@
+package digest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/athletes"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("GCP_BUCKET_NAME", "desirelines-data")
+	t.Setenv("STRAVA_ATHLETE_ID", "12345")
+	t.Setenv("ATHLETE_TIMEZONE", "Europe/Berlin")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.BucketName != "desirelines-data" || cfg.AthleteID != 12345 || cfg.TimeZone.String() != "Europe/Berlin" {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	t.Setenv("STRAVA_ATHLETE_ID", "athlete")
+	t.Setenv("ATHLETE_TIMEZONE", "Mars/Olympus")
+	_, err = LoadConfig()
+	if err == nil || !strings.Contains(err.Error(), "STRAVA_ATHLETE_ID") || !strings.Contains(err.Error(), "ATHLETE_TIMEZONE") {
+		t.Errorf("expected both invalid settings reported, got %v", err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := (&Config{BucketName: "b", Athletes: &athletes.Config{}}).Validate(); err != nil {
+		t.Errorf("expected valid config, got %v", err)
+	}
+	err := (&Config{Athletes: &athletes.Config{Location: "gs://bucket"}}).Validate()
+	if err == nil || !strings.Contains(err.Error(), "GCP_BUCKET_NAME") || !strings.Contains(err.Error(), "ATHLETE_REGISTRY") {
+		t.Errorf("expected the bucket and registry reported, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+// Package digest writes weekly digests of the stored aggregates. Run on a
+// schedule, it reads each athlete's summary_activities.json and goals, and
+// writes the last week's distance, elevation and goal progress to
+// digests/{year}/{week}.json (see aggregation.Digest), which the API
+// gateway serves for emails and widgets.
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/athletes"
+)
+
+// Outcomes of writing an athlete's digest.
+const (
+	OutcomeWritten = "written"
+	OutcomeFailed  = "failed"
+)
+
+// Store reads and writes the aggregate blobs.
+type Store interface {
+	// Read returns the object's content, or nil if it doesn't exist.
+	Read(ctx context.Context, name string) ([]byte, error)
+	Write(ctx context.Context, name string, data []byte) error
+	Close() error
+}
+
+// AthleteSource lists the athletes whose digests are written.
+type AthleteSource func(ctx context.Context) ([]athletes.Athlete, error)
+
+// StaticAthletes is a source of a fixed list of athletes.
+func StaticAthletes(list ...athletes.Athlete) AthleteSource {
+	return func(context.Context) ([]athletes.Athlete, error) {
+		return list, nil
+	}
+}
+
+// Result is the outcome for one athlete.
+type Result struct {
+	Outcome   string `json:"outcome"`
+	Blob      string `json:"blob,omitempty"`
+	Error     string `json:"error,omitempty"`
+	AthleteID int64  `json:"athlete_id"`
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	Results []Result `json:"results"`
+	Year    int      `json:"year"`
+	Week    int      `json:"week"`
+	Written int      `json:"written"`
+	Failed  int      `json:"failed"`
+}
+
+// Generator writes the digests of the athletes from a source.
+type Generator struct {
+	store    Store
+	athletes AthleteSource
+	// location decides which week was last week
+	location *time.Location
+	now      func() time.Time
+}
+
+// NewGenerator creates a generator reading and writing blobs in store,
+// with weeks ending at midnight in location.
+func NewGenerator(store Store, source AthleteSource, location *time.Location) *Generator {
+	return &Generator{store: store, athletes: source, location: location, now: time.Now}
+}
+
+// LastWeek returns the ISO week before the current one, the latest whose
+// days are all over.
+func (g *Generator) LastWeek() (year, week int) {
+	return g.now().In(g.location).AddDate(0, 0, -7).ISOWeek()
+}
+
+// Run writes every athlete's digest of ISO week of year. An athlete
+// failing doesn't stop the others; their failures are in the report. The
+// error is only set for a week that doesn't exist or when the athletes
+// can't be listed.
+func (g *Generator) Run(ctx context.Context, year, week int) (Report, error) {
+	if _, err := aggregation.WeekStart(year, week); err != nil {
+		return Report{}, err
+	}
+	list, err := g.athletes(ctx)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to list athletes: %w", err)
+	}
+
+	report := Report{Year: year, Week: week, Results: make([]Result, 0, len(list))}
+	for _, athlete := range list {
+		result := Result{AthleteID: athlete.ID, Blob: athlete.Blob(aggregation.DigestBlob(year, week)), Outcome: OutcomeWritten}
+		if err := g.write(ctx, athlete, year, week); err != nil {
+			result.Outcome = OutcomeFailed
+			result.Error = err.Error()
+			report.Failed++
+			Logger.ErrorContext(ctx, "Failed to write digest", "athlete_id", athlete.ID, "year", year, "week", week, "error", err)
+		} else {
+			report.Written++
+			Logger.InfoContext(ctx, "Wrote digest", "athlete_id", athlete.ID, "blob", result.Blob)
+		}
+		digests.WithLabelValues(result.Outcome).Inc()
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// write builds and writes one athlete's digest from the summaries and
+// goals of the calendar years the week spans.
+func (g *Generator) write(ctx context.Context, athlete athletes.Athlete, year, week int) error {
+	start, _ := aggregation.WeekStart(year, week)
+	summaries := map[int]aggregation.Summary{}
+	goals := map[int][]aggregation.Goal{}
+	for _, calendarYear := range []int{start.Year(), start.AddDate(0, 0, 6).Year()} {
+		if _, ok := summaries[calendarYear]; ok {
+			continue
+		}
+		summary, err := g.summary(ctx, athlete, calendarYear)
+		if err != nil {
+			return err
+		}
+		summaries[calendarYear] = summary
+		goals[calendarYear] = g.goals(ctx, athlete, calendarYear)
+	}
+
+	digest, err := aggregation.BuildDigest(summaries, goals, year, week)
+	if err != nil {
+		return err
+	}
+	digest.GeneratedAt = g.now().UTC()
+	data, err := json.Marshal(digest)
+	if err != nil {
+		return fmt.Errorf("failed to encode digest: %w", err)
+	}
+	return g.store.Write(ctx, athlete.Blob(aggregation.DigestBlob(year, week)), data)
+}
+
+// summary returns the athlete's summary of year, empty if they have none.
+func (g *Generator) summary(ctx context.Context, athlete athletes.Athlete, year int) (aggregation.Summary, error) {
+	name := athlete.Blob(aggregation.SummaryBlob(year))
+	data, err := g.store.Read(ctx, name)
+	if err != nil || data == nil {
+		return aggregation.Summary{}, err
+	}
+	var summary aggregation.Summary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return summary, nil
+}
+
+// goals returns the athlete's goals for year, or none if the athlete is
+// unknown or has set none. Goals that can't be read are logged and
+// ignored, so the digest still has the week's totals.
+func (g *Generator) goals(ctx context.Context, athlete athletes.Athlete, year int) []aggregation.Goal {
+	if athlete.ID == 0 {
+		return nil
+	}
+	name := athlete.Blob(aggregation.GoalsBlob(athlete.ID, year))
+	data, err := g.store.Read(ctx, name)
+	if err != nil {
+		Logger.WarnContext(ctx, "Ignoring unreadable goals", "blob", name, "error", err)
+		return nil
+	}
+	if data == nil {
+		return nil
+	}
+	var goals aggregation.GoalSet
+	if err := json.Unmarshal(data, &goals); err != nil {
+		Logger.WarnContext(ctx, "Ignoring unparseable goals", "blob", name, "error", err)
+		return nil
+	}
+	return goals.Goals
+}
@@ -0,0 +1,129 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/athletes"
+)
+
+// memoryStore keeps blobs in a map, failing writes under failPrefix.
+type memoryStore struct {
+	blobs      map[string][]byte
+	failPrefix string
+}
+
+func (s *memoryStore) Read(_ context.Context, name string) ([]byte, error) {
+	return s.blobs[name], nil
+}
+
+func (s *memoryStore) Write(_ context.Context, name string, data []byte) error {
+	if s.failPrefix != "" && len(name) >= len(s.failPrefix) && name[:len(s.failPrefix)] == s.failPrefix {
+		return errors.New("write failed")
+	}
+	s.blobs[name] = data
+	return nil
+}
+
+func (s *memoryStore) Close() error { return nil }
+
+// newTestStore holds athlete 1's aggregates at the bucket root and
+// athlete 2's under their prefix.
+func newTestStore() *memoryStore {
+	return &memoryStore{blobs: map[string][]byte{
+		"activities/2025/summary_activities.json": []byte(`{
+			"2025-02-09": {"activity_ids": [1], "distance_miles": 30},
+			"2025-02-10": {"activity_ids": [2], "distance_miles": 20, "elevation_feet": 400},
+			"2025-02-12": {"activity_ids": [3], "distance_miles": 10, "elevation_feet": 100}
+		}`),
+		"goals/1/2025.json": []byte(`{"goals": [{"id": "year", "target": 1000}]}`),
+		"athletes/2/activities/2025/summary_activities.json": []byte(`{"2025-02-11": {"activity_ids": [4], "distance_miles": 5}}`),
+		"athletes/2/goals/2/2025.json":                       []byte(`not json`),
+	}}
+}
+
+func newTestGenerator(store Store, list ...athletes.Athlete) *Generator {
+	g := NewGenerator(store, StaticAthletes(list...), time.UTC)
+	g.now = func() time.Time { return time.Date(2025, time.February, 18, 6, 0, 0, 0, time.UTC) }
+	return g
+}
+
+func readDigest(t *testing.T, store *memoryStore, name string) aggregation.Digest {
+	t.Helper()
+	var digest aggregation.Digest
+	if err := json.Unmarshal(store.blobs[name], &digest); err != nil {
+		t.Fatalf("failed to read %s: %v", name, err)
+	}
+	return digest
+}
+
+func TestGenerator_LastWeek(t *testing.T) {
+	// Tuesday of week 8, and Sunday night of week 7 in New York
+	g := newTestGenerator(newTestStore())
+	if year, week := g.LastWeek(); year != 2025 || week != 7 {
+		t.Errorf("expected week 7 of 2025, got %d of %d", week, year)
+	}
+	newYork, _ := time.LoadLocation("America/New_York")
+	g.location = newYork
+	g.now = func() time.Time { return time.Date(2025, time.February, 17, 3, 0, 0, 0, time.UTC) }
+	if year, week := g.LastWeek(); year != 2025 || week != 6 {
+		t.Errorf("expected week 6 of 2025 while week 7 isn't over locally, got %d of %d", week, year)
+	}
+}
+
+func TestGenerator_Run(t *testing.T) {
+	store := newTestStore()
+	report, err := newTestGenerator(store, athletes.Athlete{ID: 1}, athletes.Athlete{ID: 2, Prefix: "athletes/2/"}).Run(context.Background(), 2025, 7)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Written != 2 || report.Failed != 0 || report.Results[1].Blob != "athletes/2/digests/2025/07.json" {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	digest := readDigest(t, store, "digests/2025/07.json")
+	if digest.DistanceMiles != 30 || digest.ElevationFeet != 500 || digest.Activities != 2 || digest.GeneratedAt.IsZero() {
+		t.Errorf("unexpected digest %+v", digest)
+	}
+	if len(digest.Goals) != 1 || digest.Goals[0].Progress != 60 || digest.Goals[0].Delta != 30 {
+		t.Errorf("expected the yearly goal's progress, got %+v", digest.Goals)
+	}
+
+	// Unparseable goals leave the week's totals
+	digest = readDigest(t, store, "athletes/2/digests/2025/07.json")
+	if digest.DistanceMiles != 5 || len(digest.Goals) != 0 {
+		t.Errorf("unexpected digest %+v", digest)
+	}
+}
+
+func TestGenerator_RunWithoutData(t *testing.T) {
+	store := &memoryStore{blobs: map[string][]byte{}}
+	report, err := newTestGenerator(store, athletes.Athlete{}).Run(context.Background(), 2025, 1)
+	if err != nil || report.Written != 1 {
+		t.Fatalf("expected an empty digest to be written, got %+v, %v", report, err)
+	}
+	if digest := readDigest(t, store, "digests/2025/01.json"); digest.StartDate != "2024-12-30" || digest.Activities != 0 {
+		t.Errorf("unexpected digest %+v", digest)
+	}
+}
+
+func TestGenerator_RunFailures(t *testing.T) {
+	store := newTestStore()
+	store.failPrefix = "athletes/2/"
+	store.blobs["activities/2025/summary_activities.json"] = []byte(`[]`)
+	report, err := newTestGenerator(store, athletes.Athlete{ID: 1}, athletes.Athlete{ID: 2, Prefix: "athletes/2/"}, athletes.Athlete{ID: 3, Prefix: "athletes/3/"}).Run(context.Background(), 2025, 7)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Failed != 2 || report.Written != 1 || report.Results[0].Outcome != OutcomeFailed || report.Results[2].Outcome != OutcomeWritten {
+		t.Errorf("expected the unparseable summary and failed write reported, got %+v", report)
+	}
+
+	if _, err := newTestGenerator(store).Run(context.Background(), 2025, 53); err == nil {
+		t.Error("expected an error for a week that doesn't exist")
+	}
+}
@@ -0,0 +1,165 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/config"
+)
+
+// Handler runs the generator for Cloud Scheduler: a POST writes last
+// week's digests and answers with the report.
+type Handler struct {
+	generator *Generator
+	// store is nil when the generator was passed in
+	store Store
+}
+
+// NewHandler creates a handler from the environment.
+func NewHandler(ctx context.Context) (*Handler, error) {
+	if err := config.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", config.FileEnv, err)
+	}
+	cfg, err := LoadConfig()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	if err := SetLogLevel(cfg.LogLevel); err != nil {
+		return nil, err
+	}
+
+	store, err := NewGCSStore(ctx, cfg.BucketName)
+	if err != nil {
+		return nil, err
+	}
+	source := StaticAthletes(athletes.Athlete{ID: cfg.AthleteID})
+	if cfg.Athletes.Enabled() {
+		load := athletes.FileLoader(cfg.Athletes.Location)
+		if bucket, object, ok := cfg.Athletes.GCS(); ok {
+			load = registryLoader(store.client.Bucket(bucket).Object(object))
+		}
+		// Every run reads the registry afresh
+		source = athletes.New(load, 0).Athletes
+	}
+
+	Logger.Info("Digest generator initialized", "bucket", cfg.BucketName, "athlete_registry", cfg.Athletes.Location, "time_zone", cfg.TimeZone.String())
+	return &Handler{generator: NewGenerator(store, source, cfg.TimeZone), store: store}, nil
+}
+
+// registryLoader reads the athlete registry from a Cloud Storage object.
+func registryLoader(handle *storage.ObjectHandle) athletes.Loader {
+	return func(ctx context.Context) ([]byte, error) {
+		reader, err := handle.NewReader(ctx)
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read athlete registry: %w", err)
+		}
+		defer func() { _ = reader.Close() }()
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read athlete registry: %w", err)
+		}
+		return data, nil
+	}
+}
+
+// NewHandlerWithGenerator creates a handler around an existing generator
+// (useful for testing).
+func NewHandlerWithGenerator(generator *Generator) *Handler {
+	return &Handler{generator: generator}
+}
+
+// Close releases the store.
+func (h *Handler) Close() error {
+	if h.store == nil {
+		return nil
+	}
+	return h.store.Close()
+}
+
+// ServeHTTP writes the digests on POST, serves metrics on /metrics and
+// answers liveness probes on /live. A POST writes last week's digests, or
+// with ?year=2025&week=7 those of an earlier ISO week, e.g. after a
+// backfill. A run in which any athlete failed is answered with a 500, so
+// Cloud Scheduler records it as failed and retries.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/metrics":
+		MetricsHandler().ServeHTTP(w, r)
+		return
+	case r.URL.Path == "/live":
+		writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+		return
+	case r.Method != http.MethodPost:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	year, week := h.generator.LastWeek()
+	if query := r.URL.Query(); query.Has("year") || query.Has("week") {
+		var err error
+		year, week, err = parseWeek(query.Get("year"), query.Get("week"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	start := time.Now()
+	report, err := h.generator.Run(r.Context(), year, week)
+	lastRun.SetToCurrentTime()
+	switch {
+	case err != nil:
+		runs.WithLabelValues("failed").Inc()
+		Logger.ErrorContext(r.Context(), "Digest run failed", "year", year, "week", week, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Digest run failed"})
+		return
+	case report.Failed > 0:
+		runs.WithLabelValues("partial").Inc()
+		Logger.ErrorContext(r.Context(), "Some digests failed to write", "year", year, "week", week, "written", report.Written, "failed", report.Failed)
+		writeJSON(w, http.StatusInternalServerError, report)
+		return
+	}
+	runs.WithLabelValues("ok").Inc()
+	Logger.InfoContext(r.Context(), "Wrote digests", "year", year, "week", week, "written", report.Written, "took", time.Since(start).String())
+	writeJSON(w, http.StatusOK, report)
+}
+
+// parseWeek parses the year and week query parameters, which must be
+// given together and name an ISO week.
+func parseWeek(yearParam, weekParam string) (year, week int, err error) {
+	year, err = strconv.Atoi(yearParam)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid year: %q", yearParam)
+	}
+	week, err = strconv.Atoi(weekParam)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid week: %q", weekParam)
+	}
+	if _, err := aggregation.WeekStart(year, week); err != nil {
+		return 0, 0, err
+	}
+	return year, week, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		Logger.Error("Failed to encode response", "error", err)
+	}
+}
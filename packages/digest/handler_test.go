@@ -0,0 +1,67 @@
+package digest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/athletes"
+)
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	store := newTestStore()
+	handler := NewHandlerWithGenerator(newTestGenerator(store, athletes.Athlete{ID: 1}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var report Report
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil || report.Written != 1 || report.Week != 7 {
+		t.Errorf("expected last week's digest, got %s", rr.Body)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/?year=2025&week=6", nil))
+	if _, ok := store.blobs["digests/2025/06.json"]; rr.Code != http.StatusOK || !ok {
+		t.Errorf("expected the requested week's digest, got %d: %s", rr.Code, rr.Body)
+	}
+
+	for _, query := range []string{"?year=2025", "?year=2025&week=60", "?year=last&week=1"} {
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("POST", "/"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", query, rr.Code)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(rr.Body.String(), `digest_athletes_total{outcome="written"}`) ||
+		!strings.Contains(rr.Body.String(), `digest_runs_total{result="ok"}`) {
+		t.Errorf("expected digest metrics, got %s", rr.Body)
+	}
+}
+
+func TestHandler_ServeHTTPPartialFailure(t *testing.T) {
+	store := newTestStore()
+	store.failPrefix = "digests/"
+	rr := httptest.NewRecorder()
+	NewHandlerWithGenerator(newTestGenerator(store, athletes.Athlete{ID: 1})).ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 for a failed digest, got %d", rr.Code)
+	}
+	var report Report
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil || report.Failed != 1 {
+		t.Errorf("expected the failure reported, got %s", rr.Body)
+	}
+}
@@ -0,0 +1,38 @@
+package digest
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsRegistry holds the generator's metrics, served on /metrics.
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	digests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "digest_athletes_total",
+		Help: "Athlete digests generated, by outcome (written or failed).",
+	}, []string{"outcome"})
+
+	runs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "digest_runs_total",
+		Help: "Digest runs, by result (ok, partial when some athletes failed, or failed).",
+	}, []string{"result"})
+
+	lastRun = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "digest_last_run_timestamp_seconds",
+		Help: "Unix time the last digest run finished.",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(digests, runs, lastRun)
+}
+
+// MetricsHandler serves the generator's metrics in the Prometheus text
+// format.
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
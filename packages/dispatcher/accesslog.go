@@ -0,0 +1,86 @@
+package dispatcher
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// logLabelsKey is Cloud Logging's special field for entry labels.
+const logLabelsKey = "logging.googleapis.com/labels"
+
+// accessLogLabel tags access log entries, so a log filter like
+// labels.log_type="access" separates them from business logs.
+var accessLogLabel = slog.Any(logLabelsKey, map[string]string{"log_type": "access"})
+
+// statusRecorder remembers the status code and body size written through
+// it, and with body set the first maxLoggedPayloadBytes of the body.
+type statusRecorder struct {
+	http.ResponseWriter
+	body   *bytes.Buffer
+	status int
+	size   int
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	if r.body != nil {
+		r.body.Write(b[:min(n, max(0, maxLoggedPayloadBytes-r.body.Len()))])
+	}
+	r.size += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// logAccess writes one AccessLogger entry for a served request, with
+// Cloud Logging's httpRequest fields so request latency and status can be
+// charted from the logs. 5xx responses are logged as errors and 4xx as
+// warnings.
+func (h *Handler) logAccess(r *http.Request, recorder *statusRecorder, correlationID string, start time.Time) {
+	status := recorder.status
+	if status == 0 {
+		// Nothing was written; net/http sends an empty 200
+		status = http.StatusOK
+	}
+	level := slog.LevelInfo
+	switch {
+	case status >= http.StatusInternalServerError:
+		level = slog.LevelError
+	case status >= http.StatusBadRequest:
+		level = slog.LevelWarn
+	}
+
+	AccessLogger.LogAttrs(r.Context(), level, fmt.Sprintf("%s %s %d", r.Method, r.URL.Path, status),
+		slog.Group("httpRequest",
+			slog.String("requestMethod", r.Method),
+			slog.String("requestUrl", redactURL(r.URL, h.config.RedactFields)),
+			slog.Int("status", status),
+			slog.String("responseSize", strconv.Itoa(recorder.size)),
+			slog.String("userAgent", r.UserAgent()),
+			slog.String("remoteIp", h.clientIP(r)),
+			slog.String("protocol", r.Proto),
+			slog.String("latency", fmt.Sprintf("%.9fs", time.Since(start).Seconds())),
+		),
+		slog.String("correlation_id", correlationID),
+		accessLogLabel,
+	)
+}
@@ -0,0 +1,89 @@
+package dispatcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_AccessLog(t *testing.T) {
+	var out bytes.Buffer
+	previous := AccessLogger
+	AccessLogger = slog.New(slog.NewJSONHandler(&out, nil))
+	t.Cleanup(func() { AccessLogger = previous })
+
+	handler := NewHandlerWithPublisher(&Config{AccessLog: true}, &MockPublisher{})
+
+	req := httptest.NewRequest(http.MethodPost, "/?debug=1", strings.NewReader("not json"))
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("User-Agent", "Strava/1.0")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/live", nil))
+
+	var response map[string]string
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+
+	type entry struct {
+		Level         string `json:"level"`
+		Msg           string `json:"msg"`
+		CorrelationID string `json:"correlation_id"`
+		HTTPRequest   struct {
+			RequestMethod string `json:"requestMethod"`
+			RequestURL    string `json:"requestUrl"`
+			Status        int    `json:"status"`
+			ResponseSize  string `json:"responseSize"`
+			UserAgent     string `json:"userAgent"`
+			RemoteIP      string `json:"remoteIp"`
+			Latency       string `json:"latency"`
+		} `json:"httpRequest"`
+		Labels map[string]string `json:"logging.googleapis.com/labels"`
+	}
+	decoder := json.NewDecoder(&out)
+	var rejected, live entry
+	if err := decoder.Decode(&rejected); err != nil {
+		t.Fatalf("invalid access log line: %v", err)
+	}
+	if err := decoder.Decode(&live); err != nil {
+		t.Fatalf("invalid access log line: %v", err)
+	}
+
+	if rejected.Level != "WARN" || rejected.Msg != "POST / 400" {
+		t.Errorf("unexpected entry for rejected request: %+v", rejected)
+	}
+	if rejected.CorrelationID != response["correlation_id"] {
+		t.Errorf("expected correlation ID %q, got %q", response["correlation_id"], rejected.CorrelationID)
+	}
+	fields := rejected.HTTPRequest
+	if fields.RequestMethod != "POST" || fields.RequestURL != "/?debug=1" || fields.Status != http.StatusBadRequest ||
+		fields.UserAgent != "Strava/1.0" || fields.RemoteIP != "192.0.2.1" || fields.ResponseSize == "0" ||
+		!strings.HasSuffix(fields.Latency, "s") {
+		t.Errorf("unexpected httpRequest fields: %+v", fields)
+	}
+	if rejected.Labels["log_type"] != "access" {
+		t.Errorf("expected access log label, got %v", rejected.Labels)
+	}
+	if live.Level != "INFO" || live.HTTPRequest.Status != http.StatusOK {
+		t.Errorf("unexpected entry for liveness probe: %+v", live)
+	}
+}
+
+func TestHandler_AccessLogDisabled(t *testing.T) {
+	var out bytes.Buffer
+	previous := AccessLogger
+	AccessLogger = slog.New(slog.NewJSONHandler(&out, nil))
+	t.Cleanup(func() { AccessLogger = previous })
+
+	handler := NewHandlerWithPublisher(&Config{}, &MockPublisher{})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/live", nil))
+
+	if out.Len() != 0 {
+		t.Errorf("expected no access log, got %s", out.String())
+	}
+}
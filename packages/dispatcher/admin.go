@@ -0,0 +1,156 @@
+package dispatcher
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/logging"
+)
+
+// maskedValue replaces configured secrets in /admin/status.
+const maskedValue = "***"
+
+// sensitiveConfigField matches Config fields holding credentials.
+var sensitiveConfigField = regexp.MustCompile(`(?i)secret|token|password`)
+
+// handleAdmin serves the /admin/ endpoints to requests bearing
+// Config.AdminToken. Without a configured token they don't exist.
+func (h *Handler) handleAdmin(w http.ResponseWriter, r *http.Request, correlationID string) {
+	ctx := r.Context()
+	if h.config.AdminToken == "" {
+		writeError(w, http.StatusNotFound, "Not found", "", correlationID)
+		return
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.config.AdminToken)) != 1 {
+		h.logAndWriteError(ctx, w, correlationID, http.StatusUnauthorized, "Unauthorized", nil, "Rejected admin request with missing or invalid token")
+		return
+	}
+
+	switch r.URL.Path {
+	case "/admin/log-level":
+		h.handleLogLevel(w, r, correlationID)
+	case "/admin/status":
+		h.handleStatus(w, r, correlationID)
+	default:
+		writeError(w, http.StatusNotFound, "Not found", "", correlationID)
+	}
+}
+
+// handleLogLevel reports the log level on GET and changes it on PUT, e.g.
+// {"level": "DEBUG"}. The change applies to this instance until restart.
+func (h *Handler) handleLogLevel(w http.ResponseWriter, r *http.Request, correlationID string) {
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var request struct {
+			Level string `json:"level"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&request); err != nil {
+			h.logAndWriteError(ctx, w, correlationID, http.StatusBadRequest, "Invalid JSON payload", err, "Invalid log level request")
+			return
+		}
+		level, err := logging.ParseLevel(request.Level)
+		if err != nil {
+			h.logAndWriteError(ctx, w, correlationID, http.StatusBadRequest, "Invalid log level", err, "Invalid log level request")
+			return
+		}
+		SetLogLevel(level)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed", "", correlationID)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"level":          logging.Severity(logLevel.Level()),
+		"correlation_id": correlationID,
+	}); err != nil {
+		Logger.ErrorContext(ctx, "Failed to encode log level response", "correlation_id", correlationID, "error", err)
+	}
+}
+
+// handleStatus reports what this instance is running with: its config
+// with secrets masked, the loaded secrets' state, the publisher and uptime.
+func (h *Handler) handleStatus(w http.ResponseWriter, r *http.Request, correlationID string) {
+	ctx := r.Context()
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed", "", correlationID)
+		return
+	}
+
+	publisher := map[string]string{"backend": h.config.PublisherBackend}
+	if h.breaker != nil {
+		publisher["circuit"] = h.breaker.Stats().State.String()
+	}
+	response := map[string]any{
+		"correlation_id": correlationID,
+		"started_at":     h.startedAt.UTC(),
+		"uptime":         time.Since(h.startedAt).Round(time.Second).String(),
+		"publisher":      publisher,
+		"config":         sanitizeConfig(reflect.ValueOf(*h.config)),
+	}
+	if reporter, ok := h.secrets.(secretStatusReporter); ok {
+		response["secrets"] = reporter.SecretStatus()
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		Logger.ErrorContext(ctx, "Failed to encode status response", "correlation_id", correlationID, "error", err)
+	}
+}
+
+// sanitizeConfig converts a config value to JSON-friendly values, masking
+// non-empty string fields whose names look like credentials. Durations and
+// other Stringers are shown as strings.
+func sanitizeConfig(v reflect.Value) any {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		if stringer, ok := v.Interface().(fmt.Stringer); ok {
+			return stringer.String()
+		}
+		v = v.Elem()
+	}
+	if stringer, ok := v.Interface().(fmt.Stringer); ok {
+		return stringer.String()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := map[string]any{}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			value := v.Field(i)
+			if value.Kind() == reflect.String && value.Len() > 0 && sensitiveConfigField.MatchString(field.Name) {
+				fields[field.Name] = maskedValue
+				continue
+			}
+			fields[field.Name] = sanitizeConfig(value)
+		}
+		return fields
+	case reflect.Slice:
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = sanitizeConfig(v.Index(i))
+		}
+		return items
+	case reflect.Map:
+		entries := map[string]any{}
+		for _, key := range v.MapKeys() {
+			entries[fmt.Sprint(key.Interface())] = sanitizeConfig(v.MapIndex(key))
+		}
+		return entries
+	default:
+		return v.Interface()
+	}
+}
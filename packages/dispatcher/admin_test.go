@@ -0,0 +1,132 @@
+package dispatcher
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/ipfilter"
+)
+
+func TestHandler_AdminLogLevel(t *testing.T) {
+	t.Cleanup(func() { SetLogLevel(slog.LevelInfo) })
+	handler := NewHandlerWithPublisher(&Config{AdminToken: "admin-secret"}, &MockPublisher{})
+
+	serve := func(method, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/log-level", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve(http.MethodGet, "", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without token, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodGet, "wrong", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with wrong token, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodPut, "admin-secret", `{"level":"loud"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown level, got %d", rr.Code)
+	}
+
+	rr := serve(http.MethodPut, "admin-secret", `{"level":"debug"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var response map[string]string
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if response["level"] != "DEBUG" || logLevel.Level() != slog.LevelDebug {
+		t.Errorf("expected level DEBUG, got %q (logger at %v)", response["level"], logLevel.Level())
+	}
+}
+
+func TestHandler_AdminDisabledWithoutToken(t *testing.T) {
+	handler := NewHandlerWithPublisher(&Config{}, &MockPublisher{})
+	req := httptest.NewRequest(http.MethodGet, "/admin/log-level", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 when no admin token is configured, got %d", rr.Code)
+	}
+}
+
+func TestHandler_AdminStatus(t *testing.T) {
+	secretsPath := filepath.Join(t.TempDir(), "strava_auth.json")
+	writeTestSecretsFile(t, secretsPath, map[string]any{
+		"webhook_verify_token":    "verify-secret",
+		"webhook_subscription_id": 12345,
+	})
+	filter, err := ipfilter.Parse("203.0.113.0/24", "")
+	if err != nil {
+		t.Fatalf("ipfilter.Parse failed: %v", err)
+	}
+	cfg := &Config{
+		AdminToken:       "admin-secret",
+		ReplayToken:      "replay-secret",
+		PublisherBackend: PublisherBackendPubSub,
+		GCPPubSubTopicID: "activity_events",
+		IPFilter:         filter,
+		Kafka:            KafkaSettings{SASLUsername: "user", SASLPassword: "kafka-secret"},
+		EnrichTimeout:    time.Second,
+	}
+	handler := NewHandlerWithPublisher(cfg, &MockPublisher{})
+	handler.secrets = NewSecretCache(secretsPath, time.Minute)
+	if _, _, err := handler.secrets.GetSecrets(); err != nil {
+		t.Fatalf("GetSecrets failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+
+	body := rr.Body.String()
+	for _, secret := range []string{"admin-secret", "replay-secret", "kafka-secret", "verify-secret"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("status leaks %q: %s", secret, body)
+		}
+	}
+	var status struct {
+		Config struct {
+			GCPPubSubTopicID string
+			AdminToken       string
+			IPFilter         string
+			EnrichTimeout    string
+			Kafka            struct{ SASLUsername string }
+		}
+		Secrets   SecretStatus
+		Publisher map[string]string
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if status.Config.GCPPubSubTopicID != "activity_events" || status.Config.AdminToken != maskedValue {
+		t.Errorf("unexpected config: %+v", status.Config)
+	}
+	if status.Config.IPFilter != "allow=203.0.113.0/24 deny=" || status.Config.EnrichTimeout != "1s" || status.Config.Kafka.SASLUsername != "user" {
+		t.Errorf("unexpected config values: %+v", status.Config)
+	}
+	if status.Secrets.Source != SecretSourceFile || len(status.Secrets.SubscriptionIDs) != 1 || status.Secrets.SubscriptionIDs[0] != 12345 {
+		t.Errorf("unexpected secrets status: %+v", status.Secrets)
+	}
+	if len(status.Secrets.ContentHash) != 12 || status.Secrets.LastReload.IsZero() {
+		t.Errorf("expected reload hash and time, got %+v", status.Secrets)
+	}
+	if status.Publisher["backend"] != PublisherBackendPubSub {
+		t.Errorf("unexpected publisher: %v", status.Publisher)
+	}
+}
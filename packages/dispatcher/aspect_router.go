@@ -0,0 +1,89 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+)
+
+// AspectRouter sends each event to the publisher configured for its
+// aspect_type, falling back to a default, so the delete pipeline and the
+// create/update pipeline can scale and fail independently.
+type AspectRouter struct {
+	fallback Publisher
+	routes   map[string]Publisher
+}
+
+// NewAspectRouter routes events whose aspect_type has an entry in routes to
+// that publisher and everything else to fallback. The same publisher may
+// serve several aspects.
+func NewAspectRouter(fallback Publisher, routes map[string]Publisher) *AspectRouter {
+	return &AspectRouter{fallback: fallback, routes: routes}
+}
+
+// Publish implements the Publisher interface.
+func (r *AspectRouter) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	return r.PublishBatch(ctx, []PendingEvent{newPendingEvent(ctx, webhook, correlationID)})[0]
+}
+
+// PublishBatch implements the BatchPublisher interface, splitting the batch
+// between the aspect publishers.
+func (r *AspectRouter) PublishBatch(ctx context.Context, events []PendingEvent) []error {
+	return routeBatch(ctx, events, func(event PendingEvent) Publisher {
+		if publisher, ok := r.routes[event.Webhook.AspectType]; ok {
+			return publisher
+		}
+		return r.fallback
+	})
+}
+
+// Close implements the Publisher interface, closing each distinct publisher
+// once.
+func (r *AspectRouter) Close(ctx context.Context) error {
+	publishers := []Publisher{r.fallback}
+	for _, publisher := range r.routes {
+		publishers = append(publishers, publisher)
+	}
+	return closeDistinct(ctx, publishers)
+}
+
+// closeDistinct closes each publisher once, however often it appears.
+func closeDistinct(ctx context.Context, publishers []Publisher) error {
+	var errs []error
+	closed := map[Publisher]bool{}
+	for _, publisher := range publishers {
+		if closed[publisher] {
+			continue
+		}
+		closed[publisher] = true
+		errs = append(errs, publisher.Close(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// routeBatch publishes each event through the publisher pick chooses for
+// it, batching the events that share a publisher, and returns the errors in
+// the original order.
+func routeBatch(ctx context.Context, events []PendingEvent, pick func(PendingEvent) Publisher) []error {
+	var order []Publisher
+	groups := map[Publisher][]int{}
+	for i, event := range events {
+		publisher := pick(event)
+		if _, ok := groups[publisher]; !ok {
+			order = append(order, publisher)
+		}
+		groups[publisher] = append(groups[publisher], i)
+	}
+
+	errs := make([]error, len(events))
+	for _, publisher := range order {
+		indexes := groups[publisher]
+		group := make([]PendingEvent, len(indexes))
+		for j, i := range indexes {
+			group[j] = events[i]
+		}
+		for j, err := range publishAll(ctx, publisher, group) {
+			errs[indexes[j]] = err
+		}
+	}
+	return errs
+}
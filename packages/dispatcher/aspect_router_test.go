@@ -0,0 +1,53 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAspectRouter(t *testing.T) {
+	fallback := &MockPublisher{}
+	deletes := &MockPublisher{PublishErr: errors.New("delete topic unavailable")}
+	router := NewAspectRouter(fallback, map[string]Publisher{AspectDelete: deletes})
+
+	events := []PendingEvent{
+		{Webhook: WebhookRequest{ObjectID: 1, AspectType: AspectCreate}},
+		{Webhook: WebhookRequest{ObjectID: 2, AspectType: AspectDelete}},
+		{Webhook: WebhookRequest{ObjectID: 3, AspectType: AspectUpdate}},
+	}
+	errs := router.PublishBatch(context.Background(), events)
+
+	if errs[0] != nil || errs[2] != nil {
+		t.Errorf("expected create and update to succeed, got %v", errs)
+	}
+	if errs[1] == nil {
+		t.Error("expected the delete failure to be reported for its event only")
+	}
+	if len(fallback.Published) != 2 || fallback.Published[0].ObjectID != 1 || fallback.Published[1].ObjectID != 3 {
+		t.Errorf("expected create and update on the fallback publisher, got %+v", fallback.Published)
+	}
+}
+
+func TestAspectRouter_CloseSharedPublisherOnce(t *testing.T) {
+	fallback := &MockPublisher{}
+	shared := &countingCloser{}
+	router := NewAspectRouter(fallback, map[string]Publisher{AspectUpdate: shared, AspectDelete: shared})
+
+	if err := router.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !fallback.Closed || shared.closes != 1 {
+		t.Errorf("expected each publisher closed once, fallback=%v shared=%d", fallback.Closed, shared.closes)
+	}
+}
+
+type countingCloser struct {
+	MockPublisher
+	closes int
+}
+
+func (c *countingCloser) Close(ctx context.Context) error {
+	c.closes++
+	return nil
+}
@@ -0,0 +1,161 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAsyncQueueSize bounds events waiting for background publishing.
+	DefaultAsyncQueueSize = 100
+
+	defaultAsyncMaxAttempts    = 3
+	defaultAsyncRetryBackoff   = time.Second
+	defaultAsyncPublishTimeout = 30 * time.Second
+)
+
+// ErrPublisherClosed is returned when publishing after Close.
+var ErrPublisherClosed = errors.New("publisher is closed")
+
+// AsyncPublisher queues events on a bounded channel and publishes them from
+// a background worker, so the webhook can be acknowledged before Pub/Sub
+// confirms. Events that queue up during a burst are published together when
+// the wrapped publisher is a BatchPublisher. When the queue is full the event
+// is published inline instead of being dropped. Close drains the queue before
+// closing the wrapped publisher.
+type AsyncPublisher struct {
+	publisher    Publisher
+	queue        chan PendingEvent
+	done         chan struct{}
+	maxAttempts  int
+	retryBackoff time.Duration
+	timeout      time.Duration
+	closeOnce    sync.Once
+	mu           sync.RWMutex
+	closed       bool
+}
+
+// NewAsyncPublisher starts a background worker publishing through publisher.
+// A single worker keeps events in arrival order.
+func NewAsyncPublisher(publisher Publisher, queueSize int) *AsyncPublisher {
+	if queueSize <= 0 {
+		queueSize = DefaultAsyncQueueSize
+	}
+
+	p := &AsyncPublisher{
+		publisher:    publisher,
+		queue:        make(chan PendingEvent, queueSize),
+		done:         make(chan struct{}),
+		maxAttempts:  defaultAsyncMaxAttempts,
+		retryBackoff: defaultAsyncRetryBackoff,
+		timeout:      defaultAsyncPublishTimeout,
+	}
+	go p.run()
+	return p
+}
+
+// Publish enqueues the event and returns immediately. If the queue is full
+// it publishes synchronously so the event is not lost.
+func (p *AsyncPublisher) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrPublisherClosed
+	}
+
+	select {
+	case p.queue <- newPendingEvent(ctx, webhook, correlationID):
+		Logger.Debug("Queued webhook for async publish", "correlation_id", correlationID, "queue_depth", len(p.queue))
+		return nil
+	default:
+		Logger.Warn("Async publish queue full, publishing inline", "correlation_id", correlationID, "queue_size", cap(p.queue))
+		return p.publisher.Publish(ctx, webhook, correlationID)
+	}
+}
+
+// run publishes queued events until the queue is closed and drained.
+func (p *AsyncPublisher) run() {
+	defer close(p.done)
+	for event := range p.queue {
+		p.publishWithRetry(p.takeQueued([]PendingEvent{event}))
+	}
+}
+
+// takeQueued appends events already waiting in the queue to batch, up to
+// the queue's capacity, without blocking for new ones.
+func (p *AsyncPublisher) takeQueued(batch []PendingEvent) []PendingEvent {
+	for len(batch) < cap(p.queue) {
+		select {
+		case event, ok := <-p.queue:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, event)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// publishWithRetry publishes batch and retries the events that failed, in
+// their original order, until they succeed or run out of attempts.
+func (p *AsyncPublisher) publishWithRetry(batch []PendingEvent) {
+	backoff := p.retryBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+		errs := publishAll(ctx, p.publisher, batch)
+		cancel()
+
+		var failed []PendingEvent
+		for i, err := range errs {
+			if err == nil {
+				continue
+			}
+			event := batch[i]
+			if attempt >= p.maxAttempts {
+				Logger.Error("Async publish failed, dropping event",
+					"correlation_id", event.CorrelationID,
+					"object_id", event.Webhook.ObjectID,
+					"attempts", attempt,
+					"error", err)
+				continue
+			}
+			Logger.Warn("Async publish failed, retrying",
+				"correlation_id", event.CorrelationID,
+				"attempt", attempt,
+				"error", err)
+			failed = append(failed, event)
+		}
+		if len(failed) == 0 {
+			return
+		}
+
+		batch = failed
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Close stops accepting events, waits for queued events to be published and
+// then closes the wrapped publisher. If ctx ends first, events still queued
+// are abandoned.
+func (p *AsyncPublisher) Close(ctx context.Context) error {
+	p.closeOnce.Do(func() {
+		p.mu.Lock()
+		p.closed = true
+		close(p.queue)
+		p.mu.Unlock()
+	})
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return fmt.Errorf("timed out draining async publish queue (%d events pending): %w", len(p.queue), ctx.Err())
+	}
+	return p.publisher.Close(ctx)
+}
@@ -0,0 +1,195 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingPublisher is a concurrency-safe Publisher for background tests.
+type recordingPublisher struct {
+	block     chan struct{}
+	failures  int
+	published []WebhookRequest
+	calls     int
+	mu        sync.Mutex
+	closed    bool
+}
+
+func (r *recordingPublisher) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	if r.block != nil {
+		<-r.block
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("transient failure")
+	}
+	r.published = append(r.published, webhook)
+	return nil
+}
+
+func (r *recordingPublisher) Close(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+// batchingPublisher records the size of each PublishBatch call.
+type batchingPublisher struct {
+	recordingPublisher
+	batchSizes []int
+}
+
+func (b *batchingPublisher) PublishBatch(ctx context.Context, events []PendingEvent) []error {
+	b.mu.Lock()
+	b.batchSizes = append(b.batchSizes, len(events))
+	b.mu.Unlock()
+
+	errs := make([]error, len(events))
+	for i, event := range events {
+		errs[i] = b.Publish(ctx, event.Webhook, event.CorrelationID)
+	}
+	return errs
+}
+
+func TestAsyncPublisher_PublishesInBackground(t *testing.T) {
+	inner := &recordingPublisher{}
+	publisher := NewAsyncPublisher(inner, 10)
+
+	for i := 1; i <= 5; i++ {
+		if err := publisher.Publish(context.Background(), WebhookRequest{ObjectID: int64(i)}, "corr"); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+
+	if err := publisher.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if len(inner.published) != 5 {
+		t.Fatalf("expected 5 events flushed on close, got %d", len(inner.published))
+	}
+	for i, webhook := range inner.published {
+		if webhook.ObjectID != int64(i+1) {
+			t.Errorf("expected events in order, got ObjectID %d at position %d", webhook.ObjectID, i)
+		}
+	}
+	if !inner.closed {
+		t.Error("expected wrapped publisher to be closed")
+	}
+
+	if err := publisher.Publish(context.Background(), WebhookRequest{}, "late"); !errors.Is(err, ErrPublisherClosed) {
+		t.Errorf("expected ErrPublisherClosed after Close, got %v", err)
+	}
+}
+
+func TestAsyncPublisher_RetriesTransientFailures(t *testing.T) {
+	inner := &recordingPublisher{failures: 2}
+	publisher := NewAsyncPublisher(inner, 10)
+	publisher.retryBackoff = time.Millisecond
+
+	if err := publisher.Publish(context.Background(), WebhookRequest{ObjectID: 1}, "corr"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if err := publisher.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if inner.calls != 3 || len(inner.published) != 1 {
+		t.Errorf("expected success on third attempt, got %d calls and %d published", inner.calls, len(inner.published))
+	}
+}
+
+func TestAsyncPublisher_OverflowPublishesInline(t *testing.T) {
+	inner := &recordingPublisher{block: make(chan struct{})}
+	publisher := NewAsyncPublisher(inner, 1)
+
+	// The worker takes the first event and blocks; the second fills the queue
+	if err := publisher.Publish(context.Background(), WebhookRequest{ObjectID: 1}, "corr"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(publisher.queue) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := publisher.Publish(context.Background(), WebhookRequest{ObjectID: 2}, "corr"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	overflowed := make(chan error, 1)
+	go func() {
+		overflowed <- publisher.Publish(context.Background(), WebhookRequest{ObjectID: 3}, "corr")
+	}()
+
+	// Unblock every pending Publish call (worker and inline)
+	close(inner.block)
+
+	if err := <-overflowed; err != nil {
+		t.Fatalf("inline publish failed: %v", err)
+	}
+	if err := publisher.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if len(inner.published) != 3 {
+		t.Errorf("expected all 3 events published, got %d", len(inner.published))
+	}
+}
+
+func TestAsyncPublisher_BatchesQueuedEvents(t *testing.T) {
+	inner := &batchingPublisher{recordingPublisher: recordingPublisher{block: make(chan struct{})}}
+	publisher := NewAsyncPublisher(inner, 10)
+
+	// The first event holds the worker while the burst queues up behind it
+	if err := publisher.Publish(context.Background(), WebhookRequest{ObjectID: 1}, "corr"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(publisher.queue) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 2; i <= 5; i++ {
+		if err := publisher.Publish(context.Background(), WebhookRequest{ObjectID: int64(i)}, "corr"); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+
+	close(inner.block)
+	if err := publisher.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if len(inner.batchSizes) != 2 || inner.batchSizes[0] != 1 || inner.batchSizes[1] != 4 {
+		t.Errorf("expected batches of [1 4], got %v", inner.batchSizes)
+	}
+	for i, webhook := range inner.published {
+		if webhook.ObjectID != int64(i+1) {
+			t.Errorf("expected events in order, got ObjectID %d at position %d", webhook.ObjectID, i)
+		}
+	}
+}
+
+func TestAsyncPublisher_CloseRespectsDeadline(t *testing.T) {
+	inner := &recordingPublisher{block: make(chan struct{})}
+	defer close(inner.block)
+	publisher := NewAsyncPublisher(inner, 10)
+
+	if err := publisher.Publish(context.Background(), WebhookRequest{ObjectID: 1}, "corr"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := publisher.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error while the worker is stuck, got %v", err)
+	}
+	if inner.closed {
+		t.Error("expected wrapped publisher to stay open after an abandoned drain")
+	}
+}
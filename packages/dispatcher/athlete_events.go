@@ -0,0 +1,42 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+)
+
+// AthleteRouter sends athlete object events (profile updates and
+// deauthorizations) to a separate publisher, typically one for a topic a
+// profile-sync consumer subscribes to, and everything else to the wrapped
+// publisher.
+type AthleteRouter struct {
+	events   Publisher
+	athletes Publisher
+}
+
+// NewAthleteRouter routes athlete events to athletes and everything else to
+// events.
+func NewAthleteRouter(events, athletes Publisher) *AthleteRouter {
+	return &AthleteRouter{events: events, athletes: athletes}
+}
+
+// Publish implements the Publisher interface.
+func (r *AthleteRouter) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	return r.PublishBatch(ctx, []PendingEvent{newPendingEvent(ctx, webhook, correlationID)})[0]
+}
+
+// PublishBatch implements the BatchPublisher interface, splitting the batch
+// between the two publishers.
+func (r *AthleteRouter) PublishBatch(ctx context.Context, events []PendingEvent) []error {
+	return routeBatch(ctx, events, func(event PendingEvent) Publisher {
+		if event.Webhook.ObjectType == ObjectAthlete {
+			return r.athletes
+		}
+		return r.events
+	})
+}
+
+// Close implements the Publisher interface.
+func (r *AthleteRouter) Close(ctx context.Context) error {
+	return errors.Join(r.events.Close(ctx), r.athletes.Close(ctx))
+}
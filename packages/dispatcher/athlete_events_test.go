@@ -0,0 +1,53 @@
+package dispatcher
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAthleteRouter(t *testing.T) {
+	events := &MockPublisher{}
+	athletes := &MockPublisher{}
+	router := NewAthleteRouter(events, athletes)
+
+	ctx := context.Background()
+	errs := router.PublishBatch(ctx, []PendingEvent{
+		{Webhook: WebhookRequest{ObjectType: ObjectActivity, ObjectID: 1}, CorrelationID: "corr-1"},
+		{Webhook: WebhookRequest{ObjectType: ObjectAthlete, AspectType: AspectUpdate, ObjectID: 7}, CorrelationID: "corr-2"},
+		{Webhook: deauthorizationWebhook(), CorrelationID: "corr-3"},
+	})
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("event %d: PublishBatch failed: %v", i, err)
+		}
+	}
+
+	if len(events.Published) != 1 || events.Published[0].ObjectID != 1 {
+		t.Errorf("expected only the activity event on the events publisher, got %+v", events.Published)
+	}
+	if len(athletes.Published) != 2 {
+		t.Errorf("expected the profile update and deauthorization on the athlete publisher, got %+v", athletes.Published)
+	}
+
+	if err := router.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !events.Closed || !athletes.Closed {
+		t.Error("expected Close to close both publishers")
+	}
+}
+
+func TestDeauthorizationRouter_TakesPrecedenceOverAthleteRouter(t *testing.T) {
+	events := &MockPublisher{}
+	athletes := &MockPublisher{}
+	deauthorizations := &MockPublisher{}
+	router := NewDeauthorizationRouter(NewAthleteRouter(events, athletes), deauthorizations)
+
+	if err := router.Publish(context.Background(), deauthorizationWebhook(), "corr-1"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if len(deauthorizations.Published) != 1 || len(athletes.Published) != 0 {
+		t.Errorf("expected the deauthorization on its own topic, got athletes=%+v deauthorizations=%+v",
+			athletes.Published, deauthorizations.Published)
+	}
+}
@@ -0,0 +1,152 @@
+package dispatcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"cloud.google.com/go/storage"
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"google.golang.org/api/googleapi"
+)
+
+// maxRegistryUpdateAttempts bounds how often a registry update is retried
+// after losing a race with another writer.
+const maxRegistryUpdateAttempts = 5
+
+// StoragePrefixAttribute carries the registered owner's storage prefix
+// (see athletes.Athlete), so subscriptions can filter by athlete and
+// consumers without the registry know where the athlete's aggregates
+// live. It is absent for athletes at the bucket root and without
+// ATHLETE_REGISTRY.
+const StoragePrefixAttribute = "storage_prefix"
+
+// storagePrefixKey is the context key for an event's storage prefix.
+type storagePrefixKey struct{}
+
+// withStoragePrefix returns ctx marking the events published with it as
+// belonging to the athlete under prefix.
+func withStoragePrefix(ctx context.Context, prefix string) context.Context {
+	return context.WithValue(ctx, storagePrefixKey{}, prefix)
+}
+
+// storagePrefixFromContext returns the prefix set by withStoragePrefix.
+func storagePrefixFromContext(ctx context.Context) string {
+	prefix, _ := ctx.Value(storagePrefixKey{}).(string)
+	return prefix
+}
+
+// athleteRegistry is the registry with the storage client reading it, if
+// it is kept in Cloud Storage.
+type athleteRegistry struct {
+	*athletes.Registry
+	// update writes the registry for onboarding.
+	update athletes.Updater
+	client *storage.Client
+}
+
+// openAthleteRegistry opens the registry cfg locates.
+func openAthleteRegistry(ctx context.Context, cfg *athletes.Config) (*athleteRegistry, error) {
+	bucket, object, ok := cfg.GCS()
+	if !ok {
+		return &athleteRegistry{
+			Registry: athletes.New(athletes.FileLoader(cfg.Location), cfg.CacheTTL),
+			update:   athletes.FileUpdater(cfg.Location),
+		}, nil
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
+	}
+	handle := client.Bucket(bucket).Object(object)
+	load := func(ctx context.Context) ([]byte, error) {
+		data, _, err := readRegistryObject(ctx, handle)
+		return data, err
+	}
+	return &athleteRegistry{
+		Registry: athletes.New(load, cfg.CacheTTL),
+		update:   gcsRegistryUpdater(handle),
+		client:   client,
+	}, nil
+}
+
+// readRegistryObject returns the registry object's data and generation,
+// nil and 0 if it doesn't exist.
+func readRegistryObject(ctx context.Context, handle *storage.ObjectHandle) ([]byte, int64, error) {
+	reader, err := handle.NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read athlete registry: %w", err)
+	}
+	defer func() { _ = reader.Close() }()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read athlete registry: %w", err)
+	}
+	return data, reader.Attrs.Generation, nil
+}
+
+// gcsRegistryUpdater updates the registry object with a generation
+// precondition, starting over when another instance wrote it first.
+func gcsRegistryUpdater(handle *storage.ObjectHandle) athletes.Updater {
+	return func(ctx context.Context, update func([]byte) ([]byte, error)) error {
+		for attempt := 1; ; attempt++ {
+			data, generation, err := readRegistryObject(ctx, handle)
+			if err != nil {
+				return err
+			}
+			data, err = update(data)
+			if err != nil {
+				return err
+			}
+
+			conditions := storage.Conditions{GenerationMatch: generation}
+			if generation == 0 {
+				conditions = storage.Conditions{DoesNotExist: true}
+			}
+			w := handle.If(conditions).NewWriter(ctx)
+			w.ContentType = "application/json"
+			if _, err := io.Copy(w, bytes.NewReader(data)); err != nil {
+				_ = w.Close()
+				return fmt.Errorf("failed to write athlete registry: %w", err)
+			}
+			err = w.Close()
+			var apiErr *googleapi.Error
+			if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed && attempt < maxRegistryUpdateAttempts {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write athlete registry: %w", err)
+			}
+			return nil
+		}
+	}
+}
+
+// Close releases the storage client.
+func (r *athleteRegistry) Close() error {
+	if r.client == nil {
+		return nil
+	}
+	if err := r.client.Close(); err != nil {
+		return fmt.Errorf("failed to close storage client: %w", err)
+	}
+	return nil
+}
+
+// athleteFetchers returns the fetcher of each registered athlete, for
+// EnrichingPublisher.
+func athleteFetchers(registry *athletes.Registry, clients *athletes.Clients) func(ctx context.Context, ownerID int64) (ActivityFetcher, error) {
+	return func(ctx context.Context, ownerID int64) (ActivityFetcher, error) {
+		athlete, err := registry.Lookup(ctx, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		return clients.Client(athlete), nil
+	}
+}
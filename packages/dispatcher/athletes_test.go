@@ -0,0 +1,116 @@
+package dispatcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/athletes"
+)
+
+// testRegistry registers athlete 1 under athletes/1/ and athlete 2 at the
+// bucket root.
+func testRegistry() *athleteRegistry {
+	return &athleteRegistry{Registry: athletes.New(func(context.Context) ([]byte, error) {
+		return []byte(`{"athletes": [{"id": 1, "prefix": "athletes/1/"}, {"id": 2, "prefix": ""}]}`), nil
+	}, 0)}
+}
+
+func TestHandler_AthleteRegistry(t *testing.T) {
+	secretsPath := filepath.Join(t.TempDir(), "strava_auth.json")
+	writeTestSecretsFile(t, secretsPath, map[string]any{
+		"webhook_verify_token":    "test-token",
+		"webhook_subscription_id": 12345,
+	})
+	var out bytes.Buffer
+	handler := NewHandlerWithPublisher(&Config{}, &LocalPublisher{out: &out})
+	handler.secrets = NewSecretCache(secretsPath, time.Minute)
+	handler.registry = testRegistry()
+
+	post := func(ownerID string) int {
+		body := `{"aspect_type":"create","object_type":"activity","object_id":1,"owner_id":` + ownerID + `,"event_time":1,"subscription_id":12345}`
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		return rr.Code
+	}
+
+	if code := post("3"); code != http.StatusCreated || out.Len() != 0 {
+		t.Errorf("expected an unregistered athlete's event to be acknowledged and dropped, got %d and %q", code, out.String())
+	}
+
+	if code := post("1"); code != http.StatusCreated {
+		t.Fatalf("expected a registered athlete's event to be published, got %d", code)
+	}
+	var record LocalRecord
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("invalid local record: %v", err)
+	}
+	if record.Attributes[StoragePrefixAttribute] != "athletes/1/" {
+		t.Errorf("expected the athlete's storage prefix attribute, got %v", record.Attributes)
+	}
+
+	out.Reset()
+	if code := post("2"); code != http.StatusCreated {
+		t.Fatalf("expected a registered athlete's event to be published, got %d", code)
+	}
+	var rootRecord LocalRecord
+	if err := json.Unmarshal(out.Bytes(), &rootRecord); err != nil {
+		t.Fatalf("invalid local record: %v", err)
+	}
+	if _, ok := rootRecord.Attributes[StoragePrefixAttribute]; ok {
+		t.Errorf("expected no prefix attribute for the bucket root, got %v", rootRecord.Attributes)
+	}
+}
+
+func TestHandler_AthleteRegistryError(t *testing.T) {
+	secretsPath := filepath.Join(t.TempDir(), "strava_auth.json")
+	writeTestSecretsFile(t, secretsPath, map[string]any{
+		"webhook_verify_token":    "test-token",
+		"webhook_subscription_id": 12345,
+	})
+	mockPub := &MockPublisher{}
+	handler := NewHandlerWithPublisher(&Config{}, mockPub)
+	handler.secrets = NewSecretCache(secretsPath, time.Minute)
+	handler.registry = &athleteRegistry{Registry: athletes.New(func(context.Context) ([]byte, error) {
+		return nil, errors.New("registry unavailable")
+	}, 0)}
+
+	body := `{"aspect_type":"create","object_type":"activity","object_id":1,"owner_id":1,"event_time":1,"subscription_id":12345}`
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	if rr.Code != http.StatusInternalServerError || len(mockPub.Published) != 0 {
+		t.Errorf("expected a 500 so Strava retries, got %d with %d published", rr.Code, len(mockPub.Published))
+	}
+}
+
+func TestAthleteEnrichingPublisher(t *testing.T) {
+	registry := testRegistry()
+	fetchers := map[int64]*stubFetcher{1: {}, 2: {}}
+	inner := &MockPublisher{}
+	publisher := NewAthleteEnrichingPublisher(inner, func(ctx context.Context, ownerID int64) (ActivityFetcher, error) {
+		if _, err := registry.Lookup(ctx, ownerID); err != nil {
+			return nil, err
+		}
+		return fetchers[ownerID], nil
+	}, 0)
+
+	for _, ownerID := range []int64{2, 3} {
+		webhook := WebhookRequest{ObjectType: ObjectActivity, AspectType: AspectCreate, ObjectID: 42, OwnerID: ownerID}
+		if err := publisher.Publish(context.Background(), webhook, "corr"); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	if fetchers[1].calls != 0 || fetchers[2].calls != 1 {
+		t.Errorf("expected only the owner's fetcher to be called, got %d and %d", fetchers[1].calls, fetchers[2].calls)
+	}
+	if len(inner.Published) != 2 || inner.Published[0].Activity == nil || inner.Published[1].Activity != nil {
+		t.Errorf("expected the unregistered owner's event published without details, got %+v", inner.Published)
+	}
+}
@@ -0,0 +1,361 @@
+package dispatcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	bigquery "google.golang.org/api/bigquery/v2"
+)
+
+const (
+	// Audit outcomes: published to the topic, acknowledged without
+	// publishing, or answered with an error.
+	AuditPublished = "published"
+	AuditIgnored   = "ignored"
+	AuditRejected  = "rejected"
+
+	// DefaultAuditPrefix is the object prefix audit archives are written
+	// under.
+	DefaultAuditPrefix = "audit/"
+
+	// DefaultAuditFlushInterval is how long records wait before being
+	// written, unless a full batch is ready sooner.
+	DefaultAuditFlushInterval = 10 * time.Second
+
+	// auditBatchSize is the most records written at once; it also bounds
+	// the queue, beyond which records are dropped rather than slowing down
+	// webhook responses.
+	auditBatchSize = 500
+
+	// auditWriteTimeout bounds a single batch write.
+	auditWriteTimeout = 30 * time.Second
+)
+
+// AuditRecord describes one received webhook and what the dispatcher did
+// with it. Event fields are empty when the body couldn't be decoded.
+type AuditRecord struct {
+	ReceivedAt    time.Time `json:"received_at"`
+	CorrelationID string    `json:"correlation_id"`
+	Outcome       string    `json:"outcome"`
+	// Reason is the rejection or ignore reason, as in the metric labels.
+	Reason         string `json:"reason,omitempty"`
+	StatusCode     int    `json:"status_code"`
+	Source         string `json:"source,omitempty"`
+	ClientIP       string `json:"client_ip,omitempty"`
+	AspectType     string `json:"aspect_type,omitempty"`
+	ObjectType     string `json:"object_type,omitempty"`
+	ObjectID       int64  `json:"object_id,omitempty"`
+	OwnerID        int64  `json:"owner_id,omitempty"`
+	EventTime      int64  `json:"event_time,omitempty"`
+	SubscriptionID int    `json:"subscription_id,omitempty"`
+}
+
+// reject marks the record as answered with an error for reason.
+func (a *AuditRecord) reject(reason string) {
+	a.Outcome, a.Reason = AuditRejected, reason
+}
+
+// ignore marks the record as acknowledged without publishing for reason.
+func (a *AuditRecord) ignore(reason string) {
+	a.Outcome, a.Reason = AuditIgnored, reason
+}
+
+// setWebhook copies the decoded event's identifying fields.
+func (a *AuditRecord) setWebhook(webhook WebhookRequest) {
+	a.AspectType = webhook.AspectType
+	a.ObjectType = webhook.ObjectType
+	a.ObjectID = webhook.ObjectID
+	a.OwnerID = webhook.OwnerID
+	a.EventTime = webhook.EventTime
+	a.SubscriptionID = webhook.SubscriptionID
+}
+
+// newAuditRecord starts the record for a webhook request.
+func (h *Handler) newAuditRecord(r *http.Request, correlationID string) *AuditRecord {
+	return &AuditRecord{
+		ReceivedAt:    time.Now(),
+		CorrelationID: correlationID,
+		Outcome:       AuditPublished,
+		Source:        eventSourceFromContext(r.Context()),
+		ClientIP:      h.clientIP(r),
+	}
+}
+
+// AuditSink persists batches of audit records.
+type AuditSink interface {
+	Write(ctx context.Context, records []AuditRecord) error
+	Close() error
+}
+
+// AuditLog writes audit records to a sink from a background worker, in
+// batches of up to auditBatchSize or every flush interval. Recording never
+// blocks the request: when the queue is full the record is dropped and
+// counted.
+type AuditLog struct {
+	sink      AuditSink
+	queue     chan AuditRecord
+	done      chan struct{}
+	interval  time.Duration
+	closeOnce sync.Once
+	mu        sync.RWMutex
+	closed    bool
+}
+
+// NewAuditLog starts a worker writing to sink every interval.
+func NewAuditLog(sink AuditSink, interval time.Duration) *AuditLog {
+	if interval <= 0 {
+		interval = DefaultAuditFlushInterval
+	}
+	a := &AuditLog{
+		sink:     sink,
+		queue:    make(chan AuditRecord, auditBatchSize),
+		done:     make(chan struct{}),
+		interval: interval,
+	}
+	go a.run()
+	return a
+}
+
+// Record queues record for writing. It is a no-op on a nil AuditLog.
+func (a *AuditLog) Record(record AuditRecord) {
+	if a == nil {
+		return
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return
+	}
+	select {
+	case a.queue <- record:
+	default:
+		auditRecordsDropped.Inc()
+		Logger.Warn("Audit queue full, dropping record", "correlation_id", record.CorrelationID)
+	}
+}
+
+// run batches queued records until the queue is closed and drained.
+func (a *AuditLog) run() {
+	defer close(a.done)
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	var batch []AuditRecord
+	for {
+		select {
+		case record, ok := <-a.queue:
+			if !ok {
+				a.flush(batch)
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) < auditBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		a.flush(batch)
+		batch = nil
+	}
+}
+
+// flush writes batch, logging instead of retrying on failure; the audit
+// log is best-effort and must not hold up webhook processing.
+func (a *AuditLog) flush(batch []AuditRecord) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), auditWriteTimeout)
+	defer cancel()
+	if err := a.sink.Write(ctx, batch); err != nil {
+		auditRecordsDropped.Add(float64(len(batch)))
+		Logger.Error("Failed to write audit records", "records", len(batch), "error", err)
+	}
+}
+
+// Close stops accepting records, writes those still queued and closes the
+// sink. If ctx ends first, queued records are abandoned.
+func (a *AuditLog) Close(ctx context.Context) error {
+	a.closeOnce.Do(func() {
+		a.mu.Lock()
+		a.closed = true
+		close(a.queue)
+		a.mu.Unlock()
+	})
+
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+		return fmt.Errorf("timed out writing audit records (%d pending): %w", len(a.queue), ctx.Err())
+	}
+	return a.sink.Close()
+}
+
+// GCSAuditSink archives each batch as an NDJSON object in a Cloud Storage
+// bucket, named <prefix>YYYY/MM/DD/<unix_nanos>-<uuid>.ndjson after the
+// batch's first record.
+type GCSAuditSink struct {
+	client *storage.Client
+	bucket string
+	prefix string
+}
+
+// NewGCSAuditSink creates a sink writing to bucket under prefix.
+func NewGCSAuditSink(ctx context.Context, bucket, prefix string) (*GCSAuditSink, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
+	}
+	Logger.Info("Audit archive initialized", "bucket", bucket, "prefix", prefix)
+	return &GCSAuditSink{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+// Write implements the AuditSink interface.
+func (s *GCSAuditSink) Write(ctx context.Context, records []AuditRecord) error {
+	data, err := marshalAuditRecords(records)
+	if err != nil {
+		return err
+	}
+
+	w := s.client.Bucket(s.bucket).Object(auditObjectName(s.prefix, records[0], uuid.NewString())).NewWriter(ctx)
+	w.ContentType = "application/x-ndjson"
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return fmt.Errorf("failed to write audit archive: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write audit archive: %w", err)
+	}
+	return nil
+}
+
+// Close implements the AuditSink interface.
+func (s *GCSAuditSink) Close() error {
+	if err := s.client.Close(); err != nil {
+		return fmt.Errorf("failed to close storage client: %w", err)
+	}
+	return nil
+}
+
+// marshalAuditRecords encodes records as newline-delimited JSON.
+func marshalAuditRecords(records []AuditRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return nil, fmt.Errorf("failed to marshal audit record: %w", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// auditObjectName partitions archives by date, like dead-letter records,
+// so gaps can be checked one day at a time.
+func auditObjectName(prefix string, first AuditRecord, id string) string {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	receivedAt := first.ReceivedAt.UTC()
+	return prefix + path.Join(receivedAt.Format("2006/01/02"), fmt.Sprintf("%d-%s.ndjson", receivedAt.UnixNano(), id))
+}
+
+// BigQueryAuditSink streams records into a BigQuery table whose columns
+// match AuditRecord's JSON names. The correlation ID is used as the insert
+// ID so retried inserts aren't duplicated.
+type BigQueryAuditSink struct {
+	tabledata *bigquery.TabledataService
+	projectID string
+	datasetID string
+	tableID   string
+}
+
+// NewBigQueryAuditSink creates a sink for table, given as
+// "project.dataset.table" or "dataset.table" in projectID.
+func NewBigQueryAuditSink(ctx context.Context, table, projectID string) (*BigQueryAuditSink, error) {
+	project, dataset, tableID, err := parseBigQueryTable(table, projectID)
+	if err != nil {
+		return nil, err
+	}
+	service, err := bigquery.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create BigQuery client: %w", err)
+	}
+	Logger.Info("Audit table initialized", "table", project+"."+dataset+"."+tableID)
+	return &BigQueryAuditSink{
+		tabledata: bigquery.NewTabledataService(service),
+		projectID: project,
+		datasetID: dataset,
+		tableID:   tableID,
+	}, nil
+}
+
+// parseBigQueryTable splits a table reference, defaulting the project.
+func parseBigQueryTable(table, projectID string) (project, dataset, tableID string, err error) {
+	parts := strings.Split(table, ".")
+	switch {
+	case len(parts) == 3 && !slices.Contains(parts, ""):
+		return parts[0], parts[1], parts[2], nil
+	case len(parts) == 2 && !slices.Contains(parts, ""):
+		if projectID == "" {
+			return "", "", "", fmt.Errorf("GCP_PROJECT_ID is required to resolve table %q", table)
+		}
+		return projectID, parts[0], parts[1], nil
+	default:
+		return "", "", "", fmt.Errorf("invalid BigQuery table %q (expected [project.]dataset.table)", table)
+	}
+}
+
+// Write implements the AuditSink interface.
+func (s *BigQueryAuditSink) Write(ctx context.Context, records []AuditRecord) error {
+	rows, err := auditRows(records)
+	if err != nil {
+		return err
+	}
+	response, err := s.tabledata.InsertAll(s.projectID, s.datasetID, s.tableID, &bigquery.TableDataInsertAllRequest{Rows: rows}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to insert audit records: %w", err)
+	}
+	if len(response.InsertErrors) > 0 {
+		var errs []error
+		for _, insertErr := range response.InsertErrors {
+			for _, e := range insertErr.Errors {
+				errs = append(errs, fmt.Errorf("row %d: %s", insertErr.Index, e.Message))
+			}
+		}
+		return fmt.Errorf("failed to insert %d audit records: %w", len(response.InsertErrors), errors.Join(errs...))
+	}
+	return nil
+}
+
+// auditRows converts records to insertAll rows keyed by their JSON names.
+func auditRows(records []AuditRecord) ([]*bigquery.TableDataInsertAllRequestRows, error) {
+	rows := make([]*bigquery.TableDataInsertAllRequestRows, len(records))
+	for i, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal audit record: %w", err)
+		}
+		var row map[string]bigquery.JsonValue
+		if err := json.Unmarshal(data, &row); err != nil {
+			return nil, fmt.Errorf("failed to marshal audit record: %w", err)
+		}
+		rows[i] = &bigquery.TableDataInsertAllRequestRows{InsertId: record.CorrelationID, Json: row}
+	}
+	return rows, nil
+}
+
+// Close implements the AuditSink interface.
+func (s *BigQueryAuditSink) Close() error {
+	return nil
+}
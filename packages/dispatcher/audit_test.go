@@ -0,0 +1,178 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/ipfilter"
+)
+
+// memoryAuditSink collects written audit records.
+type memoryAuditSink struct {
+	mu      sync.Mutex
+	batches [][]AuditRecord
+	err     error
+	closed  bool
+}
+
+func (s *memoryAuditSink) Write(ctx context.Context, records []AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.batches = append(s.batches, records)
+	return nil
+}
+
+func (s *memoryAuditSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *memoryAuditSink) records() []AuditRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var records []AuditRecord
+	for _, batch := range s.batches {
+		records = append(records, batch...)
+	}
+	return records
+}
+
+func TestHandler_AuditsEveryEvent(t *testing.T) {
+	secretsPath := filepath.Join(t.TempDir(), "strava_auth.json")
+	writeTestSecretsFile(t, secretsPath, map[string]any{
+		"webhook_verify_token":    "test-token",
+		"webhook_subscription_id": 12345,
+	})
+	filter, err := ipfilter.Parse("192.0.2.0/24", "")
+	if err != nil {
+		t.Fatalf("ipfilter.Parse failed: %v", err)
+	}
+	sink := &memoryAuditSink{}
+	handler := NewHandlerWithPublisher(&Config{IPFilter: filter}, &MockPublisher{})
+	handler.secrets = NewSecretCache(secretsPath, time.Minute)
+	handler.audit = NewAuditLog(sink, time.Hour)
+
+	post := func(remoteAddr, body string) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	post("192.0.2.1:1234", `{"aspect_type":"create","object_type":"activity","object_id":1,"owner_id":7,"event_time":1,"subscription_id":12345}`)
+	post("192.0.2.1:1234", `{"aspect_type":"update","object_type":"athlete","object_id":7,"owner_id":7,"event_time":1,"subscription_id":12345}`)
+	post("192.0.2.1:1234", `{"aspect_type":"create","object_type":"activity","object_id":2,"owner_id":7,"event_time":1,"subscription_id":999}`)
+	post("192.0.2.1:1234", `{not json`)
+	post("198.51.100.1:1234", `{}`)
+	if err := handler.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	want := []AuditRecord{
+		{Outcome: AuditPublished, StatusCode: http.StatusCreated, ObjectID: 1},
+		{Outcome: AuditIgnored, Reason: reasonNonActivity, StatusCode: http.StatusCreated, ObjectID: 7},
+		{Outcome: AuditRejected, Reason: reasonSubscription, StatusCode: http.StatusUnauthorized, ObjectID: 2},
+		{Outcome: AuditRejected, Reason: reasonInvalidJSON, StatusCode: http.StatusBadRequest},
+		{Outcome: AuditRejected, Reason: reasonIPFiltered, StatusCode: http.StatusForbidden},
+	}
+	got := sink.records()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d audit records, got %d: %+v", len(want), len(got), got)
+	}
+	for i, record := range got {
+		if record.Outcome != want[i].Outcome || record.Reason != want[i].Reason ||
+			record.StatusCode != want[i].StatusCode || record.ObjectID != want[i].ObjectID {
+			t.Errorf("record %d: got %+v, want %+v", i, record, want[i])
+		}
+		if record.CorrelationID == "" || record.ClientIP == "" {
+			t.Errorf("record %d: missing correlation ID or client IP: %+v", i, record)
+		}
+	}
+	if !sink.closed {
+		t.Error("expected Close to close the audit sink")
+	}
+}
+
+func TestAuditLog_FlushesOnInterval(t *testing.T) {
+	sink := &memoryAuditSink{}
+	audit := NewAuditLog(sink, 10*time.Millisecond)
+	defer audit.Close(context.Background())
+
+	audit.Record(AuditRecord{CorrelationID: "corr-1"})
+	deadline := time.Now().Add(time.Second)
+	for len(sink.records()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the record to be written within the flush interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAuditLog_WriteFailureDoesNotBlock(t *testing.T) {
+	sink := &memoryAuditSink{err: errors.New("bucket not found")}
+	audit := NewAuditLog(sink, time.Hour)
+
+	audit.Record(AuditRecord{CorrelationID: "corr-1"})
+	if err := audit.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	// Recording after Close is a no-op
+	audit.Record(AuditRecord{CorrelationID: "corr-2"})
+
+	var nilLog *AuditLog
+	nilLog.Record(AuditRecord{CorrelationID: "corr-3"})
+}
+
+func TestAuditObjectName(t *testing.T) {
+	record := AuditRecord{ReceivedAt: time.Date(2024, 3, 5, 23, 0, 0, 0, time.UTC)}
+	got := auditObjectName("audit", record, "abc")
+	want := "audit/2024/03/05/1709679600000000000-abc.ndjson"
+	if got != want {
+		t.Errorf("auditObjectName() = %q, want %q", got, want)
+	}
+}
+
+func TestParseBigQueryTable(t *testing.T) {
+	tests := []struct {
+		table, projectID string
+		want             string
+		wantErr          bool
+	}{
+		{table: "p.d.t", want: "p/d/t"},
+		{table: "d.t", projectID: "p", want: "p/d/t"},
+		{table: "d.t", wantErr: true},
+		{table: "t", projectID: "p", wantErr: true},
+		{table: "p..t", wantErr: true},
+	}
+	for _, tt := range tests {
+		project, dataset, table, err := parseBigQueryTable(tt.table, tt.projectID)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseBigQueryTable(%q, %q) error = %v, wantErr %v", tt.table, tt.projectID, err, tt.wantErr)
+			continue
+		}
+		if got := project + "/" + dataset + "/" + table; !tt.wantErr && got != tt.want {
+			t.Errorf("parseBigQueryTable(%q, %q) = %q, want %q", tt.table, tt.projectID, got, tt.want)
+		}
+	}
+}
+
+func TestAuditRows(t *testing.T) {
+	rows, err := auditRows([]AuditRecord{{CorrelationID: "corr-1", Outcome: AuditRejected, Reason: reasonInvalidJSON, StatusCode: 400}})
+	if err != nil {
+		t.Fatalf("auditRows failed: %v", err)
+	}
+	if rows[0].InsertId != "corr-1" {
+		t.Errorf("expected the correlation ID as insert ID, got %q", rows[0].InsertId)
+	}
+	if rows[0].Json["reason"] != reasonInvalidJSON || rows[0].Json["status_code"] != float64(400) {
+		t.Errorf("unexpected row %v", rows[0].Json)
+	}
+}
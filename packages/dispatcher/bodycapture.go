@@ -0,0 +1,148 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+const (
+	// DefaultBodyCapturePrefix is the object prefix captured bodies are
+	// written under.
+	DefaultBodyCapturePrefix = "debug/bodies/"
+
+	// DefaultBodyCaptureMaxBytes caps how much of each body is kept.
+	DefaultBodyCaptureMaxBytes = 16 << 10
+
+	// bodyCaptureWriteTimeout bounds a capture write; the request is failing
+	// anyway, so it shouldn't wait long.
+	bodyCaptureWriteTimeout = 5 * time.Second
+)
+
+// redactedValue replaces the values of sensitive fields in captured bodies.
+const redactedValue = "[REDACTED]"
+
+// sensitiveField matches string-valued JSON fields whose name mentions a
+// token, secret or password. It works on text, since captured bodies are
+// by definition not valid JSON.
+var sensitiveField = regexp.MustCompile(`(?i)("[^"]*(?:token|secret|password)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"?`)
+
+// BodyCapture is a webhook body the dispatcher could not decode.
+type BodyCapture struct {
+	CapturedAt    time.Time `json:"captured_at"`
+	CorrelationID string    `json:"correlation_id"`
+	Error         string    `json:"error"`
+	// Size is the body's length before truncation.
+	Size      int    `json:"size"`
+	Truncated bool   `json:"truncated"`
+	Body      string `json:"body"`
+}
+
+// NewBodyCapture returns a capture of body, redacted and truncated to
+// maxBytes.
+func NewBodyCapture(body []byte, maxBytes int, correlationID string, decodeErr error) BodyCapture {
+	capture := BodyCapture{
+		CapturedAt:    time.Now(),
+		CorrelationID: correlationID,
+		Error:         decodeErr.Error(),
+		Size:          len(body),
+	}
+	if maxBytes > 0 && len(body) > maxBytes {
+		body = body[:maxBytes]
+		capture.Truncated = true
+	}
+	capture.Body = redactSensitiveFields(string(body))
+	return capture
+}
+
+// redactSensitiveFields replaces the values of token, secret and password
+// fields in body.
+func redactSensitiveFields(body string) string {
+	return sensitiveField.ReplaceAllString(body, `$1"`+redactedValue+`"`)
+}
+
+// BodyCaptureStore persists bodies of webhooks that failed to decode.
+type BodyCaptureStore interface {
+	Write(ctx context.Context, capture BodyCapture) error
+	Close() error
+}
+
+// GCSBodyCaptureStore writes each capture as a JSON object in a Cloud
+// Storage bucket, named <prefix>YYYY/MM/DD/<correlation_id>.json.
+type GCSBodyCaptureStore struct {
+	client *storage.Client
+	bucket string
+	prefix string
+}
+
+// NewGCSBodyCaptureStore creates a store writing to bucket under prefix.
+func NewGCSBodyCaptureStore(ctx context.Context, bucket, prefix string) (*GCSBodyCaptureStore, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
+	}
+	Logger.Info("Body capture store initialized", "bucket", bucket, "prefix", prefix)
+	return &GCSBodyCaptureStore{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+// Write implements the BodyCaptureStore interface.
+func (s *GCSBodyCaptureStore) Write(ctx context.Context, capture BodyCapture) error {
+	data, err := json.Marshal(capture)
+	if err != nil {
+		return fmt.Errorf("failed to marshal body capture: %w", err)
+	}
+
+	w := s.client.Bucket(s.bucket).Object(bodyCaptureObjectName(s.prefix, capture)).NewWriter(ctx)
+	w.ContentType = "application/json"
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return fmt.Errorf("failed to write body capture: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write body capture: %w", err)
+	}
+	return nil
+}
+
+// Close implements the BodyCaptureStore interface.
+func (s *GCSBodyCaptureStore) Close() error {
+	if err := s.client.Close(); err != nil {
+		return fmt.Errorf("failed to close storage client: %w", err)
+	}
+	return nil
+}
+
+// bodyCaptureObjectName partitions captures by date, like dead-letter
+// records.
+func bodyCaptureObjectName(prefix string, capture BodyCapture) string {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix + path.Join(capture.CapturedAt.UTC().Format("2006/01/02"), capture.CorrelationID+".json")
+}
+
+// captureBody writes the body of a webhook that failed to decode to the
+// handler's capture store, if one is configured. Failures are only logged.
+func (h *Handler) captureBody(ctx context.Context, body []byte, correlationID string, decodeErr error) {
+	if h.bodyCapture == nil {
+		return
+	}
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), bodyCaptureWriteTimeout)
+	defer cancel()
+
+	capture := NewBodyCapture(body, h.config.BodyCaptureMaxBytes, correlationID, decodeErr)
+	if err := h.bodyCapture.Write(writeCtx, capture); err != nil {
+		Logger.ErrorContext(ctx, "Failed to capture webhook body", "correlation_id", correlationID, "error", err)
+		return
+	}
+	Logger.InfoContext(ctx, "Captured undecodable webhook body",
+		"correlation_id", correlationID,
+		"size", capture.Size,
+		"truncated", capture.Truncated)
+}
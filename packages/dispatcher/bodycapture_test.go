@@ -0,0 +1,77 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeBodyCaptureStore records captures.
+type fakeBodyCaptureStore struct {
+	captures []BodyCapture
+	closed   bool
+}
+
+func (s *fakeBodyCaptureStore) Write(ctx context.Context, capture BodyCapture) error {
+	s.captures = append(s.captures, capture)
+	return nil
+}
+
+func (s *fakeBodyCaptureStore) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestRedactSensitiveFields(t *testing.T) {
+	tests := map[string]string{
+		`{"access_token": "abc123", "object_id": 1`:     `{"access_token": "[REDACTED]", "object_id": 1`,
+		`{"Refresh_Token":"a\"b","x":"y"}`:              `{"Refresh_Token":"[REDACTED]","x":"y"}`,
+		`{"client_secret":"s3cr3t`:                      `{"client_secret":"[REDACTED]"`,
+		`{"aspect_type":"create","updates":{"title":1}`: `{"aspect_type":"create","updates":{"title":1}`,
+	}
+	for body, want := range tests {
+		if got := redactSensitiveFields(body); got != want {
+			t.Errorf("redactSensitiveFields(%q) = %q, want %q", body, got, want)
+		}
+	}
+}
+
+func TestNewBodyCapture_Truncates(t *testing.T) {
+	capture := NewBodyCapture([]byte(`{"object_id": 12345`), 5, "corr-1", errors.New("unexpected EOF"))
+	if capture.Body != `{"obj` || !capture.Truncated || capture.Size != 19 {
+		t.Errorf("unexpected capture: %+v", capture)
+	}
+	if capture.CorrelationID != "corr-1" || capture.Error != "unexpected EOF" {
+		t.Errorf("unexpected capture metadata: %+v", capture)
+	}
+}
+
+func TestHandler_CapturesUndecodableBody(t *testing.T) {
+	store := &fakeBodyCaptureStore{}
+	handler := NewHandlerWithPublisher(&Config{BodyCaptureMaxBytes: DefaultBodyCaptureMaxBytes}, &MockPublisher{})
+	handler.bodyCapture = store
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"aspect_type":"create","verify_token":"t0k3n",`))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	if len(store.captures) != 1 {
+		t.Fatalf("expected one capture, got %d", len(store.captures))
+	}
+	if body := store.captures[0].Body; strings.Contains(body, "t0k3n") || !strings.Contains(body, `"aspect_type":"create"`) {
+		t.Errorf("expected redacted body, got %q", body)
+	}
+
+	if err := handler.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !store.closed {
+		t.Error("expected capture store to be closed")
+	}
+}
@@ -0,0 +1,217 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBreakerFailureThreshold is how many consecutive failed publishes
+	// open the circuit.
+	DefaultBreakerFailureThreshold = 5
+	// DefaultBreakerOpenTimeout is how long the circuit stays open before a
+	// probe publish is let through.
+	DefaultBreakerOpenTimeout = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned for events rejected while the circuit is open
+// and no fallback publisher is configured.
+var ErrCircuitOpen = errors.New("publisher circuit breaker is open")
+
+// BreakerState is the state of a CircuitBreakerPublisher.
+type BreakerState int
+
+const (
+	// BreakerClosed passes every publish through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects publishes without calling the wrapped publisher.
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe through to test for recovery.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// BreakerStats is a snapshot of a circuit breaker's state and counters.
+type BreakerStats struct {
+	State               BreakerState
+	ConsecutiveFailures int
+	// Opened counts transitions into the open state.
+	Opened int64
+	// Rejected counts events short-circuited while open.
+	Rejected int64
+	// Fallbacks counts rejected events handed to the fallback publisher.
+	Fallbacks int64
+}
+
+// CircuitBreakerPublisher stops calling the wrapped publisher after
+// failureThreshold consecutive failures, so webhooks fail fast (or go to the
+// fallback) during an outage instead of each waiting out its timeout. After
+// openTimeout one probe is let through: success closes the circuit, failure
+// re-opens it.
+type CircuitBreakerPublisher struct {
+	openedAt         time.Time
+	publisher        Publisher
+	fallback         Publisher
+	now              func() time.Time
+	stats            BreakerStats
+	failureThreshold int
+	openTimeout      time.Duration
+	mu               sync.Mutex
+	probing          bool
+}
+
+// NewCircuitBreakerPublisher wraps publisher. fallback, if non-nil, receives
+// events rejected while the circuit is open.
+func NewCircuitBreakerPublisher(publisher, fallback Publisher, failureThreshold int, openTimeout time.Duration) *CircuitBreakerPublisher {
+	if failureThreshold <= 0 {
+		failureThreshold = DefaultBreakerFailureThreshold
+	}
+	if openTimeout <= 0 {
+		openTimeout = DefaultBreakerOpenTimeout
+	}
+	return &CircuitBreakerPublisher{
+		publisher:        publisher,
+		fallback:         fallback,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		now:              time.Now,
+	}
+}
+
+// Publish implements the Publisher interface.
+func (b *CircuitBreakerPublisher) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	return b.PublishBatch(ctx, []PendingEvent{newPendingEvent(ctx, webhook, correlationID)})[0]
+}
+
+// PublishBatch implements the BatchPublisher interface. A batch counts as
+// one failure only when none of its events were published.
+func (b *CircuitBreakerPublisher) PublishBatch(ctx context.Context, events []PendingEvent) []error {
+	if !b.allow() {
+		return b.reject(ctx, events)
+	}
+
+	errs := publishAll(ctx, b.publisher, events)
+	failed := true
+	for _, err := range errs {
+		if err == nil {
+			failed = false
+			break
+		}
+	}
+	// A caller giving up says nothing about the publisher's health
+	if failed && errors.Is(ctx.Err(), context.Canceled) {
+		b.release()
+		return errs
+	}
+	b.record(failed)
+	return errs
+}
+
+// Stats returns a snapshot of the breaker's state and counters.
+func (b *CircuitBreakerPublisher) Stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
+
+// Close implements the Publisher interface.
+func (b *CircuitBreakerPublisher) Close(ctx context.Context) error {
+	errs := []error{b.publisher.Close(ctx)}
+	if b.fallback != nil {
+		errs = append(errs, b.fallback.Close(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// allow reports whether a publish may reach the wrapped publisher, moving
+// an expired open circuit to half-open and admitting one probe.
+func (b *CircuitBreakerPublisher) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.stats.State {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.setState(BreakerHalfOpen)
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// release gives up a probe slot without judging the outcome.
+func (b *CircuitBreakerPublisher) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *CircuitBreakerPublisher) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.stats.ConsecutiveFailures = 0
+		if b.stats.State != BreakerClosed {
+			b.setState(BreakerClosed)
+		}
+		return
+	}
+
+	b.stats.ConsecutiveFailures++
+	if b.stats.State == BreakerHalfOpen || b.stats.ConsecutiveFailures >= b.failureThreshold {
+		b.openedAt = b.now()
+		b.stats.Opened++
+		b.setState(BreakerOpen)
+	}
+}
+
+// setState must be called with mu held.
+func (b *CircuitBreakerPublisher) setState(state BreakerState) {
+	Logger.Warn("Publisher circuit breaker state change",
+		"from", b.stats.State.String(),
+		"to", state.String(),
+		"consecutive_failures", b.stats.ConsecutiveFailures,
+		"rejected_total", b.stats.Rejected)
+	b.stats.State = state
+}
+
+func (b *CircuitBreakerPublisher) reject(ctx context.Context, events []PendingEvent) []error {
+	b.mu.Lock()
+	b.stats.Rejected += int64(len(events))
+	if b.fallback != nil {
+		b.stats.Fallbacks += int64(len(events))
+	}
+	b.mu.Unlock()
+
+	if b.fallback != nil {
+		return publishAll(ctx, b.fallback, events)
+	}
+	errs := make([]error, len(events))
+	for i := range errs {
+		errs[i] = ErrCircuitOpen
+	}
+	return errs
+}
@@ -0,0 +1,129 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerPublisher_OpensAndRecovers(t *testing.T) {
+	inner := &MockPublisher{PublishErr: errors.New("pubsub down")}
+	breaker := NewCircuitBreakerPublisher(inner, nil, 3, time.Minute)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	breaker.now = func() time.Time { return now }
+
+	for range 3 {
+		if err := breaker.Publish(context.Background(), WebhookRequest{}, "corr"); errors.Is(err, ErrCircuitOpen) {
+			t.Fatal("expected publishes to reach the publisher before the threshold")
+		}
+	}
+	if state := breaker.Stats().State; state != BreakerOpen {
+		t.Fatalf("expected open circuit after 3 failures, got %s", state)
+	}
+
+	// While open, publishes short-circuit
+	if err := breaker.Publish(context.Background(), WebhookRequest{}, "corr"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	// A failed probe re-opens the circuit
+	now = now.Add(time.Minute)
+	if err := breaker.Publish(context.Background(), WebhookRequest{}, "corr"); errors.Is(err, ErrCircuitOpen) {
+		t.Error("expected the probe to reach the publisher")
+	}
+	if state := breaker.Stats().State; state != BreakerOpen {
+		t.Errorf("expected circuit to re-open after failed probe, got %s", state)
+	}
+
+	// A successful probe closes it
+	now = now.Add(time.Minute)
+	inner.PublishErr = nil
+	if err := breaker.Publish(context.Background(), WebhookRequest{ObjectID: 1}, "corr"); err != nil {
+		t.Errorf("expected probe to succeed, got %v", err)
+	}
+
+	stats := breaker.Stats()
+	if stats.State != BreakerClosed || stats.ConsecutiveFailures != 0 {
+		t.Errorf("expected closed circuit with no failures, got %+v", stats)
+	}
+	if stats.Opened != 2 || stats.Rejected != 1 {
+		t.Errorf("expected 2 opens and 1 rejection, got %+v", stats)
+	}
+}
+
+func TestCircuitBreakerPublisher_HalfOpenAllowsSingleProbe(t *testing.T) {
+	breaker := NewCircuitBreakerPublisher(&MockPublisher{}, nil, 1, time.Minute)
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+	breaker.record(true)
+
+	now = now.Add(time.Minute)
+	if !breaker.allow() {
+		t.Fatal("expected the first call after the timeout to probe")
+	}
+	if breaker.allow() {
+		t.Error("expected concurrent calls to be rejected while probing")
+	}
+}
+
+func TestCircuitBreakerPublisher_UsesFallbackWhenOpen(t *testing.T) {
+	fallback := &MockPublisher{}
+	breaker := NewCircuitBreakerPublisher(&MockPublisher{PublishErr: errors.New("down")}, fallback, 1, time.Minute)
+
+	_ = breaker.Publish(context.Background(), WebhookRequest{ObjectID: 1}, "corr")
+	if err := breaker.Publish(context.Background(), WebhookRequest{ObjectID: 2}, "corr"); err != nil {
+		t.Fatalf("expected fallback publish to succeed, got %v", err)
+	}
+
+	if len(fallback.Published) != 1 || fallback.Published[0].ObjectID != 2 {
+		t.Errorf("expected rejected event in fallback, got %+v", fallback.Published)
+	}
+	if stats := breaker.Stats(); stats.Fallbacks != 1 {
+		t.Errorf("expected 1 fallback, got %d", stats.Fallbacks)
+	}
+}
+
+func TestCircuitBreakerPublisher_IgnoresCanceledRequests(t *testing.T) {
+	breaker := NewCircuitBreakerPublisher(&MockPublisher{PublishErr: context.Canceled}, nil, 1, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = breaker.Publish(ctx, WebhookRequest{}, "corr")
+
+	if state := breaker.Stats().State; state != BreakerClosed {
+		t.Errorf("expected a canceled request not to open the circuit, got %s", state)
+	}
+}
+
+func TestHandler_CircuitOpenReturns503(t *testing.T) {
+	secretsPath := t.TempDir() + "/strava_auth.json"
+	writeTestSecretsFile(t, secretsPath, map[string]any{
+		"webhook_verify_token":    "test-token",
+		"webhook_subscription_id": 12345,
+	})
+
+	breaker := NewCircuitBreakerPublisher(&MockPublisher{PublishErr: errors.New("down")}, nil, 1, time.Minute)
+	breaker.record(true)
+	handler := NewHandlerWithPublisher(&Config{}, breaker)
+	handler.secrets = NewSecretCache(secretsPath, time.Minute)
+	handler.breaker = breaker
+
+	body := `{"aspect_type":"create","event_time":1234567890,"object_id":1,"object_type":"activity","owner_id":2,"subscription_id":12345}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 while circuit is open, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/ready", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"publisher_circuit":"open"`) {
+		t.Errorf("expected ready with open circuit reported, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,71 @@
+package dispatcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// MessageFormat selects how webhooks are encoded in Pub/Sub message data.
+type MessageFormat string
+
+const (
+	// MessageFormatRaw publishes the webhook JSON as received from Strava.
+	MessageFormatRaw MessageFormat = "raw"
+	// MessageFormatCloudEvents wraps the webhook in a CloudEvents 1.0
+	// structured-mode envelope.
+	MessageFormatCloudEvents MessageFormat = "cloudevents"
+
+	cloudEventsSpecVersion = "1.0"
+	cloudEventsContentType = "application/cloudevents+json"
+)
+
+// ParseMessageFormat validates a MESSAGE_FORMAT value; empty means raw.
+func ParseMessageFormat(value string) (MessageFormat, error) {
+	switch format := MessageFormat(value); format {
+	case "", MessageFormatRaw:
+		return MessageFormatRaw, nil
+	case MessageFormatCloudEvents:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown message format %q (want %q or %q)", value, MessageFormatRaw, MessageFormatCloudEvents)
+	}
+}
+
+// CloudEvent is a CloudEvents 1.0 structured-mode event carrying a webhook.
+// See https://github.com/cloudevents/spec/blob/v1.0.2/cloudevents/spec.md.
+type CloudEvent struct {
+	SpecVersion     string         `json:"specversion"`
+	Type            string         `json:"type"`
+	Source          string         `json:"source"`
+	ID              string         `json:"id"`
+	Time            string         `json:"time"`
+	Subject         string         `json:"subject"`
+	DataContentType string         `json:"datacontenttype"`
+	Data            WebhookRequest `json:"data"`
+}
+
+// NewCloudEvent wraps webhook. The type is "com.strava.<object>.<aspect>"
+// (e.g. com.strava.activity.create), the source is the push subscription,
+// the ID is the correlation ID and the time is the Strava event time.
+func NewCloudEvent(webhook WebhookRequest, correlationID string) CloudEvent {
+	return CloudEvent{
+		SpecVersion:     cloudEventsSpecVersion,
+		Type:            fmt.Sprintf("com.strava.%s.%s", webhook.ObjectType, webhook.AspectType),
+		Source:          fmt.Sprintf("https://www.strava.com/api/v3/push_subscriptions/%d", webhook.SubscriptionID),
+		ID:              correlationID,
+		Time:            time.Unix(webhook.EventTime, 0).UTC().Format(time.RFC3339),
+		Subject:         strconv.FormatInt(webhook.ObjectID, 10),
+		DataContentType: "application/json",
+		Data:            webhook,
+	}
+}
+
+// encodeMessage returns the Pub/Sub message data for webhook in format.
+func encodeMessage(format MessageFormat, webhook WebhookRequest, correlationID string) ([]byte, error) {
+	if format == MessageFormatCloudEvents {
+		return json.Marshal(NewCloudEvent(webhook, correlationID))
+	}
+	return json.Marshal(webhook)
+}
@@ -0,0 +1,69 @@
+package dispatcher
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMessageFormat(t *testing.T) {
+	for value, want := range map[string]MessageFormat{"": MessageFormatRaw, "raw": MessageFormatRaw, "cloudevents": MessageFormatCloudEvents} {
+		got, err := ParseMessageFormat(value)
+		if err != nil || got != want {
+			t.Errorf("ParseMessageFormat(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+	if _, err := ParseMessageFormat("avro"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestEncodeMessage_CloudEvents(t *testing.T) {
+	webhook := WebhookRequest{
+		AspectType:     AspectCreate,
+		ObjectType:     ObjectActivity,
+		EventTime:      1717243200,
+		ObjectID:       42,
+		OwnerID:        7,
+		SubscriptionID: 12345,
+	}
+
+	data, err := encodeMessage(MessageFormatCloudEvents, webhook, "corr-1")
+	if err != nil {
+		t.Fatalf("encodeMessage failed: %v", err)
+	}
+
+	var event map[string]any
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	want := map[string]string{
+		"specversion":     "1.0",
+		"type":            "com.strava.activity.create",
+		"source":          "https://www.strava.com/api/v3/push_subscriptions/12345",
+		"id":              "corr-1",
+		"time":            "2024-06-01T12:00:00Z",
+		"subject":         "42",
+		"datacontenttype": "application/json",
+	}
+	for key, value := range want {
+		if event[key] != value {
+			t.Errorf("%s = %v, want %q", key, event[key], value)
+		}
+	}
+	payload, ok := event["data"].(map[string]any)
+	if !ok || payload["object_id"] != float64(42) || payload["owner_id"] != float64(7) {
+		t.Errorf("expected webhook as data, got %v", event["data"])
+	}
+}
+
+func TestEncodeMessage_Raw(t *testing.T) {
+	data, err := encodeMessage(MessageFormatRaw, WebhookRequest{ObjectID: 42}, "corr-1")
+	if err != nil {
+		t.Fatalf("encodeMessage failed: %v", err)
+	}
+
+	var decoded WebhookRequest
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.ObjectID != 42 {
+		t.Errorf("expected raw webhook JSON, got %s", data)
+	}
+}
@@ -0,0 +1,421 @@
+// Command simulate posts synthetic Strava webhook events to a dispatcher,
+// for load testing and for exercising its validation and dedupe paths
+// locally.
+//
+//	simulate -url http://localhost:8080/ -subscription-id 123456 -rate 20 -duration 1m
+//	simulate -athletes 50 -aspects create=6,update=3,delete=1 -invalid 10 -duplicates 5
+//
+// Events are generated at -rate per second from -workers concurrent
+// requests until -duration has passed, -count events were sent or the
+// command is interrupted, and a summary of the responses by event kind and
+// status, with latency percentiles, is printed at the end.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/dispatcher"
+	"golang.org/x/time/rate"
+)
+
+// Event kinds, as counted in the summary.
+const (
+	kindValid     = "valid"
+	kindDuplicate = "duplicate"
+	kindInvalid   = "invalid"
+)
+
+// invalidPayloads are the ways an invalid event is broken, each rejected
+// by a different dispatcher check.
+var invalidPayloads = []string{
+	"malformed_json",
+	"unknown_aspect_type",
+	"unknown_object_type",
+	"missing_object_id",
+	"wrong_subscription_id",
+}
+
+// recentEvents is how many sent events a duplicate may repeat.
+const recentEvents = 100
+
+func main() {
+	log.SetFlags(0)
+	configFile := flag.String("config", "", "Config file with settings like STRAVA_WEBHOOK_SUBSCRIPTION_ID (default $CONFIG_FILE)")
+	flag.String("url", "", "Dispatcher webhook URL (default $DISPATCHER_URL or http://localhost:8080/)")
+	flag.String("subscription-id", "", "Subscription ID the dispatcher accepts (default $STRAVA_WEBHOOK_SUBSCRIPTION_ID)")
+	flag.String("replay-token", "", "X-Replay-Token to send, letting events past an IP allowlist (default $REPLAY_TOKEN)")
+	ratePerSecond := flag.Float64("rate", 5, "Events per second")
+	duration := flag.Duration("duration", 30*time.Second, "How long to send events (0 = until -count or interrupted)")
+	count := flag.Int("count", 0, "Events to send (0 = until -duration or interrupted)")
+	workers := flag.Int("workers", 8, "Concurrent requests")
+	athletes := flag.Int("athletes", 10, "Distinct athletes owning the events")
+	aspects := flag.String("aspects", "create=7,update=2,delete=1", "Relative weights of activity aspect types")
+	invalid := flag.Float64("invalid", 0, "Percentage of events with an invalid payload")
+	duplicates := flag.Float64("duplicates", 0, "Percentage of events repeating a recent one, as Strava redeliveries do")
+	seed := flag.Uint64("seed", 0, "Random seed, for repeatable runs (default: random)")
+	timeout := flag.Duration("timeout", 10*time.Second, "Per-request timeout")
+	flag.Parse()
+	if err := config.Setup(*configFile); err != nil {
+		log.Fatal(err)
+	}
+	config.BindFlags(flag.CommandLine, map[string]string{
+		"url":             "DISPATCHER_URL",
+		"subscription-id": "STRAVA_WEBHOOK_SUBSCRIPTION_ID",
+		"replay-token":    "REPLAY_TOKEN",
+	})
+
+	subscriptionID, err := strconv.Atoi(config.Get("STRAVA_WEBHOOK_SUBSCRIPTION_ID"))
+	if err != nil || subscriptionID <= 0 {
+		log.Fatal("-subscription-id is required")
+	}
+	weights, err := parseAspects(*aspects)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch {
+	case *ratePerSecond <= 0:
+		log.Fatal("-rate must be positive")
+	case *workers <= 0:
+		log.Fatal("-workers must be positive")
+	case *athletes <= 0:
+		log.Fatal("-athletes must be positive")
+	case *invalid < 0 || *duplicates < 0 || *invalid+*duplicates > 100:
+		log.Fatal("-invalid and -duplicates must be percentages adding up to at most 100")
+	}
+	if *seed == 0 {
+		*seed = rand.Uint64()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	url := config.GetOrDefault("DISPATCHER_URL", "http://localhost:8080/")
+	if _, err := http.NewRequest(http.MethodPost, url, nil); err != nil {
+		log.Fatalf("Invalid -url %q: %v", url, err)
+	}
+	log.Printf("Sending %.1f events/s to %s (seed %d)", *ratePerSecond, url, *seed)
+
+	gen := &generator{
+		rand:           rand.New(rand.NewPCG(*seed, *seed)),
+		subscriptionID: subscriptionID,
+		athletes:       *athletes,
+		aspects:        weights,
+		invalid:        *invalid,
+		duplicates:     *duplicates,
+		nextID:         9_000_000_000 + int64(*seed%1_000_000)*1000,
+		created:        map[int64][]int64{},
+	}
+	s := &sender{
+		client:      &http.Client{Timeout: *timeout},
+		url:         url,
+		replayToken: config.Get("REPLAY_TOKEN"),
+		stats:       newStats(),
+	}
+
+	jobs := make(chan event)
+	var wg sync.WaitGroup
+	for range *workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range jobs {
+				s.send(e)
+			}
+		}()
+	}
+
+	started := time.Now()
+	limiter := rate.NewLimiter(rate.Limit(*ratePerSecond), 1)
+	for sent := 0; *count == 0 || sent < *count; sent++ {
+		if limiter.Wait(ctx) != nil {
+			break
+		}
+		select {
+		case jobs <- gen.next():
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	s.stats.print(os.Stdout, time.Since(started))
+}
+
+// event is one generated webhook delivery.
+type event struct {
+	kind string
+	// reason is the invalidPayloads entry of an invalid event.
+	reason string
+	body   []byte
+}
+
+// generator produces the event stream. It is used from one goroutine.
+type generator struct {
+	rand           *rand.Rand
+	subscriptionID int
+	athletes       int
+	aspects        []aspectWeight
+	invalid        float64
+	duplicates     float64
+	nextID         int64
+	// created holds the activity IDs created per athlete, which updates
+	// and deletes refer to.
+	created map[int64][]int64
+	recent  [][]byte
+}
+
+// next returns the next event: a duplicate, an invalid payload or a valid
+// activity event, in the configured proportions.
+func (g *generator) next() event {
+	roll := g.rand.Float64() * 100
+	if roll < g.duplicates {
+		// The first events have nothing to repeat yet
+		if len(g.recent) > 0 {
+			return event{kind: kindDuplicate, body: g.recent[g.rand.IntN(len(g.recent))]}
+		}
+	} else if roll < g.duplicates+g.invalid {
+		reason := invalidPayloads[g.rand.IntN(len(invalidPayloads))]
+		return event{kind: kindInvalid, reason: reason, body: g.invalidBody(reason)}
+	}
+
+	body := mustMarshal(g.webhook())
+	g.recent = append(g.recent, body)
+	if len(g.recent) > recentEvents {
+		g.recent = g.recent[1:]
+	}
+	return event{kind: kindValid, body: body}
+}
+
+// webhook returns a valid activity event for a random athlete. Updates and
+// deletes refer to an activity created earlier in the run when there is
+// one.
+func (g *generator) webhook() dispatcher.WebhookRequest {
+	ownerID := 1_000_000 + int64(g.rand.IntN(g.athletes))
+	webhook := dispatcher.WebhookRequest{
+		AspectType:     g.aspect(),
+		ObjectType:     dispatcher.ObjectActivity,
+		OwnerID:        ownerID,
+		SubscriptionID: g.subscriptionID,
+		EventTime:      time.Now().Unix(),
+		Updates:        map[string]any{},
+	}
+
+	created := g.created[ownerID]
+	switch {
+	case webhook.AspectType == dispatcher.AspectCreate || len(created) == 0:
+		g.nextID++
+		webhook.ObjectID = g.nextID
+		if webhook.AspectType == dispatcher.AspectCreate {
+			g.created[ownerID] = append(created, webhook.ObjectID)
+		}
+	case webhook.AspectType == dispatcher.AspectDelete:
+		i := g.rand.IntN(len(created))
+		webhook.ObjectID = created[i]
+		g.created[ownerID] = slices.Delete(created, i, i+1)
+	default:
+		webhook.ObjectID = created[g.rand.IntN(len(created))]
+	}
+	if webhook.AspectType == dispatcher.AspectUpdate {
+		webhook.Updates["title"] = fmt.Sprintf("Simulated ride %d", webhook.ObjectID)
+	}
+	return webhook
+}
+
+// aspect picks an aspect type by weight.
+func (g *generator) aspect() string {
+	var total int
+	for _, a := range g.aspects {
+		total += a.weight
+	}
+	pick := g.rand.IntN(total)
+	for _, a := range g.aspects {
+		if pick < a.weight {
+			return a.aspect
+		}
+		pick -= a.weight
+	}
+	return g.aspects[len(g.aspects)-1].aspect
+}
+
+// invalidBody returns a payload broken as reason describes.
+func (g *generator) invalidBody(reason string) []byte {
+	webhook := g.webhook()
+	switch reason {
+	case "malformed_json":
+		body := mustMarshal(webhook)
+		return body[:len(body)/2]
+	case "unknown_aspect_type":
+		webhook.AspectType = "rename"
+	case "unknown_object_type":
+		webhook.ObjectType = "route"
+	case "missing_object_id":
+		webhook.ObjectID = 0
+	case "wrong_subscription_id":
+		webhook.SubscriptionID++
+	}
+	return mustMarshal(webhook)
+}
+
+func mustMarshal(webhook dispatcher.WebhookRequest) []byte {
+	body, err := json.Marshal(webhook)
+	if err != nil {
+		panic(err)
+	}
+	return body
+}
+
+// aspectWeight is one entry of -aspects.
+type aspectWeight struct {
+	aspect string
+	weight int
+}
+
+// parseAspects parses -aspects, e.g. "create=7,update=2,delete=1".
+func parseAspects(value string) ([]aspectWeight, error) {
+	var weights []aspectWeight
+	var total int
+	for entry := range strings.SplitSeq(value, ",") {
+		aspect, w, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		weight, err := strconv.Atoi(w)
+		if !slices.Contains([]string{dispatcher.AspectCreate, dispatcher.AspectUpdate, dispatcher.AspectDelete}, aspect) || err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid -aspects entry %q (want create, update or delete=weight)", entry)
+		}
+		weights = append(weights, aspectWeight{aspect: aspect, weight: weight})
+		total += weight
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("-aspects %q has no positive weight", value)
+	}
+	return weights, nil
+}
+
+// sender posts events and records the responses.
+type sender struct {
+	client      *http.Client
+	url         string
+	replayToken string
+	stats       *stats
+}
+
+func (s *sender) send(e event) {
+	// The URL was checked at startup
+	req, _ := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(e.body))
+	req.Header.Set("Content-Type", "application/json")
+	if s.replayToken != "" {
+		req.Header.Set(dispatcher.ReplayTokenHeader, s.replayToken)
+	}
+
+	started := time.Now()
+	resp, err := s.client.Do(req)
+	status := "error"
+	if err == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	s.stats.record(e, status, time.Since(started))
+}
+
+// stats counts responses by event kind and status.
+type stats struct {
+	mu        sync.Mutex
+	counts    map[string]map[string]int
+	invalid   map[string]map[string]int
+	latencies []time.Duration
+}
+
+func newStats() *stats {
+	return &stats{counts: map[string]map[string]int{}, invalid: map[string]map[string]int{}}
+}
+
+func (s *stats) record(e event, status string, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	increment(s.counts, e.kind, status)
+	if e.kind == kindInvalid {
+		increment(s.invalid, e.reason, status)
+	}
+	if status != "error" {
+		s.latencies = append(s.latencies, latency)
+	}
+}
+
+func increment(counts map[string]map[string]int, key, status string) {
+	if counts[key] == nil {
+		counts[key] = map[string]int{}
+	}
+	counts[key][status]++
+}
+
+// print writes the summary. Transport errors are counted under status
+// "error" and left out of the latencies.
+func (s *stats) print(w io.Writer, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var total int
+	for _, statuses := range s.counts {
+		for _, n := range statuses {
+			total += n
+		}
+	}
+	fmt.Fprintf(w, "Sent %d events in %s (%.1f/s)\n", total, elapsed.Round(time.Millisecond), float64(total)/elapsed.Seconds())
+	for _, kind := range []string{kindValid, kindDuplicate, kindInvalid} {
+		if statuses, ok := s.counts[kind]; ok {
+			fmt.Fprintf(w, "  %-10s %s\n", kind, formatStatuses(statuses))
+		}
+	}
+	for _, reason := range invalidPayloads {
+		if statuses, ok := s.invalid[reason]; ok {
+			fmt.Fprintf(w, "    %-22s %s\n", reason, formatStatuses(statuses))
+		}
+	}
+
+	if len(s.latencies) == 0 {
+		return
+	}
+	slices.Sort(s.latencies)
+	percentile := func(p float64) time.Duration {
+		return s.latencies[int(p*float64(len(s.latencies)-1))].Round(time.Microsecond)
+	}
+	fmt.Fprintf(w, "Latency p50 %s, p95 %s, p99 %s, max %s\n", percentile(0.50), percentile(0.95), percentile(0.99), percentile(1))
+}
+
+// formatStatuses lists counts by status, e.g. "200=95 400=5".
+func formatStatuses(statuses map[string]int) string {
+	keys := make([]string, 0, len(statuses))
+	for status := range statuses {
+		keys = append(keys, status)
+	}
+	slices.Sort(keys)
+	parts := make([]string, len(keys))
+	for i, status := range keys {
+		parts[i] = fmt.Sprintf("%s=%d", status, statuses[status])
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,290 @@
+// Command subscription creates, views and deletes the app's Strava webhook
+// subscription with the client credentials from the Strava secrets, and
+// keeps webhook_subscription_id in those secrets up to date.
+//
+//	subscription [flags] create -callback-url https://.../webhook
+//	subscription [flags] view
+//	subscription [flags] delete [-id 123456]
+//
+// The secrets are read from, and written back to, the mounted secrets file
+// or, with -secret, the latest version of a Secret Manager secret.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/dispatcher"
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+// subscriptionIDKey is the secrets field the dispatcher checks events
+// against.
+const subscriptionIDKey = "webhook_subscription_id"
+
+func main() {
+	log.SetFlags(0)
+	configFile := flag.String("config", "", "Config file with settings like SECRET_MANAGER_SECRET (default $CONFIG_FILE)")
+	secretsFile := flag.String("secrets-file", dispatcher.DefaultSecretsPath, "Strava secrets JSON file")
+	flag.String("secret", "", "Secret Manager secret ID or projects/.../secrets/... name holding the Strava secrets, overriding -secrets-file (default $SECRET_MANAGER_SECRET)")
+	flag.String("project", "", "GCP project resolving a -secret ID (default $GCP_PROJECT_ID)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] create|view|delete [command flags]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := config.Setup(*configFile); err != nil {
+		log.Fatal(err)
+	}
+	config.BindFlags(flag.CommandLine, map[string]string{"secret": "SECRET_MANAGER_SECRET", "project": "GCP_PROJECT_ID"})
+	secret, project := config.Get("SECRET_MANAGER_SECRET"), config.Get("GCP_PROJECT_ID")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var store secretsStore = fileStore{path: *secretsFile}
+	if secret != "" {
+		smStore, err := newSecretManagerStore(ctx, secret, project)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() { _ = smStore.client.Close() }()
+		store = smStore
+	}
+
+	doc, err := readSecrets(ctx, store)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if doc.secrets.ClientID == 0 || doc.secrets.ClientSecret == "" {
+		log.Fatalf("%s has no client_id and client_secret", store)
+	}
+	client := strava.New(doc.secrets.ClientID, doc.secrets.ClientSecret, "")
+
+	command, args := flag.Arg(0), flag.Args()[1:]
+	switch command {
+	case "create":
+		err = create(ctx, client, store, doc, args)
+	case "view":
+		err = view(ctx, client, doc)
+	case "delete":
+		err = remove(ctx, client, store, doc, args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func create(ctx context.Context, client *strava.Client, store secretsStore, doc *secretsDocument, args []string) error {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	callbackURL := flags.String("callback-url", config.Get("CALLBACK_URL"), "URL of the deployed dispatcher")
+	_ = flags.Parse(args)
+	if *callbackURL == "" {
+		return errors.New("-callback-url is required")
+	}
+	if doc.secrets.WebhookVerifyToken == "" {
+		return fmt.Errorf("%s has no webhook_verify_token", store)
+	}
+
+	// Strava calls the dispatcher to verify the token before answering
+	id, err := client.CreateSubscription(ctx, *callbackURL, doc.secrets.WebhookVerifyToken)
+	if err != nil {
+		return err
+	}
+	log.Printf("Created subscription %d for %s", id, *callbackURL)
+
+	if err := doc.setSubscriptionID(id); err != nil {
+		return err
+	}
+	if err := store.Write(ctx, doc.raw); err != nil {
+		return fmt.Errorf("subscription %d was created but not saved: %w", id, err)
+	}
+	log.Printf("Saved %s=%d to %s", subscriptionIDKey, id, store)
+	return nil
+}
+
+func view(ctx context.Context, client *strava.Client, doc *secretsDocument) error {
+	subscriptions, err := client.ListSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+	if len(subscriptions) == 0 {
+		log.Print("No subscriptions")
+	}
+	for _, subscription := range subscriptions {
+		configured := ""
+		if subscription.ID == doc.secrets.WebhookSubscriptionID {
+			configured = " (configured)"
+		}
+		fmt.Printf("%d%s\t%s\tcreated %s\n", subscription.ID, configured, subscription.CallbackURL, subscription.CreatedAt.Format(time.RFC3339))
+	}
+	if id := doc.secrets.WebhookSubscriptionID; id != 0 && !slices.ContainsFunc(subscriptions, func(s strava.Subscription) bool { return s.ID == id }) {
+		log.Printf("Warning: configured %s %d doesn't exist in Strava", subscriptionIDKey, id)
+	}
+	return nil
+}
+
+func remove(ctx context.Context, client *strava.Client, store secretsStore, doc *secretsDocument, args []string) error {
+	flags := flag.NewFlagSet("delete", flag.ExitOnError)
+	id := flags.Int("id", doc.secrets.WebhookSubscriptionID, "subscription to delete (default: the configured one)")
+	_ = flags.Parse(args)
+	if *id == 0 {
+		return fmt.Errorf("no %s in %s; pass -id", subscriptionIDKey, store)
+	}
+
+	if err := client.DeleteSubscription(ctx, *id); err != nil {
+		return err
+	}
+	log.Printf("Deleted subscription %d", *id)
+
+	if *id != doc.secrets.WebhookSubscriptionID {
+		return nil
+	}
+	if err := doc.setSubscriptionID(0); err != nil {
+		return err
+	}
+	if err := store.Write(ctx, doc.raw); err != nil {
+		return fmt.Errorf("subscription %d was deleted but %s still lists it: %w", *id, store, err)
+	}
+	log.Printf("Removed %s from %s", subscriptionIDKey, store)
+	return nil
+}
+
+// secretsDocument is the Strava secrets JSON, kept raw so fields this tool
+// doesn't know survive a rewrite.
+type secretsDocument struct {
+	raw     []byte
+	secrets dispatcher.StravaSecrets
+}
+
+func readSecrets(ctx context.Context, store secretsStore) (*secretsDocument, error) {
+	raw, err := store.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	doc := &secretsDocument{raw: raw}
+	if err := json.Unmarshal(raw, &doc.secrets); err != nil {
+		return nil, fmt.Errorf("invalid secrets in %s: %w", store, err)
+	}
+	return doc, nil
+}
+
+// setSubscriptionID sets webhook_subscription_id, or removes it for 0.
+func (d *secretsDocument) setSubscriptionID(id int) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(d.raw, &fields); err != nil {
+		return fmt.Errorf("invalid secrets: %w", err)
+	}
+	if id == 0 {
+		delete(fields, subscriptionIDKey)
+	} else {
+		fields[subscriptionIDKey] = json.RawMessage(fmt.Sprint(id))
+	}
+	raw, err := json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode secrets: %w", err)
+	}
+	d.raw = append(raw, '\n')
+	d.secrets.WebhookSubscriptionID = id
+	return nil
+}
+
+// secretsStore is where the Strava secrets JSON lives.
+type secretsStore interface {
+	Read(ctx context.Context) ([]byte, error)
+	Write(ctx context.Context, data []byte) error
+	fmt.Stringer
+}
+
+type fileStore struct {
+	path string
+}
+
+func (s fileStore) Read(ctx context.Context) ([]byte, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets: %w", err)
+	}
+	return data, nil
+}
+
+// Write replaces the file, keeping its permissions. A mounted secret
+// volume is read-only; update the secret it comes from instead.
+func (s fileStore) Write(ctx context.Context, data []byte) error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to write secrets: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write secrets: %w", err)
+	}
+	return nil
+}
+
+func (s fileStore) String() string {
+	return s.path
+}
+
+// secretManagerStore reads the latest version of a secret and writes by
+// adding a new version, which running dispatchers pick up on their next
+// reload.
+type secretManagerStore struct {
+	client *secretmanager.Client
+	name   string
+}
+
+func newSecretManagerStore(ctx context.Context, secret, projectID string) (*secretManagerStore, error) {
+	name, _, _ := strings.Cut(secret, "/versions/")
+	if !strings.HasPrefix(name, "projects/") {
+		if projectID == "" {
+			return nil, fmt.Errorf("-project is required to resolve secret %q", secret)
+		}
+		name = fmt.Sprintf("projects/%s/secrets/%s", projectID, name)
+	}
+	client, err := secretmanager.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Secret Manager client: %w", err)
+	}
+	return &secretManagerStore{client: client, name: name}, nil
+}
+
+func (s *secretManagerStore) Read(ctx context.Context) ([]byte, error) {
+	resp, err := s.client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: s.name + "/versions/latest"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to access %s: %w", s.name, err)
+	}
+	return resp.GetPayload().GetData(), nil
+}
+
+func (s *secretManagerStore) Write(ctx context.Context, data []byte) error {
+	_, err := s.client.AddSecretVersion(ctx, &secretmanagerpb.AddSecretVersionRequest{
+		Parent:  s.name,
+		Payload: &secretmanagerpb.SecretPayload{Data: data},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add a version to %s: %w", s.name, err)
+	}
+	return nil
+}
+
+func (s *secretManagerStore) String() string {
+	return s.name
+}
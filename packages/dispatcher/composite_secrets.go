@@ -0,0 +1,226 @@
+package dispatcher
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secretSource is one layer of a CompositeSecretProvider.
+type secretSource interface {
+	// Secrets returns everything the source holds, reloading it if due.
+	Secrets() (StravaSecrets, error)
+}
+
+// namedSecretSource is a secret source with its SECRET_SOURCE name.
+type namedSecretSource struct {
+	source secretSource
+	name   string
+}
+
+// envSecretSource serves secrets read from the environment at startup.
+type envSecretSource struct {
+	secrets StravaSecrets
+}
+
+// newEnvSecretSource reads STRAVA_WEBHOOK_VERIFY_TOKEN,
+// STRAVA_PREVIOUS_WEBHOOK_VERIFY_TOKEN, STRAVA_WEBHOOK_SUBSCRIPTION_ID,
+// STRAVA_CLIENT_ID, STRAVA_CLIENT_SECRET and STRAVA_REFRESH_TOKEN.
+func newEnvSecretSource() (*envSecretSource, error) {
+	secrets := StravaSecrets{
+		WebhookVerifyToken:         os.Getenv("STRAVA_WEBHOOK_VERIFY_TOKEN"),
+		PreviousWebhookVerifyToken: os.Getenv("STRAVA_PREVIOUS_WEBHOOK_VERIFY_TOKEN"),
+		ClientSecret:               os.Getenv("STRAVA_CLIENT_SECRET"),
+		RefreshToken:               os.Getenv("STRAVA_REFRESH_TOKEN"),
+	}
+	var err error
+	if secrets.WebhookSubscriptionID, err = envInt("STRAVA_WEBHOOK_SUBSCRIPTION_ID"); err != nil {
+		return nil, err
+	}
+	if secrets.ClientID, err = envInt("STRAVA_CLIENT_ID"); err != nil {
+		return nil, err
+	}
+	return &envSecretSource{secrets: secrets}, nil
+}
+
+// envInt parses an optional integer environment variable.
+func envInt(key string) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	return n, nil
+}
+
+// Secrets implements secretSource.
+func (s *envSecretSource) Secrets() (StravaSecrets, error) {
+	return s.secrets, nil
+}
+
+// mergeSecrets combines layers in precedence order: each field takes the
+// first layer's non-empty value.
+func mergeSecrets(layers ...StravaSecrets) StravaSecrets {
+	var merged StravaSecrets
+	for _, layer := range layers {
+		merged.WebhookVerifyToken = firstNonZero(merged.WebhookVerifyToken, layer.WebhookVerifyToken)
+		merged.PreviousWebhookVerifyToken = firstNonZero(merged.PreviousWebhookVerifyToken, layer.PreviousWebhookVerifyToken)
+		merged.ClientSecret = firstNonZero(merged.ClientSecret, layer.ClientSecret)
+		merged.RefreshToken = firstNonZero(merged.RefreshToken, layer.RefreshToken)
+		merged.WebhookSubscriptionID = firstNonZero(merged.WebhookSubscriptionID, layer.WebhookSubscriptionID)
+		merged.ClientID = firstNonZero(merged.ClientID, layer.ClientID)
+		if len(merged.WebhookSubscriptionIDs) == 0 {
+			merged.WebhookSubscriptionIDs = layer.WebhookSubscriptionIDs
+		}
+	}
+	return merged
+}
+
+// firstNonZero returns current unless it is empty, in which case next.
+func firstNonZero[T comparable](current, next T) T {
+	var zero T
+	if current != zero {
+		return current
+	}
+	return next
+}
+
+// CompositeSecretProvider merges secrets from several sources, e.g. Secret
+// Manager over the mounted file over environment variables, field by field
+// in precedence order. Each source keeps its own cache and TTL.
+type CompositeSecretProvider struct {
+	previous    previousToken
+	sources     []namedSecretSource
+	gracePeriod time.Duration
+	mu          sync.Mutex
+}
+
+// NewCompositeSecretProvider layers sources, highest precedence first.
+func NewCompositeSecretProvider(sources ...namedSecretSource) *CompositeSecretProvider {
+	return &CompositeSecretProvider{sources: sources, gracePeriod: DefaultVerifyTokenGracePeriod}
+}
+
+// Secrets returns the merged secrets. A source that fails is skipped, since
+// each serves its last loaded values when it can; it is only an error when
+// every source fails.
+func (p *CompositeSecretProvider) Secrets() (StravaSecrets, error) {
+	var layers []StravaSecrets
+	var errs []error
+	for _, source := range p.sources {
+		secrets, err := source.source.Secrets()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source.name, err))
+			continue
+		}
+		layers = append(layers, secrets)
+	}
+	if len(layers) == 0 {
+		return StravaSecrets{}, fmt.Errorf("no secret source available: %w", errors.Join(errs...))
+	}
+
+	merged := mergeSecrets(layers...)
+	p.mu.Lock()
+	p.previous.update(merged.PreviousWebhookVerifyToken, time.Now())
+	p.mu.Unlock()
+	return merged, nil
+}
+
+// GetSecrets implements the SecretProvider interface.
+func (p *CompositeSecretProvider) GetSecrets() (string, int, error) {
+	secrets, err := p.Secrets()
+	if err != nil {
+		return "", 0, err
+	}
+	return secrets.WebhookVerifyToken, primarySubscriptionID(secrets.SubscriptionIDs()), nil
+}
+
+// AcceptsVerifyToken reports whether token is the current verify token or
+// the previous one within its grace period.
+func (p *CompositeSecretProvider) AcceptsVerifyToken(token string) (bool, error) {
+	secrets, err := p.Secrets()
+	if err != nil {
+		return false, err
+	}
+	if token == secrets.WebhookVerifyToken {
+		return true, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.previous.accepts(token, p.gracePeriod, time.Now()), nil
+}
+
+// AcceptsSubscriptionID reports whether id is one of the configured
+// webhook subscriptions.
+func (p *CompositeSecretProvider) AcceptsSubscriptionID(id int) (bool, error) {
+	secrets, err := p.Secrets()
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(secrets.SubscriptionIDs(), id), nil
+}
+
+// StartRefresh starts the background refresh of every source that
+// supports it.
+func (p *CompositeSecretProvider) StartRefresh(interval time.Duration) {
+	for _, source := range p.sources {
+		if refresher, ok := source.source.(interface{ StartRefresh(time.Duration) }); ok {
+			refresher.StartRefresh(interval)
+		}
+	}
+}
+
+// SecretStatus implements secretStatusReporter, summarizing the merged
+// secrets with the newest reload and check across sources.
+func (p *CompositeSecretProvider) SecretStatus() SecretStatus {
+	names := make([]string, len(p.sources))
+	var locations []string
+	var status SecretStatus
+	for i, source := range p.sources {
+		names[i] = source.name
+		reporter, ok := source.source.(secretStatusReporter)
+		if !ok {
+			continue
+		}
+		layer := reporter.SecretStatus()
+		locations = append(locations, layer.Location)
+		if layer.LastReload.After(status.LastReload) {
+			status.LastReload = layer.LastReload
+		}
+		if layer.LastCheck.After(status.LastCheck) {
+			status.LastCheck = layer.LastCheck
+		}
+	}
+	status.Source = strings.Join(names, ",")
+	status.Location = strings.Join(locations, ",")
+
+	if secrets, err := p.Secrets(); err == nil {
+		if data, err := json.Marshal(secrets); err == nil {
+			status.ContentHash = shortHash(fmt.Sprintf("%x", sha256.Sum256(data)))
+		}
+		status.SubscriptionIDs = secrets.SubscriptionIDs()
+	}
+	return status
+}
+
+// Close stops background refreshes and releases the sources' clients.
+func (p *CompositeSecretProvider) Close() error {
+	var errs []error
+	for _, source := range p.sources {
+		if closer, ok := source.source.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
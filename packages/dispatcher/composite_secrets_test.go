@@ -0,0 +1,116 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompositeSecretProvider_Precedence(t *testing.T) {
+	secretsPath := filepath.Join(t.TempDir(), "strava_auth.json")
+	writeTestSecretsFile(t, secretsPath, map[string]any{
+		"webhook_verify_token":    "file-token",
+		"webhook_subscription_id": 222,
+		"client_id":               42,
+		"client_secret":           "file-client-secret",
+	})
+	t.Setenv("STRAVA_WEBHOOK_VERIFY_TOKEN", "env-token")
+	t.Setenv("STRAVA_CLIENT_SECRET", "env-client-secret")
+	t.Setenv("STRAVA_REFRESH_TOKEN", "env-refresh")
+	env, err := newEnvSecretSource()
+	if err != nil {
+		t.Fatalf("newEnvSecretSource failed: %v", err)
+	}
+	manager := newSecretManagerProvider("projects/p/secrets/strava/versions/latest", time.Minute,
+		func(ctx context.Context, name string) ([]byte, error) {
+			return []byte(`{"webhook_verify_token":"sm-token","webhook_subscription_id":0}`), nil
+		})
+
+	provider := NewCompositeSecretProvider(
+		namedSecretSource{source: manager, name: SecretSourceSecretManager},
+		namedSecretSource{source: NewSecretCache(secretsPath, time.Minute), name: SecretSourceFile},
+		namedSecretSource{source: env, name: SecretSourceEnv},
+	)
+	secrets, err := provider.Secrets()
+	if err != nil {
+		t.Fatalf("Secrets failed: %v", err)
+	}
+	want := StravaSecrets{
+		WebhookVerifyToken:    "sm-token",
+		WebhookSubscriptionID: 222,
+		ClientID:              42,
+		ClientSecret:          "file-client-secret",
+		RefreshToken:          "env-refresh",
+	}
+	if secrets.WebhookVerifyToken != want.WebhookVerifyToken ||
+		secrets.WebhookSubscriptionID != want.WebhookSubscriptionID ||
+		secrets.ClientID != want.ClientID ||
+		secrets.ClientSecret != want.ClientSecret ||
+		secrets.RefreshToken != want.RefreshToken {
+		t.Errorf("got %+v, want %+v", secrets, want)
+	}
+
+	if ok, _ := provider.AcceptsVerifyToken("file-token"); ok {
+		t.Error("expected a lower-precedence verify token to be rejected")
+	}
+	if ok, _ := provider.AcceptsSubscriptionID(222); !ok {
+		t.Error("expected the file's subscription ID to be accepted")
+	}
+	if status := provider.SecretStatus(); status.Source != "secret-manager,file,env" || status.ContentHash == "" {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestCompositeSecretProvider_FallsBackWhenSourceFails(t *testing.T) {
+	t.Setenv("STRAVA_WEBHOOK_VERIFY_TOKEN", "env-token")
+	t.Setenv("STRAVA_WEBHOOK_SUBSCRIPTION_ID", "12345")
+	env, err := newEnvSecretSource()
+	if err != nil {
+		t.Fatalf("newEnvSecretSource failed: %v", err)
+	}
+	provider := NewCompositeSecretProvider(
+		namedSecretSource{source: NewSecretCache(filepath.Join(t.TempDir(), "missing.json"), time.Minute), name: SecretSourceFile},
+		namedSecretSource{source: env, name: SecretSourceEnv},
+	)
+
+	token, subscriptionID, err := provider.GetSecrets()
+	if err != nil {
+		t.Fatalf("GetSecrets failed: %v", err)
+	}
+	if token != "env-token" || subscriptionID != 12345 {
+		t.Errorf("got (%q, %d), want env values", token, subscriptionID)
+	}
+}
+
+func TestCompositeSecretProvider_AllSourcesFail(t *testing.T) {
+	manager := newSecretManagerProvider("projects/p/secrets/strava/versions/latest", time.Minute,
+		func(ctx context.Context, name string) ([]byte, error) {
+			return nil, errors.New("permission denied")
+		})
+	provider := NewCompositeSecretProvider(
+		namedSecretSource{source: manager, name: SecretSourceSecretManager},
+		namedSecretSource{source: NewSecretCache(filepath.Join(t.TempDir(), "missing.json"), time.Minute), name: SecretSourceFile},
+	)
+
+	if _, _, err := provider.GetSecrets(); err == nil {
+		t.Error("expected an error when no source has secrets")
+	}
+}
+
+func TestNewEnvSecretSource_InvalidInt(t *testing.T) {
+	t.Setenv("STRAVA_CLIENT_ID", "abc")
+
+	if _, err := newEnvSecretSource(); err == nil {
+		t.Error("expected error for non-numeric STRAVA_CLIENT_ID")
+	}
+}
+
+func TestNewSecretProvider_DuplicateSource(t *testing.T) {
+	t.Setenv("SECRET_SOURCE", "env,env")
+
+	if _, err := NewSecretProvider(context.Background(), &Config{}); err == nil {
+		t.Error("expected error for a source listed twice")
+	}
+}
@@ -3,12 +3,23 @@ package dispatcher
 import (
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/andy-esch/desirelines/packages/alert"
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/ipfilter"
+	"github.com/andy-esch/desirelines/packages/logging"
+	"github.com/andy-esch/desirelines/packages/tokenstore"
 )
 
 const (
@@ -16,32 +27,235 @@ const (
 	DefaultSecretsPath = "/etc/secrets/strava_auth.json"
 	// DefaultSecretCacheTTL is the default cache TTL for secret reloading
 	DefaultSecretCacheTTL = 5 * time.Minute
+	// DefaultVerifyTokenGracePeriod is how long previous_webhook_verify_token
+	// keeps being accepted after it first appears in the secrets
+	DefaultVerifyTokenGracePeriod = 24 * time.Hour
+)
+
+// Publisher backends selectable with PUBLISHER.
+const (
+	PublisherBackendPubSub = "pubsub"
+	PublisherBackendKafka  = "kafka"
+	PublisherBackendLocal  = "local"
 )
 
 // Config holds all configuration for the dispatcher.
 type Config struct {
-	StravaWebhookVerifyToken    string
-	GCPProjectID                string
-	GCPPubSubTopicID            string
-	LogLevel                    string
-	StravaWebhookSubscriptionID int
+	// IPFilter restricts which client addresses may call the webhook; nil
+	// admits everyone.
+	IPFilter *ipfilter.Filter
+	// TrustedProxies are the proxies whose X-Forwarded-For header names the
+	// client for IPFilter, the rate limits and the logs; with none, the
+	// connection's address is the client's.
+	TrustedProxies ipfilter.TrustedProxies
+	// RateLimiter limits webhook requests globally and per client IP; nil
+	// disables flood protection.
+	RateLimiter *RateLimiter
+	// OwnerAllowlist restricts which athletes' events are published; nil
+	// publishes every athlete's.
+	OwnerAllowlist *OwnerAllowlist
+	// Athletes locates the athlete registry; when it is enabled only
+	// registered athletes' events are published, with their storage
+	// prefix, and enriched with their own tokens.
+	Athletes *athletes.Config
+	// TokenStore shares the enrichment's Strava tokens with the other
+	// functions; its Backend is empty when the dispatcher refreshes on its
+	// own.
+	TokenStore *tokenstore.Config
+	// Alerts posts dead-lettered events to a Slack or Discord webhook;
+	// disabled without ALERT_WEBHOOK_URL.
+	Alerts *alert.Config
+	// AspectTopicIDs overrides GCPPubSubTopicID per aspect_type.
+	AspectTopicIDs map[string]string
+	// FilterRules drop or route events before the other routing; see
+	// FilterRouter.
+	FilterRules []FilterRule
+	// RedactFields are header, query parameter and JSON field names masked
+	// in logs on top of credential-like ones.
+	RedactFields     []string
+	GCPProjectID     string
+	GCPPubSubTopicID string
+	// PublisherBackend is one of the PublisherBackend constants.
+	PublisherBackend string
+	// LocalPublishFile is where the local backend appends events; empty
+	// means stdout.
+	LocalPublishFile string
+	// KafkaTopicID replaces GCPPubSubTopicID with the Kafka backend.
+	KafkaTopicID string
+	// GCPPubSubHistoricalTopicID receives events older than MaxEventAge
+	// when set; otherwise they are only flagged.
+	GCPPubSubHistoricalTopicID string
+	// DeauthorizationTopicID receives athlete deauthorization events when
+	// set (implies PublishDeauthorizations).
+	DeauthorizationTopicID string
+	// AthleteTopicID receives athlete object events when set, including
+	// deauthorizations unless DeauthorizationTopicID is also set (implies
+	// PublishDeauthorizations).
+	AthleteTopicID string
+	// DeadLetterBucket, when set, receives events that fail to publish.
+	DeadLetterBucket string
+	DeadLetterPrefix string
+	// BodyCaptureBucket, when set, receives webhook bodies that fail to
+	// decode, truncated to BodyCaptureMaxBytes.
+	BodyCaptureBucket   string
+	BodyCapturePrefix   string
+	BodyCaptureMaxBytes int
+	// AuditBucket or AuditTable, when set, receives a record of every
+	// webhook event received (see AuditLog): NDJSON archives under
+	// AuditPrefix, or rows in a "[project.]dataset.table".
+	AuditBucket string
+	AuditPrefix string
+	AuditTable  string
+	// OutboxCollection, when set, enables the Firestore outbox.
+	OutboxCollection string
+	// DedupeCollection, when set, shares dedupe keys through Firestore.
+	DedupeCollection string
+	// LogLevel is the initial minimum log level (see logging.ParseLevel).
+	LogLevel string
+	// ReplayToken, when set, is the X-Replay-Token value authenticating
+	// replayed events, which skip the IP filter and are published with
+	// source=replay.
+	ReplayToken string
+	// AdminToken, when set, enables the /admin/ endpoints for requests
+	// bearing it.
+	AdminToken string
+	// OAuthRedirectURL, when set, enables athlete onboarding at /connect.
+	// It is the public URL of /oauth/callback, whose host must be the
+	// app's authorization callback domain on Strava.
+	OAuthRedirectURL string
+	// OnboardingSuccessURL is where onboarded athletes are sent, with an
+	// athlete_id query parameter; empty answers with JSON.
+	OnboardingSuccessURL string
+	// Kafka configures the brokers and authentication for the Kafka
+	// backend.
+	Kafka KafkaSettings
+	// Batching tunes how Pub/Sub groups messages; zero fields keep the
+	// client library defaults.
+	Batching BatchSettings
+	// Retry controls retries of transient publish failures.
+	Retry RetryPolicy
+	// OutboxSweepInterval and OutboxSweepAge control how often, and after
+	// how long, unsent outbox entries are republished.
+	OutboxSweepInterval time.Duration
+	OutboxSweepAge      time.Duration
+	// AuditFlushInterval is how often queued audit records are written.
+	AuditFlushInterval time.Duration
+	// MessageFormat selects raw or CloudEvents message data.
+	MessageFormat MessageFormat
+	// MaxEventAge marks events whose event_time is older as historical;
+	// 0 disables the check.
+	MaxEventAge time.Duration
+	// DedupeWindow is how long a delivered event suppresses identical
+	// redeliveries; 0 disables deduplication.
+	DedupeWindow time.Duration
+	// DedupeCacheSize bounds the in-memory dedupe cache.
+	DedupeCacheSize int
+	// BreakerOpenTimeout is how long the publisher circuit stays open.
+	BreakerOpenTimeout time.Duration
+	// BreakerFailureThreshold is the consecutive publish failures that open
+	// the circuit; 0 disables the breaker.
+	BreakerFailureThreshold int
+	// AsyncQueueSize bounds the async publish queue (see AsyncPublisher).
+	AsyncQueueSize int
+	// AthleteMetricsLimit is how many athletes get their own owner_id
+	// metric label; 0 disables per-athlete metrics.
+	AthleteMetricsLimit int
+	// TraceSampleRatio is the fraction of new traces sampled when tracing
+	// is enabled.
+	TraceSampleRatio float64
+	// EnrichTimeout bounds each Strava activity lookup.
+	EnrichTimeout time.Duration
+	// EnrichActivities attaches the full Strava activity to published
+	// create and update events.
+	EnrichActivities bool
+	// AsyncPublish acknowledges webhooks before Pub/Sub confirms publishing.
+	AsyncPublish bool
+	// AccessLog writes one AccessLogger entry per request.
+	AccessLog bool
+	// DebugPayloads writes each request and response, redacted, to
+	// PayloadLogger (see Handler.logPayloads).
+	DebugPayloads bool
+	// HealthCheckTopics makes HEAD / and /ready verify that the Pub/Sub
+	// topics exist and can be published to.
+	HealthCheckTopics bool
+	// PublishDeauthorizations publishes athlete deauthorization events,
+	// which are otherwise ignored like other athlete events.
+	PublishDeauthorizations bool
+	// TracingEnabled exports OpenTelemetry spans to Cloud Trace.
+	TracingEnabled bool
 }
 
 // StravaSecrets represents the structure of the mounted secret file.
+// PreviousWebhookVerifyToken is optional and is set while rotating tokens.
+// WebhookSubscriptionIDs lists additional accepted subscriptions (e.g. dev
+// and prod Strava apps).
 type StravaSecrets struct {
-	WebhookVerifyToken    string `json:"webhook_verify_token"`
-	WebhookSubscriptionID int    `json:"webhook_subscription_id"`
+	ClientSecret               string `json:"client_secret,omitempty"`
+	RefreshToken               string `json:"refresh_token,omitempty"`
+	WebhookVerifyToken         string `json:"webhook_verify_token"`
+	PreviousWebhookVerifyToken string `json:"previous_webhook_verify_token,omitempty"`
+	WebhookSubscriptionIDs     []int  `json:"webhook_subscription_ids,omitempty"`
+	WebhookSubscriptionID      int    `json:"webhook_subscription_id"`
+	ClientID                   int    `json:"client_id,omitempty"`
+}
+
+// SubscriptionIDs returns every accepted subscription ID, with
+// webhook_subscription_id first when set and duplicates removed.
+func (s StravaSecrets) SubscriptionIDs() []int {
+	var ids []int
+	for _, id := range append([]int{s.WebhookSubscriptionID}, s.WebhookSubscriptionIDs...) {
+		if id != 0 && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// primarySubscriptionID is the ID reported by GetSecrets: the first accepted
+// subscription, or 0 if none are configured.
+func primarySubscriptionID(ids []int) int {
+	if len(ids) == 0 {
+		return 0
+	}
+	return ids[0]
+}
+
+// previousToken tracks a rotated-out verify token and when it was first
+// seen, which anchors its grace window.
+type previousToken struct {
+	since time.Time
+	token string
+}
+
+// update records the previous token from freshly loaded secrets, restarting
+// the grace window only when the token itself changes.
+func (p *previousToken) update(token string, now time.Time) {
+	if token != p.token {
+		p.token = token
+		p.since = now
+	}
+}
+
+// accepts reports whether token is the previous token and still within grace.
+func (p *previousToken) accepts(token string, grace time.Duration, now time.Time) bool {
+	return p.token != "" && token == p.token && now.Sub(p.since) < grace
 }
 
 // SecretCache provides TTL-based caching with content hash validation for secrets.
 type SecretCache struct {
-	lastCheck      time.Time
-	contentHash    string
-	secretsPath    string
-	verifyToken    string
-	ttl            time.Duration
-	subscriptionID int
-	mu             sync.RWMutex
+	lastCheck       time.Time
+	lastReload      time.Time
+	previous        previousToken
+	refresher       *secretRefresher
+	secrets         StravaSecrets
+	contentHash     string
+	secretsPath     string
+	verifyToken     string
+	subscriptionIDs []int
+	ttl             time.Duration
+	gracePeriod     time.Duration
+	subscriptionID  int
+	mu              sync.RWMutex
 }
 
 // NewSecretCache creates a new secret cache with the specified TTL.
@@ -49,6 +263,7 @@ func NewSecretCache(secretsPath string, ttl time.Duration) *SecretCache {
 	return &SecretCache{
 		secretsPath: secretsPath,
 		ttl:         ttl,
+		gracePeriod: DefaultVerifyTokenGracePeriod,
 	}
 }
 
@@ -73,32 +288,62 @@ func (c *SecretCache) GetSecrets() (string, int, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if err := c.reload(now); err != nil {
+		return "", 0, err
+	}
+	return c.verifyToken, c.subscriptionID, nil
+}
+
+// StartRefresh reloads the secrets every interval in the background until
+// Close, so requests after a rotation find them already loaded instead of
+// waiting out the TTL.
+func (c *SecretCache) StartRefresh(interval time.Duration) {
+	c.refresher = startSecretRefresher(interval, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		_ = c.reload(time.Now())
+	})
+}
+
+// Close stops the background refresh, if started.
+func (c *SecretCache) Close() error {
+	c.refresher.stop()
+	return nil
+}
+
+// reload reloads the secrets if the file content changed. Must be called
+// with c.mu held. It only fails when no secrets were ever loaded; otherwise
+// the cached values are kept.
+func (c *SecretCache) reload(now time.Time) error {
 	currentHash, err := c.hashFile()
 	if err != nil {
 		Logger.Error("Failed to hash secrets file", "error", err)
-		// Return cached values if available
+		// Keep cached values if available
 		if c.verifyToken != "" {
-			return c.verifyToken, c.subscriptionID, nil
+			return nil
 		}
-		return "", 0, fmt.Errorf("failed to read secrets file: %w", err)
+		return fmt.Errorf("failed to read secrets file: %w", err)
 	}
 
 	// Content changed or first load
 	if currentHash != c.contentHash {
 		if err := c.loadSecrets(); err != nil {
+			secretReloads.WithLabelValues(SecretSourceFile, "failure").Inc()
 			Logger.Error("Failed to reload secrets", "error", err)
-			// Return cached values if available
+			// Keep cached values if available
 			if c.verifyToken != "" {
-				return c.verifyToken, c.subscriptionID, nil
+				return nil
 			}
-			return "", 0, fmt.Errorf("failed to load secrets: %w", err)
+			return fmt.Errorf("failed to load secrets: %w", err)
 		}
 		c.contentHash = currentHash
+		c.lastReload = now
+		secretReloads.WithLabelValues(SecretSourceFile, "success").Inc()
 		Logger.Info("Secrets reloaded due to content change")
 	}
 
 	c.lastCheck = now
-	return c.verifyToken, c.subscriptionID, nil
+	return nil
 }
 
 // hashFile computes SHA256 hash of the secrets file content.
@@ -139,64 +384,503 @@ func (c *SecretCache) loadSecrets() error {
 	}
 
 	// Direct field access with compile-time type safety
+	c.secrets = secrets
 	c.verifyToken = secrets.WebhookVerifyToken
-	c.subscriptionID = secrets.WebhookSubscriptionID
+	c.subscriptionIDs = secrets.SubscriptionIDs()
+	c.subscriptionID = primarySubscriptionID(c.subscriptionIDs)
+	c.previous.update(secrets.PreviousWebhookVerifyToken, time.Now())
 
 	return nil
 }
 
-// LoadConfig loads configuration from environment variables and mounted secrets.
+// AcceptsVerifyToken reports whether token is the current verify token or
+// the previous one within its grace period.
+func (c *SecretCache) AcceptsVerifyToken(token string) (bool, error) {
+	verifyToken, _, err := c.GetSecrets()
+	if err != nil {
+		return false, err
+	}
+	if token == verifyToken {
+		return true, nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.previous.accepts(token, c.gracePeriod, time.Now()), nil
+}
+
+// AcceptsSubscriptionID reports whether id is one of the configured
+// webhook subscriptions.
+func (c *SecretCache) AcceptsSubscriptionID(id int) (bool, error) {
+	if _, _, err := c.GetSecrets(); err != nil {
+		return false, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return slices.Contains(c.subscriptionIDs, id), nil
+}
+
+// Secrets returns everything loaded from the file, reloading it like
+// GetSecrets.
+func (c *SecretCache) Secrets() (StravaSecrets, error) {
+	if _, _, err := c.GetSecrets(); err != nil {
+		return StravaSecrets{}, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.secrets, nil
+}
+
+// SecretStatus implements secretStatusReporter.
+func (c *SecretCache) SecretStatus() SecretStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return SecretStatus{
+		Source:          SecretSourceFile,
+		Location:        c.secretsPath,
+		LastReload:      c.lastReload,
+		LastCheck:       c.lastCheck,
+		ContentHash:     shortHash(c.contentHash),
+		SubscriptionIDs: slices.Clone(c.subscriptionIDs),
+	}
+}
+
+// LoadConfig loads configuration from environment variables. Strava
+// secrets are read separately by the SecretProvider (see NewSecretProvider).
 func LoadConfig() (*Config, error) {
-	// Load webhook secrets from mounted volume if available
-	secretsPath := DefaultSecretsPath
-	if _, err := os.Stat(secretsPath); err == nil {
-		secretsFile, err := os.Open(secretsPath)
-		if err != nil {
-			Logger.Error("Failed to open secrets file", "error", err)
-		} else {
-			defer func() {
-				if closeErr := secretsFile.Close(); closeErr != nil {
-					Logger.Error("Failed to close secrets file", "error", closeErr)
-				}
-			}()
-
-			var secrets StravaSecrets
-			if err := json.NewDecoder(secretsFile).Decode(&secrets); err != nil {
-				Logger.Error("Failed to decode secrets file", "error", err)
-			} else {
-				// Set environment variables from secrets (takes precedence)
-				if secrets.WebhookVerifyToken != "" {
-					if err := os.Setenv("STRAVA_WEBHOOK_VERIFY_TOKEN", secrets.WebhookVerifyToken); err != nil {
-						Logger.Error("Failed to set STRAVA_WEBHOOK_VERIFY_TOKEN", "error", err)
-					}
-				}
-				if secrets.WebhookSubscriptionID != 0 {
-					if err := os.Setenv("STRAVA_WEBHOOK_SUBSCRIPTION_ID", strconv.Itoa(secrets.WebhookSubscriptionID)); err != nil {
-						Logger.Error("Failed to set STRAVA_WEBHOOK_SUBSCRIPTION_ID", "error", err)
-					}
-				}
+	// Invalid values are collected rather than returned one at a time, so a
+	// bad deployment reports everything wrong with it at once
+	var errs []error
+
+	asyncQueueSize, err := strconv.Atoi(config.GetOrDefault("ASYNC_QUEUE_SIZE", strconv.Itoa(DefaultAsyncQueueSize)))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid ASYNC_QUEUE_SIZE: %v", err))
+	}
+
+	athleteMetricsLimit, err := strconv.Atoi(config.GetOrDefault("ATHLETE_METRICS_LIMIT", strconv.Itoa(DefaultAthleteMetricsLimit)))
+	if err != nil || athleteMetricsLimit < 0 {
+		errs = append(errs, fmt.Errorf("invalid ATHLETE_METRICS_LIMIT: %q", config.Get("ATHLETE_METRICS_LIMIT")))
+	}
+
+	batching, err := loadBatchSettings()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	retry, err := loadRetryPolicy()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	breakerThreshold, err := strconv.Atoi(config.GetOrDefault("BREAKER_FAILURE_THRESHOLD", strconv.Itoa(DefaultBreakerFailureThreshold)))
+	if err != nil || breakerThreshold < 0 {
+		errs = append(errs, fmt.Errorf("invalid BREAKER_FAILURE_THRESHOLD: %q", config.Get("BREAKER_FAILURE_THRESHOLD")))
+	}
+	breakerOpenTimeout, err := time.ParseDuration(config.GetOrDefault("BREAKER_OPEN_TIMEOUT", DefaultBreakerOpenTimeout.String()))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid BREAKER_OPEN_TIMEOUT: %v", err))
+	}
+
+	outboxSweepInterval, err := time.ParseDuration(config.GetOrDefault("OUTBOX_SWEEP_INTERVAL", DefaultOutboxSweepInterval.String()))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid OUTBOX_SWEEP_INTERVAL: %v", err))
+	}
+	outboxSweepAge, err := time.ParseDuration(config.GetOrDefault("OUTBOX_SWEEP_AGE", DefaultOutboxSweepAge.String()))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid OUTBOX_SWEEP_AGE: %v", err))
+	}
+
+	dedupeWindow, err := time.ParseDuration(config.GetOrDefault("DEDUPE_WINDOW", "0s"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid DEDUPE_WINDOW: %v", err))
+	}
+	dedupeCacheSize, err := strconv.Atoi(config.GetOrDefault("DEDUPE_CACHE_SIZE", strconv.Itoa(DefaultDedupeCacheSize)))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid DEDUPE_CACHE_SIZE: %v", err))
+	}
+
+	maxEventAge, err := time.ParseDuration(config.GetOrDefault("MAX_EVENT_AGE", "0s"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid MAX_EVENT_AGE: %v", err))
+	}
+
+	enrichTimeout, err := time.ParseDuration(config.GetOrDefault("ENRICH_TIMEOUT", DefaultEnrichTimeout.String()))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid ENRICH_TIMEOUT: %v", err))
+	}
+	tokenStore, err := tokenstore.LoadConfig()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	registry, err := athletes.LoadConfig()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	alerts, err := alert.LoadConfig()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if tokenStore != nil {
+		tokenStore.PerAthlete = registry.Enabled()
+	}
+
+	messageFormat, err := ParseMessageFormat(config.Get("MESSAGE_FORMAT"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid MESSAGE_FORMAT: %w", err))
+	}
+
+	backend := config.GetOrDefault("PUBLISHER", PublisherBackendPubSub)
+	if !slices.Contains([]string{PublisherBackendPubSub, PublisherBackendKafka, PublisherBackendLocal}, backend) {
+		errs = append(errs, fmt.Errorf("invalid PUBLISHER: %q", backend))
+	}
+
+	deauthorizationTopic := config.Get("GCP_PUBSUB_DEAUTHORIZATION_TOPIC")
+	athleteTopic := config.Get("GCP_PUBSUB_ATHLETE_TOPIC")
+
+	ipFilter, err := ipfilter.Parse(config.Get("IP_ALLOWLIST"), config.Get("IP_DENYLIST"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid IP filter: %w", err))
+	}
+	trustedProxies, err := ipfilter.ParseTrustedProxies(config.Get("TRUSTED_PROXIES"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err))
+	}
+
+	rateLimits, err := loadRateLimits()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	filterRules, err := loadFilterRules()
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	ownerAllowlist, err := ParseOwnerAllowlist(config.Get("OWNER_ALLOWLIST"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid OWNER_ALLOWLIST: %w", err))
+	}
+
+	bodyCaptureMaxBytes, err := strconv.Atoi(config.GetOrDefault("BODY_CAPTURE_MAX_BYTES", strconv.Itoa(DefaultBodyCaptureMaxBytes)))
+	if err != nil || bodyCaptureMaxBytes <= 0 {
+		errs = append(errs, fmt.Errorf("invalid BODY_CAPTURE_MAX_BYTES: %q", config.Get("BODY_CAPTURE_MAX_BYTES")))
+	}
+
+	logLevel := config.GetOrDefault("LOG_LEVEL", "INFO")
+	if _, err := logging.ParseLevel(logLevel); err != nil {
+		errs = append(errs, fmt.Errorf("invalid LOG_LEVEL: %w", err))
+	}
+
+	auditFlushInterval, err := time.ParseDuration(config.GetOrDefault("AUDIT_FLUSH_INTERVAL", DefaultAuditFlushInterval.String()))
+	if err != nil || auditFlushInterval <= 0 {
+		errs = append(errs, fmt.Errorf("invalid AUDIT_FLUSH_INTERVAL: %q", config.Get("AUDIT_FLUSH_INTERVAL")))
+	}
+
+	traceSampleRatio, err := strconv.ParseFloat(config.GetOrDefault("TRACE_SAMPLE_RATIO", "1"), 64)
+	if err != nil || traceSampleRatio < 0 || traceSampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("invalid TRACE_SAMPLE_RATIO: %q", config.Get("TRACE_SAMPLE_RATIO")))
+	}
+
+	cfg := &Config{
+		IPFilter:                   ipFilter,
+		TrustedProxies:             trustedProxies,
+		RateLimiter:                NewRateLimiter(rateLimits),
+		OwnerAllowlist:             ownerAllowlist,
+		Athletes:                   registry,
+		TokenStore:                 tokenStore,
+		Alerts:                     alerts,
+		FilterRules:                filterRules,
+		AspectTopicIDs:             loadAspectTopics(),
+		Batching:                   batching,
+		Retry:                      retry,
+		BreakerFailureThreshold:    breakerThreshold,
+		BreakerOpenTimeout:         breakerOpenTimeout,
+		OutboxCollection:           config.Get("OUTBOX_COLLECTION"),
+		DedupeWindow:               dedupeWindow,
+		DedupeCacheSize:            dedupeCacheSize,
+		DedupeCollection:           config.Get("DEDUPE_COLLECTION"),
+		OutboxSweepInterval:        outboxSweepInterval,
+		OutboxSweepAge:             outboxSweepAge,
+		GCPProjectID:               config.GetOrDefault("GCP_PROJECT_ID", ""),
+		GCPPubSubTopicID:           config.GetOrDefault("GCP_PUBSUB_TOPIC", ""),
+		PublisherBackend:           backend,
+		KafkaTopicID:               config.Get("KAFKA_TOPIC"),
+		LocalPublishFile:           config.Get("LOCAL_PUBLISH_FILE"),
+		Kafka:                      loadKafkaSettings(),
+		GCPPubSubHistoricalTopicID: config.Get("GCP_PUBSUB_HISTORICAL_TOPIC"),
+		DeauthorizationTopicID:     deauthorizationTopic,
+		AthleteTopicID:             athleteTopic,
+		PublishDeauthorizations:    deauthorizationTopic != "" || athleteTopic != "" || config.Get("PUBLISH_DEAUTHORIZATIONS") == "true",
+		MaxEventAge:                maxEventAge,
+		MessageFormat:              messageFormat,
+		LogLevel:                   logLevel,
+		DeadLetterBucket:           config.Get("DEAD_LETTER_BUCKET"),
+		DeadLetterPrefix:           config.GetOrDefault("DEAD_LETTER_PREFIX", DefaultDeadLetterPrefix),
+		BodyCaptureBucket:          config.Get("BODY_CAPTURE_BUCKET"),
+		BodyCapturePrefix:          config.GetOrDefault("BODY_CAPTURE_PREFIX", DefaultBodyCapturePrefix),
+		BodyCaptureMaxBytes:        bodyCaptureMaxBytes,
+		AuditBucket:                config.Get("AUDIT_BUCKET"),
+		AuditPrefix:                config.GetOrDefault("AUDIT_PREFIX", DefaultAuditPrefix),
+		AuditTable:                 config.Get("AUDIT_BIGQUERY_TABLE"),
+		AuditFlushInterval:         auditFlushInterval,
+		EnrichActivities:           config.Get("ENRICH_ACTIVITIES") == "true",
+		EnrichTimeout:              enrichTimeout,
+		AsyncPublish:               config.Get("ASYNC_PUBLISH") == "true",
+		AccessLog:                  config.Get("ACCESS_LOG") != "false",
+		DebugPayloads:              config.Get("DEBUG_PAYLOADS") == "true",
+		RedactFields:               splitList(config.Get("LOG_REDACT_FIELDS")),
+		HealthCheckTopics:          config.Get("HEALTH_CHECK_TOPICS") == "true",
+		AsyncQueueSize:             asyncQueueSize,
+		AthleteMetricsLimit:        athleteMetricsLimit,
+		TracingEnabled:             config.Get("TRACING_ENABLED") == "true",
+		AdminToken:                 config.Get("ADMIN_TOKEN"),
+		ReplayToken:                config.Get("REPLAY_TOKEN"),
+		OAuthRedirectURL:           config.Get("OAUTH_REDIRECT_URL"),
+		OnboardingSuccessURL:       config.Get("ONBOARDING_SUCCESS_URL"),
+		TraceSampleRatio:           traceSampleRatio,
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks that the settings the configured backend and features
+// depend on are present and consistent, reporting every problem at once so
+// a mis-deployed service fails at startup instead of on its first publish.
+func (c *Config) Validate() error {
+	var errs []error
+	require := func(value, name, purpose string) {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s is required %s", name, purpose))
+		}
+	}
+
+	switch c.PublisherBackend {
+	case PublisherBackendPubSub:
+		require(c.GCPProjectID, "GCP_PROJECT_ID", "for the Pub/Sub publisher")
+		require(c.GCPPubSubTopicID, "GCP_PUBSUB_TOPIC", "for the Pub/Sub publisher")
+	case PublisherBackendKafka:
+		if len(c.Kafka.Brokers) == 0 {
+			errs = append(errs, fmt.Errorf("KAFKA_BROKERS is required for the Kafka publisher"))
+		}
+		require(c.KafkaTopicID, "KAFKA_TOPIC", "for the Kafka publisher")
+		if _, err := kafkaSASLMechanism(c.Kafka); err != nil {
+			errs = append(errs, fmt.Errorf("invalid KAFKA_SASL_MECHANISM: %w", err))
+		}
+	}
+
+	if c.OutboxCollection != "" {
+		require(c.GCPProjectID, "GCP_PROJECT_ID", "for OUTBOX_COLLECTION")
+	}
+	if c.DedupeCollection != "" {
+		require(c.GCPProjectID, "GCP_PROJECT_ID", "for DEDUPE_COLLECTION")
+	}
+	if c.TracingEnabled {
+		require(c.GCPProjectID, "GCP_PROJECT_ID", "for TRACING_ENABLED")
+	}
+	if c.AuditBucket != "" && c.AuditTable != "" {
+		errs = append(errs, fmt.Errorf("AUDIT_BUCKET and AUDIT_BIGQUERY_TABLE are mutually exclusive"))
+	}
+	if c.AuditTable != "" {
+		if _, _, _, err := parseBigQueryTable(c.AuditTable, c.GCPProjectID); err != nil {
+			errs = append(errs, fmt.Errorf("invalid AUDIT_BIGQUERY_TABLE: %w", err))
+		}
+	}
+
+	if c.AsyncPublish && c.AsyncQueueSize <= 0 {
+		errs = append(errs, fmt.Errorf("ASYNC_QUEUE_SIZE must be positive with ASYNC_PUBLISH, got %d", c.AsyncQueueSize))
+	}
+	if c.DedupeWindow < 0 {
+		errs = append(errs, fmt.Errorf("DEDUPE_WINDOW must not be negative, got %s", c.DedupeWindow))
+	}
+	if c.DedupeWindow > 0 && c.DedupeCacheSize <= 0 {
+		errs = append(errs, fmt.Errorf("DEDUPE_CACHE_SIZE must be positive with DEDUPE_WINDOW, got %d", c.DedupeCacheSize))
+	}
+	if c.MaxEventAge < 0 {
+		errs = append(errs, fmt.Errorf("MAX_EVENT_AGE must not be negative, got %s", c.MaxEventAge))
+	}
+	if c.EnrichActivities && c.EnrichTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("ENRICH_TIMEOUT must be positive with ENRICH_ACTIVITIES, got %s", c.EnrichTimeout))
+	}
+	if c.OAuthRedirectURL != "" {
+		if !c.Athletes.Enabled() {
+			errs = append(errs, fmt.Errorf("ATHLETE_REGISTRY is required for OAUTH_REDIRECT_URL"))
+		}
+		if !c.TokenStore.Enabled() {
+			errs = append(errs, fmt.Errorf("TOKEN_STORE is required for OAUTH_REDIRECT_URL"))
+		}
+		if !absoluteURL(c.OAuthRedirectURL) {
+			errs = append(errs, fmt.Errorf("invalid OAUTH_REDIRECT_URL: %q (want an absolute http(s) URL)", c.OAuthRedirectURL))
+		}
+	}
+	if c.OnboardingSuccessURL != "" && !absoluteURL(c.OnboardingSuccessURL) {
+		errs = append(errs, fmt.Errorf("invalid ONBOARDING_SUCCESS_URL: %q (want an absolute http(s) URL)", c.OnboardingSuccessURL))
+	}
+	if err := c.TokenStore.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Athletes.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Alerts.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// loadBatchSettings reads the PUBSUB_BATCH_* variables. Unset values are
+// left zero so the Pub/Sub client defaults apply.
+func loadBatchSettings() (BatchSettings, error) {
+	var batching BatchSettings
+	if v := config.Get("PUBSUB_BATCH_MAX_MESSAGES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return batching, fmt.Errorf("invalid PUBSUB_BATCH_MAX_MESSAGES: %q", v)
+		}
+		batching.CountThreshold = n
+	}
+	if v := config.Get("PUBSUB_BATCH_MAX_BYTES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return batching, fmt.Errorf("invalid PUBSUB_BATCH_MAX_BYTES: %q", v)
+		}
+		batching.ByteThreshold = n
+	}
+	if v := config.Get("PUBSUB_BATCH_MAX_LATENCY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return batching, fmt.Errorf("invalid PUBSUB_BATCH_MAX_LATENCY: %q", v)
+		}
+		batching.DelayThreshold = d
+	}
+	return batching, nil
+}
+
+// loadRateLimits reads the RATE_LIMIT* variables. Unset rates are left zero,
+// disabling that limit.
+func loadRateLimits() (RateLimits, error) {
+	var limits RateLimits
+	for _, setting := range []struct {
+		rate  *float64
+		burst *int
+		name  string
+	}{
+		{&limits.Global, &limits.GlobalBurst, "RATE_LIMIT"},
+		{&limits.PerIP, &limits.PerIPBurst, "RATE_LIMIT_PER_IP"},
+	} {
+		if v := config.Get(setting.name); v != "" {
+			r, err := strconv.ParseFloat(v, 64)
+			if err != nil || r < 0 {
+				return limits, fmt.Errorf("invalid %s: %q", setting.name, v)
+			}
+			*setting.rate = r
+		}
+		if v := config.Get(setting.name + "_BURST"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return limits, fmt.Errorf("invalid %s_BURST: %q", setting.name, v)
 			}
+			*setting.burst = n
 		}
 	}
+	return limits, nil
+}
 
-	subIDStr := getEnvOrDefault("STRAVA_WEBHOOK_SUBSCRIPTION_ID", "0")
-	subscriptionID, err := strconv.Atoi(subIDStr)
-	if err != nil {
-		return nil, fmt.Errorf("invalid STRAVA_WEBHOOK_SUBSCRIPTION_ID: %v", err)
+// loadRetryPolicy reads the PUBLISH_* retry variables over
+// DefaultRetryPolicy.
+func loadRetryPolicy() (RetryPolicy, error) {
+	policy := DefaultRetryPolicy
+	if v := config.Get("PUBLISH_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return policy, fmt.Errorf("invalid PUBLISH_MAX_ATTEMPTS: %q", v)
+		}
+		policy.MaxAttempts = n
+	}
+	if v := config.Get("PUBLISH_RETRY_BACKOFF"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return policy, fmt.Errorf("invalid PUBLISH_RETRY_BACKOFF: %q", v)
+		}
+		policy.InitialBackoff = d
+	}
+	if v := config.Get("PUBLISH_RETRY_MAX_BACKOFF"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return policy, fmt.Errorf("invalid PUBLISH_RETRY_MAX_BACKOFF: %q", v)
+		}
+		policy.MaxBackoff = d
+	}
+	if policy.MaxBackoff < policy.InitialBackoff {
+		policy.MaxBackoff = policy.InitialBackoff
 	}
+	return policy, nil
+}
 
-	return &Config{
-		StravaWebhookVerifyToken:    getEnvOrDefault("STRAVA_WEBHOOK_VERIFY_TOKEN", ""),
-		StravaWebhookSubscriptionID: subscriptionID,
-		GCPProjectID:                getEnvOrDefault("GCP_PROJECT_ID", ""),
-		GCPPubSubTopicID:            getEnvOrDefault("GCP_PUBSUB_TOPIC", ""),
-		LogLevel:                    getEnvOrDefault("LOG_LEVEL", "INFO"),
-	}, nil
+// loadAspectTopics reads the optional per-aspect topic overrides.
+func loadAspectTopics() map[string]string {
+	topics := map[string]string{}
+	for aspect, key := range map[string]string{
+		AspectCreate: "GCP_PUBSUB_CREATE_TOPIC",
+		AspectUpdate: "GCP_PUBSUB_UPDATE_TOPIC",
+		AspectDelete: "GCP_PUBSUB_DELETE_TOPIC",
+	} {
+		if topic := config.Get(key); topic != "" {
+			topics[aspect] = topic
+		}
+	}
+	return topics
 }
 
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// absoluteURL reports whether value is an absolute http or https URL.
+func absoluteURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// loadKafkaSettings reads the Kafka backend's broker and SASL settings.
+func loadKafkaSettings() KafkaSettings {
+	return KafkaSettings{
+		Brokers:       splitList(config.Get("KAFKA_BROKERS")),
+		SASLMechanism: config.Get("KAFKA_SASL_MECHANISM"),
+		SASLUsername:  config.Get("KAFKA_SASL_USERNAME"),
+		SASLPassword:  config.Get("KAFKA_SASL_PASSWORD"),
+		TLS:           config.Get("KAFKA_TLS") == "true",
+	}
+}
+
+// loadFilterRules reads filter rules from EVENT_FILTERS (inline JSON) or
+// the file named by EVENT_FILTERS_FILE.
+func loadFilterRules() ([]FilterRule, error) {
+	data := []byte(config.Get("EVENT_FILTERS"))
+	if path := config.Get("EVENT_FILTERS_FILE"); path != "" {
+		if len(data) > 0 {
+			return nil, fmt.Errorf("set only one of EVENT_FILTERS and EVENT_FILTERS_FILE")
+		}
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("invalid EVENT_FILTERS_FILE: %v", err)
+		}
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	rules, err := ParseFilterRules(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid event filters: %w", err)
 	}
-	return defaultValue
+	return rules, nil
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -224,6 +225,98 @@ func TestSecretCache_ContentHashDetection(t *testing.T) {
 	}
 }
 
+func TestSecretCache_AcceptsPreviousVerifyToken(t *testing.T) {
+	secretsPath := filepath.Join(t.TempDir(), "strava_auth.json")
+	writeSecretsFile(t, secretsPath, map[string]any{
+		"webhook_verify_token":          "new-token",
+		"previous_webhook_verify_token": "old-token",
+		"webhook_subscription_id":       12345,
+	})
+
+	cache := NewSecretCache(secretsPath, time.Minute)
+	cache.gracePeriod = time.Hour
+
+	for token, want := range map[string]bool{"new-token": true, "old-token": true, "other-token": false} {
+		accepted, err := cache.AcceptsVerifyToken(token)
+		if err != nil {
+			t.Fatalf("AcceptsVerifyToken(%q) failed: %v", token, err)
+		}
+		if accepted != want {
+			t.Errorf("AcceptsVerifyToken(%q) = %v, want %v", token, accepted, want)
+		}
+	}
+
+	// Grace window elapsed: only the current token is accepted
+	cache.previous.since = time.Now().Add(-2 * time.Hour)
+	if accepted, _ := cache.AcceptsVerifyToken("old-token"); accepted {
+		t.Error("expected previous token to be rejected after grace period")
+	}
+	if accepted, _ := cache.AcceptsVerifyToken("new-token"); !accepted {
+		t.Error("expected current token to be accepted after grace period")
+	}
+}
+
+func TestPreviousToken_Update(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var previous previousToken
+
+	previous.update("old-token", start)
+	// Reloading the same previous token must not extend its window
+	previous.update("old-token", start.Add(time.Hour))
+	if !previous.since.Equal(start) {
+		t.Errorf("expected grace window anchored at %v, got %v", start, previous.since)
+	}
+
+	previous.update("", start.Add(2*time.Hour))
+	if previous.accepts("", time.Hour, start.Add(2*time.Hour)) {
+		t.Error("empty previous token must never be accepted")
+	}
+}
+
+func TestSecretCache_MultipleSubscriptionIDs(t *testing.T) {
+	secretsPath := filepath.Join(t.TempDir(), "strava_auth.json")
+	writeSecretsFile(t, secretsPath, map[string]any{
+		"webhook_verify_token":     "token",
+		"webhook_subscription_ids": []int{111, 222},
+	})
+
+	cache := NewSecretCache(secretsPath, time.Minute)
+
+	_, subscriptionID, err := cache.GetSecrets()
+	if err != nil {
+		t.Fatalf("GetSecrets failed: %v", err)
+	}
+	if subscriptionID != 111 {
+		t.Errorf("expected primary subscription ID 111, got %d", subscriptionID)
+	}
+
+	for id, want := range map[int]bool{111: true, 222: true, 333: false, 0: false} {
+		accepted, err := cache.AcceptsSubscriptionID(id)
+		if err != nil {
+			t.Fatalf("AcceptsSubscriptionID(%d) failed: %v", id, err)
+		}
+		if accepted != want {
+			t.Errorf("AcceptsSubscriptionID(%d) = %v, want %v", id, accepted, want)
+		}
+	}
+}
+
+func TestStravaSecrets_SubscriptionIDs(t *testing.T) {
+	secrets := StravaSecrets{WebhookSubscriptionID: 222, WebhookSubscriptionIDs: []int{111, 222, 0, 333}}
+
+	got := secrets.SubscriptionIDs()
+	want := []int{222, 111, 333}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("expected %v, got %v", want, got)
+			break
+		}
+	}
+}
+
 // Helper function to write secrets file
 func writeSecretsFile(t *testing.T, path string, secrets map[string]any) {
 	data, err := json.Marshal(secrets)
@@ -236,3 +329,237 @@ func writeSecretsFile(t *testing.T, path string, secrets map[string]any) {
 		t.Fatalf("Failed to write secrets file: %v", err)
 	}
 }
+
+func TestLoadBatchSettings(t *testing.T) {
+	t.Run("unset keeps defaults", func(t *testing.T) {
+		batching, err := loadBatchSettings()
+		if err != nil {
+			t.Fatalf("loadBatchSettings failed: %v", err)
+		}
+		if batching != (BatchSettings{}) {
+			t.Errorf("expected zero settings, got %+v", batching)
+		}
+	})
+
+	t.Run("parses thresholds", func(t *testing.T) {
+		t.Setenv("PUBSUB_BATCH_MAX_MESSAGES", "250")
+		t.Setenv("PUBSUB_BATCH_MAX_BYTES", "1048576")
+		t.Setenv("PUBSUB_BATCH_MAX_LATENCY", "25ms")
+
+		batching, err := loadBatchSettings()
+		if err != nil {
+			t.Fatalf("loadBatchSettings failed: %v", err)
+		}
+		want := BatchSettings{CountThreshold: 250, ByteThreshold: 1 << 20, DelayThreshold: 25 * time.Millisecond}
+		if batching != want {
+			t.Errorf("expected %+v, got %+v", want, batching)
+		}
+	})
+
+	t.Run("rejects invalid values", func(t *testing.T) {
+		t.Setenv("PUBSUB_BATCH_MAX_LATENCY", "soon")
+		if _, err := loadBatchSettings(); err == nil {
+			t.Error("expected error for invalid PUBSUB_BATCH_MAX_LATENCY")
+		}
+	})
+}
+
+func TestLoadRetryPolicy(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		policy, err := loadRetryPolicy()
+		if err != nil {
+			t.Fatalf("loadRetryPolicy failed: %v", err)
+		}
+		if policy != DefaultRetryPolicy {
+			t.Errorf("expected %+v, got %+v", DefaultRetryPolicy, policy)
+		}
+	})
+
+	t.Run("overrides", func(t *testing.T) {
+		t.Setenv("PUBLISH_MAX_ATTEMPTS", "5")
+		t.Setenv("PUBLISH_RETRY_BACKOFF", "50ms")
+		t.Setenv("PUBLISH_RETRY_MAX_BACKOFF", "500ms")
+
+		policy, err := loadRetryPolicy()
+		if err != nil {
+			t.Fatalf("loadRetryPolicy failed: %v", err)
+		}
+		want := RetryPolicy{MaxAttempts: 5, InitialBackoff: 50 * time.Millisecond, MaxBackoff: 500 * time.Millisecond}
+		if policy != want {
+			t.Errorf("expected %+v, got %+v", want, policy)
+		}
+	})
+
+	t.Run("rejects zero attempts", func(t *testing.T) {
+		t.Setenv("PUBLISH_MAX_ATTEMPTS", "0")
+		if _, err := loadRetryPolicy(); err == nil {
+			t.Error("expected error for PUBLISH_MAX_ATTEMPTS=0")
+		}
+	})
+}
+
+func TestLoadConfig_ReportsAllInvalidValues(t *testing.T) {
+	t.Setenv("ASYNC_QUEUE_SIZE", "lots")
+	t.Setenv("DEDUPE_WINDOW", "soon")
+	t.Setenv("TRACE_SAMPLE_RATIO", "2")
+
+	_, err := LoadConfig()
+	if err == nil {
+		t.Fatal("expected error for invalid values")
+	}
+	for _, name := range []string{"ASYNC_QUEUE_SIZE", "DEDUPE_WINDOW", "TRACE_SAMPLE_RATIO"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("expected %s in error, got %v", name, err)
+		}
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr []string
+	}{
+		{
+			name: "pubsub with project and topic",
+			cfg:  Config{PublisherBackend: PublisherBackendPubSub, GCPProjectID: "p", GCPPubSubTopicID: "t"},
+		},
+		{
+			name:    "pubsub missing project and topic",
+			cfg:     Config{PublisherBackend: PublisherBackendPubSub},
+			wantErr: []string{"GCP_PROJECT_ID", "GCP_PUBSUB_TOPIC"},
+		},
+		{
+			name:    "kafka missing brokers",
+			cfg:     Config{PublisherBackend: PublisherBackendKafka, KafkaTopicID: "t"},
+			wantErr: []string{"KAFKA_BROKERS"},
+		},
+		{
+			name: "local needs nothing",
+			cfg:  Config{PublisherBackend: PublisherBackendLocal},
+		},
+		{
+			name:    "firestore features need a project",
+			cfg:     Config{PublisherBackend: PublisherBackendLocal, OutboxCollection: "outbox", AuditTable: "dataset.table"},
+			wantErr: []string{"OUTBOX_COLLECTION", "AUDIT_BIGQUERY_TABLE"},
+		},
+		{
+			name:    "conflicting audit sinks",
+			cfg:     Config{PublisherBackend: PublisherBackendLocal, AuditBucket: "b", AuditTable: "p.d.t"},
+			wantErr: []string{"mutually exclusive"},
+		},
+		{
+			name:    "dedupe without cache",
+			cfg:     Config{PublisherBackend: PublisherBackendLocal, DedupeWindow: time.Minute},
+			wantErr: []string{"DEDUPE_CACHE_SIZE"},
+		},
+		{
+			name:    "onboarding without registry and token store",
+			cfg:     Config{PublisherBackend: PublisherBackendLocal, OAuthRedirectURL: "https://example.com/oauth/callback"},
+			wantErr: []string{"ATHLETE_REGISTRY", "TOKEN_STORE"},
+		},
+		{
+			name:    "relative onboarding URLs",
+			cfg:     Config{PublisherBackend: PublisherBackendLocal, OAuthRedirectURL: "/oauth/callback", OnboardingSuccessURL: "welcome"},
+			wantErr: []string{"OAUTH_REDIRECT_URL", "ONBOARDING_SUCCESS_URL"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("expected valid config, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected errors mentioning %v", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected %q in error, got %v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadAspectTopics(t *testing.T) {
+	t.Setenv("GCP_PUBSUB_DELETE_TOPIC", "activity_deletes")
+	t.Setenv("GCP_PUBSUB_UPDATE_TOPIC", "")
+
+	topics := loadAspectTopics()
+	if len(topics) != 1 || topics[AspectDelete] != "activity_deletes" {
+		t.Errorf("expected only the delete override, got %v", topics)
+	}
+}
+
+func TestLoadKafkaSettings(t *testing.T) {
+	t.Setenv("KAFKA_BROKERS", "broker-1:9092, broker-2:9092,")
+	t.Setenv("KAFKA_SASL_MECHANISM", "scram-sha-512")
+	t.Setenv("KAFKA_TLS", "true")
+
+	settings := loadKafkaSettings()
+	if len(settings.Brokers) != 2 || settings.Brokers[1] != "broker-2:9092" {
+		t.Errorf("expected two trimmed brokers, got %q", settings.Brokers)
+	}
+	if settings.SASLMechanism != "scram-sha-512" || !settings.TLS {
+		t.Errorf("unexpected settings %+v", settings)
+	}
+}
+
+func TestLoadFilterRules(t *testing.T) {
+	t.Run("unset", func(t *testing.T) {
+		rules, err := loadFilterRules()
+		if err != nil || rules != nil {
+			t.Errorf("expected no rules, got %v, %v", rules, err)
+		}
+	})
+
+	t.Run("from file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "filters.json")
+		if err := os.WriteFile(path, []byte(`[{"aspect_type": "update", "action": "drop"}]`), 0o600); err != nil {
+			t.Fatalf("failed to write rules: %v", err)
+		}
+		t.Setenv("EVENT_FILTERS_FILE", path)
+
+		rules, err := loadFilterRules()
+		if err != nil || len(rules) != 1 {
+			t.Errorf("expected one rule, got %v, %v", rules, err)
+		}
+	})
+
+	t.Run("rejects both sources", func(t *testing.T) {
+		t.Setenv("EVENT_FILTERS", `[]`)
+		t.Setenv("EVENT_FILTERS_FILE", "filters.json")
+		if _, err := loadFilterRules(); err == nil {
+			t.Error("expected error when both are set")
+		}
+	})
+}
+
+func TestSecretCache_StartRefresh(t *testing.T) {
+	secretsPath := filepath.Join(t.TempDir(), "strava_auth.json")
+	writeTestSecretsFile(t, secretsPath, map[string]any{"webhook_verify_token": "old", "webhook_subscription_id": 1})
+	cache := NewSecretCache(secretsPath, time.Hour)
+	if token, _, err := cache.GetSecrets(); err != nil || token != "old" {
+		t.Fatalf("unexpected initial load: %q, %v", token, err)
+	}
+
+	cache.StartRefresh(5 * time.Millisecond)
+	defer cache.Close()
+	writeTestSecretsFile(t, secretsPath, map[string]any{"webhook_verify_token": "rotated", "webhook_subscription_id": 1})
+
+	// The TTL hasn't expired, so only the refresher can pick up the rotation
+	deadline := time.Now().Add(time.Second)
+	for {
+		if token, _, _ := cache.GetSecrets(); token == "rotated" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected background refresh to load rotated token")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,180 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/andy-esch/desirelines/packages/alert"
+)
+
+const (
+	// DefaultDeadLetterPrefix is the object prefix dead-lettered events are
+	// written under.
+	DefaultDeadLetterPrefix = "dead-letter/"
+
+	// deadLetterWriteTimeout bounds a dead-letter write independently of the
+	// request, whose deadline may be what made the publish fail.
+	deadLetterWriteTimeout = 5 * time.Second
+)
+
+// DeadLetterRecord is a webhook that could not be published, with enough
+// context to replay it later.
+type DeadLetterRecord struct {
+	FailedAt      time.Time      `json:"failed_at"`
+	CorrelationID string         `json:"correlation_id"`
+	Error         string         `json:"error"`
+	Webhook       WebhookRequest `json:"webhook"`
+}
+
+// DeadLetterStore persists records for events that could not be published.
+type DeadLetterStore interface {
+	Write(ctx context.Context, record DeadLetterRecord) error
+	Close() error
+}
+
+// GCSDeadLetterStore writes each record as a JSON object in a Cloud Storage
+// bucket, named <prefix>YYYY/MM/DD/<correlation_id>.json.
+type GCSDeadLetterStore struct {
+	client *storage.Client
+	bucket string
+	prefix string
+}
+
+// NewGCSDeadLetterStore creates a store writing to bucket under prefix.
+func NewGCSDeadLetterStore(ctx context.Context, bucket, prefix string) (*GCSDeadLetterStore, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
+	}
+	Logger.Info("Dead-letter store initialized", "bucket", bucket, "prefix", prefix)
+	return &GCSDeadLetterStore{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+// Write implements the DeadLetterStore interface.
+func (s *GCSDeadLetterStore) Write(ctx context.Context, record DeadLetterRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead-letter record: %w", err)
+	}
+
+	w := s.client.Bucket(s.bucket).Object(deadLetterObjectName(s.prefix, record)).NewWriter(ctx)
+	w.ContentType = "application/json"
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return fmt.Errorf("failed to write dead-letter record: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write dead-letter record: %w", err)
+	}
+	return nil
+}
+
+// Close implements the DeadLetterStore interface.
+func (s *GCSDeadLetterStore) Close() error {
+	if err := s.client.Close(); err != nil {
+		return fmt.Errorf("failed to close storage client: %w", err)
+	}
+	return nil
+}
+
+// deadLetterObjectName partitions records by failure date so a replay can
+// pick up one day at a time.
+func deadLetterObjectName(prefix string, record DeadLetterRecord) string {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix + path.Join(record.FailedAt.UTC().Format("2006/01/02"), record.CorrelationID+".json")
+}
+
+// DeadLetterPublisher writes events the wrapped publisher fails to publish
+// to a DeadLetterStore and reports them as handled, so Strava gets a success
+// and stops retrying an event that has been kept for replay. An event is
+// only reported as failed when the dead-letter write fails too.
+type DeadLetterPublisher struct {
+	publisher Publisher
+	store     DeadLetterStore
+	// alerter, if not nil, is told about every dead-lettered event
+	alerter *alert.Alerter
+	now     func() time.Time
+}
+
+// NewDeadLetterPublisher wraps publisher with store as its fallback,
+// alerting on each event it dead-letters when alerter is not nil.
+func NewDeadLetterPublisher(publisher Publisher, store DeadLetterStore, alerter *alert.Alerter) *DeadLetterPublisher {
+	return &DeadLetterPublisher{publisher: publisher, store: store, alerter: alerter, now: time.Now}
+}
+
+// Publish implements the Publisher interface.
+func (p *DeadLetterPublisher) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	return p.PublishBatch(ctx, []PendingEvent{newPendingEvent(ctx, webhook, correlationID)})[0]
+}
+
+// PublishBatch implements the BatchPublisher interface.
+func (p *DeadLetterPublisher) PublishBatch(ctx context.Context, events []PendingEvent) []error {
+	errs := publishAll(ctx, p.publisher, events)
+	for i, err := range errs {
+		if err != nil {
+			errs[i] = p.deadLetter(ctx, events[i], err)
+		}
+	}
+	return errs
+}
+
+func (p *DeadLetterPublisher) deadLetter(ctx context.Context, event PendingEvent, publishErr error) error {
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), deadLetterWriteTimeout)
+	defer cancel()
+
+	record := DeadLetterRecord{
+		FailedAt:      p.now(),
+		CorrelationID: event.CorrelationID,
+		Error:         publishErr.Error(),
+		Webhook:       event.Webhook,
+	}
+	if err := p.store.Write(writeCtx, record); err != nil {
+		Logger.Error("Failed to dead-letter webhook",
+			"correlation_id", event.CorrelationID,
+			"object_id", event.Webhook.ObjectID,
+			"publish_error", publishErr,
+			"error", err)
+		p.alert(writeCtx, "Webhook publish and dead-letter failed, Strava will retry", event, errors.Join(publishErr, err))
+		return errors.Join(publishErr, err)
+	}
+
+	Logger.Warn("Publish failed, webhook written to dead-letter store",
+		"correlation_id", event.CorrelationID,
+		"object_id", event.Webhook.ObjectID,
+		"owner_id", event.Webhook.OwnerID,
+		"error", publishErr)
+	p.alert(writeCtx, "Webhook dead-lettered", event, publishErr)
+	return nil
+}
+
+// alert reports a dead-lettered event. Alerts share one key, so an outage
+// posts once per cooldown rather than once per event.
+func (p *DeadLetterPublisher) alert(ctx context.Context, title string, event PendingEvent, err error) {
+	sendErr := p.alerter.Send(ctx, alert.Alert{
+		Key:   "dead_letter",
+		Title: title,
+		Text:  err.Error(),
+		Fields: []alert.Field{
+			{Name: "Object", Value: fmt.Sprintf("%s %d", event.Webhook.ObjectType, event.Webhook.ObjectID)},
+			{Name: "Owner", Value: strconv.FormatInt(event.Webhook.OwnerID, 10)},
+			{Name: "Correlation ID", Value: event.CorrelationID},
+		},
+	})
+	if sendErr != nil {
+		Logger.Warn("Failed to send dead-letter alert", "correlation_id", event.CorrelationID, "error", sendErr)
+	}
+}
+
+// Close implements the Publisher interface.
+func (p *DeadLetterPublisher) Close(ctx context.Context) error {
+	return errors.Join(p.publisher.Close(ctx), p.store.Close())
+}
@@ -0,0 +1,135 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/alert"
+)
+
+// memoryDeadLetterStore keeps records in memory and can be made to fail.
+type memoryDeadLetterStore struct {
+	writeErr error
+	records  []DeadLetterRecord
+	closed   bool
+}
+
+func (m *memoryDeadLetterStore) Write(ctx context.Context, record DeadLetterRecord) error {
+	if m.writeErr != nil {
+		return m.writeErr
+	}
+	m.records = append(m.records, record)
+	return nil
+}
+
+func (m *memoryDeadLetterStore) Close() error {
+	m.closed = true
+	return nil
+}
+
+func TestDeadLetterPublisher(t *testing.T) {
+	failedAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	webhook := WebhookRequest{ObjectID: 42, OwnerID: 7, AspectType: AspectCreate}
+
+	t.Run("passes successful publishes through", func(t *testing.T) {
+		inner := &MockPublisher{}
+		store := &memoryDeadLetterStore{}
+		publisher := NewDeadLetterPublisher(inner, store, nil)
+
+		if err := publisher.Publish(context.Background(), webhook, "corr-1"); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+		if len(inner.Published) != 1 || len(store.records) != 0 {
+			t.Errorf("expected publish without dead-letter, got %d published and %d records", len(inner.Published), len(store.records))
+		}
+	})
+
+	t.Run("dead-letters failed publishes", func(t *testing.T) {
+		store := &memoryDeadLetterStore{}
+		publisher := NewDeadLetterPublisher(&MockPublisher{PublishErr: errors.New("pubsub down")}, store, nil)
+		publisher.now = func() time.Time { return failedAt }
+
+		if err := publisher.Publish(context.Background(), webhook, "corr-1"); err != nil {
+			t.Fatalf("expected dead-lettered event to be reported as handled, got %v", err)
+		}
+		if len(store.records) != 1 {
+			t.Fatalf("expected 1 record, got %d", len(store.records))
+		}
+		record := store.records[0]
+		if !record.FailedAt.Equal(failedAt) || record.CorrelationID != "corr-1" || record.Error != "pubsub down" || record.Webhook.ObjectID != 42 {
+			t.Errorf("unexpected record %+v", record)
+		}
+	})
+
+	t.Run("fails when dead-letter write fails", func(t *testing.T) {
+		publishErr := errors.New("pubsub down")
+		store := &memoryDeadLetterStore{writeErr: errors.New("gcs down")}
+		publisher := NewDeadLetterPublisher(&MockPublisher{PublishErr: publishErr}, store, nil)
+
+		if err := publisher.Publish(context.Background(), webhook, "corr-1"); !errors.Is(err, publishErr) {
+			t.Errorf("expected publish error to be returned, got %v", err)
+		}
+	})
+
+	t.Run("writes even after the request context ends", func(t *testing.T) {
+		store := &memoryDeadLetterStore{}
+		publisher := NewDeadLetterPublisher(&MockPublisher{PublishErr: context.DeadlineExceeded}, store, nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := publisher.Publish(ctx, webhook, "corr-1"); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+		if len(store.records) != 1 {
+			t.Errorf("expected 1 record, got %d", len(store.records))
+		}
+	})
+
+	t.Run("alerts on dead-lettered events", func(t *testing.T) {
+		var alerts []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			alerts = append(alerts, string(body))
+		}))
+		defer server.Close()
+		alerter := alert.New(server.URL, alert.FormatSlack, "dispatcher")
+		publisher := NewDeadLetterPublisher(&MockPublisher{PublishErr: errors.New("pubsub down")}, &memoryDeadLetterStore{}, alerter)
+
+		for _, correlationID := range []string{"corr-1", "corr-2"} {
+			if err := publisher.Publish(context.Background(), webhook, correlationID); err != nil {
+				t.Fatalf("Publish failed: %v", err)
+			}
+		}
+		if len(alerts) != 1 || !strings.Contains(alerts[0], "Webhook dead-lettered") || !strings.Contains(alerts[0], "pubsub down") || !strings.Contains(alerts[0], "corr-1") {
+			t.Errorf("expected one alert for the outage, got %q", alerts)
+		}
+	})
+
+	t.Run("closes publisher and store", func(t *testing.T) {
+		inner := &MockPublisher{}
+		store := &memoryDeadLetterStore{}
+		if err := NewDeadLetterPublisher(inner, store, nil).Close(context.Background()); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		if !inner.Closed || !store.closed {
+			t.Error("expected both publisher and store to be closed")
+		}
+	})
+}
+
+func TestDeadLetterObjectName(t *testing.T) {
+	record := DeadLetterRecord{FailedAt: time.Date(2025, 6, 1, 23, 30, 0, 0, time.FixedZone("PDT", -7*3600)), CorrelationID: "abc"}
+
+	if got := deadLetterObjectName("dead-letter/", record); got != "dead-letter/2025/06/02/abc.json" {
+		t.Errorf("unexpected object name %q", got)
+	}
+	if got := deadLetterObjectName("failed", record); got != "failed/2025/06/02/abc.json" {
+		t.Errorf("expected prefix to gain a slash, got %q", got)
+	}
+}
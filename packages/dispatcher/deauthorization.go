@@ -0,0 +1,53 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+)
+
+// DeauthorizationAttribute is the Pub/Sub attribute set to "true" on
+// athlete events revoking the app's access.
+const DeauthorizationAttribute = "deauthorization"
+
+// IsDeauthorization reports whether webhook is an athlete event with
+// updates.authorized set to false.
+func IsDeauthorization(webhook WebhookRequest) bool {
+	return webhook.ObjectType == ObjectAthlete &&
+		webhook.UpdateFields != nil &&
+		webhook.UpdateFields.IsDeauthorization()
+}
+
+// DeauthorizationRouter sends deauthorization events to a separate
+// publisher, typically one for a topic that drives data cleanup, and
+// everything else to the wrapped publisher.
+type DeauthorizationRouter struct {
+	events           Publisher
+	deauthorizations Publisher
+}
+
+// NewDeauthorizationRouter routes deauthorization events to
+// deauthorizations and everything else to events.
+func NewDeauthorizationRouter(events, deauthorizations Publisher) *DeauthorizationRouter {
+	return &DeauthorizationRouter{events: events, deauthorizations: deauthorizations}
+}
+
+// Publish implements the Publisher interface.
+func (r *DeauthorizationRouter) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	return r.PublishBatch(ctx, []PendingEvent{newPendingEvent(ctx, webhook, correlationID)})[0]
+}
+
+// PublishBatch implements the BatchPublisher interface, splitting the batch
+// between the two publishers.
+func (r *DeauthorizationRouter) PublishBatch(ctx context.Context, events []PendingEvent) []error {
+	return routeBatch(ctx, events, func(event PendingEvent) Publisher {
+		if IsDeauthorization(event.Webhook) {
+			return r.deauthorizations
+		}
+		return r.events
+	})
+}
+
+// Close implements the Publisher interface.
+func (r *DeauthorizationRouter) Close(ctx context.Context) error {
+	return errors.Join(r.events.Close(ctx), r.deauthorizations.Close(ctx))
+}
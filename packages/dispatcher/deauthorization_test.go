@@ -0,0 +1,61 @@
+package dispatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func deauthorizationWebhook() WebhookRequest {
+	authorized := false
+	return WebhookRequest{
+		ObjectType:   ObjectAthlete,
+		AspectType:   AspectUpdate,
+		ObjectID:     7,
+		OwnerID:      7,
+		UpdateFields: &UpdateFields{Authorized: &authorized},
+	}
+}
+
+func TestIsDeauthorization(t *testing.T) {
+	if !IsDeauthorization(deauthorizationWebhook()) {
+		t.Error("expected authorized=false athlete event to be a deauthorization")
+	}
+
+	activity := deauthorizationWebhook()
+	activity.ObjectType = ObjectActivity
+	if IsDeauthorization(activity) {
+		t.Error("expected activity events never to be deauthorizations")
+	}
+	if IsDeauthorization(WebhookRequest{ObjectType: ObjectAthlete}) {
+		t.Error("expected athlete event without updates not to be a deauthorization")
+	}
+}
+
+func TestDeauthorizationRouter(t *testing.T) {
+	events := &MockPublisher{}
+	deauthorizations := &MockPublisher{}
+	router := NewDeauthorizationRouter(events, deauthorizations)
+
+	ctx := context.Background()
+	if err := router.Publish(ctx, WebhookRequest{ObjectType: ObjectActivity, ObjectID: 1}, "corr-1"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if err := router.Publish(ctx, deauthorizationWebhook(), "corr-2"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	if len(events.Published) != 1 || events.Published[0].ObjectID != 1 {
+		t.Errorf("expected the activity event on the events publisher, got %+v", events.Published)
+	}
+	if len(deauthorizations.Published) != 1 || deauthorizations.Published[0].ObjectID != 7 {
+		t.Errorf("expected the deauthorization on its own publisher, got %+v", deauthorizations.Published)
+	}
+}
+
+func TestEventAttributes_Deauthorization(t *testing.T) {
+	attributes := eventAttributes(PendingEvent{Webhook: deauthorizationWebhook()}, MessageFormatRaw, time.Now(), 0)
+	if attributes[DeauthorizationAttribute] != "true" {
+		t.Errorf("expected deauthorization attribute, got %v", attributes)
+	}
+}
@@ -0,0 +1,148 @@
+package dispatcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxLoggedPayloadBytes caps how much of a request or response body a
+// debug payload entry holds; webhook bodies larger than this are rejected
+// anyway.
+const maxLoggedPayloadBytes = MaxWebhookBodyBytes
+
+// PayloadLogger writes the DEBUG_PAYLOADS entries. Like AccessLogger it
+// ignores LOG_LEVEL, so payloads can be logged without every other debug
+// message.
+var PayloadLogger = newLogger(slog.LevelDebug)
+
+// alwaysRedacted are names masked in payload logs even though they don't
+// look like credentials.
+var alwaysRedacted = []string{"Authorization", "Cookie"}
+
+// redacts reports whether a header, query parameter or JSON field called
+// name is masked in payload logs: anything named like a credential (as in
+// /admin/status), plus the configured fields.
+func redacts(name string, fields []string) bool {
+	if sensitiveConfigField.MatchString(name) {
+		return true
+	}
+	matches := func(field string) bool { return strings.EqualFold(name, field) }
+	return slices.ContainsFunc(fields, matches) || slices.ContainsFunc(alwaysRedacted, matches)
+}
+
+// peekBody reads up to maxLoggedPayloadBytes of r's body for logging and
+// puts it back, so the handler still sees (and size-checks) all of it.
+func peekBody(r *http.Request) []byte {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxLoggedPayloadBytes))
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), r.Body), Closer: r.Body}
+	if err != nil {
+		return nil
+	}
+	return body
+}
+
+// readCloser reads from one source and closes another.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// logPayloads writes one PayloadLogger entry with the request and
+// response, redacted: credential-like and configured names are masked in
+// headers, query parameters and JSON bodies at any depth, and bodies that
+// aren't JSON are reduced to their size since they can't be redacted.
+func (h *Handler) logPayloads(r *http.Request, requestBody []byte, recorder *statusRecorder, correlationID string) {
+	fields := h.config.RedactFields
+	status := recorder.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	PayloadLogger.LogAttrs(r.Context(), slog.LevelDebug, "Webhook request and response",
+		slog.String("correlation_id", correlationID),
+		slog.Group("request",
+			slog.String("method", r.Method),
+			slog.String("url", redactURL(r.URL, fields)),
+			slog.Any("headers", redactHeaders(r.Header, fields)),
+			redactBody(requestBody, fields),
+		),
+		slog.Group("response",
+			slog.Int("status", status),
+			slog.Any("headers", redactHeaders(recorder.Header(), fields)),
+			redactBody(recorder.body.Bytes(), fields),
+		),
+	)
+}
+
+// redactURL returns u's path and query with redacted parameter values
+// masked.
+func redactURL(u *url.URL, fields []string) string {
+	query := u.Query()
+	for name := range query {
+		if redacts(name, fields) {
+			query[name] = []string{maskedValue}
+		}
+	}
+	if len(query) == 0 {
+		return u.EscapedPath()
+	}
+	return u.EscapedPath() + "?" + query.Encode()
+}
+
+// redactHeaders flattens header values, masking redacted ones.
+func redactHeaders(header http.Header, fields []string) map[string]string {
+	redacted := make(map[string]string, len(header))
+	for name, values := range header {
+		if redacts(name, fields) {
+			redacted[name] = maskedValue
+			continue
+		}
+		redacted[name] = strings.Join(values, ", ")
+	}
+	return redacted
+}
+
+// redactBody returns a "body" attribute holding body's JSON with redacted
+// fields masked, or a "body_bytes" attribute when body isn't JSON.
+func redactBody(body []byte, fields []string) slog.Attr {
+	if len(body) == 0 {
+		return slog.Attr{}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// Numbers are kept as written so large IDs aren't rounded
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return slog.String("body_bytes", strconv.Itoa(len(body)))
+	}
+	return slog.Any("body", redactValue(value, fields))
+}
+
+// redactValue masks redacted fields of decoded JSON, recursively.
+func redactValue(value any, fields []string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if redacts(key, fields) {
+				v[key] = maskedValue
+				continue
+			}
+			v[key] = redactValue(item, fields)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactValue(item, fields)
+		}
+	}
+	return value
+}
@@ -0,0 +1,88 @@
+package dispatcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_DebugPayloads(t *testing.T) {
+	var out bytes.Buffer
+	previous := PayloadLogger
+	PayloadLogger = slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	t.Cleanup(func() { PayloadLogger = previous })
+
+	secretsPath := filepath.Join(t.TempDir(), "strava_auth.json")
+	writeTestSecretsFile(t, secretsPath, map[string]any{
+		"webhook_verify_token":    "test-token",
+		"webhook_subscription_id": 12345,
+	})
+	publisher := &MockPublisher{}
+	handler := NewHandlerWithPublisher(&Config{DebugPayloads: true, RedactFields: []string{"title"}}, publisher)
+	handler.secrets = NewSecretCache(secretsPath, time.Minute)
+
+	verify := httptest.NewRequest(http.MethodGet, "/?hub.mode=subscribe&hub.challenge=abc&hub.verify_token=test-token", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), verify)
+
+	body := `{"aspect_type":"update","object_type":"activity","object_id":12345678901234,"owner_id":7,"event_time":1,"subscription_id":12345,"updates":{"title":"Home"}}`
+	event := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	event.Header.Set("Authorization", "Bearer admin")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, event)
+	if rr.Code != http.StatusCreated || len(publisher.Published) != 1 {
+		t.Fatalf("expected the event to be published after logging its body, got %d", rr.Code)
+	}
+
+	logged := out.String()
+	for _, secret := range []string{"test-token", "Bearer admin", "Home"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("payload log leaked %q: %s", secret, logged)
+		}
+	}
+
+	type entry struct {
+		Request struct {
+			URL     string            `json:"url"`
+			Headers map[string]string `json:"headers"`
+			Body    map[string]any    `json:"body"`
+		} `json:"request"`
+		Response struct {
+			Body   map[string]any `json:"body"`
+			Status int            `json:"status"`
+		} `json:"response"`
+	}
+	decoder := json.NewDecoder(&out)
+	var verification, webhook entry
+	if err := decoder.Decode(&verification); err != nil {
+		t.Fatalf("invalid payload log line: %v", err)
+	}
+	if err := decoder.Decode(&webhook); err != nil {
+		t.Fatalf("invalid payload log line: %v", err)
+	}
+
+	if !strings.Contains(verification.Request.URL, "hub.verify_token=%2A%2A%2A") || verification.Response.Body["hub.challenge"] != "abc" {
+		t.Errorf("unexpected verification entry: %+v", verification)
+	}
+	if webhook.Request.Headers["Authorization"] != maskedValue {
+		t.Errorf("expected Authorization to be masked, got %v", webhook.Request.Headers)
+	}
+	if webhook.Request.Body["object_id"] != float64(12345678901234) || webhook.Request.Body["updates"].(map[string]any)["title"] != maskedValue {
+		t.Errorf("unexpected request body: %v", webhook.Request.Body)
+	}
+	if webhook.Response.Status != http.StatusCreated || webhook.Response.Body["correlation_id"] == nil {
+		t.Errorf("unexpected response: %+v", webhook.Response)
+	}
+}
+
+func TestRedactBody_NotJSON(t *testing.T) {
+	attr := redactBody([]byte("token=abc"), nil)
+	if attr.Key != "body_bytes" || attr.Value.String() != "9" {
+		t.Errorf("expected only the body size, got %v", attr)
+	}
+}
@@ -0,0 +1,219 @@
+package dispatcher
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultDedupeCacheSize bounds the in-memory dedupe cache.
+const DefaultDedupeCacheSize = 10000
+
+// DedupeKey identifies a webhook delivery. Strava redelivers the same event
+// with the same object, aspect and event time.
+func DedupeKey(webhook WebhookRequest) string {
+	return fmt.Sprintf("%d-%s-%d", webhook.ObjectID, webhook.AspectType, webhook.EventTime)
+}
+
+// DedupeStore remembers recently seen dedupe keys.
+type DedupeStore interface {
+	// MarkSeen records key as seen at now and reports whether it had
+	// already been seen within window.
+	MarkSeen(ctx context.Context, key string, now time.Time, window time.Duration) (bool, error)
+	// Forget removes key, so a redelivery of an event that failed to
+	// publish is let through.
+	Forget(ctx context.Context, key string) error
+	Close() error
+}
+
+// MemoryDedupeStore is a per-instance LRU of seen keys.
+type MemoryDedupeStore struct {
+	entries  map[string]*list.Element
+	order    *list.List
+	capacity int
+	mu       sync.Mutex
+}
+
+type memoryDedupeEntry struct {
+	seenAt time.Time
+	key    string
+}
+
+// NewMemoryDedupeStore creates an LRU holding up to capacity keys.
+func NewMemoryDedupeStore(capacity int) *MemoryDedupeStore {
+	if capacity <= 0 {
+		capacity = DefaultDedupeCacheSize
+	}
+	return &MemoryDedupeStore{
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		capacity: capacity,
+	}
+}
+
+// MarkSeen implements the DedupeStore interface.
+func (s *MemoryDedupeStore) MarkSeen(ctx context.Context, key string, now time.Time, window time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		entry := elem.Value.(*memoryDedupeEntry)
+		if now.Sub(entry.seenAt) < window {
+			return true, nil
+		}
+		entry.seenAt = now
+		s.order.MoveToFront(elem)
+		return false, nil
+	}
+
+	s.entries[key] = s.order.PushFront(&memoryDedupeEntry{key: key, seenAt: now})
+	if s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryDedupeEntry).key)
+	}
+	return false, nil
+}
+
+// Forget implements the DedupeStore interface.
+func (s *MemoryDedupeStore) Forget(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[key]; ok {
+		s.order.Remove(elem)
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// Close implements the DedupeStore interface.
+func (s *MemoryDedupeStore) Close() error {
+	return nil
+}
+
+// dedupeDocument is the Firestore record of a seen key. expire_at can back
+// a Firestore TTL policy so old keys are deleted automatically.
+type dedupeDocument struct {
+	SeenAt   time.Time `firestore:"seen_at"`
+	ExpireAt time.Time `firestore:"expire_at"`
+}
+
+// FirestoreDedupeStore shares seen keys across instances through a
+// Firestore collection, one document per key.
+type FirestoreDedupeStore struct {
+	client     *firestore.Client
+	collection string
+}
+
+// NewFirestoreDedupeStore creates a store using collection in the
+// project's default database.
+func NewFirestoreDedupeStore(ctx context.Context, projectID, collection string) (*FirestoreDedupeStore, error) {
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	Logger.Info("Dedupe store initialized", "collection", collection)
+	return &FirestoreDedupeStore{client: client, collection: collection}, nil
+}
+
+// MarkSeen implements the DedupeStore interface. The check and the write
+// happen in one transaction, so concurrent deliveries to different
+// instances can't both pass.
+func (s *FirestoreDedupeStore) MarkSeen(ctx context.Context, key string, now time.Time, window time.Duration) (bool, error) {
+	ref := s.client.Collection(s.collection).Doc(key)
+	duplicate := false
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		duplicate = false
+		snapshot, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			var doc dedupeDocument
+			if err := snapshot.DataTo(&doc); err == nil && now.Sub(doc.SeenAt) < window {
+				duplicate = true
+				return nil
+			}
+		}
+		return tx.Set(ref, dedupeDocument{SeenAt: now, ExpireAt: now.Add(window)})
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to check dedupe key: %w", err)
+	}
+	return duplicate, nil
+}
+
+// Forget implements the DedupeStore interface.
+func (s *FirestoreDedupeStore) Forget(ctx context.Context, key string) error {
+	if _, err := s.client.Collection(s.collection).Doc(key).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to forget dedupe key: %w", err)
+	}
+	return nil
+}
+
+// Close implements the DedupeStore interface.
+func (s *FirestoreDedupeStore) Close() error {
+	if err := s.client.Close(); err != nil {
+		return fmt.Errorf("failed to close Firestore client: %w", err)
+	}
+	return nil
+}
+
+// DedupingPublisher drops events whose dedupe key was already seen within
+// the window, consulting each store in order (typically the in-memory LRU,
+// then a shared store). A store error lets the event through: publishing a
+// duplicate is better than losing an event.
+type DedupingPublisher struct {
+	publisher Publisher
+	now       func() time.Time
+	stores    []DedupeStore
+	window    time.Duration
+}
+
+// NewDedupingPublisher wraps publisher with the given stores.
+func NewDedupingPublisher(publisher Publisher, window time.Duration, stores ...DedupeStore) *DedupingPublisher {
+	return &DedupingPublisher{publisher: publisher, now: time.Now, stores: stores, window: window}
+}
+
+// Publish implements the Publisher interface.
+func (p *DedupingPublisher) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	key := DedupeKey(webhook)
+	now := p.now()
+	for _, store := range p.stores {
+		duplicate, err := store.MarkSeen(ctx, key, now, p.window)
+		if err != nil {
+			Logger.Warn("Dedupe check failed, publishing anyway", "correlation_id", correlationID, "dedupe_key", key, "error", err)
+			continue
+		}
+		if duplicate {
+			Logger.Info("Suppressed duplicate webhook", "correlation_id", correlationID, "dedupe_key", key)
+			return nil
+		}
+	}
+
+	if err := p.publisher.Publish(ctx, webhook, correlationID); err != nil {
+		for _, store := range p.stores {
+			if forgetErr := store.Forget(context.WithoutCancel(ctx), key); forgetErr != nil {
+				Logger.Warn("Failed to forget dedupe key after publish failure", "correlation_id", correlationID, "dedupe_key", key, "error", forgetErr)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// Close implements the Publisher interface.
+func (p *DedupingPublisher) Close(ctx context.Context) error {
+	errs := []error{p.publisher.Close(ctx)}
+	for _, store := range p.stores {
+		errs = append(errs, store.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,118 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// failingDedupeStore always errors, to check the publisher fails open.
+type failingDedupeStore struct{}
+
+func (failingDedupeStore) MarkSeen(ctx context.Context, key string, now time.Time, window time.Duration) (bool, error) {
+	return false, errors.New("store unavailable")
+}
+
+func (failingDedupeStore) Forget(ctx context.Context, key string) error { return nil }
+
+func (failingDedupeStore) Close() error { return nil }
+
+func TestDedupeKey(t *testing.T) {
+	webhook := WebhookRequest{ObjectID: 42, AspectType: AspectUpdate, EventTime: 1700000000}
+	if got := DedupeKey(webhook); got != "42-update-1700000000" {
+		t.Errorf("unexpected dedupe key %q", got)
+	}
+
+	later := webhook
+	later.EventTime++
+	if DedupeKey(later) == DedupeKey(webhook) {
+		t.Error("expected a different event time to produce a different key")
+	}
+}
+
+func TestMemoryDedupeStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("suppresses within window", func(t *testing.T) {
+		store := NewMemoryDedupeStore(10)
+		if dup, _ := store.MarkSeen(ctx, "a", now, time.Minute); dup {
+			t.Error("expected first sighting not to be a duplicate")
+		}
+		if dup, _ := store.MarkSeen(ctx, "a", now.Add(30*time.Second), time.Minute); !dup {
+			t.Error("expected repeat within window to be a duplicate")
+		}
+		if dup, _ := store.MarkSeen(ctx, "a", now.Add(2*time.Minute), time.Minute); dup {
+			t.Error("expected repeat after window to pass")
+		}
+	})
+
+	t.Run("evicts least recently used", func(t *testing.T) {
+		store := NewMemoryDedupeStore(2)
+		_, _ = store.MarkSeen(ctx, "a", now, time.Hour)
+		_, _ = store.MarkSeen(ctx, "b", now, time.Hour)
+		_, _ = store.MarkSeen(ctx, "c", now, time.Hour)
+
+		if dup, _ := store.MarkSeen(ctx, "a", now, time.Hour); dup {
+			t.Error("expected oldest key to have been evicted")
+		}
+		if dup, _ := store.MarkSeen(ctx, "c", now, time.Hour); !dup {
+			t.Error("expected newest key to be retained")
+		}
+	})
+
+	t.Run("forget lets key through", func(t *testing.T) {
+		store := NewMemoryDedupeStore(10)
+		_, _ = store.MarkSeen(ctx, "a", now, time.Hour)
+		_ = store.Forget(ctx, "a")
+		if dup, _ := store.MarkSeen(ctx, "a", now, time.Hour); dup {
+			t.Error("expected forgotten key not to be a duplicate")
+		}
+	})
+}
+
+func TestDedupingPublisher(t *testing.T) {
+	webhook := WebhookRequest{ObjectID: 42, AspectType: AspectCreate, EventTime: 1700000000}
+
+	t.Run("publishes once per window", func(t *testing.T) {
+		inner := &MockPublisher{}
+		publisher := NewDedupingPublisher(inner, time.Minute, NewMemoryDedupeStore(10))
+
+		for _, id := range []string{"corr-1", "corr-2"} {
+			if err := publisher.Publish(context.Background(), webhook, id); err != nil {
+				t.Fatalf("Publish failed: %v", err)
+			}
+		}
+		if len(inner.Published) != 1 {
+			t.Errorf("expected redelivery to be suppressed, got %d publishes", len(inner.Published))
+		}
+	})
+
+	t.Run("retries after failed publish", func(t *testing.T) {
+		inner := &MockPublisher{PublishErr: errors.New("pubsub down")}
+		publisher := NewDedupingPublisher(inner, time.Minute, NewMemoryDedupeStore(10))
+
+		if err := publisher.Publish(context.Background(), webhook, "corr-1"); err == nil {
+			t.Fatal("expected publish error")
+		}
+		inner.PublishErr = nil
+		if err := publisher.Publish(context.Background(), webhook, "corr-2"); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+		if len(inner.Published) != 1 {
+			t.Errorf("expected Strava's retry to be published, got %d publishes", len(inner.Published))
+		}
+	})
+
+	t.Run("fails open when store errors", func(t *testing.T) {
+		inner := &MockPublisher{}
+		publisher := NewDedupingPublisher(inner, time.Minute, failingDedupeStore{})
+
+		_ = publisher.Publish(context.Background(), webhook, "corr-1")
+		_ = publisher.Publish(context.Background(), webhook, "corr-2")
+		if len(inner.Published) != 2 {
+			t.Errorf("expected both deliveries published when dedupe is unavailable, got %d", len(inner.Published))
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+const (
+	// DefaultEnrichTimeout bounds the Strava lookup for one event.
+	DefaultEnrichTimeout = time.Second
+
+	// EnrichedAttribute is the Pub/Sub attribute set to "true" on events
+	// carrying the full activity.
+	EnrichedAttribute = "enriched"
+)
+
+// ActivityFetcher returns the detailed JSON for an activity, as
+// *strava.Client does.
+type ActivityFetcher interface {
+	GetActivityJSON(ctx context.Context, id int64) (json.RawMessage, error)
+}
+
+// EnrichingPublisher attaches the full Strava activity to create and update
+// events before publishing, so consumers don't each need Strava credentials
+// and rate-limit handling. If the lookup fails the event is published
+// without it and consumers fall back to fetching the activity themselves.
+type EnrichingPublisher struct {
+	publisher Publisher
+	// fetchers returns the fetcher for an owner's activities
+	fetchers func(ctx context.Context, ownerID int64) (ActivityFetcher, error)
+	timeout  time.Duration
+}
+
+// NewEnrichingPublisher wraps publisher, looking activities up with fetcher.
+func NewEnrichingPublisher(publisher Publisher, fetcher ActivityFetcher, timeout time.Duration) *EnrichingPublisher {
+	return NewAthleteEnrichingPublisher(publisher, func(context.Context, int64) (ActivityFetcher, error) {
+		return fetcher, nil
+	}, timeout)
+}
+
+// NewAthleteEnrichingPublisher wraps publisher, looking each activity up
+// with the fetcher fetchers returns for its owner, e.g. with the owner's
+// Strava tokens.
+func NewAthleteEnrichingPublisher(publisher Publisher, fetchers func(ctx context.Context, ownerID int64) (ActivityFetcher, error), timeout time.Duration) *EnrichingPublisher {
+	if timeout <= 0 {
+		timeout = DefaultEnrichTimeout
+	}
+	return &EnrichingPublisher{publisher: publisher, fetchers: fetchers, timeout: timeout}
+}
+
+// Publish implements the Publisher interface.
+func (p *EnrichingPublisher) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	return p.publisher.Publish(ctx, p.enrich(ctx, webhook, correlationID), correlationID)
+}
+
+// PublishBatch implements the BatchPublisher interface.
+func (p *EnrichingPublisher) PublishBatch(ctx context.Context, events []PendingEvent) []error {
+	enriched := make([]PendingEvent, len(events))
+	for i, event := range events {
+		event.Webhook = p.enrich(ctx, event.Webhook, event.CorrelationID)
+		enriched[i] = event
+	}
+	return publishAll(ctx, p.publisher, enriched)
+}
+
+// Close implements the Publisher interface.
+func (p *EnrichingPublisher) Close(ctx context.Context) error {
+	return p.publisher.Close(ctx)
+}
+
+func (p *EnrichingPublisher) enrich(ctx context.Context, webhook WebhookRequest, correlationID string) WebhookRequest {
+	if webhook.ObjectType != ObjectActivity || webhook.AspectType == AspectDelete || webhook.Activity != nil {
+		return webhook
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	fetcher, err := p.fetchers(ctx, webhook.OwnerID)
+	var activity json.RawMessage
+	if err == nil {
+		activity, err = fetcher.GetActivityJSON(ctx, webhook.ObjectID)
+	}
+	if err != nil {
+		level := Logger.Warn
+		if errors.Is(err, strava.ErrNotFound) {
+			level = Logger.Info
+		}
+		level("Activity enrichment failed, publishing without details",
+			"correlation_id", correlationID,
+			"object_id", webhook.ObjectID,
+			"error", err)
+		return webhook
+	}
+
+	webhook.Activity = activity
+	return webhook
+}
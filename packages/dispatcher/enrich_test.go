@@ -0,0 +1,129 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+// stubFetcher returns a canned activity or error.
+type stubFetcher struct {
+	err   error
+	calls int
+}
+
+func (s *stubFetcher) GetActivityJSON(ctx context.Context, id int64) (json.RawMessage, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return json.RawMessage(fmt.Sprintf(`{"id":%d}`, id)), nil
+}
+
+func TestEnrichingPublisher(t *testing.T) {
+	tests := []struct {
+		fetchErr     error
+		name         string
+		webhook      WebhookRequest
+		wantActivity string
+		wantCalls    int
+	}{
+		{
+			name:         "create is enriched",
+			webhook:      WebhookRequest{ObjectType: ObjectActivity, AspectType: AspectCreate, ObjectID: 42},
+			wantActivity: `{"id":42}`,
+			wantCalls:    1,
+		},
+		{
+			name:      "delete is not looked up",
+			webhook:   WebhookRequest{ObjectType: ObjectActivity, AspectType: AspectDelete, ObjectID: 42},
+			wantCalls: 0,
+		},
+		{
+			name:      "athlete events are not looked up",
+			webhook:   WebhookRequest{ObjectType: ObjectAthlete, AspectType: AspectUpdate, ObjectID: 7},
+			wantCalls: 0,
+		},
+		{
+			name:      "lookup failure publishes plain event",
+			webhook:   WebhookRequest{ObjectType: ObjectActivity, AspectType: AspectUpdate, ObjectID: 42},
+			fetchErr:  strava.ErrNotFound,
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &MockPublisher{}
+			fetcher := &stubFetcher{err: tt.fetchErr}
+			publisher := NewEnrichingPublisher(inner, fetcher, 0)
+
+			if err := publisher.Publish(context.Background(), tt.webhook, "corr"); err != nil {
+				t.Fatalf("Publish failed: %v", err)
+			}
+			if fetcher.calls != tt.wantCalls {
+				t.Errorf("expected %d lookups, got %d", tt.wantCalls, fetcher.calls)
+			}
+			if len(inner.Published) != 1 {
+				t.Fatalf("expected 1 published event, got %d", len(inner.Published))
+			}
+			if got := string(inner.Published[0].Activity); got != tt.wantActivity {
+				t.Errorf("expected activity %q, got %q", tt.wantActivity, got)
+			}
+		})
+	}
+}
+
+func TestEnrichedPayloadRoundTrip(t *testing.T) {
+	webhook := WebhookRequest{ObjectType: ObjectActivity, AspectType: AspectCreate, ObjectID: 42, Activity: json.RawMessage(`{"id":42}`)}
+
+	data, err := json.Marshal(webhook)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if _, ok := decoded["activity"].(map[string]any); !ok {
+		t.Errorf("expected activity object in payload, got %s", data)
+	}
+
+	plain, err := json.Marshal(WebhookRequest{ObjectID: 42})
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if strings.Contains(string(plain), `"activity"`) {
+		t.Errorf("expected no activity field on unenriched events, got %s", plain)
+	}
+}
+
+func TestHandler_IgnoresIncomingActivity(t *testing.T) {
+	secretsPath := t.TempDir() + "/strava_auth.json"
+	writeTestSecretsFile(t, secretsPath, map[string]any{
+		"webhook_verify_token":    "test-token",
+		"webhook_subscription_id": 12345,
+	})
+
+	mockPub := &MockPublisher{}
+	handler := NewHandlerWithPublisher(&Config{}, mockPub)
+	handler.secrets = NewSecretCache(secretsPath, time.Minute)
+
+	body := `{"aspect_type":"create","event_time":1234567890,"object_id":1,"object_type":"activity","owner_id":2,"subscription_id":12345,"activity":{"distance":1e9}}`
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(mockPub.Published) != 1 || mockPub.Published[0].Activity != nil {
+		t.Errorf("expected caller-supplied activity to be dropped, got %+v", mockPub.Published)
+	}
+}
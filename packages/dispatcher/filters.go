@@ -0,0 +1,138 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Filter rule actions.
+const (
+	// FilterDrop acknowledges matching events without publishing them.
+	FilterDrop = "drop"
+	// FilterRoute publishes matching events to the rule's topic.
+	FilterRoute = "route"
+)
+
+// FilterRule matches events by aspect_type, object_type and sport type,
+// with empty fields matching anything, and drops or routes them.
+type FilterRule struct {
+	AspectType string `json:"aspect_type,omitempty"`
+	ObjectType string `json:"object_type,omitempty"`
+	// SportType matches the activity's sport_type when the event is
+	// enriched, or a type change in its updates.
+	SportType string `json:"sport_type,omitempty"`
+	Action    string `json:"action"`
+	// Topic is the destination of FilterRoute rules.
+	Topic string `json:"topic,omitempty"`
+}
+
+// ParseFilterRules parses a JSON array of rules, e.g.
+//
+//	[{"aspect_type": "update", "action": "drop"},
+//	 {"aspect_type": "delete", "action": "route", "topic": "activity_deletes"}]
+func ParseFilterRules(data []byte) ([]FilterRule, error) {
+	var rules []FilterRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse filter rules: %w", err)
+	}
+	for i, rule := range rules {
+		switch rule.Action {
+		case FilterDrop:
+		case FilterRoute:
+			if rule.Topic == "" {
+				return nil, fmt.Errorf("filter rule %d: route requires a topic", i)
+			}
+		default:
+			return nil, fmt.Errorf("filter rule %d: unknown action %q", i, rule.Action)
+		}
+	}
+	return rules, nil
+}
+
+// Matches reports whether webhook satisfies every field set in the rule.
+func (r FilterRule) Matches(webhook WebhookRequest) bool {
+	return (r.AspectType == "" || r.AspectType == webhook.AspectType) &&
+		(r.ObjectType == "" || r.ObjectType == webhook.ObjectType) &&
+		(r.SportType == "" || r.SportType == SportType(webhook))
+}
+
+// SportType returns the activity's sport type from its enriched details or
+// its updates, or "" when the event doesn't carry one.
+func SportType(webhook WebhookRequest) string {
+	if webhook.Activity != nil {
+		var activity struct {
+			SportType string `json:"sport_type"`
+		}
+		if err := json.Unmarshal(webhook.Activity, &activity); err == nil && activity.SportType != "" {
+			return activity.SportType
+		}
+	}
+	if webhook.UpdateFields != nil && webhook.UpdateFields.Type != nil {
+		return *webhook.UpdateFields.Type
+	}
+	return ""
+}
+
+// FilterRouter applies the first rule matching each event: drop rules
+// discard it, route rules publish it to their topic's publisher, and events
+// matching no rule go to the wrapped publisher.
+type FilterRouter struct {
+	next   Publisher
+	routes map[string]Publisher
+	rules  []FilterRule
+}
+
+// NewFilterRouter applies rules in order, with routes holding the
+// publisher for each route rule's topic.
+func NewFilterRouter(next Publisher, rules []FilterRule, routes map[string]Publisher) *FilterRouter {
+	return &FilterRouter{next: next, rules: rules, routes: routes}
+}
+
+// Publish implements the Publisher interface.
+func (r *FilterRouter) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	return r.PublishBatch(ctx, []PendingEvent{newPendingEvent(ctx, webhook, correlationID)})[0]
+}
+
+// PublishBatch implements the BatchPublisher interface.
+func (r *FilterRouter) PublishBatch(ctx context.Context, events []PendingEvent) []error {
+	return routeBatch(ctx, events, func(event PendingEvent) Publisher {
+		for i, rule := range r.rules {
+			if !rule.Matches(event.Webhook) {
+				continue
+			}
+			if rule.Action == FilterDrop {
+				Logger.Info("Dropping event by filter rule",
+					"correlation_id", event.CorrelationID,
+					"rule", i,
+					"aspect_type", event.Webhook.AspectType,
+					"object_type", event.Webhook.ObjectType,
+					"object_id", event.Webhook.ObjectID)
+				return discardPublisher{}
+			}
+			return r.routes[rule.Topic]
+		}
+		return r.next
+	})
+}
+
+// Close implements the Publisher interface, closing each distinct publisher
+// once.
+func (r *FilterRouter) Close(ctx context.Context) error {
+	publishers := []Publisher{r.next}
+	for _, publisher := range r.routes {
+		publishers = append(publishers, publisher)
+	}
+	return closeDistinct(ctx, publishers)
+}
+
+// discardPublisher accepts events without publishing them.
+type discardPublisher struct{}
+
+func (discardPublisher) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	return nil
+}
+
+func (discardPublisher) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,79 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestParseFilterRules(t *testing.T) {
+	rules, err := ParseFilterRules([]byte(`[{"aspect_type": "update", "action": "drop"}, {"sport_type": "Walk", "action": "route", "topic": "walks"}]`))
+	if err != nil {
+		t.Fatalf("ParseFilterRules failed: %v", err)
+	}
+	if len(rules) != 2 || rules[0].Action != FilterDrop || rules[1].Topic != "walks" {
+		t.Errorf("unexpected rules %+v", rules)
+	}
+
+	for _, data := range []string{
+		`{"action": "drop"}`,
+		`[{"action": "ignore"}]`,
+		`[{"aspect_type": "delete", "action": "route"}]`,
+	} {
+		if _, err := ParseFilterRules([]byte(data)); err == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+}
+
+func TestSportType(t *testing.T) {
+	sport := "Ride"
+	updated := WebhookRequest{UpdateFields: &UpdateFields{Type: &sport}}
+	if got := SportType(updated); got != "Ride" {
+		t.Errorf("expected sport type from updates, got %q", got)
+	}
+
+	enriched := WebhookRequest{Activity: json.RawMessage(`{"sport_type": "GravelRide", "type": "Ride"}`)}
+	if got := SportType(enriched); got != "GravelRide" {
+		t.Errorf("expected sport_type from enriched activity, got %q", got)
+	}
+
+	if got := SportType(WebhookRequest{}); got != "" {
+		t.Errorf("expected no sport type, got %q", got)
+	}
+}
+
+func TestFilterRouter(t *testing.T) {
+	next := &MockPublisher{}
+	deletes := &MockPublisher{}
+	rules := []FilterRule{
+		{AspectType: AspectUpdate, Action: FilterDrop},
+		{AspectType: AspectDelete, ObjectType: ObjectActivity, Action: FilterRoute, Topic: "deletes"},
+	}
+	router := NewFilterRouter(next, rules, map[string]Publisher{"deletes": deletes})
+
+	events := []PendingEvent{
+		{Webhook: WebhookRequest{ObjectID: 1, AspectType: AspectCreate, ObjectType: ObjectActivity}},
+		{Webhook: WebhookRequest{ObjectID: 2, AspectType: AspectUpdate, ObjectType: ObjectActivity}},
+		{Webhook: WebhookRequest{ObjectID: 3, AspectType: AspectDelete, ObjectType: ObjectActivity}},
+	}
+	for i, err := range router.PublishBatch(context.Background(), events) {
+		if err != nil {
+			t.Errorf("event %d: unexpected error %v", i, err)
+		}
+	}
+
+	if len(next.Published) != 1 || next.Published[0].ObjectID != 1 {
+		t.Errorf("expected only the create on the default publisher, got %+v", next.Published)
+	}
+	if len(deletes.Published) != 1 || deletes.Published[0].ObjectID != 3 {
+		t.Errorf("expected the delete on the routed publisher, got %+v", deletes.Published)
+	}
+
+	if err := router.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !next.Closed || !deletes.Closed {
+		t.Error("expected both publishers to be closed")
+	}
+}
@@ -4,40 +4,309 @@
 package dispatcher
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/andy-esch/desirelines/packages/alert"
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/logging"
+	"github.com/andy-esch/desirelines/packages/strava"
+	"github.com/andy-esch/desirelines/packages/tokenstore"
 	"github.com/google/uuid"
 )
 
+// MaxWebhookBodyBytes caps the webhook POST body. Strava events are a few
+// hundred bytes; anything near this size is junk traffic.
+const MaxWebhookBodyBytes = 64 << 10
+
 // Handler orchestrates the webhook processing.
 type Handler struct {
-	secretCache *SecretCache
-	config      *Config
-	publisher   Publisher
+	secrets   SecretProvider
+	config    *Config
+	publisher Publisher
+	// breaker is the publisher's circuit breaker, if enabled, kept for
+	// reporting its state.
+	breaker *CircuitBreakerPublisher
+	// bodyCapture, if set, keeps bodies that fail to decode.
+	bodyCapture BodyCaptureStore
+	// audit, if set, records every webhook event received.
+	audit *AuditLog
+	// athletes labels the per-athlete metrics.
+	athletes *athleteLabeler
+	// topics, if set, checks the publisher's topics in health checks.
+	topics *topicHealth
+	// startedAt is when the handler was created, for reporting uptime.
+	startedAt time.Time
+	// shutdownTracing flushes exported spans, if tracing is enabled.
+	shutdownTracing func(context.Context) error
+	// tokens, if set, shares the enrichment's Strava tokens.
+	tokens tokenstore.Store
+	// registry, if set, lists the athletes whose events are published.
+	registry *athleteRegistry
+	// stravaOptions configure the onboarding's Strava client, e.g. with a
+	// test server's token URL.
+	stravaOptions []strava.Option
 }
 
 // NewHandler creates a new webhook handler.
 func NewHandler(ctx context.Context) (*Handler, error) {
+	if err := config.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", config.FileEnv, err)
+	}
 	cfg, err := LoadConfig()
+	if err == nil {
+		err = cfg.Validate()
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to load config: %w", err)
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	if level, err := logging.ParseLevel(cfg.LogLevel); err == nil {
+		SetLogLevel(level)
 	}
+	build := CurrentBuild()
+	Logger.Info("Starting dispatcher", "version", build.Version, "commit", build.Commit, "build_time", build.BuildTime)
 
-	publisher, err := NewPubSubPublisher(ctx, cfg.GCPProjectID, cfg.GCPPubSubTopicID)
+	var shutdownTracing func(context.Context) error
+	if cfg.TracingEnabled {
+		shutdownTracing, err = SetupTracing(ctx, cfg.GCPProjectID, cfg.TraceSampleRatio)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up tracing: %w", err)
+		}
+	}
+
+	secrets, err := NewSecretProvider(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret provider: %w", err)
+	}
+
+	defaultTopicID := cfg.GCPPubSubTopicID
+	var topicCheckers []TopicChecker
+	newTopicPublisher := func(topicID string) (Publisher, error) {
+		topicPublisher, err := NewPubSubPublisher(ctx, cfg.GCPProjectID, topicID, cfg.Batching)
+		if err != nil {
+			return nil, err
+		}
+		topicCheckers = append(topicCheckers, topicPublisher)
+		topicPublisher.maxEventAge = cfg.MaxEventAge
+		topicPublisher.format = cfg.MessageFormat
+		return topicPublisher, nil
+	}
+	switch cfg.PublisherBackend {
+	case PublisherBackendKafka:
+		defaultTopicID = cfg.KafkaTopicID
+		newTopicPublisher = func(topicID string) (Publisher, error) {
+			topicPublisher, err := NewKafkaPublisher(cfg.Kafka, topicID, cfg.Batching)
+			if err != nil {
+				return nil, err
+			}
+			topicPublisher.maxEventAge = cfg.MaxEventAge
+			topicPublisher.format = cfg.MessageFormat
+			return topicPublisher, nil
+		}
+	case PublisherBackendLocal:
+		newTopicPublisher = func(topicID string) (Publisher, error) {
+			topicPublisher, err := NewLocalPublisher(cfg.LocalPublishFile, topicID)
+			if err != nil {
+				return nil, err
+			}
+			topicPublisher.maxEventAge = cfg.MaxEventAge
+			topicPublisher.format = cfg.MessageFormat
+			return topicPublisher, nil
+		}
+	}
+
+	publisher, err := newTopicPublisher(defaultTopicID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create publisher: %w", err)
 	}
 
-	// Create secret cache with default settings
-	secretCache := NewDefaultSecretCache()
+	if len(cfg.AspectTopicIDs) > 0 {
+		topicPublishers := map[string]Publisher{defaultTopicID: publisher}
+		routes := make(map[string]Publisher, len(cfg.AspectTopicIDs))
+		for aspect, topicID := range cfg.AspectTopicIDs {
+			if _, ok := topicPublishers[topicID]; !ok {
+				aspectPublisher, err := newTopicPublisher(topicID)
+				if err != nil {
+					return nil, fmt.Errorf("failed to create %s publisher: %w", aspect, err)
+				}
+				topicPublishers[topicID] = aspectPublisher
+			}
+			routes[aspect] = topicPublishers[topicID]
+		}
+		publisher = NewAspectRouter(publisher, routes)
+	}
+	if cfg.MaxEventAge > 0 && cfg.GCPPubSubHistoricalTopicID != "" {
+		historicalPublisher, err := newTopicPublisher(cfg.GCPPubSubHistoricalTopicID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create historical publisher: %w", err)
+		}
+		publisher = NewHistoricalRouter(publisher, historicalPublisher, cfg.MaxEventAge)
+	}
+	if cfg.AthleteTopicID != "" {
+		athletePublisher, err := newTopicPublisher(cfg.AthleteTopicID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create athlete publisher: %w", err)
+		}
+		publisher = NewAthleteRouter(publisher, athletePublisher)
+	}
+	if cfg.DeauthorizationTopicID != "" {
+		deauthorizationPublisher, err := newTopicPublisher(cfg.DeauthorizationTopicID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create deauthorization publisher: %w", err)
+		}
+		publisher = NewDeauthorizationRouter(publisher, deauthorizationPublisher)
+	}
+	if len(cfg.FilterRules) > 0 {
+		routes := map[string]Publisher{}
+		for _, rule := range cfg.FilterRules {
+			if rule.Action != FilterRoute || routes[rule.Topic] != nil {
+				continue
+			}
+			routePublisher, err := newTopicPublisher(rule.Topic)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create filter route publisher: %w", err)
+			}
+			routes[rule.Topic] = routePublisher
+		}
+		publisher = NewFilterRouter(publisher, cfg.FilterRules, routes)
+	}
+	if cfg.Retry.MaxAttempts > 1 {
+		publisher = NewRetryingPublisher(publisher, cfg.Retry)
+	}
+	var breaker *CircuitBreakerPublisher
+	if cfg.BreakerFailureThreshold > 0 {
+		breaker = NewCircuitBreakerPublisher(publisher, nil, cfg.BreakerFailureThreshold, cfg.BreakerOpenTimeout)
+		publisher = breaker
+	}
+	if cfg.DeadLetterBucket != "" {
+		store, err := NewGCSDeadLetterStore(ctx, cfg.DeadLetterBucket, cfg.DeadLetterPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dead-letter store: %w", err)
+		}
+		alerter := alert.Open(cfg.Alerts, "dispatcher")
+		if alerter != nil {
+			Logger.Info("Alerting on dead-lettered webhooks", "format", cfg.Alerts.Format)
+		}
+		publisher = NewDeadLetterPublisher(publisher, store, alerter)
+	}
+	var registry *athleteRegistry
+	if cfg.Athletes.Enabled() {
+		registry, err = openAthleteRegistry(ctx, cfg.Athletes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open athlete registry: %w", err)
+		}
+		Logger.Info("Publishing registered athletes' events", "athlete_registry", cfg.Athletes.Location)
+	}
+	// Enrichment and onboarding share the athletes' tokens
+	var tokens tokenstore.Store
+	if cfg.TokenStore.Enabled() && (cfg.EnrichActivities || cfg.OAuthRedirectURL != "") {
+		tokens, err = tokenstore.Open(ctx, cfg.TokenStore)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open token store: %w", err)
+		}
+		Logger.Info("Sharing Strava tokens", "token_store", cfg.TokenStore.Backend, "athlete_id", cfg.TokenStore.AthleteID)
+	}
+	if cfg.OAuthRedirectURL != "" {
+		Logger.Info("Athlete onboarding enabled", "redirect_url", cfg.OAuthRedirectURL)
+	}
+	if cfg.EnrichActivities {
+		credentials, err := secrets.Secrets()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Strava app credentials: %w", err)
+		}
+		// A token store or the athlete registry may already hold the
+		// refresh token
+		if credentials.ClientID == 0 || credentials.ClientSecret == "" || (credentials.RefreshToken == "" && !cfg.TokenStore.Enabled() && registry == nil) {
+			return nil, errors.New("ENRICH_ACTIVITIES requires client_id, client_secret and refresh_token in the Strava secrets")
+		}
+		if registry != nil {
+			// Each registered athlete is looked up with their own tokens
+			clients := athletes.NewClients(credentials.ClientID, credentials.ClientSecret, tokens)
+			publisher = NewAthleteEnrichingPublisher(publisher, athleteFetchers(registry.Registry, clients), cfg.EnrichTimeout)
+		} else {
+			var opts []strava.Option
+			if tokens != nil {
+				opts = append(opts, strava.WithTokenStore(tokens, cfg.TokenStore.AthleteID))
+			}
+			fetcher := strava.New(credentials.ClientID, credentials.ClientSecret, credentials.RefreshToken, opts...)
+			publisher = NewEnrichingPublisher(publisher, fetcher, cfg.EnrichTimeout)
+		}
+		Logger.Info("Activity enrichment enabled", "timeout", cfg.EnrichTimeout)
+	}
+	if cfg.OutboxCollection != "" {
+		store, err := NewFirestoreOutboxStore(ctx, cfg.GCPProjectID, cfg.OutboxCollection)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create outbox store: %w", err)
+		}
+		publisher = NewOutboxPublisher(publisher, store, cfg.OutboxSweepInterval, cfg.OutboxSweepAge)
+	}
+	if cfg.DedupeWindow > 0 {
+		stores := []DedupeStore{NewMemoryDedupeStore(cfg.DedupeCacheSize)}
+		if cfg.DedupeCollection != "" {
+			store, err := NewFirestoreDedupeStore(ctx, cfg.GCPProjectID, cfg.DedupeCollection)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create dedupe store: %w", err)
+			}
+			stores = append(stores, store)
+		}
+		publisher = NewDedupingPublisher(publisher, cfg.DedupeWindow, stores...)
+		Logger.Info("Webhook deduplication enabled", "window", cfg.DedupeWindow, "shared", cfg.DedupeCollection != "")
+	}
+	if cfg.AsyncPublish {
+		publisher = NewAsyncPublisher(publisher, cfg.AsyncQueueSize)
+		Logger.Info("Async publish mode enabled", "queue_size", cfg.AsyncQueueSize)
+	}
+
+	var bodyCapture BodyCaptureStore
+	if cfg.BodyCaptureBucket != "" {
+		bodyCapture, err = NewGCSBodyCaptureStore(ctx, cfg.BodyCaptureBucket, cfg.BodyCapturePrefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create body capture store: %w", err)
+		}
+	}
+
+	var topics *topicHealth
+	if cfg.HealthCheckTopics && len(topicCheckers) > 0 {
+		topics = newTopicHealth(topicCheckers)
+	}
+
+	var audit *AuditLog
+	switch {
+	case cfg.AuditBucket != "":
+		sink, err := NewGCSAuditSink(ctx, cfg.AuditBucket, cfg.AuditPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create audit sink: %w", err)
+		}
+		audit = NewAuditLog(sink, cfg.AuditFlushInterval)
+	case cfg.AuditTable != "":
+		sink, err := NewBigQueryAuditSink(ctx, cfg.AuditTable, cfg.GCPProjectID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create audit sink: %w", err)
+		}
+		audit = NewAuditLog(sink, cfg.AuditFlushInterval)
+	}
 
 	return &Handler{
-		secretCache: secretCache,
-		config:      cfg,
-		publisher:   publisher,
+		secrets:         secrets,
+		config:          cfg,
+		publisher:       publisher,
+		breaker:         breaker,
+		bodyCapture:     bodyCapture,
+		audit:           audit,
+		athletes:        newAthleteLabeler(cfg.AthleteMetricsLimit),
+		topics:          topics,
+		startedAt:       time.Now(),
+		shutdownTracing: shutdownTracing,
+		tokens:          tokens,
+		registry:        registry,
 	}, nil
 }
 
@@ -47,16 +316,132 @@ func NewHandlerWithPublisher(cfg *Config, publisher Publisher) *Handler {
 	secretCache := NewDefaultSecretCache()
 
 	return &Handler{
-		secretCache: secretCache,
-		config:      cfg,
-		publisher:   publisher,
+		secrets:   secretCache,
+		config:    cfg,
+		publisher: publisher,
+		athletes:  newAthleteLabeler(cfg.AthleteMetricsLimit),
+		startedAt: time.Now(),
+	}
+}
+
+// Close flushes pending messages and releases the publisher, then the
+// secret provider if it holds resources and the token store. ctx bounds
+// how long flushing may take.
+func (h *Handler) Close(ctx context.Context) error {
+	errs := []error{h.publisher.Close(ctx)}
+	if closer, ok := h.secrets.(io.Closer); ok {
+		errs = append(errs, closer.Close())
+	}
+	if h.bodyCapture != nil {
+		errs = append(errs, h.bodyCapture.Close())
+	}
+	if h.tokens != nil {
+		errs = append(errs, h.tokens.Close())
+	}
+	if h.registry != nil {
+		errs = append(errs, h.registry.Close())
 	}
+	// After the publisher, so records of the final flush aren't lost
+	if h.audit != nil {
+		errs = append(errs, h.audit.Close(ctx))
+	}
+	// Spans from the final flush are exported too
+	if h.shutdownTracing != nil {
+		errs = append(errs, h.shutdownTracing(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// clientIP returns the caller's address, believing X-Forwarded-For only
+// from the configured TRUSTED_PROXIES.
+func (h *Handler) clientIP(r *http.Request) string {
+	return h.config.TrustedProxies.ClientIP(r)
 }
 
 // ServeHTTP is the main entry point for handling HTTP requests.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	correlationID := uuid.New().String()
 	w.Header().Set("Content-Type", "application/json")
+	// Logs carry the request's trace so Cloud Logging groups them under it
+	r = r.WithContext(requestTraceContext(r))
+	ctx := r.Context()
+
+	if h.config.AccessLog || h.config.DebugPayloads {
+		recorder := &statusRecorder{ResponseWriter: w}
+		w = recorder
+		if h.config.AccessLog {
+			defer h.logAccess(r, recorder, correlationID, time.Now())
+		}
+		if h.config.DebugPayloads {
+			recorder.body = new(bytes.Buffer)
+			defer h.logPayloads(r, peekBody(r), recorder, correlationID)
+		}
+	}
+
+	switch r.URL.Path {
+	case "/live":
+		h.handleLive(w)
+		return
+	case "/ready":
+		h.handleReady(ctx, w, correlationID)
+		return
+	case "/version":
+		h.handleVersion(w)
+		return
+	case "/metrics":
+		MetricsHandler().ServeHTTP(w, r)
+		return
+	}
+
+	// Admin endpoints have their own token and are called by operators
+	// from outside Strava's address ranges
+	if strings.HasPrefix(r.URL.Path, "/admin/") {
+		h.handleAdmin(w, r, correlationID)
+		return
+	}
+
+	// Before authentication, so a flood is turned away as cheaply as
+	// possible; replays are limited too
+	if !h.checkRateLimit(w, r, correlationID) {
+		return
+	}
+
+	// Onboarding is visited by athletes' browsers, outside Strava's
+	// address ranges
+	if r.URL.Path == "/connect" || r.URL.Path == "/oauth/callback" {
+		switch {
+		case !h.onboardingEnabled():
+			writeError(w, http.StatusNotFound, "Not found", "", correlationID)
+		case r.URL.Path == "/connect":
+			h.handleConnect(w, r, correlationID)
+		default:
+			h.handleOAuthCallback(w, r, correlationID)
+		}
+		return
+	}
+
+	replay, err := h.authenticateReplay(r)
+	if err != nil {
+		eventsRejected.WithLabelValues(reasonInvalidReplayToken).Inc()
+		h.auditRejection(r, correlationID, http.StatusUnauthorized, reasonInvalidReplayToken)
+		h.logAndWriteError(ctx, w, correlationID, http.StatusUnauthorized, "Invalid replay token", nil, "Rejected request with invalid replay token")
+		return
+	}
+	if replay {
+		ctx = withEventSource(ctx, SourceReplay)
+		r = r.WithContext(ctx)
+	}
+
+	// Replay tools run outside Strava's address ranges
+	if h.config.IPFilter != nil && !replay {
+		if ip := h.clientIP(r); !h.config.IPFilter.Allowed(ip) {
+			Logger.WarnContext(ctx, "Rejected request from filtered IP", "correlation_id", correlationID, "client_ip", ip)
+			eventsRejected.WithLabelValues(reasonIPFiltered).Inc()
+			h.auditRejection(r, correlationID, http.StatusForbidden, reasonIPFiltered)
+			writeError(w, http.StatusForbidden, "Forbidden", "", correlationID)
+			return
+		}
+	}
 
 	switch r.Method {
 	case http.MethodGet:
@@ -64,16 +449,72 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		h.handleEvent(w, r, correlationID)
 	case http.MethodHead:
-		Logger.Info("Health check request", "correlation_id", correlationID)
+		Logger.InfoContext(ctx, "Health check request", "correlation_id", correlationID)
+		if err := h.topics.check(ctx); err != nil {
+			Logger.ErrorContext(ctx, "Health check failed: topic unavailable", "correlation_id", correlationID, "error", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 	default:
-		Logger.Warn("Invalid request method", "correlation_id", correlationID, "method", r.Method)
+		Logger.WarnContext(ctx, "Invalid request method", "correlation_id", correlationID, "method", r.Method)
+		eventsRejected.WithLabelValues(reasonMethodNotAllowed).Inc()
 		writeError(w, http.StatusMethodNotAllowed, "Method not allowed", "", correlationID)
 	}
 }
 
+// handleLive reports that the process is up and serving HTTP.
+func (h *Handler) handleLive(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "alive"}); err != nil {
+		Logger.Error("Failed to encode liveness response", "error", err)
+	}
+}
+
+// handleVersion reports which build is running.
+func (h *Handler) handleVersion(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(CurrentBuild()); err != nil {
+		Logger.Error("Failed to encode version response", "error", err)
+	}
+}
+
+// handleReady reports whether the handler can accept webhook traffic: the
+// publisher must be initialized and the webhook secrets must be loadable,
+// and with HealthCheckTopics the topics must accept publishes.
+func (h *Handler) handleReady(ctx context.Context, w http.ResponseWriter, correlationID string) {
+	if h.publisher == nil {
+		h.logAndWriteError(ctx, w, correlationID, http.StatusServiceUnavailable, "Not ready", nil, "Readiness check failed: publisher not initialized")
+		return
+	}
+
+	if _, _, err := h.secrets.GetSecrets(); err != nil {
+		h.logAndWriteError(ctx, w, correlationID, http.StatusServiceUnavailable, "Not ready", err, "Readiness check failed: secrets unavailable")
+		return
+	}
+
+	if err := h.topics.check(ctx); err != nil {
+		h.logAndWriteError(ctx, w, correlationID, http.StatusServiceUnavailable, "Not ready", err, "Readiness check failed: topic unavailable")
+		return
+	}
+
+	// An open circuit is reported but doesn't fail readiness: restarting
+	// the instance wouldn't fix a Pub/Sub outage
+	response := map[string]string{"status": "ready"}
+	if h.breaker != nil {
+		response["publisher_circuit"] = h.breaker.Stats().State.String()
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		Logger.ErrorContext(ctx, "Failed to encode readiness response", "correlation_id", correlationID, "error", err)
+	}
+}
+
 func (h *Handler) handleVerification(w http.ResponseWriter, r *http.Request, correlationID string) {
-	Logger.Info("Processing webhook verification request", "correlation_id", correlationID)
+	ctx := r.Context()
+	Logger.InfoContext(ctx, "Processing webhook verification request", "correlation_id", correlationID)
+	verificationAttempts.Inc()
 
 	mode := r.URL.Query().Get("hub.mode")
 	challenge := r.URL.Query().Get("hub.challenge")
@@ -81,72 +522,203 @@ func (h *Handler) handleVerification(w http.ResponseWriter, r *http.Request, cor
 
 	if mode != "subscribe" {
 		msg := fmt.Sprintf("invalid hub.mode: %s", mode)
-		Logger.Warn("Invalid hub.mode", "correlation_id", correlationID, "hub_mode", mode)
+		Logger.WarnContext(ctx, "Invalid hub.mode", "correlation_id", correlationID, "hub_mode", mode)
+		verificationFailures.WithLabelValues(reasonInvalidMode).Inc()
 		writeError(w, http.StatusBadRequest, msg, "", correlationID)
 		return
 	}
 
-	// Get current verify token from secret cache
-	verifyToken, _, err := h.secretCache.GetSecrets()
+	// Accepts the current token, or the previous one during a rotation
+	accepted, err := h.secrets.AcceptsVerifyToken(token)
 	if err != nil {
-		h.logAndWriteError(w, correlationID, http.StatusInternalServerError, "Configuration error", err, "Failed to get verify token")
+		verificationFailures.WithLabelValues(reasonConfigError).Inc()
+		h.logAndWriteError(ctx, w, correlationID, http.StatusInternalServerError, "Configuration error", err, "Failed to get verify token")
 		return
 	}
 
-	if token != verifyToken {
-		h.logAndWriteError(w, correlationID, http.StatusUnauthorized, "Invalid verify token", nil, "Invalid verify token")
+	if !accepted {
+		verificationFailures.WithLabelValues(reasonInvalidToken).Inc()
+		h.logAndWriteError(ctx, w, correlationID, http.StatusUnauthorized, "Invalid verify token", nil, "Invalid verify token")
 		return
 	}
 
-	Logger.Info("Webhook verification successful", "correlation_id", correlationID)
+	Logger.InfoContext(ctx, "Webhook verification successful", "correlation_id", correlationID)
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(map[string]string{"hub.challenge": challenge}); err != nil {
-		Logger.Error("Failed to encode response", "correlation_id", correlationID, "error", err)
+		Logger.ErrorContext(ctx, "Failed to encode response", "correlation_id", correlationID, "error", err)
 	}
 }
 
 func (h *Handler) handleEvent(w http.ResponseWriter, r *http.Request, correlationID string) {
-	Logger.Info("Processing webhook event", "correlation_id", correlationID)
+	ctx, span := startEventSpan(r, correlationID)
+	defer span.End()
+	Logger.InfoContext(ctx, "Processing webhook event", "correlation_id", correlationID, "source", eventSourceFromContext(ctx))
 
+	r.Body = http.MaxBytesReader(w, r.Body, MaxWebhookBodyBytes)
+
+	audit := h.newAuditRecord(r, correlationID)
+	recorder := &statusRecorder{ResponseWriter: w}
+	w = recorder
+	defer func() {
+		audit.StatusCode = recorder.status
+		h.audit.Record(*audit)
+	}()
+
+	// The raw body is kept so a payload that fails to decode can be captured
+	body, err := io.ReadAll(r.Body)
 	var webhook WebhookRequest
-	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
-		h.logAndWriteError(w, correlationID, http.StatusBadRequest, "Invalid JSON payload", err, "Invalid JSON payload")
+	if err == nil {
+		err = json.NewDecoder(bytes.NewReader(body)).Decode(&webhook)
+	}
+	if err != nil {
+		h.captureBody(ctx, body, correlationID, err)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			eventsRejected.WithLabelValues(reasonBodyTooLarge).Inc()
+			audit.reject(reasonBodyTooLarge)
+			h.logAndWriteError(ctx, w, correlationID, http.StatusRequestEntityTooLarge, "Request body too large", err, "Webhook body exceeds size limit")
+			return
+		}
+		eventsRejected.WithLabelValues(reasonInvalidJSON).Inc()
+		audit.reject(reasonInvalidJSON)
+		h.logAndWriteError(ctx, w, correlationID, http.StatusBadRequest, "Invalid JSON payload", err, "Invalid JSON payload")
 		return
 	}
 
+	Logger.DebugContext(ctx, "Webhook payload", "correlation_id", correlationID, "webhook", webhook)
+	audit.setWebhook(webhook)
+
+	// Only the enricher may set activity details
+	webhook.Activity = nil
+
 	if err := webhook.Validate(); err != nil {
-		h.logAndWriteError(w, correlationID, http.StatusBadRequest, "Webhook validation failed", err, "Webhook validation failed")
+		eventsRejected.WithLabelValues(reasonInvalidEvent).Inc()
+		audit.reject(reasonInvalidEvent)
+		h.logAndWriteError(ctx, w, correlationID, http.StatusBadRequest, "Webhook validation failed", err, "Webhook validation failed")
+		return
+	}
+
+	// Publish the updates normalized, replacing anything the client sent
+	updateFields, err := ParseUpdateFields(webhook.Updates)
+	if err != nil {
+		eventsRejected.WithLabelValues(reasonInvalidEvent).Inc()
+		audit.reject(reasonInvalidEvent)
+		h.logAndWriteError(ctx, w, correlationID, http.StatusBadRequest, "Webhook validation failed", err, "Webhook validation failed")
 		return
 	}
+	webhook.UpdateFields = nil
+	if !updateFields.IsEmpty() {
+		webhook.UpdateFields = &updateFields
+	}
+	eventsReceived.WithLabelValues(webhook.AspectType, webhook.ObjectType).Inc()
+	span.SetAttributes(webhookSpanAttributes(webhook)...)
 
-	// Get current subscription ID from secret cache
-	_, subscriptionID, err := h.secretCache.GetSecrets()
+	// Events may come from any configured subscription (e.g. dev and prod apps)
+	accepted, err := h.secrets.AcceptsSubscriptionID(webhook.SubscriptionID)
 	if err != nil {
-		h.logAndWriteError(w, correlationID, http.StatusInternalServerError, "Configuration error", err, "Failed to get subscription ID")
+		eventsRejected.WithLabelValues(reasonConfigError).Inc()
+		audit.reject(reasonConfigError)
+		h.logAndWriteError(ctx, w, correlationID, http.StatusInternalServerError, "Configuration error", err, "Failed to get subscription ID")
 		return
 	}
 
-	if webhook.SubscriptionID != subscriptionID {
+	if !accepted {
 		msg := fmt.Sprintf("invalid subscription_id: %d", webhook.SubscriptionID)
-		h.logAndWriteError(w, correlationID, http.StatusUnauthorized, msg, nil, msg)
+		eventsRejected.WithLabelValues(reasonSubscription).Inc()
+		audit.reject(reasonSubscription)
+		h.logAndWriteError(ctx, w, correlationID, http.StatusUnauthorized, msg, nil, msg)
+		return
+	}
+	// Counted once the subscription is known to be ours, so forged events
+	// can't use up the labels
+	h.athletes.countAthleteEvent(webhook, athleteStageReceived)
+
+	// Acknowledge events from unknown athletes so Strava doesn't retry them
+	if h.config.OwnerAllowlist != nil && !h.config.OwnerAllowlist.Allowed(webhook.OwnerID) {
+		Logger.WarnContext(ctx, "Ignoring event from athlete not in allowlist",
+			"correlation_id", correlationID,
+			"owner_id", webhook.OwnerID,
+			"object_type", webhook.ObjectType,
+			"object_id", webhook.ObjectID)
+		eventsIgnored.WithLabelValues(reasonOwnerNotAllowed).Inc()
+		audit.ignore(reasonOwnerNotAllowed)
+		writeSuccess(w, correlationID)
 		return
 	}
+	if h.registry != nil {
+		athlete, err := h.registry.Lookup(ctx, webhook.OwnerID)
+		if errors.Is(err, athletes.ErrUnknownAthlete) {
+			Logger.WarnContext(ctx, "Ignoring event from unregistered athlete",
+				"correlation_id", correlationID,
+				"owner_id", webhook.OwnerID,
+				"object_type", webhook.ObjectType,
+				"object_id", webhook.ObjectID)
+			eventsIgnored.WithLabelValues(reasonOwnerUnregistered).Inc()
+			audit.ignore(reasonOwnerUnregistered)
+			writeSuccess(w, correlationID)
+			return
+		}
+		if err != nil {
+			// Strava retries, by when the registry may be readable again
+			eventsRejected.WithLabelValues(reasonConfigError).Inc()
+			audit.reject(reasonConfigError)
+			h.logAndWriteError(ctx, w, correlationID, http.StatusInternalServerError, "Configuration error", err, "Failed to look up athlete")
+			return
+		}
+		ctx = withStoragePrefix(ctx, athlete.Prefix)
+	}
 
-	if webhook.ObjectType != ObjectActivity {
-		Logger.Info("Ignoring non-activity webhook", "correlation_id", correlationID, "object_type", webhook.ObjectType)
+	if IsDeauthorization(webhook) {
+		if !h.config.PublishDeauthorizations {
+			Logger.InfoContext(ctx, "Ignoring athlete deauthorization", "correlation_id", correlationID, "owner_id", webhook.OwnerID)
+			eventsIgnored.WithLabelValues(reasonDeauthorization).Inc()
+			audit.ignore(reasonDeauthorization)
+			writeSuccess(w, correlationID)
+			return
+		}
+		Logger.InfoContext(ctx, "Athlete deauthorized app", "correlation_id", correlationID, "owner_id", webhook.OwnerID)
+	} else if webhook.ObjectType == ObjectAthlete && h.config.AthleteTopicID != "" {
+		Logger.InfoContext(ctx, "Forwarding athlete event", "correlation_id", correlationID, "owner_id", webhook.OwnerID)
+	} else if webhook.ObjectType != ObjectActivity {
+		Logger.InfoContext(ctx, "Ignoring non-activity webhook", "correlation_id", correlationID, "object_type", webhook.ObjectType)
+		eventsIgnored.WithLabelValues(reasonNonActivity).Inc()
+		audit.ignore(reasonNonActivity)
 		writeSuccess(w, correlationID)
 		return
 	}
 
-	if err := h.publisher.Publish(r.Context(), webhook, correlationID); err != nil {
-		h.logAndWriteError(w, correlationID, http.StatusInternalServerError, "Failed to publish event", err, "Failed to publish webhook")
+	start := time.Now()
+	err = h.publisher.Publish(ctx, webhook, correlationID)
+	publishDuration.WithLabelValues(resultLabel(err)).Observe(time.Since(start).Seconds())
+	if err != nil {
+		recordSpanError(span, err)
+		statusCode, reason := http.StatusInternalServerError, reasonPublishFailed
+		if errors.Is(err, ErrCircuitOpen) {
+			statusCode, reason = http.StatusServiceUnavailable, reasonCircuitOpen
+		}
+		eventsRejected.WithLabelValues(reason).Inc()
+		audit.reject(reason)
+		h.logAndWriteError(ctx, w, correlationID, statusCode, "Failed to publish event", err, "Failed to publish webhook")
 		return
 	}
 
-	Logger.Info("Webhook processing successful", "correlation_id", correlationID)
+	eventsPublished.WithLabelValues(webhook.AspectType).Inc()
+	h.athletes.countAthleteEvent(webhook, athleteStagePublished)
+	Logger.InfoContext(ctx, "Webhook processing successful", "correlation_id", correlationID)
 	writeSuccess(w, correlationID)
 }
 
+// auditRejection records a webhook POST rejected before its body was read.
+func (h *Handler) auditRejection(r *http.Request, correlationID string, statusCode int, reason string) {
+	if r.Method != http.MethodPost {
+		return
+	}
+	audit := h.newAuditRecord(r, correlationID)
+	audit.reject(reason)
+	audit.StatusCode = statusCode
+	h.audit.Record(*audit)
+}
+
 func writeError(w http.ResponseWriter, code int, msg, details, correlationID string) {
 	w.WriteHeader(code)
 	response := map[string]string{
@@ -172,14 +744,14 @@ func writeSuccess(w http.ResponseWriter, correlationID string) {
 }
 
 // logAndWriteError logs an error and writes an HTTP error response in one call.
-func (h *Handler) logAndWriteError(w http.ResponseWriter, correlationID string,
+func (h *Handler) logAndWriteError(ctx context.Context, w http.ResponseWriter, correlationID string,
 	statusCode int, userMsg string, err error, logMsg string) {
 
 	if err != nil {
-		Logger.Error(logMsg, "correlation_id", correlationID, "error", err, "status_code", statusCode)
+		Logger.ErrorContext(ctx, logMsg, "correlation_id", correlationID, "error", err, "status_code", statusCode)
 		writeError(w, statusCode, userMsg, err.Error(), correlationID)
 	} else {
-		Logger.Warn(logMsg, "correlation_id", correlationID, "status_code", statusCode)
+		Logger.WarnContext(ctx, logMsg, "correlation_id", correlationID, "status_code", statusCode)
 		writeError(w, statusCode, userMsg, "", correlationID)
 	}
 }
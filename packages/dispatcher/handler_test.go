@@ -1,6 +1,7 @@
 package dispatcher
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +10,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/andy-esch/desirelines/packages/ipfilter"
 )
 
 func TestHandler_ServeHTTP_Verification(t *testing.T) {
@@ -35,7 +38,7 @@ func TestHandler_ServeHTTP_Verification(t *testing.T) {
 	handler := NewHandlerWithPublisher(cfg, mockPub)
 
 	// Override the secret cache path for testing
-	handler.secretCache = NewSecretCache(secretsPath, time.Minute)
+	handler.secrets = NewSecretCache(secretsPath, time.Minute)
 
 	// Valid request
 	req := httptest.NewRequest("GET", "/?hub.mode=subscribe&hub.challenge=test-challenge&hub.verify_token=test-token", nil)
@@ -83,7 +86,7 @@ func TestHandler_ServeHTTP_Event(t *testing.T) {
 	handler := NewHandlerWithPublisher(cfg, mockPub)
 
 	// Override the secret cache path for testing
-	handler.secretCache = NewSecretCache(secretsPath, time.Minute)
+	handler.secrets = NewSecretCache(secretsPath, time.Minute)
 
 	// Valid event
 	body := `{"aspect_type":"create","object_type":"activity","object_id":1,"owner_id":1,"event_time":1,"subscription_id":12345}`
@@ -101,6 +104,49 @@ func TestHandler_ServeHTTP_Event(t *testing.T) {
 		t.Errorf("published message has wrong ObjectID: got %d want %d", mockPub.Published[0].ObjectID, 1)
 	}
 
+	// Update event publishes typed update fields
+	mockPub.Published = nil // Reset mock
+	body = `{"aspect_type":"update","object_type":"activity","object_id":1,"owner_id":1,"event_time":1,"subscription_id":12345,"updates":{"title":"Lunch Ride","private":"true"}}`
+	req = httptest.NewRequest("POST", "/", strings.NewReader(body))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusCreated {
+		t.Errorf("handler returned wrong status code for update: got %v want %v", status, http.StatusCreated)
+	}
+	if len(mockPub.Published) != 1 || mockPub.Published[0].UpdateFields == nil {
+		t.Fatalf("expected update with typed fields to be published, got %+v", mockPub.Published)
+	}
+	if fields := mockPub.Published[0].UpdateFields; *fields.Title != "Lunch Ride" || !*fields.Private {
+		t.Errorf("unexpected update fields: %+v", fields)
+	}
+
+	// Malformed update value
+	mockPub.Published = nil // Reset mock
+	body = `{"aspect_type":"update","object_type":"activity","object_id":1,"owner_id":1,"event_time":1,"subscription_id":12345,"updates":{"private":"maybe"}}`
+	req = httptest.NewRequest("POST", "/", strings.NewReader(body))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("handler returned wrong status code for malformed updates: got %v want %v", status, http.StatusBadRequest)
+	}
+
+	// Owner not in allowlist (acknowledged, not published)
+	mockPub.Published = nil // Reset mock
+	cfg.OwnerAllowlist, _ = ParseOwnerAllowlist("2")
+	req = httptest.NewRequest("POST", "/", strings.NewReader(`{"aspect_type":"create","object_type":"activity","object_id":1,"owner_id":1,"event_time":1,"subscription_id":12345}`))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	cfg.OwnerAllowlist = nil
+
+	if status := rr.Code; status != http.StatusCreated {
+		t.Errorf("handler returned wrong status code for unknown owner: got %v want %v", status, http.StatusCreated)
+	}
+	if len(mockPub.Published) != 0 {
+		t.Errorf("expected 0 messages to be published for unknown owner, got %d", len(mockPub.Published))
+	}
+
 	// Invalid subscription ID
 	mockPub.Published = nil // Reset mock
 	body = `{"aspect_type":"create","object_type":"activity","object_id":1,"owner_id":1,"event_time":1,"subscription_id":99999}`
@@ -115,6 +161,30 @@ func TestHandler_ServeHTTP_Event(t *testing.T) {
 		t.Errorf("expected 0 messages to be published for bad sub id, got %d", len(mockPub.Published))
 	}
 
+	// Deauthorization (ignored unless enabled)
+	mockPub.Published = nil // Reset mock
+	deauthBody := `{"aspect_type":"update","object_type":"athlete","object_id":1,"owner_id":1,"event_time":1,"subscription_id":12345,"updates":{"authorized":"false"}}`
+	req = httptest.NewRequest("POST", "/", strings.NewReader(deauthBody))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusCreated {
+		t.Errorf("handler returned wrong status code for ignored deauthorization: got %v want %v", status, http.StatusCreated)
+	}
+	if len(mockPub.Published) != 0 {
+		t.Errorf("expected deauthorization to be ignored by default, got %d published", len(mockPub.Published))
+	}
+
+	cfg.PublishDeauthorizations = true
+	req = httptest.NewRequest("POST", "/", strings.NewReader(deauthBody))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	cfg.PublishDeauthorizations = false
+
+	if len(mockPub.Published) != 1 || !IsDeauthorization(mockPub.Published[0]) {
+		t.Errorf("expected deauthorization to be published when enabled, got %+v", mockPub.Published)
+	}
+
 	// Non-activity event (should be ignored)
 	mockPub.Published = nil // Reset mock
 	body = `{"aspect_type":"update","object_type":"athlete","object_id":1,"owner_id":1,"event_time":1,"subscription_id":12345}`
@@ -128,6 +198,89 @@ func TestHandler_ServeHTTP_Event(t *testing.T) {
 	if len(mockPub.Published) != 0 {
 		t.Errorf("expected 0 messages to be published for ignored event, got %d", len(mockPub.Published))
 	}
+
+	// Athlete events are forwarded when an athlete topic is configured
+	cfg.AthleteTopicID = "athlete-events"
+	req = httptest.NewRequest("POST", "/", strings.NewReader(body))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	cfg.AthleteTopicID = ""
+
+	if len(mockPub.Published) != 1 || mockPub.Published[0].ObjectType != ObjectAthlete {
+		t.Errorf("expected athlete event to be published with an athlete topic, got %+v", mockPub.Published)
+	}
+
+	// Oversized body
+	body = `{"aspect_type":"create","updates":{"title":"` + strings.Repeat("x", MaxWebhookBodyBytes) + `"}}`
+	req = httptest.NewRequest("POST", "/", strings.NewReader(body))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusRequestEntityTooLarge {
+		t.Errorf("handler returned wrong status code for oversized body: got %v want %v", status, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestHandler_ServeHTTP_LiveAndReady(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "handler_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			t.Logf("Failed to clean up temp dir: %v", err)
+		}
+	}()
+
+	secretsPath := filepath.Join(tempDir, "strava_auth.json")
+	secrets := map[string]any{
+		"webhook_verify_token":    "test-token",
+		"webhook_subscription_id": 12345,
+	}
+	writeTestSecretsFile(t, secretsPath, secrets)
+
+	handler := NewHandlerWithPublisher(&Config{}, &MockPublisher{})
+	handler.secrets = NewSecretCache(secretsPath, time.Minute)
+
+	// Liveness
+	req := httptest.NewRequest("GET", "/live", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("live returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	// Readiness with secrets available
+	req = httptest.NewRequest("GET", "/ready", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("ready returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	// Readiness with secrets missing
+	handler.secrets = NewSecretCache(filepath.Join(tempDir, "missing.json"), time.Minute)
+	req = httptest.NewRequest("GET", "/ready", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusServiceUnavailable {
+		t.Errorf("ready returned wrong status code without secrets: got %v want %v", status, http.StatusServiceUnavailable)
+	}
+}
+
+func TestHandler_Close(t *testing.T) {
+	mockPub := &MockPublisher{}
+	handler := NewHandlerWithPublisher(&Config{}, mockPub)
+
+	if err := handler.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !mockPub.Closed {
+		t.Error("expected Close to close the publisher")
+	}
 }
 
 // Helper function to write test secrets file
@@ -142,3 +295,56 @@ func writeTestSecretsFile(t *testing.T, path string, secrets map[string]any) {
 		t.Fatalf("Failed to write secrets file: %v", err)
 	}
 }
+
+func TestHandler_ServeHTTP_IPFilter(t *testing.T) {
+	filter, err := ipfilter.Parse("203.0.113.0/24", "")
+	if err != nil {
+		t.Fatalf("ipfilter.Parse failed: %v", err)
+	}
+	proxies, err := ipfilter.ParseTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies failed: %v", err)
+	}
+	handler := NewHandlerWithPublisher(&Config{IPFilter: filter, TrustedProxies: proxies}, &MockPublisher{})
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected status %d for filtered IP, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	// Probes are not filtered
+	req = httptest.NewRequest("GET", "/live", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d for /live, got %d", http.StatusOK, rr.Code)
+	}
+
+	// Right-most X-Forwarded-For entry is the client seen by the load balancer
+	req = httptest.NewRequest("HEAD", "/", nil)
+	req.RemoteAddr = "10.1.2.3:1234"
+	req.Header.Set("X-Forwarded-For", "192.0.2.1, 203.0.113.5")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d for allowed forwarded IP, got %d", http.StatusOK, rr.Code)
+	}
+
+	// A client connecting directly can't claim an allowed address
+	req = httptest.NewRequest("HEAD", "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.5")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected status %d for spoofed X-Forwarded-For, got %d", http.StatusForbidden, rr.Code)
+	}
+}
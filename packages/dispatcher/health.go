@@ -0,0 +1,56 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// topicCheckCacheTTL is how long a topic check result is reused, so
+	// frequent probes don't each call the Pub/Sub API.
+	topicCheckCacheTTL = time.Minute
+
+	// topicCheckTimeout bounds the checks of all topics.
+	topicCheckTimeout = 5 * time.Second
+)
+
+// topicHealth checks that the publisher's topics exist and accept publishes
+// from this service, caching the result for topicCheckCacheTTL. A nil
+// topicHealth is always healthy.
+type topicHealth struct {
+	checkedAt time.Time
+	err       error
+	now       func() time.Time
+	checkers  []TopicChecker
+	mu        sync.Mutex
+}
+
+func newTopicHealth(checkers []TopicChecker) *topicHealth {
+	return &topicHealth{checkers: checkers, now: time.Now}
+}
+
+// check returns the joined errors of every failing topic.
+func (t *topicHealth) check(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if !t.checkedAt.IsZero() && now.Sub(t.checkedAt) < topicCheckCacheTTL {
+		return t.err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, topicCheckTimeout)
+	defer cancel()
+	var errs []error
+	for _, checker := range t.checkers {
+		errs = append(errs, checker.CheckTopic(ctx))
+	}
+	t.err = errors.Join(errs...)
+	t.checkedAt = now
+	return t.err
+}
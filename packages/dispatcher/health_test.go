@@ -0,0 +1,94 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/v2/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// fakeTopicChecker returns err and counts its calls.
+type fakeTopicChecker struct {
+	err   error
+	calls int
+}
+
+func (f *fakeTopicChecker) CheckTopic(ctx context.Context) error {
+	f.calls++
+	return f.err
+}
+
+func TestHandler_HealthCheckTopics(t *testing.T) {
+	secretsPath := filepath.Join(t.TempDir(), "strava_auth.json")
+	writeTestSecretsFile(t, secretsPath, map[string]any{
+		"webhook_verify_token":    "test-token",
+		"webhook_subscription_id": 12345,
+	})
+	checker := &fakeTopicChecker{err: errors.New("topic not found")}
+	handler := NewHandlerWithPublisher(&Config{}, &MockPublisher{})
+	handler.secrets = NewSecretCache(secretsPath, time.Minute)
+	handler.topics = newTopicHealth([]TopicChecker{checker})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodHead, "/", nil),
+		httptest.NewRequest(http.MethodGet, "/ready", nil),
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s %s: got status %d, want %d", req.Method, req.URL.Path, rr.Code, http.StatusServiceUnavailable)
+		}
+	}
+	if checker.calls != 1 {
+		t.Errorf("expected the cached result to be reused, got %d checks", checker.calls)
+	}
+
+	// Once the cached failure expires, a fixed topic is reported healthy
+	checker.err = nil
+	handler.topics.now = func() time.Time { return time.Now().Add(topicCheckCacheTTL) }
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodHead, "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("got status %d after the topic recovered, want %d", rr.Code, http.StatusOK)
+	}
+}
+
+func TestPubSubPublisher_CheckTopic(t *testing.T) {
+	ctx := context.Background()
+	srv := pstest.NewServer()
+	t.Cleanup(func() { _ = srv.Close() })
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial fake server: %v", err)
+	}
+	client, err := pubsub.NewClient(ctx, "test-project", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	newPublisher := func(topicName string) *PubSubPublisher {
+		return &PubSubPublisher{client: client, publisher: client.Publisher(topicName)}
+	}
+	if err := newPublisher("projects/test-project/topics/missing").CheckTopic(ctx); err == nil {
+		t.Error("expected an error for a missing topic")
+	}
+
+	topicName := "projects/test-project/topics/activity-events"
+	if _, err := client.TopicAdminClient.CreateTopic(ctx, &pubsubpb.Topic{Name: topicName}); err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+	if err := newPublisher(topicName).CheckTopic(ctx); err != nil {
+		t.Errorf("CheckTopic failed for an existing topic: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// HistoricalAttribute is the Pub/Sub attribute set to "true" on events
+// older than the configured maximum event age.
+const HistoricalAttribute = "historical"
+
+// IsHistorical reports whether webhook's event_time is more than maxAge
+// before now. A zero maxAge treats every event as current.
+func IsHistorical(webhook WebhookRequest, now time.Time, maxAge time.Duration) bool {
+	if maxAge <= 0 {
+		return false
+	}
+	return now.Sub(time.Unix(webhook.EventTime, 0)) > maxAge
+}
+
+// HistoricalRouter sends events older than maxAge to a separate publisher,
+// typically one for a historical topic, so a large replay of old events
+// doesn't crowd out real-time processing.
+type HistoricalRouter struct {
+	live       Publisher
+	historical Publisher
+	now        func() time.Time
+	maxAge     time.Duration
+}
+
+// NewHistoricalRouter routes events older than maxAge to historical and
+// everything else to live.
+func NewHistoricalRouter(live, historical Publisher, maxAge time.Duration) *HistoricalRouter {
+	return &HistoricalRouter{live: live, historical: historical, now: time.Now, maxAge: maxAge}
+}
+
+// Publish implements the Publisher interface.
+func (r *HistoricalRouter) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	return r.PublishBatch(ctx, []PendingEvent{newPendingEvent(ctx, webhook, correlationID)})[0]
+}
+
+// PublishBatch implements the BatchPublisher interface, splitting the batch
+// between the two publishers.
+func (r *HistoricalRouter) PublishBatch(ctx context.Context, events []PendingEvent) []error {
+	now := r.now()
+	historical := 0
+	errs := routeBatch(ctx, events, func(event PendingEvent) Publisher {
+		if IsHistorical(event.Webhook, now, r.maxAge) {
+			historical++
+			return r.historical
+		}
+		return r.live
+	})
+	if historical > 0 {
+		Logger.Info("Routed historical events", "count", historical, "max_event_age", r.maxAge)
+	}
+	return errs
+}
+
+// Close implements the Publisher interface.
+func (r *HistoricalRouter) Close(ctx context.Context) error {
+	return errors.Join(r.live.Close(ctx), r.historical.Close(ctx))
+}
@@ -0,0 +1,56 @@
+package dispatcher
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIsHistorical(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	recent := WebhookRequest{EventTime: now.Add(-time.Hour).Unix()}
+	old := WebhookRequest{EventTime: now.Add(-48 * time.Hour).Unix()}
+
+	if IsHistorical(recent, now, 24*time.Hour) {
+		t.Error("expected recent event not to be historical")
+	}
+	if !IsHistorical(old, now, 24*time.Hour) {
+		t.Error("expected old event to be historical")
+	}
+	if IsHistorical(old, now, 0) {
+		t.Error("expected zero max age to disable the check")
+	}
+}
+
+func TestHistoricalRouter(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	live := &MockPublisher{}
+	historical := &MockPublisher{}
+	router := NewHistoricalRouter(live, historical, 24*time.Hour)
+	router.now = func() time.Time { return now }
+
+	events := []PendingEvent{
+		{Webhook: WebhookRequest{ObjectID: 1, EventTime: now.Add(-time.Minute).Unix()}},
+		{Webhook: WebhookRequest{ObjectID: 2, EventTime: now.AddDate(0, -6, 0).Unix()}},
+		{Webhook: WebhookRequest{ObjectID: 3, EventTime: now.Unix()}},
+	}
+	for i, err := range router.PublishBatch(context.Background(), events) {
+		if err != nil {
+			t.Errorf("event %d: unexpected error %v", i, err)
+		}
+	}
+
+	if len(live.Published) != 2 || live.Published[0].ObjectID != 1 || live.Published[1].ObjectID != 3 {
+		t.Errorf("expected current events on the live publisher, got %+v", live.Published)
+	}
+	if len(historical.Published) != 1 || historical.Published[0].ObjectID != 2 {
+		t.Errorf("expected old event on the historical publisher, got %+v", historical.Published)
+	}
+
+	if err := router.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !live.Closed || !historical.Closed {
+		t.Error("expected both publishers to be closed")
+	}
+}
@@ -0,0 +1,185 @@
+package dispatcher
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultKafkaBatchTimeout matches the Pub/Sub client's default delay; the
+// Kafka writer's own 1s default would hold every webhook response.
+const defaultKafkaBatchTimeout = 10 * time.Millisecond
+
+// KafkaSettings configures the Kafka publisher. SASLMechanism is one of
+// "plain", "scram-sha-256" or "scram-sha-512"; empty disables SASL.
+type KafkaSettings struct {
+	SASLMechanism string
+	SASLUsername  string
+	SASLPassword  string
+	Brokers       []string
+	TLS           bool
+}
+
+// kafkaWriter is the subset of *kafka.Writer used by KafkaPublisher.
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// KafkaPublisher is a Kafka adapter that implements the Publisher
+// interface. Messages carry the same data and attributes (as headers) as on
+// Pub/Sub and are keyed by athlete, so one owner's events stay in order
+// within a partition.
+type KafkaPublisher struct {
+	writer kafkaWriter
+	topic  string
+	// format selects raw or CloudEvents message data.
+	format MessageFormat
+	// maxEventAge, when set, flags older events with HistoricalAttribute.
+	maxEventAge time.Duration
+}
+
+// NewKafkaPublisher creates a publisher writing to topic. The batch
+// settings map onto the writer's batch size, bytes and timeout.
+func NewKafkaPublisher(settings KafkaSettings, topic string, batching BatchSettings) (*KafkaPublisher, error) {
+	if len(settings.Brokers) == 0 {
+		return nil, fmt.Errorf("no Kafka brokers configured")
+	}
+	mechanism, err := kafkaSASLMechanism(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &kafka.Transport{SASL: mechanism}
+	if settings.TLS {
+		transport.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(settings.Brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: defaultKafkaBatchTimeout,
+		Transport:    transport,
+	}
+	if batching.DelayThreshold > 0 {
+		writer.BatchTimeout = batching.DelayThreshold
+	}
+	if batching.CountThreshold > 0 {
+		writer.BatchSize = batching.CountThreshold
+	}
+	if batching.ByteThreshold > 0 {
+		writer.BatchBytes = int64(batching.ByteThreshold)
+	}
+	Logger.Info("Kafka publisher initialized",
+		"brokers", strings.Join(settings.Brokers, ","),
+		"topic", topic,
+		"sasl_mechanism", settings.SASLMechanism,
+		"tls", settings.TLS)
+
+	return &KafkaPublisher{writer: writer, topic: topic}, nil
+}
+
+// kafkaSASLMechanism builds the SASL mechanism named in settings.
+func kafkaSASLMechanism(settings KafkaSettings) (sasl.Mechanism, error) {
+	switch settings.SASLMechanism {
+	case "":
+		return nil, nil
+	case "plain":
+		return plain.Mechanism{Username: settings.SASLUsername, Password: settings.SASLPassword}, nil
+	case "scram-sha-256":
+		return scram.Mechanism(scram.SHA256, settings.SASLUsername, settings.SASLPassword)
+	case "scram-sha-512":
+		return scram.Mechanism(scram.SHA512, settings.SASLUsername, settings.SASLPassword)
+	default:
+		return nil, fmt.Errorf("unsupported Kafka SASL mechanism %q", settings.SASLMechanism)
+	}
+}
+
+// Publish implements the Publisher interface.
+func (p *KafkaPublisher) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	return p.PublishBatch(ctx, []PendingEvent{newPendingEvent(ctx, webhook, correlationID)})[0]
+}
+
+// PublishBatch implements the BatchPublisher interface, writing all
+// messages in one call.
+func (p *KafkaPublisher) PublishBatch(ctx context.Context, events []PendingEvent) []error {
+	now := time.Now()
+	errs := make([]error, len(events))
+	var messages []kafka.Message
+	var indexes []int
+	var spans []trace.Span
+	for i, event := range events {
+		data, err := encodeMessage(p.format, event.Webhook, event.CorrelationID)
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to marshal webhook data: %v", err)
+			continue
+		}
+		attributes := eventAttributes(event, p.format, now, p.maxEventAge)
+		spans = append(spans, startPublishSpan(ctx, event, "kafka", p.topic, attributes))
+		headers := make([]kafka.Header, 0, len(attributes))
+		for key, value := range attributes {
+			headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+		}
+		messages = append(messages, kafka.Message{
+			Key:     []byte(OrderingKey(event.Webhook)),
+			Value:   data,
+			Headers: headers,
+		})
+		indexes = append(indexes, i)
+	}
+	if len(messages) == 0 {
+		return errs
+	}
+
+	err := p.writer.WriteMessages(ctx, messages...)
+	var writeErrs kafka.WriteErrors
+	for j, i := range indexes {
+		event := events[i]
+		messageErr := err
+		if errors.As(err, &writeErrs) {
+			messageErr = writeErrs[j]
+		}
+		endSpan(spans[j], messageErr)
+		if messageErr != nil {
+			errs[i] = fmt.Errorf("failed to publish to Kafka: %w", messageErr)
+			continue
+		}
+
+		Logger.InfoContext(eventContext(ctx, event), "Successfully published webhook to Kafka",
+			"correlation_id", event.CorrelationID,
+			"topic", p.topic,
+			"object_id", event.Webhook.ObjectID,
+			"aspect_type", event.Webhook.AspectType,
+			"owner_id", event.Webhook.OwnerID,
+			"subscription_id", event.Webhook.SubscriptionID)
+	}
+	return errs
+}
+
+// Close flushes pending messages and closes the writer.
+func (p *KafkaPublisher) Close(ctx context.Context) error {
+	closed := make(chan error, 1)
+	go func() {
+		closed <- p.writer.Close()
+	}()
+
+	select {
+	case err := <-closed:
+		if err != nil {
+			return fmt.Errorf("failed to close Kafka writer: %v", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("timed out flushing Kafka messages: %w", ctx.Err())
+	}
+}
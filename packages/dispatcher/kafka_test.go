@@ -0,0 +1,80 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeKafkaWriter records written messages and fails them with err.
+type fakeKafkaWriter struct {
+	err      error
+	messages []kafka.Message
+	closed   bool
+}
+
+func (w *fakeKafkaWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.messages = append(w.messages, msgs...)
+	return w.err
+}
+
+func (w *fakeKafkaWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestKafkaPublisher_PublishBatch(t *testing.T) {
+	writer := &fakeKafkaWriter{}
+	publisher := &KafkaPublisher{writer: writer, topic: "activity_events"}
+
+	events := []PendingEvent{
+		{CorrelationID: "corr-1", Webhook: WebhookRequest{ObjectID: 1, OwnerID: 7, AspectType: AspectCreate}},
+		{CorrelationID: "corr-2", Webhook: WebhookRequest{ObjectID: 2, OwnerID: 8, AspectType: AspectDelete}},
+	}
+	for i, err := range publisher.PublishBatch(context.Background(), events) {
+		if err != nil {
+			t.Errorf("event %d: unexpected error %v", i, err)
+		}
+	}
+
+	if len(writer.messages) != 2 {
+		t.Fatalf("expected one write of 2 messages, got %d", len(writer.messages))
+	}
+	message := writer.messages[1]
+	if string(message.Key) != "8" {
+		t.Errorf("expected message keyed by owner, got %q", message.Key)
+	}
+	headers := map[string]string{}
+	for _, header := range message.Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	if headers["correlation_id"] != "corr-2" || headers["aspect_type"] != AspectDelete {
+		t.Errorf("expected message attributes as headers, got %v", headers)
+	}
+}
+
+func TestKafkaPublisher_PartialFailure(t *testing.T) {
+	writer := &fakeKafkaWriter{err: kafka.WriteErrors{nil, errors.New("leader not available")}}
+	publisher := &KafkaPublisher{writer: writer}
+
+	errs := publisher.PublishBatch(context.Background(), []PendingEvent{
+		{Webhook: WebhookRequest{ObjectID: 1}},
+		{Webhook: WebhookRequest{ObjectID: 2}},
+	})
+	if errs[0] != nil || errs[1] == nil {
+		t.Errorf("expected only the second event to fail, got %v", errs)
+	}
+}
+
+func TestKafkaSASLMechanism(t *testing.T) {
+	for _, name := range []string{"", "plain", "scram-sha-256", "scram-sha-512"} {
+		if _, err := kafkaSASLMechanism(KafkaSettings{SASLMechanism: name, SASLUsername: "user", SASLPassword: "pass"}); err != nil {
+			t.Errorf("kafkaSASLMechanism(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := kafkaSASLMechanism(KafkaSettings{SASLMechanism: "gssapi"}); err == nil {
+		t.Error("expected error for unsupported mechanism")
+	}
+}
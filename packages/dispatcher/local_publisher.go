@@ -0,0 +1,97 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// LocalRecord is one line written by LocalPublisher: the message as it
+// would have been published, with its topic and attributes.
+type LocalRecord struct {
+	Attributes map[string]string `json:"attributes"`
+	Topic      string            `json:"topic,omitempty"`
+	Data       json.RawMessage   `json:"data"`
+}
+
+// LocalPublisher appends published events as NDJSON to a file or stdout,
+// so the dispatcher can run end-to-end without GCP credentials or an
+// emulator.
+type LocalPublisher struct {
+	out    io.Writer
+	closer io.Closer
+	topic  string
+	// format selects raw or CloudEvents message data.
+	format MessageFormat
+	// maxEventAge, when set, flags older events with HistoricalAttribute.
+	maxEventAge time.Duration
+	mu          sync.Mutex
+}
+
+// NewLocalPublisher creates a publisher appending to path, or writing to
+// stdout when path is empty or "-". Logs go to stderr, so stdout carries
+// only events.
+func NewLocalPublisher(path, topic string) (*LocalPublisher, error) {
+	if path == "" || path == "-" {
+		Logger.Info("Local publisher initialized", "output", "stdout", "topic", topic)
+		return &LocalPublisher{out: os.Stdout, topic: topic}, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open local publish file: %w", err)
+	}
+	Logger.Info("Local publisher initialized", "output", path, "topic", topic)
+	return &LocalPublisher{out: file, closer: file, topic: topic}, nil
+}
+
+// Publish implements the Publisher interface.
+func (p *LocalPublisher) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) (err error) {
+	data, err := encodeMessage(p.format, webhook, correlationID)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook data: %v", err)
+	}
+	event := newPendingEvent(ctx, webhook, correlationID)
+	attributes := eventAttributes(event, p.format, time.Now(), p.maxEventAge)
+	span := startPublishSpan(ctx, event, "local", p.topic, attributes)
+	defer func() { endSpan(span, err) }()
+
+	line, err := json.Marshal(LocalRecord{
+		Topic:      p.topic,
+		Attributes: attributes,
+		Data:       data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal local record: %v", err)
+	}
+
+	// One write per line keeps records whole when several publishers
+	// append to the same file.
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.out.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write local record: %w", err)
+	}
+
+	Logger.InfoContext(ctx, "Successfully published webhook locally",
+		"correlation_id", correlationID,
+		"topic", p.topic,
+		"object_id", webhook.ObjectID,
+		"aspect_type", webhook.AspectType)
+	return nil
+}
+
+// Close closes the output file; stdout is left open.
+func (p *LocalPublisher) Close(ctx context.Context) error {
+	if p.closer == nil {
+		return nil
+	}
+	if err := p.closer.Close(); err != nil {
+		return fmt.Errorf("failed to close local publish file: %v", err)
+	}
+	return nil
+}
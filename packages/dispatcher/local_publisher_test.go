@@ -0,0 +1,57 @@
+package dispatcher
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalPublisher_AppendsNDJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	publisher, err := NewLocalPublisher(path, "strava-webhooks")
+	if err != nil {
+		t.Fatalf("NewLocalPublisher failed: %v", err)
+	}
+
+	ctx := context.Background()
+	for id, correlationID := range []string{"corr-1", "corr-2"} {
+		webhook := WebhookRequest{ObjectID: int64(id + 1), AspectType: AspectCreate, OwnerID: 7}
+		if err := publisher.Publish(ctx, webhook, correlationID); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	if err := publisher.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open output: %v", err)
+	}
+	defer file.Close()
+
+	var records []LocalRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record LocalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	record := records[1]
+	if record.Topic != "strava-webhooks" || record.Attributes["correlation_id"] != "corr-2" {
+		t.Errorf("unexpected record metadata: %+v", record)
+	}
+	var webhook WebhookRequest
+	if err := json.Unmarshal(record.Data, &webhook); err != nil || webhook.ObjectID != 2 {
+		t.Errorf("expected webhook as data, got %s", record.Data)
+	}
+}
@@ -1,48 +1,80 @@
 package dispatcher
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
 	"os"
+
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/logging"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Cloud Logging's special fields linking an entry to a Cloud Trace span.
+const (
+	logTraceKey        = "logging.googleapis.com/trace"
+	logSpanIDKey       = "logging.googleapis.com/spanId"
+	logTraceSampledKey = "logging.googleapis.com/trace_sampled"
 )
 
-// setupCloudLogger configures slog for Google Cloud structured logging.
-// Maps slog keys to Google Cloud Logging expected field names and severity levels.
-func setupCloudLogger() *slog.Logger {
-	handler := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
-			// Don't modify attributes in nested groups
-			if groups != nil {
-				return a
-			}
-
-			// Map slog attribute keys to Google Cloud Logging field names
-			switch a.Key {
-			case slog.MessageKey:
-				a.Key = "message"
-			case slog.LevelKey:
-				a.Key = "severity"
-				// Map slog levels to Google Cloud severity strings
-				level := a.Value.Any().(slog.Level)
-				switch {
-				case level < slog.LevelInfo:
-					a.Value = slog.StringValue("DEBUG")
-				case level < slog.LevelWarn:
-					a.Value = slog.StringValue("INFO")
-				case level < slog.LevelError:
-					a.Value = slog.StringValue("WARNING")
-				default:
-					a.Value = slog.StringValue("ERROR")
-				}
-			case slog.TimeKey:
-				a.Key = "timestamp"
-			}
-			return a
-		},
+// logLevel is the minimum level Logger writes, INFO unless changed with
+// SetLogLevel.
+var logLevel = new(slog.LevelVar)
+
+// newLogger returns a logger writing Cloud Logging JSON entries (see
+// logging.New) at level and above, linking entries logged with a traced
+// context to the trace.
+func newLogger(level slog.Leveler) *slog.Logger {
+	return slog.New(&cloudTraceHandler{
+		Handler:   logging.New(os.Stderr, level).Handler(),
+		projectID: config.GetOrDefault("GCP_PROJECT_ID", config.Get("GOOGLE_CLOUD_PROJECT")),
 	})
+}
+
+// cloudTraceHandler adds the trace fields for the span in the record's
+// context, so Cloud Logging groups a request's entries under its trace.
+type cloudTraceHandler struct {
+	slog.Handler
+	projectID string
+}
 
-	return slog.New(handler)
+// Handle implements slog.Handler.
+func (h *cloudTraceHandler) Handle(ctx context.Context, record slog.Record) error {
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() && h.projectID != "" {
+		record.AddAttrs(
+			slog.String(logTraceKey, fmt.Sprintf("projects/%s/traces/%s", h.projectID, span.TraceID())),
+			slog.String(logSpanIDKey, span.SpanID().String()),
+			slog.Bool(logTraceSampledKey, span.IsSampled()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *cloudTraceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &cloudTraceHandler{Handler: h.Handler.WithAttrs(attrs), projectID: h.projectID}
+}
+
+// WithGroup implements slog.Handler.
+func (h *cloudTraceHandler) WithGroup(name string) slog.Handler {
+	return &cloudTraceHandler{Handler: h.Handler.WithGroup(name), projectID: h.projectID}
 }
 
 // Logger is the package-level structured logger for Cloud Functions
-var Logger = setupCloudLogger()
+var Logger = newLogger(logLevel)
+
+// AccessLogger writes the per-request access log (see Handler.logAccess).
+// It ignores LOG_LEVEL, so raising the level for business logs doesn't
+// leave gaps in request dashboards.
+var AccessLogger = newLogger(slog.LevelDebug)
+
+// SetLogLevel changes the minimum level Logger writes. It is safe to call
+// while logging.
+func SetLogLevel(level slog.Level) {
+	// Logged before the change so raising the level doesn't hide it
+	if previous := logLevel.Level(); previous != level {
+		Logger.Info("Log level changed", "from", logging.Severity(previous), "to", logging.Severity(level))
+		logLevel.Set(level)
+	}
+}
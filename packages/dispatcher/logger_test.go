@@ -0,0 +1,45 @@
+package dispatcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCloudTraceHandler(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(&cloudTraceHandler{Handler: slog.NewJSONHandler(&out, nil), projectID: "my-project"})
+
+	span, ok := parseCloudTraceContext("105445aa7843bc8bf206b12000100000/1;o=1")
+	if !ok {
+		t.Fatal("failed to parse trace header")
+	}
+	logger.With("component", "test").InfoContext(trace.ContextWithRemoteSpanContext(context.Background(), span), "traced")
+	logger.InfoContext(context.Background(), "untraced")
+
+	decoder := json.NewDecoder(&out)
+	var traced, untraced map[string]any
+	if err := decoder.Decode(&traced); err != nil {
+		t.Fatalf("invalid log line: %v", err)
+	}
+	if err := decoder.Decode(&untraced); err != nil {
+		t.Fatalf("invalid log line: %v", err)
+	}
+
+	if got := traced[logTraceKey]; got != "projects/my-project/traces/105445aa7843bc8bf206b12000100000" {
+		t.Errorf("unexpected trace field: %v", got)
+	}
+	if got := traced[logSpanIDKey]; got != "0000000000000001" {
+		t.Errorf("unexpected span field: %v", got)
+	}
+	if traced[logTraceSampledKey] != true || traced["component"] != "test" {
+		t.Errorf("unexpected traced entry: %v", traced)
+	}
+	if _, ok := untraced[logTraceKey]; ok {
+		t.Errorf("expected no trace field without a span, got %v", untraced)
+	}
+}
@@ -0,0 +1,179 @@
+package dispatcher
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Rejection and ignore reasons used as metric labels.
+const (
+	reasonBodyTooLarge       = "body_too_large"
+	reasonInvalidJSON        = "invalid_json"
+	reasonInvalidEvent       = "invalid_event"
+	reasonConfigError        = "config_error"
+	reasonSubscription       = "unknown_subscription"
+	reasonPublishFailed      = "publish_failed"
+	reasonCircuitOpen        = "circuit_open"
+	reasonIPFiltered         = "ip_filtered"
+	reasonRateLimited        = "rate_limited"
+	reasonInvalidReplayToken = "invalid_replay_token"
+	reasonMethodNotAllowed   = "method_not_allowed"
+	reasonInvalidMode        = "invalid_mode"
+	reasonInvalidToken       = "invalid_token"
+	reasonOwnerNotAllowed    = "owner_not_allowed"
+	reasonOwnerUnregistered  = "owner_unregistered"
+	reasonNonActivity        = "non_activity"
+	reasonDeauthorization    = "deauthorization"
+)
+
+// metricsRegistry holds the dispatcher's metrics, served on /metrics.
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	eventsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dispatcher_events_received_total",
+		Help: "Webhook events that passed validation, by aspect and object type.",
+	}, []string{"aspect_type", "object_type"})
+
+	eventsPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dispatcher_events_published_total",
+		Help: "Webhook events handed to the publisher, by aspect type.",
+	}, []string{"aspect_type"})
+
+	eventsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dispatcher_events_rejected_total",
+		Help: "Webhook requests answered with an error, by reason.",
+	}, []string{"reason"})
+
+	eventsIgnored = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dispatcher_events_ignored_total",
+		Help: "Webhook events acknowledged without publishing, by reason.",
+	}, []string{"reason"})
+
+	verificationAttempts = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "dispatcher_verification_attempts_total",
+		Help: "Subscription verification requests received.",
+	})
+
+	verificationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dispatcher_verification_failures_total",
+		Help: "Subscription verification requests rejected, by reason.",
+	}, []string{"reason"})
+
+	publishDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dispatcher_publish_duration_seconds",
+		Help:    "Time to hand an event to the publisher chain, by result.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2, 5},
+	}, []string{"result"})
+
+	secretReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dispatcher_secret_reloads_total",
+		Help: "Secret reloads after a content change, by source and result.",
+	}, []string{"source", "result"})
+
+	athleteEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dispatcher_athlete_events_total",
+		Help: "Webhook events per athlete, by owner_id (see athleteLabeler) and stage (received or published).",
+	}, []string{"owner_id", "stage"})
+
+	athleteLastEvent = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dispatcher_athlete_last_event_timestamp_seconds",
+		Help: "Unix time of the last webhook event received per athlete.",
+	}, []string{"owner_id"})
+
+	auditRecordsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "dispatcher_audit_records_dropped_total",
+		Help: "Audit records lost to a full queue or a failed write.",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		eventsReceived,
+		eventsPublished,
+		eventsRejected,
+		eventsIgnored,
+		verificationAttempts,
+		verificationFailures,
+		publishDuration,
+		secretReloads,
+		athleteEvents,
+		athleteLastEvent,
+		auditRecordsDropped,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// MetricsHandler serves the dispatcher's metrics in the Prometheus text
+// format.
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// DefaultAthleteMetricsLimit is how many athletes get their own owner_id
+// label before the rest are counted together.
+const DefaultAthleteMetricsLimit = 100
+
+const (
+	// otherAthletesLabel is the owner_id label of athletes beyond the limit.
+	otherAthletesLabel = "other"
+
+	// Stages of dispatcher_athlete_events_total.
+	athleteStageReceived  = "received"
+	athleteStagePublished = "published"
+)
+
+// athleteLabeler bounds the owner_id label's cardinality: the first limit
+// athletes seen by the instance are labelled by ID, later ones as "other".
+type athleteLabeler struct {
+	seen  map[int64]string
+	limit int
+	mu    sync.Mutex
+}
+
+func newAthleteLabeler(limit int) *athleteLabeler {
+	return &athleteLabeler{seen: map[int64]string{}, limit: limit}
+}
+
+// label returns ownerID's label, claiming one of the remaining slots for a
+// new athlete.
+func (l *athleteLabeler) label(ownerID int64) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if label, ok := l.seen[ownerID]; ok {
+		return label
+	}
+	if len(l.seen) >= l.limit {
+		return otherAthletesLabel
+	}
+	label := strconv.FormatInt(ownerID, 10)
+	l.seen[ownerID] = label
+	return label
+}
+
+// countAthleteEvent counts an event for its athlete at stage. It is a
+// no-op when per-athlete metrics are disabled.
+func (l *athleteLabeler) countAthleteEvent(webhook WebhookRequest, stage string) {
+	if l == nil || l.limit <= 0 {
+		return
+	}
+	label := l.label(webhook.OwnerID)
+	athleteEvents.WithLabelValues(label, stage).Inc()
+	if stage == athleteStageReceived {
+		athleteLastEvent.WithLabelValues(label).SetToCurrentTime()
+	}
+}
+
+// resultLabel returns "success" or "failure" for err.
+func resultLabel(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
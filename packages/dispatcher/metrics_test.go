@@ -0,0 +1,88 @@
+package dispatcher
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHandler_Metrics(t *testing.T) {
+	handler := NewHandlerWithPublisher(&Config{}, &MockPublisher{})
+
+	rejected := testutil.ToFloat64(eventsRejected.WithLabelValues(reasonInvalidJSON))
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("not json"))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got := testutil.ToFloat64(eventsRejected.WithLabelValues(reasonInvalidJSON)); got != rejected+1 {
+		t.Errorf("expected invalid JSON rejection to be counted, got %v want %v", got, rejected+1)
+	}
+
+	failures := testutil.ToFloat64(verificationFailures.WithLabelValues(reasonInvalidMode))
+	req = httptest.NewRequest(http.MethodGet, "/?hub.mode=unsubscribe", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got := testutil.ToFloat64(verificationFailures.WithLabelValues(reasonInvalidMode)); got != failures+1 {
+		t.Errorf("expected verification failure to be counted, got %v want %v", got, failures+1)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("metrics returned status %d", rr.Code)
+	}
+	for _, name := range []string{"dispatcher_events_rejected_total", "dispatcher_verification_attempts_total"} {
+		if !strings.Contains(rr.Body.String(), name) {
+			t.Errorf("expected %s in metrics output", name)
+		}
+	}
+}
+
+func TestAthleteLabeler_BoundsCardinality(t *testing.T) {
+	labeler := newAthleteLabeler(2)
+
+	for _, tt := range []struct {
+		ownerID int64
+		want    string
+	}{
+		{1, "1"},
+		{2, "2"},
+		{3, otherAthletesLabel},
+		{1, "1"},
+	} {
+		if got := labeler.label(tt.ownerID); got != tt.want {
+			t.Errorf("label(%d) = %q, want %q", tt.ownerID, got, tt.want)
+		}
+	}
+}
+
+func TestHandler_AthleteMetrics(t *testing.T) {
+	secretsPath := filepath.Join(t.TempDir(), "strava_auth.json")
+	writeTestSecretsFile(t, secretsPath, map[string]any{
+		"webhook_verify_token":    "test-token",
+		"webhook_subscription_id": 12345,
+	})
+	handler := NewHandlerWithPublisher(&Config{AthleteMetricsLimit: 10}, &MockPublisher{})
+	handler.secrets = NewSecretCache(secretsPath, time.Minute)
+
+	const owner = "424242"
+	received := testutil.ToFloat64(athleteEvents.WithLabelValues(owner, athleteStageReceived))
+	published := testutil.ToFloat64(athleteEvents.WithLabelValues(owner, athleteStagePublished))
+	for _, subscriptionID := range []int{12345, 999} {
+		body := fmt.Sprintf(`{"aspect_type":"create","object_type":"activity","object_id":1,"owner_id":%s,"event_time":1,"subscription_id":%d}`, owner, subscriptionID)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	}
+
+	if got := testutil.ToFloat64(athleteEvents.WithLabelValues(owner, athleteStageReceived)); got != received+1 {
+		t.Errorf("expected one received event for the athlete, got %v want %v", got, received+1)
+	}
+	if got := testutil.ToFloat64(athleteEvents.WithLabelValues(owner, athleteStagePublished)); got != published+1 {
+		t.Errorf("expected one published event for the athlete, got %v want %v", got, published+1)
+	}
+	if got := testutil.ToFloat64(athleteLastEvent.WithLabelValues(owner)); got == 0 {
+		t.Error("expected the athlete's last event time to be set")
+	}
+}
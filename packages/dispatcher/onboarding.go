@@ -0,0 +1,165 @@
+package dispatcher
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+const (
+	// oauthStateCookie carries the state /connect sent to Strava. The
+	// callback only accepts the state it finds there, so nobody can
+	// complete a flow started in someone else's browser.
+	oauthStateCookie = "oauth_state"
+	// oauthStateTTL is how long an athlete has to authorize the app.
+	oauthStateTTL = 10 * time.Minute
+	// requiredScope is the permission the pipeline can't work without.
+	requiredScope = "activity:read_all"
+)
+
+// onboardingEnabled reports whether /connect and /oauth/callback are
+// served: they need a redirect URL, a registry to register athletes in and
+// a token store to keep their tokens.
+func (h *Handler) onboardingEnabled() bool {
+	return h.config.OAuthRedirectURL != "" && h.registry != nil && h.tokens != nil
+}
+
+// appCredentials returns the Strava app's client ID and secret, from
+// providers that expose them.
+func (h *Handler) appCredentials() (StravaSecrets, error) {
+	source, ok := h.secrets.(secretSource)
+	if !ok {
+		return StravaSecrets{}, errors.New("secret provider doesn't expose the Strava app credentials")
+	}
+	return source.Secrets()
+}
+
+// handleConnect starts onboarding by sending the athlete to Strava to
+// authorize the app, with a fresh state remembered in a cookie.
+func (h *Handler) handleConnect(w http.ResponseWriter, r *http.Request, correlationID string) {
+	ctx := r.Context()
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed", "", correlationID)
+		return
+	}
+	credentials, err := h.appCredentials()
+	if err != nil {
+		h.logAndWriteError(ctx, w, correlationID, http.StatusInternalServerError, "Configuration error", err, "Failed to load Strava app credentials")
+		return
+	}
+
+	state := uuid.New().String()
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/",
+		MaxAge:   int(oauthStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.config.OAuthRedirectURL, "https://"),
+		// Lax so the cookie comes along on Strava's redirect back
+		SameSite: http.SameSiteLaxMode,
+	})
+	Logger.InfoContext(ctx, "Starting athlete onboarding", "correlation_id", correlationID)
+	http.Redirect(w, r, strava.AuthorizeURL(credentials.ClientID, h.config.OAuthRedirectURL, strava.DefaultScope, state), http.StatusFound)
+}
+
+// handleOAuthCallback finishes onboarding: it exchanges the code Strava
+// sent the athlete back with for their tokens, stores them in the token
+// store and registers the athlete. Authorizing again updates the tokens
+// and keeps the athlete's registry entry.
+func (h *Handler) handleOAuthCallback(w http.ResponseWriter, r *http.Request, correlationID string) {
+	ctx := r.Context()
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed", "", correlationID)
+		return
+	}
+	query := r.URL.Query()
+
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(query.Get("state"))) != 1 {
+		h.logAndWriteError(ctx, w, correlationID, http.StatusBadRequest, "Invalid or expired authorization state, start again at /connect", nil, "Rejected OAuth callback with invalid state")
+		return
+	}
+	// The state is single-use
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Path: "/", MaxAge: -1})
+
+	if reason := query.Get("error"); reason != "" {
+		Logger.InfoContext(ctx, "Athlete declined authorization", "correlation_id", correlationID, "reason", reason)
+		writeError(w, http.StatusBadRequest, "Authorization was declined", reason, correlationID)
+		return
+	}
+	// Athletes can untick permissions on Strava's page
+	if !strava.HasScope(query.Get("scope"), requiredScope) {
+		Logger.WarnContext(ctx, "Athlete authorized without required scope", "correlation_id", correlationID, "scope", query.Get("scope"))
+		writeError(w, http.StatusBadRequest, "Access to all activities is required, start again at /connect", "granted scope: "+query.Get("scope"), correlationID)
+		return
+	}
+	code := query.Get("code")
+	if code == "" {
+		h.logAndWriteError(ctx, w, correlationID, http.StatusBadRequest, "Missing authorization code", nil, "Rejected OAuth callback without code")
+		return
+	}
+
+	credentials, err := h.appCredentials()
+	if err != nil {
+		h.logAndWriteError(ctx, w, correlationID, http.StatusInternalServerError, "Configuration error", err, "Failed to load Strava app credentials")
+		return
+	}
+	client := strava.New(credentials.ClientID, credentials.ClientSecret, "", h.stravaOptions...)
+	authorization, err := client.ExchangeCode(ctx, code)
+	if err != nil {
+		h.logAndWriteError(ctx, w, correlationID, http.StatusBadGateway, "Failed to authorize with Strava", err, "Failed to exchange authorization code")
+		return
+	}
+
+	athleteID := authorization.Athlete.ID
+	if _, err := h.tokens.UpdateToken(ctx, athleteID, func(*strava.Token) (*strava.Token, error) {
+		return &authorization.Token, nil
+	}); err != nil {
+		h.logAndWriteError(ctx, w, correlationID, http.StatusInternalServerError, "Failed to store Strava tokens", err, "Failed to store onboarded athlete's tokens")
+		return
+	}
+	// The tokens live only in the token store: Strava rotates refresh
+	// tokens, so a registry copy would go stale
+	athlete, err := athletes.Register(ctx, h.registry.update, athletes.Athlete{
+		ID:   athleteID,
+		Name: authorization.Athlete.Name(),
+	})
+	if err != nil {
+		h.logAndWriteError(ctx, w, correlationID, http.StatusInternalServerError, "Failed to register athlete", err, "Failed to register onboarded athlete")
+		return
+	}
+	// Publish the athlete's events straight away rather than after the TTL
+	h.registry.Invalidate()
+	Logger.InfoContext(ctx, "Onboarded athlete", "correlation_id", correlationID, "owner_id", athleteID, "storage_prefix", athlete.Prefix)
+
+	if h.config.OnboardingSuccessURL != "" {
+		target, err := url.Parse(h.config.OnboardingSuccessURL)
+		if err == nil {
+			values := target.Query()
+			values.Set("athlete_id", strconv.FormatInt(athleteID, 10))
+			target.RawQuery = values.Encode()
+			http.Redirect(w, r, target.String(), http.StatusSeeOther)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"athlete_id":     athleteID,
+		"name":           athlete.Name,
+		"storage_prefix": athlete.Prefix,
+		"correlation_id": correlationID,
+	}); err != nil {
+		Logger.ErrorContext(ctx, "Failed to encode onboarding response", "correlation_id", correlationID, "error", err)
+	}
+}
@@ -0,0 +1,141 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/strava"
+	"github.com/andy-esch/desirelines/packages/tokenstore"
+)
+
+// newOnboardingHandler returns a handler onboarding athletes into a file
+// registry and token store, exchanging codes with a fake Strava.
+func newOnboardingHandler(t *testing.T) (*Handler, string) {
+	t.Helper()
+	dir := t.TempDir()
+	secretsPath := filepath.Join(dir, "strava_auth.json")
+	writeTestSecretsFile(t, secretsPath, map[string]any{
+		"webhook_verify_token":    "test-token",
+		"webhook_subscription_id": 12345,
+		"client_id":               99,
+		"client_secret":           "app-secret",
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("code") != "good" || r.Form.Get("client_id") != "99" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"access","refresh_token":"refresh","expires_at":4102444800,
+			"athlete":{"id":42,"firstname":"Ada","lastname":"Lovelace"}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	registryPath := filepath.Join(dir, "athletes.json")
+	handler := NewHandlerWithPublisher(&Config{OAuthRedirectURL: "https://dispatcher.example/oauth/callback"}, &MockPublisher{})
+	handler.secrets = NewSecretCache(secretsPath, time.Minute)
+	handler.registry = &athleteRegistry{
+		Registry: athletes.New(athletes.FileLoader(registryPath), time.Hour),
+		update:   athletes.FileUpdater(registryPath),
+	}
+	handler.tokens = tokenstore.NewFileStore(filepath.Join(dir, "tokens.json"))
+	handler.stravaOptions = []strava.Option{strava.WithTokenURL(server.URL)}
+	return handler, registryPath
+}
+
+func TestHandler_Connect(t *testing.T) {
+	handler, _ := newOnboardingHandler(t)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/connect", nil))
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected a redirect to Strava, got %d", rr.Code)
+	}
+
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid redirect: %v", err)
+	}
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oauthStateCookie || !cookies[0].Secure || !cookies[0].HttpOnly {
+		t.Fatalf("expected a secure state cookie, got %+v", cookies)
+	}
+	query := location.Query()
+	if query.Get("state") != cookies[0].Value || query.Get("client_id") != "99" || query.Get("redirect_uri") != "https://dispatcher.example/oauth/callback" {
+		t.Errorf("unexpected authorize URL %s", location)
+	}
+}
+
+func TestHandler_OAuthCallback(t *testing.T) {
+	handler, registryPath := newOnboardingHandler(t)
+	callback := func(query string, state string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/oauth/callback?"+query, nil)
+		if state != "" {
+			req.AddCookie(&http.Cookie{Name: oauthStateCookie, Value: state})
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		state string
+		want  int
+	}{
+		{"without a state cookie", "state=s1&code=good&scope=read,activity:read_all", "", http.StatusBadRequest},
+		{"with another state", "state=s2&code=good&scope=read,activity:read_all", "s1", http.StatusBadRequest},
+		{"declined", "state=s1&error=access_denied", "s1", http.StatusBadRequest},
+		{"without activity:read_all", "state=s1&code=good&scope=read,activity:read", "s1", http.StatusBadRequest},
+		{"with a bad code", "state=s1&code=bad&scope=read,activity:read_all", "s1", http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := callback(tt.query, tt.state); rr.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, rr.Code, rr.Body)
+			}
+		})
+	}
+
+	rr := callback("state=s1&code=good&scope=read,activity:read_all", "s1")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the athlete to be onboarded, got %d: %s", rr.Code, rr.Body)
+	}
+	var response struct {
+		StoragePrefix string `json:"storage_prefix"`
+		AthleteID     int64  `json:"athlete_id"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil || response.AthleteID != 42 || response.StoragePrefix != "athletes/42/" {
+		t.Errorf("unexpected response %s", rr.Body)
+	}
+
+	athlete, err := athletes.New(athletes.FileLoader(registryPath), 0).Lookup(context.Background(), 42)
+	if err != nil || athlete.Name != "Ada Lovelace" {
+		t.Errorf("expected the athlete registered, got %+v, %v", athlete, err)
+	}
+	if athlete.RefreshToken != "" {
+		t.Errorf("expected the refresh token kept out of the registry, got %q", athlete.RefreshToken)
+	}
+	if _, err := handler.registry.Lookup(context.Background(), 42); err != nil {
+		t.Errorf("expected the cached registry to be refreshed, got %v", err)
+	}
+	token, err := handler.tokens.UpdateToken(context.Background(), 42, func(current *strava.Token) (*strava.Token, error) { return current, nil })
+	if err != nil || token == nil || token.AccessToken != "access" {
+		t.Errorf("expected the tokens stored, got %+v, %v", token, err)
+	}
+}
+
+func TestHandler_OnboardingDisabled(t *testing.T) {
+	handler := NewHandlerWithPublisher(&Config{}, &MockPublisher{})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/connect", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without onboarding, got %d", rr.Code)
+	}
+}
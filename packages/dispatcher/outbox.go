@@ -0,0 +1,317 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	// DefaultOutboxSweepInterval is how often unsent outbox entries are looked for.
+	DefaultOutboxSweepInterval = time.Minute
+	// DefaultOutboxSweepAge is how old a pending entry must be before the
+	// sweeper republishes it, leaving in-flight publishes time to finish.
+	DefaultOutboxSweepAge = 2 * time.Minute
+
+	outboxSweepBatchSize = 100
+	outboxSweepTimeout   = 30 * time.Second
+
+	outboxStatusPending = "pending"
+	outboxStatusSent    = "sent"
+)
+
+// OutboxEntry is an event recorded before publishing.
+type OutboxEntry struct {
+	CreatedAt     time.Time
+	CorrelationID string
+	Webhook       WebhookRequest
+	// SpanContext, Source and StoragePrefix are kept as in PendingEvent, so
+	// a swept entry is published as it would have been at first.
+	SpanContext   trace.SpanContext
+	Source        string
+	StoragePrefix string
+}
+
+// pendingEvent returns the event the entry was recorded for.
+func (e OutboxEntry) pendingEvent() PendingEvent {
+	return PendingEvent{
+		CorrelationID: e.CorrelationID,
+		Webhook:       e.Webhook,
+		SpanContext:   e.SpanContext,
+		Source:        e.Source,
+		StoragePrefix: e.StoragePrefix,
+	}
+}
+
+// OutboxStore durably records events until they are known to be published.
+type OutboxStore interface {
+	// Add records a pending entry keyed by its correlation ID.
+	Add(ctx context.Context, entry OutboxEntry) error
+	// MarkSent marks the entry for correlationID as published.
+	MarkSent(ctx context.Context, correlationID string) error
+	// Pending returns up to limit unsent entries created before olderThan,
+	// oldest first.
+	Pending(ctx context.Context, olderThan time.Time, limit int) ([]OutboxEntry, error)
+	Close() error
+}
+
+// outboxDocument is the Firestore representation of an OutboxEntry. The
+// webhook is kept as its JSON payload so replays publish exactly what
+// Strava sent, and the span as a W3C traceparent.
+type outboxDocument struct {
+	CreatedAt     time.Time `firestore:"created_at"`
+	SentAt        time.Time `firestore:"sent_at,omitempty"`
+	CorrelationID string    `firestore:"correlation_id"`
+	Status        string    `firestore:"status"`
+	Payload       string    `firestore:"payload"`
+	TraceParent   string    `firestore:"traceparent,omitempty"`
+	Source        string    `firestore:"source,omitempty"`
+	StoragePrefix string    `firestore:"storage_prefix,omitempty"`
+}
+
+// FirestoreOutboxStore keeps outbox entries as documents in a Firestore
+// collection, one per correlation ID. Pending queries need a composite
+// index on (status, created_at).
+type FirestoreOutboxStore struct {
+	client     *firestore.Client
+	collection string
+}
+
+// NewFirestoreOutboxStore creates a store using collection in the project's
+// default database.
+func NewFirestoreOutboxStore(ctx context.Context, projectID, collection string) (*FirestoreOutboxStore, error) {
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	Logger.Info("Outbox store initialized", "collection", collection)
+	return &FirestoreOutboxStore{client: client, collection: collection}, nil
+}
+
+// Add implements the OutboxStore interface.
+func (s *FirestoreOutboxStore) Add(ctx context.Context, entry OutboxEntry) error {
+	payload, err := json.Marshal(entry.Webhook)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook data: %w", err)
+	}
+	_, err = s.client.Collection(s.collection).Doc(entry.CorrelationID).Set(ctx, outboxDocument{
+		CreatedAt:     entry.CreatedAt,
+		CorrelationID: entry.CorrelationID,
+		Status:        outboxStatusPending,
+		Payload:       string(payload),
+		TraceParent:   formatTraceParent(entry.SpanContext),
+		Source:        entry.Source,
+		StoragePrefix: entry.StoragePrefix,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write outbox entry: %w", err)
+	}
+	return nil
+}
+
+// MarkSent implements the OutboxStore interface.
+func (s *FirestoreOutboxStore) MarkSent(ctx context.Context, correlationID string) error {
+	_, err := s.client.Collection(s.collection).Doc(correlationID).Update(ctx, []firestore.Update{
+		{Path: "status", Value: outboxStatusSent},
+		{Path: "sent_at", Value: firestore.ServerTimestamp},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox entry sent: %w", err)
+	}
+	return nil
+}
+
+// Pending implements the OutboxStore interface.
+func (s *FirestoreOutboxStore) Pending(ctx context.Context, olderThan time.Time, limit int) ([]OutboxEntry, error) {
+	iter := s.client.Collection(s.collection).
+		Where("status", "==", outboxStatusPending).
+		Where("created_at", "<", olderThan).
+		OrderBy("created_at", firestore.Asc).
+		Limit(limit).
+		Documents(ctx)
+	defer iter.Stop()
+
+	var entries []OutboxEntry
+	for {
+		snapshot, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, fmt.Errorf("failed to query outbox: %w", err)
+		}
+
+		var doc outboxDocument
+		if err := snapshot.DataTo(&doc); err != nil {
+			Logger.Error("Skipping unreadable outbox entry", "id", snapshot.Ref.ID, "error", err)
+			continue
+		}
+		var webhook WebhookRequest
+		if err := json.Unmarshal([]byte(doc.Payload), &webhook); err != nil {
+			Logger.Error("Skipping outbox entry with invalid payload", "id", snapshot.Ref.ID, "error", err)
+			continue
+		}
+		entries = append(entries, OutboxEntry{
+			CreatedAt:     doc.CreatedAt,
+			CorrelationID: doc.CorrelationID,
+			Webhook:       webhook,
+			SpanContext:   parseTraceParent(doc.TraceParent),
+			Source:        doc.Source,
+			StoragePrefix: doc.StoragePrefix,
+		})
+	}
+}
+
+// formatTraceParent returns span as a W3C traceparent header value, or ""
+// when it isn't valid.
+func formatTraceParent(span trace.SpanContext) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(trace.ContextWithSpanContext(context.Background(), span), carrier)
+	return carrier.Get("traceparent")
+}
+
+// parseTraceParent parses a W3C traceparent header value, returning an
+// invalid span context for "" or a malformed value.
+func parseTraceParent(header string) trace.SpanContext {
+	ctx := propagation.TraceContext{}.Extract(context.Background(), propagation.MapCarrier{"traceparent": header})
+	return trace.SpanContextFromContext(ctx)
+}
+
+// Close implements the OutboxStore interface.
+func (s *FirestoreOutboxStore) Close() error {
+	if err := s.client.Close(); err != nil {
+		return fmt.Errorf("failed to close Firestore client: %w", err)
+	}
+	return nil
+}
+
+// OutboxPublisher records each event in an OutboxStore before publishing
+// it and marks it sent afterwards. Once the entry is recorded the webhook
+// succeeds even if publishing fails, and a background sweeper republishes
+// entries left pending, so an event acknowledged to Strava is never lost.
+// An event can be published twice if marking it sent fails, so consumers
+// should tolerate duplicates.
+type OutboxPublisher struct {
+	publisher     Publisher
+	store         OutboxStore
+	now           func() time.Time
+	stop          chan struct{}
+	stopOnce      sync.Once
+	wg            sync.WaitGroup
+	sweepInterval time.Duration
+	sweepAge      time.Duration
+}
+
+// NewOutboxPublisher wraps publisher with store and starts the sweeper.
+func NewOutboxPublisher(publisher Publisher, store OutboxStore, sweepInterval, sweepAge time.Duration) *OutboxPublisher {
+	if sweepInterval <= 0 {
+		sweepInterval = DefaultOutboxSweepInterval
+	}
+	if sweepAge <= 0 {
+		sweepAge = DefaultOutboxSweepAge
+	}
+
+	p := &OutboxPublisher{
+		publisher:     publisher,
+		store:         store,
+		now:           time.Now,
+		stop:          make(chan struct{}),
+		sweepInterval: sweepInterval,
+		sweepAge:      sweepAge,
+	}
+	p.wg.Add(1)
+	go p.runSweeper()
+	return p
+}
+
+// Publish implements the Publisher interface. It fails only when the event
+// could not be recorded in the outbox.
+func (p *OutboxPublisher) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	event := newPendingEvent(ctx, webhook, correlationID)
+	entry := OutboxEntry{
+		CreatedAt:     p.now(),
+		CorrelationID: correlationID,
+		Webhook:       webhook,
+		SpanContext:   event.SpanContext,
+		Source:        event.Source,
+		StoragePrefix: event.StoragePrefix,
+	}
+	if err := p.store.Add(ctx, entry); err != nil {
+		return err
+	}
+
+	if err := p.publisher.Publish(ctx, webhook, correlationID); err != nil {
+		Logger.Warn("Publish failed, leaving event in outbox for the sweeper",
+			"correlation_id", correlationID,
+			"object_id", webhook.ObjectID,
+			"error", err)
+		return nil
+	}
+	p.markSent(context.WithoutCancel(ctx), correlationID)
+	return nil
+}
+
+func (p *OutboxPublisher) markSent(ctx context.Context, correlationID string) {
+	if err := p.store.MarkSent(ctx, correlationID); err != nil {
+		Logger.Error("Failed to mark outbox entry sent; it may be published again",
+			"correlation_id", correlationID,
+			"error", err)
+	}
+}
+
+func (p *OutboxPublisher) runSweeper() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), outboxSweepTimeout)
+			p.sweep(ctx)
+			cancel()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// sweep republishes pending entries older than the sweep age, with the
+// span, source and storage prefix they were received with, and returns how
+// many were published.
+func (p *OutboxPublisher) sweep(ctx context.Context) int {
+	entries, err := p.store.Pending(ctx, p.now().Add(-p.sweepAge), outboxSweepBatchSize)
+	if err != nil {
+		Logger.Error("Outbox sweep failed", "error", err)
+	}
+
+	published := 0
+	for _, entry := range entries {
+		if err := p.publisher.Publish(eventContext(ctx, entry.pendingEvent()), entry.Webhook, entry.CorrelationID); err != nil {
+			Logger.Warn("Outbox republish failed", "correlation_id", entry.CorrelationID, "error", err)
+			continue
+		}
+		p.markSent(ctx, entry.CorrelationID)
+		published++
+	}
+	if published > 0 {
+		Logger.Info("Republished pending outbox entries", "count", published, "pending", len(entries))
+	}
+	return published
+}
+
+// Close implements the Publisher interface, stopping the sweeper before
+// closing the wrapped publisher and the store.
+func (p *OutboxPublisher) Close(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	p.wg.Wait()
+	return errors.Join(p.publisher.Close(ctx), p.store.Close())
+}
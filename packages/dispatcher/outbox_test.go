@@ -0,0 +1,221 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// memoryOutboxStore is an in-memory OutboxStore.
+type memoryOutboxStore struct {
+	addErr      error
+	markSentErr error
+	entries     map[string]OutboxEntry
+	sent        map[string]bool
+	mu          sync.Mutex
+	closed      bool
+}
+
+func newMemoryOutboxStore() *memoryOutboxStore {
+	return &memoryOutboxStore{entries: map[string]OutboxEntry{}, sent: map[string]bool{}}
+}
+
+func (m *memoryOutboxStore) Add(ctx context.Context, entry OutboxEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.addErr != nil {
+		return m.addErr
+	}
+	m.entries[entry.CorrelationID] = entry
+	return nil
+}
+
+func (m *memoryOutboxStore) MarkSent(ctx context.Context, correlationID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.markSentErr != nil {
+		return m.markSentErr
+	}
+	m.sent[correlationID] = true
+	return nil
+}
+
+func (m *memoryOutboxStore) Pending(ctx context.Context, olderThan time.Time, limit int) ([]OutboxEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var pending []OutboxEntry
+	for id, entry := range m.entries {
+		if !m.sent[id] && entry.CreatedAt.Before(olderThan) {
+			pending = append(pending, entry)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].CreatedAt.Before(pending[j].CreatedAt) })
+	if len(pending) > limit {
+		pending = pending[:limit]
+	}
+	return pending, nil
+}
+
+func (m *memoryOutboxStore) Close() error {
+	m.closed = true
+	return nil
+}
+
+func newTestOutboxPublisher(t *testing.T, inner Publisher, store OutboxStore) *OutboxPublisher {
+	t.Helper()
+	// A long interval keeps the background sweeper out of the way; tests
+	// call sweep directly
+	publisher := NewOutboxPublisher(inner, store, time.Hour, time.Minute)
+	t.Cleanup(func() { _ = publisher.Close(context.Background()) })
+	return publisher
+}
+
+func TestOutboxPublisher_MarksPublishedEventsSent(t *testing.T) {
+	inner := &MockPublisher{}
+	store := newMemoryOutboxStore()
+	publisher := newTestOutboxPublisher(t, inner, store)
+
+	if err := publisher.Publish(context.Background(), WebhookRequest{ObjectID: 1}, "corr-1"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if len(inner.Published) != 1 {
+		t.Errorf("expected event to be published, got %d", len(inner.Published))
+	}
+	if _, ok := store.entries["corr-1"]; !ok || !store.sent["corr-1"] {
+		t.Error("expected outbox entry to be recorded and marked sent")
+	}
+}
+
+func TestOutboxPublisher_SweeperRepublishesUnsentEvents(t *testing.T) {
+	inner := &MockPublisher{PublishErr: errors.New("pubsub down")}
+	store := newMemoryOutboxStore()
+	publisher := newTestOutboxPublisher(t, inner, store)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	publisher.now = func() time.Time { return now }
+
+	if err := publisher.Publish(context.Background(), WebhookRequest{ObjectID: 1}, "corr-1"); err != nil {
+		t.Fatalf("expected recorded event to succeed despite publish failure, got %v", err)
+	}
+	if store.sent["corr-1"] {
+		t.Fatal("expected entry to stay pending after a failed publish")
+	}
+
+	// Too recent to sweep: the original publish may still be in flight
+	inner.PublishErr = nil
+	if n := publisher.sweep(context.Background()); n != 0 {
+		t.Errorf("expected recent entry to be left alone, republished %d", n)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if n := publisher.sweep(context.Background()); n != 1 {
+		t.Errorf("expected 1 entry republished, got %d", n)
+	}
+	if len(inner.Published) != 1 || inner.Published[0].ObjectID != 1 || !store.sent["corr-1"] {
+		t.Errorf("expected swept entry to be published and marked sent, got %+v", inner.Published)
+	}
+}
+
+func TestOutboxPublisher_FailsWhenOutboxWriteFails(t *testing.T) {
+	inner := &MockPublisher{}
+	store := newMemoryOutboxStore()
+	store.addErr = errors.New("firestore down")
+	publisher := newTestOutboxPublisher(t, inner, store)
+
+	if err := publisher.Publish(context.Background(), WebhookRequest{ObjectID: 1}, "corr-1"); err == nil {
+		t.Fatal("expected error when the event can't be recorded")
+	}
+	if len(inner.Published) != 0 {
+		t.Error("expected no publish without an outbox entry")
+	}
+}
+
+func TestOutboxPublisher_CloseStopsSweeperAndClosesStore(t *testing.T) {
+	inner := &MockPublisher{}
+	store := newMemoryOutboxStore()
+	publisher := NewOutboxPublisher(inner, store, time.Millisecond, time.Minute)
+
+	if err := publisher.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !inner.Closed || !store.closed {
+		t.Error("expected publisher and store to be closed")
+	}
+}
+
+// eventRecordingPublisher records the event context of each publish.
+type eventRecordingPublisher struct {
+	Publisher
+	events []PendingEvent
+}
+
+func (p *eventRecordingPublisher) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	p.events = append(p.events, newPendingEvent(ctx, webhook, correlationID))
+	return p.Publisher.Publish(ctx, webhook, correlationID)
+}
+
+func TestOutboxPublisher_SweeperRestoresEventContext(t *testing.T) {
+	inner := &MockPublisher{PublishErr: errors.New("pubsub down")}
+	recorder := &eventRecordingPublisher{Publisher: inner}
+	store := newMemoryOutboxStore()
+	publisher := newTestOutboxPublisher(t, recorder, store)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	publisher.now = func() time.Time { return now }
+
+	span, ok := parseCloudTraceContext("105445aa7843bc8bf206b12000100000/1;o=1")
+	if !ok {
+		t.Fatal("failed to parse trace header")
+	}
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), span)
+	ctx = withEventSource(ctx, SourceReplay)
+	ctx = withStoragePrefix(ctx, "athletes/42")
+	if err := publisher.Publish(ctx, WebhookRequest{ObjectID: 1}, "corr-1"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	inner.PublishErr = nil
+	now = now.Add(2 * time.Minute)
+	if n := publisher.sweep(context.Background()); n != 1 {
+		t.Fatalf("expected 1 entry republished, got %d", n)
+	}
+
+	if len(recorder.events) != 2 {
+		t.Fatalf("expected the first publish and the republish, got %d", len(recorder.events))
+	}
+	swept := recorder.events[1]
+	if swept.Source != SourceReplay || swept.StoragePrefix != "athletes/42" {
+		t.Errorf("expected the source and storage prefix restored, got %q and %q", swept.Source, swept.StoragePrefix)
+	}
+	if swept.SpanContext.TraceID() != span.TraceID() || swept.SpanContext.SpanID() != span.SpanID() {
+		t.Errorf("expected the received span restored, got %v", swept.SpanContext)
+	}
+}
+
+func TestTraceParent(t *testing.T) {
+	span, ok := parseCloudTraceContext("105445aa7843bc8bf206b12000100000/1;o=1")
+	if !ok {
+		t.Fatal("failed to parse trace header")
+	}
+
+	header := formatTraceParent(span)
+	if header != "00-105445aa7843bc8bf206b12000100000-0000000000000001-01" {
+		t.Errorf("unexpected traceparent %q", header)
+	}
+	parsed := parseTraceParent(header)
+	if parsed.TraceID() != span.TraceID() || parsed.SpanID() != span.SpanID() || !parsed.IsSampled() {
+		t.Errorf("expected the span back, got %v", parsed)
+	}
+
+	if header := formatTraceParent(trace.SpanContext{}); header != "" {
+		t.Errorf("expected no traceparent without a span, got %q", header)
+	}
+	for _, header := range []string{"", "not-a-traceparent"} {
+		if parseTraceParent(header).IsValid() {
+			t.Errorf("expected an invalid span for %q", header)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package dispatcher
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// OwnerAllowlist limits which athletes' events are published, protecting
+// the pipeline if the Strava subscription ever receives other athletes'
+// events.
+type OwnerAllowlist struct {
+	owners map[int64]bool
+}
+
+// ParseOwnerAllowlist builds an allowlist from comma-separated athlete IDs.
+// It returns nil when the list is empty so callers can skip filtering
+// entirely.
+func ParseOwnerAllowlist(list string) (*OwnerAllowlist, error) {
+	owners := map[int64]bool{}
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(entry, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid owner ID %q", entry)
+		}
+		owners[id] = true
+	}
+	if len(owners) == 0 {
+		return nil, nil
+	}
+	return &OwnerAllowlist{owners: owners}, nil
+}
+
+// Allowed reports whether events owned by ownerID may be published.
+func (a *OwnerAllowlist) Allowed(ownerID int64) bool {
+	return a.owners[ownerID]
+}
+
+// String lists the allowed athlete IDs in ascending order.
+func (a *OwnerAllowlist) String() string {
+	ids := make([]string, 0, len(a.owners))
+	for _, id := range slices.Sorted(maps.Keys(a.owners)) {
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(ids, ",")
+}
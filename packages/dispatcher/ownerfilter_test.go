@@ -0,0 +1,31 @@
+package dispatcher
+
+import "testing"
+
+func TestParseOwnerAllowlist(t *testing.T) {
+	allowlist, err := ParseOwnerAllowlist(" 1234, 5678 ,")
+	if err != nil {
+		t.Fatalf("ParseOwnerAllowlist failed: %v", err)
+	}
+	if !allowlist.Allowed(1234) || !allowlist.Allowed(5678) {
+		t.Error("expected listed owners to be allowed")
+	}
+	if allowlist.Allowed(9999) {
+		t.Error("expected unlisted owner to be rejected")
+	}
+}
+
+func TestParseOwnerAllowlist_Empty(t *testing.T) {
+	allowlist, err := ParseOwnerAllowlist("")
+	if err != nil || allowlist != nil {
+		t.Errorf("expected nil allowlist, got %v, %v", allowlist, err)
+	}
+}
+
+func TestParseOwnerAllowlist_Invalid(t *testing.T) {
+	for _, list := range []string{"abc", "12,-3", "0"} {
+		if _, err := ParseOwnerAllowlist(list); err == nil {
+			t.Errorf("expected error for %q", list)
+		}
+	}
+}
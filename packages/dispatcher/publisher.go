@@ -2,24 +2,131 @@ package dispatcher
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"slices"
+	"strconv"
+	"time"
 
+	"cloud.google.com/go/iam/apiv1/iampb"
 	"cloud.google.com/go/pubsub/v2"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Publisher defines the interface for publishing webhook events.
+// Close flushes any buffered messages and releases resources, giving up
+// when ctx is done; events still buffered at that point may be lost.
 type Publisher interface {
 	Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error
+	Close(ctx context.Context) error
+}
+
+// TopicChecker is implemented by publishers that can verify their topic is
+// usable without publishing to it.
+type TopicChecker interface {
+	CheckTopic(ctx context.Context) error
+}
+
+// PendingEvent is a webhook waiting to be published, with the correlation ID
+// of the request that delivered it.
+type PendingEvent struct {
+	CorrelationID string
+	Webhook       WebhookRequest
+	// SpanContext is the trace span that received the event, so publishing
+	// continues its trace even from a background worker.
+	SpanContext trace.SpanContext
+	// Source is where the event came from when not Strava, e.g.
+	// SourceReplay.
+	Source string
+	// StoragePrefix is the registered owner's storage prefix (see
+	// StoragePrefixAttribute).
+	StoragePrefix string
+}
+
+// newPendingEvent wraps webhook with the span context and event source of
+// ctx.
+func newPendingEvent(ctx context.Context, webhook WebhookRequest, correlationID string) PendingEvent {
+	return PendingEvent{
+		CorrelationID: correlationID,
+		Webhook:       webhook,
+		SpanContext:   trace.SpanContextFromContext(ctx),
+		Source:        eventSourceFromContext(ctx),
+		StoragePrefix: storagePrefixFromContext(ctx),
+	}
+}
+
+// eventContext returns ctx carrying the span that received event, if any,
+// and the event's source and storage prefix.
+func eventContext(ctx context.Context, event PendingEvent) context.Context {
+	if event.Source != "" {
+		ctx = withEventSource(ctx, event.Source)
+	}
+	if event.StoragePrefix != "" {
+		ctx = withStoragePrefix(ctx, event.StoragePrefix)
+	}
+	if !event.SpanContext.IsValid() {
+		return ctx
+	}
+	return trace.ContextWithSpanContext(ctx, event.SpanContext)
+}
+
+// BatchPublisher is implemented by publishers that can send several events
+// without a round trip per event. The returned errors line up with events.
+type BatchPublisher interface {
+	PublishBatch(ctx context.Context, events []PendingEvent) []error
+}
+
+// publishAll publishes events through publisher, in one batch when it
+// supports batching and one by one otherwise.
+func publishAll(ctx context.Context, publisher Publisher, events []PendingEvent) []error {
+	if batcher, ok := publisher.(BatchPublisher); ok {
+		return batcher.PublishBatch(ctx, events)
+	}
+	errs := make([]error, len(events))
+	for i, event := range events {
+		errs[i] = publisher.Publish(eventContext(ctx, event), event.Webhook, event.CorrelationID)
+	}
+	return errs
 }
 
 // PubSubPublisher is a Pub/Sub adapter that implements the Publisher interface.
 type PubSubPublisher struct {
+	client    *pubsub.Client
 	publisher *pubsub.Publisher
+	topic     string
+	// format selects raw or CloudEvents message data.
+	format MessageFormat
+	// maxEventAge, when set, flags older events with HistoricalAttribute.
+	maxEventAge time.Duration
+}
+
+// BatchSettings controls how the Pub/Sub client bundles messages into
+// publish requests: a batch is sent when it holds CountThreshold messages or
+// ByteThreshold bytes, or DelayThreshold after its first message. Zero
+// fields keep the client library defaults.
+type BatchSettings struct {
+	DelayThreshold time.Duration
+	CountThreshold int
+	ByteThreshold  int
+}
+
+// apply overrides the non-zero thresholds in settings.
+func (b BatchSettings) apply(settings *pubsub.PublishSettings) {
+	if b.DelayThreshold > 0 {
+		settings.DelayThreshold = b.DelayThreshold
+	}
+	if b.CountThreshold > 0 {
+		settings.CountThreshold = b.CountThreshold
+	}
+	if b.ByteThreshold > 0 {
+		settings.ByteThreshold = b.ByteThreshold
+	}
 }
 
 // NewPubSubPublisher creates a new Pub/Sub publisher.
-func NewPubSubPublisher(ctx context.Context, projectID, topicID string) (*PubSubPublisher, error) {
+func NewPubSubPublisher(ctx context.Context, projectID, topicID string, batching BatchSettings) (*PubSubPublisher, error) {
 	client, err := pubsub.NewClient(ctx, projectID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create PubSub client: %v", err)
@@ -27,36 +134,170 @@ func NewPubSubPublisher(ctx context.Context, projectID, topicID string) (*PubSub
 
 	topicName := fmt.Sprintf("projects/%s/topics/%s", projectID, topicID)
 	publisher := client.Publisher(topicName)
-	Logger.Info("PubSub publisher initialized", "topic", topicName)
+	// Per-athlete ordering keys (see OrderingKey) require ordered publishing
+	publisher.EnableMessageOrdering = true
+	batching.apply(&publisher.PublishSettings)
+	Logger.Info("PubSub publisher initialized",
+		"topic", topicName,
+		"batch_max_messages", publisher.PublishSettings.CountThreshold,
+		"batch_max_bytes", publisher.PublishSettings.ByteThreshold,
+		"batch_max_latency", publisher.PublishSettings.DelayThreshold)
 
-	return &PubSubPublisher{publisher: publisher}, nil
+	return &PubSubPublisher{client: client, publisher: publisher, topic: topicID}, nil
 }
 
 // Publish implements the Publisher interface.
 func (p *PubSubPublisher) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
-	data, err := json.Marshal(webhook)
-	if err != nil {
-		return fmt.Errorf("failed to marshal webhook data: %v", err)
+	return p.PublishBatch(ctx, []PendingEvent{newPendingEvent(ctx, webhook, correlationID)})[0]
+}
+
+// PublishBatch implements the BatchPublisher interface. All messages are
+// handed to the client before waiting on any result, so they share publish
+// requests according to the batch settings.
+func (p *PubSubPublisher) PublishBatch(ctx context.Context, events []PendingEvent) []error {
+	now := time.Now()
+	errs := make([]error, len(events))
+	results := make([]*pubsub.PublishResult, len(events))
+	spans := make([]trace.Span, len(events))
+	for i, event := range events {
+		data, err := encodeMessage(p.format, event.Webhook, event.CorrelationID)
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to marshal webhook data: %v", err)
+			continue
+		}
+		attributes := eventAttributes(event, p.format, now, p.maxEventAge)
+		spans[i] = startPublishSpan(ctx, event, "gcp_pubsub", p.topic, attributes)
+		results[i] = p.publisher.Publish(ctx, &pubsub.Message{
+			Data:        data,
+			Attributes:  attributes,
+			OrderingKey: OrderingKey(event.Webhook),
+		})
 	}
 
-	result := p.publisher.Publish(ctx, &pubsub.Message{
-		Data: data,
-		Attributes: map[string]string{
-			"correlation_id": correlationID,
-		},
-	})
+	for i, result := range results {
+		if result == nil {
+			continue
+		}
+		event := events[i]
+
+		// Get blocks until the message is published or an error occurs.
+		_, err := result.Get(ctx)
+		endSpan(spans[i], err)
+		if err != nil {
+			// A failed ordered publish pauses its key; resume so the athlete's
+			// next event (or Strava's retry of this one) can be published.
+			p.publisher.ResumePublish(OrderingKey(event.Webhook))
+			errs[i] = fmt.Errorf("failed to publish to PubSub: %w", err)
+			continue
+		}
 
-	// Get blocks until the message is published or an error occurs.
-	_, err = result.Get(ctx)
+		Logger.InfoContext(eventContext(ctx, event), "Successfully published webhook to PubSub",
+			"correlation_id", event.CorrelationID,
+			"object_id", event.Webhook.ObjectID,
+			"aspect_type", event.Webhook.AspectType,
+			"owner_id", event.Webhook.OwnerID,
+			"subscription_id", event.Webhook.SubscriptionID)
+	}
+	return errs
+}
+
+// MessageAttributes returns the Pub/Sub attributes set on every published
+// event, so subscriptions can filter (e.g. attributes.aspect_type = "create")
+// without decoding the payload.
+func MessageAttributes(webhook WebhookRequest, correlationID string) map[string]string {
+	return map[string]string{
+		"correlation_id":  correlationID,
+		"aspect_type":     webhook.AspectType,
+		"object_type":     webhook.ObjectType,
+		"owner_id":        strconv.FormatInt(webhook.OwnerID, 10),
+		"subscription_id": strconv.Itoa(webhook.SubscriptionID),
+	}
+}
+
+// eventAttributes returns MessageAttributes for event plus the attributes
+// that depend on the message format and the publisher's maximum event age.
+func eventAttributes(event PendingEvent, format MessageFormat, now time.Time, maxEventAge time.Duration) map[string]string {
+	attributes := MessageAttributes(event.Webhook, event.CorrelationID)
+	if format == MessageFormatCloudEvents {
+		attributes["content-type"] = cloudEventsContentType
+	}
+	if IsHistorical(event.Webhook, now, maxEventAge) {
+		attributes[HistoricalAttribute] = "true"
+	}
+	if event.Webhook.Activity != nil {
+		attributes[EnrichedAttribute] = "true"
+	}
+	if IsDeauthorization(event.Webhook) {
+		attributes[DeauthorizationAttribute] = "true"
+	}
+	if event.Source != "" {
+		attributes[SourceAttribute] = event.Source
+	}
+	if event.StoragePrefix != "" {
+		attributes[StoragePrefixAttribute] = event.StoragePrefix
+	}
+	return attributes
+}
+
+// OrderingKey returns the Pub/Sub ordering key for an event. Keying by
+// athlete keeps create, update and delete for one owner in publish order
+// for subscriptions with message ordering enabled, while different athletes
+// are still delivered in parallel.
+func OrderingKey(webhook WebhookRequest) string {
+	return strconv.FormatInt(webhook.OwnerID, 10)
+}
+
+// Flush sends any batched messages immediately instead of waiting for a
+// batch threshold. It blocks until they are published or fail.
+func (p *PubSubPublisher) Flush() {
+	p.publisher.Flush()
+}
+
+// topicPublishPermission is what publishing to a topic requires.
+const topicPublishPermission = "pubsub.topics.publish"
+
+// CheckTopic implements the TopicChecker interface: the topic must exist
+// and the credentials must be allowed to publish to it. The permission
+// check is skipped against the emulator, which doesn't implement IAM.
+func (p *PubSubPublisher) CheckTopic(ctx context.Context) error {
+	name := p.publisher.String()
+	if _, err := p.client.TopicAdminClient.GetTopic(ctx, &pubsubpb.GetTopicRequest{Topic: name}); err != nil {
+		return fmt.Errorf("topic %s: %w", name, err)
+	}
+
+	resp, err := p.client.TopicAdminClient.TestIamPermissions(ctx, &iampb.TestIamPermissionsRequest{
+		Resource:    name,
+		Permissions: []string{topicPublishPermission},
+	})
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to publish to PubSub: %v", err)
+		return fmt.Errorf("failed to check permissions on topic %s: %w", name, err)
+	}
+	if !slices.Contains(resp.GetPermissions(), topicPublishPermission) {
+		return fmt.Errorf("missing %s on topic %s", topicPublishPermission, name)
 	}
+	return nil
+}
 
-	Logger.Info("Successfully published webhook to PubSub",
-		"correlation_id", correlationID,
-		"object_id", webhook.ObjectID,
-		"aspect_type", webhook.AspectType,
-		"owner_id", webhook.OwnerID)
+// Close flushes pending messages and releases the underlying PubSub client.
+func (p *PubSubPublisher) Close(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		p.publisher.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		return fmt.Errorf("timed out flushing PubSub messages: %w", ctx.Err())
+	}
+
+	if err := p.client.Close(); err != nil {
+		return fmt.Errorf("failed to close PubSub client: %v", err)
+	}
 	return nil
 }
 
@@ -64,6 +305,7 @@ func (p *PubSubPublisher) Publish(ctx context.Context, webhook WebhookRequest, c
 type MockPublisher struct {
 	PublishErr error
 	Published  []WebhookRequest
+	Closed     bool
 }
 
 // Publish implements the mock publisher.
@@ -73,3 +315,9 @@ func (m *MockPublisher) Publish(ctx context.Context, webhook WebhookRequest, cor
 	}
 	return m.PublishErr
 }
+
+// Close implements the mock publisher.
+func (m *MockPublisher) Close(ctx context.Context) error {
+	m.Closed = true
+	return nil
+}
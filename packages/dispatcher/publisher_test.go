@@ -0,0 +1,67 @@
+package dispatcher
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+)
+
+func TestMessageAttributes(t *testing.T) {
+	webhook := WebhookRequest{
+		AspectType:     AspectCreate,
+		ObjectType:     ObjectActivity,
+		ObjectID:       42,
+		OwnerID:        9876543210,
+		SubscriptionID: 12345,
+	}
+
+	attrs := MessageAttributes(webhook, "corr-1")
+
+	want := map[string]string{
+		"correlation_id":  "corr-1",
+		"aspect_type":     "create",
+		"object_type":     "activity",
+		"owner_id":        "9876543210",
+		"subscription_id": "12345",
+	}
+	if len(attrs) != len(want) {
+		t.Errorf("expected %d attributes, got %d: %v", len(want), len(attrs), attrs)
+	}
+	for key, value := range want {
+		if attrs[key] != value {
+			t.Errorf("attribute %s = %q, want %q", key, attrs[key], value)
+		}
+	}
+}
+
+func TestOrderingKey(t *testing.T) {
+	first := OrderingKey(WebhookRequest{OwnerID: 7, ObjectID: 1, AspectType: AspectCreate})
+	second := OrderingKey(WebhookRequest{OwnerID: 7, ObjectID: 2, AspectType: AspectDelete})
+	other := OrderingKey(WebhookRequest{OwnerID: 8, ObjectID: 1, AspectType: AspectCreate})
+
+	if first != "7" {
+		t.Errorf("expected ordering key %q, got %q", "7", first)
+	}
+	if first != second {
+		t.Error("expected events for the same athlete to share an ordering key")
+	}
+	if first == other {
+		t.Error("expected different athletes to have different ordering keys")
+	}
+}
+
+func TestBatchSettingsApply(t *testing.T) {
+	settings := pubsub.DefaultPublishSettings
+	BatchSettings{CountThreshold: 500, DelayThreshold: 50 * time.Millisecond}.apply(&settings)
+
+	if settings.CountThreshold != 500 {
+		t.Errorf("expected CountThreshold 500, got %d", settings.CountThreshold)
+	}
+	if settings.DelayThreshold != 50*time.Millisecond {
+		t.Errorf("expected DelayThreshold 50ms, got %v", settings.DelayThreshold)
+	}
+	if settings.ByteThreshold != pubsub.DefaultPublishSettings.ByteThreshold {
+		t.Errorf("expected default ByteThreshold to be kept, got %d", settings.ByteThreshold)
+	}
+}
@@ -0,0 +1,150 @@
+package dispatcher
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// maxRateLimitClients bounds the per-IP limiter table; idle limiters are
+// swept when it fills so a flood of distinct IPs can't grow it forever.
+const maxRateLimitClients = 10000
+
+// RateLimits configures flood protection as sustained requests per second
+// with a burst allowance, for all callers together and for each client IP.
+// A zero rate disables that limit; a zero burst defaults to one second's
+// worth of requests.
+type RateLimits struct {
+	Global      float64
+	PerIP       float64
+	GlobalBurst int
+	PerIPBurst  int
+}
+
+// RateLimiter is a token bucket limiter applied globally and per client
+// IP, so a runaway replay script or a hostile client can't drive up publish
+// costs or exhaust instance concurrency.
+type RateLimiter struct {
+	global  *rate.Limiter
+	clients map[string]*rate.Limiter
+	limits  RateLimits
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+// NewRateLimiter creates a limiter for limits. It returns nil when both
+// rates are zero so callers can skip limiting entirely.
+func NewRateLimiter(limits RateLimits) *RateLimiter {
+	if limits.Global <= 0 && limits.PerIP <= 0 {
+		return nil
+	}
+	limits.GlobalBurst = burstOrDefault(limits.GlobalBurst, limits.Global)
+	limits.PerIPBurst = burstOrDefault(limits.PerIPBurst, limits.PerIP)
+
+	l := &RateLimiter{
+		clients: make(map[string]*rate.Limiter),
+		limits:  limits,
+		now:     time.Now,
+	}
+	if limits.Global > 0 {
+		l.global = rate.NewLimiter(rate.Limit(limits.Global), limits.GlobalBurst)
+	}
+	return l
+}
+
+// burstOrDefault is burst, or one second of requests at r (at least one).
+func burstOrDefault(burst int, r float64) int {
+	if burst > 0 || r <= 0 {
+		return burst
+	}
+	return max(1, int(math.Ceil(r)))
+}
+
+// String describes the limits as "global=<rate>/s burst=<n> per_ip=...".
+func (l *RateLimiter) String() string {
+	return fmt.Sprintf("global=%g/s burst=%d per_ip=%g/s burst=%d",
+		l.limits.Global, l.limits.GlobalBurst, l.limits.PerIP, l.limits.PerIPBurst)
+}
+
+// Allow reports whether a request from ip may proceed and, when it may
+// not, how long until it would. A request refused by the global limit
+// doesn't use up the client's own allowance.
+func (l *RateLimiter) Allow(ip string) (bool, time.Duration) {
+	now := l.now()
+
+	var client *rate.Reservation
+	if l.limits.PerIP > 0 {
+		client = l.clientLimiter(ip, now).ReserveN(now, 1)
+		if delay := client.DelayFrom(now); delay > 0 {
+			client.CancelAt(now)
+			return false, delay
+		}
+	}
+	if l.global != nil {
+		reservation := l.global.ReserveN(now, 1)
+		if delay := reservation.DelayFrom(now); delay > 0 {
+			reservation.CancelAt(now)
+			if client != nil {
+				client.CancelAt(now)
+			}
+			return false, delay
+		}
+	}
+	return true, 0
+}
+
+// clientLimiter returns ip's limiter, creating it if needed.
+func (l *RateLimiter) clientLimiter(ip string, now time.Time) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limiter, ok := l.clients[ip]
+	if !ok {
+		if len(l.clients) >= maxRateLimitClients {
+			l.sweep(now)
+		}
+		limiter = rate.NewLimiter(rate.Limit(l.limits.PerIP), l.limits.PerIPBurst)
+		l.clients[ip] = limiter
+	}
+	return limiter
+}
+
+// sweep drops limiters that have refilled, which behave like new ones, or
+// every limiter if none have. Must be called with l.mu held.
+func (l *RateLimiter) sweep(now time.Time) {
+	for ip, limiter := range l.clients {
+		if limiter.TokensAt(now) >= float64(limiter.Burst()) {
+			delete(l.clients, ip)
+		}
+	}
+	if len(l.clients) >= maxRateLimitClients {
+		l.clients = make(map[string]*rate.Limiter)
+	}
+}
+
+// checkRateLimit writes a 429 with Retry-After when the caller is over the
+// rate limit. It returns false when the request must not proceed.
+func (h *Handler) checkRateLimit(w http.ResponseWriter, r *http.Request, correlationID string) bool {
+	if h.config.RateLimiter == nil {
+		return true
+	}
+	ip := h.clientIP(r)
+	allowed, retryAfter := h.config.RateLimiter.Allow(ip)
+	if allowed {
+		return true
+	}
+
+	// Debug only: logging every refused request would let a flood drive up
+	// logging costs instead
+	Logger.DebugContext(r.Context(), "Rejected rate-limited request", "correlation_id", correlationID, "client_ip", ip)
+	eventsRejected.WithLabelValues(reasonRateLimited).Inc()
+	h.auditRejection(r, correlationID, http.StatusTooManyRequests, reasonRateLimited)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	writeError(w, http.StatusTooManyRequests, "Too many requests", "", correlationID)
+	return false
+}
@@ -0,0 +1,127 @@
+package dispatcher
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_PerIP(t *testing.T) {
+	limiter := NewRateLimiter(RateLimits{PerIP: 1, PerIPBurst: 2})
+	now := time.Unix(1700000000, 0)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow("192.0.2.1"); !ok {
+			t.Fatalf("request %d: expected to be within the burst", i+1)
+		}
+	}
+	ok, retryAfter := limiter.Allow("192.0.2.1")
+	if ok {
+		t.Fatal("expected the request over the burst to be refused")
+	}
+	if retryAfter <= 0 || retryAfter > time.Second {
+		t.Errorf("expected a retry delay of up to 1s, got %s", retryAfter)
+	}
+	if ok, _ := limiter.Allow("192.0.2.2"); !ok {
+		t.Error("expected another client to have its own allowance")
+	}
+
+	now = now.Add(time.Second)
+	if ok, _ := limiter.Allow("192.0.2.1"); !ok {
+		t.Error("expected a token to have refilled after 1s")
+	}
+}
+
+func TestRateLimiter_GlobalRefusalKeepsClientAllowance(t *testing.T) {
+	limiter := NewRateLimiter(RateLimits{Global: 1, GlobalBurst: 1, PerIP: 1, PerIPBurst: 1})
+	now := time.Unix(1700000000, 0)
+	limiter.now = func() time.Time { return now }
+
+	if ok, _ := limiter.Allow("192.0.2.1"); !ok {
+		t.Fatal("expected the first request to be allowed")
+	}
+	if ok, _ := limiter.Allow("192.0.2.2"); ok {
+		t.Fatal("expected the global limit to refuse a second client")
+	}
+
+	now = now.Add(time.Second)
+	if ok, _ := limiter.Allow("192.0.2.2"); !ok {
+		t.Error("expected the refused client's own token to have been returned")
+	}
+}
+
+func TestRateLimiter_SweepsIdleClients(t *testing.T) {
+	limiter := NewRateLimiter(RateLimits{PerIP: 1})
+	now := time.Unix(1700000000, 0)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < maxRateLimitClients; i++ {
+		limiter.Allow(fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+	}
+	now = now.Add(time.Second)
+	limiter.Allow("192.0.2.1")
+	if len(limiter.clients) != 1 {
+		t.Errorf("expected refilled limiters to be swept, %d remain", len(limiter.clients))
+	}
+}
+
+func TestNewRateLimiter_Disabled(t *testing.T) {
+	if limiter := NewRateLimiter(RateLimits{}); limiter != nil {
+		t.Errorf("expected nil limiter without rates, got %v", limiter)
+	}
+	limiter := NewRateLimiter(RateLimits{Global: 2.5})
+	if got, want := limiter.String(), "global=2.5/s burst=3 per_ip=0/s burst=0"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestHandler_RateLimited(t *testing.T) {
+	cfg := &Config{RateLimiter: NewRateLimiter(RateLimits{PerIP: 1, PerIPBurst: 1})}
+	handler := NewHandlerWithPublisher(cfg, &MockPublisher{})
+
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodHead, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := serve(); rr.Code != http.StatusOK {
+		t.Fatalf("expected first request to pass, got %d", rr.Code)
+	}
+	rr := serve()
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected Retry-After: 1, got %q", rr.Header().Get("Retry-After"))
+	}
+
+	live := httptest.NewRecorder()
+	handler.ServeHTTP(live, httptest.NewRequest(http.MethodGet, "/live", nil))
+	if live.Code != http.StatusOK {
+		t.Errorf("expected probes to be exempt, got %d", live.Code)
+	}
+}
+
+func TestLoadRateLimits(t *testing.T) {
+	t.Setenv("RATE_LIMIT", "50")
+	t.Setenv("RATE_LIMIT_BURST", "100")
+	t.Setenv("RATE_LIMIT_PER_IP", "0.5")
+
+	limits, err := loadRateLimits()
+	if err != nil {
+		t.Fatalf("loadRateLimits failed: %v", err)
+	}
+	if limits != (RateLimits{Global: 50, GlobalBurst: 100, PerIP: 0.5}) {
+		t.Errorf("unexpected limits %+v", limits)
+	}
+
+	t.Setenv("RATE_LIMIT_PER_IP_BURST", "-1")
+	if _, err := loadRateLimits(); err == nil {
+		t.Error("expected error for negative RATE_LIMIT_PER_IP_BURST")
+	}
+}
@@ -0,0 +1,96 @@
+package dispatcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/ipfilter"
+)
+
+func TestHandler_ReplayToken(t *testing.T) {
+	secretsPath := filepath.Join(t.TempDir(), "strava_auth.json")
+	writeTestSecretsFile(t, secretsPath, map[string]any{
+		"webhook_verify_token":    "test-token",
+		"webhook_subscription_id": 12345,
+	})
+	filter, err := ipfilter.Parse("203.0.113.0/24", "")
+	if err != nil {
+		t.Fatalf("ipfilter.Parse failed: %v", err)
+	}
+	var out bytes.Buffer
+	handler := NewHandlerWithPublisher(&Config{IPFilter: filter, ReplayToken: "replay-secret"}, &LocalPublisher{out: &out})
+	handler.secrets = NewSecretCache(secretsPath, time.Minute)
+
+	post := func(token string) int {
+		body := `{"aspect_type":"create","object_type":"activity","object_id":1,"owner_id":1,"event_time":1,"subscription_id":12345}`
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.RemoteAddr = "192.0.2.1:1234"
+		if token != "" {
+			req.Header.Set(ReplayTokenHeader, token)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := post("wrong"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for invalid replay token, got %d", code)
+	}
+	if code := post(""); code != http.StatusForbidden {
+		t.Errorf("expected untokened request from outside the allowlist to be filtered, got %d", code)
+	}
+	if code := post("replay-secret"); code != http.StatusCreated {
+		t.Fatalf("expected replay to bypass the IP filter, got %d", code)
+	}
+
+	var record LocalRecord
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("invalid local record: %v", err)
+	}
+	if record.Attributes[SourceAttribute] != SourceReplay {
+		t.Errorf("expected source=replay attribute, got %v", record.Attributes)
+	}
+}
+
+func TestHandler_ReplayTokenNotConfigured(t *testing.T) {
+	handler := NewHandlerWithPublisher(&Config{}, &MockPublisher{})
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{}`))
+	req.Header.Set(ReplayTokenHeader, "anything")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 when no replay token is configured, got %d", rr.Code)
+	}
+}
+
+func TestAsyncPublisher_KeepsEventSource(t *testing.T) {
+	inner := &MockPublisher{}
+	publisher := NewAsyncPublisher(&sourceRecordingPublisher{Publisher: inner}, 1)
+	if err := publisher.Publish(withEventSource(t.Context(), SourceReplay), WebhookRequest{ObjectID: 1}, "corr-1"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if err := publisher.Close(t.Context()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if sources := publisher.publisher.(*sourceRecordingPublisher).sources; len(sources) != 1 || sources[0] != SourceReplay {
+		t.Errorf("expected queued event to keep its source, got %v", sources)
+	}
+}
+
+// sourceRecordingPublisher records the event source of each publish.
+type sourceRecordingPublisher struct {
+	Publisher
+	sources []string
+}
+
+func (p *sourceRecordingPublisher) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	p.sources = append(p.sources, eventSourceFromContext(ctx))
+	return p.Publisher.Publish(ctx, webhook, correlationID)
+}
@@ -0,0 +1,133 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultRetryPolicy keeps the worst case well inside the 2 seconds Strava
+// waits for a webhook response.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     time.Second,
+}
+
+// RetryPolicy controls how transient publish failures are retried. Backoff
+// doubles from InitialBackoff up to MaxBackoff, and each wait is jittered
+// to between half and all of the current backoff. MaxAttempts of 1 (or
+// less) disables retries.
+type RetryPolicy struct {
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	MaxAttempts    int
+}
+
+// IsRetryable reports whether a publish error is worth retrying: gRPC codes
+// that signal a transient server or network condition. Anything else, such
+// as permission or not-found errors, fails the same way on every attempt.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	switch st.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted,
+		codes.Aborted, codes.Internal, codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
+
+// RetryingPublisher retries retryable failures of the wrapped publisher
+// according to its policy, giving up early when the request context ends.
+type RetryingPublisher struct {
+	publisher Publisher
+	policy    RetryPolicy
+}
+
+// NewRetryingPublisher wraps publisher with policy.
+func NewRetryingPublisher(publisher Publisher, policy RetryPolicy) *RetryingPublisher {
+	return &RetryingPublisher{publisher: publisher, policy: policy}
+}
+
+// Publish implements the Publisher interface.
+func (p *RetryingPublisher) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	return p.PublishBatch(ctx, []PendingEvent{newPendingEvent(ctx, webhook, correlationID)})[0]
+}
+
+// PublishBatch implements the BatchPublisher interface, retrying only the
+// events whose errors are retryable.
+func (p *RetryingPublisher) PublishBatch(ctx context.Context, events []PendingEvent) []error {
+	errs := publishAll(ctx, p.publisher, events)
+
+	pending := make([]int, 0, len(events))
+	backoff := p.policy.InitialBackoff
+	for attempt := 1; attempt < p.policy.MaxAttempts; attempt++ {
+		pending = pending[:0]
+		for i, err := range errs {
+			if IsRetryable(err) {
+				pending = append(pending, i)
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
+
+		Logger.Warn("Publish failed with retryable error, backing off",
+			"attempt", attempt,
+			"events", len(pending),
+			"backoff", backoff,
+			"error", errs[pending[0]])
+		if !sleepContext(ctx, jitter(backoff)) {
+			break
+		}
+
+		retry := make([]PendingEvent, len(pending))
+		for j, i := range pending {
+			retry[j] = events[i]
+		}
+		for j, err := range publishAll(ctx, p.publisher, retry) {
+			errs[pending[j]] = err
+		}
+
+		backoff = min(backoff*2, p.policy.MaxBackoff)
+	}
+	return errs
+}
+
+// Close implements the Publisher interface.
+func (p *RetryingPublisher) Close(ctx context.Context) error {
+	return p.publisher.Close(ctx)
+}
+
+// jitter returns a random duration between d/2 and d, so instances that
+// failed together don't retry in lockstep.
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + rand.N(d-half)
+}
+
+// sleepContext waits for d and reports whether it did so before ctx ended.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
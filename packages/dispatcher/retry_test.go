@@ -0,0 +1,139 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// scriptedPublisher returns the queued errors in order, then succeeds. A nil
+// entry publishes normally.
+type scriptedPublisher struct {
+	MockPublisher
+	errs  []error
+	calls int
+}
+
+func (s *scriptedPublisher) Publish(ctx context.Context, webhook WebhookRequest, correlationID string) error {
+	s.calls++
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	return s.MockPublisher.Publish(ctx, webhook, correlationID)
+}
+
+var testRetryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		name string
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "unavailable", err: status.Error(codes.Unavailable, "blip"), want: true},
+		{name: "wrapped unavailable", err: fmt.Errorf("failed to publish to PubSub: %w", status.Error(codes.Unavailable, "blip")), want: true},
+		{name: "resource exhausted", err: status.Error(codes.ResourceExhausted, "quota"), want: true},
+		{name: "permission denied", err: status.Error(codes.PermissionDenied, "no"), want: false},
+		{name: "not found", err: status.Error(codes.NotFound, "no topic"), want: false},
+		{name: "plain error", err: errors.New("failed to marshal webhook data"), want: false},
+		{name: "canceled", err: context.Canceled, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryingPublisher_RetriesTransientErrors(t *testing.T) {
+	inner := &scriptedPublisher{errs: []error{
+		status.Error(codes.Unavailable, "blip"),
+		status.Error(codes.Unavailable, "blip"),
+	}}
+	publisher := NewRetryingPublisher(inner, testRetryPolicy)
+
+	if err := publisher.Publish(context.Background(), WebhookRequest{ObjectID: 1}, "corr"); err != nil {
+		t.Fatalf("expected publish to succeed after retries, got %v", err)
+	}
+	if inner.calls != 3 || len(inner.Published) != 1 {
+		t.Errorf("expected 3 calls and 1 published, got %d calls and %d published", inner.calls, len(inner.Published))
+	}
+}
+
+func TestRetryingPublisher_GivesUpAfterMaxAttempts(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "down")
+	inner := &scriptedPublisher{errs: []error{unavailable, unavailable, unavailable, unavailable}}
+	publisher := NewRetryingPublisher(inner, testRetryPolicy)
+
+	if err := publisher.Publish(context.Background(), WebhookRequest{ObjectID: 1}, "corr"); !errors.Is(err, unavailable) {
+		t.Errorf("expected last error to be returned, got %v", err)
+	}
+	if inner.calls != testRetryPolicy.MaxAttempts {
+		t.Errorf("expected %d calls, got %d", testRetryPolicy.MaxAttempts, inner.calls)
+	}
+}
+
+func TestRetryingPublisher_DoesNotRetryPermanentErrors(t *testing.T) {
+	inner := &scriptedPublisher{errs: []error{status.Error(codes.PermissionDenied, "no")}}
+	publisher := NewRetryingPublisher(inner, testRetryPolicy)
+
+	if err := publisher.Publish(context.Background(), WebhookRequest{ObjectID: 1}, "corr"); err == nil {
+		t.Fatal("expected permanent error to be returned")
+	}
+	if inner.calls != 1 {
+		t.Errorf("expected a single attempt, got %d", inner.calls)
+	}
+}
+
+func TestRetryingPublisher_StopsWhenContextEnds(t *testing.T) {
+	inner := &scriptedPublisher{errs: []error{status.Error(codes.Unavailable, "blip")}}
+	publisher := NewRetryingPublisher(inner, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour, MaxBackoff: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := publisher.Publish(ctx, WebhookRequest{ObjectID: 1}, "corr"); err == nil {
+		t.Fatal("expected error when the context ends during backoff")
+	}
+	if inner.calls != 1 {
+		t.Errorf("expected no retry after the context ended, got %d calls", inner.calls)
+	}
+}
+
+func TestRetryingPublisher_RetriesOnlyFailedBatchEvents(t *testing.T) {
+	inner := &scriptedPublisher{errs: []error{nil, status.Error(codes.Unavailable, "blip")}}
+	publisher := NewRetryingPublisher(inner, testRetryPolicy)
+
+	events := []PendingEvent{{Webhook: WebhookRequest{ObjectID: 1}}, {Webhook: WebhookRequest{ObjectID: 2}}}
+	for i, err := range publisher.PublishBatch(context.Background(), events) {
+		if err != nil {
+			t.Errorf("event %d: expected success, got %v", i, err)
+		}
+	}
+	if inner.calls != 3 {
+		t.Errorf("expected only the failed event to be retried, got %d calls", inner.calls)
+	}
+	if len(inner.Published) != 2 || inner.Published[0].ObjectID != 1 || inner.Published[1].ObjectID != 2 {
+		t.Errorf("expected both events published once, got %+v", inner.Published)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for range 100 {
+		if got := jitter(100 * time.Millisecond); got < 50*time.Millisecond || got >= 100*time.Millisecond {
+			t.Fatalf("jitter out of range: %v", got)
+		}
+	}
+}
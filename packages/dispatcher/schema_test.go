@@ -0,0 +1,106 @@
+package dispatcher
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/andy-esch/desirelines/schemas/generated/go/events"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const webhookEventProto = "schemas/proto/webhook_event.proto"
+
+// TestWebhookRequestMatchesProto keeps WebhookRequest and the published
+// event schema from drifting apart on field names or types.
+func TestWebhookRequestMatchesProto(t *testing.T) {
+	event := (&events.WebhookEvent{}).ProtoReflect().Descriptor()
+
+	// Fields set only by optional enrichment are outside the contract.
+	assertMatchesProto(t, reflect.TypeOf(WebhookRequest{}), event, "activity")
+	assertMatchesProto(t, reflect.TypeOf(UpdateFields{}), event.Fields().ByName("update_fields").Message())
+}
+
+// TestWebhookRequestDecodesAsProto publishes a request the way the
+// dispatcher does and decodes it as Pub/Sub's JSON schema validation would.
+func TestWebhookRequestDecodesAsProto(t *testing.T) {
+	title, private := "Morning Ride", true
+	req := WebhookRequest{
+		AspectType:     AspectUpdate,
+		ObjectType:     ObjectActivity,
+		EventTime:      1700000000,
+		ObjectID:       12345,
+		OwnerID:        67890,
+		SubscriptionID: 42,
+		Updates:        map[string]any{UpdateTitle: title, UpdatePrivate: "true"},
+		UpdateFields:   &UpdateFields{Title: &title, Private: &private},
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("failed to marshal request: %v", err)
+	}
+
+	var event events.WebhookEvent
+	if err := protojson.Unmarshal(data, &event); err != nil {
+		t.Fatalf("published JSON does not match %s: %v", webhookEventProto, err)
+	}
+	if event.GetObjectId() != req.ObjectID || event.GetOwnerId() != req.OwnerID {
+		t.Errorf("ids = %d/%d, want %d/%d", event.GetObjectId(), event.GetOwnerId(), req.ObjectID, req.OwnerID)
+	}
+	if got := event.GetUpdates()[UpdateTitle]; got != title {
+		t.Errorf("updates[%q] = %q, want %q", UpdateTitle, got, title)
+	}
+	if fields := event.GetUpdateFields(); fields.GetTitle() != title || !fields.GetPrivate() || fields.Type != nil {
+		t.Errorf("update_fields = %v, want title and private only", fields)
+	}
+}
+
+func assertMatchesProto(t *testing.T, typ reflect.Type, message protoreflect.MessageDescriptor, skip ...string) {
+	t.Helper()
+
+	// Go kinds each proto kind may be published from.
+	compatible := map[protoreflect.Kind][]reflect.Kind{
+		protoreflect.StringKind:  {reflect.String},
+		protoreflect.BoolKind:    {reflect.Bool},
+		protoreflect.Int64Kind:   {reflect.Int, reflect.Int64},
+		protoreflect.MessageKind: {reflect.Struct},
+	}
+
+	goFields := map[string]bool{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if slices.Contains(skip, name) {
+			continue
+		}
+		goFields[name] = true
+
+		protoField := message.Fields().ByName(protoreflect.Name(name))
+		if protoField == nil {
+			t.Errorf("%s.%s (json %q) missing from %s", typ.Name(), field.Name, name, webhookEventProto)
+			continue
+		}
+		kind := field.Type.Kind()
+		if kind == reflect.Pointer {
+			kind = field.Type.Elem().Kind()
+		}
+		if protoField.IsMap() {
+			if kind != reflect.Map {
+				t.Errorf("%s.%s is %s, incompatible with proto map %s", typ.Name(), field.Name, field.Type, protoField.FullName())
+			}
+			continue
+		}
+		if !slices.Contains(compatible[protoField.Kind()], kind) {
+			t.Errorf("%s.%s is %s, incompatible with proto type %s", typ.Name(), field.Name, field.Type, protoField.Kind())
+		}
+	}
+
+	for i := 0; i < message.Fields().Len(); i++ {
+		if name := string(message.Fields().Get(i).Name()); !goFields[name] {
+			t.Errorf("proto field %q has no matching %s field", name, typ.Name())
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package dispatcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/andy-esch/desirelines/packages/config"
+)
+
+const (
+	// SecretSourceFile reads secrets from the mounted volume.
+	SecretSourceFile = "file"
+	// SecretSourceSecretManager reads secrets from the Secret Manager API.
+	SecretSourceSecretManager = "secret-manager"
+	// SecretSourceEnv reads secrets from the STRAVA_* environment variables.
+	SecretSourceEnv = "env"
+
+	// DefaultSecretSources is the SECRET_SOURCE precedence when unset: the
+	// mounted file, falling back to environment variables.
+	DefaultSecretSources = SecretSourceFile + "," + SecretSourceEnv
+
+	// secretManagerTimeout bounds a single Secret Manager access call.
+	secretManagerTimeout = 10 * time.Second
+)
+
+// SecretProvider supplies the Strava webhook secrets. Implementations cache
+// and refresh values themselves so callers can ask on every request.
+type SecretProvider interface {
+	GetSecrets() (string, int, error)
+	// AcceptsVerifyToken reports whether token is the current verify token
+	// or a rotated-out one still within its grace period.
+	AcceptsVerifyToken(token string) (bool, error)
+	// AcceptsSubscriptionID reports whether id is a configured subscription.
+	AcceptsSubscriptionID(id int) (bool, error)
+}
+
+// SecretStatus describes the secrets a provider has loaded, without
+// revealing them.
+type SecretStatus struct {
+	// LastReload is when the secrets content last changed; LastCheck when
+	// it was last compared.
+	LastReload time.Time `json:"last_reload"`
+	LastCheck  time.Time `json:"last_check"`
+	Source     string    `json:"source"`
+	// Location is the secrets file path or Secret Manager version name.
+	Location string `json:"location"`
+	// ContentHash is a prefix of the SHA-256 of the loaded secrets, enough to
+	// tell whether two instances loaded the same version.
+	ContentHash     string `json:"content_hash"`
+	SubscriptionIDs []int  `json:"subscription_ids"`
+}
+
+// secretStatusReporter is implemented by providers that can describe their
+// loaded state.
+type secretStatusReporter interface {
+	SecretStatus() SecretStatus
+}
+
+// shortHash truncates a hex content hash for display.
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// secretRefresher calls refresh on an interval from a background
+// goroutine until stopped.
+type secretRefresher struct {
+	done chan struct{}
+	quit chan struct{}
+}
+
+func startSecretRefresher(interval time.Duration, refresh func()) *secretRefresher {
+	r := &secretRefresher{done: make(chan struct{}), quit: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				refresh()
+			case <-r.quit:
+				return
+			}
+		}
+	}()
+	return r
+}
+
+// stop ends the refresh loop and waits for an in-flight refresh. It is a
+// no-op on a nil refresher.
+func (r *secretRefresher) stop() {
+	if r == nil {
+		return
+	}
+	close(r.quit)
+	<-r.done
+}
+
+// NewSecretProvider creates a CompositeSecretProvider over the sources
+// listed in SECRET_SOURCE, highest precedence first, e.g.
+// "secret-manager,file,env" (default DefaultSecretSources). The Secret
+// Manager source reads SECRET_MANAGER_SECRET, either a full
+// "projects/.../secrets/..." resource name or a secret ID in GCP_PROJECT_ID.
+// VERIFY_TOKEN_GRACE_PERIOD overrides how long a previous verify token is
+// accepted, and SECRET_REFRESH_INTERVAL, when set, reloads the secrets in
+// the background.
+func NewSecretProvider(ctx context.Context, cfg *Config) (*CompositeSecretProvider, error) {
+	grace, err := time.ParseDuration(config.GetOrDefault("VERIFY_TOKEN_GRACE_PERIOD", DefaultVerifyTokenGracePeriod.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid VERIFY_TOKEN_GRACE_PERIOD: %w", err)
+	}
+	refreshInterval, err := time.ParseDuration(config.GetOrDefault("SECRET_REFRESH_INTERVAL", "0s"))
+	if err != nil || refreshInterval < 0 {
+		return nil, fmt.Errorf("invalid SECRET_REFRESH_INTERVAL: %q", config.Get("SECRET_REFRESH_INTERVAL"))
+	}
+
+	var sources []namedSecretSource
+	for _, name := range strings.Split(config.GetOrDefault("SECRET_SOURCE", DefaultSecretSources), ",") {
+		name = strings.TrimSpace(name)
+		if slices.ContainsFunc(sources, func(s namedSecretSource) bool { return s.name == name }) {
+			return nil, fmt.Errorf("invalid SECRET_SOURCE: %s listed twice", name)
+		}
+		source, err := newSecretSource(ctx, name, cfg)
+		if err != nil {
+			_ = NewCompositeSecretProvider(sources...).Close()
+			return nil, err
+		}
+		sources = append(sources, namedSecretSource{source: source, name: name})
+	}
+
+	provider := NewCompositeSecretProvider(sources...)
+	provider.gracePeriod = grace
+	if refreshInterval > 0 {
+		provider.StartRefresh(refreshInterval)
+	}
+	return provider, nil
+}
+
+// newSecretSource creates the SECRET_SOURCE layer called name.
+func newSecretSource(ctx context.Context, name string, cfg *Config) (secretSource, error) {
+	switch name {
+	case SecretSourceFile:
+		return NewDefaultSecretCache(), nil
+	case SecretSourceEnv:
+		return newEnvSecretSource()
+	case SecretSourceSecretManager:
+		version, err := secretVersionName(config.GetOrDefault("SECRET_MANAGER_SECRET", ""), cfg.GCPProjectID)
+		if err != nil {
+			return nil, err
+		}
+		return NewSecretManagerProvider(ctx, version, DefaultSecretCacheTTL)
+	default:
+		return nil, fmt.Errorf("invalid SECRET_SOURCE: %s (expected a comma-separated list of %s, %s and %s)", name, SecretSourceSecretManager, SecretSourceFile, SecretSourceEnv)
+	}
+}
+
+// secretVersionName expands a secret ID into the resource name of its
+// latest version. Fully-qualified names are used as given, with
+// "/versions/latest" appended if no version is specified.
+func secretVersionName(secret, projectID string) (string, error) {
+	if secret == "" {
+		return "", fmt.Errorf("SECRET_MANAGER_SECRET is required when SECRET_SOURCE includes %s", SecretSourceSecretManager)
+	}
+	if !strings.HasPrefix(secret, "projects/") {
+		if projectID == "" {
+			return "", fmt.Errorf("GCP_PROJECT_ID is required to resolve secret %q", secret)
+		}
+		secret = fmt.Sprintf("projects/%s/secrets/%s", projectID, secret)
+	}
+	if !strings.Contains(secret, "/versions/") {
+		secret += "/versions/latest"
+	}
+	return secret, nil
+}
+
+// secretFetcher returns the payload of a secret version.
+type secretFetcher func(ctx context.Context, name string) ([]byte, error)
+
+// SecretManagerProvider reads the secrets JSON (same format as the mounted
+// file) from Secret Manager, caching it for a TTL so rotations are picked up
+// without redeploying or syncing files.
+type SecretManagerProvider struct {
+	lastCheck       time.Time
+	lastReload      time.Time
+	refresher       *secretRefresher
+	secrets         StravaSecrets
+	previous        previousToken
+	client          *secretmanager.Client
+	fetch           secretFetcher
+	name            string
+	contentHash     string
+	verifyToken     string
+	subscriptionIDs []int
+	ttl             time.Duration
+	gracePeriod     time.Duration
+	subscriptionID  int
+	mu              sync.RWMutex
+}
+
+// NewSecretManagerProvider creates a provider for the given secret version
+// resource name (e.g. "projects/p/secrets/strava-auth/versions/latest").
+func NewSecretManagerProvider(ctx context.Context, name string, ttl time.Duration) (*SecretManagerProvider, error) {
+	client, err := secretmanager.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret manager client: %w", err)
+	}
+
+	fetch := func(ctx context.Context, name string) ([]byte, error) {
+		resp, err := client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: name})
+		if err != nil {
+			return nil, err
+		}
+		return resp.GetPayload().GetData(), nil
+	}
+
+	provider := newSecretManagerProvider(name, ttl, fetch)
+	provider.client = client
+	return provider, nil
+}
+
+func newSecretManagerProvider(name string, ttl time.Duration, fetch secretFetcher) *SecretManagerProvider {
+	return &SecretManagerProvider{
+		fetch:       fetch,
+		name:        name,
+		ttl:         ttl,
+		gracePeriod: DefaultVerifyTokenGracePeriod,
+	}
+}
+
+// GetSecrets returns cached secrets or fetches them if the TTL expired.
+// On fetch failure previously loaded values are served, like SecretCache.
+func (p *SecretManagerProvider) GetSecrets() (string, int, error) {
+	p.mu.RLock()
+	now := time.Now()
+
+	if now.Sub(p.lastCheck) < p.ttl {
+		defer p.mu.RUnlock()
+		return p.verifyToken, p.subscriptionID, nil
+	}
+	p.mu.RUnlock()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.reload(now); err != nil {
+		return "", 0, err
+	}
+	return p.verifyToken, p.subscriptionID, nil
+}
+
+// StartRefresh fetches the secrets every interval in the background until
+// Close, like SecretCache.StartRefresh.
+func (p *SecretManagerProvider) StartRefresh(interval time.Duration) {
+	p.refresher = startSecretRefresher(interval, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		_ = p.reload(time.Now())
+	})
+}
+
+// reload fetches the secrets and loads them if they changed. Must be called
+// with p.mu held. It only fails when no secrets were ever loaded.
+func (p *SecretManagerProvider) reload(now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), secretManagerTimeout)
+	defer cancel()
+
+	payload, err := p.fetch(ctx, p.name)
+	if err == nil {
+		err = p.load(payload)
+	}
+	if err != nil {
+		secretReloads.WithLabelValues(SecretSourceSecretManager, "failure").Inc()
+		Logger.Error("Failed to load secrets from Secret Manager", "secret", p.name, "error", err)
+		if p.verifyToken != "" {
+			return nil
+		}
+		return fmt.Errorf("failed to load secrets from secret manager: %w", err)
+	}
+
+	p.lastCheck = now
+	return nil
+}
+
+// load parses payload and replaces the cached values if it changed.
+func (p *SecretManagerProvider) load(payload []byte) error {
+	hash := fmt.Sprintf("%x", sha256.Sum256(payload))
+	if hash == p.contentHash {
+		return nil
+	}
+
+	var secrets StravaSecrets
+	if err := json.Unmarshal(payload, &secrets); err != nil {
+		return fmt.Errorf("failed to parse secret payload: %w", err)
+	}
+
+	p.secrets = secrets
+	p.verifyToken = secrets.WebhookVerifyToken
+	p.subscriptionIDs = secrets.SubscriptionIDs()
+	p.subscriptionID = primarySubscriptionID(p.subscriptionIDs)
+	p.previous.update(secrets.PreviousWebhookVerifyToken, time.Now())
+	p.contentHash = hash
+	p.lastReload = time.Now()
+	secretReloads.WithLabelValues(SecretSourceSecretManager, "success").Inc()
+	Logger.Info("Secrets reloaded from Secret Manager", "secret", p.name)
+	return nil
+}
+
+// AcceptsVerifyToken reports whether token is the current verify token or
+// the previous one within its grace period.
+func (p *SecretManagerProvider) AcceptsVerifyToken(token string) (bool, error) {
+	verifyToken, _, err := p.GetSecrets()
+	if err != nil {
+		return false, err
+	}
+	if token == verifyToken {
+		return true, nil
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.previous.accepts(token, p.gracePeriod, time.Now()), nil
+}
+
+// AcceptsSubscriptionID reports whether id is one of the configured
+// webhook subscriptions.
+func (p *SecretManagerProvider) AcceptsSubscriptionID(id int) (bool, error) {
+	if _, _, err := p.GetSecrets(); err != nil {
+		return false, err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return slices.Contains(p.subscriptionIDs, id), nil
+}
+
+// Secrets returns everything loaded from Secret Manager, fetching it like
+// GetSecrets.
+func (p *SecretManagerProvider) Secrets() (StravaSecrets, error) {
+	if _, _, err := p.GetSecrets(); err != nil {
+		return StravaSecrets{}, err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.secrets, nil
+}
+
+// SecretStatus implements secretStatusReporter.
+func (p *SecretManagerProvider) SecretStatus() SecretStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return SecretStatus{
+		Source:          SecretSourceSecretManager,
+		Location:        p.name,
+		LastReload:      p.lastReload,
+		LastCheck:       p.lastCheck,
+		ContentHash:     shortHash(p.contentHash),
+		SubscriptionIDs: slices.Clone(p.subscriptionIDs),
+	}
+}
+
+// Close stops the background refresh, if started, and releases the Secret
+// Manager client.
+func (p *SecretManagerProvider) Close() error {
+	p.refresher.stop()
+	if p.client == nil {
+		return nil
+	}
+	return p.client.Close()
+}
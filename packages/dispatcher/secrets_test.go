@@ -0,0 +1,135 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSecretManagerProvider_GetSecrets(t *testing.T) {
+	payload := []byte(`{"webhook_verify_token":"sm-token","webhook_subscription_id":777}`)
+	var calls int
+	var fetchErr error
+	fetch := func(ctx context.Context, name string) ([]byte, error) {
+		calls++
+		if name != "projects/p/secrets/strava/versions/latest" {
+			t.Errorf("unexpected secret name %q", name)
+		}
+		return payload, fetchErr
+	}
+
+	provider := newSecretManagerProvider("projects/p/secrets/strava/versions/latest", time.Minute, fetch)
+
+	token, subID, err := provider.GetSecrets()
+	if err != nil {
+		t.Fatalf("GetSecrets failed: %v", err)
+	}
+	if token != "sm-token" || subID != 777 {
+		t.Errorf("unexpected secrets: token=%q subscription_id=%d", token, subID)
+	}
+
+	// Cached within TTL
+	if _, _, err := provider.GetSecrets(); err != nil {
+		t.Fatalf("GetSecrets failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 fetch within TTL, got %d", calls)
+	}
+
+	// Rotation is picked up after TTL expiry
+	payload = []byte(`{"webhook_verify_token":"rotated","webhook_subscription_id":777}`)
+	provider.lastCheck = time.Time{}
+	if token, _, _ := provider.GetSecrets(); token != "rotated" {
+		t.Errorf("expected rotated token, got %q", token)
+	}
+
+	// Fetch failures fall back to cached values
+	fetchErr = errors.New("permission denied")
+	provider.lastCheck = time.Time{}
+	token, _, err = provider.GetSecrets()
+	if err != nil || token != "rotated" {
+		t.Errorf("expected cached token on failure, got %q, %v", token, err)
+	}
+}
+
+func TestSecretManagerProvider_InitialFailure(t *testing.T) {
+	provider := newSecretManagerProvider("projects/p/secrets/strava/versions/latest", time.Minute,
+		func(ctx context.Context, name string) ([]byte, error) {
+			return []byte("not json"), nil
+		})
+
+	if _, _, err := provider.GetSecrets(); err == nil {
+		t.Error("expected error for unparseable payload with nothing cached")
+	}
+}
+
+func TestSecretVersionName(t *testing.T) {
+	tests := []struct {
+		secret    string
+		projectID string
+		want      string
+		wantErr   bool
+	}{
+		{"strava-auth", "my-project", "projects/my-project/secrets/strava-auth/versions/latest", false},
+		{"projects/other/secrets/strava-auth", "", "projects/other/secrets/strava-auth/versions/latest", false},
+		{"projects/other/secrets/strava-auth/versions/3", "", "projects/other/secrets/strava-auth/versions/3", false},
+		{"strava-auth", "", "", true},
+		{"", "my-project", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := secretVersionName(tt.secret, tt.projectID)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("secretVersionName(%q, %q) error = %v, wantErr %v", tt.secret, tt.projectID, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("secretVersionName(%q, %q) = %q, want %q", tt.secret, tt.projectID, got, tt.want)
+		}
+	}
+}
+
+func TestNewSecretProvider_InvalidSource(t *testing.T) {
+	t.Setenv("SECRET_SOURCE", "vault")
+
+	if _, err := NewSecretProvider(context.Background(), &Config{}); err == nil {
+		t.Error("expected error for unknown SECRET_SOURCE")
+	}
+}
+
+func TestSecretManagerProvider_StartRefresh(t *testing.T) {
+	fetched := make(chan struct{}, 1)
+	provider := newSecretManagerProvider("projects/p/secrets/strava/versions/latest", time.Hour,
+		func(ctx context.Context, name string) ([]byte, error) {
+			select {
+			case fetched <- struct{}{}:
+			default:
+			}
+			return []byte(`{"webhook_verify_token":"refreshed","webhook_subscription_id":777}`), nil
+		})
+	provider.StartRefresh(time.Millisecond)
+
+	select {
+	case <-fetched:
+	case <-time.After(time.Second):
+		t.Fatal("expected a background fetch")
+	}
+	if err := provider.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	select {
+	case <-fetched: // drain a fetch made before Close
+	default:
+	}
+
+	// Loaded without a request paying for it, and within the TTL
+	if token, _, err := provider.GetSecrets(); err != nil || token != "refreshed" {
+		t.Errorf("expected refreshed token, got %q, %v", token, err)
+	}
+	select {
+	case <-fetched:
+		t.Error("expected GetSecrets to serve the refreshed secrets without fetching")
+	default:
+	}
+}
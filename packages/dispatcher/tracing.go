@@ -0,0 +1,140 @@
+package dispatcher
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	texporter "github.com/GoogleCloudPlatform/opentelemetry-operations-go/exporter/trace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/andy-esch/desirelines/packages/dispatcher"
+
+// tracer creates the dispatcher's spans. It uses the global provider, so
+// spans are no-ops until SetupTracing installs one.
+var tracer = otel.Tracer(tracerName)
+
+// SetupTracing exports spans to Cloud Trace in projectID, sampling
+// sampleRatio of new traces (incoming sampled traces are always kept), and
+// propagates W3C trace context. The returned function flushes and stops the
+// exporter.
+func SetupTracing(ctx context.Context, projectID string, sampleRatio float64) (func(context.Context) error, error) {
+	exporter, err := texporter.New(texporter.WithProjectID(projectID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Trace exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	Logger.Info("Tracing enabled", "exporter", "cloud-trace", "sample_ratio", sampleRatio)
+
+	return provider.Shutdown, nil
+}
+
+// cloudTraceHeader is the Google Cloud load balancer's trace header,
+// "TRACE_ID/SPAN_ID;o=OPTIONS" with a decimal span ID.
+const cloudTraceHeader = "X-Cloud-Trace-Context"
+
+// requestTraceContext returns the request's context carrying the remote
+// span from its traceparent header, or else its X-Cloud-Trace-Context
+// header. Without either header the context is returned unchanged.
+func requestTraceContext(r *http.Request) context.Context {
+	ctx := propagation.TraceContext{}.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	if span, ok := parseCloudTraceContext(r.Header.Get(cloudTraceHeader)); ok {
+		return trace.ContextWithRemoteSpanContext(ctx, span)
+	}
+	return ctx
+}
+
+// parseCloudTraceContext parses an X-Cloud-Trace-Context header value.
+func parseCloudTraceContext(header string) (trace.SpanContext, bool) {
+	traceHex, rest, ok := strings.Cut(header, "/")
+	if !ok {
+		return trace.SpanContext{}, false
+	}
+	spanDecimal, options, _ := strings.Cut(rest, ";")
+	traceID, err := trace.TraceIDFromHex(traceHex)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	spanNumber, err := strconv.ParseUint(spanDecimal, 10, 64)
+	if err != nil || spanNumber == 0 {
+		return trace.SpanContext{}, false
+	}
+	var spanID trace.SpanID
+	binary.BigEndian.PutUint64(spanID[:], spanNumber)
+
+	config := trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, Remote: true}
+	if options == "o=1" {
+		config.TraceFlags = trace.FlagsSampled
+	}
+	return trace.NewSpanContext(config), true
+}
+
+// startEventSpan starts the server span for handling a webhook event,
+// continuing the request's trace (see requestTraceContext).
+func startEventSpan(r *http.Request, correlationID string) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	return tracer.Start(ctx, "handleEvent",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("correlation_id", correlationID),
+		))
+}
+
+// webhookSpanAttributes identifies the webhook's object on a span.
+func webhookSpanAttributes(webhook WebhookRequest) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int64("strava.object_id", webhook.ObjectID),
+		attribute.String("strava.object_type", webhook.ObjectType),
+		attribute.String("strava.aspect_type", webhook.AspectType),
+		attribute.Int64("strava.owner_id", webhook.OwnerID),
+	}
+}
+
+// startPublishSpan starts a producer span for publishing event to topic,
+// as a child of the span that received it, and injects the span's trace
+// context into attributes so consumers can continue the trace.
+func startPublishSpan(ctx context.Context, event PendingEvent, system, topic string, attributes map[string]string) trace.Span {
+	ctx, span := tracer.Start(eventContext(ctx, event), "publish "+topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", system),
+			attribute.String("messaging.destination.name", topic),
+			attribute.String("correlation_id", event.CorrelationID),
+		),
+		trace.WithAttributes(webhookSpanAttributes(event.Webhook)...))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(attributes))
+	return span
+}
+
+// recordSpanError marks span as failed with err.
+func recordSpanError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		recordSpanError(span, err)
+	}
+	span.End()
+}
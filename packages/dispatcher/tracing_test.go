@@ -0,0 +1,163 @@
+package dispatcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	testSpans     = tracetest.NewInMemoryExporter()
+	testTracerSet sync.Once
+)
+
+// useTestTracer records spans in memory. The provider is installed once,
+// since tracer keeps delegating to the first global provider set.
+func useTestTracer(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	testTracerSet.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(testSpans)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	testSpans.Reset()
+	return testSpans
+}
+
+func TestHandler_TracesEventThroughPublish(t *testing.T) {
+	exporter := useTestTracer(t)
+
+	secretsPath := filepath.Join(t.TempDir(), "strava_auth.json")
+	writeTestSecretsFile(t, secretsPath, map[string]any{
+		"webhook_verify_token":    "test-token",
+		"webhook_subscription_id": 12345,
+	})
+	var out bytes.Buffer
+	handler := NewHandlerWithPublisher(&Config{}, &LocalPublisher{out: &out, topic: "activity_events"})
+	handler.secrets = NewSecretCache(secretsPath, time.Minute)
+
+	const incomingTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	body := `{"aspect_type":"create","object_type":"activity","object_id":1,"owner_id":1,"event_time":1,"subscription_id":12345}`
+	req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("traceparent", "00-"+incomingTraceID+"-00f067aa0ba902b7-01")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body)
+	}
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	eventSpan, ok := spans["handleEvent"]
+	if !ok {
+		t.Fatalf("expected handleEvent span, got %v", spans)
+	}
+	publishSpan, ok := spans["publish activity_events"]
+	if !ok {
+		t.Fatalf("expected publish span, got %v", spans)
+	}
+	if eventSpan.SpanContext.TraceID().String() != incomingTraceID {
+		t.Errorf("expected handleEvent to continue the incoming trace, got %s", eventSpan.SpanContext.TraceID())
+	}
+	if publishSpan.Parent.SpanID() != eventSpan.SpanContext.SpanID() {
+		t.Errorf("expected publish span to be a child of handleEvent")
+	}
+
+	var record LocalRecord
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("invalid local record: %v", err)
+	}
+	want := "00-" + incomingTraceID + "-" + publishSpan.SpanContext.SpanID().String() + "-01"
+	if got := record.Attributes["traceparent"]; got != want {
+		t.Errorf("expected traceparent %q in message attributes, got %q", want, got)
+	}
+}
+
+func TestAsyncPublisher_KeepsEventTrace(t *testing.T) {
+	exporter := useTestTracer(t)
+
+	var out bytes.Buffer
+	publisher := NewAsyncPublisher(&LocalPublisher{out: &out, topic: "activity_events"}, 1)
+	ctx, span := tracer.Start(t.Context(), "handleEvent")
+	if err := publisher.Publish(ctx, WebhookRequest{ObjectID: 1}, "corr-1"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	span.End()
+	if err := publisher.Close(t.Context()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	for _, stub := range exporter.GetSpans() {
+		if stub.Name == "publish activity_events" {
+			if stub.Parent.SpanID() != span.SpanContext().SpanID() {
+				t.Error("expected queued publish to stay in the receiving trace")
+			}
+			return
+		}
+	}
+	t.Fatal("expected a publish span")
+}
+
+func TestRequestTraceContext(t *testing.T) {
+	tests := []struct {
+		name      string
+		headers   map[string]string
+		wantTrace string
+		wantSpan  string
+	}{
+		{
+			name:      "traceparent",
+			headers:   map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			wantTrace: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantSpan:  "00f067aa0ba902b7",
+		},
+		{
+			name:      "cloud trace header",
+			headers:   map[string]string{cloudTraceHeader: "105445aa7843bc8bf206b12000100000/255;o=1"},
+			wantTrace: "105445aa7843bc8bf206b12000100000",
+			wantSpan:  "00000000000000ff",
+		},
+		{
+			name: "traceparent wins",
+			headers: map[string]string{
+				"traceparent":    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				cloudTraceHeader: "105445aa7843bc8bf206b12000100000/255;o=1",
+			},
+			wantTrace: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantSpan:  "00f067aa0ba902b7",
+		},
+		{name: "malformed cloud trace header", headers: map[string]string{cloudTraceHeader: "not-a-trace/abc"}},
+		{name: "no headers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			span := trace.SpanContextFromContext(requestTraceContext(req))
+			if tt.wantTrace == "" {
+				if span.IsValid() {
+					t.Errorf("expected no span, got %v", span)
+				}
+				return
+			}
+			if span.TraceID().String() != tt.wantTrace || span.SpanID().String() != tt.wantSpan {
+				t.Errorf("got trace %s span %s, want %s %s", span.TraceID(), span.SpanID(), tt.wantTrace, tt.wantSpan)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package dispatcher
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Keys Strava sends in a webhook's updates.
+const (
+	UpdateTitle      = "title"
+	UpdateType       = "type"
+	UpdatePrivate    = "private"
+	UpdateVisibility = "visibility"
+	UpdateAuthorized = "authorized"
+)
+
+// UpdateFields is the typed form of a webhook's updates. Strava sends
+// every value as a string ("private": "true"); fields absent from the
+// updates are nil.
+type UpdateFields struct {
+	// Title is the activity's new name.
+	Title *string `json:"title,omitempty"`
+	// Type is the activity's new sport type, e.g. "Ride".
+	Type *string `json:"type,omitempty"`
+	// Private reports whether the activity is now visible only to its owner.
+	Private *bool `json:"private,omitempty"`
+	// Visibility is the activity's new visibility, e.g. "followers_only".
+	Visibility *string `json:"visibility,omitempty"`
+	// Authorized is false when the athlete revoked access.
+	Authorized *bool `json:"authorized,omitempty"`
+}
+
+// ParseUpdateFields parses the known keys of updates, accepting Strava's
+// string booleans as well as JSON booleans. Unknown keys are ignored.
+func ParseUpdateFields(updates map[string]any) (UpdateFields, error) {
+	var fields UpdateFields
+	var err error
+	if fields.Title, err = updateString(updates, UpdateTitle); err != nil {
+		return UpdateFields{}, err
+	}
+	if fields.Type, err = updateString(updates, UpdateType); err != nil {
+		return UpdateFields{}, err
+	}
+	if fields.Visibility, err = updateString(updates, UpdateVisibility); err != nil {
+		return UpdateFields{}, err
+	}
+	if fields.Private, err = updateBool(updates, UpdatePrivate); err != nil {
+		return UpdateFields{}, err
+	}
+	if fields.Authorized, err = updateBool(updates, UpdateAuthorized); err != nil {
+		return UpdateFields{}, err
+	}
+	return fields, nil
+}
+
+// IsEmpty reports whether no known update keys were present.
+func (f UpdateFields) IsEmpty() bool {
+	return f == UpdateFields{}
+}
+
+// IsDeauthorization reports whether the updates revoke the app's access.
+func (f UpdateFields) IsDeauthorization() bool {
+	return f.Authorized != nil && !*f.Authorized
+}
+
+// updateString returns updates[key] as a string, or nil when absent.
+func updateString(updates map[string]any, key string) (*string, error) {
+	value, ok := updates[key]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid updates.%s: expected string, got %T", key, value)
+	}
+	return &s, nil
+}
+
+// updateBool returns updates[key] as a bool, or nil when absent.
+func updateBool(updates map[string]any, key string) (*bool, error) {
+	value, ok := updates[key]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	switch v := value.(type) {
+	case bool:
+		return &v, nil
+	case string:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid updates.%s: %q is not a boolean", key, v)
+		}
+		return &b, nil
+	default:
+		return nil, fmt.Errorf("invalid updates.%s: expected boolean, got %T", key, value)
+	}
+}
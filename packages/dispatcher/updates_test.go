@@ -0,0 +1,56 @@
+package dispatcher
+
+import "testing"
+
+func TestParseUpdateFields(t *testing.T) {
+	fields, err := ParseUpdateFields(map[string]any{
+		"title":      "Evening Ride",
+		"type":       "Ride",
+		"private":    "false",
+		"visibility": "followers_only",
+		"unknown":    42,
+	})
+	if err != nil {
+		t.Fatalf("ParseUpdateFields failed: %v", err)
+	}
+	if *fields.Title != "Evening Ride" || *fields.Type != "Ride" || *fields.Visibility != "followers_only" {
+		t.Errorf("unexpected string fields: %+v", fields)
+	}
+	if fields.Private == nil || *fields.Private {
+		t.Errorf("expected private=false, got %v", fields.Private)
+	}
+	if fields.Authorized != nil || fields.IsDeauthorization() {
+		t.Error("expected authorized to be absent")
+	}
+}
+
+func TestParseUpdateFields_Deauthorization(t *testing.T) {
+	for _, value := range []any{"false", false} {
+		fields, err := ParseUpdateFields(map[string]any{"authorized": value})
+		if err != nil {
+			t.Fatalf("ParseUpdateFields(%v) failed: %v", value, err)
+		}
+		if !fields.IsDeauthorization() {
+			t.Errorf("expected authorized=%v to be a deauthorization", value)
+		}
+	}
+}
+
+func TestParseUpdateFields_Empty(t *testing.T) {
+	fields, err := ParseUpdateFields(nil)
+	if err != nil || !fields.IsEmpty() {
+		t.Errorf("expected empty fields, got %+v, %v", fields, err)
+	}
+}
+
+func TestParseUpdateFields_Invalid(t *testing.T) {
+	for _, updates := range []map[string]any{
+		{"private": "maybe"},
+		{"authorized": 1},
+		{"title": 12},
+	} {
+		if _, err := ParseUpdateFields(updates); err == nil {
+			t.Errorf("expected error for %v", updates)
+		}
+	}
+}
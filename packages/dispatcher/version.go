@@ -0,0 +1,81 @@
+package dispatcher
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Build metadata, set at link time:
+//
+//	go build -ldflags "-X github.com/andy-esch/desirelines/packages/dispatcher.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/andy-esch/desirelines/packages/dispatcher.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Unset values fall back to what the Go toolchain embedded in the binary.
+var (
+	Version   string
+	Commit    string
+	BuildTime string
+)
+
+const modulePath = "github.com/andy-esch/desirelines/packages/dispatcher"
+
+// unknownBuildValue stands in for metadata neither the linker nor the
+// toolchain provided.
+const unknownBuildValue = "unknown"
+
+// BuildInfo identifies the running revision, as served at /version.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+	// Modified is set when the binary was built from an uncommitted tree.
+	Modified bool `json:"modified,omitempty"`
+}
+
+// CurrentBuild returns the build metadata, read once.
+var CurrentBuild = sync.OnceValue(func() BuildInfo {
+	info := BuildInfo{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if embedded, ok := debug.ReadBuildInfo(); ok {
+		info.fillFrom(embedded)
+	}
+	for _, field := range []*string{&info.Version, &info.Commit, &info.BuildTime} {
+		if *field == "" {
+			*field = unknownBuildValue
+		}
+	}
+	return info
+})
+
+// fillFrom completes unset fields from the toolchain's build info: the
+// module version (this package is the main module locally and a dependency
+// in the Cloud Function) and, when built inside a git checkout, the VCS
+// revision and commit time.
+func (b *BuildInfo) fillFrom(embedded *debug.BuildInfo) {
+	if b.Version == "" {
+		module := &embedded.Main
+		for _, dep := range embedded.Deps {
+			if dep.Path == modulePath {
+				module = dep
+			}
+		}
+		if module.Path == modulePath && module.Version != "(devel)" {
+			b.Version = module.Version
+		}
+	}
+	for _, setting := range embedded.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if b.Commit == "" {
+				b.Commit = setting.Value
+			}
+		case "vcs.time":
+			if b.BuildTime == "" {
+				b.BuildTime = setting.Value
+			}
+		case "vcs.modified":
+			b.Modified = setting.Value == "true"
+		}
+	}
+}
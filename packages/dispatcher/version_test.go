@@ -0,0 +1,54 @@
+package dispatcher
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"runtime/debug"
+	"testing"
+)
+
+func TestBuildInfo_FillFrom(t *testing.T) {
+	embedded := &debug.BuildInfo{
+		Main: debug.Module{Path: "github.com/andy-esch/desirelines/dispatcher-function", Version: "(devel)"},
+		Deps: []*debug.Module{{Path: modulePath, Version: "v1.2.3"}},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abc123"},
+			{Key: "vcs.time", Value: "2024-03-05T23:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+
+	var info BuildInfo
+	info.fillFrom(embedded)
+	want := BuildInfo{Version: "v1.2.3", Commit: "abc123", BuildTime: "2024-03-05T23:00:00Z", Modified: true}
+	if info != want {
+		t.Errorf("got %+v, want %+v", info, want)
+	}
+
+	// Link-time values win over the embedded ones
+	info = BuildInfo{Commit: "def456", BuildTime: "2024-03-06T00:00:00Z"}
+	info.fillFrom(embedded)
+	if info.Commit != "def456" || info.BuildTime != "2024-03-06T00:00:00Z" {
+		t.Errorf("expected link-time values to be kept, got %+v", info)
+	}
+}
+
+func TestHandler_Version(t *testing.T) {
+	handler := NewHandlerWithPublisher(&Config{}, &MockPublisher{})
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", rr.Code, http.StatusOK)
+	}
+	var info BuildInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if info.GoVersion != runtime.Version() || info.Commit == "" || info.Version == "" {
+		t.Errorf("unexpected build info %+v", info)
+	}
+}
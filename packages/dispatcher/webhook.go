@@ -1,6 +1,7 @@
 package dispatcher
 
 import (
+	"encoding/json"
 	"fmt"
 	"slices"
 )
@@ -18,13 +19,19 @@ const (
 
 // WebhookRequest represents the Strava webhook payload structure
 type WebhookRequest struct {
-	Updates        map[string]any `json:"updates"`
-	AspectType     string         `json:"aspect_type"`
-	ObjectType     string         `json:"object_type"`
-	EventTime      int64          `json:"event_time"`
-	ObjectID       int64          `json:"object_id"`
-	OwnerID        int64          `json:"owner_id"`
-	SubscriptionID int            `json:"subscription_id"`
+	Updates map[string]any `json:"updates"`
+	// Activity is the detailed Strava activity, set only by the enricher
+	// (see EnrichingPublisher); it is never read from incoming webhooks.
+	Activity json.RawMessage `json:"activity,omitempty"`
+	// UpdateFields is Updates parsed into typed values by the dispatcher;
+	// nil when Updates holds none of the known keys.
+	UpdateFields   *UpdateFields `json:"update_fields,omitempty"`
+	AspectType     string        `json:"aspect_type"`
+	ObjectType     string        `json:"object_type"`
+	EventTime      int64         `json:"event_time"`
+	ObjectID       int64         `json:"object_id"`
+	OwnerID        int64         `json:"owner_id"`
+	SubscriptionID int           `json:"subscription_id"`
 }
 
 // Validate validates the webhook request fields
@@ -0,0 +1,160 @@
+// Package ipfilter decides which client addresses may call a service and
+// which proxies' X-Forwarded-For headers name the client, for the webhook
+// dispatcher and the API gateway.
+package ipfilter
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Filter admits or rejects requests by client address (see
+// TrustedProxies.ClientIP). Deny entries win over allow entries; an empty
+// allowlist admits every address not denied.
+type Filter struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// Parse builds a filter from comma-separated CIDR lists (bare IPs
+// are treated as single-address prefixes). It returns nil when both lists
+// are empty so callers can skip filtering entirely.
+func Parse(allowlist, denylist string) (*Filter, error) {
+	allow, err := parsePrefixes(allowlist)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allowlist: %w", err)
+	}
+	deny, err := parsePrefixes(denylist)
+	if err != nil {
+		return nil, fmt.Errorf("invalid denylist: %w", err)
+	}
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+	return &Filter{allow: allow, deny: deny}, nil
+}
+
+func parsePrefixes(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// String describes the filter as "allow=<prefixes> deny=<prefixes>".
+func (f *Filter) String() string {
+	join := func(prefixes []netip.Prefix) string {
+		entries := make([]string, len(prefixes))
+		for i, prefix := range prefixes {
+			entries[i] = prefix.String()
+		}
+		return strings.Join(entries, ",")
+	}
+	return fmt.Sprintf("allow=%s deny=%s", join(f.allow), join(f.deny))
+}
+
+// Allowed reports whether a request from addr may proceed. Addresses that
+// cannot be parsed are only admitted when no allowlist is configured.
+func (f *Filter) Allowed(addr string) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return len(f.allow) == 0
+	}
+	ip = ip.Unmap()
+
+	for _, prefix := range f.deny {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, prefix := range f.allow {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// TrustedProxies are the load balancers and reverse proxies whose
+// X-Forwarded-For entries are believed. Anyone else can send the header,
+// so an empty list takes the connection's address as the client's.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies builds the list from comma-separated CIDRs or IPs.
+func ParseTrustedProxies(list string) (TrustedProxies, error) {
+	return parsePrefixes(list)
+}
+
+// String describes the list as comma-separated prefixes.
+func (t TrustedProxies) String() string {
+	entries := make([]string, len(t))
+	for i, prefix := range t {
+		entries[i] = prefix.String()
+	}
+	return strings.Join(entries, ",")
+}
+
+// trusts reports whether addr is one of the proxies.
+func (t TrustedProxies) trusts(addr string) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, prefix := range t {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the caller's address. A request from a trusted proxy is
+// attributed to the right-most X-Forwarded-For entry that isn't a trusted
+// proxy too: each proxy appends the address it saw, and entries left of
+// the first untrusted one are client-controlled.
+func (t TrustedProxies) ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !t.trusts(ip) {
+		return ip
+	}
+
+	entries := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := strings.TrimSpace(entries[i])
+		if entry == "" {
+			break
+		}
+		ip = entry
+		if !t.trusts(ip) {
+			break
+		}
+	}
+	return ip
+}
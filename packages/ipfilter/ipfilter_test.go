@@ -0,0 +1,110 @@
+package ipfilter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFilter_Allowed(t *testing.T) {
+	filter, err := Parse("203.0.113.0/24, 2001:db8::/32, 198.51.100.9", "203.0.113.66")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"203.0.113.10", true},
+		{"198.51.100.9", true},
+		{"2001:db8::1", true},
+		{"::ffff:203.0.113.10", true},
+		{"203.0.113.66", false},
+		{"192.0.2.1", false},
+		{"not-an-ip", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := filter.Allowed(tt.addr); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	filter, err := Parse("", " ")
+	if err != nil || filter != nil {
+		t.Errorf("expected nil filter for empty lists, got %v, %v", filter, err)
+	}
+
+	denyOnly, err := Parse("", "10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !denyOnly.Allowed("192.0.2.1") || denyOnly.Allowed("10.1.2.3") || !denyOnly.Allowed("not-an-ip") {
+		t.Error("deny-only filter should admit everything outside the denylist")
+	}
+
+	for _, list := range []string{"10.0.0.0/33", "192.168.1.0/24,bogus"} {
+		if _, err := Parse(list, ""); err == nil {
+			t.Errorf("expected error for %q", list)
+		}
+	}
+}
+
+func TestFilter_String(t *testing.T) {
+	filter, err := Parse("203.0.113.7/24, 198.51.100.9", "")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := filter.String(); got != "allow=203.0.113.0/24,198.51.100.9/32 deny=" {
+		t.Errorf("unexpected description %q", got)
+	}
+}
+
+func TestTrustedProxies_ClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.7")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		proxies   TrustedProxies
+		remote    string
+		forwarded string
+		want      string
+	}{
+		{"direct", proxies, "198.51.100.2:1234", "", "198.51.100.2"},
+		{"direct with spoofed header", proxies, "198.51.100.2:1234", "203.0.113.5", "198.51.100.2"},
+		{"nothing trusted", nil, "10.1.2.3:1234", "203.0.113.5", "10.1.2.3"},
+		{"behind a proxy", proxies, "10.1.2.3:1234", "203.0.113.5", "203.0.113.5"},
+		{"spoofed prefix ignored", proxies, "10.1.2.3:1234", "198.51.100.9, 203.0.113.5", "203.0.113.5"},
+		{"chain of proxies", proxies, "10.1.2.3:1234", "198.51.100.9, 203.0.113.5, 192.0.2.7", "203.0.113.5"},
+		{"proxy without header", proxies, "10.1.2.3:1234", "", "10.1.2.3"},
+		{"mapped address", proxies, "[::ffff:10.1.2.3]:1234", "203.0.113.5", "203.0.113.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := tt.proxies.ClientIP(req); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	if got := proxies.String(); got != "10.0.0.0/8,192.0.2.7/32" {
+		t.Errorf("unexpected description %q", got)
+	}
+	if _, err := ParseTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+}
@@ -0,0 +1,145 @@
+// Package listener opens the socket a local server accepts connections on,
+// from an address that can name more than a TCP port, for running behind
+// a reverse proxy on the same host:
+//
+//	:8080                       every interface, port 8080
+//	127.0.0.1:8080, [::1]:8080  one interface
+//	unix:/run/desirelines.sock  a Unix domain socket
+//	systemd                     the socket systemd passed, with socket activation
+//
+// The commands read the address from LISTEN_ADDR, or a -listen flag, and
+// fall back to ":$PORT", then run their server on it with Serve.
+package listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Address forms besides host:port.
+const (
+	unixPrefix = "unix:"
+	systemd    = "systemd"
+)
+
+// socketMode lets the proxy's group connect to a Unix socket as well as
+// the server's user.
+const socketMode fs.FileMode = 0o660
+
+// listenFDsStart is the first file descriptor systemd passes (SD_LISTEN_FDS_START).
+var listenFDsStart = 3
+
+// Listen opens a listener on addr.
+func Listen(addr string) (net.Listener, error) {
+	switch {
+	case addr == systemd:
+		return activated()
+	case strings.HasPrefix(addr, unixPrefix):
+		return listenUnix(strings.TrimPrefix(addr, unixPrefix))
+	default:
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		return ln, nil
+	}
+}
+
+// listenUnix listens on a Unix socket at path, replacing one a previous
+// run left behind. The socket is removed when the listener is closed.
+func listenUnix(path string) (net.Listener, error) {
+	if path == "" {
+		return nil, errors.New("unix listen address needs a socket path")
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode()&fs.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, socketMode); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("failed to set permissions of %s: %w", path, err)
+	}
+	return ln, nil
+}
+
+// activated returns the socket systemd passed to the process, as described
+// in sd_listen_fds(3). The LISTEN_* variables are cleared so processes the
+// server starts don't take the socket for theirs.
+func activated() (net.Listener, error) {
+	pid, fds := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS")
+	for _, key := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		_ = os.Unsetenv(key)
+	}
+
+	if fds == "" {
+		return nil, errors.New("no socket passed by systemd: LISTEN_FDS is not set")
+	}
+	if pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil, fmt.Errorf("sockets passed by systemd are for process %s", pid)
+	}
+	count, err := strconv.Atoi(fds)
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS: %q", fds)
+	}
+	if count > 1 {
+		return nil, fmt.Errorf("systemd passed %d sockets, expected one", count)
+	}
+
+	file := os.NewFile(uintptr(listenFDsStart), "systemd socket")
+	// FileListener duplicates the descriptor
+	defer file.Close()
+	ln, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use the socket passed by systemd: %w", err)
+	}
+	return ln, nil
+}
+
+// Serve serves srv on ln until ctx is done, then shuts it down, giving
+// in-flight requests up to shutdownTimeout to finish. With certFile and
+// keyFile it serves HTTPS, and net/http negotiates HTTP/2. It returns nil
+// once the server has shut down cleanly, or the error that stopped it.
+func Serve(ctx context.Context, srv *http.Server, ln net.Listener, certFile, keyFile string, shutdownTimeout time.Duration) error {
+	if (certFile == "") != (keyFile == "") {
+		_ = ln.Close()
+		return errors.New("TLS certificate and key files must be set together")
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		if certFile != "" {
+			served <- srv.ServeTLS(ln, certFile, keyFile)
+		} else {
+			served <- srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-served:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package listener
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestListen_TCP(t *testing.T) {
+	ln, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln.Close()
+	if addr := ln.Addr().(*net.TCPAddr); !addr.IP.IsLoopback() || addr.Port == 0 {
+		t.Errorf("expected a loopback port, got %s", addr)
+	}
+
+	if _, err := Listen("not-an-address"); err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+}
+
+func TestListen_Unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.sock")
+	ln, err := Listen("unix:" + path)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected the socket created: %v", err)
+	}
+	if info.Mode().Perm() != socketMode {
+		t.Errorf("expected mode %s, got %s", socketMode, info.Mode().Perm())
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	_ = conn.Close()
+	_ = ln.Close()
+
+	// A socket left behind by a crashed run is replaced
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatalf("ListenUnix failed: %v", err)
+	}
+	stale.SetUnlinkOnClose(false)
+	_ = stale.Close()
+	ln, err = Listen("unix:" + path)
+	if err != nil {
+		t.Fatalf("expected a stale socket replaced, got %v", err)
+	}
+	_ = ln.Close()
+
+	// Anything else at the path is left alone
+	file := filepath.Join(t.TempDir(), "data.json")
+	if err := os.WriteFile(file, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen("unix:" + file); err == nil {
+		t.Error("expected an error for a path that isn't a socket")
+	}
+	if _, err := Listen("unix:"); err == nil {
+		t.Error("expected an error without a socket path")
+	}
+}
+
+func TestListen_Systemd(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	file, err := tcp.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	// Listen takes over the descriptor it is passed, as systemd's
+	fd, err := syscall.Dup(int(file.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(start int) { listenFDsStart = start }(listenFDsStart)
+	listenFDsStart = fd
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	ln, err := Listen("systemd")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln.Close()
+	if ln.Addr().String() != tcp.Addr().String() {
+		t.Errorf("expected the passed socket %s, got %s", tcp.Addr(), ln.Addr())
+	}
+	if _, ok := os.LookupEnv("LISTEN_FDS"); ok {
+		t.Error("expected LISTEN_FDS cleared")
+	}
+
+	tests := []struct {
+		name, pid, fds, want string
+	}{
+		{"not activated", "", "", "not set"},
+		{"another process", "1", "1", "for process 1"},
+		{"several sockets", "", "2", "expected one"},
+		{"invalid", "", "none", "invalid LISTEN_FDS"},
+	}
+	for _, tt := range tests {
+		t.Setenv("LISTEN_PID", tt.pid)
+		t.Setenv("LISTEN_FDS", tt.fds)
+		if _, err := Listen("systemd"); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	ln, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = io.WriteString(w, "done")
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- Serve(ctx, srv, ln, "", "", time.Second) }()
+
+	// A request in flight when ctx is done still gets its response
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		body <- string(data)
+	}()
+	<-started
+	cancel()
+	close(release)
+
+	if got := <-body; got != "done" {
+		t.Errorf("expected the in-flight request to finish, got %q", got)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("expected a clean shutdown, got %v", err)
+	}
+}
+
+func TestServe_Errors(t *testing.T) {
+	ln, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	if err := Serve(context.Background(), &http.Server{}, ln, "cert.pem", "", time.Second); err == nil {
+		t.Error("expected an error for a certificate without a key")
+	}
+
+	ln, err = Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	if err := Serve(context.Background(), &http.Server{}, ln, "missing.pem", "missing.key", time.Second); err == nil {
+		t.Error("expected an error for a missing certificate")
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/andy-esch/desirelines/packages/logging
+
+go 1.25
@@ -0,0 +1,63 @@
+// Package logging sets up the structured loggers of the services that run
+// as Cloud Functions: JSON entries with the field names and severities
+// Cloud Logging expects, so it parses them instead of logging raw text.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// New returns a logger writing JSON entries to w at level and above, with
+// the message, level and time under Cloud Logging's message, severity
+// and timestamp fields.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// Don't modify attributes in nested groups
+			if groups != nil {
+				return a
+			}
+			switch a.Key {
+			case slog.MessageKey:
+				a.Key = "message"
+			case slog.LevelKey:
+				a.Key = "severity"
+				a.Value = slog.StringValue(Severity(a.Value.Any().(slog.Level)))
+			case slog.TimeKey:
+				a.Key = "timestamp"
+			}
+			return a
+		},
+	}))
+}
+
+// Severity maps a slog level to its Google Cloud severity string.
+func Severity(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return "DEBUG"
+	case level < slog.LevelWarn:
+		return "INFO"
+	case level < slog.LevelError:
+		return "WARNING"
+	default:
+		return "ERROR"
+	}
+}
+
+// ParseLevel parses a level name: DEBUG, INFO, WARNING (or WARN) or ERROR,
+// in any case.
+func ParseLevel(name string) (slog.Level, error) {
+	if strings.EqualFold(name, "WARNING") {
+		name = "WARN"
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return level, fmt.Errorf("unknown log level %q", name)
+	}
+	return level, nil
+}
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var out bytes.Buffer
+	level := new(slog.LevelVar)
+	logger := New(&out, level)
+
+	logger.Debug("hidden")
+	logger.Warn("Low on quota", "remaining", 3, slog.Group("request", "level", "nested"))
+
+	var entry map[string]any
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("expected one JSON entry, got %q: %v", out.String(), err)
+	}
+	if entry["message"] != "Low on quota" || entry["severity"] != "WARNING" || entry["timestamp"] == nil || entry["remaining"] != 3.0 {
+		t.Errorf("unexpected entry %v", entry)
+	}
+	if group, _ := entry["request"].(map[string]any); group["level"] != "nested" {
+		t.Errorf("expected nested attributes left alone, got %v", entry["request"])
+	}
+
+	out.Reset()
+	level.Set(slog.LevelDebug)
+	logger.Debug("shown")
+	if !bytes.Contains(out.Bytes(), []byte(`"severity":"DEBUG"`)) {
+		t.Errorf("expected the level to apply when changed, got %q", out.String())
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		want slog.Level
+	}{
+		{"DEBUG", slog.LevelDebug},
+		{"info", slog.LevelInfo},
+		{"Warning", slog.LevelWarn},
+		{"WARN", slog.LevelWarn},
+		{"error", slog.LevelError},
+	}
+	for _, tt := range tests {
+		if got, err := ParseLevel(tt.name); err != nil || got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	tests := []struct {
+		level slog.Level
+		want  string
+	}{
+		{slog.LevelDebug, "DEBUG"},
+		{slog.LevelInfo, "INFO"},
+		{slog.LevelInfo + 1, "INFO"},
+		{slog.LevelWarn, "WARNING"},
+		{slog.LevelError, "ERROR"},
+		{slog.LevelError + 4, "ERROR"},
+	}
+	for _, tt := range tests {
+		if got := Severity(tt.level); got != tt.want {
+			t.Errorf("Severity(%v) = %q; want %q", tt.level, got, tt.want)
+		}
+	}
+}
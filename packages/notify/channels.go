@@ -0,0 +1,210 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Channel names.
+const (
+	ChannelEmail    = "email"
+	ChannelPushover = "pushover"
+	ChannelWebhook  = "webhook"
+)
+
+const (
+	// DefaultSendGridURL is SendGrid's v3 mail endpoint.
+	DefaultSendGridURL = "https://api.sendgrid.com/v3/mail/send"
+	// DefaultPushoverURL is Pushover's message endpoint.
+	DefaultPushoverURL = "https://api.pushover.net/1/messages.json"
+	// SignatureHeader carries the hex HMAC-SHA256 of a webhook's body,
+	// prefixed with "sha256=", when a webhook secret is configured.
+	SignatureHeader = "X-Desirelines-Signature"
+)
+
+// defaultTimeout bounds each delivery, so a slow provider doesn't hold up
+// the event that reached the milestone.
+const defaultTimeout = 10 * time.Second
+
+// maxErrorBodyBytes bounds how much of a failed response is reported.
+const maxErrorBodyBytes = 512
+
+// sender posts to a provider's endpoint.
+type sender struct {
+	httpClient *http.Client
+	url        string
+}
+
+// Option configures a channel.
+type Option func(*sender)
+
+// WithHTTPClient sends with httpClient instead of one with a ten second
+// timeout.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(s *sender) {
+		s.httpClient = httpClient
+	}
+}
+
+// WithURL sends to endpoint instead of the provider's endpoint (useful for
+// testing).
+func WithURL(endpoint string) Option {
+	return func(s *sender) {
+		s.url = endpoint
+	}
+}
+
+func newSender(defaultURL string, opts []Option) sender {
+	s := sender{httpClient: &http.Client{Timeout: defaultTimeout}, url: defaultURL}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// post sends body to endpoint, failing on a non-2xx response.
+func (s sender) post(ctx context.Context, endpoint, contentType string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// SendGrid emails milestones through SendGrid to the settings' email.
+type SendGrid struct {
+	apiKey string
+	from   string
+	sender
+}
+
+// NewSendGrid creates an email channel sending from the address from with
+// a SendGrid API key allowed to send mail.
+func NewSendGrid(apiKey, from string, opts ...Option) *SendGrid {
+	return &SendGrid{apiKey: apiKey, from: from, sender: newSender(DefaultSendGridURL, opts)}
+}
+
+// Name implements the Channel interface.
+func (c *SendGrid) Name() string { return ChannelEmail }
+
+// Configured implements the Channel interface.
+func (c *SendGrid) Configured(settings Settings) bool { return settings.Email != "" }
+
+// Send implements the Channel interface.
+func (c *SendGrid) Send(ctx context.Context, settings Settings, milestone Milestone) error {
+	type address struct {
+		Email string `json:"email"`
+	}
+	type content struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}
+	body, err := json.Marshal(struct {
+		Personalizations []map[string][]address `json:"personalizations"`
+		From             address                `json:"from"`
+		Subject          string                 `json:"subject"`
+		Content          []content              `json:"content"`
+	}{
+		Personalizations: []map[string][]address{{"to": {{Email: settings.Email}}}},
+		From:             address{Email: c.from},
+		Subject:          milestone.Title(),
+		Content:          []content{{Type: "text/plain", Value: milestone.Message()}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode email: %w", err)
+	}
+	return c.post(ctx, c.url, "application/json", body, http.Header{"Authorization": {"Bearer " + c.apiKey}})
+}
+
+// Pushover pushes milestones through Pushover to the settings' user key.
+type Pushover struct {
+	appToken string
+	sender
+}
+
+// NewPushover creates a Pushover channel for the application's token.
+func NewPushover(appToken string, opts ...Option) *Pushover {
+	return &Pushover{appToken: appToken, sender: newSender(DefaultPushoverURL, opts)}
+}
+
+// Name implements the Channel interface.
+func (c *Pushover) Name() string { return ChannelPushover }
+
+// Configured implements the Channel interface.
+func (c *Pushover) Configured(settings Settings) bool { return settings.PushoverUser != "" }
+
+// Send implements the Channel interface.
+func (c *Pushover) Send(ctx context.Context, settings Settings, milestone Milestone) error {
+	form := url.Values{
+		"token":   {c.appToken},
+		"user":    {settings.PushoverUser},
+		"title":   {milestone.Title()},
+		"message": {milestone.Message()},
+	}
+	return c.post(ctx, c.url, "application/x-www-form-urlencoded", []byte(form.Encode()), nil)
+}
+
+// Webhook posts milestones as JSON to the settings' webhook URL, for
+// integrations of the athlete's own.
+type Webhook struct {
+	secret string
+	sender
+}
+
+// NewWebhook creates a webhook channel. With a secret, each request is
+// signed in SignatureHeader so receivers can check it came from the
+// processor. WithURL doesn't apply, the URL is the athlete's.
+func NewWebhook(secret string, opts ...Option) *Webhook {
+	return &Webhook{secret: secret, sender: newSender("", opts)}
+}
+
+// Name implements the Channel interface.
+func (c *Webhook) Name() string { return ChannelWebhook }
+
+// Configured implements the Channel interface.
+func (c *Webhook) Configured(settings Settings) bool { return settings.WebhookURL != "" }
+
+// Send implements the Channel interface. The body is the milestone with
+// its title and message.
+func (c *Webhook) Send(ctx context.Context, settings Settings, milestone Milestone) error {
+	body, err := json.Marshal(struct {
+		Milestone
+		Title   string `json:"title"`
+		Message string `json:"message"`
+	}{milestone, milestone.Title(), milestone.Message()})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook: %w", err)
+	}
+	header := http.Header{}
+	if c.secret != "" {
+		mac := hmac.New(sha256.New, []byte(c.secret))
+		mac.Write(body)
+		header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	return c.post(ctx, settings.WebhookURL, "application/json", body, header)
+}
@@ -0,0 +1,97 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+)
+
+// recordingServer answers every request with status, recording the last.
+func recordingServer(t *testing.T, status int) (*httptest.Server, *http.Request, *[]byte) {
+	t.Helper()
+	var request http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = *r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"errors": ["nope"]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &request, &body
+}
+
+func testMilestone() Milestone {
+	return Milestone{Goal: aggregation.Goal{ID: "year", Target: 2500}, AthleteID: 7, Year: 2025, Percent: 50, Progress: 1250}
+}
+
+func TestSendGrid_Send(t *testing.T) {
+	server, request, body := recordingServer(t, http.StatusAccepted)
+	channel := NewSendGrid("sg-key", "desirelines@example.com", WithURL(server.URL))
+	if err := channel.Send(context.Background(), Settings{Email: "andy@example.com"}, testMilestone()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if request.Header.Get("Authorization") != "Bearer sg-key" || request.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected headers %v", request.Header)
+	}
+	var mail struct {
+		Personalizations []struct {
+			To []struct{ Email string } `json:"to"`
+		} `json:"personalizations"`
+		From    struct{ Email string } `json:"from"`
+		Subject string                 `json:"subject"`
+	}
+	if err := json.Unmarshal(*body, &mail); err != nil {
+		t.Fatalf("invalid body %s: %v", *body, err)
+	}
+	if mail.Personalizations[0].To[0].Email != "andy@example.com" || mail.From.Email != "desirelines@example.com" || mail.Subject != "50% of your 2025 goal" {
+		t.Errorf("unexpected mail %s", *body)
+	}
+}
+
+func TestPushover_Send(t *testing.T) {
+	server, _, body := recordingServer(t, http.StatusOK)
+	if err := NewPushover("app-token", WithURL(server.URL)).Send(context.Background(), Settings{PushoverUser: "user-key"}, testMilestone()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	form, _ := url.ParseQuery(string(*body))
+	if form.Get("token") != "app-token" || form.Get("user") != "user-key" || form.Get("title") != "50% of your 2025 goal" {
+		t.Errorf("unexpected form %v", form)
+	}
+
+	server, _, _ = recordingServer(t, http.StatusBadRequest)
+	err := NewPushover("app-token", WithURL(server.URL)).Send(context.Background(), Settings{PushoverUser: "user-key"}, testMilestone())
+	if err == nil || !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "nope") {
+		t.Errorf("expected the rejection reported, got %v", err)
+	}
+}
+
+func TestWebhook_Send(t *testing.T) {
+	server, request, body := recordingServer(t, http.StatusNoContent)
+	if err := NewWebhook("hook-secret").Send(context.Background(), Settings{WebhookURL: server.URL}, testMilestone()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	mac := hmac.New(sha256.New, []byte("hook-secret"))
+	mac.Write(*body)
+	if got := request.Header.Get(SignatureHeader); got != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("unexpected signature %q", got)
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(*body, &payload); err != nil || payload["percent"] != 50.0 || payload["title"] != "50% of your 2025 goal" || payload["athlete_id"] != 7.0 {
+		t.Errorf("unexpected payload %s", *body)
+	}
+
+	if err := NewWebhook("").Send(context.Background(), Settings{WebhookURL: server.URL}, testMilestone()); err != nil || request.Header.Get(SignatureHeader) != "" {
+		t.Errorf("expected an unsigned request without a secret, got %v", err)
+	}
+}
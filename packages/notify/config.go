@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/andy-esch/desirelines/packages/config"
+)
+
+// Config selects the channels and holds the deployment's credentials for
+// them; destinations are each athlete's (see Settings).
+type Config struct {
+	// Channels are the names of the enabled channels; none disables
+	// notifications.
+	Channels []string
+	// SendGridAPIKey and EmailFrom send ChannelEmail.
+	SendGridAPIKey string
+	EmailFrom      string
+	// PushoverAppToken sends ChannelPushover.
+	PushoverAppToken string
+	// WebhookSecret, if set, signs ChannelWebhook requests.
+	WebhookSecret string
+}
+
+// LoadConfig loads the notification settings from NOTIFY_CHANNELS, a
+// comma-separated list of channel names, and the channels' credentials.
+func LoadConfig() *Config {
+	var channels []string
+	for _, name := range strings.Split(config.Get("NOTIFY_CHANNELS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			channels = append(channels, name)
+		}
+	}
+	return &Config{
+		Channels:         channels,
+		SendGridAPIKey:   config.Get("SENDGRID_API_KEY"),
+		EmailFrom:        config.Get("NOTIFY_EMAIL_FROM"),
+		PushoverAppToken: config.Get("PUSHOVER_APP_TOKEN"),
+		WebhookSecret:    config.Get("NOTIFY_WEBHOOK_SECRET"),
+	}
+}
+
+// Enabled reports whether any channel is enabled; a nil Config has none.
+func (c *Config) Enabled() bool {
+	return c != nil && len(c.Channels) > 0
+}
+
+// Validate checks that every enabled channel is known and has its
+// credentials, reporting every problem at once.
+func (c *Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	var errs []error
+	for _, name := range c.Channels {
+		switch name {
+		case ChannelEmail:
+			if c.SendGridAPIKey == "" || c.EmailFrom == "" {
+				errs = append(errs, errors.New("SENDGRID_API_KEY and NOTIFY_EMAIL_FROM are required for the email channel"))
+			}
+		case ChannelPushover:
+			if c.PushoverAppToken == "" {
+				errs = append(errs, errors.New("PUSHOVER_APP_TOKEN is required for the pushover channel"))
+			}
+		case ChannelWebhook:
+		default:
+			errs = append(errs, fmt.Errorf("invalid NOTIFY_CHANNELS channel %q (want %s, %s or %s)", name, ChannelEmail, ChannelPushover, ChannelWebhook))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Open creates a notifier sending on the enabled channels, in the order
+// they are listed, each once. Validate must have passed.
+func Open(cfg *Config, opts ...Option) *Notifier {
+	var channels []Channel
+	seen := map[string]bool{}
+	for _, name := range cfg.Channels {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		switch name {
+		case ChannelEmail:
+			channels = append(channels, NewSendGrid(cfg.SendGridAPIKey, cfg.EmailFrom, opts...))
+		case ChannelPushover:
+			channels = append(channels, NewPushover(cfg.PushoverAppToken, opts...))
+		case ChannelWebhook:
+			channels = append(channels, NewWebhook(cfg.WebhookSecret, opts...))
+		}
+	}
+	return New(channels...)
+}
@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	if LoadConfig().Enabled() {
+		t.Error("expected notifications disabled without NOTIFY_CHANNELS")
+	}
+
+	t.Setenv("NOTIFY_CHANNELS", "email, webhook,")
+	t.Setenv("SENDGRID_API_KEY", "sg-key")
+	t.Setenv("NOTIFY_EMAIL_FROM", "desirelines@example.com")
+	cfg := LoadConfig()
+	if !cfg.Enabled() || !slices.Equal(cfg.Channels, []string{"email", "webhook"}) || cfg.SendGridAPIKey != "sg-key" {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected valid config, got %v", err)
+	}
+	if got := Open(cfg).Channels(); !slices.Equal(got, []string{ChannelEmail, ChannelWebhook}) {
+		t.Errorf("unexpected channels %v", got)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	err := (&Config{Channels: []string{"email", "pushover", "carrier-pigeon"}}).Validate()
+	for _, want := range []string{"SENDGRID_API_KEY", "PUSHOVER_APP_TOKEN", `"carrier-pigeon"`} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+	var disabled *Config
+	if err := disabled.Validate(); err != nil {
+		t.Errorf("expected a nil config to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+module github.com/andy-esch/desirelines/packages/notify
+
+go 1.25
+
+require (
+	github.com/andy-esch/desirelines/packages/aggregation v0.0.0
+	github.com/andy-esch/desirelines/packages/config v0.0.0
+)
+
+require github.com/andy-esch/desirelines/packages/strava v0.0.0 // indirect
+
+replace github.com/andy-esch/desirelines/packages/aggregation => ../aggregation
+
+replace github.com/andy-esch/desirelines/packages/config => ../config
+
+replace github.com/andy-esch/desirelines/packages/strava => ../strava
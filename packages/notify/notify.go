@@ -0,0 +1,201 @@
+// Package notify tells athletes when an activity takes them past a
+// milestone of one of their goals, 25, 50, 75 and 100% of the target by
+// default. Messages go out on pluggable channels, email through SendGrid,
+// Pushover and webhooks, to the destinations in each athlete's settings:
+//
+//	notifier := notify.New(notify.NewPushover(appToken), notify.NewWebhook(secret))
+//	if percent, ok := notify.Crossed(settings.Thresholds(), goal.Target, before, after); ok {
+//		sent, err := notifier.Notify(ctx, settings, notify.Milestone{Goal: goal, Percent: percent, ...})
+//	}
+//
+// Settings are one JSON document per athlete, kept with their aggregates
+// at SettingsBlob:
+//
+//	{"email": "andy@example.com", "pushover_user": "u...", "webhook_url": "https://...", "milestones": [50, 100]}
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+)
+
+// DefaultMilestones are the percentages of a goal's target notified when
+// an athlete's settings don't list their own.
+var DefaultMilestones = []int{25, 50, 75, 100}
+
+// SettingsBlob is the object name of an athlete's notification settings.
+func SettingsBlob(athleteID int64) string {
+	return fmt.Sprintf("notifications/%d.json", athleteID)
+}
+
+// Settings are an athlete's notification settings. Each channel sends to
+// its destination when the athlete has set one.
+type Settings struct {
+	Email        string `json:"email,omitempty"`
+	PushoverUser string `json:"pushover_user,omitempty"`
+	WebhookURL   string `json:"webhook_url,omitempty"`
+	// Milestones are the percentages of a goal's target notified;
+	// DefaultMilestones when empty.
+	Milestones []int `json:"milestones,omitempty"`
+	// Disabled pauses the athlete's notifications.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// Validate checks the settings, reporting every problem at once.
+func (s Settings) Validate() error {
+	var errs []error
+	if s.Email != "" {
+		if _, err := mail.ParseAddress(s.Email); err != nil {
+			errs = append(errs, fmt.Errorf("invalid email %q", s.Email))
+		}
+	}
+	if s.WebhookURL != "" {
+		if u, err := url.Parse(s.WebhookURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("invalid webhook_url %q (want an http or https URL)", s.WebhookURL))
+		}
+	}
+	for _, percent := range s.Milestones {
+		if percent <= 0 || percent > 100 {
+			errs = append(errs, fmt.Errorf("milestone %d is not a percentage from 1 to 100", percent))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Thresholds returns the milestones notified, ascending.
+func (s Settings) Thresholds() []int {
+	if len(s.Milestones) == 0 {
+		return DefaultMilestones
+	}
+	thresholds := slices.Clone(s.Milestones)
+	slices.Sort(thresholds)
+	return slices.Compact(thresholds)
+}
+
+// Crossed returns the highest of milestones, percentages of target, that
+// progress passed going from before to after. An activity taking an
+// athlete past several milestones at once is notified once, for the
+// highest.
+func Crossed(milestones []int, target, before, after float64) (int, bool) {
+	if target <= 0 || after <= before {
+		return 0, false
+	}
+	crossed, ok := 0, false
+	for _, percent := range milestones {
+		threshold := target * float64(percent) / 100
+		if before < threshold && after >= threshold {
+			crossed, ok = max(crossed, percent), true
+		}
+	}
+	return crossed, ok
+}
+
+// Milestone is a goal milestone an athlete reached.
+type Milestone struct {
+	// ReachedAt is when the activity that reached it was processed.
+	ReachedAt time.Time        `json:"reached_at"`
+	Goal      aggregation.Goal `json:"goal"`
+	// Progress is the goal's total after the activity, in the unit of
+	// the goal's type.
+	Progress  float64 `json:"progress"`
+	AthleteID int64   `json:"athlete_id"`
+	Year      int     `json:"year"`
+	Percent   int     `json:"percent"`
+}
+
+// Title is the milestone's one-line summary, e.g. "50% of your 2025 goal".
+func (m Milestone) Title() string {
+	name := m.Goal.Label
+	if name == "" {
+		name = fmt.Sprintf("your %d goal", m.Year)
+	}
+	if m.Percent >= 100 {
+		return fmt.Sprintf("Goal reached: %s", name)
+	}
+	return fmt.Sprintf("%d%% of %s", m.Percent, name)
+}
+
+// Message describes the milestone, e.g. "1,250 of 2,500 miles (50%).
+// Keep it up!".
+func (m Milestone) Message() string {
+	unit := "miles"
+	switch m.Goal.Type {
+	case aggregation.GoalElevation:
+		unit = "feet climbed"
+	case aggregation.GoalCount:
+		unit = "activities"
+	}
+	return fmt.Sprintf("%s of %s %s (%d%%). Keep it up!", formatNumber(m.Progress), formatNumber(m.Goal.Target), unit, m.Percent)
+}
+
+// formatNumber rounds v to a whole number with thousands separators.
+func formatNumber(v float64) string {
+	digits := fmt.Sprintf("%.0f", v)
+	var b strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 && digits[i-1] != '-' {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	return b.String()
+}
+
+// Channel delivers milestones to one kind of destination.
+type Channel interface {
+	// Name identifies the channel in logs, e.g. "email".
+	Name() string
+	// Configured reports whether settings have a destination on the
+	// channel.
+	Configured(settings Settings) bool
+	Send(ctx context.Context, settings Settings, milestone Milestone) error
+}
+
+// Notifier sends milestones on its channels.
+type Notifier struct {
+	channels []Channel
+}
+
+// New creates a notifier sending on channels.
+func New(channels ...Channel) *Notifier {
+	return &Notifier{channels: channels}
+}
+
+// Channels returns the names of the notifier's channels.
+func (n *Notifier) Channels() []string {
+	names := make([]string, len(n.channels))
+	for i, channel := range n.channels {
+		names[i] = channel.Name()
+	}
+	return names
+}
+
+// Notify sends milestone on every channel settings have a destination on,
+// trying each even when another fails, and returns the names of the
+// channels it was sent on. Disabled settings send nothing.
+func (n *Notifier) Notify(ctx context.Context, settings Settings, milestone Milestone) ([]string, error) {
+	if settings.Disabled {
+		return nil, nil
+	}
+	var sent []string
+	var errs []error
+	for _, channel := range n.channels {
+		if !channel.Configured(settings) {
+			continue
+		}
+		if err := channel.Send(ctx, settings, milestone); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel.Name(), err))
+			continue
+		}
+		sent = append(sent, channel.Name())
+	}
+	return sent, errors.Join(errs...)
+}
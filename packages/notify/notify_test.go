@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+)
+
+func TestCrossed(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after float64
+		want          int
+		wantOK        bool
+	}{
+		{"below the first", 100, 200, 0, false},
+		{"reaches the first", 600, 625, 25, true},
+		{"passes two at once", 600, 1300, 50, true},
+		{"reaches the target", 2400, 2600, 100, true},
+		{"already past", 2600, 2700, 0, false},
+		{"going down", 1300, 1200, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Crossed(DefaultMilestones, 2500, tt.before, tt.after)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Crossed(%v, %v) = %d, %v; want %d, %v", tt.before, tt.after, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSettings(t *testing.T) {
+	if got := (Settings{}).Thresholds(); !slices.Equal(got, DefaultMilestones) {
+		t.Errorf("expected the default milestones, got %v", got)
+	}
+	if got := (Settings{Milestones: []int{100, 50, 50}}).Thresholds(); !slices.Equal(got, []int{50, 100}) {
+		t.Errorf("expected sorted unique milestones, got %v", got)
+	}
+
+	valid := Settings{Email: "andy@example.com", WebhookURL: "https://example.com/hook", Milestones: []int{10, 100}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected valid settings, got %v", err)
+	}
+	err := Settings{Email: "andy", WebhookURL: "ftp://example.com", Milestones: []int{0, 150}}.Validate()
+	for _, want := range []string{"invalid email", "invalid webhook_url", "milestone 0", "milestone 150"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestMilestone_Text(t *testing.T) {
+	m := Milestone{Goal: aggregation.Goal{ID: "year", Target: 2500}, Year: 2025, Percent: 50, Progress: 1262.4}
+	if got := m.Title(); got != "50% of your 2025 goal" {
+		t.Errorf("unexpected title %q", got)
+	}
+	if got := m.Message(); got != "1,262 of 2,500 miles (50%). Keep it up!" {
+		t.Errorf("unexpected message %q", got)
+	}
+
+	m = Milestone{Goal: aggregation.Goal{ID: "june", Type: aggregation.GoalCount, Label: "June rides", Target: 20}, Percent: 100, Progress: 20}
+	if got := m.Title(); got != "Goal reached: June rides" {
+		t.Errorf("unexpected title %q", got)
+	}
+	if got := m.Message(); !strings.HasPrefix(got, "20 of 20 activities") {
+		t.Errorf("unexpected message %q", got)
+	}
+}
+
+// fakeChannel records milestones sent to settings with a Pushover user,
+// failing with err.
+type fakeChannel struct {
+	name string
+	err  error
+	sent []Milestone
+}
+
+func (c *fakeChannel) Name() string                      { return c.name }
+func (c *fakeChannel) Configured(settings Settings) bool { return settings.PushoverUser != "" }
+
+func (c *fakeChannel) Send(_ context.Context, _ Settings, milestone Milestone) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.sent = append(c.sent, milestone)
+	return nil
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	ok, failing := &fakeChannel{name: "ok"}, &fakeChannel{name: "failing", err: errors.New("down")}
+	notifier := New(failing, ok, NewSendGrid("key", "from@example.com"))
+	milestone := Milestone{Percent: 50}
+
+	sent, err := notifier.Notify(context.Background(), Settings{PushoverUser: "u"}, milestone)
+	if !slices.Equal(sent, []string{"ok"}) || len(ok.sent) != 1 {
+		t.Errorf("expected the working channel to send, got %v", sent)
+	}
+	if err == nil || !strings.Contains(err.Error(), "failing: down") {
+		t.Errorf("expected the failing channel reported, got %v", err)
+	}
+
+	sent, err = notifier.Notify(context.Background(), Settings{PushoverUser: "u", Disabled: true}, milestone)
+	if len(sent) != 0 || err != nil || len(ok.sent) != 1 {
+		t.Errorf("expected disabled settings to send nothing, got %v, %v", sent, err)
+	}
+	if got := notifier.Channels(); !slices.Equal(got, []string{"failing", "ok", ChannelEmail}) {
+		t.Errorf("unexpected channels %v", got)
+	}
+}
@@ -0,0 +1,348 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	bigquery "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// activitySchema is the activities table's schema. NewBigQuerySink creates
+// the table with it and adds columns appended here later to an existing
+// table; columns are never removed or retyped.
+var activitySchema = []*bigquery.TableFieldSchema{
+	{Name: "id", Type: "INTEGER", Mode: "REQUIRED", Description: "Strava activity ID"},
+	{Name: "athlete_id", Type: "INTEGER", Description: "Owner of the activity, from the event"},
+	{Name: "name", Type: "STRING"},
+	{Name: "type", Type: "STRING"},
+	{Name: "sport_type", Type: "STRING"},
+	{Name: "start_date", Type: "TIMESTAMP"},
+	{Name: "start_date_local", Type: "DATETIME", Description: "Wall-clock start time in the activity's time zone"},
+	{Name: "timezone", Type: "STRING"},
+	{Name: "distance", Type: "FLOAT", Description: "Meters"},
+	{Name: "total_elevation_gain", Type: "FLOAT", Description: "Meters"},
+	{Name: "average_speed", Type: "FLOAT", Description: "Meters per second"},
+	{Name: "max_speed", Type: "FLOAT", Description: "Meters per second"},
+	{Name: "moving_time", Type: "INTEGER", Description: "Seconds"},
+	{Name: "elapsed_time", Type: "INTEGER", Description: "Seconds"},
+	{Name: "trainer", Type: "BOOLEAN"},
+	{Name: "manual", Type: "BOOLEAN"},
+	{Name: "private", Type: "BOOLEAN"},
+	{Name: "processed_at", Type: "TIMESTAMP", Mode: "REQUIRED", Description: "When the processor fetched the activity"},
+}
+
+// BigQuerySink streams fetched activities into a BigQuery table, one row
+// per fetch. The activity ID is used as the insert ID so retried inserts
+// aren't duplicated, but an event redelivered later adds another row; take
+// the latest processed_at per id.
+type BigQuerySink struct {
+	tabledata *bigquery.TabledataService
+	jobs      *bigquery.JobsService
+	projectID string
+	datasetID string
+	tableID   string
+}
+
+// NewBigQuerySink creates a sink for table, given as "project.dataset.table"
+// or "dataset.table" in projectID, creating the table or adding missing
+// columns so it matches activitySchema. The dataset must exist.
+func NewBigQuerySink(ctx context.Context, table, projectID string, opts ...option.ClientOption) (*BigQuerySink, error) {
+	project, dataset, tableID, err := parseBigQueryTable(table, projectID)
+	if err != nil {
+		return nil, err
+	}
+	service, err := bigquery.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create BigQuery client: %w", err)
+	}
+	if err := ensureActivityTable(ctx, bigquery.NewTablesService(service), project, dataset, tableID); err != nil {
+		return nil, err
+	}
+	Logger.Info("Activity table initialized", "table", project+"."+dataset+"."+tableID)
+	return &BigQuerySink{
+		tabledata: bigquery.NewTabledataService(service),
+		jobs:      bigquery.NewJobsService(service),
+		projectID: project,
+		datasetID: dataset,
+		tableID:   tableID,
+	}, nil
+}
+
+// ensureActivityTable creates the table with activitySchema, or patches in
+// the columns an existing table lacks.
+func ensureActivityTable(ctx context.Context, tables *bigquery.TablesService, project, dataset, tableID string) error {
+	table, err := tables.Get(project, dataset, tableID).Context(ctx).Do()
+	if isStatus(err, http.StatusNotFound) {
+		_, err = tables.Insert(project, dataset, &bigquery.Table{
+			TableReference: &bigquery.TableReference{ProjectId: project, DatasetId: dataset, TableId: tableID},
+			Schema:         &bigquery.TableSchema{Fields: activitySchema},
+			Description:    "Strava activities fetched by the activity processor",
+		}).Context(ctx).Do()
+		// Another instance may have created it first
+		if err != nil && !isStatus(err, http.StatusConflict) {
+			return fmt.Errorf("failed to create activity table: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read activity table: %w", err)
+	}
+
+	var fields []*bigquery.TableFieldSchema
+	if table.Schema != nil {
+		fields = table.Schema.Fields
+	}
+	missing := missingFields(fields, activitySchema)
+	if len(missing) == 0 {
+		return nil
+	}
+	_, err = tables.Patch(project, dataset, tableID, &bigquery.Table{
+		Schema: &bigquery.TableSchema{Fields: append(slices.Clone(fields), missing...)},
+	}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to add columns to activity table: %w", err)
+	}
+	Logger.Info("Activity table columns added", "columns", len(missing))
+	return nil
+}
+
+// missingFields returns nullable copies of the fields of want that have
+// lacks; BigQuery only adds nullable columns to an existing table.
+func missingFields(have, want []*bigquery.TableFieldSchema) []*bigquery.TableFieldSchema {
+	var missing []*bigquery.TableFieldSchema
+	for _, field := range want {
+		if slices.ContainsFunc(have, func(f *bigquery.TableFieldSchema) bool { return strings.EqualFold(f.Name, field.Name) }) {
+			continue
+		}
+		added := *field
+		added.Mode = "NULLABLE"
+		missing = append(missing, &added)
+	}
+	return missing
+}
+
+// isStatus reports whether err is a Google API error with code.
+func isStatus(err error, code int) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// parseBigQueryTable splits a table reference, defaulting the project.
+func parseBigQueryTable(table, projectID string) (project, dataset, tableID string, err error) {
+	parts := strings.Split(table, ".")
+	switch {
+	case len(parts) == 3 && !slices.Contains(parts, ""):
+		return parts[0], parts[1], parts[2], nil
+	case len(parts) == 2 && !slices.Contains(parts, ""):
+		if projectID == "" {
+			return "", "", "", fmt.Errorf("GCP_PROJECT_ID is required to resolve table %q", table)
+		}
+		return projectID, parts[0], parts[1], nil
+	default:
+		return "", "", "", fmt.Errorf("invalid BigQuery table %q (expected [project.]dataset.table)", table)
+	}
+}
+
+// Write implements the ActivitySink interface.
+func (s *BigQuerySink) Write(ctx context.Context, record ActivityRecord) error {
+	rows := []*bigquery.TableDataInsertAllRequestRows{{
+		InsertId: strconv.FormatInt(record.Activity.ID, 10),
+		Json:     activityRow(record),
+	}}
+	response, err := s.tabledata.InsertAll(s.projectID, s.datasetID, s.tableID, &bigquery.TableDataInsertAllRequest{Rows: rows}).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("failed to insert activity %d: %w", record.Activity.ID, err)
+	}
+	if len(response.InsertErrors) > 0 {
+		var errs []error
+		for _, insertErr := range response.InsertErrors {
+			for _, e := range insertErr.Errors {
+				errs = append(errs, fmt.Errorf("%s: %s", e.Location, e.Message))
+			}
+		}
+		return fmt.Errorf("failed to insert activity %d: %w", record.Activity.ID, errors.Join(errs...))
+	}
+	return nil
+}
+
+// activityRow converts record to an insertAll row of activitySchema's
+// columns.
+func activityRow(record ActivityRecord) map[string]bigquery.JsonValue {
+	activity := record.Activity
+	row := map[string]bigquery.JsonValue{
+		"id":                   activity.ID,
+		"name":                 activity.Name,
+		"type":                 activity.Type,
+		"sport_type":           activity.SportType,
+		"timezone":             activity.Timezone,
+		"distance":             activity.Distance,
+		"total_elevation_gain": activity.TotalElevationGain,
+		"average_speed":        activity.AverageSpeed,
+		"max_speed":            activity.MaxSpeed,
+		"moving_time":          activity.MovingTime,
+		"elapsed_time":         activity.ElapsedTime,
+		"trainer":              activity.Trainer,
+		"manual":               activity.Manual,
+		"private":              activity.Private,
+		"processed_at":         record.ProcessedAt.UTC().Format(time.RFC3339Nano),
+	}
+	if record.OwnerID != 0 {
+		row["athlete_id"] = record.OwnerID
+	}
+	if !activity.StartDate.IsZero() {
+		row["start_date"] = activity.StartDate.UTC().Format(time.RFC3339)
+	}
+	if !activity.StartDateLocal.IsZero() {
+		// Strava's local time carries a misleading "Z"; DATETIME has no zone
+		row["start_date_local"] = activity.StartDateLocal.Format(time.DateTime)
+	}
+	return row
+}
+
+// Close implements the ActivitySink interface.
+func (s *BigQuerySink) Close() error {
+	return nil
+}
+
+// Activities returns the latest row of each of athleteID's activities, or
+// of every athlete's for 0, with local start dates from from to to
+// inclusive, as YYYY-MM-DD, ordered by date.
+func (s *BigQuerySink) Activities(ctx context.Context, athleteID int64, from, to string) ([]ActivityRecord, error) {
+	query := fmt.Sprintf("SELECT * EXCEPT(row_num) FROM ("+
+		"SELECT *, ROW_NUMBER() OVER (PARTITION BY id ORDER BY processed_at DESC) AS row_num FROM `%s.%s.%s` "+
+		"WHERE DATE(start_date_local) BETWEEN @from AND @to AND (@athlete_id = 0 OR athlete_id = @athlete_id)"+
+		") WHERE row_num = 1 ORDER BY start_date_local", s.projectID, s.datasetID, s.tableID)
+	useLegacySQL := false
+	response, err := s.jobs.Query(s.projectID, &bigquery.QueryRequest{
+		Query:         query,
+		UseLegacySql:  &useLegacySQL,
+		ParameterMode: "NAMED",
+		QueryParameters: []*bigquery.QueryParameter{
+			queryParameter("from", "DATE", from),
+			queryParameter("to", "DATE", to),
+			queryParameter("athlete_id", "INT64", strconv.FormatInt(athleteID, 10)),
+		},
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query activities of athlete %d: %w", athleteID, err)
+	}
+
+	// Large or slow results are fetched in pages, waiting for the job first
+	jobComplete, schema, rows, pageToken := response.JobComplete, response.Schema, response.Rows, response.PageToken
+	var records []ActivityRecord
+	for {
+		if jobComplete {
+			for _, row := range rows {
+				record, err := activityRecord(schema, row)
+				if err != nil {
+					return nil, err
+				}
+				records = append(records, record)
+			}
+			if pageToken == "" {
+				return records, nil
+			}
+		}
+		results, err := s.jobs.GetQueryResults(s.projectID, response.JobReference.JobId).
+			Location(response.JobReference.Location).PageToken(pageToken).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read activities of athlete %d: %w", athleteID, err)
+		}
+		jobComplete, schema, rows, pageToken = results.JobComplete, results.Schema, results.Rows, results.PageToken
+	}
+}
+
+func queryParameter(name, typ, value string) *bigquery.QueryParameter {
+	return &bigquery.QueryParameter{
+		Name:           name,
+		ParameterType:  &bigquery.QueryParameterType{Type: typ},
+		ParameterValue: &bigquery.QueryParameterValue{Value: value},
+	}
+}
+
+// activityRecord converts a result row of activitySchema's columns back to
+// the record activityRow made it from. BigQuery returns every value as a
+// string, and TIMESTAMPs as seconds since the epoch.
+func activityRecord(schema *bigquery.TableSchema, row *bigquery.TableRow) (ActivityRecord, error) {
+	var record ActivityRecord
+	if schema == nil || len(schema.Fields) != len(row.F) {
+		return record, errors.New("activity query returned rows not matching its schema")
+	}
+	activity := &record.Activity
+	var errs []error
+	for i, field := range schema.Fields {
+		value, ok := row.F[i].V.(string)
+		if !ok {
+			// NULL
+			continue
+		}
+		parseInt := func() int64 {
+			n, err := strconv.ParseInt(value, 10, 64)
+			errs = append(errs, err)
+			return n
+		}
+		parseFloat := func() float64 {
+			f, err := strconv.ParseFloat(value, 64)
+			errs = append(errs, err)
+			return f
+		}
+		parseTimestamp := func() time.Time {
+			seconds, err := strconv.ParseFloat(value, 64)
+			errs = append(errs, err)
+			return time.UnixMicro(int64(seconds * 1e6)).UTC()
+		}
+		switch field.Name {
+		case "id":
+			activity.ID = parseInt()
+		case "athlete_id":
+			record.OwnerID = parseInt()
+		case "name":
+			activity.Name = value
+		case "type":
+			activity.Type = value
+		case "sport_type":
+			activity.SportType = value
+		case "timezone":
+			activity.Timezone = value
+		case "start_date":
+			activity.StartDate = parseTimestamp()
+		case "start_date_local":
+			// DATETIME has no zone; Strava's local times parse as UTC
+			local, err := time.Parse("2006-01-02T15:04:05", strings.SplitN(value, ".", 2)[0])
+			errs = append(errs, err)
+			activity.StartDateLocal = local
+		case "processed_at":
+			record.ProcessedAt = parseTimestamp()
+		case "distance":
+			activity.Distance = parseFloat()
+		case "total_elevation_gain":
+			activity.TotalElevationGain = parseFloat()
+		case "average_speed":
+			activity.AverageSpeed = parseFloat()
+		case "max_speed":
+			activity.MaxSpeed = parseFloat()
+		case "moving_time":
+			activity.MovingTime = int(parseInt())
+		case "elapsed_time":
+			activity.ElapsedTime = int(parseInt())
+		case "trainer":
+			activity.Trainer = value == "true"
+		case "manual":
+			activity.Manual = value == "true"
+		case "private":
+			activity.Private = value == "true"
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return record, fmt.Errorf("failed to decode activity row: %w", err)
+	}
+	return record, nil
+}
@@ -0,0 +1,261 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	bigquery "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/option"
+)
+
+// fakeBigQuery serves the table, insertAll and query endpoints the sink
+// uses for a single table. Queries return queryPages, one per page.
+type fakeBigQuery struct {
+	table      *bigquery.Table
+	inserted   []*bigquery.TableDataInsertAllRequestRows
+	patches    int
+	queries    []*bigquery.QueryRequest
+	queryPages [][]*bigquery.TableRow
+	mu         sync.Mutex
+}
+
+func (f *fakeBigQuery) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/tables/activities"):
+		if f.table == nil {
+			http.Error(w, `{"error":{"code":404,"message":"Not found: Table"}}`, http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(f.table)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/tables"):
+		f.decode(w, r, &f.table)
+		_ = json.NewEncoder(w).Encode(f.table)
+	case r.Method == http.MethodPatch && strings.HasSuffix(r.URL.Path, "/tables/activities"):
+		var patch bigquery.Table
+		f.decode(w, r, &patch)
+		f.table.Schema = patch.Schema
+		f.patches++
+		_ = json.NewEncoder(w).Encode(f.table)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/tables/activities/insertAll"):
+		var request bigquery.TableDataInsertAllRequest
+		f.decode(w, r, &request)
+		f.inserted = append(f.inserted, request.Rows...)
+		_ = json.NewEncoder(w).Encode(bigquery.TableDataInsertAllResponse{})
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/queries"):
+		var request bigquery.QueryRequest
+		f.decode(w, r, &request)
+		f.queries = append(f.queries, &request)
+		response := f.queryPage(0)
+		_ = json.NewEncoder(w).Encode(bigquery.QueryResponse{
+			JobComplete:  true,
+			JobReference: &bigquery.JobReference{JobId: "job", Location: "US"},
+			Schema:       response.Schema,
+			Rows:         response.Rows,
+			PageToken:    response.PageToken,
+		})
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/queries/job"):
+		page, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+		_ = json.NewEncoder(w).Encode(f.queryPage(page))
+	default:
+		http.Error(w, fmt.Sprintf(`{"error":{"code":400,"message":"unexpected %s %s"}}`, r.Method, r.URL.Path), http.StatusBadRequest)
+	}
+}
+
+func (f *fakeBigQuery) queryPage(page int) *bigquery.GetQueryResultsResponse {
+	response := &bigquery.GetQueryResultsResponse{
+		JobComplete: true,
+		Schema:      &bigquery.TableSchema{Fields: activitySchema},
+	}
+	if page < len(f.queryPages) {
+		response.Rows = f.queryPages[page]
+	}
+	if page+1 < len(f.queryPages) {
+		response.PageToken = strconv.Itoa(page + 1)
+	}
+	return response
+}
+
+func (f *fakeBigQuery) decode(w http.ResponseWriter, r *http.Request, v any) {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+func newFakeBigQuerySink(t *testing.T, fake *fakeBigQuery) (*BigQuerySink, error) {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return NewBigQuerySink(context.Background(), "strava.activities", "project",
+		option.WithEndpoint(server.URL+"/"), option.WithoutAuthentication())
+}
+
+func TestBigQuerySink_CreatesTable(t *testing.T) {
+	fake := &fakeBigQuery{}
+	sink, err := newFakeBigQuerySink(t, fake)
+	if err != nil {
+		t.Fatalf("NewBigQuerySink failed: %v", err)
+	}
+
+	if fake.table == nil || len(fake.table.Schema.Fields) != len(activitySchema) {
+		t.Fatalf("expected the table to be created with the activity schema, got %+v", fake.table)
+	}
+	if ref := fake.table.TableReference; ref.ProjectId != "project" || ref.DatasetId != "strava" || ref.TableId != "activities" {
+		t.Errorf("unexpected table reference %+v", ref)
+	}
+
+	activity := ride(42, "2025-03-08T09:30:00Z", 16093.4)
+	activity.StartDate = time.Date(2025, time.March, 8, 14, 30, 0, 0, time.UTC)
+	processedAt := time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+	if err := sink.Write(context.Background(), ActivityRecord{ProcessedAt: processedAt, Activity: activity, OwnerID: 7}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if len(fake.inserted) != 1 {
+		t.Fatalf("expected one row, got %d", len(fake.inserted))
+	}
+	row := fake.inserted[0]
+	if row.InsertId != "42" {
+		t.Errorf("expected the activity ID as insert ID, got %q", row.InsertId)
+	}
+	want := map[string]any{
+		"id":               float64(42),
+		"athlete_id":       float64(7),
+		"type":             "Ride",
+		"start_date":       "2025-03-08T14:30:00Z",
+		"start_date_local": "2025-03-08 09:30:00",
+		"processed_at":     "2025-03-10T12:00:00Z",
+	}
+	for column, value := range want {
+		if row.Json[column] != value {
+			t.Errorf("column %s = %v, want %v", column, row.Json[column], value)
+		}
+	}
+}
+
+func TestBigQuerySink_AddsMissingColumns(t *testing.T) {
+	fake := &fakeBigQuery{table: &bigquery.Table{Schema: &bigquery.TableSchema{Fields: []*bigquery.TableFieldSchema{
+		{Name: "id", Type: "INTEGER", Mode: "REQUIRED"},
+		{Name: "legacy_column", Type: "STRING"},
+	}}}}
+	if _, err := newFakeBigQuerySink(t, fake); err != nil {
+		t.Fatalf("NewBigQuerySink failed: %v", err)
+	}
+
+	fields := fake.table.Schema.Fields
+	if len(fields) != len(activitySchema)+1 || fields[1].Name != "legacy_column" {
+		t.Fatalf("expected existing columns to be kept and the rest appended, got %d columns", len(fields))
+	}
+	for _, field := range fields[2:] {
+		if field.Mode != "NULLABLE" {
+			t.Errorf("expected added column %s to be nullable, got %q", field.Name, field.Mode)
+		}
+	}
+
+	if _, err := newFakeBigQuerySink(t, fake); err != nil {
+		t.Fatalf("NewBigQuerySink failed: %v", err)
+	}
+	if fake.patches != 1 {
+		t.Errorf("expected an up-to-date table not to be patched, got %d patches", fake.patches)
+	}
+}
+
+// resultRow builds a query result row in activitySchema's column order
+// from the non-NULL values.
+func resultRow(values map[string]string) *bigquery.TableRow {
+	row := &bigquery.TableRow{}
+	for _, field := range activitySchema {
+		cell := &bigquery.TableCell{}
+		if value, ok := values[field.Name]; ok {
+			cell.V = value
+		}
+		row.F = append(row.F, cell)
+	}
+	return row
+}
+
+func TestBigQuerySink_Activities(t *testing.T) {
+	fake := &fakeBigQuery{queryPages: [][]*bigquery.TableRow{
+		{resultRow(map[string]string{
+			"id":               "42",
+			"athlete_id":       "7",
+			"type":             "Ride",
+			"start_date":       "1.7414442E9",
+			"start_date_local": "2025-03-08T09:30:00",
+			"distance":         "16093.4",
+			"moving_time":      "3600",
+			"trainer":          "false",
+			"processed_at":     "1.7416080E9",
+		})},
+		{resultRow(map[string]string{"id": "43", "type": "VirtualRide", "start_date_local": "2025-03-09T18:00:00", "trainer": "true"})},
+	}}
+	sink, err := newFakeBigQuerySink(t, fake)
+	if err != nil {
+		t.Fatalf("NewBigQuerySink failed: %v", err)
+	}
+
+	records, err := sink.Activities(context.Background(), 7, "2025-03-01", "2025-03-31")
+	if err != nil {
+		t.Fatalf("Activities failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected the rows of both pages, got %d", len(records))
+	}
+	first := records[0]
+	if first.OwnerID != 7 || first.Activity.ID != 42 || first.Activity.Distance != 16093.4 || first.Activity.MovingTime != 3600 {
+		t.Errorf("unexpected first record %+v", first)
+	}
+	if want := time.Date(2025, time.March, 8, 14, 30, 0, 0, time.UTC); !first.Activity.StartDate.Equal(want) {
+		t.Errorf("start_date = %s, want %s", first.Activity.StartDate, want)
+	}
+	if first.Activity.Date() != "2025-03-08" {
+		t.Errorf("expected local date 2025-03-08, got %s", first.Activity.Date())
+	}
+	if !records[1].Activity.Trainer || records[1].OwnerID != 0 {
+		t.Errorf("unexpected second record %+v", records[1])
+	}
+
+	query := fake.queries[0]
+	if !strings.Contains(query.Query, "`project.strava.activities`") || query.UseLegacySql == nil || *query.UseLegacySql {
+		t.Errorf("expected a standard SQL query of the table, got %q", query.Query)
+	}
+	params := map[string]string{}
+	for _, param := range query.QueryParameters {
+		params[param.Name] = param.ParameterValue.Value
+	}
+	if params["from"] != "2025-03-01" || params["to"] != "2025-03-31" || params["athlete_id"] != "7" {
+		t.Errorf("unexpected query parameters %v", params)
+	}
+}
+
+func TestParseBigQueryTable(t *testing.T) {
+	tests := []struct {
+		table, projectID, want string
+		wantErr                bool
+	}{
+		{table: "p.d.t", want: "p.d.t"},
+		{table: "d.t", projectID: "p", want: "p.d.t"},
+		{table: "d.t", wantErr: true},
+		{table: "t", projectID: "p", wantErr: true},
+		{table: "p..t", wantErr: true},
+	}
+	for _, tt := range tests {
+		project, dataset, table, err := parseBigQueryTable(tt.table, tt.projectID)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseBigQueryTable(%q, %q) error = %v, wantErr %v", tt.table, tt.projectID, err, tt.wantErr)
+			continue
+		}
+		if got := project + "." + dataset + "." + table; !tt.wantErr && got != tt.want {
+			t.Errorf("parseBigQueryTable(%q, %q) = %q, want %q", tt.table, tt.projectID, got, tt.want)
+		}
+	}
+}
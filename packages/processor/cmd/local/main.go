@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/listener"
+	"github.com/andy-esch/desirelines/packages/processor"
+)
+
+const defaultShutdownTimeout = 10 * time.Second
+
+func main() {
+	configFile := flag.String("config", "", "Config file of KEY=VALUE settings or a JSON object (default $CONFIG_FILE)")
+	flag.String("port", "", "Port to listen on (default $PORT or 8080)")
+	flag.String("listen", "", "Address to listen on: host:port, unix:/path/to.sock or systemd (default $LISTEN_ADDR or :port)")
+	flag.String("subscription", "", "Pub/Sub subscription to pull events from (default $PUBSUB_SUBSCRIPTION)")
+	flag.Parse()
+	if err := config.Setup(*configFile); err != nil {
+		log.Fatalf("Failed to load config file: %v", err)
+	}
+	config.BindFlags(flag.CommandLine, map[string]string{"port": "PORT", "listen": "LISTEN_ADDR", "subscription": "PUBSUB_SUBSCRIPTION"})
+
+	log.Println("Starting processor local development server...")
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	handler, err := processor.NewHandler(ctx)
+	if err != nil {
+		log.Fatalf("Failed to initialize processor handler: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", handler)
+
+	server := &http.Server{
+		Handler: mux,
+	}
+
+	shutdownTimeout, err := config.Duration("SHUTDOWN_TIMEOUT", defaultShutdownTimeout)
+	if err != nil {
+		log.Printf("%v, using default %s", err, defaultShutdownTimeout)
+	}
+
+	ln, err := listener.Listen(config.GetOrDefault("LISTEN_ADDR", ":"+config.GetOrDefault("PORT", "8080")))
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+
+	// Without a subscription events only arrive by push, e.g. from the
+	// Pub/Sub emulator or a replayed push body
+	received := make(chan struct{})
+	if subscription := config.Get("PUBSUB_SUBSCRIPTION"); subscription != "" {
+		go func() {
+			defer close(received)
+			if err := handler.Receive(ctx, config.Get("GCP_PROJECT_ID"), subscription); err != nil {
+				log.Printf("Receiving events failed: %v", err)
+				stop()
+			}
+		}()
+	} else {
+		close(received)
+	}
+
+	log.Printf("Server listening on %s", ln.Addr())
+	err = listener.Serve(ctx, server, ln, "", "", shutdownTimeout)
+	stop()
+
+	// Receive returns once the messages it is processing are done
+	select {
+	case <-received:
+	case <-time.After(shutdownTimeout):
+		log.Println("Timed out waiting for in-flight messages")
+	}
+
+	if err := handler.Close(); err != nil {
+		log.Printf("Failed to close processor: %v", err)
+	}
+	if err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+	log.Println("Server stopped")
+}
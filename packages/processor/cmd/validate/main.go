@@ -0,0 +1,259 @@
+// Command validate recomputes each year's aggregates from the source
+// activities and compares them with the summary_activities.json and
+// distances.json in GCP_BUCKET_NAME, reporting the days that drifted, to
+// catch aggregation bugs that don't fail any event.
+//
+//	validate -years 2024,2025
+//	validate -source bigquery -table strava.activities -athlete-id 12345
+//	validate -source firestore -collection activities -athlete-id 12345 -json
+//
+// The source is the Strava API with the processor's app credentials, or
+// the processor's activity sink. The sinks only see created activities,
+// so activities deleted since show up as missing from the summary there.
+// The command exits with status 1 when any year drifted.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/processor"
+	"github.com/andy-esch/desirelines/packages/strava"
+	"github.com/andy-esch/desirelines/packages/tokenstore"
+)
+
+// Activity sources.
+const (
+	sourceStrava    = "strava"
+	sourceBigQuery  = "bigquery"
+	sourceFirestore = "firestore"
+)
+
+func main() {
+	log.SetFlags(0)
+	configFile := flag.String("config", "", "Config file of KEY=VALUE settings or a JSON object (default $CONFIG_FILE)")
+	source := flag.String("source", sourceStrava, "Where to read activities: strava, bigquery or firestore")
+	yearsFlag := flag.String("years", "", "Comma-separated years to check (default: the current year)")
+	flag.String("bucket", "", "Bucket the aggregates are served from (default $GCP_BUCKET_NAME)")
+	flag.String("table", "", "[project.]dataset.table of the BigQuery sink (default $ACTIVITY_BIGQUERY_TABLE)")
+	flag.String("collection", "", "Collection of the Firestore sink (default $ACTIVITY_FIRESTORE_COLLECTION)")
+	flag.String("athlete-id", "", "Athlete whose activities the sink is queried for (default $STRAVA_ATHLETE_ID; all athletes in BigQuery)")
+	tolerance := flag.Float64("tolerance", processor.DefaultDriftTolerance, "Miles a served total may differ by")
+	maxDays := flag.Int("max-days", 20, "Drifted days listed per year (0 = all)")
+	jsonOutput := flag.Bool("json", false, "Print the reports as JSON")
+	flag.Parse()
+	if err := config.Setup(*configFile); err != nil {
+		log.Fatalf("Failed to load config file: %v", err)
+	}
+	config.BindFlags(flag.CommandLine, map[string]string{
+		"bucket":     "GCP_BUCKET_NAME",
+		"table":      "ACTIVITY_BIGQUERY_TABLE",
+		"collection": "ACTIVITY_FIRESTORE_COLLECTION",
+		"athlete-id": "STRAVA_ATHLETE_ID",
+	})
+
+	cfg, err := processor.LoadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	if cfg.BucketName == "" {
+		log.Fatal("-bucket is required")
+	}
+	years, err := parseYears(*yearsFlag, time.Now().In(cfg.TimeZone).Year())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	list, closeSource, err := openSource(ctx, *source, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeSource()
+	store, err := processor.NewGCSStore(ctx, cfg.BucketName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() { _ = store.Close() }()
+
+	var reports []processor.YearReport
+	for _, year := range years {
+		activities, err := list(ctx, year)
+		if err != nil {
+			log.Fatalf("Failed to read %d activities from %s: %v", year, *source, err)
+		}
+		report, err := processor.ValidateYear(ctx, store, year, activities, cfg.ActivityTypes, *tolerance)
+		if err != nil {
+			log.Fatalf("Failed to validate %d: %v", year, err)
+		}
+		reports = append(reports, report)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(reports); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		for _, report := range reports {
+			printReport(os.Stdout, report, *maxDays)
+		}
+	}
+	for _, report := range reports {
+		if !report.OK() {
+			os.Exit(1)
+		}
+	}
+}
+
+// parseYears parses -years, defaulting to current.
+func parseYears(value string, current int) ([]int, error) {
+	if value == "" {
+		return []int{current}, nil
+	}
+	var years []int
+	for entry := range strings.SplitSeq(value, ",") {
+		year, err := strconv.Atoi(strings.TrimSpace(entry))
+		if err != nil || year < 2000 || year > current {
+			return nil, fmt.Errorf("invalid -years entry %q", entry)
+		}
+		years = append(years, year)
+	}
+	return years, nil
+}
+
+// lister returns the activities that may count towards year, including
+// some either side of it.
+type lister func(ctx context.Context, year int) ([]strava.Activity, error)
+
+// openSource returns the lister for source and a function releasing it.
+func openSource(ctx context.Context, source string, cfg *processor.Config) (lister, func(), error) {
+	var athleteID int64
+	if v := config.Get("STRAVA_ATHLETE_ID"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid -athlete-id %q", v)
+		}
+		athleteID = id
+	}
+
+	switch source {
+	case sourceStrava:
+		return openStrava(ctx, cfg)
+	case sourceBigQuery:
+		if cfg.ActivityTable == "" {
+			return nil, nil, errors.New("-table is required with -source bigquery")
+		}
+		sink, err := processor.NewBigQuerySink(ctx, cfg.ActivityTable, cfg.GCPProjectID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return recordLister(athleteID, sink.Activities), func() { _ = sink.Close() }, nil
+	case sourceFirestore:
+		switch {
+		case cfg.ActivityCollection == "":
+			return nil, nil, errors.New("-collection is required with -source firestore")
+		case cfg.GCPProjectID == "":
+			return nil, nil, errors.New("GCP_PROJECT_ID is required with -source firestore")
+		case athleteID == 0:
+			return nil, nil, errors.New("-athlete-id is required with -source firestore")
+		}
+		sink, err := processor.NewFirestoreSink(ctx, cfg.GCPProjectID, cfg.ActivityCollection)
+		if err != nil {
+			return nil, nil, err
+		}
+		return recordLister(athleteID, sink.Activities), func() { _ = sink.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown -source %q (expected strava, bigquery or firestore)", source)
+	}
+}
+
+// openStrava lists the athlete's activities with the processor's Strava
+// credentials, sharing tokens through TOKEN_STORE like the processor.
+func openStrava(ctx context.Context, cfg *processor.Config) (lister, func(), error) {
+	if cfg.StravaClientID == 0 || cfg.StravaClientSecret == "" || (cfg.StravaRefreshToken == "" && !cfg.TokenStore.Enabled()) {
+		return nil, nil, errors.New("strava client_id, client_secret and refresh_token are required (secrets file or STRAVA_CLIENT_ID, STRAVA_CLIENT_SECRET and STRAVA_REFRESH_TOKEN)")
+	}
+	var opts []strava.Option
+	closeTokens := func() {}
+	if cfg.TokenStore.Enabled() {
+		if err := cfg.TokenStore.Validate(); err != nil {
+			return nil, nil, err
+		}
+		tokens, err := tokenstore.Open(ctx, cfg.TokenStore)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open token store: %w", err)
+		}
+		opts = append(opts, strava.WithTokenStore(tokens, cfg.TokenStore.AthleteID))
+		closeTokens = func() { _ = tokens.Close() }
+	}
+	client := strava.New(cfg.StravaClientID, cfg.StravaClientSecret, cfg.StravaRefreshToken, opts...)
+	return func(ctx context.Context, year int) ([]strava.Activity, error) {
+		// Listed by UTC start time but summarized by local date, hence the
+		// day's margin either side
+		start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		return client.ListActivities(ctx, start.AddDate(0, 0, -1), start.AddDate(1, 0, 1))
+	}, closeTokens, nil
+}
+
+// recordLister lists a sink's records of year's local dates.
+func recordLister(athleteID int64, activities func(ctx context.Context, athleteID int64, from, to string) ([]processor.ActivityRecord, error)) lister {
+	return func(ctx context.Context, year int) ([]strava.Activity, error) {
+		records, err := activities(ctx, athleteID, fmt.Sprintf("%d-01-01", year), fmt.Sprintf("%d-12-31", year))
+		if err != nil {
+			return nil, err
+		}
+		result := make([]strava.Activity, len(records))
+		for i, record := range records {
+			result[i] = record.Activity
+		}
+		return result, nil
+	}
+}
+
+// printReport writes a year's result, listing up to maxDays drifted days.
+func printReport(w io.Writer, r processor.YearReport, maxDays int) {
+	status := "OK"
+	if !r.OK() {
+		status = "DRIFT"
+	}
+	fmt.Fprintf(w, "%d: %s — %d activities, %.2f miles expected, %.2f served\n", r.Year, status, r.Activities, r.ExpectedMiles, r.ServedMiles)
+	if r.MissingSummary {
+		fmt.Fprintln(w, "  summary_activities.json is missing")
+	}
+	if r.MissingDistances {
+		fmt.Fprintln(w, "  distances.json is missing")
+	}
+	if len(r.Days) > 0 {
+		fmt.Fprintf(w, "  summary_activities.json: %d days differ\n", len(r.Days))
+	}
+	for i, day := range r.Days {
+		if maxDays > 0 && i == maxDays {
+			fmt.Fprintf(w, "    ... %d more\n", len(r.Days)-maxDays)
+			break
+		}
+		line := fmt.Sprintf("    %s  %.2f miles expected, %.2f served", day.Date, day.ExpectedMiles, day.ServedMiles)
+		if len(day.Missing) > 0 {
+			line += fmt.Sprintf("; missing %v", day.Missing)
+		}
+		if len(day.Extra) > 0 {
+			line += fmt.Sprintf("; extra %v", day.Extra)
+		}
+		fmt.Fprintln(w, line)
+	}
+	if d := r.Distances; d != nil {
+		fmt.Fprintf(w, "  distances.json: %d days differ from %s; %.2f miles expected on %s, %.2f served\n",
+			d.Days, d.FirstDate, d.ExpectedMiles, d.LastDate, d.ServedMiles)
+	}
+}
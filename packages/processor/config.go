@@ -0,0 +1,183 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	// Embedded so ATHLETE_TIMEZONE resolves in minimal images too
+	_ "time/tzdata"
+
+	"github.com/andy-esch/desirelines/packages/alert"
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/notify"
+	"github.com/andy-esch/desirelines/packages/strava"
+	"github.com/andy-esch/desirelines/packages/tokenstore"
+)
+
+const (
+	// DefaultSecretsPath is the standard secret volume mount path, shared
+	// with the dispatcher.
+	DefaultSecretsPath = "/etc/secrets/strava_auth.json"
+
+	// DefaultTimeZone is the athlete's time zone, which decides what
+	// "today" is for the cumulative series.
+	DefaultTimeZone = "America/New_York"
+
+	// DefaultActivityTypes are the Strava activity types counted towards
+	// the distance totals.
+	DefaultActivityTypes = "Ride,VirtualRide"
+)
+
+// Config holds all configuration for the processor.
+type Config struct {
+	// TimeZone decides which day is today when the cumulative series is
+	// cut off.
+	TimeZone *time.Location
+	// TokenStore shares the Strava tokens with the other functions; its
+	// Backend is empty when each process refreshes on its own.
+	TokenStore *tokenstore.Config
+	// Athletes locates the athlete registry; when it is enabled every
+	// event is scoped to its owner's prefix and tokens, and events of
+	// unregistered athletes are skipped.
+	Athletes *athletes.Config
+	// Notify selects the channels goal milestones are sent on; none
+	// disables them.
+	Notify *notify.Config
+	// Alerts posts failed events to a Slack or Discord webhook; disabled
+	// without ALERT_WEBHOOK_URL.
+	Alerts *alert.Config
+	// BucketName is the bucket the API gateway serves aggregates from.
+	BucketName   string
+	GCPProjectID string
+	// ActivityTable or ActivityCollection, when set, receives every
+	// fetched activity: rows in a "[project.]dataset.table" (see
+	// BigQuerySink), or documents in a Firestore collection (see
+	// FirestoreSink).
+	ActivityTable      string
+	ActivityCollection string
+	// Subscription is the Pub/Sub subscription pulled by cmd/local; push
+	// deployments leave it empty.
+	Subscription       string
+	LogLevel           string
+	StravaClientSecret string
+	StravaRefreshToken string
+	// ActivityTypes are the Strava activity types that are counted.
+	ActivityTypes  []string
+	StravaClientID int
+	// StoreStreams keeps each counted activity's streams for the
+	// gateway's single-activity charts.
+	StoreStreams bool
+	// WritePrecompressed keeps a gzipped .gz sibling next to each summary
+	// and distances blob for the gateway's SERVE_PRECOMPRESSED.
+	WritePrecompressed bool
+}
+
+// LoadConfig loads configuration from environment variables, with the
+// Strava app credentials read from the mounted secrets file when present
+// and from STRAVA_* variables otherwise.
+func LoadConfig() (*Config, error) {
+	var errs []error
+
+	timeZone, err := time.LoadLocation(config.GetOrDefault("ATHLETE_TIMEZONE", DefaultTimeZone))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid ATHLETE_TIMEZONE: %v", err))
+	}
+
+	credentials, err := strava.LoadCredentials(config.GetOrDefault("STRAVA_AUTH_FILE", DefaultSecretsPath), config.Get)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	tokenStore, err := tokenstore.LoadConfig()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	registry, err := athletes.LoadConfig()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	alerts, err := alert.LoadConfig()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if tokenStore != nil {
+		tokenStore.PerAthlete = registry.Enabled()
+	}
+
+	var activityTypes []string
+	for _, activityType := range strings.Split(config.GetOrDefault("ACTIVITY_TYPES", DefaultActivityTypes), ",") {
+		if activityType = strings.TrimSpace(activityType); activityType != "" {
+			activityTypes = append(activityTypes, activityType)
+		}
+	}
+
+	cfg := &Config{
+		TimeZone:           timeZone,
+		TokenStore:         tokenStore,
+		Athletes:           registry,
+		Notify:             notify.LoadConfig(),
+		Alerts:             alerts,
+		BucketName:         config.Get("GCP_BUCKET_NAME"),
+		GCPProjectID:       config.Get("GCP_PROJECT_ID"),
+		ActivityTable:      config.Get("ACTIVITY_BIGQUERY_TABLE"),
+		ActivityCollection: config.Get("ACTIVITY_FIRESTORE_COLLECTION"),
+		Subscription:       config.Get("PUBSUB_SUBSCRIPTION"),
+		LogLevel:           config.GetOrDefault("LOG_LEVEL", "INFO"),
+		ActivityTypes:      activityTypes,
+		StravaClientID:     credentials.ClientID,
+		StravaClientSecret: credentials.ClientSecret,
+		StravaRefreshToken: credentials.RefreshToken,
+		StoreStreams:       config.Get("STORE_STREAMS") == "true",
+		WritePrecompressed: config.Get("WRITE_PRECOMPRESSED") == "true",
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks that the settings needed to process events are present,
+// reporting every problem at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.BucketName == "" {
+		errs = append(errs, errors.New("GCP_BUCKET_NAME is required"))
+	}
+	// A token store or the athlete registry may already hold the refresh
+	// token
+	if c.StravaClientID == 0 || c.StravaClientSecret == "" || (c.StravaRefreshToken == "" && !c.TokenStore.Enabled() && !c.Athletes.Enabled()) {
+		errs = append(errs, errors.New("strava client_id, client_secret and refresh_token are required (secrets file or STRAVA_CLIENT_ID, STRAVA_CLIENT_SECRET and STRAVA_REFRESH_TOKEN)"))
+	}
+	if err := c.TokenStore.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Athletes.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Notify.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Alerts.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(c.ActivityTypes) == 0 {
+		errs = append(errs, errors.New("ACTIVITY_TYPES must list at least one type"))
+	}
+	if c.ActivityTable != "" && c.ActivityCollection != "" {
+		errs = append(errs, errors.New("ACTIVITY_BIGQUERY_TABLE and ACTIVITY_FIRESTORE_COLLECTION are mutually exclusive"))
+	}
+	if c.ActivityTable != "" {
+		if _, _, _, err := parseBigQueryTable(c.ActivityTable, c.GCPProjectID); err != nil {
+			errs = append(errs, fmt.Errorf("invalid ACTIVITY_BIGQUERY_TABLE: %w", err))
+		}
+	}
+	if c.ActivityCollection != "" && c.GCPProjectID == "" {
+		errs = append(errs, errors.New("GCP_PROJECT_ID is required for ACTIVITY_FIRESTORE_COLLECTION"))
+	}
+	if c.Subscription != "" && c.GCPProjectID == "" {
+		errs = append(errs, errors.New("GCP_PROJECT_ID is required for PUBSUB_SUBSCRIPTION"))
+	}
+	return errors.Join(errs...)
+}
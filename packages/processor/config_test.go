@@ -0,0 +1,151 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_SecretsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "strava_auth.json")
+	if err := os.WriteFile(path, []byte(`{"client_id":123,"client_secret":"file-secret","refresh_token":"file-refresh"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("STRAVA_AUTH_FILE", path)
+	t.Setenv("STRAVA_CLIENT_SECRET", "env-secret")
+	t.Setenv("GCP_BUCKET_NAME", "bucket")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.StravaClientID != 123 || cfg.StravaClientSecret != "file-secret" || cfg.StravaRefreshToken != "file-refresh" {
+		t.Errorf("expected the secrets file to take precedence, got %+v", cfg)
+	}
+	if cfg.TimeZone.String() != DefaultTimeZone {
+		t.Errorf("expected default time zone, got %s", cfg.TimeZone)
+	}
+	if strings.Join(cfg.ActivityTypes, ",") != DefaultActivityTypes {
+		t.Errorf("expected default activity types, got %v", cfg.ActivityTypes)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected valid config, got %v", err)
+	}
+}
+
+func TestLoadConfig_EnvCredentials(t *testing.T) {
+	t.Setenv("STRAVA_AUTH_FILE", filepath.Join(t.TempDir(), "missing.json"))
+	t.Setenv("STRAVA_CLIENT_ID", "456")
+	t.Setenv("STRAVA_CLIENT_SECRET", "env-secret")
+	t.Setenv("STRAVA_REFRESH_TOKEN", "env-refresh")
+	t.Setenv("ACTIVITY_TYPES", " Ride, Run ,")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.StravaClientID != 456 || cfg.StravaClientSecret != "env-secret" || cfg.StravaRefreshToken != "env-refresh" {
+		t.Errorf("expected credentials from the environment, got %+v", cfg)
+	}
+	if strings.Join(cfg.ActivityTypes, ",") != "Ride,Run" {
+		t.Errorf("expected trimmed activity types, got %q", cfg.ActivityTypes)
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "GCP_BUCKET_NAME") {
+		t.Errorf("expected missing bucket to be reported, got %v", err)
+	}
+}
+
+func TestLoadConfig_ReportsAllErrors(t *testing.T) {
+	t.Setenv("STRAVA_AUTH_FILE", filepath.Join(t.TempDir(), "missing.json"))
+	t.Setenv("ATHLETE_TIMEZONE", "Nowhere/Special")
+	t.Setenv("STRAVA_CLIENT_ID", "abc")
+
+	_, err := LoadConfig()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"ATHLETE_TIMEZONE", "STRAVA_CLIENT_ID"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %s in %q", want, err)
+		}
+	}
+}
+
+func TestLoadConfig_TokenStore(t *testing.T) {
+	t.Setenv("STRAVA_AUTH_FILE", filepath.Join(t.TempDir(), "missing.json"))
+	t.Setenv("STRAVA_CLIENT_ID", "456")
+	t.Setenv("STRAVA_CLIENT_SECRET", "env-secret")
+	t.Setenv("GCP_BUCKET_NAME", "bucket")
+	t.Setenv("TOKEN_STORE", "file")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "STRAVA_ATHLETE_ID") {
+		t.Errorf("expected the missing athlete ID to be reported, got %v", err)
+	}
+
+	t.Setenv("STRAVA_ATHLETE_ID", "789")
+	if cfg, err = LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected the token store to stand in for the refresh token, got %v", err)
+	}
+}
+
+func TestLoadConfig_Athletes(t *testing.T) {
+	t.Setenv("STRAVA_AUTH_FILE", filepath.Join(t.TempDir(), "missing.json"))
+	t.Setenv("STRAVA_CLIENT_ID", "456")
+	t.Setenv("STRAVA_CLIENT_SECRET", "env-secret")
+	t.Setenv("GCP_BUCKET_NAME", "bucket")
+	t.Setenv("TOKEN_STORE", "file")
+	t.Setenv("ATHLETE_REGISTRY", "gs://bucket/athletes.json")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected the registry to stand in for the refresh token and athlete ID, got %v", err)
+	}
+
+	t.Setenv("ATHLETE_REGISTRY", "gs://bucket")
+	if cfg, err = LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "ATHLETE_REGISTRY") {
+		t.Errorf("expected the invalid registry to be reported, got %v", err)
+	}
+}
+
+func TestConfig_ValidateActivitySink(t *testing.T) {
+	cfg := &Config{
+		BucketName:         "bucket",
+		StravaClientID:     1,
+		StravaClientSecret: "secret",
+		StravaRefreshToken: "refresh",
+		ActivityTypes:      []string{"Ride"},
+		ActivityTable:      "strava.activities",
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "ACTIVITY_BIGQUERY_TABLE") {
+		t.Errorf("expected a dataset-only table without GCP_PROJECT_ID to be reported, got %v", err)
+	}
+
+	cfg.GCPProjectID = "project"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected valid config, got %v", err)
+	}
+
+	cfg.ActivityCollection = "activities"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("expected both activity sinks to be rejected, got %v", err)
+	}
+
+	cfg.ActivityTable, cfg.GCPProjectID = "", ""
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "ACTIVITY_FIRESTORE_COLLECTION") {
+		t.Errorf("expected the Firestore sink to need GCP_PROJECT_ID, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	// Webhook aspect types
+	AspectCreate = "create"
+	AspectUpdate = "update"
+	AspectDelete = "delete"
+
+	// ObjectActivity is the webhook object type of activity events.
+	ObjectActivity = "activity"
+)
+
+// ErrInvalidEvent marks messages that can never be processed, which are
+// acknowledged rather than retried.
+var ErrInvalidEvent = errors.New("invalid event")
+
+// Event is a Strava webhook event as published by the dispatcher.
+type Event struct {
+	// Activity is the detailed Strava activity when the dispatcher
+	// enriched the event, which saves fetching it again.
+	Activity   json.RawMessage `json:"activity,omitempty"`
+	AspectType string          `json:"aspect_type"`
+	ObjectType string          `json:"object_type"`
+	EventTime  int64           `json:"event_time"`
+	ObjectID   int64           `json:"object_id"`
+	OwnerID    int64           `json:"owner_id"`
+}
+
+// DecodeEvent decodes Pub/Sub message data in either of the dispatcher's
+// formats: the raw webhook JSON or a CloudEvents envelope around it.
+func DecodeEvent(data []byte) (Event, error) {
+	var envelope struct {
+		SpecVersion string          `json:"specversion"`
+		Data        json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return Event{}, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+	if envelope.SpecVersion != "" {
+		data = envelope.Data
+	}
+
+	var event Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return Event{}, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+	if event.ObjectID == 0 || event.AspectType == "" || event.ObjectType == "" {
+		return Event{}, fmt.Errorf("%w: aspect_type, object_type and object_id are required", ErrInvalidEvent)
+	}
+	return event, nil
+}
+
+// PushRequest is the body of a Pub/Sub push delivery, which is also what
+// Eventarc sends a Cloud Function triggered by a topic.
+type PushRequest struct {
+	Message struct {
+		Attributes map[string]string `json:"attributes"`
+		MessageID  string            `json:"messageId"`
+		// Data is base64 in the JSON, decoded by encoding/json.
+		Data []byte `json:"data"`
+	} `json:"message"`
+	Subscription string `json:"subscription"`
+}
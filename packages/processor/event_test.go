@@ -0,0 +1,40 @@
+package processor
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeEvent(t *testing.T) {
+	raw := `{"aspect_type":"create","object_type":"activity","object_id":42,"owner_id":7,"event_time":1700000000}`
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"raw", raw},
+		{"cloudevents", `{"specversion":"1.0","type":"com.strava.webhook.activity.create","data":` + raw + `}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := DecodeEvent([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("DecodeEvent failed: %v", err)
+			}
+			if event.AspectType != AspectCreate || event.ObjectType != ObjectActivity || event.ObjectID != 42 || event.OwnerID != 7 || event.EventTime != 1700000000 {
+				t.Errorf("unexpected event %+v", event)
+			}
+		})
+	}
+}
+
+func TestDecodeEvent_Invalid(t *testing.T) {
+	for _, data := range []string{
+		`not json`,
+		`{"aspect_type":"create","object_type":"activity"}`,
+		`{"specversion":"1.0","data":"not an event"}`,
+	} {
+		if _, err := DecodeEvent([]byte(data)); !errors.Is(err, ErrInvalidEvent) {
+			t.Errorf("DecodeEvent(%s): expected ErrInvalidEvent, got %v", data, err)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/andy-esch/desirelines/packages/strava"
+	"google.golang.org/api/iterator"
+)
+
+// firestoreActivity is the Firestore representation of an ActivityRecord.
+// Date and Year duplicate the local start date so tools can query a day or
+// a year without time zone arithmetic.
+type firestoreActivity struct {
+	StartDate          time.Time `firestore:"start_date"`
+	StartDateLocal     time.Time `firestore:"start_date_local"`
+	ProcessedAt        time.Time `firestore:"processed_at"`
+	Date               string    `firestore:"date"`
+	Name               string    `firestore:"name"`
+	Type               string    `firestore:"type"`
+	SportType          string    `firestore:"sport_type"`
+	Timezone           string    `firestore:"timezone"`
+	ID                 int64     `firestore:"id"`
+	AthleteID          int64     `firestore:"athlete_id"`
+	Distance           float64   `firestore:"distance"`
+	TotalElevationGain float64   `firestore:"total_elevation_gain"`
+	AverageSpeed       float64   `firestore:"average_speed"`
+	MaxSpeed           float64   `firestore:"max_speed"`
+	MovingTime         int       `firestore:"moving_time"`
+	ElapsedTime        int       `firestore:"elapsed_time"`
+	Year               int       `firestore:"year"`
+	Trainer            bool      `firestore:"trainer"`
+	Manual             bool      `firestore:"manual"`
+	Private            bool      `firestore:"private"`
+}
+
+// toFirestoreActivity converts record to its document.
+func toFirestoreActivity(record ActivityRecord) firestoreActivity {
+	activity := record.Activity
+	return firestoreActivity{
+		StartDate:          activity.StartDate,
+		StartDateLocal:     activity.StartDateLocal,
+		ProcessedAt:        record.ProcessedAt,
+		Date:               activity.Date(),
+		Name:               activity.Name,
+		Type:               activity.Type,
+		SportType:          activity.SportType,
+		Timezone:           activity.Timezone,
+		ID:                 activity.ID,
+		AthleteID:          record.OwnerID,
+		Distance:           activity.Distance,
+		TotalElevationGain: activity.TotalElevationGain,
+		AverageSpeed:       activity.AverageSpeed,
+		MaxSpeed:           activity.MaxSpeed,
+		MovingTime:         activity.MovingTime,
+		ElapsedTime:        activity.ElapsedTime,
+		Year:               activity.StartDateLocal.Year(),
+		Trainer:            activity.Trainer,
+		Manual:             activity.Manual,
+		Private:            activity.Private,
+	}
+}
+
+// record converts the document back to an ActivityRecord.
+func (a firestoreActivity) record() ActivityRecord {
+	return ActivityRecord{
+		ProcessedAt: a.ProcessedAt,
+		Activity: strava.Activity{
+			StartDate:          a.StartDate,
+			StartDateLocal:     a.StartDateLocal.UTC(),
+			Name:               a.Name,
+			Type:               a.Type,
+			SportType:          a.SportType,
+			Timezone:           a.Timezone,
+			ID:                 a.ID,
+			Distance:           a.Distance,
+			TotalElevationGain: a.TotalElevationGain,
+			AverageSpeed:       a.AverageSpeed,
+			MaxSpeed:           a.MaxSpeed,
+			MovingTime:         a.MovingTime,
+			ElapsedTime:        a.ElapsedTime,
+			Trainer:            a.Trainer,
+			Manual:             a.Manual,
+			Private:            a.Private,
+		},
+		OwnerID: a.AthleteID,
+	}
+}
+
+// FirestoreSink keeps fetched activities as documents in a Firestore
+// collection, one per activity ID, so a redelivered event overwrites its
+// activity's document rather than adding another.
+type FirestoreSink struct {
+	client     *firestore.Client
+	collection string
+}
+
+// NewFirestoreSink creates a sink using collection in the project's
+// default database.
+func NewFirestoreSink(ctx context.Context, projectID, collection string) (*FirestoreSink, error) {
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	Logger.Info("Activity collection initialized", "collection", collection)
+	return &FirestoreSink{client: client, collection: collection}, nil
+}
+
+// Write implements the ActivitySink interface.
+func (s *FirestoreSink) Write(ctx context.Context, record ActivityRecord) error {
+	ref := s.client.Collection(s.collection).Doc(strconv.FormatInt(record.Activity.ID, 10))
+	if _, err := ref.Set(ctx, toFirestoreActivity(record)); err != nil {
+		return fmt.Errorf("failed to write activity %d: %w", record.Activity.ID, err)
+	}
+	return nil
+}
+
+// Activities returns athleteID's activities with local start dates from
+// from to to inclusive, as YYYY-MM-DD, ordered by date. The query needs a
+// composite index on athlete_id and date.
+func (s *FirestoreSink) Activities(ctx context.Context, athleteID int64, from, to string) ([]ActivityRecord, error) {
+	iter := s.client.Collection(s.collection).
+		Where("athlete_id", "==", athleteID).
+		Where("date", ">=", from).
+		Where("date", "<=", to).
+		OrderBy("date", firestore.Asc).
+		Documents(ctx)
+	defer iter.Stop()
+
+	var records []ActivityRecord
+	for {
+		snapshot, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to query activities of athlete %d: %w", athleteID, err)
+		}
+		var doc firestoreActivity
+		if err := snapshot.DataTo(&doc); err != nil {
+			return nil, fmt.Errorf("failed to decode activity %s: %w", snapshot.Ref.ID, err)
+		}
+		records = append(records, doc.record())
+	}
+}
+
+// Close implements the ActivitySink interface.
+func (s *FirestoreSink) Close() error {
+	if err := s.client.Close(); err != nil {
+		return fmt.Errorf("failed to close Firestore client: %w", err)
+	}
+	return nil
+}
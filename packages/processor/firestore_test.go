@@ -0,0 +1,22 @@
+package processor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFirestoreActivity_RoundTrip(t *testing.T) {
+	activity := ride(42, "2024-12-31T23:30:00Z", 16093.4)
+	activity.StartDate = time.Date(2025, time.January, 1, 4, 30, 0, 0, time.UTC)
+	activity.Name = "New Year's Eve ride"
+	activity.Trainer = true
+	record := ActivityRecord{ProcessedAt: time.Date(2025, time.January, 1, 5, 0, 0, 0, time.UTC), Activity: activity, OwnerID: 7}
+
+	doc := toFirestoreActivity(record)
+	if doc.Date != "2024-12-31" || doc.Year != 2024 || doc.AthleteID != 7 {
+		t.Errorf("expected the local date and owner to be queryable, got date %q, year %d, athlete %d", doc.Date, doc.Year, doc.AthleteID)
+	}
+	if got := doc.record(); got != record {
+		t.Errorf("round trip changed the record:\n got %+v\nwant %+v", got, record)
+	}
+}
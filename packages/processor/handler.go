@@ -0,0 +1,280 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"cloud.google.com/go/pubsub/v2"
+	"github.com/andy-esch/desirelines/packages/alert"
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/notify"
+	"github.com/andy-esch/desirelines/packages/strava"
+	"github.com/andy-esch/desirelines/packages/tokenstore"
+	"github.com/google/uuid"
+)
+
+// reasonInvalidEvent is the skip reason of messages that can't be decoded.
+const reasonInvalidEvent = "invalid_event"
+
+// maxPushBodyBytes caps push request bodies; enriched events with a full
+// activity are a few tens of kilobytes.
+const maxPushBodyBytes = 10 << 20
+
+// Handler delivers Pub/Sub messages to a Processor, either pushed over
+// HTTP (Pub/Sub push subscriptions and Eventarc triggers share the format)
+// or pulled by Receive.
+type Handler struct {
+	processor *Processor
+	store     Store
+	// sink receives fetched activities, nil without ACTIVITY_BIGQUERY_TABLE
+	// or ACTIVITY_FIRESTORE_COLLECTION
+	sink ActivitySink
+	// tokens is the shared token store, nil without TOKEN_STORE
+	tokens tokenstore.Store
+	// registryStore reads a Cloud Storage ATHLETE_REGISTRY, nil otherwise
+	registryStore Store
+	// alerter posts failed events, nil without ALERT_WEBHOOK_URL
+	alerter *alert.Alerter
+}
+
+// NewHandler creates a handler from the environment, writing to
+// GCP_BUCKET_NAME and fetching activities with the app's Strava
+// credentials.
+func NewHandler(ctx context.Context) (*Handler, error) {
+	if err := config.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", config.FileEnv, err)
+	}
+	cfg, err := LoadConfig()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	if err := SetLogLevel(cfg.LogLevel); err != nil {
+		return nil, err
+	}
+
+	store, err := NewGCSStore(ctx, cfg.BucketName)
+	if err != nil {
+		return nil, err
+	}
+	var opts []strava.Option
+	var tokens tokenstore.Store
+	if cfg.TokenStore.Enabled() {
+		tokens, err = tokenstore.Open(ctx, cfg.TokenStore)
+		if err != nil {
+			_ = store.Close()
+			return nil, fmt.Errorf("failed to open token store: %w", err)
+		}
+		// With the athlete registry, each athlete's client shares theirs
+		if !cfg.TokenStore.PerAthlete {
+			opts = append(opts, strava.WithTokenStore(tokens, cfg.TokenStore.AthleteID))
+		}
+		Logger.Info("Sharing Strava tokens", "token_store", cfg.TokenStore.Backend, "athlete_id", cfg.TokenStore.AthleteID)
+	}
+	client := strava.New(cfg.StravaClientID, cfg.StravaClientSecret, cfg.StravaRefreshToken, opts...)
+
+	var processorOpts []Option
+	var sink ActivitySink
+	switch {
+	case cfg.ActivityTable != "":
+		sink, err = NewBigQuerySink(ctx, cfg.ActivityTable, cfg.GCPProjectID)
+	case cfg.ActivityCollection != "":
+		sink, err = NewFirestoreSink(ctx, cfg.GCPProjectID, cfg.ActivityCollection)
+	}
+	if err != nil {
+		_ = store.Close()
+		if tokens != nil {
+			_ = tokens.Close()
+		}
+		return nil, fmt.Errorf("failed to create activity sink: %w", err)
+	}
+	if sink != nil {
+		processorOpts = append(processorOpts, WithActivitySink(sink))
+	}
+
+	var registryStore Store
+	if cfg.Athletes.Enabled() {
+		load := athletes.FileLoader(cfg.Athletes.Location)
+		if bucket, object, ok := cfg.Athletes.GCS(); ok {
+			registryStore, err = NewGCSStore(ctx, bucket)
+			if err != nil {
+				_ = store.Close()
+				if sink != nil {
+					_ = sink.Close()
+				}
+				if tokens != nil {
+					_ = tokens.Close()
+				}
+				return nil, fmt.Errorf("failed to open athlete registry: %w", err)
+			}
+			load = registryLoader(registryStore, object)
+		}
+		clients := athletes.NewClients(cfg.StravaClientID, cfg.StravaClientSecret, tokens)
+		processorOpts = append(processorOpts, WithAthletes(athletes.New(load, cfg.Athletes.CacheTTL), func(athlete athletes.Athlete) ActivitySource {
+			return clients.Client(athlete)
+		}))
+		Logger.Info("Serving registered athletes", "athlete_registry", cfg.Athletes.Location)
+	}
+	if cfg.Notify.Enabled() {
+		notifier := notify.Open(cfg.Notify)
+		processorOpts = append(processorOpts, WithNotifier(notifier))
+		Logger.Info("Sending goal milestone notifications", "channels", notifier.Channels())
+	}
+	if cfg.StoreStreams {
+		processorOpts = append(processorOpts, WithStreams())
+		Logger.Info("Storing activity streams")
+	}
+	if cfg.WritePrecompressed {
+		processorOpts = append(processorOpts, WithPrecompressed())
+		Logger.Info("Writing precompressed chart data")
+	}
+	alerter := alert.Open(cfg.Alerts, "processor")
+	if alerter != nil {
+		Logger.Info("Alerting on failed events", "format", cfg.Alerts.Format)
+	}
+	Logger.Info("Processor initialized", "bucket", cfg.BucketName, "activity_types", cfg.ActivityTypes, "time_zone", cfg.TimeZone.String())
+	return &Handler{processor: NewProcessor(store, client, cfg, processorOpts...), store: store, sink: sink, tokens: tokens, registryStore: registryStore, alerter: alerter}, nil
+}
+
+// registryLoader reads the athlete registry from object in store.
+func registryLoader(store Store, object string) athletes.Loader {
+	return func(ctx context.Context) ([]byte, error) {
+		data, _, err := store.Read(ctx, object)
+		return data, err
+	}
+}
+
+// NewHandlerWithProcessor creates a handler around an existing processor,
+// posting failed events to alerter if it is not nil (useful for testing).
+func NewHandlerWithProcessor(processor *Processor, alerter *alert.Alerter) *Handler {
+	return &Handler{processor: processor, store: processor.store, alerter: alerter}
+}
+
+// Close releases the store, the activity sink, the token store and the
+// athlete registry's store.
+func (h *Handler) Close() error {
+	err := h.store.Close()
+	if h.sink != nil {
+		err = errors.Join(err, h.sink.Close())
+	}
+	if h.tokens != nil {
+		err = errors.Join(err, h.tokens.Close())
+	}
+	if h.registryStore != nil {
+		err = errors.Join(err, h.registryStore.Close())
+	}
+	return err
+}
+
+// HandleMessage processes one message's data. A nil error means the
+// message is done with and should be acknowledged, including messages
+// that can never be processed; otherwise it should be redelivered.
+func (h *Handler) HandleMessage(ctx context.Context, data []byte, messageID string) (Result, error) {
+	correlationID := uuid.New().String()
+	event, err := DecodeEvent(data)
+	if err != nil {
+		Logger.ErrorContext(ctx, "Dropping undecodable message", "correlation_id", correlationID, "message_id", messageID, "error", err)
+		return Result{Outcome: OutcomeSkipped, Reason: reasonInvalidEvent}, nil
+	}
+
+	logArgs := []any{
+		"correlation_id", correlationID,
+		"message_id", messageID,
+		"aspect_type", event.AspectType,
+		"object_type", event.ObjectType,
+		"object_id", event.ObjectID,
+		"owner_id", event.OwnerID,
+	}
+	result, err := h.processor.Process(ctx, event)
+	if errors.Is(err, ErrInvalidEvent) {
+		Logger.ErrorContext(ctx, "Dropping unprocessable event", append(logArgs, "error", err)...)
+		h.alert(ctx, "invalid_event", "Dropped an unprocessable event", event, err)
+		return Result{Outcome: OutcomeSkipped, Reason: reasonInvalidEvent}, nil
+	}
+	if err != nil {
+		Logger.ErrorContext(ctx, "Failed to process event", append(logArgs, "error", err)...)
+		h.alert(ctx, "failure", "Failed to process event, Pub/Sub will redeliver it", event, err)
+		return result, err
+	}
+	Logger.InfoContext(ctx, "Processed event", append(logArgs, "outcome", result.Outcome, "reason", result.Reason, "year", result.Year)...)
+	return result, nil
+}
+
+// alert posts a failed event. Alerts are throttled per key, so an outage
+// redelivering every event posts once per cooldown.
+func (h *Handler) alert(ctx context.Context, key, title string, event Event, err error) {
+	sendErr := h.alerter.Send(ctx, alert.Alert{
+		Key:   key,
+		Title: title,
+		Text:  err.Error(),
+		Fields: []alert.Field{
+			{Name: "Event", Value: fmt.Sprintf("%s %s %d", event.ObjectType, event.AspectType, event.ObjectID)},
+			{Name: "Owner", Value: strconv.FormatInt(event.OwnerID, 10)},
+		},
+	})
+	if sendErr != nil {
+		Logger.WarnContext(ctx, "Failed to send alert", "object_id", event.ObjectID, "error", sendErr)
+	}
+}
+
+// ServeHTTP accepts push deliveries on POST and answers liveness probes on
+// /live. Failed events get a 500 so Pub/Sub redelivers them.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == "/live" {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	var push PushRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPushBodyBytes)).Decode(&push); err != nil {
+		// Redelivering a malformed push wouldn't fix it
+		Logger.ErrorContext(r.Context(), "Dropping malformed push request", "error", err)
+		writeJSON(w, http.StatusOK, Result{Outcome: OutcomeSkipped, Reason: reasonInvalidEvent})
+		return
+	}
+
+	result, err := h.HandleMessage(r.Context(), push.Message.Data, push.Message.MessageID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Processing failed"})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		Logger.Error("Failed to encode response", "error", err)
+	}
+}
+
+// Receive pulls messages from subscription until ctx is done, acking
+// those HandleMessage is done with and nacking the rest for redelivery.
+func (h *Handler) Receive(ctx context.Context, projectID, subscription string) error {
+	client, err := pubsub.NewClient(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to create Pub/Sub client: %w", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	Logger.Info("Receiving events", "subscription", subscription)
+	return client.Subscriber(subscription).Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+		if _, err := h.HandleMessage(ctx, msg.Data, msg.ID); err != nil {
+			msg.Nack()
+			return
+		}
+		msg.Ack()
+	})
+}
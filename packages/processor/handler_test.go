@@ -0,0 +1,123 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/alert"
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+func pushBody(t *testing.T, data string) *bytes.Buffer {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{
+		"message": map[string]any{
+			"data":      base64.StdEncoding.EncodeToString([]byte(data)),
+			"messageId": "1",
+		},
+		"subscription": "projects/p/subscriptions/s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewBuffer(body)
+}
+
+func TestHandler_Push(t *testing.T) {
+	store := newMemoryStore()
+	source := &fakeStrava{activities: map[int64]strava.Activity{1: ride(1, "2025-03-01T08:00:00Z", 20000)}}
+	handler := NewHandlerWithProcessor(newTestProcessor(store, source), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/", pushBody(t, `{"aspect_type":"create","object_type":"activity","object_id":1}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var result Result
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || result.Outcome != OutcomeCreated {
+		t.Errorf("unexpected response %s", w.Body)
+	}
+	if _, ok := store.summary(t, 2025)["2025-03-01"]; !ok {
+		t.Error("expected the activity to be counted")
+	}
+}
+
+func TestHandler_PushFailureIsRedelivered(t *testing.T) {
+	var alerts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		alerts = append(alerts, string(body))
+	}))
+	defer server.Close()
+	alerter := alert.New(server.URL, alert.FormatDiscord, "processor")
+	handler := NewHandlerWithProcessor(newTestProcessor(newMemoryStore(), &fakeStrava{err: &strava.APIError{StatusCode: 503}}), alerter)
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodPost, "/", pushBody(t, `{"aspect_type":"create","object_type":"activity","object_id":1}`))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected 500 so Pub/Sub redelivers, got %d", w.Code)
+		}
+	}
+	if len(alerts) != 1 || !strings.Contains(alerts[0], "Failed to process event") || !strings.Contains(alerts[0], "activity create 1") {
+		t.Errorf("expected one alert for the redelivered failure, got %q", alerts)
+	}
+}
+
+func TestHandler_InvalidMessagesAreAcked(t *testing.T) {
+	handler := NewHandlerWithProcessor(newTestProcessor(newMemoryStore(), &fakeStrava{}), nil)
+
+	for name, body := range map[string]*bytes.Buffer{
+		"malformed push": bytes.NewBufferString(`{"message":`),
+		"invalid event":  pushBody(t, `{"object_type":"activity"}`),
+		"no start date":  pushBody(t, `{"aspect_type":"create","object_type":"activity","object_id":1,"activity":{"id":1,"type":"Ride"}}`),
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", body))
+			if w.Code != http.StatusOK {
+				t.Errorf("expected 200 so Pub/Sub stops redelivering, got %d", w.Code)
+			}
+			var result Result
+			if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || result.Reason != reasonInvalidEvent {
+				t.Errorf("unexpected response %s", w.Body)
+			}
+		})
+	}
+}
+
+func TestHandler_HandleMessage(t *testing.T) {
+	handler := NewHandlerWithProcessor(newTestProcessor(newMemoryStore(), &fakeStrava{}), nil)
+
+	result, err := handler.HandleMessage(context.Background(), []byte(`{"aspect_type":"update","object_type":"athlete","object_id":7}`), "1")
+	if err != nil || result.Reason != reasonNonActivity {
+		t.Errorf("expected athlete event to be skipped, got %+v, %v", result, err)
+	}
+}
+
+func TestHandler_Routes(t *testing.T) {
+	handler := NewHandlerWithProcessor(newTestProcessor(newMemoryStore(), &fakeStrava{}), nil)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/live", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected /live to return 200, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", w.Code)
+	}
+}
@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/notify"
+)
+
+// WithNotifier tells athletes through notifier when an event takes one of
+// their goals past a milestone in their notification settings.
+func WithNotifier(notifier *notify.Notifier) Option {
+	return func(p *Processor) {
+		p.notifier = notifier
+	}
+}
+
+// notifyMilestones notifies athleteID of the milestones of goals that the
+// change from the previous summary to summary crossed. Only a change that
+// was written gets here, so a redelivered event never notifies twice.
+// Failures are logged rather than failing the event, as redelivering it
+// wouldn't retry them.
+func (p *Processor) notifyMilestones(ctx context.Context, athleteID int64, year int, previous []byte, summary aggregation.Summary, goals []aggregation.Goal) {
+	if p.notifier == nil || athleteID == 0 || len(goals) == 0 {
+		return
+	}
+	settings, ok := p.notificationSettings(ctx, athleteID)
+	if !ok {
+		return
+	}
+	before := aggregation.Summary{}
+	if previous != nil {
+		// Parsed successfully before the change was applied
+		_ = json.Unmarshal(previous, &before)
+	}
+
+	for _, goal := range goals {
+		if _, _, err := goal.Dates(year); err != nil || goal.Target <= 0 {
+			continue
+		}
+		progress := aggregation.GoalProgress(summary, year, goal)
+		percent, crossed := notify.Crossed(settings.Thresholds(), goal.Target, aggregation.GoalProgress(before, year, goal), progress)
+		if !crossed {
+			continue
+		}
+		milestone := notify.Milestone{
+			ReachedAt: p.now(),
+			Goal:      goal,
+			Progress:  progress,
+			AthleteID: athleteID,
+			Year:      year,
+			Percent:   percent,
+		}
+		sent, err := p.notifier.Notify(ctx, settings, milestone)
+		if err != nil {
+			Logger.ErrorContext(ctx, "Failed to send milestone notification", "athlete_id", athleteID, "goal", goal.ID, "percent", percent, "error", err)
+		}
+		if len(sent) > 0 {
+			Logger.InfoContext(ctx, "Sent milestone notification", "athlete_id", athleteID, "goal", goal.ID, "percent", percent, "channels", sent)
+		}
+	}
+}
+
+// notificationSettings returns athleteID's notification settings, and
+// false if they have none. Settings that can't be read or are invalid are
+// logged and treated as none.
+func (p *Processor) notificationSettings(ctx context.Context, athleteID int64) (notify.Settings, bool) {
+	var settings notify.Settings
+	data, _, err := p.store.Read(ctx, notify.SettingsBlob(athleteID))
+	if err != nil {
+		Logger.WarnContext(ctx, "Failed to read notification settings", "blob", notify.SettingsBlob(athleteID), "error", err)
+		return settings, false
+	}
+	if data == nil {
+		return settings, false
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		Logger.WarnContext(ctx, "Ignoring unparseable notification settings", "blob", notify.SettingsBlob(athleteID), "error", err)
+		return settings, false
+	}
+	if err := settings.Validate(); err != nil {
+		Logger.WarnContext(ctx, "Ignoring invalid notification settings", "blob", notify.SettingsBlob(athleteID), "error", err)
+		return settings, false
+	}
+	return settings, !settings.Disabled
+}
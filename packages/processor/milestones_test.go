@@ -0,0 +1,72 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/notify"
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+// recordingChannel records the milestones sent to settings with a webhook.
+type recordingChannel struct {
+	sent []notify.Milestone
+}
+
+func (c *recordingChannel) Name() string { return "recording" }
+func (c *recordingChannel) Configured(settings notify.Settings) bool {
+	return settings.WebhookURL != ""
+}
+
+func (c *recordingChannel) Send(_ context.Context, _ notify.Settings, milestone notify.Milestone) error {
+	c.sent = append(c.sent, milestone)
+	return nil
+}
+
+func TestProcess_NotifiesMilestones(t *testing.T) {
+	store := newMemoryStore()
+	store.objects[aggregation.GoalsBlob(7, 2025)] = []byte(`{"goals":[{"id":"rides","type":"count","target":4}]}`)
+	store.objects[notify.SettingsBlob(7)] = []byte(`{"webhook_url":"https://example.com/hook"}`)
+	source := &fakeStrava{activities: map[int64]strava.Activity{
+		1: ride(1, "2025-03-01T08:00:00Z", 20000),
+		2: ride(2, "2025-03-02T08:00:00Z", 20000),
+		3: ride(3, "2025-03-03T08:00:00Z", 20000),
+	}}
+	channel := &recordingChannel{}
+	p := newTestProcessor(store, source, WithNotifier(notify.New(channel)))
+
+	for _, id := range []int64{1, 1, 2, 3} {
+		event := createEvent(id)
+		event.OwnerID = 7
+		if _, err := p.Process(context.Background(), event); err != nil {
+			t.Fatalf("Process(%d) failed: %v", id, err)
+		}
+	}
+	// The redelivered first ride doesn't notify again
+	if len(channel.sent) != 3 {
+		t.Fatalf("expected a notification per milestone, got %+v", channel.sent)
+	}
+	for i, want := range []int{25, 50, 75} {
+		if got := channel.sent[i]; got.Percent != want || got.AthleteID != 7 || got.Goal.ID != "rides" || got.Progress != float64(i+1) {
+			t.Errorf("notification %d: expected %d%%, got %+v", i, want, got)
+		}
+	}
+}
+
+func TestProcess_NotifiesOnlyWithSettings(t *testing.T) {
+	store := newMemoryStore()
+	store.objects[aggregation.GoalsBlob(7, 2025)] = []byte(`{"goals":[{"id":"rides","type":"count","target":1}]}`)
+	source := &fakeStrava{activities: map[int64]strava.Activity{1: ride(1, "2025-03-01T08:00:00Z", 20000)}}
+	channel := &recordingChannel{}
+	p := newTestProcessor(store, source, WithNotifier(notify.New(channel)))
+
+	event := createEvent(1)
+	event.OwnerID = 7
+	if _, err := p.Process(context.Background(), event); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if len(channel.sent) != 0 {
+		t.Errorf("expected no notifications without settings, got %+v", channel.sent)
+	}
+}
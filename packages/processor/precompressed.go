@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+)
+
+// precompressedSuffix names the gzipped sibling of a chart data blob, which
+// the API gateway serves first with SERVE_PRECOMPRESSED.
+const precompressedSuffix = ".gz"
+
+// WithPrecompressed also writes every summary and distances blob gzipped to
+// its .gz sibling, so the gateway's SERVE_PRECOMPRESSED never serves a
+// sibling older than the blob.
+func WithPrecompressed() Option {
+	return func(p *Processor) {
+		p.precompress = true
+	}
+}
+
+// chartBlob is a chart data blob as read, with the generations to replace
+// it and its .gz sibling at.
+type chartBlob struct {
+	name         string
+	data         []byte
+	generation   int64
+	gzGeneration int64
+	// gzCurrent reports whether the sibling holds data, as it always does
+	// without precompression.
+	gzCurrent bool
+}
+
+// current reports whether the blob, and its sibling, already hold data.
+func (b chartBlob) current(data []byte) bool {
+	return b.gzCurrent && bytes.Equal(b.data, data)
+}
+
+// readChart reads the chart data blob name and, with precompression, its
+// .gz sibling.
+func (p *Processor) readChart(ctx context.Context, name string) (chartBlob, error) {
+	data, generation, err := p.store.Read(ctx, name)
+	if err != nil {
+		return chartBlob{}, err
+	}
+	blob := chartBlob{name: name, data: data, generation: generation, gzCurrent: true}
+	if !p.precompress {
+		return blob, nil
+	}
+
+	compressed, gzGeneration, err := p.store.Read(ctx, name+precompressedSuffix)
+	if err != nil {
+		return chartBlob{}, err
+	}
+	blob.gzGeneration = gzGeneration
+	if compressed != nil {
+		decompressed, err := gunzip(compressed)
+		blob.gzCurrent = err == nil && bytes.Equal(decompressed, data)
+	} else {
+		blob.gzCurrent = data == nil
+	}
+	return blob, nil
+}
+
+// writeChart replaces blob with data, writing the .gz sibling first with
+// precompression. Each write only succeeds if its object is unchanged since
+// readChart, and ErrConflict from either means the caller should start
+// again from a fresh read.
+func (p *Processor) writeChart(ctx context.Context, blob chartBlob, data []byte) error {
+	if p.precompress {
+		compressed, err := gzipBytes(data)
+		if err != nil {
+			return err
+		}
+		if err := p.store.Write(ctx, blob.name+precompressedSuffix, compressed, blob.gzGeneration); err != nil {
+			return err
+		}
+	}
+	return p.store.Write(ctx, blob.name, data, blob.generation)
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress: %w", err)
+	}
+	return compressed.Bytes(), nil
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = gz.Close() }()
+	return io.ReadAll(gz)
+}
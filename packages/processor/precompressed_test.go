@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+func TestProcess_WritesPrecompressedSiblings(t *testing.T) {
+	store := newMemoryStore()
+	source := &fakeStrava{activities: map[int64]strava.Activity{1: ride(1, "2025-03-01T08:00:00Z", 20000)}}
+	p := newTestProcessor(store, source, WithPrecompressed())
+
+	if _, err := p.Process(context.Background(), createEvent(1)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	for _, name := range []string{aggregation.SummaryBlob(2025), aggregation.DistancesBlob(2025)} {
+		compressed := store.objects[name+precompressedSuffix]
+		if compressed == nil {
+			t.Fatalf("expected %s%s to be written", name, precompressedSuffix)
+		}
+		data, err := gunzip(compressed)
+		if err != nil {
+			t.Fatalf("invalid gzip in %s%s: %v", name, precompressedSuffix, err)
+		}
+		if string(data) != string(store.objects[name]) {
+			t.Errorf("expected %s%s to hold %s, got %s", name, precompressedSuffix, store.objects[name], data)
+		}
+	}
+}
+
+func TestProcess_RepairsPrecompressedSiblings(t *testing.T) {
+	tests := []struct {
+		name   string
+		damage func(store *memoryStore, name string)
+	}{
+		{"missing", func(store *memoryStore, name string) {
+			delete(store.objects, name)
+		}},
+		{"stale", func(store *memoryStore, name string) {
+			stale, _ := gzipBytes([]byte(`{}`))
+			store.objects[name] = stale
+			store.generations[name]++
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			source := &fakeStrava{activities: map[int64]strava.Activity{1: ride(1, "2025-03-01T08:00:00Z", 20000)}}
+			p := newTestProcessor(store, source, WithPrecompressed())
+			if _, err := p.Process(context.Background(), createEvent(1)); err != nil {
+				t.Fatalf("Process failed: %v", err)
+			}
+
+			names := []string{aggregation.SummaryBlob(2025), aggregation.DistancesBlob(2025)}
+			for _, name := range names {
+				tt.damage(store, name+precompressedSuffix)
+			}
+			if _, err := p.Process(context.Background(), createEvent(1)); err != nil {
+				t.Fatalf("redelivery failed: %v", err)
+			}
+
+			for _, name := range names {
+				data, err := gunzip(store.objects[name+precompressedSuffix])
+				if err != nil {
+					t.Fatalf("invalid gzip in %s%s: %v", name, precompressedSuffix, err)
+				}
+				if string(data) != string(store.objects[name]) {
+					t.Errorf("expected %s%s to be rewritten, got %s", name, precompressedSuffix, data)
+				}
+			}
+		})
+	}
+}
+
+func TestProcess_WithoutPrecompressedWritesNoSiblings(t *testing.T) {
+	store := newMemoryStore()
+	source := &fakeStrava{activities: map[int64]strava.Activity{1: ride(1, "2025-03-01T08:00:00Z", 20000)}}
+	p := newTestProcessor(store, source)
+
+	if _, err := p.Process(context.Background(), createEvent(1)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if _, ok := store.objects[aggregation.DistancesBlob(2025)+precompressedSuffix]; ok {
+		t.Error("expected no .gz sibling without WithPrecompressed")
+	}
+}
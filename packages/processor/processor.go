@@ -0,0 +1,427 @@
+// Package processor consumes the activity events the dispatcher publishes
+// and keeps each year's aggregates in Cloud Storage up to date: the per-day
+// summary_activities.json and the cumulative distances.json the web app
+// charts. It replaces the Python activity aggregator with the same blob
+// formats, so the API gateway serves either's output.
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/notify"
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+// Outcomes of processing an event.
+const (
+	OutcomeCreated = "created"
+	OutcomeDeleted = "deleted"
+	OutcomeSkipped = "skipped"
+)
+
+// Skip reasons.
+const (
+	reasonNonActivity    = "non_activity"
+	reasonUpdate         = "update_not_supported"
+	reasonNotFound       = "activity_not_found"
+	reasonActivityType   = "activity_type"
+	reasonAlreadyCounted = "already_counted"
+	reasonNotInSummary   = "not_in_summary"
+	reasonUnknownAspect  = "unknown_aspect"
+	reasonUnknownAthlete = "unknown_athlete"
+)
+
+// maxSummaryWriteRetries bounds how often a summary update is reapplied, or
+// distances rebuilt, after losing a race with a concurrent event.
+const maxSummaryWriteRetries = 5
+
+// Result describes what processing an event did.
+type Result struct {
+	Outcome string `json:"outcome"`
+	Reason  string `json:"reason,omitempty"`
+	Year    int    `json:"year,omitempty"`
+}
+
+// ActivitySource fetches activities from Strava, as *strava.Client does.
+type ActivitySource interface {
+	GetActivity(ctx context.Context, id int64) (*strava.Activity, error)
+	// ListActivities returns the athlete's activities that started
+	// between after and before.
+	ListActivities(ctx context.Context, after, before time.Time) ([]strava.Activity, error)
+}
+
+// Processor applies activity events to the aggregates.
+type Processor struct {
+	store  Store
+	source ActivitySource
+	// sink, if set, receives every fetched activity
+	sink ActivitySink
+	// registry, if set, scopes each event to its owner with sources
+	registry *athletes.Registry
+	sources  func(athletes.Athlete) ActivitySource
+	// notifier, if set, tells athletes about goal milestones
+	notifier      *notify.Notifier
+	location      *time.Location
+	now           func() time.Time
+	activityTypes []string
+	// storeStreams keeps counted activities' streams (see WithStreams)
+	storeStreams bool
+	// precompress writes chart data's .gz siblings (see WithPrecompressed)
+	precompress bool
+}
+
+// Option configures a Processor.
+type Option func(*Processor)
+
+// WithActivitySink writes every activity fetched for a create event to
+// sink before it is aggregated. A failed write fails the event, so it is
+// redelivered.
+func WithActivitySink(sink ActivitySink) Option {
+	return func(p *Processor) {
+		p.sink = sink
+	}
+}
+
+// WithAthletes serves the athletes in registry: each event's aggregates
+// are kept under its owner's prefix and their activities fetched from
+// sources(owner), and events of unregistered athletes are skipped.
+func WithAthletes(registry *athletes.Registry, sources func(athletes.Athlete) ActivitySource) Option {
+	return func(p *Processor) {
+		p.registry = registry
+		p.sources = sources
+	}
+}
+
+// NewProcessor creates a processor writing to store and fetching
+// activities from source.
+func NewProcessor(store Store, source ActivitySource, cfg *Config, opts ...Option) *Processor {
+	location := cfg.TimeZone
+	if location == nil {
+		location = time.UTC
+	}
+	p := &Processor{
+		store:         store,
+		source:        source,
+		location:      location,
+		now:           time.Now,
+		activityTypes: cfg.ActivityTypes,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Process applies event. Created activities are added to their day once
+// however often the event is redelivered; deleted ones are removed and
+// their day's total recomputed. Update events are skipped, as they were by
+// the Python aggregator.
+func (p *Processor) Process(ctx context.Context, event Event) (Result, error) {
+	if event.ObjectType != ObjectActivity {
+		return Result{Outcome: OutcomeSkipped, Reason: reasonNonActivity}, nil
+	}
+	if p.registry != nil {
+		athlete, err := p.registry.Lookup(ctx, event.OwnerID)
+		if errors.Is(err, athletes.ErrUnknownAthlete) {
+			return Result{Outcome: OutcomeSkipped, Reason: reasonUnknownAthlete}, nil
+		}
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to look up athlete %d: %w", event.OwnerID, err)
+		}
+		p = p.forAthlete(athlete)
+	}
+	switch event.AspectType {
+	case AspectCreate:
+		return p.create(ctx, event)
+	case AspectDelete:
+		return p.delete(ctx, event)
+	case AspectUpdate:
+		return Result{Outcome: OutcomeSkipped, Reason: reasonUpdate}, nil
+	default:
+		return Result{Outcome: OutcomeSkipped, Reason: reasonUnknownAspect}, nil
+	}
+}
+
+// forAthlete returns a copy of p reading and writing athlete's aggregates
+// with their Strava client.
+func (p *Processor) forAthlete(athlete athletes.Athlete) *Processor {
+	scoped := *p
+	scoped.store = withPrefix(p.store, athlete.Prefix)
+	scoped.source = p.sources(athlete)
+	scoped.registry = nil
+	return &scoped
+}
+
+func (p *Processor) create(ctx context.Context, event Event) (Result, error) {
+	activity, err := p.activity(ctx, event)
+	if errors.Is(err, strava.ErrNotFound) {
+		// Deleted or made private since the event; retrying won't help
+		return Result{Outcome: OutcomeSkipped, Reason: reasonNotFound}, nil
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	if p.sink != nil {
+		record := ActivityRecord{ProcessedAt: p.now(), Activity: activity, OwnerID: event.OwnerID}
+		if err := p.sink.Write(ctx, record); err != nil {
+			return Result{}, fmt.Errorf("failed to write activity %d to the sink: %w", activity.ID, err)
+		}
+	}
+	if !p.counts(activity) {
+		return Result{Outcome: OutcomeSkipped, Reason: reasonActivityType}, nil
+	}
+	if activity.StartDateLocal.IsZero() {
+		return Result{}, fmt.Errorf("%w: activity %d has no start_date_local", ErrInvalidEvent, activity.ID)
+	}
+	year := activity.StartDateLocal.Year()
+	if p.storeStreams {
+		if err := p.writeStreams(ctx, activity); err != nil {
+			return Result{}, fmt.Errorf("failed to store streams of activity %d: %w", activity.ID, err)
+		}
+	}
+
+	added, err := p.updateSummary(ctx, event.OwnerID, year, func(summary aggregation.Summary) (bool, error) {
+		return summary.Add(activity), nil
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	if !added {
+		return Result{Outcome: OutcomeSkipped, Reason: reasonAlreadyCounted, Year: year}, nil
+	}
+	return Result{Outcome: OutcomeCreated, Year: year}, nil
+}
+
+// activity returns the event's activity, from the enriched event when the
+// dispatcher attached it and from Strava otherwise.
+func (p *Processor) activity(ctx context.Context, event Event) (strava.Activity, error) {
+	if len(event.Activity) > 0 {
+		var activity strava.Activity
+		if err := json.Unmarshal(event.Activity, &activity); err == nil && activity.ID == event.ObjectID {
+			return activity, nil
+		}
+	}
+	activity, err := p.source.GetActivity(ctx, event.ObjectID)
+	if err != nil {
+		return strava.Activity{}, err
+	}
+	return *activity, nil
+}
+
+// counts reports whether activity's type is counted in the totals.
+func (p *Processor) counts(activity strava.Activity) bool {
+	return slices.Contains(p.activityTypes, activity.Type)
+}
+
+// delete removes the activity from the summary it is counted in. Strava
+// no longer returns a deleted activity, so the summary of the year the
+// event happened in and of the year before are searched for its ID.
+func (p *Processor) delete(ctx context.Context, event Event) (Result, error) {
+	if p.storeStreams {
+		if err := p.store.Delete(ctx, aggregation.StreamsBlob(event.ObjectID)); err != nil {
+			return Result{}, err
+		}
+	}
+	eventYear := time.Unix(event.EventTime, 0).In(p.location).Year()
+	for _, year := range []int{eventYear, eventYear - 1} {
+		removed, err := p.updateSummary(ctx, event.OwnerID, year, func(summary aggregation.Summary) (bool, error) {
+			date, ok := summary.Find(event.ObjectID)
+			if !ok {
+				return false, nil
+			}
+			return true, p.recountDay(ctx, summary, date, event.ObjectID)
+		})
+		if err != nil {
+			return Result{}, err
+		}
+		if removed {
+			return Result{Outcome: OutcomeDeleted, Year: year}, nil
+		}
+	}
+	return Result{Outcome: OutcomeSkipped, Reason: reasonNotInSummary}, nil
+}
+
+// recountDay drops activity id from date. The summary only keeps a day's
+// total, so when other activities remain the day is rebuilt from Strava's
+// list of activities on that date.
+func (p *Processor) recountDay(ctx context.Context, summary aggregation.Summary, date string, id int64) error {
+	if len(summary[date].ActivityIDs) == 1 {
+		delete(summary, date)
+		return nil
+	}
+
+	day, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return fmt.Errorf("invalid summary date %q: %w", date, err)
+	}
+	// Padded by a day either side: activities are listed by UTC start
+	// time but summarized by their own local date
+	activities, err := p.source.ListActivities(ctx, day.AddDate(0, 0, -1), day.AddDate(0, 0, 2))
+	if err != nil {
+		return fmt.Errorf("failed to list activities on %s: %w", date, err)
+	}
+
+	delete(summary, date)
+	for _, activity := range activities {
+		if activity.ID != id && activity.Date() == date && p.counts(activity) {
+			summary.Add(activity)
+		}
+	}
+	return nil
+}
+
+// updateSummary applies change to year's summary and rewrites both of the
+// year's blobs when it reports a change, with the goal lines of athleteID.
+// The summary is replaced only if nobody rewrote it since it was read, and
+// change is reapplied to the newer version otherwise, so concurrent events
+// never lose each other's updates. When change reports none, the distances
+// are still brought up to date with the summary: a redelivered event finds
+// its change already applied if writing them failed the first time.
+func (p *Processor) updateSummary(ctx context.Context, athleteID int64, year int, change func(aggregation.Summary) (bool, error)) (bool, error) {
+	for range maxSummaryWriteRetries {
+		blob, err := p.readChart(ctx, aggregation.SummaryBlob(year))
+		if err != nil {
+			return false, err
+		}
+		summary, err := parseSummary(year, blob.data)
+		if err != nil {
+			return false, err
+		}
+
+		changed, err := change(summary)
+		if err != nil {
+			return false, err
+		}
+		if !changed {
+			if blob.data == nil {
+				return false, nil
+			}
+			if !blob.gzCurrent {
+				err := p.writeChart(ctx, blob, blob.data)
+				if errors.Is(err, ErrConflict) {
+					continue
+				}
+				if err != nil {
+					return false, err
+				}
+			}
+			_, err := p.writeDistances(ctx, athleteID, year, true)
+			return false, err
+		}
+
+		data, err := json.Marshal(summary)
+		if err != nil {
+			return false, fmt.Errorf("failed to encode summary: %w", err)
+		}
+		err = p.writeChart(ctx, blob, data)
+		if errors.Is(err, ErrConflict) {
+			Logger.InfoContext(ctx, "Summary changed concurrently, retrying", "year", year)
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+
+		goals, err := p.writeDistances(ctx, athleteID, year, false)
+		if err != nil {
+			return false, err
+		}
+		p.notifyMilestones(ctx, athleteID, year, blob.data, summary, goals)
+		return true, nil
+	}
+	return false, fmt.Errorf("summary for %d kept changing after %d attempts: %w", year, maxSummaryWriteRetries, ErrConflict)
+}
+
+// writeDistances rebuilds year's distances from the stored summary with the
+// goal lines of athleteID, and returns the goals they were built with. They
+// are replaced only if nobody rewrote them since they were read, and rebuilt
+// from the newer summary otherwise, so events finishing out of order can't
+// leave distances built from an older summary. With onlyIfStale, distances
+// that already match the summary are left alone.
+func (p *Processor) writeDistances(ctx context.Context, athleteID int64, year int, onlyIfStale bool) ([]aggregation.Goal, error) {
+	for range maxSummaryWriteRetries {
+		// Read before the summary: whoever writes a newer summary rewrites
+		// the distances afterwards, so one of the two writes conflicts
+		stored, err := p.readChart(ctx, aggregation.DistancesBlob(year))
+		if err != nil {
+			return nil, err
+		}
+		data, _, err := p.store.Read(ctx, aggregation.SummaryBlob(year))
+		if err != nil {
+			return nil, err
+		}
+		summary, err := parseSummary(year, data)
+		if err != nil {
+			return nil, err
+		}
+		distances, goals, err := p.distances(ctx, athleteID, year, summary)
+		if err != nil {
+			return nil, err
+		}
+		if onlyIfStale {
+			if stored.current(distances) {
+				return goals, nil
+			}
+			Logger.InfoContext(ctx, "Distances out of date with the summary, rewriting", "year", year)
+		}
+
+		err = p.writeChart(ctx, stored, distances)
+		if errors.Is(err, ErrConflict) {
+			Logger.InfoContext(ctx, "Distances changed concurrently, retrying", "year", year)
+			continue
+		}
+		return goals, err
+	}
+	return nil, fmt.Errorf("distances for %d kept changing after %d attempts: %w", year, maxSummaryWriteRetries, ErrConflict)
+}
+
+// parseSummary decodes year's summary, which is empty if data is nil.
+func parseSummary(year int, data []byte) (aggregation.Summary, error) {
+	summary := aggregation.Summary{}
+	if data != nil {
+		if err := json.Unmarshal(data, &summary); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", aggregation.SummaryBlob(year), err)
+		}
+	}
+	return summary, nil
+}
+
+// distances builds and encodes year's distances from summary, with the goal
+// lines of athleteID, and returns the goals they were built with.
+func (p *Processor) distances(ctx context.Context, athleteID int64, year int, summary aggregation.Summary) ([]byte, []aggregation.Goal, error) {
+	goals, err := p.goals(ctx, athleteID, year)
+	if err != nil {
+		return nil, nil, err
+	}
+	distances, err := json.Marshal(aggregation.Build(summary, year, p.now().In(p.location), aggregation.Options{Goals: goals}))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode distances: %w", err)
+	}
+	return distances, goals, nil
+}
+
+// goals returns athleteID's goals for year, or none if the athlete is
+// unknown or has set none. A goals file that can't be parsed is logged and
+// ignored rather than holding up the aggregates.
+func (p *Processor) goals(ctx context.Context, athleteID int64, year int) ([]aggregation.Goal, error) {
+	if athleteID == 0 {
+		return nil, nil
+	}
+	data, _, err := p.store.Read(ctx, aggregation.GoalsBlob(athleteID, year))
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var goals aggregation.GoalSet
+	if err := json.Unmarshal(data, &goals); err != nil {
+		Logger.WarnContext(ctx, "Ignoring unparseable goals", "blob", aggregation.GoalsBlob(athleteID, year), "error", err)
+		return nil, nil
+	}
+	return goals.Goals, nil
+}
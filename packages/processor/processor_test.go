@@ -0,0 +1,546 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+// memoryStore is an in-memory Store with GCS-style generations. conflicts
+// makes that many guarded writes fail as if someone wrote first, and
+// failures makes that many writes of an object fail outright.
+type memoryStore struct {
+	objects     map[string][]byte
+	generations map[string]int64
+	conflicts   int
+	failures    map[string]int
+	// beforeWrite, if set, runs before each write, outside the lock
+	beforeWrite func(name string)
+	mu          sync.Mutex
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{objects: map[string][]byte{}, generations: map[string]int64{}, failures: map[string]int{}}
+}
+
+func (s *memoryStore) Read(_ context.Context, name string) ([]byte, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.objects[name], s.generations[name], nil
+}
+
+func (s *memoryStore) Write(_ context.Context, name string, data []byte, generation int64) error {
+	if s.beforeWrite != nil {
+		s.beforeWrite(name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures[name] > 0 {
+		s.failures[name]--
+		return errWriteFailed
+	}
+	if generation != AnyGeneration {
+		if s.conflicts > 0 {
+			s.conflicts--
+			return ErrConflict
+		}
+		if generation != s.generations[name] {
+			return ErrConflict
+		}
+	}
+	s.objects[name] = data
+	s.generations[name]++
+	return nil
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (s *memoryStore) Delete(_ context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, name)
+	delete(s.generations, name)
+	return nil
+}
+
+func (s *memoryStore) Close() error { return nil }
+
+func (s *memoryStore) summary(t *testing.T, year int) aggregation.Summary {
+	t.Helper()
+	summary := aggregation.Summary{}
+	if data := s.objects[aggregation.SummaryBlob(year)]; data != nil {
+		if err := json.Unmarshal(data, &summary); err != nil {
+			t.Fatalf("invalid summary: %v", err)
+		}
+	}
+	return summary
+}
+
+// fakeStrava serves activities by ID; ListActivities returns all of them.
+type fakeStrava struct {
+	activities map[int64]strava.Activity
+	err        error
+}
+
+func (f *fakeStrava) GetActivity(_ context.Context, id int64) (*strava.Activity, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	activity, ok := f.activities[id]
+	if !ok {
+		return nil, fmt.Errorf("activity %d: %w", id, strava.ErrNotFound)
+	}
+	return &activity, nil
+}
+
+func (f *fakeStrava) ListActivities(context.Context, time.Time, time.Time) ([]strava.Activity, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	var activities []strava.Activity
+	for _, activity := range f.activities {
+		activities = append(activities, activity)
+	}
+	return activities, nil
+}
+
+// memorySink collects written activity records; err fails every write.
+type memorySink struct {
+	records []ActivityRecord
+	err     error
+}
+
+func (s *memorySink) Write(_ context.Context, record ActivityRecord) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *memorySink) Close() error { return nil }
+
+func newTestProcessor(store Store, source ActivitySource, opts ...Option) *Processor {
+	p := NewProcessor(store, source, &Config{
+		TimeZone:      time.UTC,
+		ActivityTypes: []string{"Ride", "VirtualRide"},
+	}, opts...)
+	p.now = func() time.Time { return time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC) }
+	return p
+}
+
+func ride(id int64, start string, meters float64) strava.Activity {
+	startDateLocal, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		panic(err)
+	}
+	return strava.Activity{ID: id, Type: "Ride", StartDateLocal: startDateLocal, Distance: meters}
+}
+
+func createEvent(id int64) Event {
+	return Event{AspectType: AspectCreate, ObjectType: ObjectActivity, ObjectID: id}
+}
+
+func deleteEvent(id int64) Event {
+	eventTime := time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC).Unix()
+	return Event{AspectType: AspectDelete, ObjectType: ObjectActivity, ObjectID: id, EventTime: eventTime}
+}
+
+func TestProcess_Create(t *testing.T) {
+	store := newMemoryStore()
+	source := &fakeStrava{activities: map[int64]strava.Activity{1: ride(1, "2025-03-01T08:00:00Z", 20000)}}
+	p := newTestProcessor(store, source)
+
+	result, err := p.Process(context.Background(), createEvent(1))
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if result != (Result{Outcome: OutcomeCreated, Year: 2025}) {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	day := store.summary(t, 2025)["2025-03-01"]
+	if day == nil || day.DistanceMiles != aggregation.DistanceMiles(source.activities[1]) {
+		t.Fatalf("unexpected summary day %+v", day)
+	}
+
+	var distances aggregation.Distances
+	if err := json.Unmarshal(store.objects[aggregation.DistancesBlob(2025)], &distances); err != nil {
+		t.Fatalf("invalid distances: %v", err)
+	}
+	points := distances.DistanceTraveled
+	if len(points) != 69 || points[len(points)-1] != (aggregation.Point{X: "2025-03-10", Y: day.DistanceMiles}) {
+		t.Errorf("expected the series to run to today, got %d points ending %+v", len(points), points[len(points)-1])
+	}
+	if len(distances.AvgDistance) != 69 || len(distances.UpperDistance) != 69 || len(distances.LowerDistance) != 69 {
+		t.Errorf("expected the goal lines to run to today too, got %+v", distances)
+	}
+}
+
+func TestProcess_CreateAddsGoalLines(t *testing.T) {
+	store := newMemoryStore()
+	store.objects[aggregation.GoalsBlob(7, 2025)] = []byte(`{"goals":[{"id":"rides","type":"count","target":100}]}`)
+	source := &fakeStrava{activities: map[int64]strava.Activity{1: ride(1, "2025-03-01T08:00:00Z", 20000)}}
+	p := newTestProcessor(store, source)
+
+	event := createEvent(1)
+	event.OwnerID = 7
+	if _, err := p.Process(context.Background(), event); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	var distances aggregation.Distances
+	if err := json.Unmarshal(store.objects[aggregation.DistancesBlob(2025)], &distances); err != nil {
+		t.Fatalf("invalid distances: %v", err)
+	}
+	if len(distances.Goals) != 1 || distances.Goals[0].ID != "rides" || distances.Goals[0].Remaining != 99 {
+		t.Errorf("expected the athlete's goal to be charted, got %+v", distances.Goals)
+	}
+}
+
+func TestProcess_CreateUsesEnrichedActivity(t *testing.T) {
+	store := newMemoryStore()
+	p := newTestProcessor(store, &fakeStrava{err: errors.New("strava should not be called")})
+
+	event := createEvent(1)
+	event.Activity = json.RawMessage(`{"id":1,"type":"VirtualRide","start_date_local":"2025-03-01T08:00:00Z","distance":1000}`)
+	if _, err := p.Process(context.Background(), event); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if _, ok := store.summary(t, 2025)["2025-03-01"]; !ok {
+		t.Error("expected the enriched activity to be counted")
+	}
+}
+
+func TestProcess_CreateIsIdempotent(t *testing.T) {
+	store := newMemoryStore()
+	source := &fakeStrava{activities: map[int64]strava.Activity{1: ride(1, "2025-03-01T08:00:00Z", 20000)}}
+	p := newTestProcessor(store, source)
+
+	if _, err := p.Process(context.Background(), createEvent(1)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	result, err := p.Process(context.Background(), createEvent(1))
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if result.Reason != reasonAlreadyCounted {
+		t.Errorf("expected redelivery to be skipped, got %+v", result)
+	}
+	if ids := store.summary(t, 2025)["2025-03-01"].ActivityIDs; len(ids) != 1 {
+		t.Errorf("expected the activity once, got %v", ids)
+	}
+}
+
+func TestProcess_RedeliveryRewritesDistances(t *testing.T) {
+	store := newMemoryStore()
+	source := &fakeStrava{activities: map[int64]strava.Activity{
+		1: ride(1, "2025-03-01T08:00:00Z", 20000),
+		2: ride(2, "2025-03-02T08:00:00Z", 10000),
+	}}
+	p := newTestProcessor(store, source)
+
+	// Activity 2's first delivery counts it but fails to chart it
+	if _, err := p.Process(context.Background(), createEvent(1)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	store.failures[aggregation.DistancesBlob(2025)] = 1
+	if _, err := p.Process(context.Background(), createEvent(2)); !errors.Is(err, errWriteFailed) {
+		t.Fatalf("expected the distances write to fail, got %v", err)
+	}
+	stale := store.objects[aggregation.DistancesBlob(2025)]
+
+	result, err := p.Process(context.Background(), createEvent(2))
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if result.Reason != reasonAlreadyCounted {
+		t.Errorf("expected redelivery to be skipped, got %+v", result)
+	}
+	want, _, err := p.distances(context.Background(), 0, 2025, store.summary(t, 2025))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := store.objects[aggregation.DistancesBlob(2025)]
+	if string(got) == string(stale) || string(got) != string(want) {
+		t.Errorf("expected the redelivery to rewrite the stale distances, got %s", got)
+	}
+
+	generation := store.generations[aggregation.DistancesBlob(2025)]
+	if _, err := p.Process(context.Background(), createEvent(2)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if store.generations[aggregation.DistancesBlob(2025)] != generation {
+		t.Error("expected up-to-date distances to be left alone")
+	}
+}
+
+func TestProcess_DistancesFollowNewestSummary(t *testing.T) {
+	store := newMemoryStore()
+	source := &fakeStrava{activities: map[int64]strava.Activity{
+		1: ride(1, "2025-03-01T08:00:00Z", 20000),
+		2: ride(2, "2025-03-02T08:00:00Z", 10000),
+	}}
+	p := newTestProcessor(store, source)
+
+	// Activity 2's event runs to completion between activity 1's summary
+	// and distances writes
+	store.beforeWrite = func(name string) {
+		if name != aggregation.DistancesBlob(2025) {
+			return
+		}
+		store.beforeWrite = nil
+		if _, err := p.Process(context.Background(), createEvent(2)); err != nil {
+			t.Errorf("Process failed: %v", err)
+		}
+	}
+	if _, err := p.Process(context.Background(), createEvent(1)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	want, _, err := p.distances(context.Background(), 0, 2025, store.summary(t, 2025))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := store.objects[aggregation.DistancesBlob(2025)]; string(got) != string(want) {
+		t.Errorf("expected distances built from both activities, got %s", got)
+	}
+}
+
+func TestProcess_Skips(t *testing.T) {
+	source := &fakeStrava{activities: map[int64]strava.Activity{
+		1: {ID: 1, Type: "Run", StartDateLocal: time.Date(2025, time.March, 1, 8, 0, 0, 0, time.UTC), Distance: 5000},
+	}}
+	tests := []struct {
+		name   string
+		event  Event
+		reason string
+	}{
+		{"wrong type", createEvent(1), reasonActivityType},
+		{"not found", createEvent(2), reasonNotFound},
+		{"update", Event{AspectType: AspectUpdate, ObjectType: ObjectActivity, ObjectID: 1}, reasonUpdate},
+		{"athlete", Event{AspectType: AspectUpdate, ObjectType: "athlete", ObjectID: 7}, reasonNonActivity},
+		{"unknown aspect", Event{AspectType: "merge", ObjectType: ObjectActivity, ObjectID: 1}, reasonUnknownAspect},
+		{"not in summary", deleteEvent(1), reasonNotInSummary},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			result, err := newTestProcessor(store, source).Process(context.Background(), tt.event)
+			if err != nil {
+				t.Fatalf("Process failed: %v", err)
+			}
+			if result.Outcome != OutcomeSkipped || result.Reason != tt.reason {
+				t.Errorf("expected skip for %s, got %+v", tt.reason, result)
+			}
+			if len(store.objects) != 0 {
+				t.Errorf("expected nothing written, got %d objects", len(store.objects))
+			}
+		})
+	}
+}
+
+func TestProcess_ScopesToAthlete(t *testing.T) {
+	registry := athletes.New(func(context.Context) ([]byte, error) {
+		return []byte(`{"athletes": [{"id": 7, "prefix": "athletes/7/"}, {"id": 8, "prefix": ""}]}`), nil
+	}, 0)
+	sources := map[int64]ActivitySource{
+		7: &fakeStrava{activities: map[int64]strava.Activity{1: ride(1, "2025-03-01T08:00:00Z", 20000)}},
+		8: &fakeStrava{activities: map[int64]strava.Activity{2: ride(2, "2025-03-02T08:00:00Z", 10000)}},
+	}
+	store := newMemoryStore()
+	store.objects["athletes/7/"+aggregation.GoalsBlob(7, 2025)] = []byte(`{"goals":[{"id":"rides","type":"count","target":100}]}`)
+	p := newTestProcessor(store, &fakeStrava{err: errors.New("the shared client should not be called")},
+		WithAthletes(registry, func(athlete athletes.Athlete) ActivitySource { return sources[athlete.ID] }))
+
+	for _, event := range []Event{
+		{AspectType: AspectCreate, ObjectType: ObjectActivity, ObjectID: 1, OwnerID: 7},
+		{AspectType: AspectCreate, ObjectType: ObjectActivity, ObjectID: 2, OwnerID: 8},
+	} {
+		if result, err := p.Process(context.Background(), event); err != nil || result.Outcome != OutcomeCreated {
+			t.Fatalf("Process of %d failed: %+v, %v", event.ObjectID, result, err)
+		}
+	}
+
+	var scoped aggregation.Summary
+	if err := json.Unmarshal(store.objects["athletes/7/"+aggregation.SummaryBlob(2025)], &scoped); err != nil {
+		t.Fatalf("invalid scoped summary: %v", err)
+	}
+	if _, ok := scoped["2025-03-01"]; !ok || len(scoped) != 1 {
+		t.Errorf("expected athlete 7's activity under their prefix, got %v", scoped)
+	}
+	var distances aggregation.Distances
+	if err := json.Unmarshal(store.objects["athletes/7/"+aggregation.DistancesBlob(2025)], &distances); err != nil {
+		t.Fatalf("invalid scoped distances: %v", err)
+	}
+	if len(distances.Goals) != 1 {
+		t.Errorf("expected athlete 7's goals from their prefix, got %+v", distances.Goals)
+	}
+	if root := store.summary(t, 2025); len(root) != 1 || root["2025-03-02"] == nil {
+		t.Errorf("expected athlete 8's activity at the bucket root, got %v", root)
+	}
+
+	result, err := p.Process(context.Background(), Event{AspectType: AspectCreate, ObjectType: ObjectActivity, ObjectID: 3, OwnerID: 9})
+	if err != nil || result.Reason != reasonUnknownAthlete {
+		t.Errorf("expected an unregistered athlete to be skipped, got %+v, %v", result, err)
+	}
+}
+
+func TestProcess_StravaErrorIsRetried(t *testing.T) {
+	p := newTestProcessor(newMemoryStore(), &fakeStrava{err: &strava.APIError{StatusCode: 500}})
+
+	if _, err := p.Process(context.Background(), createEvent(1)); err == nil || errors.Is(err, ErrInvalidEvent) {
+		t.Errorf("expected a retryable error, got %v", err)
+	}
+}
+
+func TestProcess_WritesActivitiesToSink(t *testing.T) {
+	run := ride(2, "2025-03-02T08:00:00Z", 5000)
+	run.Type = "Run"
+	source := &fakeStrava{activities: map[int64]strava.Activity{1: ride(1, "2025-03-01T08:00:00Z", 20000), 2: run}}
+	sink := &memorySink{}
+	p := newTestProcessor(newMemoryStore(), source, WithActivitySink(sink))
+
+	for _, id := range []int64{1, 2} {
+		event := createEvent(id)
+		event.OwnerID = 7
+		if _, err := p.Process(context.Background(), event); err != nil {
+			t.Fatalf("Process failed: %v", err)
+		}
+	}
+	if len(sink.records) != 2 || sink.records[1].Activity.ID != 2 {
+		t.Fatalf("expected uncounted activities to be written too, got %+v", sink.records)
+	}
+	if record := sink.records[0]; record.OwnerID != 7 || !record.ProcessedAt.Equal(p.now()) {
+		t.Errorf("unexpected record %+v", record)
+	}
+}
+
+func TestProcess_SinkErrorIsRetried(t *testing.T) {
+	store := newMemoryStore()
+	source := &fakeStrava{activities: map[int64]strava.Activity{1: ride(1, "2025-03-01T08:00:00Z", 20000)}}
+	p := newTestProcessor(store, source, WithActivitySink(&memorySink{err: errors.New("quota exceeded")}))
+
+	if _, err := p.Process(context.Background(), createEvent(1)); err == nil || errors.Is(err, ErrInvalidEvent) {
+		t.Errorf("expected a retryable error, got %v", err)
+	}
+	if len(store.objects) != 0 {
+		t.Errorf("expected nothing aggregated before the sink write succeeds, got %d objects", len(store.objects))
+	}
+}
+
+func TestProcess_DeleteOnlyActivity(t *testing.T) {
+	store := newMemoryStore()
+	source := &fakeStrava{activities: map[int64]strava.Activity{1: ride(1, "2025-03-01T08:00:00Z", 20000)}}
+	p := newTestProcessor(store, source)
+	if _, err := p.Process(context.Background(), createEvent(1)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	// Strava no longer lists or returns a deleted activity
+	source.err = errors.New("strava should not be called")
+	result, err := p.Process(context.Background(), deleteEvent(1))
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if result != (Result{Outcome: OutcomeDeleted, Year: 2025}) {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if summary := store.summary(t, 2025); len(summary) != 0 {
+		t.Errorf("expected the day to be removed, got %+v", summary)
+	}
+}
+
+func TestProcess_DeleteRecountsDay(t *testing.T) {
+	store := newMemoryStore()
+	source := &fakeStrava{activities: map[int64]strava.Activity{
+		1: ride(1, "2025-03-01T08:00:00Z", 20000),
+		2: ride(2, "2025-03-01T17:00:00Z", 10000),
+		3: ride(3, "2025-03-02T08:00:00Z", 5000),
+	}}
+	p := newTestProcessor(store, source)
+	for id := range int64(3) {
+		if _, err := p.Process(context.Background(), createEvent(id+1)); err != nil {
+			t.Fatalf("Process failed: %v", err)
+		}
+	}
+
+	delete(source.activities, 1)
+	if _, err := p.Process(context.Background(), deleteEvent(1)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	summary := store.summary(t, 2025)
+	day := summary["2025-03-01"]
+	if len(day.ActivityIDs) != 1 || day.ActivityIDs[0] != 2 || day.DistanceMiles != aggregation.DistanceMiles(source.activities[2]) {
+		t.Errorf("expected the day to be recounted without the deleted activity, got %+v", day)
+	}
+	if len(summary["2025-03-02"].ActivityIDs) != 1 {
+		t.Errorf("expected other days to be left alone, got %+v", summary["2025-03-02"])
+	}
+}
+
+func TestProcess_DeleteSearchesPreviousYear(t *testing.T) {
+	store := newMemoryStore()
+	source := &fakeStrava{activities: map[int64]strava.Activity{1: ride(1, "2024-12-31T08:00:00Z", 20000)}}
+	p := newTestProcessor(store, source)
+	if _, err := p.Process(context.Background(), createEvent(1)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+
+	result, err := p.Process(context.Background(), deleteEvent(1))
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if result != (Result{Outcome: OutcomeDeleted, Year: 2024}) {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestProcess_RetriesOnConflict(t *testing.T) {
+	store := newMemoryStore()
+	source := &fakeStrava{activities: map[int64]strava.Activity{1: ride(1, "2025-03-01T08:00:00Z", 20000)}}
+	p := newTestProcessor(store, source)
+
+	store.conflicts = 2
+	if _, err := p.Process(context.Background(), createEvent(1)); err != nil {
+		t.Fatalf("expected the update to be retried, got %v", err)
+	}
+	if _, ok := store.summary(t, 2025)["2025-03-01"]; !ok {
+		t.Error("expected the activity to be counted after retrying")
+	}
+
+	store.conflicts = maxSummaryWriteRetries
+	source.activities[2] = ride(2, "2025-03-02T08:00:00Z", 1000)
+	if _, err := p.Process(context.Background(), createEvent(2)); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict after %d conflicts, got %v", maxSummaryWriteRetries, err)
+	}
+}
+
+func TestProcess_ConcurrentCreates(t *testing.T) {
+	store := newMemoryStore()
+	source := &fakeStrava{activities: map[int64]strava.Activity{}}
+	for id := range int64(4) {
+		source.activities[id+1] = ride(id+1, "2025-03-01T08:00:00Z", 1000)
+	}
+	p := newTestProcessor(store, source)
+
+	var wg sync.WaitGroup
+	for id := range int64(4) {
+		wg.Go(func() {
+			if _, err := p.Process(context.Background(), createEvent(id+1)); err != nil {
+				t.Errorf("Process failed: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	if ids := store.summary(t, 2025)["2025-03-01"].ActivityIDs; len(ids) != 4 {
+		t.Errorf("expected all 4 activities counted, got %v", ids)
+	}
+}
@@ -0,0 +1,26 @@
+package processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+// ActivityRecord is a fetched activity as handed to an ActivitySink.
+type ActivityRecord struct {
+	// ProcessedAt is when the processor fetched the activity; a redelivered
+	// event is recorded again with a later time.
+	ProcessedAt time.Time
+	Activity    strava.Activity
+	// OwnerID is the athlete the event was for.
+	OwnerID int64
+}
+
+// ActivitySink receives every activity the processor fetches for a create
+// event, whether or not its type is counted, so the raw activities can be
+// queried alongside the aggregates.
+type ActivitySink interface {
+	Write(ctx context.Context, record ActivityRecord) error
+	Close() error
+}
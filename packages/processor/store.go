@@ -0,0 +1,141 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+)
+
+// AnyGeneration makes Store.Write replace whatever object exists.
+const AnyGeneration int64 = -1
+
+// ErrConflict is returned by Store.Write when the object changed since it
+// was read.
+var ErrConflict = errors.New("object changed concurrently")
+
+// Store reads and writes the aggregate blobs. Generations identify object
+// versions for compare-and-swap updates; 0 means the object doesn't exist.
+type Store interface {
+	// Read returns the object's content and generation, or (nil, 0, nil)
+	// if it doesn't exist.
+	Read(ctx context.Context, name string) ([]byte, int64, error)
+	// Write replaces the object if its generation is still generation (or
+	// for AnyGeneration), and returns ErrConflict otherwise.
+	Write(ctx context.Context, name string, data []byte, generation int64) error
+	// Delete removes the object; deleting one that doesn't exist succeeds.
+	Delete(ctx context.Context, name string) error
+	Close() error
+}
+
+// prefixedStore scopes a Store to the objects under prefix, e.g. one
+// athlete's. Closing it closes the wrapped store.
+type prefixedStore struct {
+	Store
+	prefix string
+}
+
+// withPrefix returns store scoped to prefix, or store itself for the
+// bucket root.
+func withPrefix(store Store, prefix string) Store {
+	if prefix == "" {
+		return store
+	}
+	return prefixedStore{Store: store, prefix: prefix}
+}
+
+// Read implements the Store interface.
+func (s prefixedStore) Read(ctx context.Context, name string) ([]byte, int64, error) {
+	return s.Store.Read(ctx, s.prefix+name)
+}
+
+// Write implements the Store interface.
+func (s prefixedStore) Write(ctx context.Context, name string, data []byte, generation int64) error {
+	return s.Store.Write(ctx, s.prefix+name, data, generation)
+}
+
+// Delete implements the Store interface.
+func (s prefixedStore) Delete(ctx context.Context, name string) error {
+	return s.Store.Delete(ctx, s.prefix+name)
+}
+
+// GCSStore keeps the aggregates in a Cloud Storage bucket.
+type GCSStore struct {
+	client *storage.Client
+	bucket string
+}
+
+// NewGCSStore creates a store for bucket.
+func NewGCSStore(ctx context.Context, bucket string) (*GCSStore, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
+	}
+	return &GCSStore{client: client, bucket: bucket}, nil
+}
+
+// Read implements the Store interface.
+func (s *GCSStore) Read(ctx context.Context, name string) ([]byte, int64, error) {
+	reader, err := s.client.Bucket(s.bucket).Object(name).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return data, reader.Attrs.Generation, nil
+}
+
+// Write implements the Store interface.
+func (s *GCSStore) Write(ctx context.Context, name string, data []byte, generation int64) error {
+	object := s.client.Bucket(s.bucket).Object(name)
+	switch {
+	case generation == 0:
+		object = object.If(storage.Conditions{DoesNotExist: true})
+	case generation > 0:
+		object = object.If(storage.Conditions{GenerationMatch: generation})
+	}
+
+	w := object.NewWriter(ctx)
+	w.ContentType = "application/json"
+	if _, err := io.Copy(w, bytes.NewReader(data)); err != nil {
+		_ = w.Close()
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := w.Close(); err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+			return fmt.Errorf("%s: %w", name, ErrConflict)
+		}
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// Delete implements the Store interface.
+func (s *GCSStore) Delete(ctx context.Context, name string) error {
+	err := s.client.Bucket(s.bucket).Object(name).Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("failed to delete %s: %w", name, err)
+	}
+	return nil
+}
+
+// Close implements the Store interface.
+func (s *GCSStore) Close() error {
+	if err := s.client.Close(); err != nil {
+		return fmt.Errorf("failed to close storage client: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+// ChartStreams are the streams stored for single-activity charts. The GPS
+// track is left out, since the API gateway serves streams without a token.
+var ChartStreams = []strava.StreamType{
+	strava.StreamTime, strava.StreamDistance, strava.StreamAltitude, strava.StreamVelocitySmooth,
+	strava.StreamHeartrate, strava.StreamCadence, strava.StreamWatts, strava.StreamTemp,
+	strava.StreamMoving, strava.StreamGradeSmooth,
+}
+
+// StreamSource fetches an activity's streams, as *strava.Client does.
+type StreamSource interface {
+	GetStreams(ctx context.Context, id int64, types ...strava.StreamType) (*strava.Streams, error)
+}
+
+// WithStreams stores the ChartStreams of every counted activity at
+// aggregation.StreamsBlob when it is created, and removes them when it is
+// deleted. The activity sources must also be StreamSources. A failed fetch
+// or write fails the event, so it is redelivered.
+func WithStreams() Option {
+	return func(p *Processor) {
+		p.storeStreams = true
+	}
+}
+
+// writeStreams fetches and stores activity's streams. Manual activities
+// have none, and activities Strava no longer returns are left out.
+func (p *Processor) writeStreams(ctx context.Context, activity strava.Activity) error {
+	if activity.Manual {
+		return nil
+	}
+	source, ok := p.source.(StreamSource)
+	if !ok {
+		return errors.New("activity source can't fetch streams")
+	}
+	streams, err := source.GetStreams(ctx, activity.ID, ChartStreams...)
+	if errors.Is(err, strava.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch streams: %w", err)
+	}
+
+	data, err := json.Marshal(streams)
+	if err != nil {
+		return fmt.Errorf("failed to encode streams: %w", err)
+	}
+	return p.store.Write(ctx, aggregation.StreamsBlob(activity.ID), data, AnyGeneration)
+}
@@ -0,0 +1,86 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+// streamingStrava is a fakeStrava that also serves streams; err fails
+// only the stream requests.
+type streamingStrava struct {
+	*fakeStrava
+	err       error
+	requested [][]strava.StreamType
+}
+
+func (f *streamingStrava) GetStreams(_ context.Context, id int64, types ...strava.StreamType) (*strava.Streams, error) {
+	f.requested = append(f.requested, types)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if _, ok := f.activities[id]; !ok {
+		return nil, strava.ErrNotFound
+	}
+	return &strava.Streams{Time: &strava.Stream[int]{Data: []int{0, 1, 2}}, Watts: &strava.Stream[float64]{Data: []float64{200, 210, 190}}}, nil
+}
+
+func TestProcess_StoresStreams(t *testing.T) {
+	store := newMemoryStore()
+	manual := ride(3, "2025-03-03T08:00:00Z", 10000)
+	manual.Manual = true
+	run := ride(2, "2025-03-02T08:00:00Z", 5000)
+	run.Type = "Run"
+	source := &streamingStrava{fakeStrava: &fakeStrava{activities: map[int64]strava.Activity{1: ride(1, "2025-03-01T08:00:00Z", 20000), 2: run, 3: manual}}}
+	p := newTestProcessor(store, source, WithStreams())
+
+	for _, id := range []int64{1, 2, 3} {
+		if _, err := p.Process(context.Background(), createEvent(id)); err != nil {
+			t.Fatalf("Process failed: %v", err)
+		}
+	}
+	var streams strava.Streams
+	if err := json.Unmarshal(store.objects[aggregation.StreamsBlob(1)], &streams); err != nil {
+		t.Fatalf("invalid streams: %v", err)
+	}
+	if streams.Watts == nil || len(streams.Watts.Data) != 3 {
+		t.Errorf("unexpected streams %+v", streams)
+	}
+	if len(source.requested) != 1 || slices.Contains(source.requested[0], strava.StreamLatLng) {
+		t.Errorf("expected only the counted ride's streams fetched, without the GPS track, got %v", source.requested)
+	}
+
+	if _, err := p.Process(context.Background(), deleteEvent(1)); err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if _, ok := store.objects[aggregation.StreamsBlob(1)]; ok {
+		t.Error("expected the streams removed with the activity")
+	}
+}
+
+func TestProcess_StreamsErrorIsRetried(t *testing.T) {
+	store := newMemoryStore()
+	source := &streamingStrava{
+		fakeStrava: &fakeStrava{activities: map[int64]strava.Activity{1: ride(1, "2025-03-01T08:00:00Z", 20000)}},
+		err:        &strava.APIError{StatusCode: 429},
+	}
+	p := newTestProcessor(store, source, WithStreams())
+
+	if _, err := p.Process(context.Background(), createEvent(1)); err == nil || errors.Is(err, ErrInvalidEvent) {
+		t.Errorf("expected a retryable error, got %v", err)
+	}
+	if len(store.objects) != 0 {
+		t.Errorf("expected nothing aggregated before the streams are stored, got %d objects", len(store.objects))
+	}
+
+	// A source without streams can't be used with WithStreams
+	p = newTestProcessor(store, source.fakeStrava, WithStreams())
+	if _, err := p.Process(context.Background(), createEvent(1)); err == nil {
+		t.Error("expected an error for a source without streams")
+	}
+}
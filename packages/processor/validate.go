@@ -0,0 +1,199 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+// DefaultDriftTolerance is how many miles a served total may differ from the
+// recomputed one before it counts as drift, allowing for float sums added
+// up in a different order.
+const DefaultDriftTolerance = 0.01
+
+// YearReport compares a year's served aggregates with the ones recomputed
+// from the source activities.
+type YearReport struct {
+	Year int `json:"year"`
+	// Activities and ExpectedMiles are the counted source activities and
+	// their total; ServedMiles is the served summary's total.
+	Activities    int     `json:"activities"`
+	ExpectedMiles float64 `json:"expected_miles"`
+	ServedMiles   float64 `json:"served_miles"`
+	// MissingSummary and MissingDistances report blobs that don't exist.
+	MissingSummary   bool `json:"missing_summary,omitempty"`
+	MissingDistances bool `json:"missing_distances,omitempty"`
+	// Days lists the dates whose activities or distance differ, in order.
+	Days []DayDrift `json:"days,omitempty"`
+	// Distances describes where distances.json's distance_traveled series
+	// departs from the recomputed one, if it does.
+	Distances *SeriesDrift `json:"distances,omitempty"`
+}
+
+// DayDrift is a date whose served summary differs from the source.
+type DayDrift struct {
+	Date string `json:"date"`
+	// Missing are source activities the summary doesn't count; Extra are
+	// counted activities the source doesn't have, e.g. deleted ones.
+	Missing       []int64 `json:"missing,omitempty"`
+	Extra         []int64 `json:"extra,omitempty"`
+	ExpectedMiles float64 `json:"expected_miles"`
+	ServedMiles   float64 `json:"served_miles"`
+}
+
+// SeriesDrift summarizes the served cumulative series' departure from the
+// recomputed one, over the dates it covers.
+type SeriesDrift struct {
+	// FirstDate is the first date whose running total differs, and Days
+	// how many dates do.
+	FirstDate string `json:"first_date"`
+	Days      int    `json:"days"`
+	// LastDate is the series' last point, with both totals on that date.
+	LastDate      string  `json:"last_date"`
+	ExpectedMiles float64 `json:"expected_miles"`
+	ServedMiles   float64 `json:"served_miles"`
+}
+
+// OK reports whether the served aggregates match the source.
+func (r YearReport) OK() bool {
+	return !r.MissingSummary && !r.MissingDistances && len(r.Days) == 0 && r.Distances == nil
+}
+
+// ValidateYear recomputes year's summary from activities, counting the
+// activityTypes as the processor does, and compares it with the
+// summary_activities.json and distances.json in store. Totals within
+// tolerance miles of each other match. Activities outside year are
+// ignored, so the source can be listed with a margin either side.
+func ValidateYear(ctx context.Context, store Store, year int, activities []strava.Activity, activityTypes []string, tolerance float64) (YearReport, error) {
+	report := YearReport{Year: year}
+	expected := aggregation.Summarize(activities, activityTypes)[year]
+	if expected == nil {
+		expected = aggregation.Summary{}
+	}
+	for _, day := range expected {
+		report.Activities += len(day.ActivityIDs)
+	}
+
+	served, err := readBlob[aggregation.Summary](ctx, store, aggregation.SummaryBlob(year))
+	if err != nil {
+		return report, err
+	}
+	if served == nil {
+		report.MissingSummary = true
+		served = &aggregation.Summary{}
+	}
+	report.Days = compareSummaries(expected, *served, tolerance)
+	report.ExpectedMiles = totalMiles(expected)
+	report.ServedMiles = totalMiles(*served)
+
+	distances, err := readBlob[aggregation.Distances](ctx, store, aggregation.DistancesBlob(year))
+	if err != nil {
+		return report, err
+	}
+	if distances == nil {
+		report.MissingDistances = true
+		return report, nil
+	}
+	report.Distances = compareSeries(expected, distances.DistanceTraveled, tolerance)
+	return report, nil
+}
+
+// readBlob decodes the JSON object name, returning nil if it doesn't exist.
+func readBlob[T any](ctx context.Context, store Store, name string) (*T, error) {
+	data, _, err := store.Read(ctx, name)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return &v, nil
+}
+
+// compareSummaries lists the dates on which served differs from expected.
+func compareSummaries(expected, served aggregation.Summary, tolerance float64) []DayDrift {
+	dates := map[string]bool{}
+	for date := range expected {
+		dates[date] = true
+	}
+	for date := range served {
+		dates[date] = true
+	}
+
+	var drift []DayDrift
+	for _, date := range slices.Sorted(maps.Keys(dates)) {
+		want, got := dayOf(expected, date), dayOf(served, date)
+		day := DayDrift{
+			Date:          date,
+			Missing:       without(want.ActivityIDs, got.ActivityIDs),
+			Extra:         without(got.ActivityIDs, want.ActivityIDs),
+			ExpectedMiles: want.DistanceMiles,
+			ServedMiles:   got.DistanceMiles,
+		}
+		if len(day.Missing) > 0 || len(day.Extra) > 0 || math.Abs(day.ExpectedMiles-day.ServedMiles) > tolerance {
+			drift = append(drift, day)
+		}
+	}
+	return drift
+}
+
+// compareSeries checks each point of served against the running total of
+// expected on its date, so a series written earlier in the year is only
+// compared over the dates it has.
+func compareSeries(expected aggregation.Summary, served []aggregation.Point, tolerance float64) *SeriesDrift {
+	if len(served) == 0 {
+		return nil
+	}
+	var drift *SeriesDrift
+	var total float64
+	for _, point := range served {
+		if day, ok := expected[point.X]; ok {
+			total += day.DistanceMiles
+		}
+		if math.Abs(point.Y-total) > tolerance {
+			if drift == nil {
+				drift = &SeriesDrift{FirstDate: point.X}
+			}
+			drift.Days++
+		}
+	}
+	if drift != nil {
+		last := served[len(served)-1]
+		drift.LastDate, drift.ServedMiles = last.X, last.Y
+		drift.ExpectedMiles = total
+	}
+	return drift
+}
+
+func dayOf(summary aggregation.Summary, date string) aggregation.DaySummary {
+	if day, ok := summary[date]; ok && day != nil {
+		return *day
+	}
+	return aggregation.DaySummary{}
+}
+
+// without returns the IDs in ids that aren't in other.
+func without(ids, other []int64) []int64 {
+	var result []int64
+	for _, id := range ids {
+		if !slices.Contains(other, id) {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func totalMiles(summary aggregation.Summary) float64 {
+	var total float64
+	for _, day := range summary {
+		total += day.DistanceMiles
+	}
+	return total
+}
@@ -0,0 +1,129 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+// storeAggregates writes the summary and distances the processor would for
+// activities.
+func storeAggregates(t *testing.T, store *memoryStore, year int, activities []strava.Activity) {
+	t.Helper()
+	summary := aggregation.Summarize(activities, []string{"Ride", "VirtualRide"})[year]
+	data, _ := json.Marshal(summary)
+	if err := store.Write(context.Background(), aggregation.SummaryBlob(year), data, AnyGeneration); err != nil {
+		t.Fatal(err)
+	}
+	distances := aggregation.Build(summary, year, time.Date(year, time.March, 10, 0, 0, 0, 0, time.UTC), aggregation.Options{})
+	data, _ = json.Marshal(distances)
+	if err := store.Write(context.Background(), aggregation.DistancesBlob(year), data, AnyGeneration); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateYear_Matches(t *testing.T) {
+	activities := []strava.Activity{
+		ride(1, "2025-03-01T09:00:00Z", 16093.4),
+		ride(2, "2025-03-01T17:00:00Z", 8046.7),
+		ride(3, "2025-03-05T09:00:00Z", 32186.8),
+		// Outside the year, as listed with a margin
+		ride(4, "2024-12-31T22:00:00Z", 10000),
+	}
+	store := newMemoryStore()
+	storeAggregates(t, store, 2025, activities)
+
+	report, err := ValidateYear(context.Background(), store, 2025, activities, []string{"Ride"}, DefaultDriftTolerance)
+	if err != nil {
+		t.Fatalf("ValidateYear failed: %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("expected matching aggregates, got %+v", report)
+	}
+	if report.Activities != 3 {
+		t.Errorf("expected 3 counted activities, got %d", report.Activities)
+	}
+}
+
+func TestValidateYear_ReportsDrift(t *testing.T) {
+	served := []strava.Activity{
+		ride(1, "2025-03-01T09:00:00Z", 16093.4),
+		ride(2, "2025-03-03T09:00:00Z", 8046.7),
+		ride(3, "2025-03-05T09:00:00Z", 32186.8),
+	}
+	store := newMemoryStore()
+	storeAggregates(t, store, 2025, served)
+
+	// Activity 2 was deleted, 3 was shortened and 5 never counted
+	source := []strava.Activity{
+		served[0],
+		ride(3, "2025-03-05T09:00:00Z", 30000),
+		ride(5, "2025-03-07T09:00:00Z", 1609.34),
+	}
+	report, err := ValidateYear(context.Background(), store, 2025, source, []string{"Ride"}, DefaultDriftTolerance)
+	if err != nil {
+		t.Fatalf("ValidateYear failed: %v", err)
+	}
+	if report.OK() {
+		t.Fatal("expected drift to be reported")
+	}
+
+	var dates []string
+	for _, day := range report.Days {
+		dates = append(dates, day.Date)
+	}
+	if want := []string{"2025-03-03", "2025-03-05", "2025-03-07"}; !reflect.DeepEqual(dates, want) {
+		t.Fatalf("expected drift on %v, got %v", want, dates)
+	}
+	if extra := report.Days[0].Extra; !reflect.DeepEqual(extra, []int64{2}) {
+		t.Errorf("expected deleted activity 2 as extra, got %v", extra)
+	}
+	if day := report.Days[1]; len(day.Missing)+len(day.Extra) != 0 || day.ServedMiles <= day.ExpectedMiles {
+		t.Errorf("expected a distance-only difference on 2025-03-05, got %+v", day)
+	}
+	if missing := report.Days[2].Missing; !reflect.DeepEqual(missing, []int64{5}) {
+		t.Errorf("expected uncounted activity 5 as missing, got %v", missing)
+	}
+
+	series := report.Distances
+	if series == nil {
+		t.Fatal("expected distances.json drift")
+	}
+	if series.FirstDate != "2025-03-03" || series.LastDate != "2025-03-10" {
+		t.Errorf("unexpected series drift %+v", series)
+	}
+	if series.ExpectedMiles != report.ExpectedMiles || series.ServedMiles != report.ServedMiles {
+		t.Errorf("expected the series totals to match the summaries' on the last date, got %+v", series)
+	}
+}
+
+func TestValidateYear_WithinTolerance(t *testing.T) {
+	activities := []strava.Activity{ride(1, "2025-03-01T09:00:00Z", 16093.4)}
+	store := newMemoryStore()
+	storeAggregates(t, store, 2025, activities)
+
+	activities[0].Distance += 1
+	report, err := ValidateYear(context.Background(), store, 2025, activities, []string{"Ride"}, DefaultDriftTolerance)
+	if err != nil {
+		t.Fatalf("ValidateYear failed: %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("expected a one-meter difference to be tolerated, got %+v", report)
+	}
+}
+
+func TestValidateYear_MissingBlobs(t *testing.T) {
+	activities := []strava.Activity{ride(1, "2025-03-01T09:00:00Z", 16093.4)}
+	report, err := ValidateYear(context.Background(), newMemoryStore(), 2025, activities, []string{"Ride"}, DefaultDriftTolerance)
+	if err != nil {
+		t.Fatalf("ValidateYear failed: %v", err)
+	}
+	if !report.MissingSummary || !report.MissingDistances || len(report.Days) != 1 {
+		t.Errorf("expected missing blobs and the uncounted day, got %+v", report)
+	}
+}
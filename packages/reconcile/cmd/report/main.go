@@ -0,0 +1,90 @@
+// Command report writes the activities missing from the target table as a
+// JSON report grouped by athlete and month, for scheduled checks and
+// dashboards:
+//
+//	report -source legacy.strava.activities -target desirelines-prod.desirelines.activities
+//	report -start-date 2025-01-01 -out gaps.json -fail-on-missing
+//
+// See reconcile.Report for the format.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/reconcile"
+)
+
+func main() {
+	log.SetFlags(0)
+	configFile := flag.String("config", "", "Config file of KEY=VALUE settings or a JSON object (default $CONFIG_FILE)")
+	flag.String("source", "", "project.dataset.table of every activity (default $RECONCILE_SOURCE_TABLE)")
+	flag.String("target", "", "project.dataset.table of the pipeline's activities (default $RECONCILE_TARGET_TABLE)")
+	flag.String("project", "", "Project to run the query in (default $GCP_PROJECT_ID or the source's project)")
+	startDate := flag.String("start-date", "", "Only activities started on or after this date (YYYY-MM-DD, UTC)")
+	endDate := flag.String("end-date", "", "Only activities started before this date (YYYY-MM-DD, UTC)")
+	out := flag.String("out", "", "File to write the report to (default: stdout)")
+	failOnMissing := flag.Bool("fail-on-missing", false, "Exit with status 1 when any activity is missing")
+	flag.Parse()
+	if err := config.Setup(*configFile); err != nil {
+		log.Fatalf("Failed to load config file: %v", err)
+	}
+	config.BindFlags(flag.CommandLine, map[string]string{
+		"source":  "RECONCILE_SOURCE_TABLE",
+		"target":  "RECONCILE_TARGET_TABLE",
+		"project": "GCP_PROJECT_ID",
+	})
+
+	source, err := reconcile.ParseTable(config.Get("RECONCILE_SOURCE_TABLE"))
+	if err != nil {
+		log.Fatalf("Invalid -source: %v", err)
+	}
+	target, err := reconcile.ParseTable(config.Get("RECONCILE_TARGET_TABLE"))
+	if err != nil {
+		log.Fatalf("Invalid -target: %v", err)
+	}
+	query := reconcile.Query{Source: source, Target: target, StartDate: *startDate, EndDate: *endDate}
+	if err := query.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
+	ctx := context.Background()
+	client, err := bigquery.NewClient(ctx, config.GetOrDefault("GCP_PROJECT_ID", source.Project))
+	if err != nil {
+		log.Fatalf("Failed to create BigQuery client: %v", err)
+	}
+	defer client.Close()
+
+	activities, err := reconcile.MissingActivities(ctx, client, query)
+	if err != nil {
+		log.Fatalf("Failed to query missing activities: %v", err)
+	}
+	report := reconcile.NewReport(query, activities, time.Now())
+
+	output := os.Stdout
+	if *out != "" {
+		output, err = os.Create(*out)
+		if err != nil {
+			log.Fatalf("Failed to create report: %v", err)
+		}
+	}
+	encoder := json.NewEncoder(output)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+	if err := output.Close(); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+	log.Printf("%d missing activities across %d athletes", report.Missing, len(report.Athletes))
+
+	if *failOnMissing && report.Missing > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,183 @@
+// Package reconcile finds activities the desirelines pipeline missed: those
+// in a source BigQuery table, e.g. the legacy project's, that the target
+// activities table doesn't have. The backfill script replays them and the
+// report command summarizes them by athlete and month:
+//
+//	query := reconcile.Query{
+//		Source: reconcile.Table{Project: "legacy", Dataset: "strava", Table: "activities"},
+//		Target: reconcile.Table{Project: "desirelines-prod", Dataset: "desirelines", Table: "activities"},
+//	}
+//	gaps, err := reconcile.MissingActivities(ctx, client, query)
+//	report := reconcile.NewReport(query, gaps, time.Now())
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/iterator"
+)
+
+// identifierPattern matches project, dataset and table names, which are
+// interpolated into the query.
+var identifierPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Table identifies a BigQuery table.
+type Table struct {
+	Project string
+	Dataset string
+	Table   string
+}
+
+// ParseTable parses "project.dataset.table".
+func ParseTable(value string) (Table, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 {
+		return Table{}, fmt.Errorf("invalid BigQuery table %q (expected project.dataset.table)", value)
+	}
+	table := Table{Project: parts[0], Dataset: parts[1], Table: parts[2]}
+	return table, table.Validate()
+}
+
+// String returns the table as project.dataset.table.
+func (t Table) String() string {
+	return t.Project + "." + t.Dataset + "." + t.Table
+}
+
+// Validate checks that the table's names are safe to put in a query.
+func (t Table) Validate() error {
+	for _, part := range []string{t.Project, t.Dataset, t.Table} {
+		if !identifierPattern.MatchString(part) {
+			return fmt.Errorf("invalid BigQuery table %q", t.String())
+		}
+	}
+	return nil
+}
+
+// Query selects the activities to look for.
+type Query struct {
+	// Source is the table of every activity, with the legacy schema's
+	// id, athlete.id and start_date columns; Target is the pipeline's
+	// activities table, with an id column.
+	Source Table
+	Target Table
+	// StartDate and EndDate, as YYYY-MM-DD in UTC, limit the activities to
+	// those started from StartDate until before EndDate; either may be
+	// empty.
+	StartDate string
+	EndDate   string
+	// Limit caps how many activities are returned, 0 for all.
+	Limit int
+}
+
+// Validate reports every invalid setting at once.
+func (q Query) Validate() error {
+	var errs []error
+	if err := q.Source.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("source: %w", err))
+	}
+	if err := q.Target.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("target: %w", err))
+	}
+	for _, setting := range []struct{ name, value string }{
+		{"start date", q.StartDate},
+		{"end date", q.EndDate},
+	} {
+		if _, err := time.Parse(time.DateOnly, setting.value); setting.value != "" && err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s %q (want YYYY-MM-DD)", setting.name, setting.value))
+		}
+	}
+	if q.StartDate != "" && q.EndDate != "" && q.EndDate <= q.StartDate {
+		// Both are YYYY-MM-DD, so they order as strings
+		errs = append(errs, errors.New("end date must be after start date"))
+	}
+	if q.Limit < 0 {
+		errs = append(errs, errors.New("limit must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// SQL returns the query's statement, oldest activity first. The dates are
+// the @start_date and @end_date parameters.
+func (q Query) SQL() string {
+	var sql strings.Builder
+	fmt.Fprintf(&sql, `SELECT
+	pr.id AS missing_activity_id,
+	pr.athlete.id AS athlete_id,
+	pr.start_date
+FROM %s AS pr
+LEFT JOIN %s AS de
+ON pr.id = de.id
+WHERE de.id IS NULL`, quote(q.Source), quote(q.Target))
+	if q.StartDate != "" {
+		sql.WriteString("\n\tAND pr.start_date >= @start_date")
+	}
+	if q.EndDate != "" {
+		sql.WriteString("\n\tAND pr.start_date < @end_date")
+	}
+	sql.WriteString("\nORDER BY pr.start_date")
+	if q.Limit > 0 {
+		fmt.Fprintf(&sql, "\nLIMIT %d", q.Limit)
+	}
+	return sql.String()
+}
+
+// parameters returns the values of the query's date parameters.
+func (q Query) parameters() []bigquery.QueryParameter {
+	var params []bigquery.QueryParameter
+	for _, param := range []struct{ name, value string }{
+		{"start_date", q.StartDate},
+		{"end_date", q.EndDate},
+	} {
+		if param.value == "" {
+			continue
+		}
+		// Validate checked the format
+		date, _ := time.Parse(time.DateOnly, param.value)
+		params = append(params, bigquery.QueryParameter{Name: param.name, Value: date})
+	}
+	return params
+}
+
+func quote(t Table) string {
+	return "`" + t.String() + "`"
+}
+
+// Activity is an activity missing from the target table.
+type Activity struct {
+	ID        int64     `bigquery:"missing_activity_id" json:"id"`
+	AthleteID int64     `bigquery:"athlete_id" json:"athlete_id"`
+	StartDate time.Time `bigquery:"start_date" json:"start_date"`
+}
+
+// MissingActivities runs q with client, returning the source activities
+// the target table lacks, oldest first.
+func MissingActivities(ctx context.Context, client *bigquery.Client, q Query) ([]Activity, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	query := client.Query(q.SQL())
+	query.Parameters = q.parameters()
+	it, err := query.Read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	var activities []Activity
+	for {
+		var activity Activity
+		err := it.Next(&activity)
+		if errors.Is(err, iterator.Done) {
+			return activities, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read query results: %w", err)
+		}
+		activities = append(activities, activity)
+	}
+}
@@ -0,0 +1,132 @@
+package reconcile
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testQuery() Query {
+	return Query{
+		Source: Table{Project: "legacy", Dataset: "strava", Table: "activities"},
+		Target: Table{Project: "desirelines-prod", Dataset: "desirelines", Table: "activities"},
+	}
+}
+
+func TestParseTable(t *testing.T) {
+	table, err := ParseTable("desirelines-prod.desirelines.activities")
+	if err != nil {
+		t.Fatalf("ParseTable failed: %v", err)
+	}
+	if table != (Table{Project: "desirelines-prod", Dataset: "desirelines", Table: "activities"}) {
+		t.Errorf("unexpected table %+v", table)
+	}
+
+	for _, value := range []string{"desirelines.activities", "p.d.t.x", "p.d.t`; DROP", "p..t"} {
+		if _, err := ParseTable(value); err == nil {
+			t.Errorf("expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestQuerySQL(t *testing.T) {
+	q := testQuery()
+	sql := q.SQL()
+	for _, want := range []string{"FROM `legacy.strava.activities` AS pr", "LEFT JOIN `desirelines-prod.desirelines.activities` AS de", "WHERE de.id IS NULL", "ORDER BY pr.start_date"} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("expected %q in:\n%s", want, sql)
+		}
+	}
+	if strings.Contains(sql, "@start_date") || strings.Contains(sql, "LIMIT") {
+		t.Errorf("expected no date filter or limit without dates, got:\n%s", sql)
+	}
+	if len(q.parameters()) != 0 {
+		t.Errorf("expected no parameters, got %v", q.parameters())
+	}
+
+	q.StartDate, q.EndDate, q.Limit = "2025-01-01", "2025-02-01", 50
+	sql = q.SQL()
+	for _, want := range []string{"AND pr.start_date >= @start_date", "AND pr.start_date < @end_date", "LIMIT 50"} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("expected %q in:\n%s", want, sql)
+		}
+	}
+	params := q.parameters()
+	if len(params) != 2 || params[0].Name != "start_date" || params[0].Value != time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("unexpected parameters %v", params)
+	}
+}
+
+func TestQueryValidate(t *testing.T) {
+	if err := testQuery().Validate(); err != nil {
+		t.Errorf("expected a valid query, got %v", err)
+	}
+
+	q := testQuery()
+	q.Target.Dataset = "bad name"
+	q.StartDate = "2025-13-01"
+	q.Limit = -1
+	err := q.Validate()
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	for _, want := range []string{"target", "start date", "limit"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q reported, got: %v", want, err)
+		}
+	}
+
+	q = testQuery()
+	q.StartDate, q.EndDate = "2025-02-01", "2025-01-01"
+	if err := q.Validate(); err == nil {
+		t.Error("expected an end date before the start date to be rejected")
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	at := func(date string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	activities := []Activity{
+		{ID: 5, AthleteID: 2, StartDate: at("2025-02-03T10:00:00Z")},
+		{ID: 1, AthleteID: 1, StartDate: at("2025-01-05T10:00:00Z")},
+		{ID: 3, AthleteID: 1, StartDate: at("2025-02-01T00:30:00Z")},
+		{ID: 2, AthleteID: 1, StartDate: at("2025-01-31T23:30:00Z")},
+	}
+	now := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.FixedZone("EST", -5*3600))
+	q := testQuery()
+	q.StartDate = "2025-01-01"
+
+	report := NewReport(q, activities, now)
+	if report.Missing != 4 || report.Source != "legacy.strava.activities" || report.StartDate != "2025-01-01" || !report.GeneratedAt.Equal(now) {
+		t.Errorf("unexpected report header %+v", report)
+	}
+	want := []AthleteGaps{
+		{AthleteID: 1, Missing: 3, Months: []MonthGap{
+			{Month: "2025-01", Missing: 2, ActivityIDs: []int64{1, 2}},
+			{Month: "2025-02", Missing: 1, ActivityIDs: []int64{3}},
+		}},
+		{AthleteID: 2, Missing: 1, Months: []MonthGap{
+			{Month: "2025-02", Missing: 1, ActivityIDs: []int64{5}},
+		}},
+	}
+	if !reflect.DeepEqual(report.Athletes, want) {
+		t.Errorf("expected %+v, got %+v", want, report.Athletes)
+	}
+}
+
+func TestNewReportEmpty(t *testing.T) {
+	data, err := json.Marshal(NewReport(testQuery(), nil, time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"athletes":[]`) {
+		t.Errorf("expected an empty athletes list rather than null, got %s", data)
+	}
+}
@@ -0,0 +1,70 @@
+package reconcile
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// Report groups missing activities by athlete and month, for scheduled
+// checks and dashboards.
+type Report struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Source      string    `json:"source"`
+	Target      string    `json:"target"`
+	StartDate   string    `json:"start_date,omitempty"`
+	EndDate     string    `json:"end_date,omitempty"`
+	// Missing counts every missing activity.
+	Missing  int           `json:"missing"`
+	Athletes []AthleteGaps `json:"athletes"`
+}
+
+// AthleteGaps are one athlete's missing activities.
+type AthleteGaps struct {
+	AthleteID int64      `json:"athlete_id"`
+	Missing   int        `json:"missing"`
+	Months    []MonthGap `json:"months"`
+}
+
+// MonthGap lists the missing activities that started in one month.
+type MonthGap struct {
+	// Month is YYYY-MM, in UTC like the query's dates.
+	Month       string  `json:"month"`
+	Missing     int     `json:"missing"`
+	ActivityIDs []int64 `json:"activity_ids"`
+}
+
+// NewReport groups activities by athlete, in ascending ID order, and by
+// month, oldest first.
+func NewReport(q Query, activities []Activity, now time.Time) Report {
+	report := Report{
+		GeneratedAt: now.UTC(),
+		Source:      q.Source.String(),
+		Target:      q.Target.String(),
+		StartDate:   q.StartDate,
+		EndDate:     q.EndDate,
+		Missing:     len(activities),
+		Athletes:    []AthleteGaps{},
+	}
+
+	sorted := slices.Clone(activities)
+	slices.SortStableFunc(sorted, func(a, b Activity) int {
+		return cmp.Or(cmp.Compare(a.AthleteID, b.AthleteID), a.StartDate.Compare(b.StartDate), cmp.Compare(a.ID, b.ID))
+	})
+	for _, activity := range sorted {
+		if n := len(report.Athletes); n == 0 || report.Athletes[n-1].AthleteID != activity.AthleteID {
+			report.Athletes = append(report.Athletes, AthleteGaps{AthleteID: activity.AthleteID})
+		}
+		athlete := &report.Athletes[len(report.Athletes)-1]
+		athlete.Missing++
+
+		month := activity.StartDate.UTC().Format("2006-01")
+		if n := len(athlete.Months); n == 0 || athlete.Months[n-1].Month != month {
+			athlete.Months = append(athlete.Months, MonthGap{Month: month})
+		}
+		gap := &athlete.Months[len(athlete.Months)-1]
+		gap.Missing++
+		gap.ActivityIDs = append(gap.ActivityIDs, activity.ID)
+	}
+	return report
+}
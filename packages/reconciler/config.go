@@ -0,0 +1,151 @@
+package reconciler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/alert"
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/reconcile"
+)
+
+const (
+	// DefaultLookback is how many days before today a run checks. Runs
+	// are daily, so a week lets a few failed runs catch up.
+	DefaultLookback = 7 * 24 * time.Hour
+	// DefaultMaxReplays bounds the replays per run, so a broken target
+	// table doesn't replay the athlete's whole history through Strava's
+	// rate limits at once.
+	DefaultMaxReplays = 100
+)
+
+// Config holds all configuration for the reconciler.
+type Config struct {
+	// SourceTable and TargetTable are compared as in reconcile.Query.
+	SourceTable string
+	TargetTable string
+	// GCPProjectID runs the query and owns the topic; the query defaults
+	// to the source's project.
+	GCPProjectID string
+	// TopicID is the dispatcher's topic replayed events are published to.
+	TopicID  string
+	LogLevel string
+	Lookback time.Duration
+	// Alerts posts failed runs and large gaps to a Slack or Discord
+	// webhook; disabled without ALERT_WEBHOOK_URL.
+	Alerts *alert.Config
+	// MaxReplays bounds the replays per run; the rest wait for the next.
+	MaxReplays int
+	// AlertThreshold is how many missing activities a run tolerates
+	// before alerting.
+	AlertThreshold int
+	SubscriptionID int
+	DryRun         bool
+}
+
+// LoadConfig loads configuration from environment variables.
+func LoadConfig() (*Config, error) {
+	var errs []error
+	lookback, err := config.Duration("RECONCILE_LOOKBACK", DefaultLookback)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	maxReplays, err := intSetting("RECONCILE_MAX_REPLAYS", DefaultMaxReplays)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	subscriptionID, err := intSetting("RECONCILE_SUBSCRIPTION_ID", 0)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	alertThreshold, err := intSetting("RECONCILE_ALERT_THRESHOLD", 0)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	alerts, err := alert.LoadConfig()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	dryRun, err := strconv.ParseBool(config.GetOrDefault("RECONCILE_DRY_RUN", "false"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid RECONCILE_DRY_RUN: %q", config.Get("RECONCILE_DRY_RUN")))
+	}
+
+	cfg := &Config{
+		SourceTable:    config.Get("RECONCILE_SOURCE_TABLE"),
+		TargetTable:    config.Get("RECONCILE_TARGET_TABLE"),
+		GCPProjectID:   config.Get("GCP_PROJECT_ID"),
+		TopicID:        config.Get("GCP_PUBSUB_TOPIC"),
+		LogLevel:       config.GetOrDefault("LOG_LEVEL", "INFO"),
+		Lookback:       lookback,
+		Alerts:         alerts,
+		MaxReplays:     maxReplays,
+		AlertThreshold: alertThreshold,
+		SubscriptionID: subscriptionID,
+		DryRun:         dryRun,
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// intSetting parses key as an integer, defaultValue when it is unset.
+func intSetting(key string, defaultValue int) (int, error) {
+	value := config.Get(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue, fmt.Errorf("invalid %s: %q", key, value)
+	}
+	return n, nil
+}
+
+// Query returns the comparison of the source and target tables, without
+// dates. Validate must have passed.
+func (c *Config) Query() reconcile.Query {
+	source, _ := reconcile.ParseTable(c.SourceTable)
+	target, _ := reconcile.ParseTable(c.TargetTable)
+	return reconcile.Query{Source: source, Target: target}
+}
+
+// Validate checks that the settings needed to reconcile are present,
+// reporting every problem at once.
+func (c *Config) Validate() error {
+	var errs []error
+	for _, table := range []struct{ name, value string }{
+		{"RECONCILE_SOURCE_TABLE", c.SourceTable},
+		{"RECONCILE_TARGET_TABLE", c.TargetTable},
+	} {
+		if table.value == "" {
+			errs = append(errs, fmt.Errorf("%s is required", table.name))
+		} else if _, err := reconcile.ParseTable(table.value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", table.name, err))
+		}
+	}
+	if !c.DryRun {
+		if c.GCPProjectID == "" {
+			errs = append(errs, errors.New("GCP_PROJECT_ID is required to publish replayed events"))
+		}
+		if c.TopicID == "" {
+			errs = append(errs, errors.New("GCP_PUBSUB_TOPIC is required to publish replayed events"))
+		}
+	}
+	if c.Lookback < 24*time.Hour {
+		errs = append(errs, fmt.Errorf("RECONCILE_LOOKBACK must be at least a day, got %s", c.Lookback))
+	}
+	if c.MaxReplays <= 0 {
+		errs = append(errs, fmt.Errorf("RECONCILE_MAX_REPLAYS must be positive, got %d", c.MaxReplays))
+	}
+	if c.AlertThreshold < 0 {
+		errs = append(errs, fmt.Errorf("RECONCILE_ALERT_THRESHOLD must not be negative, got %d", c.AlertThreshold))
+	}
+	if err := c.Alerts.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,61 @@
+package reconciler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	valid := func() Config {
+		return Config{
+			SourceTable:  "legacy.strava.activities",
+			TargetTable:  "desirelines-prod.desirelines.activities",
+			GCPProjectID: "desirelines-prod",
+			TopicID:      "desirelines_activity_events",
+			Lookback:     DefaultLookback,
+			MaxReplays:   DefaultMaxReplays,
+		}
+	}
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr []string
+	}{
+		{name: "valid", modify: func(*Config) {}},
+		{
+			name:   "dry run doesn't publish",
+			modify: func(c *Config) { c.DryRun, c.TopicID, c.GCPProjectID = true, "", "" },
+		},
+		{
+			name:    "missing tables and topic",
+			modify:  func(c *Config) { c.SourceTable, c.TargetTable, c.TopicID = "", "strava.activities", "" },
+			wantErr: []string{"RECONCILE_SOURCE_TABLE", "RECONCILE_TARGET_TABLE", "GCP_PUBSUB_TOPIC"},
+		},
+		{
+			name:    "unbounded",
+			modify:  func(c *Config) { c.Lookback, c.MaxReplays = 0, 0 },
+			wantErr: []string{"RECONCILE_LOOKBACK", "RECONCILE_MAX_REPLAYS"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("expected valid config, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected errors mentioning %v", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected %q in error, got %v", want, err)
+				}
+			}
+		})
+	}
+}
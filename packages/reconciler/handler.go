@@ -0,0 +1,182 @@
+package reconciler
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/andy-esch/desirelines/packages/alert"
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/reconcile"
+)
+
+// Handler runs the reconciler for Cloud Scheduler: a POST reconciles and
+// answers with the summary.
+type Handler struct {
+	reconciler *Reconciler
+	// bigquery and publisher are nil when the reconciler was passed in,
+	// and publisher is nil in dry runs
+	bigquery  *bigquery.Client
+	publisher Publisher
+	// alerter posts failed runs and runs that found more than
+	// alertThreshold missing activities; nil without ALERT_WEBHOOK_URL
+	alerter        *alert.Alerter
+	alertThreshold int
+}
+
+// NewHandler creates a handler from the environment.
+func NewHandler(ctx context.Context) (*Handler, error) {
+	if err := config.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", config.FileEnv, err)
+	}
+	cfg, err := LoadConfig()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	if err := SetLogLevel(cfg.LogLevel); err != nil {
+		return nil, err
+	}
+
+	query := cfg.Query()
+	client, err := bigquery.NewClient(ctx, cmp.Or(cfg.GCPProjectID, query.Source.Project))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create BigQuery client: %w", err)
+	}
+	find := func(ctx context.Context, q reconcile.Query) ([]reconcile.Activity, error) {
+		return reconcile.MissingActivities(ctx, client, q)
+	}
+
+	opts := []Option{WithSubscriptionID(cfg.SubscriptionID)}
+	var publisher Publisher
+	if cfg.DryRun {
+		opts = append(opts, WithDryRun())
+	} else {
+		publisher, err = NewPubSubPublisher(ctx, cfg.GCPProjectID, cfg.TopicID)
+		if err != nil {
+			_ = client.Close()
+			return nil, err
+		}
+	}
+
+	Logger.Info("Reconciler initialized", "source", query.Source.String(), "target", query.Target.String(), "topic", cfg.TopicID,
+		"lookback", cfg.Lookback.String(), "max_replays", cfg.MaxReplays, "dry_run", cfg.DryRun)
+	alerter := alert.Open(cfg.Alerts, "reconciler")
+	if alerter != nil {
+		Logger.Info("Alerting on failed runs and gaps", "format", cfg.Alerts.Format, "alert_threshold", cfg.AlertThreshold)
+	}
+	reconciler := NewReconciler(find, publisher, query, cfg.Lookback, cfg.MaxReplays, opts...)
+	return &Handler{reconciler: reconciler, bigquery: client, publisher: publisher, alerter: alerter, alertThreshold: cfg.AlertThreshold}, nil
+}
+
+// NewHandlerWithReconciler creates a handler around an existing
+// reconciler, posting failed runs and runs with more than alertThreshold
+// missing activities to alerter if it is not nil (useful for testing).
+func NewHandlerWithReconciler(reconciler *Reconciler, alerter *alert.Alerter, alertThreshold int) *Handler {
+	return &Handler{reconciler: reconciler, alerter: alerter, alertThreshold: alertThreshold}
+}
+
+// Close releases the BigQuery client and the publisher.
+func (h *Handler) Close() error {
+	var err error
+	if h.bigquery != nil {
+		err = h.bigquery.Close()
+	}
+	if h.publisher != nil {
+		err = errors.Join(err, h.publisher.Close())
+	}
+	return err
+}
+
+// ServeHTTP reconciles on POST, serves metrics on /metrics and answers
+// liveness probes on /live. A run in which any replay failed is answered
+// with a 500, so Cloud Scheduler records it as failed. Activities
+// replayed before reach the target table and drop out of the query, so a
+// retry only replays the rest.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/metrics":
+		MetricsHandler().ServeHTTP(w, r)
+		return
+	case r.URL.Path == "/live":
+		writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+		return
+	case r.Method != http.MethodPost:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	summary, err := h.reconciler.Run(r.Context())
+	lastRun.SetToCurrentTime()
+	if err != nil {
+		runs.WithLabelValues("failed").Inc()
+		Logger.ErrorContext(r.Context(), "Reconciliation failed", "error", err)
+		h.alert(r.Context(), alert.Alert{Title: "Reconciliation failed", Text: err.Error()})
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Reconciliation failed"})
+		return
+	}
+	if summary.Report.Missing > h.alertThreshold {
+		h.alert(r.Context(), gapAlert(summary))
+	}
+	if summary.Failed > 0 {
+		runs.WithLabelValues("partial").Inc()
+		Logger.ErrorContext(r.Context(), "Some missing activities failed to replay", summaryArgs(summary)...)
+		writeJSON(w, http.StatusInternalServerError, summary)
+		return
+	}
+	runs.WithLabelValues("ok").Inc()
+	Logger.InfoContext(r.Context(), "Reconciled activities", summaryArgs(summary)...)
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// alert posts a run's alert, logging rather than failing the run when it
+// can't.
+func (h *Handler) alert(ctx context.Context, a alert.Alert) {
+	if err := h.alerter.Send(ctx, a); err != nil {
+		Logger.WarnContext(ctx, "Failed to send alert", "error", err)
+	}
+}
+
+// gapAlert reports the missing activities a run found and what it did
+// about them.
+func gapAlert(summary Summary) alert.Alert {
+	report := summary.Report
+	return alert.Alert{
+		Key:   "gaps",
+		Title: fmt.Sprintf("%d activities missing from %s", report.Missing, report.Target),
+		Text:  fmt.Sprintf("%s to %s: %d replayed, %d failed, %d deferred to the next run", report.StartDate, report.EndDate, summary.Replayed, summary.Failed, summary.Deferred),
+		Fields: []alert.Field{
+			{Name: "Athletes", Value: strconv.Itoa(len(report.Athletes))},
+			{Name: "Dry run", Value: strconv.FormatBool(summary.DryRun)},
+		},
+	}
+}
+
+// summaryArgs are the log attributes of a run's summary.
+func summaryArgs(summary Summary) []any {
+	return []any{
+		"start_date", summary.Report.StartDate,
+		"end_date", summary.Report.EndDate,
+		"missing", summary.Report.Missing,
+		"athletes", len(summary.Report.Athletes),
+		"replayed", summary.Replayed,
+		"failed", summary.Failed,
+		"deferred", summary.Deferred,
+		"dry_run", summary.DryRun,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		Logger.Error("Failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,78 @@
+package reconciler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/alert"
+	"github.com/andy-esch/desirelines/packages/reconcile"
+)
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	var query reconcile.Query
+	handler := NewHandlerWithReconciler(newTestReconciler(&mockPublisher{}, testActivities(), &query, 100), nil, 0)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var summary Summary
+	if err := json.Unmarshal(rr.Body.Bytes(), &summary); err != nil || summary.Replayed != 3 || summary.Report.Missing != 3 {
+		t.Errorf("unexpected summary %s", rr.Body)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(rr.Body.String(), "reconciler_missing_activities 3") ||
+		!strings.Contains(rr.Body.String(), `reconciler_runs_total{result="ok"}`) {
+		t.Errorf("expected reconciliation metrics, got %s", rr.Body)
+	}
+}
+
+func TestHandler_ServeHTTPPartialFailure(t *testing.T) {
+	var query reconcile.Query
+	publisher := &mockPublisher{failIDs: map[int64]bool{3: true}}
+	rr := httptest.NewRecorder()
+	NewHandlerWithReconciler(newTestReconciler(publisher, testActivities(), &query, 100), nil, 0).ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 for a failed replay, got %d", rr.Code)
+	}
+	var summary Summary
+	if err := json.Unmarshal(rr.Body.Bytes(), &summary); err != nil || summary.Failed != 1 || summary.Replayed != 2 {
+		t.Errorf("expected the failure reported, got %s", rr.Body)
+	}
+}
+
+func TestHandler_ServeHTTPAlertsOnGaps(t *testing.T) {
+	var alerts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		alerts = append(alerts, string(body))
+	}))
+	defer server.Close()
+
+	var query reconcile.Query
+	for _, threshold := range []int{3, 2} {
+		alerter := alert.New(server.URL, alert.FormatSlack, "reconciler")
+		rr := httptest.NewRecorder()
+		NewHandlerWithReconciler(newTestReconciler(&mockPublisher{}, testActivities(), &query, 100), alerter, threshold).ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+		}
+	}
+	// Only the run with more gaps than its threshold alerts
+	if len(alerts) != 1 || !strings.Contains(alerts[0], "3 activities missing") || !strings.Contains(alerts[0], "3 replayed, 0 failed") {
+		t.Errorf("expected one gap alert, got %q", alerts)
+	}
+}
@@ -0,0 +1,43 @@
+package reconciler
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsRegistry holds the reconciler's metrics, served on /metrics.
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	missingActivities = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "reconciler_missing_activities",
+		Help: "Missing activities found by the last run, replayed or not.",
+	})
+
+	replays = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "reconciler_replays_total",
+		Help: "Missing activities replayed, by result (replayed or failed).",
+	}, []string{"result"})
+
+	runs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "reconciler_runs_total",
+		Help: "Reconciliation runs, by result (ok, partial when some replays failed, or failed).",
+	}, []string{"result"})
+
+	lastRun = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "reconciler_last_run_timestamp_seconds",
+		Help: "Unix time the last reconciliation run finished.",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(missingActivities, replays, runs, lastRun)
+}
+
+// MetricsHandler serves the reconciler's metrics in the Prometheus text
+// format.
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,70 @@
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"cloud.google.com/go/pubsub/v2"
+	"github.com/google/uuid"
+)
+
+// sourceReconcile is the source attribute of replayed events, so
+// consumers can tell them from Strava's, from the dispatcher's replays
+// (source=replay) and from the backfill script's (source=backfill).
+const sourceReconcile = "reconcile"
+
+// PubSubPublisher publishes events to the dispatcher's topic in the shape
+// the dispatcher publishes them.
+type PubSubPublisher struct {
+	client    *pubsub.Client
+	publisher *pubsub.Publisher
+}
+
+// NewPubSubPublisher creates a publisher for topicID in projectID.
+func NewPubSubPublisher(ctx context.Context, projectID, topicID string) (*PubSubPublisher, error) {
+	client, err := pubsub.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Pub/Sub client: %w", err)
+	}
+	publisher := client.Publisher(fmt.Sprintf("projects/%s/topics/%s", projectID, topicID))
+	// Match the dispatcher's per-athlete ordering keys
+	publisher.EnableMessageOrdering = true
+	return &PubSubPublisher{client: client, publisher: publisher}, nil
+}
+
+// Publish implements the Publisher interface, waiting for the publish to
+// be acknowledged.
+func (p *PubSubPublisher) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	orderingKey := strconv.FormatInt(event.OwnerID, 10)
+	result := p.publisher.Publish(ctx, &pubsub.Message{
+		Data: data,
+		Attributes: map[string]string{
+			"correlation_id":  uuid.NewString(),
+			"aspect_type":     event.AspectType,
+			"object_type":     event.ObjectType,
+			"owner_id":        orderingKey,
+			"subscription_id": strconv.Itoa(event.SubscriptionID),
+			"source":          sourceReconcile,
+		},
+		OrderingKey: orderingKey,
+	})
+	if _, err := result.Get(ctx); err != nil {
+		// A failed ordered publish pauses its key until resumed
+		p.publisher.ResumePublish(orderingKey)
+		return fmt.Errorf("failed to publish to Pub/Sub: %w", err)
+	}
+	return nil
+}
+
+// Close implements the Publisher interface.
+func (p *PubSubPublisher) Close() error {
+	p.publisher.Stop()
+	return p.client.Close()
+}
@@ -0,0 +1,144 @@
+// Package reconciler replays the activities the pipeline missed. Run daily
+// by Cloud Scheduler, it finds the activities of the last days that the
+// source table has and the pipeline's activities table doesn't (see
+// packages/reconcile), publishes a create event for each to the
+// dispatcher's topic, up to a bound per run, and reports what it found
+// and replayed. It does automatically what the backfill script does by
+// hand.
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/reconcile"
+)
+
+// Finder returns the activities q selects that the target table lacks,
+// oldest first, like reconcile.MissingActivities.
+type Finder func(ctx context.Context, q reconcile.Query) ([]reconcile.Activity, error)
+
+// Event is a replayed activity's webhook event, in the format Strava
+// sends and the dispatcher publishes.
+type Event struct {
+	Updates        map[string]any `json:"updates"`
+	ObjectType     string         `json:"object_type"`
+	AspectType     string         `json:"aspect_type"`
+	ObjectID       int64          `json:"object_id"`
+	OwnerID        int64          `json:"owner_id"`
+	EventTime      int64          `json:"event_time"`
+	SubscriptionID int            `json:"subscription_id"`
+}
+
+// Publisher delivers replayed events.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+	Close() error
+}
+
+// Summary is the outcome of a run.
+type Summary struct {
+	// Report lists every missing activity found, replayed or not.
+	Report reconcile.Report `json:"report"`
+	// Replayed and Failed count the events published and those that
+	// failed to; Deferred counts the missing activities over the bound,
+	// left for the next run.
+	Replayed int  `json:"replayed"`
+	Failed   int  `json:"failed"`
+	Deferred int  `json:"deferred"`
+	DryRun   bool `json:"dry_run,omitempty"`
+}
+
+// Reconciler finds and replays missing activities.
+type Reconciler struct {
+	find      Finder
+	publisher Publisher
+	now       func() time.Time
+	query     reconcile.Query
+	// lookback is how many days before today are checked
+	lookback       time.Duration
+	maxReplays     int
+	subscriptionID int
+	dryRun         bool
+}
+
+// Option configures a Reconciler.
+type Option func(*Reconciler)
+
+// WithDryRun reports missing activities without replaying them.
+func WithDryRun() Option {
+	return func(r *Reconciler) { r.dryRun = true }
+}
+
+// WithSubscriptionID sets the subscription_id of replayed events, for
+// consumers checking it.
+func WithSubscriptionID(id int) Option {
+	return func(r *Reconciler) { r.subscriptionID = id }
+}
+
+// NewReconciler creates a reconciler comparing the tables of query over
+// the lookback before today, replaying at most maxReplays activities per
+// run, oldest first.
+func NewReconciler(find Finder, publisher Publisher, query reconcile.Query, lookback time.Duration, maxReplays int, opts ...Option) *Reconciler {
+	r := &Reconciler{
+		find:       find,
+		publisher:  publisher,
+		now:        time.Now,
+		query:      query,
+		lookback:   lookback,
+		maxReplays: maxReplays,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run checks the days from the lookback until today, UTC: activities
+// started today may still be on their way through the pipeline. An
+// activity failing to publish doesn't stop the others; the error is only
+// set when the missing activities can't be found.
+func (r *Reconciler) Run(ctx context.Context) (Summary, error) {
+	now := r.now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	query := r.query
+	query.StartDate = today.Add(-r.lookback).Format(time.DateOnly)
+	query.EndDate = today.Format(time.DateOnly)
+
+	activities, err := r.find(ctx, query)
+	if err != nil {
+		return Summary{}, fmt.Errorf("failed to find missing activities: %w", err)
+	}
+	summary := Summary{Report: reconcile.NewReport(query, activities, now), DryRun: r.dryRun}
+	missingActivities.Set(float64(len(activities)))
+	if len(activities) > r.maxReplays {
+		summary.Deferred = len(activities) - r.maxReplays
+		activities = activities[:r.maxReplays]
+	}
+	if r.dryRun {
+		return summary, nil
+	}
+
+	for _, activity := range activities {
+		event := Event{
+			Updates:        map[string]any{},
+			ObjectType:     "activity",
+			AspectType:     "create",
+			ObjectID:       activity.ID,
+			OwnerID:        activity.AthleteID,
+			EventTime:      activity.StartDate.Unix(),
+			SubscriptionID: r.subscriptionID,
+		}
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			summary.Failed++
+			replays.WithLabelValues("failed").Inc()
+			Logger.ErrorContext(ctx, "Failed to replay missing activity", "activity_id", activity.ID, "owner_id", activity.AthleteID, "error", err)
+			continue
+		}
+		summary.Replayed++
+		replays.WithLabelValues("replayed").Inc()
+		Logger.InfoContext(ctx, "Replayed missing activity", "activity_id", activity.ID, "owner_id", activity.AthleteID, "start_date", activity.StartDate)
+	}
+	return summary, nil
+}
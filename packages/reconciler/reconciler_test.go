@@ -0,0 +1,111 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/reconcile"
+)
+
+// mockPublisher records published events, failing those of failIDs.
+type mockPublisher struct {
+	failIDs map[int64]bool
+	events  []Event
+}
+
+func (p *mockPublisher) Publish(_ context.Context, event Event) error {
+	if p.failIDs[event.ObjectID] {
+		return errors.New("publish failed")
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+func (p *mockPublisher) Close() error { return nil }
+
+func testQuery() reconcile.Query {
+	return reconcile.Query{
+		Source: reconcile.Table{Project: "legacy", Dataset: "strava", Table: "activities"},
+		Target: reconcile.Table{Project: "desirelines-prod", Dataset: "desirelines", Table: "activities"},
+	}
+}
+
+// newTestReconciler finds activities and records the query it was asked.
+func newTestReconciler(publisher Publisher, activities []reconcile.Activity, got *reconcile.Query, maxReplays int, opts ...Option) *Reconciler {
+	find := func(_ context.Context, q reconcile.Query) ([]reconcile.Activity, error) {
+		*got = q
+		return activities, nil
+	}
+	r := NewReconciler(find, publisher, testQuery(), 7*24*time.Hour, maxReplays, append(opts, WithSubscriptionID(305683))...)
+	r.now = func() time.Time { return time.Date(2025, time.March, 10, 6, 0, 0, 0, time.UTC) }
+	return r
+}
+
+func testActivities() []reconcile.Activity {
+	return []reconcile.Activity{
+		{ID: 1, AthleteID: 7, StartDate: time.Date(2025, time.March, 4, 8, 0, 0, 0, time.UTC)},
+		{ID: 2, AthleteID: 7, StartDate: time.Date(2025, time.March, 6, 8, 0, 0, 0, time.UTC)},
+		{ID: 3, AthleteID: 9, StartDate: time.Date(2025, time.March, 8, 8, 0, 0, 0, time.UTC)},
+	}
+}
+
+func TestReconciler_Run(t *testing.T) {
+	publisher := &mockPublisher{failIDs: map[int64]bool{2: true}}
+	var query reconcile.Query
+	summary, err := newTestReconciler(publisher, testActivities(), &query, 100).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// The last week up to, not including, today
+	if query.StartDate != "2025-03-03" || query.EndDate != "2025-03-10" || query.Source != testQuery().Source {
+		t.Errorf("unexpected query %+v", query)
+	}
+	if summary.Replayed != 2 || summary.Failed != 1 || summary.Deferred != 0 || summary.Report.Missing != 3 || len(summary.Report.Athletes) != 2 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	want := Event{
+		Updates: map[string]any{}, ObjectType: "activity", AspectType: "create",
+		ObjectID: 1, OwnerID: 7, EventTime: testActivities()[0].StartDate.Unix(), SubscriptionID: 305683,
+	}
+	if len(publisher.events) != 2 || publisher.events[0].ObjectID != want.ObjectID || publisher.events[0].EventTime != want.EventTime ||
+		publisher.events[0].AspectType != want.AspectType || publisher.events[0].SubscriptionID != want.SubscriptionID || publisher.events[1].ObjectID != 3 {
+		t.Errorf("unexpected events %+v", publisher.events)
+	}
+}
+
+func TestReconciler_RunBounded(t *testing.T) {
+	publisher := &mockPublisher{}
+	var query reconcile.Query
+	summary, err := newTestReconciler(publisher, testActivities(), &query, 2).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	// The oldest are replayed first
+	if summary.Replayed != 2 || summary.Deferred != 1 || len(publisher.events) != 2 || publisher.events[1].ObjectID != 2 {
+		t.Errorf("expected the two oldest replayed, got %+v with %+v", summary, publisher.events)
+	}
+}
+
+func TestReconciler_RunDryRun(t *testing.T) {
+	publisher := &mockPublisher{}
+	var query reconcile.Query
+	summary, err := newTestReconciler(publisher, testActivities(), &query, 100, WithDryRun()).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !summary.DryRun || summary.Replayed != 0 || summary.Report.Missing != 3 || len(publisher.events) != 0 {
+		t.Errorf("expected a report without replays, got %+v", summary)
+	}
+}
+
+func TestReconciler_RunFindError(t *testing.T) {
+	find := func(context.Context, reconcile.Query) ([]reconcile.Activity, error) {
+		return nil, errors.New("query failed")
+	}
+	if _, err := NewReconciler(find, &mockPublisher{}, testQuery(), DefaultLookback, DefaultMaxReplays).Run(context.Background()); err == nil {
+		t.Error("expected the query error")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/listener"
+	"github.com/andy-esch/desirelines/packages/server"
+)
+
+const (
+	defaultShutdownTimeout = 10 * time.Second
+	readHeaderTimeout      = 10 * time.Second
+)
+
+func main() {
+	configFile := flag.String("config", "", "Config file of KEY=VALUE settings or a JSON object (default $CONFIG_FILE)")
+	flag.String("port", "", "Port to listen on (default $PORT or 8080)")
+	flag.String("listen", "", "Address to listen on: host:port, unix:/path/to.sock or systemd (default $LISTEN_ADDR or :port)")
+	flag.Parse()
+	if err := config.Setup(*configFile); err != nil {
+		log.Fatalf("Failed to load config file: %v", err)
+	}
+	config.BindFlags(flag.CommandLine, map[string]string{"port": "PORT", "listen": "LISTEN_ADDR"})
+
+	log.Println("Starting desirelines server...")
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	handler, err := server.New(ctx)
+	if err != nil {
+		log.Fatalf("Failed to initialize server: %v", err)
+	}
+
+	// No write timeout: the gateway's WebSocket connections are long-lived,
+	// and it bounds its other requests with REQUEST_TIMEOUT
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	// Optional TLS for serving directly rather than behind a reverse proxy
+	certFile := config.Get("TLS_CERT_FILE")
+	keyFile := config.Get("TLS_KEY_FILE")
+	if (certFile == "") != (keyFile == "") {
+		log.Fatal("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	shutdownTimeout, err := config.Duration("SHUTDOWN_TIMEOUT", defaultShutdownTimeout)
+	if err != nil {
+		log.Printf("%v, using default %s", err, defaultShutdownTimeout)
+	}
+
+	ln, err := listener.Listen(config.GetOrDefault("LISTEN_ADDR", ":"+config.GetOrDefault("PORT", "8080")))
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+
+	if certFile != "" {
+		log.Printf("Server listening on %s (HTTPS, HTTP/2 enabled), webhooks at %s, API at %s", ln.Addr(), server.WebhookPrefix, server.APIPrefix)
+	} else {
+		log.Printf("Server listening on %s, webhooks at %s, API at %s", ln.Addr(), server.WebhookPrefix, server.APIPrefix)
+	}
+	err = listener.Serve(ctx, srv, ln, certFile, keyFile, shutdownTimeout)
+	stop()
+
+	// Flush any pending webhook events only after in-flight requests are
+	// done, with a fresh deadline so a slow drain doesn't leave none for it.
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelFlush()
+	if err := handler.Close(flushCtx); err != nil {
+		log.Printf("Failed to close dispatcher: %v", err)
+	}
+	if err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+	log.Println("Server stopped")
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// correlationIDHeader carries a request's ID; the API gateway echoes it
+// back so client and server logs can be matched up.
+const correlationIDHeader = "X-Correlation-ID"
+
+// Middleware adds what both services need in front of them when they
+// share a process: every request gets a correlation ID, unless the client
+// sent one, a panic is logged and answered with a 500 instead of dropping
+// the connection, and every request is logged once it is served.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(correlationIDHeader) == "" {
+			r.Header.Set(correlationIDHeader, uuid.New().String())
+		}
+		recorder := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+
+		defer func() {
+			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				log.Printf("[%s] Panic serving %s %s: %v\n%s", r.Header.Get(correlationIDHeader), r.Method, r.URL.Path, p, debug.Stack())
+				if recorder.status == 0 && !recorder.hijacked {
+					http.Error(recorder, "Internal server error", http.StatusInternalServerError)
+				}
+			}
+			log.Printf("[%s] %s %s %d %s", r.Header.Get(correlationIDHeader), r.Method, r.URL.Path, recorder.statusCode(), time.Since(start).Round(time.Millisecond))
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status   int
+	hijacked bool
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Hijack implements http.Hijacker, which the gateway's WebSocket endpoint
+// needs; a hijacked connection is logged as 101 Switching Protocols.
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer doesn't support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		r.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Flush implements http.Flusher.
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// statusCode is the status the request was answered with.
+func (r *statusRecorder) statusCode() int {
+	switch {
+	case r.hijacked:
+		return http.StatusSwitchingProtocols
+	case r.status == 0:
+		return http.StatusOK
+	default:
+		return r.status
+	}
+}
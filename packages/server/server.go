@@ -0,0 +1,130 @@
+// Package server serves the webhook dispatcher and the API gateway from one
+// process, for running the project on a VPS instead of Cloud Functions:
+//
+//	/webhook/...  dispatcher.Handler, e.g. Strava's callback at /webhook
+//	/api/...      apigateway.Handler, e.g. /api/activities/2025/distance
+//
+// Each service sees its paths with the prefix stripped, so it behaves as
+// it does deployed on its own. The processor still runs separately,
+// subscribed to the topic the dispatcher publishes to.
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/andy-esch/desirelines/packages/apigateway"
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/dispatcher"
+)
+
+// Path prefixes the services are mounted under.
+const (
+	WebhookPrefix = "/webhook"
+	APIPrefix     = "/api"
+)
+
+// Setting scopes: WEBHOOK_IP_ALLOWLIST applies IP_ALLOWLIST to the
+// dispatcher only, API_IP_ALLOWLIST to the gateway only.
+const (
+	webhookScope = "WEBHOOK_"
+	apiScope     = "API_"
+)
+
+// scopedSettings can be set per service, since Strava's webhook addresses
+// and the dashboard's visitors rarely share an IP filter. Unscoped, they
+// apply to both services.
+var scopedSettings = []string{"IP_ALLOWLIST", "IP_DENYLIST"}
+
+// Server routes requests to the dispatcher and the API gateway.
+type Server struct {
+	handler    http.Handler
+	dispatcher *dispatcher.Handler
+}
+
+// New creates both services from the same configuration as when they are
+// deployed separately.
+func New(ctx context.Context) (*Server, error) {
+	var webhook *dispatcher.Handler
+	err := withScope(webhookScope, func() error {
+		var err error
+		webhook, err = dispatcher.NewHandler(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dispatcher: %w", err)
+	}
+
+	var api *apigateway.Handler
+	err = withScope(apiScope, func() error {
+		var err error
+		api, err = apigateway.NewHandler(ctx)
+		return err
+	})
+	if err != nil {
+		// The dispatcher's publisher is open by now
+		_ = webhook.Close(ctx)
+		return nil, fmt.Errorf("failed to create API gateway: %w", err)
+	}
+
+	return &Server{handler: Routes(webhook, api), dispatcher: webhook}, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Close flushes the dispatcher's pending messages and releases its
+// resources; call it once in-flight requests are done. ctx bounds how
+// long flushing may take.
+func (s *Server) Close(ctx context.Context) error {
+	return s.dispatcher.Close(ctx)
+}
+
+// Routes mounts webhook under WebhookPrefix and api under APIPrefix,
+// wrapped in Middleware. Other paths are 404s.
+func Routes(webhook, api http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mount(mux, WebhookPrefix, webhook)
+	mount(mux, APIPrefix, api)
+	return Middleware(mux)
+}
+
+// mount serves prefix and everything under it with h, which sees the paths
+// without prefix. The bare prefix is registered too, since ServeMux would
+// otherwise redirect it and Strava doesn't follow redirects.
+func mount(mux *http.ServeMux, prefix string, h http.Handler) {
+	stripped := stripPrefix(prefix, h)
+	mux.Handle(prefix, stripped)
+	mux.Handle(prefix+"/", stripped)
+}
+
+// stripPrefix is http.StripPrefix, except that the bare prefix becomes "/"
+// rather than an empty path.
+func stripPrefix(prefix string, h http.Handler) http.Handler {
+	return http.StripPrefix(prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/") {
+			r.URL.Path = "/" + r.URL.Path
+			if r.URL.RawPath != "" {
+				r.URL.RawPath = "/" + r.URL.RawPath
+			}
+		}
+		h.ServeHTTP(w, r)
+	}))
+}
+
+// withScope runs create with each of scopedSettings overridden by its
+// scope-prefixed setting, where one is set, and restores them after.
+func withScope(scope string, create func() error) error {
+	for _, key := range scopedSettings {
+		if value := config.Get(scope + key); value != "" {
+			config.Set(key, value)
+			// An empty override falls back to the environment and file
+			defer config.Set(key, "")
+		}
+	}
+	return create()
+}
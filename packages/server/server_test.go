@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/config"
+)
+
+// echoHandler answers with its name and the path it was given.
+func echoHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Service", name)
+		w.Header().Set("X-Path", r.URL.Path)
+		w.Header().Set(correlationIDHeader, r.Header.Get(correlationIDHeader))
+	})
+}
+
+func TestRoutes(t *testing.T) {
+	handler := Routes(echoHandler("webhook"), echoHandler("api"))
+
+	tests := []struct {
+		method, path  string
+		service, want string
+	}{
+		{http.MethodPost, "/webhook", "webhook", "/"},
+		{http.MethodGet, "/webhook?hub.mode=subscribe", "webhook", "/"},
+		{http.MethodGet, "/webhook/live", "webhook", "/live"},
+		{http.MethodGet, "/api/activities/2025/distance", "api", "/activities/2025/distance"},
+		{http.MethodGet, "/api", "api", "/"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != http.StatusOK || w.Header().Get("X-Service") != tt.service || w.Header().Get("X-Path") != tt.want {
+			t.Errorf("%s %s: expected %s to get %s, got %d from %q with %q", tt.method, tt.path, tt.service, tt.want, w.Code, w.Header().Get("X-Service"), w.Header().Get("X-Path"))
+		}
+	}
+
+	for _, path := range []string{"/", "/webhooks", "/apis/activities"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestMiddleware_CorrelationID(t *testing.T) {
+	handler := Middleware(echoHandler("api"))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Header().Get(correlationIDHeader) == "" {
+		t.Error("expected a correlation ID to be assigned")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(correlationIDHeader, "client-id")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if got := w.Header().Get(correlationIDHeader); got != "client-id" {
+		t.Errorf("expected the client's correlation ID kept, got %q", got)
+	}
+}
+
+func TestMiddleware_RecoversPanics(t *testing.T) {
+	handler := Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", w.Code)
+	}
+}
+
+// hijackableRecorder is a ResponseRecorder whose connection can be taken
+// over, as a WebSocket upgrade does.
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	server, client := net.Pipe()
+	_ = client.Close()
+	return server, nil, nil
+}
+
+func TestMiddleware_Hijack(t *testing.T) {
+	var hijacked bool
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Fatalf("Hijack failed: %v", err)
+		}
+		hijacked = true
+		_ = conn.Close()
+	}))
+	handler.ServeHTTP(hijackableRecorder{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/ws", nil))
+	if !hijacked {
+		t.Error("expected the connection to be hijacked")
+	}
+}
+
+func TestWithScope(t *testing.T) {
+	t.Setenv("IP_ALLOWLIST", "10.0.0.0/8")
+	t.Setenv("WEBHOOK_IP_ALLOWLIST", "192.168.0.0/16")
+
+	var got string
+	if err := withScope(webhookScope, func() error {
+		got = config.Get("IP_ALLOWLIST")
+		return nil
+	}); err != nil {
+		t.Fatalf("withScope failed: %v", err)
+	}
+	if got != "192.168.0.0/16" {
+		t.Errorf("expected the webhook allowlist, got %q", got)
+	}
+
+	if err := withScope(apiScope, func() error {
+		got = config.Get("IP_ALLOWLIST")
+		return nil
+	}); err != nil {
+		t.Fatalf("withScope failed: %v", err)
+	}
+	if got != "10.0.0.0/8" {
+		t.Errorf("expected the shared allowlist without an API one, got %q", got)
+	}
+	if got := config.Get("IP_ALLOWLIST"); got != "10.0.0.0/8" {
+		t.Errorf("expected the override restored, got %q", got)
+	}
+}
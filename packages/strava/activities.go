@@ -0,0 +1,97 @@
+package strava
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// PageSize is the most activities Strava returns per page.
+const PageSize = 200
+
+// Activity holds the commonly used fields of a Strava activity, present in
+// both the detailed and the summary representation.
+type Activity struct {
+	StartDate time.Time `json:"start_date"`
+	// StartDateLocal is the wall-clock start time in the activity's time
+	// zone. Strava formats it with a "Z" suffix, so it parses as UTC.
+	StartDateLocal time.Time `json:"start_date_local"`
+	Name           string    `json:"name"`
+	Type           string    `json:"type"`
+	SportType      string    `json:"sport_type"`
+	Timezone       string    `json:"timezone"`
+	ID             int64     `json:"id"`
+	// Distance is in meters.
+	Distance           float64 `json:"distance"`
+	TotalElevationGain float64 `json:"total_elevation_gain"`
+	AverageSpeed       float64 `json:"average_speed"`
+	MaxSpeed           float64 `json:"max_speed"`
+	// MovingTime and ElapsedTime are in seconds.
+	MovingTime  int  `json:"moving_time"`
+	ElapsedTime int  `json:"elapsed_time"`
+	Trainer     bool `json:"trainer"`
+	Manual      bool `json:"manual"`
+	Private     bool `json:"private"`
+}
+
+// Date is the local date the activity started on, as YYYY-MM-DD.
+func (a Activity) Date() string {
+	return a.StartDateLocal.Format(time.DateOnly)
+}
+
+// GetActivity returns the detailed activity with id. The error matches
+// ErrNotFound if Strava has no such activity, e.g. because it was deleted
+// or made private.
+func (c *Client) GetActivity(ctx context.Context, id int64) (*Activity, error) {
+	var activity Activity
+	if err := c.get(ctx, fmt.Sprintf("/activities/%d", id), nil, &activity); err != nil {
+		return nil, fmt.Errorf("activity %d: %w", id, err)
+	}
+	return &activity, nil
+}
+
+// GetActivityJSON returns the detailed activity with id as Strava sent it,
+// for passing on without losing fields Activity doesn't have.
+func (c *Client) GetActivityJSON(ctx context.Context, id int64) (json.RawMessage, error) {
+	var activity json.RawMessage
+	if err := c.get(ctx, fmt.Sprintf("/activities/%d", id), nil, &activity); err != nil {
+		return nil, fmt.Errorf("activity %d: %w", id, err)
+	}
+	return activity, nil
+}
+
+// ListActivities returns the athlete's activities that started between
+// after and before, oldest first, fetching as many pages as needed.
+func (c *Client) ListActivities(ctx context.Context, after, before time.Time) ([]Activity, error) {
+	var activities []Activity
+	for page := 1; ; page++ {
+		batch, err := c.ListActivitiesPage(ctx, after, before, page)
+		if err != nil {
+			return nil, err
+		}
+		activities = append(activities, batch...)
+		if len(batch) < PageSize {
+			return activities, nil
+		}
+	}
+}
+
+// ListActivitiesPage returns one page (counting from 1) of PageSize
+// activities that started between after and before, for callers that
+// process pages as they arrive. A short page is the last one.
+func (c *Client) ListActivitiesPage(ctx context.Context, after, before time.Time, page int) ([]Activity, error) {
+	query := url.Values{
+		"after":    {strconv.FormatInt(after.Unix(), 10)},
+		"before":   {strconv.FormatInt(before.Unix(), 10)},
+		"page":     {strconv.Itoa(page)},
+		"per_page": {strconv.Itoa(PageSize)},
+	}
+	var activities []Activity
+	if err := c.get(ctx, "/athlete/activities", query, &activities); err != nil {
+		return nil, fmt.Errorf("activities page %d: %w", page, err)
+	}
+	return activities, nil
+}
@@ -0,0 +1,72 @@
+package strava
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestClient_GetActivity(t *testing.T) {
+	server, _ := newTestServer(t, activityRoute(func(string) int { return http.StatusOK }))
+	client := newTestClient(server)
+
+	activity, err := client.GetActivity(context.Background(), 42)
+	if err != nil {
+		t.Fatalf("GetActivity failed: %v", err)
+	}
+	if activity.ID != 42 || activity.Type != "Ride" || activity.Distance != 1609.3 || activity.Date() != "2025-03-01" {
+		t.Errorf("unexpected activity %+v", activity)
+	}
+
+	raw, err := client.GetActivityJSON(context.Background(), 42)
+	if err != nil {
+		t.Fatalf("GetActivityJSON failed: %v", err)
+	}
+	if want := `{"id":42,"type":"Ride","start_date_local":"2025-03-01T08:00:00Z","distance":1609.3}`; string(raw) != want {
+		t.Errorf("GetActivityJSON = %s, want %s", raw, want)
+	}
+}
+
+func TestClient_ListActivities(t *testing.T) {
+	after := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)
+	server, _ := newTestServer(t, map[string]http.HandlerFunc{
+		// Serves PageSize+1 activities, so listing takes two pages
+		"GET /api/v3/athlete/activities": func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			if query.Get("after") != strconv.FormatInt(after.Unix(), 10) || query.Get("before") != strconv.FormatInt(before.Unix(), 10) ||
+				query.Get("per_page") != strconv.Itoa(PageSize) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			page, _ := strconv.Atoi(query.Get("page"))
+			count := PageSize
+			if page > 1 {
+				count = 1
+			}
+			_, _ = w.Write([]byte("["))
+			for i := range count {
+				if i > 0 {
+					_, _ = w.Write([]byte(","))
+				}
+				_, _ = fmt.Fprintf(w, `{"id":%d,"type":"Ride","start_date_local":"2025-01-05T08:00:00Z"}`, (page-1)*PageSize+i+1)
+			}
+			_, _ = w.Write([]byte("]"))
+		},
+	})
+	client := newTestClient(server)
+
+	activities, err := client.ListActivities(context.Background(), after, before)
+	if err != nil {
+		t.Fatalf("ListActivities failed: %v", err)
+	}
+	if len(activities) != PageSize+1 {
+		t.Fatalf("expected %d activities, got %d", PageSize+1, len(activities))
+	}
+	if last := activities[len(activities)-1].ID; last != PageSize+1 {
+		t.Errorf("expected the second page to be appended, last ID %d", last)
+	}
+}
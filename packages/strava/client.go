@@ -0,0 +1,327 @@
+// Package strava is a typed client for the Strava API v3, shared by the
+// dispatcher's enrichment, the processor and the backfill tools.
+//
+//	client := strava.New(clientID, clientSecret, refreshToken)
+//	activity, err := client.GetActivity(ctx, 12345)
+//
+// Access tokens are refreshed from the app's refresh token as needed, or
+// shared with other processes through a TokenStore (see WithTokenStore).
+// Requests are retried with exponential backoff on network errors and 5xx
+// responses, and after rate limiting (429) once Strava's current window
+// resets, as long as that is within the client's maximum wait. Every call
+// honours its context.
+package strava
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTokenURL is Strava's OAuth token endpoint.
+	DefaultTokenURL = "https://www.strava.com/oauth/token"
+	// DefaultBaseURL is the Strava API v3 root.
+	DefaultBaseURL = "https://www.strava.com/api/v3"
+
+	// DefaultTimeout bounds a single HTTP attempt when no client is supplied.
+	DefaultTimeout = 10 * time.Second
+	// DefaultMaxRetries is the number of retries after the first attempt.
+	DefaultMaxRetries = 3
+	// DefaultRetryBackoff is the delay before the first retry of a network
+	// error or 5xx response; it doubles on each subsequent retry.
+	DefaultRetryBackoff = 500 * time.Millisecond
+	// DefaultMaxRetryWait is the longest the client sleeps for a rate limit
+	// to reset before giving up and returning the 429.
+	DefaultMaxRetryWait = time.Minute
+
+	// tokenExpiryMargin refreshes access tokens a little early so a request
+	// never goes out with a token that expires in flight.
+	tokenExpiryMargin = time.Minute
+
+	// maxResponseBytes caps response bodies; activity lists of a full page
+	// are a few hundred kilobytes.
+	maxResponseBytes = 10 << 20
+)
+
+// ErrNotFound matches 404 responses, e.g. for an activity that was deleted
+// or made private.
+var ErrNotFound = errors.New("not found")
+
+// APIError is a non-success response from the Strava API.
+type APIError struct {
+	Body string
+	// RetryAfter is when a rate-limited (429) request may be sent again:
+	// the server's Retry-After hint, or the reset of the exhausted window.
+	RetryAfter time.Duration
+	StatusCode int
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("strava API returned %d: %s", e.StatusCode, e.Body)
+}
+
+// Is makes errors.Is(err, ErrNotFound) match 404 responses.
+func (e *APIError) Is(target error) bool {
+	return target == ErrNotFound && e.StatusCode == http.StatusNotFound
+}
+
+// Client calls the Strava API on behalf of one athlete. It is safe for
+// concurrent use.
+type Client struct {
+	expiresAt    time.Time
+	rateLimit    RateLimit
+	httpClient   *http.Client
+	tokenStore   TokenStore
+	now          func() time.Time
+	tokenURL     string
+	baseURL      string
+	clientSecret string
+	refreshToken string
+	accessToken  string
+	// rejectedToken is the access token Strava last answered 401 to
+	rejectedToken string
+	clientID      int
+	athleteID     int64
+	maxRetries    int
+	retryBackoff  time.Duration
+	maxRetryWait  time.Duration
+	mu            sync.Mutex
+	rateLimitMu   sync.Mutex
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient replaces the default HTTP client.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithBaseURL points the client at another API root, e.g. a test server.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithTokenURL points token refreshes at another OAuth endpoint.
+func WithTokenURL(tokenURL string) Option {
+	return func(c *Client) {
+		c.tokenURL = tokenURL
+	}
+}
+
+// WithRetries sets how many times a failed request is retried and the
+// initial backoff between attempts. maxRetries of 0 disables retries.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryBackoff = backoff
+	}
+}
+
+// WithMaxRetryWait sets the longest the client waits for a rate limit to
+// reset. Batch tools can wait out the 15 minute window; request paths
+// should rather fail fast.
+func WithMaxRetryWait(wait time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetryWait = wait
+	}
+}
+
+// New creates a client for the given app credentials. Endpoints that
+// authenticate with the app alone, such as webhook subscriptions, don't
+// need refreshToken.
+func New(clientID int, clientSecret, refreshToken string, opts ...Option) *Client {
+	c := &Client{
+		httpClient:   &http.Client{Timeout: DefaultTimeout},
+		now:          time.Now,
+		tokenURL:     DefaultTokenURL,
+		baseURL:      DefaultBaseURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		refreshToken: refreshToken,
+		maxRetries:   DefaultMaxRetries,
+		retryBackoff: DefaultRetryBackoff,
+		maxRetryWait: DefaultMaxRetryWait,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// RateLimit returns the usage Strava reported with the latest response.
+func (c *Client) RateLimit() RateLimit {
+	c.rateLimitMu.Lock()
+	defer c.rateLimitMu.Unlock()
+	return c.rateLimit
+}
+
+// request is one API call, rebuilt for every attempt.
+type request struct {
+	query  url.Values
+	form   url.Values
+	method string
+	url    string
+	// auth sends the athlete's access token; app-level endpoints pass the
+	// client credentials in query or form instead.
+	auth bool
+	// retry allows resending after failures. Creating a subscription isn't
+	// safe to repeat, as Strava may have created it before failing.
+	retry bool
+}
+
+// get decodes the JSON response for path into v.
+func (c *Client) get(ctx context.Context, path string, query url.Values, v any) error {
+	body, err := c.send(ctx, request{method: http.MethodGet, url: c.baseURL + path, query: query, auth: true, retry: true})
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to decode Strava response for %s: %w", path, err)
+	}
+	return nil
+}
+
+// send performs r, refreshing the token once if Strava rejects it and
+// retrying failures that may succeed later.
+func (c *Client) send(ctx context.Context, r request) ([]byte, error) {
+	refreshed := false
+	for retries := 0; ; {
+		body, err := c.sendOnce(ctx, r)
+		if err == nil {
+			return body, nil
+		}
+
+		var apiErr *APIError
+		isAPIErr := errors.As(err, &apiErr)
+		if r.auth && !refreshed && isAPIErr && apiErr.StatusCode == http.StatusUnauthorized {
+			// The token may have been revoked early; refresh once and retry
+			refreshed = true
+			c.invalidateToken()
+			continue
+		}
+		if !r.retry || retries >= c.maxRetries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		var delay time.Duration
+		switch {
+		case isAPIErr && apiErr.StatusCode == http.StatusTooManyRequests:
+			if apiErr.RetryAfter > c.maxRetryWait {
+				return nil, err
+			}
+			delay = apiErr.RetryAfter
+		case isAPIErr && apiErr.StatusCode < http.StatusInternalServerError:
+			return nil, err
+		default:
+			delay = c.retryBackoff << retries
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			// Waiting would only end in the context's error
+			return nil, err
+		}
+		retries++
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (c *Client) sendOnce(ctx context.Context, r request) ([]byte, error) {
+	endpoint := r.url
+	if len(r.query) > 0 {
+		endpoint += "?" + r.query.Encode()
+	}
+	var body io.Reader
+	if r.form != nil {
+		body = strings.NewReader(r.form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Strava request: %w", err)
+	}
+	if r.form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if r.auth {
+		token, err := c.token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("strava request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	limit, hasLimit := parseRateLimit(resp.Header)
+	if hasLimit {
+		c.rateLimitMu.Lock()
+		c.rateLimit = limit
+		c.rateLimitMu.Unlock()
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Strava response: %w", err)
+	}
+	// Subscription creation answers 201 and deletion 204
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			apiErr.RetryAfter = retryAfter(resp.Header, limit, c.now())
+		}
+		return nil, apiErr
+	}
+	return data, nil
+}
+
+// token returns a valid access token, refreshing it when it is missing or
+// about to expire. Concurrent callers wait for a single refresh.
+func (c *Client) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.accessToken != "" && c.now().Add(tokenExpiryMargin).Before(c.expiresAt) {
+		return c.accessToken, nil
+	}
+	if c.tokenStore != nil {
+		return c.storedToken(ctx)
+	}
+
+	token, err := c.refresh(ctx, c.refreshToken)
+	if err != nil {
+		return "", err
+	}
+	c.accessToken = token.AccessToken
+	c.expiresAt = token.ExpiresAt
+	c.refreshToken = token.RefreshToken
+	return c.accessToken, nil
+}
+
+// invalidateToken drops the cached access token after Strava rejected it,
+// remembering it so a token store holding the same one refreshes it too.
+func (c *Client) invalidateToken() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rejectedToken = c.accessToken
+	c.accessToken = ""
+}
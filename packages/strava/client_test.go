@@ -0,0 +1,236 @@
+package strava
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestServer serves the token endpoint and the given API routes, and
+// counts token refreshes.
+func newTestServer(t *testing.T, routes map[string]http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var refreshes atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("client_id") != "123" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		n := refreshes.Add(1)
+		_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","refresh_token":"rotated","expires_at":%d}`,
+			n, time.Now().Add(6*time.Hour).Unix())
+	})
+	for pattern, handler := range routes {
+		mux.HandleFunc(pattern, handler)
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &refreshes
+}
+
+func newTestClient(server *httptest.Server, opts ...Option) *Client {
+	opts = append([]Option{
+		WithTokenURL(server.URL + "/oauth/token"),
+		WithBaseURL(server.URL + "/api/v3"),
+		WithRetries(DefaultMaxRetries, time.Millisecond),
+	}, opts...)
+	return New(123, "secret", "refresh", opts...)
+}
+
+func activityRoute(status func(token string) int) map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"GET /api/v3/activities/{id}": func(w http.ResponseWriter, r *http.Request) {
+			if code := status(r.Header.Get("Authorization")); code != http.StatusOK {
+				w.WriteHeader(code)
+				_, _ = w.Write([]byte(`{"message":"error"}`))
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"id":%s,"type":"Ride","start_date_local":"2025-03-01T08:00:00Z","distance":1609.3}`, r.PathValue("id"))
+		},
+	}
+}
+
+func TestClient_ReusesToken(t *testing.T) {
+	server, refreshes := newTestServer(t, activityRoute(func(string) int { return http.StatusOK }))
+	client := newTestClient(server)
+
+	for range 2 {
+		if _, err := client.GetActivity(context.Background(), 42); err != nil {
+			t.Fatalf("GetActivity failed: %v", err)
+		}
+	}
+	if got := refreshes.Load(); got != 1 {
+		t.Errorf("expected the access token to be reused, got %d refreshes", got)
+	}
+	if client.refreshToken != "rotated" {
+		t.Errorf("expected rotated refresh token to be kept, got %q", client.refreshToken)
+	}
+}
+
+func TestClient_RefreshesRevokedToken(t *testing.T) {
+	server, refreshes := newTestServer(t, activityRoute(func(token string) int {
+		if token == "Bearer token-1" {
+			return http.StatusUnauthorized
+		}
+		return http.StatusOK
+	}))
+	client := newTestClient(server)
+
+	if _, err := client.GetActivity(context.Background(), 42); err != nil {
+		t.Fatalf("expected retry with a fresh token to succeed, got %v", err)
+	}
+	if got := refreshes.Load(); got != 2 {
+		t.Errorf("expected 2 refreshes, got %d", got)
+	}
+}
+
+func TestClient_NotFound(t *testing.T) {
+	server, _ := newTestServer(t, activityRoute(func(string) int { return http.StatusNotFound }))
+	client := newTestClient(server)
+
+	_, err := client.GetActivity(context.Background(), 42)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("expected the API error to be kept, got %v", err)
+	}
+}
+
+func TestClient_RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server, _ := newTestServer(t, activityRoute(func(string) int {
+		if calls.Add(1) < 3 {
+			return http.StatusBadGateway
+		}
+		return http.StatusOK
+	}))
+	client := newTestClient(server)
+
+	if _, err := client.GetActivity(context.Background(), 42); err != nil {
+		t.Fatalf("expected retries to succeed, got %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+
+	calls.Store(-10)
+	if _, err := newTestClient(server, WithRetries(1, time.Millisecond)).GetActivity(context.Background(), 42); err == nil {
+		t.Error("expected an error once retries are used up")
+	}
+}
+
+func TestClient_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	server, _ := newTestServer(t, activityRoute(func(string) int {
+		calls.Add(1)
+		return http.StatusForbidden
+	}))
+
+	if _, err := newTestClient(server).GetActivity(context.Background(), 42); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected a single attempt, got %d", got)
+	}
+}
+
+func TestClient_RateLimited(t *testing.T) {
+	var calls atomic.Int32
+	server, _ := newTestServer(t, map[string]http.HandlerFunc{
+		"GET /api/v3/activities/{id}": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-RateLimit-Limit", "200,2000")
+			w.Header().Set("X-RateLimit-Usage", "200,350")
+			if calls.Add(1) == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			_, _ = w.Write([]byte(`{"id":42}`))
+		},
+	})
+
+	// Waits out a reset within the maximum wait
+	client := newTestClient(server, WithMaxRetryWait(2*time.Second))
+	if _, err := client.GetActivity(context.Background(), 42); err != nil {
+		t.Fatalf("expected the request to be retried after the reset, got %v", err)
+	}
+	want := RateLimit{ShortTerm: Window{Limit: 200, Usage: 200}, Daily: Window{Limit: 2000, Usage: 350}}
+	if got := client.RateLimit(); got != want {
+		t.Errorf("RateLimit() = %+v, want %+v", got, want)
+	}
+
+	// Gives up straight away when the reset is further off
+	calls.Store(0)
+	start := time.Now()
+	_, err := newTestClient(server, WithMaxRetryWait(0)).GetActivity(context.Background(), 42)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests || apiErr.RetryAfter != time.Second {
+		t.Fatalf("expected a 429 with RetryAfter, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected no wait, took %s", elapsed)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, time.March, 1, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		header http.Header
+		name   string
+		limit  RateLimit
+		want   time.Duration
+	}{
+		{
+			name:   "retry-after header",
+			header: http.Header{"Retry-After": {"30"}},
+			want:   30 * time.Second,
+		},
+		{
+			name:  "short-term window",
+			limit: RateLimit{ShortTerm: Window{Limit: 100, Usage: 100}, Daily: Window{Limit: 1000, Usage: 400}},
+			want:  7*time.Minute + 30*time.Second,
+		},
+		{
+			name:  "daily limit",
+			limit: RateLimit{ShortTerm: Window{Limit: 100, Usage: 100}, Daily: Window{Limit: 1000, Usage: 1000}},
+			want:  13*time.Hour + 52*time.Minute + 30*time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := tt.header
+			if header == nil {
+				header = http.Header{}
+			}
+			if got := retryAfter(header, tt.limit, now); got != tt.want {
+				t.Errorf("retryAfter() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-RateLimit-Limit", "200,2000")
+	header.Set("X-RateLimit-Usage", "10,20")
+	header.Set("X-ReadRateLimit-Limit", "100, 1000")
+	header.Set("X-ReadRateLimit-Usage", "5, 15")
+
+	limit, ok := parseRateLimit(header)
+	want := RateLimit{ShortTerm: Window{Limit: 100, Usage: 5}, Daily: Window{Limit: 1000, Usage: 15}}
+	if !ok || limit != want {
+		t.Errorf("parseRateLimit() = %+v, %v; want the read limits %+v", limit, ok, want)
+	}
+
+	if _, ok := parseRateLimit(http.Header{"X-Ratelimit-Limit": {"bogus"}}); ok {
+		t.Error("expected malformed headers to be ignored")
+	}
+}
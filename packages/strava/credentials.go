@@ -0,0 +1,53 @@
+package strava
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Credentials are the Strava app's credentials and, for a single athlete
+// deployment, the athlete's refresh token, as kept in the strava_auth.json
+// secret.
+type Credentials struct {
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+	ClientID     int    `json:"client_id"`
+}
+
+// LoadCredentials reads the secrets file at path, if it exists, and falls
+// back to the STRAVA_CLIENT_ID, STRAVA_CLIENT_SECRET and
+// STRAVA_REFRESH_TOKEN settings, looked up with get, for anything it
+// doesn't set.
+func LoadCredentials(path string, get func(name string) string) (Credentials, error) {
+	var credentials Credentials
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return credentials, fmt.Errorf("failed to read Strava secrets: %w", err)
+	default:
+		if err := json.Unmarshal(data, &credentials); err != nil {
+			return credentials, fmt.Errorf("failed to parse Strava secrets %s: %w", path, err)
+		}
+	}
+
+	if credentials.ClientID == 0 {
+		if v := get("STRAVA_CLIENT_ID"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				return credentials, fmt.Errorf("invalid STRAVA_CLIENT_ID: %q", v)
+			}
+			credentials.ClientID = id
+		}
+	}
+	if credentials.ClientSecret == "" {
+		credentials.ClientSecret = get("STRAVA_CLIENT_SECRET")
+	}
+	if credentials.RefreshToken == "" {
+		credentials.RefreshToken = get("STRAVA_REFRESH_TOKEN")
+	}
+	return credentials, nil
+}
@@ -0,0 +1,47 @@
+package strava
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadCredentials(t *testing.T) {
+	settings := map[string]string{
+		"STRAVA_CLIENT_ID":     "99",
+		"STRAVA_CLIENT_SECRET": "env-secret",
+		"STRAVA_REFRESH_TOKEN": "env-refresh",
+	}
+	get := func(name string) string { return settings[name] }
+
+	path := filepath.Join(t.TempDir(), "strava_auth.json")
+	if err := os.WriteFile(path, []byte(`{"client_id": 12, "client_secret": "file-secret"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	credentials, err := LoadCredentials(path, get)
+	if err != nil {
+		t.Fatalf("LoadCredentials failed: %v", err)
+	}
+	if credentials != (Credentials{ClientID: 12, ClientSecret: "file-secret", RefreshToken: "env-refresh"}) {
+		t.Errorf("expected the file to win and the environment to fill in, got %+v", credentials)
+	}
+
+	credentials, err = LoadCredentials(filepath.Join(t.TempDir(), "missing.json"), get)
+	if err != nil {
+		t.Fatalf("LoadCredentials failed: %v", err)
+	}
+	if credentials != (Credentials{ClientID: 99, ClientSecret: "env-secret", RefreshToken: "env-refresh"}) {
+		t.Errorf("expected credentials from the environment without a file, got %+v", credentials)
+	}
+
+	settings["STRAVA_CLIENT_ID"] = "abc"
+	if _, err := LoadCredentials(filepath.Join(t.TempDir(), "missing.json"), get); err == nil {
+		t.Error("expected an error for an invalid STRAVA_CLIENT_ID")
+	}
+	if err := os.WriteFile(path, []byte(`{`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCredentials(path, get); err == nil {
+		t.Error("expected an error for an unparseable secrets file")
+	}
+}
@@ -0,0 +1,283 @@
+package strava
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// Export formats.
+const (
+	FormatGPX = "gpx"
+	FormatTCX = "tcx"
+)
+
+// ExportStreams are the streams WriteGPX and WriteTCX use.
+var ExportStreams = []StreamType{StreamTime, StreamLatLng, StreamAltitude, StreamDistance, StreamHeartrate, StreamCadence, StreamWatts, StreamTemp}
+
+var (
+	// ErrNoTime is returned for streams without the time stream, which
+	// every exported point needs.
+	ErrNoTime = errors.New("activity has no time stream")
+	// ErrNoRoute is returned by WriteGPX for an activity without a GPS
+	// track, e.g. one recorded on a trainer; WriteTCX can still export it.
+	ErrNoRoute = errors.New("activity has no GPS track")
+)
+
+// exportCreator names the exporting application in the files.
+const exportCreator = "desirelines"
+
+// WriteGPX writes the activity's track as GPX 1.1, with heart rate,
+// cadence and temperature in Garmin's TrackPointExtension. Power has no
+// GPX equivalent and is left out.
+func WriteGPX(w io.Writer, activity Activity, streams *Streams) error {
+	samples, err := exportPoints(activity, streams)
+	if err != nil {
+		return err
+	}
+	if streams.LatLng == nil || len(streams.LatLng.Data) == 0 {
+		return ErrNoRoute
+	}
+
+	type trackPointExtension struct {
+		HeartRate   *int     `xml:"gpxtpx:hr,omitempty"`
+		Cadence     *int     `xml:"gpxtpx:cad,omitempty"`
+		Temperature *float64 `xml:"gpxtpx:atemp,omitempty"`
+	}
+	type extensions struct {
+		TrackPointExtension trackPointExtension `xml:"gpxtpx:TrackPointExtension"`
+	}
+	// GPX's schema orders a point's elements as its fields are
+	type trackPoint struct {
+		Elevation  *float64    `xml:"ele,omitempty"`
+		Time       string      `xml:"time"`
+		Extensions *extensions `xml:"extensions,omitempty"`
+		Lat        float64     `xml:"lat,attr"`
+		Lon        float64     `xml:"lon,attr"`
+	}
+	var points []trackPoint
+	for _, p := range samples {
+		if p.position == nil {
+			continue
+		}
+		point := trackPoint{Lat: p.position[0], Lon: p.position[1], Elevation: p.altitude, Time: p.time.Format(time.RFC3339)}
+		if p.heartRate != nil || p.cadence != nil || p.temperature != nil {
+			point.Extensions = &extensions{trackPointExtension{HeartRate: p.heartRate, Cadence: p.cadence, Temperature: p.temperature}}
+		}
+		points = append(points, point)
+	}
+
+	type metadata struct {
+		Name string `xml:"name,omitempty"`
+		Time string `xml:"time"`
+	}
+	type segment struct {
+		Points []trackPoint `xml:"trkpt"`
+	}
+	type trk struct {
+		Name    string  `xml:"name,omitempty"`
+		Type    string  `xml:"type,omitempty"`
+		Segment segment `xml:"trkseg"`
+	}
+	doc := struct {
+		XMLName  xml.Name `xml:"gpx"`
+		Version  string   `xml:"version,attr"`
+		Creator  string   `xml:"creator,attr"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Gpxtpx   string   `xml:"xmlns:gpxtpx,attr"`
+		Metadata metadata `xml:"metadata"`
+		Track    trk      `xml:"trk"`
+	}{
+		Version:  "1.1",
+		Creator:  exportCreator,
+		Xmlns:    "http://www.topografix.com/GPX/1/1",
+		Gpxtpx:   "http://www.garmin.com/xmlschemas/TrackPointExtension/v1",
+		Metadata: metadata{Name: activity.Name, Time: activity.StartDate.UTC().Format(time.RFC3339)},
+		Track:    trk{Name: activity.Name, Type: activityType(activity), Segment: segment{Points: points}},
+	}
+	return writeXML(w, doc)
+}
+
+// WriteTCX writes the activity as a single-lap Garmin Training Center
+// (TCX) activity, with power in Garmin's ActivityExtension. Points without
+// a position, e.g. from a trainer, are kept without one.
+func WriteTCX(w io.Writer, activity Activity, streams *Streams) error {
+	samples, err := exportPoints(activity, streams)
+	if err != nil {
+		return err
+	}
+
+	type position struct {
+		Lat float64 `xml:"LatitudeDegrees"`
+		Lon float64 `xml:"LongitudeDegrees"`
+	}
+	type heartRate struct {
+		Value int `xml:"Value"`
+	}
+	type tpx struct {
+		Watts *float64 `xml:"ns3:Watts,omitempty"`
+	}
+	type extensions struct {
+		TPX tpx `xml:"ns3:TPX"`
+	}
+	// TCX's schema orders elements as the fields are
+	type trackPoint struct {
+		Time       string      `xml:"Time"`
+		Position   *position   `xml:"Position,omitempty"`
+		Altitude   *float64    `xml:"AltitudeMeters,omitempty"`
+		Distance   *float64    `xml:"DistanceMeters,omitempty"`
+		HeartRate  *heartRate  `xml:"HeartRateBpm,omitempty"`
+		Cadence    *int        `xml:"Cadence,omitempty"`
+		Extensions *extensions `xml:"Extensions,omitempty"`
+	}
+	points := make([]trackPoint, len(samples))
+	for i, p := range samples {
+		point := trackPoint{Time: p.time.Format(time.RFC3339), Altitude: p.altitude, Distance: p.distance, Cadence: p.cadence}
+		if p.position != nil {
+			point.Position = &position{Lat: p.position[0], Lon: p.position[1]}
+		}
+		if p.heartRate != nil {
+			point.HeartRate = &heartRate{Value: *p.heartRate}
+		}
+		if p.watts != nil {
+			point.Extensions = &extensions{tpx{Watts: p.watts}}
+		}
+		points[i] = point
+	}
+
+	start := activity.StartDate.UTC().Format(time.RFC3339)
+	type track struct {
+		Points []trackPoint `xml:"Trackpoint"`
+	}
+	type lap struct {
+		StartTime     string  `xml:"StartTime,attr"`
+		TotalTime     int     `xml:"TotalTimeSeconds"`
+		Distance      float64 `xml:"DistanceMeters"`
+		Calories      int     `xml:"Calories"`
+		Intensity     string  `xml:"Intensity"`
+		TriggerMethod string  `xml:"TriggerMethod"`
+		Track         track   `xml:"Track"`
+	}
+	type tcxActivity struct {
+		Sport string `xml:"Sport,attr"`
+		ID    string `xml:"Id"`
+		Lap   lap    `xml:"Lap"`
+		Notes string `xml:"Notes,omitempty"`
+	}
+	doc := struct {
+		XMLName    xml.Name `xml:"TrainingCenterDatabase"`
+		Xmlns      string   `xml:"xmlns,attr"`
+		Ns3        string   `xml:"xmlns:ns3,attr"`
+		Activities struct {
+			Activity tcxActivity `xml:"Activity"`
+		}
+	}{
+		Xmlns: "http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2",
+		Ns3:   "http://www.garmin.com/xmlschemas/ActivityExtension/v2",
+	}
+	doc.Activities.Activity = tcxActivity{
+		Sport: tcxSport(activity),
+		ID:    start,
+		Lap: lap{
+			StartTime:     start,
+			TotalTime:     activity.ElapsedTime,
+			Distance:      activity.Distance,
+			Intensity:     "Active",
+			TriggerMethod: "Manual",
+			Track:         track{Points: points},
+		},
+		Notes: activity.Name,
+	}
+	return writeXML(w, doc)
+}
+
+// exportPoint is one sample of an activity's streams.
+type exportPoint struct {
+	time        time.Time
+	position    *[2]float64
+	altitude    *float64
+	distance    *float64
+	heartRate   *int
+	cadence     *int
+	watts       *float64
+	temperature *float64
+}
+
+// exportPoints zips the streams into points, timed from the activity's
+// start. Streams shorter than the time stream leave the later points
+// without their value.
+func exportPoints(activity Activity, streams *Streams) ([]exportPoint, error) {
+	if streams == nil || streams.Time == nil || len(streams.Time.Data) == 0 {
+		return nil, ErrNoTime
+	}
+	start := activity.StartDate.UTC()
+	points := make([]exportPoint, len(streams.Time.Data))
+	for i, offset := range streams.Time.Data {
+		points[i] = exportPoint{
+			time:        start.Add(time.Duration(offset) * time.Second),
+			position:    sample(streams.LatLng, i),
+			altitude:    sample(streams.Altitude, i),
+			distance:    sample(streams.Distance, i),
+			heartRate:   rounded(sample(streams.Heartrate, i)),
+			cadence:     rounded(sample(streams.Cadence, i)),
+			watts:       sample(streams.Watts, i),
+			temperature: sample(streams.Temp, i),
+		}
+	}
+	return points, nil
+}
+
+// sample returns the stream's i-th sample, or nil if it has none.
+func sample[T any](stream *Stream[T], i int) *T {
+	if stream == nil || i >= len(stream.Data) {
+		return nil
+	}
+	return &stream.Data[i]
+}
+
+// rounded returns v rounded to a whole number, for the integer fields.
+func rounded(v *float64) *int {
+	if v == nil {
+		return nil
+	}
+	n := int(math.Round(*v))
+	return &n
+}
+
+// activityType is the activity's sport type, or its type for activities
+// from before Strava had sport types.
+func activityType(activity Activity) string {
+	if activity.SportType != "" {
+		return activity.SportType
+	}
+	return activity.Type
+}
+
+// tcxSport maps the activity's type to one of TCX's three sports.
+func tcxSport(activity Activity) string {
+	sport := activityType(activity)
+	switch {
+	case strings.HasSuffix(sport, "Ride"):
+		return "Biking"
+	case strings.HasSuffix(sport, "Run"):
+		return "Running"
+	default:
+		return "Other"
+	}
+}
+
+func writeXML(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode export: %w", err)
+	}
+	return encoder.Close()
+}
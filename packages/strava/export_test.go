@@ -0,0 +1,115 @@
+package strava
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testExport() (Activity, *Streams) {
+	activity := Activity{
+		ID:          42,
+		Name:        "Morning Ride",
+		Type:        "Ride",
+		SportType:   "GravelRide",
+		StartDate:   time.Date(2025, time.March, 1, 13, 0, 0, 0, time.UTC),
+		Distance:    2000,
+		ElapsedTime: 600,
+	}
+	streams := &Streams{
+		Time:      &Stream[int]{Data: []int{0, 300, 600}},
+		LatLng:    &Stream[[2]float64]{Data: [][2]float64{{40.1, -75.2}, {40.2, -75.3}, {40.3, -75.4}}},
+		Altitude:  &Stream[float64]{Data: []float64{100, 110.5, 105}},
+		Distance:  &Stream[float64]{Data: []float64{0, 1000, 2000}},
+		Heartrate: &Stream[float64]{Data: []float64{120, 140.6}},
+		Watts:     &Stream[float64]{Data: []float64{180, 210, 0}},
+	}
+	return activity, streams
+}
+
+func TestWriteGPX(t *testing.T) {
+	activity, streams := testExport()
+	var buf bytes.Buffer
+	if err := WriteGPX(&buf, activity, streams); err != nil {
+		t.Fatalf("WriteGPX failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<gpx version="1.1" creator="desirelines" xmlns="http://www.topografix.com/GPX/1/1"`,
+		`<type>GravelRide</type>`,
+		`<trkpt lat="40.2" lon="-75.3">`,
+		`<ele>110.5</ele>`,
+		`<time>2025-03-01T13:05:00Z</time>`,
+		`<gpxtpx:hr>141</gpxtpx:hr>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "<trkpt") != 3 || strings.Count(out, "<gpxtpx:hr>") != 2 || strings.Contains(out, "180") {
+		t.Errorf("expected three points, heart rate on two and no power, got\n%s", out)
+	}
+	if err := xml.Unmarshal(buf.Bytes(), new(struct{})); err != nil {
+		t.Errorf("expected well-formed XML, got %v", err)
+	}
+}
+
+func TestWriteTCX(t *testing.T) {
+	activity, streams := testExport()
+	streams.LatLng = nil
+	var buf bytes.Buffer
+	if err := WriteTCX(&buf, activity, streams); err != nil {
+		t.Fatalf("WriteTCX failed: %v", err)
+	}
+	var doc struct {
+		Activities struct {
+			Activity struct {
+				Sport string `xml:"Sport,attr"`
+				ID    string `xml:"Id"`
+				Lap   struct {
+					TotalTime  int     `xml:"TotalTimeSeconds"`
+					Distance   float64 `xml:"DistanceMeters"`
+					Trackpoint []struct {
+						Time      string    `xml:"Time"`
+						Position  *struct{} `xml:"Position"`
+						Distance  float64   `xml:"DistanceMeters"`
+						HeartRate *int      `xml:"HeartRateBpm>Value"`
+						Watts     *float64  `xml:"Extensions>TPX>Watts"`
+					} `xml:"Track>Trackpoint"`
+				} `xml:"Lap"`
+				Notes string `xml:"Notes"`
+			} `xml:"Activity"`
+		} `xml:"Activities"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid TCX: %v\n%s", err, buf.String())
+	}
+	a := doc.Activities.Activity
+	if a.Sport != "Biking" || a.ID != "2025-03-01T13:00:00Z" || a.Notes != "Morning Ride" || a.Lap.TotalTime != 600 || a.Lap.Distance != 2000 {
+		t.Errorf("unexpected activity %+v", a)
+	}
+	points := a.Lap.Trackpoint
+	if len(points) != 3 || points[2].Time != "2025-03-01T13:10:00Z" || points[1].Distance != 1000 || points[0].Position != nil {
+		t.Fatalf("unexpected points %+v", points)
+	}
+	if points[1].HeartRate == nil || *points[1].HeartRate != 141 || points[2].HeartRate != nil || points[1].Watts == nil || *points[1].Watts != 210 {
+		t.Errorf("unexpected sensor data %+v", points)
+	}
+}
+
+func TestExport_Errors(t *testing.T) {
+	activity, streams := testExport()
+	streams.LatLng = nil
+	if err := WriteGPX(new(bytes.Buffer), activity, streams); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("expected ErrNoRoute without a track, got %v", err)
+	}
+	if err := WriteTCX(new(bytes.Buffer), activity, &Streams{}); !errors.Is(err, ErrNoTime) {
+		t.Errorf("expected ErrNoTime without a time stream, got %v", err)
+	}
+	if got := tcxSport(Activity{Type: "Walk"}); got != "Other" {
+		t.Errorf("expected Other for a walk, got %q", got)
+	}
+}
@@ -0,0 +1,103 @@
+package strava
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultAuthorizeURL is Strava's OAuth authorization page.
+	DefaultAuthorizeURL = "https://www.strava.com/oauth/authorize"
+	// DefaultScope is the access the pipeline needs. Without
+	// activity:read_all Strava sends no webhook events for private
+	// activities.
+	DefaultScope = "read,activity:read_all"
+)
+
+// AuthorizedAthlete is the athlete who authorized the app.
+type AuthorizedAthlete struct {
+	Firstname string `json:"firstname"`
+	Lastname  string `json:"lastname"`
+	ID        int64  `json:"id"`
+}
+
+// Name returns the athlete's full name.
+func (a AuthorizedAthlete) Name() string {
+	return strings.TrimSpace(a.Firstname + " " + a.Lastname)
+}
+
+// Authorization is the result of exchanging an authorization code.
+type Authorization struct {
+	Athlete AuthorizedAthlete
+	Token   Token
+}
+
+// AuthorizeURL returns Strava's page asking an athlete to grant the app
+// scope. Strava sends them back to redirectURI with a code for
+// ExchangeCode, the scope granted and state, which the app should check is
+// the one it sent.
+func AuthorizeURL(clientID int, redirectURI, scope, state string) string {
+	query := url.Values{
+		"client_id":       {strconv.Itoa(clientID)},
+		"redirect_uri":    {redirectURI},
+		"response_type":   {"code"},
+		"approval_prompt": {"auto"},
+		"scope":           {scope},
+		"state":           {state},
+	}
+	return DefaultAuthorizeURL + "?" + query.Encode()
+}
+
+// ExchangeCode exchanges the code Strava redirected an athlete back with
+// for their tokens. Codes can only be used once, so the request isn't
+// retried.
+func (c *Client) ExchangeCode(ctx context.Context, code string) (*Authorization, error) {
+	form := c.appCredentials()
+	form.Set("code", code)
+	form.Set("grant_type", "authorization_code")
+	body, err := c.send(ctx, request{method: http.MethodPost, url: c.tokenURL, form: form})
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange Strava authorization code: %w", err)
+	}
+
+	var response struct {
+		AccessToken  string            `json:"access_token"`
+		RefreshToken string            `json:"refresh_token"`
+		Athlete      AuthorizedAthlete `json:"athlete"`
+		ExpiresAt    int64             `json:"expires_at"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode Strava token response: %w", err)
+	}
+	if response.AccessToken == "" || response.RefreshToken == "" || response.Athlete.ID == 0 {
+		return nil, errors.New("strava token response lacks the tokens or the athlete")
+	}
+
+	return &Authorization{
+		Athlete: response.Athlete,
+		Token: Token{
+			ExpiresAt:    time.Unix(response.ExpiresAt, 0),
+			AccessToken:  response.AccessToken,
+			RefreshToken: response.RefreshToken,
+			AthleteID:    response.Athlete.ID,
+		},
+	}, nil
+}
+
+// HasScope reports whether the comma-separated scopes Strava granted, as
+// sent to the redirect URI, include want.
+func HasScope(granted, want string) bool {
+	for _, scope := range strings.Split(granted, ",") {
+		if strings.TrimSpace(scope) == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package strava
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAuthorizeURL(t *testing.T) {
+	parsed, err := url.Parse(AuthorizeURL(123, "https://example.com/oauth/callback", DefaultScope, "state-1"))
+	if err != nil {
+		t.Fatalf("invalid URL: %v", err)
+	}
+	query := parsed.Query()
+	if parsed.Host != "www.strava.com" || query.Get("client_id") != "123" || query.Get("redirect_uri") != "https://example.com/oauth/callback" ||
+		query.Get("scope") != DefaultScope || query.Get("state") != "state-1" || query.Get("response_type") != "code" {
+		t.Errorf("unexpected authorize URL %s", parsed)
+	}
+}
+
+func TestClient_ExchangeCode(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "authorization_code" || r.Form.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Form.Get("code") != "good" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"message":"Bad Request"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"access","refresh_token":"refresh","expires_at":1700000000,
+			"athlete":{"id":42,"firstname":"Ada","lastname":"Lovelace"}}`))
+	}))
+	t.Cleanup(server.Close)
+	client := New(123, "secret", "", WithTokenURL(server.URL))
+
+	authorization, err := client.ExchangeCode(context.Background(), "good")
+	if err != nil {
+		t.Fatalf("ExchangeCode failed: %v", err)
+	}
+	if authorization.Token.AthleteID != 42 || authorization.Token.RefreshToken != "refresh" || authorization.Token.ExpiresAt.Unix() != 1700000000 {
+		t.Errorf("unexpected token %+v", authorization.Token)
+	}
+	if name := authorization.Athlete.Name(); name != "Ada Lovelace" {
+		t.Errorf("unexpected name %q", name)
+	}
+
+	calls.Store(0)
+	if _, err := client.ExchangeCode(context.Background(), "used"); err == nil || calls.Load() != 1 {
+		t.Errorf("expected a single failed exchange, got %v after %d calls", err, calls.Load())
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	if !HasScope("read,activity:read_all", "activity:read_all") {
+		t.Error("expected the scope to be found")
+	}
+	if HasScope("read,activity:read", "activity:read_all") {
+		t.Error("expected activity:read not to grant activity:read_all")
+	}
+}
@@ -0,0 +1,80 @@
+package strava
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shortTermWindow is the length of Strava's short-term rate limit window,
+// which resets on the quarter hour.
+const shortTermWindow = 15 * time.Minute
+
+// Window is the usage of one rate limit window.
+type Window struct {
+	Limit int `json:"limit"`
+	Usage int `json:"usage"`
+}
+
+// Exhausted reports whether no requests are left in the window.
+func (w Window) Exhausted() bool {
+	return w.Limit > 0 && w.Usage >= w.Limit
+}
+
+// RateLimit is the API usage Strava reports with each response. Strava
+// counts reads against both the overall and the lower read limits, so the
+// read limits are used when present.
+type RateLimit struct {
+	// ShortTerm resets every 15 minutes, on the quarter hour.
+	ShortTerm Window `json:"short_term"`
+	// Daily resets at midnight UTC.
+	Daily Window `json:"daily"`
+}
+
+// parseRateLimit reads the "short,daily" limit and usage headers, reporting
+// false if the response has none.
+func parseRateLimit(header http.Header) (RateLimit, bool) {
+	for _, prefix := range []string{"X-ReadRateLimit-", "X-RateLimit-"} {
+		limits, okLimit := parsePair(header.Get(prefix + "Limit"))
+		usage, okUsage := parsePair(header.Get(prefix + "Usage"))
+		if okLimit && okUsage {
+			return RateLimit{
+				ShortTerm: Window{Limit: limits[0], Usage: usage[0]},
+				Daily:     Window{Limit: limits[1], Usage: usage[1]},
+			}, true
+		}
+	}
+	return RateLimit{}, false
+}
+
+func parsePair(value string) ([2]int, bool) {
+	var pair [2]int
+	short, daily, ok := strings.Cut(value, ",")
+	if !ok {
+		return pair, false
+	}
+	var err error
+	if pair[0], err = strconv.Atoi(strings.TrimSpace(short)); err != nil {
+		return pair, false
+	}
+	if pair[1], err = strconv.Atoi(strings.TrimSpace(daily)); err != nil {
+		return pair, false
+	}
+	return pair, true
+}
+
+// retryAfter is how long a rate-limited request should wait: the server's
+// Retry-After hint if it sent one, midnight UTC once the daily limit is
+// used up, and otherwise the next quarter hour.
+func retryAfter(header http.Header, limit RateLimit, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	now = now.UTC()
+	if limit.Daily.Exhausted() {
+		midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		return midnight.Sub(now)
+	}
+	return now.Truncate(shortTermWindow).Add(shortTermWindow).Sub(now)
+}
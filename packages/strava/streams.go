@@ -0,0 +1,91 @@
+package strava
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// StreamType names one of an activity's sampled data streams.
+type StreamType string
+
+// Stream types.
+const (
+	StreamTime           StreamType = "time"
+	StreamDistance       StreamType = "distance"
+	StreamLatLng         StreamType = "latlng"
+	StreamAltitude       StreamType = "altitude"
+	StreamVelocitySmooth StreamType = "velocity_smooth"
+	StreamHeartrate      StreamType = "heartrate"
+	StreamCadence        StreamType = "cadence"
+	StreamWatts          StreamType = "watts"
+	StreamTemp           StreamType = "temp"
+	StreamMoving         StreamType = "moving"
+	StreamGradeSmooth    StreamType = "grade_smooth"
+)
+
+// AllStreams are the stream types GetStreams requests when given none.
+var AllStreams = []StreamType{
+	StreamTime, StreamDistance, StreamLatLng, StreamAltitude, StreamVelocitySmooth,
+	StreamHeartrate, StreamCadence, StreamWatts, StreamTemp, StreamMoving, StreamGradeSmooth,
+}
+
+// Stream is one data stream, sampled at the same points as the activity's
+// other streams.
+type Stream[T any] struct {
+	// SeriesType is the stream the samples are indexed by, "time" or
+	// "distance".
+	SeriesType   string `json:"series_type"`
+	Resolution   string `json:"resolution"`
+	Data         []T    `json:"data"`
+	OriginalSize int    `json:"original_size"`
+}
+
+// Streams are an activity's data streams. Streams the activity wasn't
+// recorded with, e.g. heartrate without a monitor, are nil.
+type Streams struct {
+	// Time is seconds since the start.
+	Time *Stream[int] `json:"time,omitempty"`
+	// Distance is meters since the start.
+	Distance *Stream[float64] `json:"distance,omitempty"`
+	// LatLng is [latitude, longitude] pairs in degrees.
+	LatLng *Stream[[2]float64] `json:"latlng,omitempty"`
+	// Altitude is meters.
+	Altitude *Stream[float64] `json:"altitude,omitempty"`
+	// VelocitySmooth is meters per second.
+	VelocitySmooth *Stream[float64] `json:"velocity_smooth,omitempty"`
+	// Heartrate is beats per minute.
+	Heartrate *Stream[float64] `json:"heartrate,omitempty"`
+	// Cadence is revolutions (or steps) per minute.
+	Cadence *Stream[float64] `json:"cadence,omitempty"`
+	// Watts is power output.
+	Watts *Stream[float64] `json:"watts,omitempty"`
+	// Temp is degrees Celsius.
+	Temp        *Stream[float64] `json:"temp,omitempty"`
+	Moving      *Stream[bool]    `json:"moving,omitempty"`
+	GradeSmooth *Stream[float64] `json:"grade_smooth,omitempty"`
+}
+
+// GetStreams returns the activity's streams of the given types, or of all
+// types if none are given. The error matches ErrNotFound if Strava has no
+// such activity.
+func (c *Client) GetStreams(ctx context.Context, id int64, types ...StreamType) (*Streams, error) {
+	if len(types) == 0 {
+		types = AllStreams
+	}
+	keys := make([]string, len(types))
+	for i, t := range types {
+		keys[i] = string(t)
+	}
+	query := url.Values{
+		"keys":        {strings.Join(keys, ",")},
+		"key_by_type": {"true"},
+	}
+
+	var streams Streams
+	if err := c.get(ctx, fmt.Sprintf("/activities/%d/streams", id), query, &streams); err != nil {
+		return nil, fmt.Errorf("streams of activity %d: %w", id, err)
+	}
+	return &streams, nil
+}
@@ -0,0 +1,46 @@
+package strava
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestClient_GetStreams(t *testing.T) {
+	server, _ := newTestServer(t, map[string]http.HandlerFunc{
+		"GET /api/v3/activities/{id}/streams": func(w http.ResponseWriter, r *http.Request) {
+			if r.PathValue("id") != "42" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.URL.Query().Get("key_by_type") != "true" || r.URL.Query().Get("keys") != "time,latlng" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{
+				"time": {"data": [0, 5, 10], "series_type": "distance", "original_size": 3, "resolution": "high"},
+				"latlng": {"data": [[40.1, -75.2], [40.2, -75.3], [40.3, -75.4]], "series_type": "distance", "original_size": 3, "resolution": "high"}
+			}`))
+		},
+	})
+	client := newTestClient(server)
+
+	streams, err := client.GetStreams(context.Background(), 42, StreamTime, StreamLatLng)
+	if err != nil {
+		t.Fatalf("GetStreams failed: %v", err)
+	}
+	if streams.Time == nil || len(streams.Time.Data) != 3 || streams.Time.Data[2] != 10 {
+		t.Errorf("unexpected time stream %+v", streams.Time)
+	}
+	if streams.LatLng == nil || streams.LatLng.Data[1] != [2]float64{40.2, -75.3} || streams.LatLng.SeriesType != "distance" {
+		t.Errorf("unexpected latlng stream %+v", streams.LatLng)
+	}
+	if streams.Heartrate != nil {
+		t.Errorf("expected streams that weren't sent to be nil, got %+v", streams.Heartrate)
+	}
+
+	if _, err := client.GetStreams(context.Background(), 7); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
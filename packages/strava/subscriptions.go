@@ -0,0 +1,73 @@
+package strava
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Subscription is a webhook push subscription of the app.
+type Subscription struct {
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	CallbackURL   string    `json:"callback_url"`
+	ID            int       `json:"id"`
+	ApplicationID int       `json:"application_id"`
+}
+
+// CreateSubscription subscribes callbackURL to the app's webhook events
+// and returns the new subscription's ID. Strava verifies the callback
+// before answering, so it must already be served with verifyToken. The
+// request isn't retried.
+func (c *Client) CreateSubscription(ctx context.Context, callbackURL, verifyToken string) (int, error) {
+	form := c.appCredentials()
+	form.Set("callback_url", callbackURL)
+	form.Set("verify_token", verifyToken)
+
+	body, err := c.send(ctx, request{method: http.MethodPost, url: c.baseURL + "/push_subscriptions", form: form})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create subscription: %w", err)
+	}
+	var created struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil {
+		return 0, fmt.Errorf("failed to decode subscription response: %w", err)
+	}
+	return created.ID, nil
+}
+
+// ListSubscriptions returns the app's subscriptions; Strava allows one.
+func (c *Client) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	body, err := c.send(ctx, request{method: http.MethodGet, url: c.baseURL + "/push_subscriptions", query: c.appCredentials(), retry: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	var subscriptions []Subscription
+	if err := json.Unmarshal(body, &subscriptions); err != nil {
+		return nil, fmt.Errorf("failed to decode subscriptions: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// DeleteSubscription deletes the subscription with id.
+func (c *Client) DeleteSubscription(ctx context.Context, id int) error {
+	endpoint := fmt.Sprintf("%s/push_subscriptions/%d", c.baseURL, id)
+	if _, err := c.send(ctx, request{method: http.MethodDelete, url: endpoint, query: c.appCredentials(), retry: true}); err != nil {
+		return fmt.Errorf("failed to delete subscription %d: %w", id, err)
+	}
+	return nil
+}
+
+// appCredentials returns the client_id and client_secret parameters the
+// subscription endpoints authenticate with instead of an access token.
+func (c *Client) appCredentials() url.Values {
+	return url.Values{
+		"client_id":     {strconv.Itoa(c.clientID)},
+		"client_secret": {c.clientSecret},
+	}
+}
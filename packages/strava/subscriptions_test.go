@@ -0,0 +1,61 @@
+package strava
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Subscriptions(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v3/push_subscriptions", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("client_id") != "123" || r.Form.Get("client_secret") != "secret" ||
+			r.Form.Get("callback_url") != "https://example.com/webhook" || r.Form.Get("verify_token") != "verify" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":777}`))
+	})
+	mux.HandleFunc("GET /api/v3/push_subscriptions", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"id":777,"application_id":123,"callback_url":"https://example.com/webhook","created_at":"2024-06-01T12:00:00Z","updated_at":"2024-06-01T12:00:00Z"}]`))
+	})
+	mux.HandleFunc("DELETE /api/v3/push_subscriptions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "777" || r.URL.Query().Get("client_id") != "123" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	id, err := client.CreateSubscription(ctx, "https://example.com/webhook", "verify")
+	if err != nil || id != 777 {
+		t.Fatalf("CreateSubscription = %d, %v; want 777", id, err)
+	}
+
+	subscriptions, err := client.ListSubscriptions(ctx)
+	if err != nil {
+		t.Fatalf("ListSubscriptions failed: %v", err)
+	}
+	if len(subscriptions) != 1 || subscriptions[0].ID != 777 || subscriptions[0].CallbackURL != "https://example.com/webhook" {
+		t.Errorf("unexpected subscriptions %+v", subscriptions)
+	}
+
+	if err := client.DeleteSubscription(ctx, 777); err != nil {
+		t.Errorf("DeleteSubscription failed: %v", err)
+	}
+	var apiErr *APIError
+	if err := client.DeleteSubscription(ctx, 1); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a 404 API error deleting an unknown subscription, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package strava
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Token is an athlete's OAuth credentials as returned by Strava's token
+// endpoint.
+type Token struct {
+	ExpiresAt    time.Time `json:"expires_at"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	AthleteID    int64     `json:"athlete_id"`
+}
+
+// Valid reports whether the access token can still be sent at now, with a
+// margin so it doesn't expire in flight.
+func (t *Token) Valid(now time.Time) bool {
+	return t.AccessToken != "" && now.Add(tokenExpiryMargin).Before(t.ExpiresAt)
+}
+
+// TokenStore persists athletes' tokens so clients in different processes
+// share them. Strava rotates refresh tokens, so clients refreshing
+// independently from a copy of the same token would invalidate each
+// other's.
+type TokenStore interface {
+	// UpdateToken calls update with the athlete's stored token, nil if
+	// there is none, and stores the token it returns unless that is current
+	// itself. Updates of the same athlete's token are serialised, also
+	// across processes; stores may call update again with the newer token
+	// when another writer got in first. It returns the token that is
+	// stored afterwards.
+	UpdateToken(ctx context.Context, athleteID int64, update func(current *Token) (*Token, error)) (*Token, error)
+}
+
+// WithTokenStore keeps the athlete's tokens in store rather than only in
+// memory. The client uses the stored access token while it is valid and
+// refreshes it through the store otherwise, so one client refreshes for
+// everyone sharing the store. The refresh token passed to New is only used
+// while the store has none for the athlete.
+func WithTokenStore(store TokenStore, athleteID int64) Option {
+	return func(c *Client) {
+		c.tokenStore = store
+		c.athleteID = athleteID
+	}
+}
+
+// storedToken returns the access token from the token store, refreshing
+// it there if it has expired or Strava rejected it. Must be called with
+// c.mu held.
+func (c *Client) storedToken(ctx context.Context) (string, error) {
+	rejected := c.rejectedToken
+	token, err := c.tokenStore.UpdateToken(ctx, c.athleteID, func(current *Token) (*Token, error) {
+		if current != nil && current.AccessToken != rejected && current.Valid(c.now()) {
+			// Still valid, or already refreshed by another client
+			return current, nil
+		}
+		refreshToken := c.refreshToken
+		if current != nil && current.RefreshToken != "" {
+			refreshToken = current.RefreshToken
+		}
+		if refreshToken == "" {
+			return nil, fmt.Errorf("no refresh token stored for athlete %d", c.athleteID)
+		}
+		return c.refresh(ctx, refreshToken)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to update stored Strava token: %w", err)
+	}
+
+	c.accessToken = token.AccessToken
+	c.expiresAt = token.ExpiresAt
+	c.refreshToken = token.RefreshToken
+	c.rejectedToken = ""
+	return c.accessToken, nil
+}
+
+// RefreshStoredToken refreshes the athlete's token in the token store if it
+// expires within window, so clients find a valid one rather than
+// refreshing on the way to Strava. It returns the stored token and whether
+// this call refreshed it. The client needs WithTokenStore.
+func (c *Client) RefreshStoredToken(ctx context.Context, window time.Duration) (*Token, bool, error) {
+	if c.tokenStore == nil {
+		return nil, false, errors.New("client has no token store")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	refreshed := false
+	token, err := c.tokenStore.UpdateToken(ctx, c.athleteID, func(current *Token) (*Token, error) {
+		refreshed = false
+		if current != nil && current.Valid(c.now().Add(window)) {
+			return current, nil
+		}
+		refreshToken := c.refreshToken
+		if current != nil && current.RefreshToken != "" {
+			refreshToken = current.RefreshToken
+		}
+		if refreshToken == "" {
+			return nil, fmt.Errorf("no refresh token stored for athlete %d", c.athleteID)
+		}
+		refreshed = true
+		return c.refresh(ctx, refreshToken)
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to refresh stored Strava token: %w", err)
+	}
+
+	c.accessToken = token.AccessToken
+	c.expiresAt = token.ExpiresAt
+	c.refreshToken = token.RefreshToken
+	c.rejectedToken = ""
+	return token, refreshed, nil
+}
+
+// refresh exchanges refreshToken for a new access token.
+func (c *Client) refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	form := url.Values{
+		"client_id":     {strconv.Itoa(c.clientID)},
+		"client_secret": {c.clientSecret},
+		"refresh_token": {refreshToken},
+		"grant_type":    {"refresh_token"},
+	}
+	body, err := c.send(ctx, request{method: http.MethodPost, url: c.tokenURL, form: form, retry: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh Strava token: %w", err)
+	}
+
+	var tokens struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresAt    int64  `json:"expires_at"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to decode Strava token response: %w", err)
+	}
+	if tokens.AccessToken == "" {
+		return nil, errors.New("strava token response has no access_token")
+	}
+
+	token := &Token{
+		ExpiresAt:    time.Unix(tokens.ExpiresAt, 0),
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		AthleteID:    c.athleteID,
+	}
+	// Keep the old refresh token if the response has none
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return token, nil
+}
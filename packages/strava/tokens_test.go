@@ -0,0 +1,121 @@
+package strava
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryTokenStore is a TokenStore serialising updates with a mutex.
+type memoryTokenStore struct {
+	tokens map[int64]*Token
+	writes int
+	mu     sync.Mutex
+}
+
+func (s *memoryTokenStore) UpdateToken(ctx context.Context, athleteID int64, update func(*Token) (*Token, error)) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.tokens[athleteID]
+	next, err := update(current)
+	if err != nil {
+		return nil, err
+	}
+	if next != current {
+		s.tokens[athleteID] = next
+		s.writes++
+	}
+	return next, nil
+}
+
+func TestClient_TokenStore(t *testing.T) {
+	server, refreshes := newTestServer(t, activityRoute(func(token string) int {
+		if token == "Bearer revoked" {
+			return http.StatusUnauthorized
+		}
+		return http.StatusOK
+	}))
+	store := &memoryTokenStore{tokens: map[int64]*Token{
+		7: {AthleteID: 7, AccessToken: "stored", RefreshToken: "stored-refresh", ExpiresAt: time.Now().Add(time.Hour)},
+	}}
+
+	// A valid stored token is used as is
+	if _, err := newTestClient(server, WithTokenStore(store, 7)).GetActivity(context.Background(), 42); err != nil {
+		t.Fatalf("GetActivity failed: %v", err)
+	}
+	if got := refreshes.Load(); got != 0 || store.writes != 0 {
+		t.Errorf("expected the stored token to be used, got %d refreshes and %d writes", got, store.writes)
+	}
+
+	// An expired one is refreshed once for all clients sharing the store
+	store.tokens[7].ExpiresAt = time.Now()
+	for range 2 {
+		if _, err := newTestClient(server, WithTokenStore(store, 7)).GetActivity(context.Background(), 42); err != nil {
+			t.Fatalf("GetActivity failed: %v", err)
+		}
+	}
+	if got := refreshes.Load(); got != 1 || store.writes != 1 {
+		t.Errorf("expected a single shared refresh, got %d refreshes and %d writes", got, store.writes)
+	}
+	if token := store.tokens[7]; token.AccessToken != "token-1" || token.RefreshToken != "rotated" || token.AthleteID != 7 {
+		t.Errorf("unexpected stored token %+v", token)
+	}
+
+	// A revoked one is refreshed although it hasn't expired
+	store.tokens[7] = &Token{AthleteID: 7, AccessToken: "revoked", RefreshToken: "rotated", ExpiresAt: time.Now().Add(time.Hour)}
+	if _, err := newTestClient(server, WithTokenStore(store, 7)).GetActivity(context.Background(), 42); err != nil {
+		t.Fatalf("expected a retry with a refreshed token, got %v", err)
+	}
+	if token := store.tokens[7]; token.AccessToken != "token-2" {
+		t.Errorf("expected the revoked token to be replaced, got %+v", token)
+	}
+}
+
+func TestClient_TokenStoreSeedsRefreshToken(t *testing.T) {
+	server, refreshes := newTestServer(t, activityRoute(func(string) int { return http.StatusOK }))
+	store := &memoryTokenStore{tokens: map[int64]*Token{}}
+
+	if _, err := newTestClient(server, WithTokenStore(store, 7)).GetActivity(context.Background(), 42); err != nil {
+		t.Fatalf("GetActivity failed: %v", err)
+	}
+	if got := refreshes.Load(); got != 1 {
+		t.Errorf("expected the configured refresh token to be used, got %d refreshes", got)
+	}
+	if token := store.tokens[7]; token == nil || token.AccessToken != "token-1" {
+		t.Errorf("expected the new token to be stored, got %+v", token)
+	}
+
+	// Without a refresh token there is nothing to refresh from
+	_, err := New(123, "secret", "", WithTokenURL(server.URL+"/oauth/token"), WithBaseURL(server.URL+"/api/v3"),
+		WithRetries(0, 0), WithTokenStore(store, 8)).GetActivity(context.Background(), 42)
+	if err == nil {
+		t.Error("expected an error for an athlete without tokens")
+	}
+}
+
+func TestClient_RefreshStoredToken(t *testing.T) {
+	server, refreshes := newTestServer(t, nil)
+	store := &memoryTokenStore{tokens: map[int64]*Token{
+		7: {AthleteID: 7, AccessToken: "stored", RefreshToken: "stored-refresh", ExpiresAt: time.Now().Add(2 * time.Hour)},
+	}}
+	client := newTestClient(server, WithTokenStore(store, 7))
+
+	// A token outlasting the window is left alone
+	if token, refreshed, err := client.RefreshStoredToken(context.Background(), time.Hour); err != nil || refreshed || token.AccessToken != "stored" {
+		t.Fatalf("expected the stored token kept, got %+v, %v, %v", token, refreshed, err)
+	}
+	// One expiring within it is refreshed ahead of time
+	token, refreshed, err := client.RefreshStoredToken(context.Background(), 3*time.Hour)
+	if err != nil || !refreshed || token.AccessToken != "token-1" || store.tokens[7].RefreshToken != "rotated" {
+		t.Fatalf("expected the token refreshed, got %+v, %v, %v", token, refreshed, err)
+	}
+	if got := refreshes.Load(); got != 1 {
+		t.Errorf("expected a single refresh, got %d", got)
+	}
+
+	if _, _, err := newTestClient(server).RefreshStoredToken(context.Background(), time.Hour); err == nil {
+		t.Error("expected an error without a token store")
+	}
+}
@@ -6,7 +6,7 @@ from stravapipe.domain.activity import (
     SummaryStravaActivity,
 )
 from stravapipe.domain.auth import StravaTokenSet
-from stravapipe.domain.webhook import AspectType, WebhookRequest
+from stravapipe.domain.webhook import AspectType, UpdateFields, WebhookRequest
 
 __all__ = [
     "AspectType",
@@ -14,5 +14,6 @@ __all__ = [
     "MinimalStravaActivity",
     "StravaTokenSet",
     "SummaryStravaActivity",
+    "UpdateFields",
     "WebhookRequest",
 ]
@@ -17,6 +17,26 @@ class AspectType(str, Enum):
     DELETE = "delete"
 
 
+class UpdateFields(BaseModel):
+    """Typed form of a webhook's updates, published by the dispatcher.
+
+    Strava sends every update value as a string ("private": "true"); the
+    dispatcher parses them so consumers don't have to. Fields absent from the
+    updates are None.
+    """
+
+    title: str | None = None
+    type: str | None = None
+    private: bool | None = None
+    visibility: str | None = None
+    authorized: bool | None = None
+
+    @property
+    def is_deauthorization(self) -> bool:
+        """Whether the athlete revoked the app's access."""
+        return self.authorized is False
+
+
 class WebhookRequest(BaseModel):
     """Strava webhook request payload.
 
@@ -49,6 +69,11 @@ class WebhookRequest(BaseModel):
         ),
     ]
 
+    update_fields: UpdateFields | None = Field(
+        default=None,
+        description="Updates parsed into typed values by the dispatcher; absent when updates is empty.",
+    )
+
     @field_validator("object_type")
     @classmethod
     def validate_object_type(cls, v):
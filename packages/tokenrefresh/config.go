@@ -0,0 +1,97 @@
+package tokenrefresh
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/strava"
+	"github.com/andy-esch/desirelines/packages/tokenstore"
+)
+
+// DefaultSecretsPath is the standard secret volume mount path, shared
+// with the dispatcher and processor.
+const DefaultSecretsPath = "/etc/secrets/strava_auth.json"
+
+// Config holds all configuration for the refresher.
+type Config struct {
+	// TokenStore holds the tokens to refresh.
+	TokenStore *tokenstore.Config
+	// Athletes locates the athlete registry; when it is enabled every
+	// registered athlete's token is refreshed, otherwise only that of the
+	// token store's STRAVA_ATHLETE_ID.
+	Athletes           *athletes.Config
+	LogLevel           string
+	StravaClientSecret string
+	// StravaRefreshToken seeds the store for the single athlete without a
+	// registry.
+	StravaRefreshToken string
+	// Window is how far ahead of their expiry tokens are refreshed.
+	Window         time.Duration
+	StravaClientID int
+}
+
+// LoadConfig loads configuration from environment variables, with the
+// Strava app credentials read from the mounted secrets file when present
+// and from STRAVA_* variables otherwise.
+func LoadConfig() (*Config, error) {
+	var errs []error
+
+	credentials, err := strava.LoadCredentials(config.GetOrDefault("STRAVA_AUTH_FILE", DefaultSecretsPath), config.Get)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	tokenStore, err := tokenstore.LoadConfig()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	registry, err := athletes.LoadConfig()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if tokenStore != nil {
+		tokenStore.PerAthlete = registry.Enabled()
+	}
+	window, err := config.Duration("REFRESH_WINDOW", DefaultWindow)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	cfg := &Config{
+		TokenStore:         tokenStore,
+		Athletes:           registry,
+		LogLevel:           config.GetOrDefault("LOG_LEVEL", "INFO"),
+		StravaClientSecret: credentials.ClientSecret,
+		StravaRefreshToken: credentials.RefreshToken,
+		Window:             window,
+		StravaClientID:     credentials.ClientID,
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks that the settings needed to refresh tokens are present,
+// reporting every problem at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if !c.TokenStore.Enabled() {
+		errs = append(errs, errors.New("TOKEN_STORE is required, it holds the tokens to refresh"))
+	}
+	if err := c.TokenStore.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.Athletes.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.StravaClientID == 0 || c.StravaClientSecret == "" {
+		errs = append(errs, errors.New("strava client_id and client_secret are required (secrets file or STRAVA_CLIENT_ID and STRAVA_CLIENT_SECRET)"))
+	}
+	if c.Window <= 0 {
+		errs = append(errs, fmt.Errorf("REFRESH_WINDOW must be positive, got %s", c.Window))
+	}
+	return errors.Join(errs...)
+}
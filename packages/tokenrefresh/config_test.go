@@ -0,0 +1,65 @@
+package tokenrefresh
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/tokenstore"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr []string
+	}{
+		{
+			name: "single athlete",
+			cfg: Config{
+				TokenStore:     &tokenstore.Config{Backend: tokenstore.BackendFile, Path: "tokens.json", AthleteID: 1},
+				StravaClientID: 1, StravaClientSecret: "s", Window: DefaultWindow,
+			},
+		},
+		{
+			name: "registered athletes",
+			cfg: Config{
+				TokenStore:     &tokenstore.Config{Backend: tokenstore.BackendFile, Path: "tokens.json", PerAthlete: true},
+				Athletes:       &athletes.Config{Location: "gs://bucket/athletes.json"},
+				StravaClientID: 1, StravaClientSecret: "s", Window: DefaultWindow,
+			},
+		},
+		{
+			name:    "nothing to refresh",
+			cfg:     Config{Window: DefaultWindow},
+			wantErr: []string{"TOKEN_STORE", "client_id"},
+		},
+		{
+			name: "no window",
+			cfg: Config{
+				TokenStore:     &tokenstore.Config{Backend: tokenstore.BackendFile, Path: "tokens.json", AthleteID: 1},
+				StravaClientID: 1, StravaClientSecret: "s",
+			},
+			wantErr: []string{"REFRESH_WINDOW"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("expected valid config, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected errors mentioning %v", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected %q in error, got %v", want, err)
+				}
+			}
+		})
+	}
+}
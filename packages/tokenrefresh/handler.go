@@ -0,0 +1,149 @@
+package tokenrefresh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/tokenstore"
+)
+
+// Handler runs the refresher for Cloud Scheduler: a POST refreshes the
+// tokens and answers with the report.
+type Handler struct {
+	refresher *Refresher
+	// tokens is the token store, nil when the refresher was passed in
+	tokens tokenstore.Store
+	// storage reads a Cloud Storage ATHLETE_REGISTRY, nil otherwise
+	storage *storage.Client
+}
+
+// NewHandler creates a handler from the environment.
+func NewHandler(ctx context.Context) (*Handler, error) {
+	if err := config.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", config.FileEnv, err)
+	}
+	cfg, err := LoadConfig()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	if err := SetLogLevel(cfg.LogLevel); err != nil {
+		return nil, err
+	}
+
+	tokens, err := tokenstore.Open(ctx, cfg.TokenStore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open token store: %w", err)
+	}
+	source := StaticAthletes(athletes.Athlete{ID: cfg.TokenStore.AthleteID, RefreshToken: cfg.StravaRefreshToken})
+	var client *storage.Client
+	if cfg.Athletes.Enabled() {
+		load := athletes.FileLoader(cfg.Athletes.Location)
+		if bucket, object, ok := cfg.Athletes.GCS(); ok {
+			client, err = storage.NewClient(ctx)
+			if err != nil {
+				_ = tokens.Close()
+				return nil, fmt.Errorf("failed to create storage client: %w", err)
+			}
+			load = registryLoader(client.Bucket(bucket).Object(object))
+		}
+		// Every run reads the registry afresh
+		source = athletes.New(load, 0).Athletes
+	}
+
+	Logger.Info("Token refresher initialized", "token_store", cfg.TokenStore.Backend, "athlete_registry", cfg.Athletes.Location, "window", cfg.Window.String())
+	refresher := NewRefresher(cfg.StravaClientID, cfg.StravaClientSecret, tokens, source, cfg.Window)
+	return &Handler{refresher: refresher, tokens: tokens, storage: client}, nil
+}
+
+// registryLoader reads the athlete registry from a Cloud Storage object.
+func registryLoader(handle *storage.ObjectHandle) athletes.Loader {
+	return func(ctx context.Context) ([]byte, error) {
+		reader, err := handle.NewReader(ctx)
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read athlete registry: %w", err)
+		}
+		defer func() { _ = reader.Close() }()
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read athlete registry: %w", err)
+		}
+		return data, nil
+	}
+}
+
+// NewHandlerWithRefresher creates a handler around an existing refresher
+// (useful for testing).
+func NewHandlerWithRefresher(refresher *Refresher) *Handler {
+	return &Handler{refresher: refresher}
+}
+
+// Close releases the token store and the registry's storage client.
+func (h *Handler) Close() error {
+	var err error
+	if h.tokens != nil {
+		err = h.tokens.Close()
+	}
+	if h.storage != nil {
+		err = errors.Join(err, h.storage.Close())
+	}
+	return err
+}
+
+// ServeHTTP refreshes the tokens on POST, serves metrics on /metrics and
+// answers liveness probes on /live. A run in which any athlete failed is
+// answered with a 500, so Cloud Scheduler records it as failed and
+// retries.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/metrics":
+		MetricsHandler().ServeHTTP(w, r)
+		return
+	case r.URL.Path == "/live":
+		writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+		return
+	case r.Method != http.MethodPost:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	start := time.Now()
+	report, err := h.refresher.Run(r.Context())
+	lastRun.SetToCurrentTime()
+	switch {
+	case err != nil:
+		runs.WithLabelValues("failed").Inc()
+		Logger.ErrorContext(r.Context(), "Token refresh failed", "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "Token refresh failed"})
+		return
+	case report.Failed > 0:
+		runs.WithLabelValues("partial").Inc()
+		Logger.ErrorContext(r.Context(), "Some Strava tokens failed to refresh", "refreshed", report.Refreshed, "current", report.Current, "failed", report.Failed)
+		writeJSON(w, http.StatusInternalServerError, report)
+		return
+	}
+	runs.WithLabelValues("ok").Inc()
+	Logger.InfoContext(r.Context(), "Refreshed Strava tokens", "refreshed", report.Refreshed, "current", report.Current, "took", time.Since(start).String())
+	writeJSON(w, http.StatusOK, report)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		Logger.Error("Failed to encode response", "error", err)
+	}
+}
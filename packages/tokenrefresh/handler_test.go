@@ -0,0 +1,55 @@
+package tokenrefresh
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/athletes"
+)
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	server, _ := newTokenServer(t)
+	refresher, _ := newTestRefresher(t, server, StaticAthletes(athletes.Athlete{ID: 1, RefreshToken: "seed"}))
+	handler := NewHandlerWithRefresher(refresher)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var report Report
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil || report.Refreshed != 1 {
+		t.Errorf("unexpected report %s", rr.Body)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(rr.Body.String(), `token_refresh_athletes_total{outcome="refreshed"}`) ||
+		!strings.Contains(rr.Body.String(), `token_refresh_token_expiry_timestamp_seconds{athlete_id="1"}`) {
+		t.Errorf("expected refresh metrics, got %s", rr.Body)
+	}
+}
+
+func TestHandler_ServeHTTPFailure(t *testing.T) {
+	server, _ := newTokenServer(t)
+	refresher, _ := newTestRefresher(t, server, StaticAthletes(athletes.Athlete{ID: 1, RefreshToken: "revoked"}))
+
+	rr := httptest.NewRecorder()
+	NewHandlerWithRefresher(refresher).ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 so the scheduler retries, got %d", rr.Code)
+	}
+	var report Report
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil || report.Failed != 1 || report.Results[0].Error == "" {
+		t.Errorf("expected the failure reported, got %s", rr.Body)
+	}
+}
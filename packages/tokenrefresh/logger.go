@@ -0,0 +1,27 @@
+package tokenrefresh
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/andy-esch/desirelines/packages/logging"
+)
+
+// logLevel is the minimum level Logger writes, INFO unless changed with
+// SetLogLevel.
+var logLevel = new(slog.LevelVar)
+
+// Logger is the package-level structured logger, writing JSON entries
+// with the field names Cloud Logging expects.
+var Logger = logging.New(os.Stderr, logLevel)
+
+// SetLogLevel sets the minimum level from a name: DEBUG, INFO, WARNING (or
+// WARN) or ERROR, in any case.
+func SetLogLevel(name string) error {
+	level, err := logging.ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	logLevel.Set(level)
+	return nil
+}
@@ -0,0 +1,43 @@
+package tokenrefresh
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsRegistry holds the refresher's metrics, served on /metrics.
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	refreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "token_refresh_athletes_total",
+		Help: "Stored tokens checked, by outcome (refreshed, current or failed).",
+	}, []string{"outcome"})
+
+	tokenExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "token_refresh_token_expiry_timestamp_seconds",
+		Help: "Unix time the stored access token expires, per athlete.",
+	}, []string{"athlete_id"})
+
+	runs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "token_refresh_runs_total",
+		Help: "Refresh runs, by result (ok, partial when some athletes failed, or failed).",
+	}, []string{"result"})
+
+	lastRun = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "token_refresh_last_run_timestamp_seconds",
+		Help: "Unix time the last refresh run finished.",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(refreshes, tokenExpiry, runs, lastRun)
+}
+
+// MetricsHandler serves the refresher's metrics in the Prometheus text
+// format.
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
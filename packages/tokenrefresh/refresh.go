@@ -0,0 +1,131 @@
+// Package tokenrefresh keeps the Strava tokens in the token store fresh.
+// Run on a schedule, it refreshes every athlete's token that expires
+// within a window, so the dispatcher and processor find a valid access
+// token instead of refreshing on the way to Strava, and an athlete whose
+// refresh token stopped working shows up in the metrics before their
+// events fail.
+package tokenrefresh
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+// Outcomes of refreshing an athlete's token.
+const (
+	OutcomeRefreshed = "refreshed"
+	OutcomeCurrent   = "current"
+	OutcomeFailed    = "failed"
+)
+
+// DefaultWindow is how far ahead of their expiry tokens are refreshed.
+// Strava access tokens last six hours, so an hourly run refreshes each
+// one a few hours early.
+const DefaultWindow = 3 * time.Hour
+
+// AthleteSource lists the athletes whose tokens are refreshed, with the
+// refresh token to seed the store with for athletes it has none for.
+type AthleteSource func(ctx context.Context) ([]athletes.Athlete, error)
+
+// StaticAthletes is a source of a fixed list of athletes.
+func StaticAthletes(list ...athletes.Athlete) AthleteSource {
+	return func(context.Context) ([]athletes.Athlete, error) {
+		return list, nil
+	}
+}
+
+// Result is the outcome for one athlete.
+type Result struct {
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	Outcome   string    `json:"outcome"`
+	Error     string    `json:"error,omitempty"`
+	AthleteID int64     `json:"athlete_id"`
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	Results   []Result `json:"results"`
+	Refreshed int      `json:"refreshed"`
+	Current   int      `json:"current"`
+	Failed    int      `json:"failed"`
+}
+
+// Refresher refreshes the stored tokens of the athletes from a source
+// with one app's credentials.
+type Refresher struct {
+	tokens       strava.TokenStore
+	athletes     AthleteSource
+	clientSecret string
+	opts         []strava.Option
+	window       time.Duration
+	clientID     int
+}
+
+// NewRefresher creates a refresher for the app's credentials refreshing
+// tokens in store that expire within window. opts apply to every client.
+func NewRefresher(clientID int, clientSecret string, tokens strava.TokenStore, source AthleteSource, window time.Duration, opts ...strava.Option) *Refresher {
+	return &Refresher{
+		tokens:       tokens,
+		athletes:     source,
+		clientSecret: clientSecret,
+		opts:         opts,
+		window:       window,
+		clientID:     clientID,
+	}
+}
+
+// Run refreshes each athlete's token in turn. An athlete failing doesn't
+// stop the others; their failures are in the report. The error is only
+// set when the athletes can't be listed.
+func (r *Refresher) Run(ctx context.Context) (Report, error) {
+	list, err := r.athletes(ctx)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to list athletes: %w", err)
+	}
+
+	report := Report{Results: make([]Result, 0, len(list))}
+	for _, athlete := range list {
+		result := r.refresh(ctx, athlete)
+		switch result.Outcome {
+		case OutcomeRefreshed:
+			report.Refreshed++
+		case OutcomeCurrent:
+			report.Current++
+		default:
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// refresh refreshes one athlete's token, logging and counting the outcome.
+func (r *Refresher) refresh(ctx context.Context, athlete athletes.Athlete) Result {
+	opts := append(append([]strava.Option{}, r.opts...), strava.WithTokenStore(r.tokens, athlete.ID))
+	client := strava.New(r.clientID, r.clientSecret, athlete.RefreshToken, opts...)
+
+	result := Result{AthleteID: athlete.ID}
+	token, refreshed, err := client.RefreshStoredToken(ctx, r.window)
+	switch {
+	case err != nil:
+		result.Outcome = OutcomeFailed
+		result.Error = err.Error()
+		Logger.ErrorContext(ctx, "Failed to refresh Strava token", "athlete_id", athlete.ID, "error", err)
+	case refreshed:
+		result.Outcome = OutcomeRefreshed
+		Logger.InfoContext(ctx, "Refreshed Strava token", "athlete_id", athlete.ID, "expires_at", token.ExpiresAt)
+	default:
+		result.Outcome = OutcomeCurrent
+		Logger.DebugContext(ctx, "Strava token still current", "athlete_id", athlete.ID, "expires_at", token.ExpiresAt)
+	}
+	if token != nil {
+		result.ExpiresAt = token.ExpiresAt
+		tokenExpiry.WithLabelValues(fmt.Sprint(athlete.ID)).Set(float64(token.ExpiresAt.Unix()))
+	}
+	refreshes.WithLabelValues(result.Outcome).Inc()
+	return result
+}
@@ -0,0 +1,97 @@
+package tokenrefresh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/athletes"
+	"github.com/andy-esch/desirelines/packages/strava"
+	"github.com/andy-esch/desirelines/packages/tokenstore"
+)
+
+// newTokenServer serves Strava's token endpoint, rejecting the "revoked"
+// refresh token, and counts refreshes.
+func newTokenServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var refreshes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("refresh_token") == "revoked" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"message":"Bad Request"}`))
+			return
+		}
+		n := refreshes.Add(1)
+		_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","refresh_token":"rotated-%d","expires_at":%d}`,
+			n, n, time.Now().Add(6*time.Hour).Unix())
+	}))
+	t.Cleanup(server.Close)
+	return server, &refreshes
+}
+
+// storeToken puts athleteID's token in store.
+func storeToken(t *testing.T, store tokenstore.Store, token strava.Token) {
+	t.Helper()
+	if _, err := store.UpdateToken(context.Background(), token.AthleteID, func(*strava.Token) (*strava.Token, error) {
+		return &token, nil
+	}); err != nil {
+		t.Fatalf("failed to store token: %v", err)
+	}
+}
+
+// newTestRefresher refreshes the tokens of source in a file store.
+func newTestRefresher(t *testing.T, server *httptest.Server, source AthleteSource) (*Refresher, tokenstore.Store) {
+	t.Helper()
+	store := tokenstore.NewFileStore(filepath.Join(t.TempDir(), "tokens.json"))
+	return NewRefresher(123, "secret", store, source, DefaultWindow,
+		strava.WithTokenURL(server.URL), strava.WithRetries(0, 0)), store
+}
+
+func TestRefresher_Run(t *testing.T) {
+	server, refreshes := newTokenServer(t)
+	refresher, store := newTestRefresher(t, server, StaticAthletes(
+		athletes.Athlete{ID: 1},
+		athletes.Athlete{ID: 2},
+		athletes.Athlete{ID: 3, RefreshToken: "seed"},
+		athletes.Athlete{ID: 4},
+	))
+	storeToken(t, store, strava.Token{AthleteID: 1, AccessToken: "fresh", RefreshToken: "r1", ExpiresAt: time.Now().Add(5 * time.Hour)})
+	storeToken(t, store, strava.Token{AthleteID: 2, AccessToken: "stale", RefreshToken: "r2", ExpiresAt: time.Now().Add(time.Hour)})
+	storeToken(t, store, strava.Token{AthleteID: 4, AccessToken: "stale", RefreshToken: "revoked", ExpiresAt: time.Now().Add(time.Hour)})
+
+	report, err := refresher.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	want := []string{OutcomeCurrent, OutcomeRefreshed, OutcomeRefreshed, OutcomeFailed}
+	for i, result := range report.Results {
+		if result.Outcome != want[i] {
+			t.Errorf("athlete %d: expected %s, got %s (%s)", result.AthleteID, want[i], result.Outcome, result.Error)
+		}
+	}
+	if report.Current != 1 || report.Refreshed != 2 || report.Failed != 1 || refreshes.Load() != 2 {
+		t.Errorf("unexpected report %+v after %d refreshes", report, refreshes.Load())
+	}
+
+	// The rotated refresh token replaced the stored one
+	token, err := store.UpdateToken(context.Background(), 2, func(current *strava.Token) (*strava.Token, error) { return current, nil })
+	if err != nil || token.RefreshToken == "r2" || !token.Valid(time.Now().Add(DefaultWindow)) {
+		t.Errorf("expected a rotated token, got %+v, %v", token, err)
+	}
+}
+
+func TestRefresher_RunSourceError(t *testing.T) {
+	server, _ := newTokenServer(t)
+	refresher, _ := newTestRefresher(t, server, func(context.Context) ([]athletes.Athlete, error) {
+		return nil, errors.New("registry unavailable")
+	})
+	if _, err := refresher.Run(context.Background()); err == nil {
+		t.Error("expected an error when athletes can't be listed")
+	}
+}
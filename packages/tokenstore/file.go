@@ -0,0 +1,103 @@
+package tokenstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+// FileStore keeps tokens in a JSON file keyed by athlete ID, for local
+// development. Updates hold an exclusive lock on a ".lock" file next to it,
+// so processes sharing the file, e.g. through a mounted volume, take turns.
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileStore creates a store for the file at path, which is created on
+// the first update.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// UpdateToken implements the strava.TokenStore interface.
+func (s *FileStore) UpdateToken(ctx context.Context, athleteID int64, update func(*strava.Token) (*strava.Token, error)) (*strava.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock token file: %w", err)
+	}
+	defer unlock()
+
+	tokens, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	key := strconv.FormatInt(athleteID, 10)
+	current := tokens[key]
+	next, err := update(current)
+	if err != nil {
+		return nil, err
+	}
+	if next == current {
+		return current, nil
+	}
+	tokens[key] = next
+	if err := s.write(tokens); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// Close implements the Store interface.
+func (s *FileStore) Close() error {
+	return nil
+}
+
+func (s *FileStore) read() (map[string]*strava.Token, error) {
+	tokens := map[string]*strava.Token{}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse token file %s: %w", s.path, err)
+	}
+	return tokens, nil
+}
+
+// write replaces the file atomically, so readers never see half of it.
+func (s *FileStore) write(tokens map[string]*strava.Token) error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal tokens: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace token file: %w", err)
+	}
+	return nil
+}
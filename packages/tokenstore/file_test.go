@@ -0,0 +1,84 @@
+package tokenstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+// refreshOnce returns an update that keeps a valid stored token and
+// otherwise "refreshes" it, counting refreshes.
+func refreshOnce(refreshes *int, mu *sync.Mutex) func(*strava.Token) (*strava.Token, error) {
+	return func(current *strava.Token) (*strava.Token, error) {
+		if current != nil && current.Valid(time.Now()) {
+			return current, nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		*refreshes++
+		return &strava.Token{AccessToken: fmt.Sprintf("token-%d", *refreshes), RefreshToken: "refresh", ExpiresAt: time.Now().Add(time.Hour)}, nil
+	}
+}
+
+func TestFileStore_UpdateToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	store := NewFileStore(path)
+
+	var refreshes int
+	var mu sync.Mutex
+	token, err := store.UpdateToken(context.Background(), 7, refreshOnce(&refreshes, &mu))
+	if err != nil {
+		t.Fatalf("UpdateToken failed: %v", err)
+	}
+	if token.AccessToken != "token-1" {
+		t.Errorf("unexpected token %+v", token)
+	}
+
+	// A second store on the same file sees the stored token
+	token, err = NewFileStore(path).UpdateToken(context.Background(), 7, refreshOnce(&refreshes, &mu))
+	if err != nil {
+		t.Fatalf("UpdateToken failed: %v", err)
+	}
+	if token.AccessToken != "token-1" || refreshes != 1 {
+		t.Errorf("expected the stored token to be kept, got %+v after %d refreshes", token, refreshes)
+	}
+
+	// Failed updates leave the file alone
+	before, _ := os.ReadFile(path)
+	if _, err := store.UpdateToken(context.Background(), 8, func(*strava.Token) (*strava.Token, error) {
+		return nil, errors.New("refresh failed")
+	}); err == nil {
+		t.Error("expected the update's error to be returned")
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Errorf("expected the file to be unchanged, got %s", after)
+	}
+}
+
+func TestFileStore_ConcurrentUpdates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+
+	var refreshes int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			// Separate stores take the file lock like separate processes
+			if _, err := NewFileStore(path).UpdateToken(context.Background(), 7, refreshOnce(&refreshes, &mu)); err != nil {
+				t.Errorf("UpdateToken failed: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if refreshes != 1 {
+		t.Errorf("expected a single refresh, got %d", refreshes)
+	}
+}
@@ -0,0 +1,94 @@
+package tokenstore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/andy-esch/desirelines/packages/strava"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// firestoreToken is the Firestore representation of a strava.Token.
+type firestoreToken struct {
+	ExpiresAt    time.Time `firestore:"expires_at"`
+	UpdatedAt    time.Time `firestore:"updated_at"`
+	AccessToken  string    `firestore:"access_token"`
+	RefreshToken string    `firestore:"refresh_token"`
+	AthleteID    int64     `firestore:"athlete_id"`
+}
+
+// FirestoreStore keeps tokens as documents in a Firestore collection, one
+// per athlete ID. Updates run in transactions, which Firestore retries
+// with the newer token when they conflict.
+type FirestoreStore struct {
+	client     *firestore.Client
+	collection string
+}
+
+// NewFirestoreStore creates a store using collection in the project's
+// default database.
+func NewFirestoreStore(ctx context.Context, projectID, collection string) (*FirestoreStore, error) {
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	return &FirestoreStore{client: client, collection: collection}, nil
+}
+
+// UpdateToken implements the strava.TokenStore interface.
+func (s *FirestoreStore) UpdateToken(ctx context.Context, athleteID int64, update func(*strava.Token) (*strava.Token, error)) (*strava.Token, error) {
+	ref := s.client.Collection(s.collection).Doc(strconv.FormatInt(athleteID, 10))
+	var result *strava.Token
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snapshot, err := tx.Get(ref)
+		var current *strava.Token
+		switch {
+		case status.Code(err) == codes.NotFound:
+		case err != nil:
+			return err
+		default:
+			var doc firestoreToken
+			if err := snapshot.DataTo(&doc); err != nil {
+				return fmt.Errorf("failed to decode token of athlete %d: %w", athleteID, err)
+			}
+			current = &strava.Token{
+				ExpiresAt:    doc.ExpiresAt,
+				AccessToken:  doc.AccessToken,
+				RefreshToken: doc.RefreshToken,
+				AthleteID:    doc.AthleteID,
+			}
+		}
+
+		next, err := update(current)
+		if err != nil {
+			return err
+		}
+		result = next
+		if next == current {
+			return nil
+		}
+		return tx.Set(ref, firestoreToken{
+			ExpiresAt:    next.ExpiresAt,
+			UpdatedAt:    time.Now(),
+			AccessToken:  next.AccessToken,
+			RefreshToken: next.RefreshToken,
+			AthleteID:    athleteID,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update token of athlete %d: %w", athleteID, err)
+	}
+	return result, nil
+}
+
+// Close implements the Store interface.
+func (s *FirestoreStore) Close() error {
+	if err := s.client.Close(); err != nil {
+		return fmt.Errorf("failed to close Firestore client: %w", err)
+	}
+	return nil
+}
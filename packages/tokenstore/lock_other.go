@@ -0,0 +1,9 @@
+//go:build !unix
+
+package tokenstore
+
+// lockFile is a no-op where flock isn't available; FileStore's mutex still
+// serialises updates within the process.
+func lockFile(string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,200 @@
+package tokenstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/andy-esch/desirelines/packages/strava"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const (
+	// currentVersionAnnotation names the secret version holding the
+	// current token. Moving it with the secret's etag as precondition makes
+	// it a compare-and-swap, which Secret Manager versions alone aren't.
+	currentVersionAnnotation = "desirelines-current-version"
+
+	// maxSecretAttempts bounds retries of updates that lost a race.
+	maxSecretAttempts = 5
+)
+
+// errSecretConflict means another writer changed the token during an
+// update, which is then retried from the newer token.
+var errSecretConflict = errors.New("token secret changed concurrently")
+
+// secretManagerAPI is the subset of the Secret Manager client the store
+// uses.
+type secretManagerAPI interface {
+	GetSecret(ctx context.Context, req *secretmanagerpb.GetSecretRequest, opts ...gax.CallOption) (*secretmanagerpb.Secret, error)
+	CreateSecret(ctx context.Context, req *secretmanagerpb.CreateSecretRequest, opts ...gax.CallOption) (*secretmanagerpb.Secret, error)
+	UpdateSecret(ctx context.Context, req *secretmanagerpb.UpdateSecretRequest, opts ...gax.CallOption) (*secretmanagerpb.Secret, error)
+	AddSecretVersion(ctx context.Context, req *secretmanagerpb.AddSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.SecretVersion, error)
+	AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.AccessSecretVersionResponse, error)
+	DestroySecretVersion(ctx context.Context, req *secretmanagerpb.DestroySecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.SecretVersion, error)
+	Close() error
+}
+
+// SecretManagerStore keeps each athlete's token as a Secret Manager secret
+// named prefix plus athlete ID, created on the first update. Superseded
+// versions are destroyed, so a secret has a single enabled version. The
+// service account needs the Secret Manager Admin role on the project, or
+// Secret Version Manager plus Viewer on pre-created secrets.
+type SecretManagerStore struct {
+	client    secretManagerAPI
+	projectID string
+	prefix    string
+	mu        sync.Mutex
+}
+
+// NewSecretManagerStore creates a store for the project's secrets.
+func NewSecretManagerStore(ctx context.Context, projectID, prefix string) (*SecretManagerStore, error) {
+	client, err := secretmanager.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create secret manager client: %w", err)
+	}
+	return newSecretManagerStore(client, projectID, prefix), nil
+}
+
+func newSecretManagerStore(client secretManagerAPI, projectID, prefix string) *SecretManagerStore {
+	return &SecretManagerStore{client: client, projectID: projectID, prefix: prefix}
+}
+
+// UpdateToken implements the strava.TokenStore interface.
+func (s *SecretManagerStore) UpdateToken(ctx context.Context, athleteID int64, update func(*strava.Token) (*strava.Token, error)) (*strava.Token, error) {
+	// Other processes are kept out by the etag; the mutex saves this one
+	// from racing itself
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for range maxSecretAttempts {
+		secret, current, err := s.load(ctx, athleteID)
+		if errors.Is(err, errSecretConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		next, err := update(current)
+		if err != nil {
+			return nil, err
+		}
+		if next == current {
+			return current, nil
+		}
+		err = s.store(ctx, secret, next)
+		if errors.Is(err, errSecretConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return next, nil
+	}
+	return nil, fmt.Errorf("token of athlete %d: %w %d times", athleteID, errSecretConflict, maxSecretAttempts)
+}
+
+// Close implements the Store interface.
+func (s *SecretManagerStore) Close() error {
+	if err := s.client.Close(); err != nil {
+		return fmt.Errorf("failed to close secret manager client: %w", err)
+	}
+	return nil
+}
+
+// load returns the athlete's secret, creating it if needed, and the token
+// its current version holds, nil for a new secret.
+func (s *SecretManagerStore) load(ctx context.Context, athleteID int64) (*secretmanagerpb.Secret, *strava.Token, error) {
+	secretID := fmt.Sprintf("%s%d", s.prefix, athleteID)
+	name := fmt.Sprintf("projects/%s/secrets/%s", s.projectID, secretID)
+
+	secret, err := s.client.GetSecret(ctx, &secretmanagerpb.GetSecretRequest{Name: name})
+	if status.Code(err) == codes.NotFound {
+		secret, err = s.client.CreateSecret(ctx, &secretmanagerpb.CreateSecretRequest{
+			Parent:   "projects/" + s.projectID,
+			SecretId: secretID,
+			Secret: &secretmanagerpb.Secret{
+				Replication: &secretmanagerpb.Replication{
+					Replication: &secretmanagerpb.Replication_Automatic_{Automatic: &secretmanagerpb.Replication_Automatic{}},
+				},
+			},
+		})
+		if status.Code(err) == codes.AlreadyExists {
+			return nil, nil, errSecretConflict
+		}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get token secret %s: %w", name, err)
+	}
+
+	version := secret.GetAnnotations()[currentVersionAnnotation]
+	if version == "" {
+		return secret, nil, nil
+	}
+	resp, err := s.client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: version})
+	if code := status.Code(err); code == codes.NotFound || code == codes.FailedPrecondition {
+		// Destroyed by a writer that replaced it after GetSecret
+		return nil, nil, errSecretConflict
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to access token secret %s: %w", version, err)
+	}
+	var token strava.Token
+	if err := json.Unmarshal(resp.GetPayload().GetData(), &token); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse token secret %s: %w", version, err)
+	}
+	return secret, &token, nil
+}
+
+// store adds token as a new version of secret and makes it current,
+// unless the secret changed since it was read.
+func (s *SecretManagerStore) store(ctx context.Context, secret *secretmanagerpb.Secret, token *strava.Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %w", err)
+	}
+	version, err := s.client.AddSecretVersion(ctx, &secretmanagerpb.AddSecretVersionRequest{
+		Parent:  secret.GetName(),
+		Payload: &secretmanagerpb.SecretPayload{Data: data},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add token secret version: %w", err)
+	}
+
+	annotations := maps.Clone(secret.GetAnnotations())
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	previous := annotations[currentVersionAnnotation]
+	annotations[currentVersionAnnotation] = version.GetName()
+	_, err = s.client.UpdateSecret(ctx, &secretmanagerpb.UpdateSecretRequest{
+		Secret:     &secretmanagerpb.Secret{Name: secret.GetName(), Etag: secret.GetEtag(), Annotations: annotations},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"annotations"}},
+	})
+	if err != nil {
+		s.destroy(ctx, version.GetName())
+		if code := status.Code(err); code == codes.Aborted || code == codes.FailedPrecondition {
+			return errSecretConflict
+		}
+		return fmt.Errorf("failed to update token secret %s: %w", secret.GetName(), err)
+	}
+	if previous != "" {
+		s.destroy(ctx, previous)
+	}
+	return nil
+}
+
+// destroy removes a token version that isn't current. Failures only leave
+// an unused version behind, so they are ignored.
+func (s *SecretManagerStore) destroy(ctx context.Context, version string) {
+	_, _ = s.client.DestroySecretVersion(ctx, &secretmanagerpb.DestroySecretVersionRequest{Name: version})
+}
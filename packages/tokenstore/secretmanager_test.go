@@ -0,0 +1,177 @@
+package tokenstore
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/andy-esch/desirelines/packages/strava"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeSecretManager keeps secrets in memory and enforces etags like
+// Secret Manager does.
+type fakeSecretManager struct {
+	secrets  map[string]*secretmanagerpb.Secret
+	versions map[string][]byte
+	// beforeUpdate runs before an UpdateSecret is applied, to inject races
+	beforeUpdate func()
+	etags        int
+	mu           sync.Mutex
+}
+
+func newFakeSecretManager() *fakeSecretManager {
+	return &fakeSecretManager{secrets: map[string]*secretmanagerpb.Secret{}, versions: map[string][]byte{}}
+}
+
+func (f *fakeSecretManager) nextEtag() string {
+	f.etags++
+	return fmt.Sprintf(`"%d"`, f.etags)
+}
+
+func (f *fakeSecretManager) GetSecret(ctx context.Context, req *secretmanagerpb.GetSecretRequest, opts ...gax.CallOption) (*secretmanagerpb.Secret, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	secret, ok := f.secrets[req.GetName()]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "secret not found")
+	}
+	return proto.Clone(secret).(*secretmanagerpb.Secret), nil
+}
+
+func (f *fakeSecretManager) CreateSecret(ctx context.Context, req *secretmanagerpb.CreateSecretRequest, opts ...gax.CallOption) (*secretmanagerpb.Secret, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := req.GetParent() + "/secrets/" + req.GetSecretId()
+	if _, ok := f.secrets[name]; ok {
+		return nil, status.Error(codes.AlreadyExists, "secret exists")
+	}
+	f.secrets[name] = &secretmanagerpb.Secret{Name: name, Etag: f.nextEtag()}
+	return proto.Clone(f.secrets[name]).(*secretmanagerpb.Secret), nil
+}
+
+func (f *fakeSecretManager) UpdateSecret(ctx context.Context, req *secretmanagerpb.UpdateSecretRequest, opts ...gax.CallOption) (*secretmanagerpb.Secret, error) {
+	if f.beforeUpdate != nil {
+		f.beforeUpdate()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	secret := f.secrets[req.GetSecret().GetName()]
+	if secret.GetEtag() != req.GetSecret().GetEtag() {
+		return nil, status.Error(codes.Aborted, "etag mismatch")
+	}
+	secret.Annotations = maps.Clone(req.GetSecret().GetAnnotations())
+	secret.Etag = f.nextEtag()
+	return proto.Clone(secret).(*secretmanagerpb.Secret), nil
+}
+
+func (f *fakeSecretManager) AddSecretVersion(ctx context.Context, req *secretmanagerpb.AddSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.SecretVersion, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := fmt.Sprintf("%s/versions/%d", req.GetParent(), len(f.versions)+1)
+	f.versions[name] = req.GetPayload().GetData()
+	return &secretmanagerpb.SecretVersion{Name: name}, nil
+}
+
+func (f *fakeSecretManager) AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.versions[req.GetName()]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "version not found")
+	}
+	if data == nil {
+		return nil, status.Error(codes.FailedPrecondition, "version destroyed")
+	}
+	return &secretmanagerpb.AccessSecretVersionResponse{Name: req.GetName(), Payload: &secretmanagerpb.SecretPayload{Data: data}}, nil
+}
+
+func (f *fakeSecretManager) DestroySecretVersion(ctx context.Context, req *secretmanagerpb.DestroySecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.SecretVersion, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.versions[req.GetName()] = nil
+	return &secretmanagerpb.SecretVersion{Name: req.GetName()}, nil
+}
+
+func (f *fakeSecretManager) Close() error {
+	return nil
+}
+
+// enabledVersions counts versions that weren't destroyed.
+func (f *fakeSecretManager) enabledVersions() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	enabled := 0
+	for _, data := range f.versions {
+		if data != nil {
+			enabled++
+		}
+	}
+	return enabled
+}
+
+func TestSecretManagerStore_UpdateToken(t *testing.T) {
+	fake := newFakeSecretManager()
+	store := newSecretManagerStore(fake, "project", DefaultSecretPrefix)
+
+	var refreshes int
+	var mu sync.Mutex
+	token, err := store.UpdateToken(context.Background(), 7, refreshOnce(&refreshes, &mu))
+	if err != nil {
+		t.Fatalf("UpdateToken failed: %v", err)
+	}
+	if token.AccessToken != "token-1" {
+		t.Errorf("unexpected token %+v", token)
+	}
+	secret := fake.secrets["projects/project/secrets/strava-token-7"]
+	if secret == nil || secret.GetAnnotations()[currentVersionAnnotation] == "" {
+		t.Fatalf("expected the secret to be created with a current version, got %v", secret)
+	}
+
+	// The stored token is read back and, once replaced, its version destroyed
+	token, err = store.UpdateToken(context.Background(), 7, func(current *strava.Token) (*strava.Token, error) {
+		if current == nil || current.AccessToken != "token-1" {
+			t.Errorf("expected the stored token, got %+v", current)
+		}
+		return &strava.Token{AccessToken: "replaced"}, nil
+	})
+	if err != nil || token.AccessToken != "replaced" {
+		t.Fatalf("UpdateToken = %+v, %v", token, err)
+	}
+	if got := fake.enabledVersions(); got != 1 {
+		t.Errorf("expected a single enabled version, got %d", got)
+	}
+}
+
+func TestSecretManagerStore_RetriesConflicts(t *testing.T) {
+	fake := newFakeSecretManager()
+	store := newSecretManagerStore(fake, "project", DefaultSecretPrefix)
+	other := newSecretManagerStore(fake, "project", DefaultSecretPrefix)
+
+	var refreshes int
+	var mu sync.Mutex
+	// Another process stores its refresh while this one is refreshing
+	fake.beforeUpdate = func() {
+		fake.beforeUpdate = nil
+		if _, err := other.UpdateToken(context.Background(), 7, refreshOnce(&refreshes, &mu)); err != nil {
+			t.Errorf("other UpdateToken failed: %v", err)
+		}
+	}
+
+	token, err := store.UpdateToken(context.Background(), 7, refreshOnce(&refreshes, &mu))
+	if err != nil {
+		t.Fatalf("UpdateToken failed: %v", err)
+	}
+	if token.AccessToken != "token-2" {
+		t.Errorf("expected the loser to adopt the other process's token, got %+v", token)
+	}
+	if got := fake.enabledVersions(); got != 1 {
+		t.Errorf("expected the losing version to be destroyed, got %d enabled versions", got)
+	}
+}
@@ -0,0 +1,121 @@
+// Package tokenstore persists athletes' Strava OAuth tokens so the
+// functions and tools calling Strava share one set of credentials:
+//
+//	store, err := tokenstore.Open(ctx, cfg)
+//	client := strava.New(clientID, clientSecret, refreshToken, strava.WithTokenStore(store, cfg.AthleteID))
+//
+// Tokens are kept in Firestore, Secret Manager or, for local development,
+// a JSON file. Every backend serialises updates of an athlete's token, so
+// a refresh is never overwritten by a client still holding the old token.
+package tokenstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/andy-esch/desirelines/packages/config"
+	"github.com/andy-esch/desirelines/packages/strava"
+)
+
+// Backends selectable with TOKEN_STORE.
+const (
+	BackendFile          = "file"
+	BackendFirestore     = "firestore"
+	BackendSecretManager = "secretmanager"
+)
+
+const (
+	// DefaultPath is the file the file backend keeps tokens in.
+	DefaultPath = "strava_tokens.json"
+	// DefaultCollection is the Firestore collection of token documents.
+	DefaultCollection = "strava_tokens"
+	// DefaultSecretPrefix prefixes the athlete ID in Secret Manager secret
+	// names, e.g. "strava-token-12345".
+	DefaultSecretPrefix = "strava-token-"
+)
+
+// Store is a strava.TokenStore with resources to release.
+type Store interface {
+	strava.TokenStore
+	Close() error
+}
+
+// Config selects and configures the token store.
+type Config struct {
+	// Backend is one of the Backend constants, or empty for no store.
+	Backend      string
+	Path         string
+	Collection   string
+	SecretPrefix string
+	GCPProjectID string
+	// AthleteID is the athlete whose tokens the clients share.
+	AthleteID int64
+	// PerAthlete is set when clients are created per registered athlete
+	// (see the athletes package), so AthleteID isn't needed.
+	PerAthlete bool
+}
+
+// LoadConfig loads the token store settings from the environment.
+func LoadConfig() (*Config, error) {
+	cfg := &Config{
+		Backend:      config.Get("TOKEN_STORE"),
+		Path:         config.GetOrDefault("TOKEN_STORE_PATH", DefaultPath),
+		Collection:   config.GetOrDefault("TOKEN_STORE_COLLECTION", DefaultCollection),
+		SecretPrefix: config.GetOrDefault("TOKEN_STORE_SECRET_PREFIX", DefaultSecretPrefix),
+		GCPProjectID: config.Get("GCP_PROJECT_ID"),
+	}
+	if v := config.Get("STRAVA_ATHLETE_ID"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid STRAVA_ATHLETE_ID: %q", v)
+		}
+		cfg.AthleteID = id
+	}
+	return cfg, nil
+}
+
+// Enabled reports whether a backend is configured; a nil Config has none.
+func (c *Config) Enabled() bool {
+	return c != nil && c.Backend != ""
+}
+
+// Validate checks the settings the configured backend needs, reporting
+// every problem at once.
+func (c *Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	var errs []error
+	switch c.Backend {
+	case BackendFile:
+		if c.Path == "" {
+			errs = append(errs, errors.New("TOKEN_STORE_PATH is required for the file token store"))
+		}
+	case BackendFirestore, BackendSecretManager:
+		if c.GCPProjectID == "" {
+			errs = append(errs, fmt.Errorf("GCP_PROJECT_ID is required for the %s token store", c.Backend))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid TOKEN_STORE %q (want %s, %s or %s)", c.Backend, BackendFile, BackendFirestore, BackendSecretManager))
+	}
+	if c.AthleteID == 0 && !c.PerAthlete {
+		errs = append(errs, errors.New("STRAVA_ATHLETE_ID is required with TOKEN_STORE"))
+	}
+	return errors.Join(errs...)
+}
+
+// Open creates the configured store. cfg must be valid and enabled.
+func Open(ctx context.Context, cfg *Config) (Store, error) {
+	switch cfg.Backend {
+	case BackendFile:
+		return NewFileStore(cfg.Path), nil
+	case BackendFirestore:
+		return NewFirestoreStore(ctx, cfg.GCPProjectID, cfg.Collection)
+	case BackendSecretManager:
+		return NewSecretManagerStore(ctx, cfg.GCPProjectID, cfg.SecretPrefix)
+	default:
+		return nil, fmt.Errorf("unknown token store %q", cfg.Backend)
+	}
+}
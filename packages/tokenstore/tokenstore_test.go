@@ -0,0 +1,47 @@
+package tokenstore
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("TOKEN_STORE", BackendFirestore)
+	t.Setenv("GCP_PROJECT_ID", "project")
+	t.Setenv("STRAVA_ATHLETE_ID", "12345")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if !cfg.Enabled() || cfg.AthleteID != 12345 || cfg.Collection != DefaultCollection || cfg.SecretPrefix != DefaultSecretPrefix {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected valid config, got %v", err)
+	}
+
+	t.Setenv("STRAVA_ATHLETE_ID", "athlete")
+	if _, err := LoadConfig(); err == nil {
+		t.Error("expected an invalid STRAVA_ATHLETE_ID to fail")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := (&Config{}).Validate(); err != nil {
+		t.Errorf("expected no store to be valid, got %v", err)
+	}
+
+	err := (&Config{Backend: BackendSecretManager}).Validate()
+	if err == nil || !strings.Contains(err.Error(), "GCP_PROJECT_ID") || !strings.Contains(err.Error(), "STRAVA_ATHLETE_ID") {
+		t.Errorf("expected every missing setting to be reported, got %v", err)
+	}
+
+	if err := (&Config{Backend: BackendFile, Path: DefaultPath, PerAthlete: true}).Validate(); err != nil {
+		t.Errorf("expected per-athlete clients not to need STRAVA_ATHLETE_ID, got %v", err)
+	}
+
+	if err := (&Config{Backend: "redis", AthleteID: 1}).Validate(); err == nil {
+		t.Error("expected an unknown backend to be rejected")
+	}
+}
@@ -0,0 +1,314 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: webhook_event.proto
+
+package events
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Strava webhook event published to the activity events Pub/Sub topic
+// This is the data contract between the dispatcher and the Python consumers
+//
+// Producer:
+//   - Go: packages/dispatcher/webhook.go
+//   - Type: WebhookRequest (JSON tags must match the field names below)
+//   - Published by: PubSubPublisher with MESSAGE_FORMAT=raw
+//
+// Consumers:
+//   - Python: packages/stravapipe/src/stravapipe/domain/webhook.py
+//   - Type: WebhookRequest (pydantic model)
+//   - Functions: activity_aggregator, activity_bq_inserter
+//
+// Pub/Sub:
+//   - Attached to the topic with JSON encoding when
+//     enable_activity_event_schema is set in Terraform, so messages keep
+//     their current wire format and non-conforming ones are rejected at publish
+//   - Pub/Sub schemas allow a single top-level message and no imports, so
+//     keep this file self-contained and nest any other message types
+//
+// Note: The optional "activity" field added by ENRICH_ACTIVITIES and the
+//
+//	CloudEvents envelope (MESSAGE_FORMAT=cloudevents) are not part of
+//	this contract; leave the schema detached when using either.
+type WebhookEvent struct {
+	state          protoimpl.MessageState     `protogen:"open.v1"`
+	AspectType     string                     `protobuf:"bytes,1,opt,name=aspect_type,json=aspectType,proto3" json:"aspect_type,omitempty"`                                                   // "create", "update" or "delete"
+	ObjectType     string                     `protobuf:"bytes,2,opt,name=object_type,json=objectType,proto3" json:"object_type,omitempty"`                                                   // "activity" or "athlete"
+	EventTime      int64                      `protobuf:"varint,3,opt,name=event_time,json=eventTime,proto3" json:"event_time,omitempty"`                                                     // Unix timestamp (seconds)
+	ObjectId       int64                      `protobuf:"varint,4,opt,name=object_id,json=objectId,proto3" json:"object_id,omitempty"`                                                        // Activity ID, or athlete ID for athlete events
+	OwnerId        int64                      `protobuf:"varint,5,opt,name=owner_id,json=ownerId,proto3" json:"owner_id,omitempty"`                                                           // Athlete ID
+	SubscriptionId int64                      `protobuf:"varint,6,opt,name=subscription_id,json=subscriptionId,proto3" json:"subscription_id,omitempty"`                                      // Push subscription ID
+	Updates        map[string]string          `protobuf:"bytes,7,rep,name=updates,proto3" json:"updates,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // e.g. "title", "type", "private", "authorized"
+	UpdateFields   *WebhookEvent_UpdateFields `protobuf:"bytes,8,opt,name=update_fields,json=updateFields,proto3" json:"update_fields,omitempty"`                                             // updates parsed by the dispatcher; absent when empty
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *WebhookEvent) Reset() {
+	*x = WebhookEvent{}
+	mi := &file_webhook_event_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WebhookEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WebhookEvent) ProtoMessage() {}
+
+func (x *WebhookEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_webhook_event_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WebhookEvent.ProtoReflect.Descriptor instead.
+func (*WebhookEvent) Descriptor() ([]byte, []int) {
+	return file_webhook_event_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *WebhookEvent) GetAspectType() string {
+	if x != nil {
+		return x.AspectType
+	}
+	return ""
+}
+
+func (x *WebhookEvent) GetObjectType() string {
+	if x != nil {
+		return x.ObjectType
+	}
+	return ""
+}
+
+func (x *WebhookEvent) GetEventTime() int64 {
+	if x != nil {
+		return x.EventTime
+	}
+	return 0
+}
+
+func (x *WebhookEvent) GetObjectId() int64 {
+	if x != nil {
+		return x.ObjectId
+	}
+	return 0
+}
+
+func (x *WebhookEvent) GetOwnerId() int64 {
+	if x != nil {
+		return x.OwnerId
+	}
+	return 0
+}
+
+func (x *WebhookEvent) GetSubscriptionId() int64 {
+	if x != nil {
+		return x.SubscriptionId
+	}
+	return 0
+}
+
+func (x *WebhookEvent) GetUpdates() map[string]string {
+	if x != nil {
+		return x.Updates
+	}
+	return nil
+}
+
+func (x *WebhookEvent) GetUpdateFields() *WebhookEvent_UpdateFields {
+	if x != nil {
+		return x.UpdateFields
+	}
+	return nil
+}
+
+// Typed form of updates (Strava sends every value as a string)
+// Unset fields were not in the updates
+type WebhookEvent_UpdateFields struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Title         *string                `protobuf:"bytes,1,opt,name=title,proto3,oneof" json:"title,omitempty"`            // New activity name
+	Type          *string                `protobuf:"bytes,2,opt,name=type,proto3,oneof" json:"type,omitempty"`              // New sport type, e.g. "Ride"
+	Private       *bool                  `protobuf:"varint,3,opt,name=private,proto3,oneof" json:"private,omitempty"`       // true when visible only to the owner
+	Visibility    *string                `protobuf:"bytes,4,opt,name=visibility,proto3,oneof" json:"visibility,omitempty"`  // e.g. "everyone", "followers_only", "only_me"
+	Authorized    *bool                  `protobuf:"varint,5,opt,name=authorized,proto3,oneof" json:"authorized,omitempty"` // false when the athlete revoked access
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WebhookEvent_UpdateFields) Reset() {
+	*x = WebhookEvent_UpdateFields{}
+	mi := &file_webhook_event_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WebhookEvent_UpdateFields) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WebhookEvent_UpdateFields) ProtoMessage() {}
+
+func (x *WebhookEvent_UpdateFields) ProtoReflect() protoreflect.Message {
+	mi := &file_webhook_event_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WebhookEvent_UpdateFields.ProtoReflect.Descriptor instead.
+func (*WebhookEvent_UpdateFields) Descriptor() ([]byte, []int) {
+	return file_webhook_event_proto_rawDescGZIP(), []int{0, 1}
+}
+
+func (x *WebhookEvent_UpdateFields) GetTitle() string {
+	if x != nil && x.Title != nil {
+		return *x.Title
+	}
+	return ""
+}
+
+func (x *WebhookEvent_UpdateFields) GetType() string {
+	if x != nil && x.Type != nil {
+		return *x.Type
+	}
+	return ""
+}
+
+func (x *WebhookEvent_UpdateFields) GetPrivate() bool {
+	if x != nil && x.Private != nil {
+		return *x.Private
+	}
+	return false
+}
+
+func (x *WebhookEvent_UpdateFields) GetVisibility() string {
+	if x != nil && x.Visibility != nil {
+		return *x.Visibility
+	}
+	return ""
+}
+
+func (x *WebhookEvent_UpdateFields) GetAuthorized() bool {
+	if x != nil && x.Authorized != nil {
+		return *x.Authorized
+	}
+	return false
+}
+
+var File_webhook_event_proto protoreflect.FileDescriptor
+
+const file_webhook_event_proto_rawDesc = "" +
+	"\n" +
+	"\x13webhook_event.proto\x12\x15desirelines.events.v1\"\x9a\x05\n" +
+	"\fWebhookEvent\x12\x1f\n" +
+	"\vaspect_type\x18\x01 \x01(\tR\n" +
+	"aspectType\x12\x1f\n" +
+	"\vobject_type\x18\x02 \x01(\tR\n" +
+	"objectType\x12\x1d\n" +
+	"\n" +
+	"event_time\x18\x03 \x01(\x03R\teventTime\x12\x1b\n" +
+	"\tobject_id\x18\x04 \x01(\x03R\bobjectId\x12\x19\n" +
+	"\bowner_id\x18\x05 \x01(\x03R\aownerId\x12'\n" +
+	"\x0fsubscription_id\x18\x06 \x01(\x03R\x0esubscriptionId\x12J\n" +
+	"\aupdates\x18\a \x03(\v20.desirelines.events.v1.WebhookEvent.UpdatesEntryR\aupdates\x12U\n" +
+	"\rupdate_fields\x18\b \x01(\v20.desirelines.events.v1.WebhookEvent.UpdateFieldsR\fupdateFields\x1a:\n" +
+	"\fUpdatesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a\xe8\x01\n" +
+	"\fUpdateFields\x12\x19\n" +
+	"\x05title\x18\x01 \x01(\tH\x00R\x05title\x88\x01\x01\x12\x17\n" +
+	"\x04type\x18\x02 \x01(\tH\x01R\x04type\x88\x01\x01\x12\x1d\n" +
+	"\aprivate\x18\x03 \x01(\bH\x02R\aprivate\x88\x01\x01\x12#\n" +
+	"\n" +
+	"visibility\x18\x04 \x01(\tH\x03R\n" +
+	"visibility\x88\x01\x01\x12#\n" +
+	"\n" +
+	"authorized\x18\x05 \x01(\bH\x04R\n" +
+	"authorized\x88\x01\x01B\b\n" +
+	"\x06_titleB\a\n" +
+	"\x05_typeB\n" +
+	"\n" +
+	"\b_privateB\r\n" +
+	"\v_visibilityB\r\n" +
+	"\v_authorizedB>Z<github.com/andy-esch/desirelines/schemas/generated/go/eventsb\x06proto3"
+
+var (
+	file_webhook_event_proto_rawDescOnce sync.Once
+	file_webhook_event_proto_rawDescData []byte
+)
+
+func file_webhook_event_proto_rawDescGZIP() []byte {
+	file_webhook_event_proto_rawDescOnce.Do(func() {
+		file_webhook_event_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_webhook_event_proto_rawDesc), len(file_webhook_event_proto_rawDesc)))
+	})
+	return file_webhook_event_proto_rawDescData
+}
+
+var file_webhook_event_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_webhook_event_proto_goTypes = []any{
+	(*WebhookEvent)(nil),              // 0: desirelines.events.v1.WebhookEvent
+	nil,                               // 1: desirelines.events.v1.WebhookEvent.UpdatesEntry
+	(*WebhookEvent_UpdateFields)(nil), // 2: desirelines.events.v1.WebhookEvent.UpdateFields
+}
+var file_webhook_event_proto_depIdxs = []int32{
+	1, // 0: desirelines.events.v1.WebhookEvent.updates:type_name -> desirelines.events.v1.WebhookEvent.UpdatesEntry
+	2, // 1: desirelines.events.v1.WebhookEvent.update_fields:type_name -> desirelines.events.v1.WebhookEvent.UpdateFields
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_webhook_event_proto_init() }
+func file_webhook_event_proto_init() {
+	if File_webhook_event_proto != nil {
+		return
+	}
+	file_webhook_event_proto_msgTypes[2].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_webhook_event_proto_rawDesc), len(file_webhook_event_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_webhook_event_proto_goTypes,
+		DependencyIndexes: file_webhook_event_proto_depIdxs,
+		MessageInfos:      file_webhook_event_proto_msgTypes,
+	}.Build()
+	File_webhook_event_proto = out.File
+	file_webhook_event_proto_goTypes = nil
+	file_webhook_event_proto_depIdxs = nil
+}
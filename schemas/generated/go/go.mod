@@ -0,0 +1,5 @@
+module github.com/andy-esch/desirelines/schemas/generated/go
+
+go 1.25
+
+require google.golang.org/protobuf v1.36.6
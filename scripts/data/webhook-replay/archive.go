@@ -0,0 +1,169 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// Archive kinds, matching the dispatcher's archives and their default
+// prefixes.
+const (
+	// ArchiveAudit is the dispatcher's NDJSON audit log of every request.
+	ArchiveAudit = "audit"
+	// ArchiveDeadLetter holds the webhooks the dispatcher failed to publish.
+	ArchiveDeadLetter = "dead-letter"
+)
+
+// auditPublished is the outcome of audit records whose event was published.
+const auditPublished = "published"
+
+// auditRecord is the part of the dispatcher's AuditRecord a replay needs.
+// The audit log doesn't keep a webhook's updates.
+type auditRecord struct {
+	ReceivedAt     time.Time `json:"received_at"`
+	Outcome        string    `json:"outcome"`
+	AspectType     string    `json:"aspect_type"`
+	ObjectType     string    `json:"object_type"`
+	ObjectID       int64     `json:"object_id"`
+	OwnerID        int64     `json:"owner_id"`
+	EventTime      int64     `json:"event_time"`
+	SubscriptionID int       `json:"subscription_id"`
+}
+
+// deadLetterRecord is the part of the dispatcher's DeadLetterRecord a
+// replay needs.
+type deadLetterRecord struct {
+	FailedAt time.Time          `json:"failed_at"`
+	Webhook  StravaWebhookEvent `json:"webhook"`
+}
+
+// archivedEvent is an event read from an archive with the time the
+// dispatcher archived it.
+type archivedEvent struct {
+	At    time.Time
+	Event StravaWebhookEvent
+}
+
+// readArchive returns the events archived between config.StartDate and
+// config.EndDate, ordered by event time. Events archived more than once,
+// e.g. Strava retries, are returned once. Audit records are limited to
+// published activity events: without the updates an athlete event, such as
+// a deauthorization, can't be replayed faithfully.
+func readArchive(ctx context.Context, config *Config) ([]StravaWebhookEvent, error) {
+	start, _ := time.Parse(time.DateOnly, config.StartDate)
+	end, _ := time.Parse(time.DateOnly, config.EndDate)
+
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
+	}
+	defer client.Close()
+	bucket := client.Bucket(config.ArchiveBucket)
+
+	type eventKey struct {
+		objectType, aspectType string
+		objectID, eventTime    int64
+	}
+	seen := map[eventKey]bool{}
+	var events []StravaWebhookEvent
+	var objects int
+	// Objects are filed by the day they were written
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		it := bucket.Objects(ctx, &storage.Query{Prefix: config.ArchivePrefix + day.Format("2006/01/02/")})
+		for {
+			attrs, err := it.Next()
+			if errors.Is(err, iterator.Done) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to list archive objects for %s: %w", day.Format(time.DateOnly), err)
+			}
+			archived, err := readArchiveObject(ctx, bucket.Object(attrs.Name), config.ArchiveKind)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", attrs.Name, err)
+			}
+			objects++
+			for _, a := range archived {
+				if a.At.Before(start) || !a.At.Before(end) {
+					continue
+				}
+				key := eventKey{a.Event.ObjectType, a.Event.AspectType, a.Event.ObjectID, a.Event.EventTime}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				events = append(events, a.Event)
+			}
+		}
+		if config.Verbose {
+			log.Printf("Read archive through %s: %d objects, %d events", day.Format(time.DateOnly), objects, len(events))
+		}
+	}
+	log.Printf("Found %d events in %d archive objects", len(events), objects)
+
+	// Stable, so a create stays ahead of an update with the same event time
+	slices.SortStableFunc(events, func(a, b StravaWebhookEvent) int {
+		return cmp.Compare(a.EventTime, b.EventTime)
+	})
+	return events, nil
+}
+
+// readArchiveObject decodes the records of one archive object: a line per
+// record for the audit log, one record for a dead letter.
+func readArchiveObject(ctx context.Context, object *storage.ObjectHandle, kind string) ([]archivedEvent, error) {
+	r, err := object.NewReader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var events []archivedEvent
+	decoder := json.NewDecoder(r)
+	for {
+		if kind == ArchiveDeadLetter {
+			var record deadLetterRecord
+			if err := decoder.Decode(&record); errors.Is(err, io.EOF) {
+				return events, nil
+			} else if err != nil {
+				return nil, err
+			}
+			if record.Webhook.Updates == nil {
+				record.Webhook.Updates = make(map[string]any)
+			}
+			events = append(events, archivedEvent{At: record.FailedAt, Event: record.Webhook})
+			continue
+		}
+
+		var record auditRecord
+		if err := decoder.Decode(&record); errors.Is(err, io.EOF) {
+			return events, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if record.Outcome != auditPublished || record.ObjectType != "activity" {
+			continue
+		}
+		events = append(events, archivedEvent{
+			At: record.ReceivedAt,
+			Event: StravaWebhookEvent{
+				ObjectType:     record.ObjectType,
+				ObjectID:       record.ObjectID,
+				AspectType:     record.AspectType,
+				OwnerID:        record.OwnerID,
+				SubscriptionID: record.SubscriptionID,
+				EventTime:      record.EventTime,
+				Updates:        make(map[string]any),
+			},
+		})
+	}
+}
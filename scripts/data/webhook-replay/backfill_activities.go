@@ -4,37 +4,136 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"cloud.google.com/go/bigquery"
-	"google.golang.org/api/iterator"
+	"github.com/andy-esch/desirelines/packages/reconcile"
+	"golang.org/x/time/rate"
 )
 
-// Hardcoded constants for prod-only script
 const (
-	sourceProject    = "progressor-341702"
-	sourceDataset    = "strava"
-	sourceTable      = "activities"
-	targetProject    = "desirelines-prod"
-	targetDataset    = "desirelines"
-	targetTable      = "activities"
-	dispatcherURL    = "https://us-central1-desirelines-prod.cloudfunctions.net/desirelines_dispatcher"
-	subscriptionID   = 305683
 	defaultRateLimit = 0.2 // requests per second
+	defaultWorkers   = 4
+
+	defaultRetryAttempts = 3
+	defaultRetryBackoff  = 5 * time.Second
+	// maxRetryBackoff caps the doubling wait between retry rounds.
+	maxRetryBackoff = 5 * time.Minute
+)
+
+// Environment holds the projects, tables and dispatcher one backfill runs
+// against.
+type Environment struct {
+	SourceProject string
+	SourceDataset string
+	SourceTable   string
+	TargetProject string
+	TargetDataset string
+	TargetTable   string
+	DispatcherURL string
+	// Topic is the Pub/Sub topic in TargetProject for -target pubsub.
+	Topic          string
+	SubscriptionID int
+}
+
+// presets are the -env defaults. Dev has no shared Strava app, so its
+// subscription ID must be given with -subscription-id.
+var presets = map[string]Environment{
+	"prod": {
+		SourceProject:  "progressor-341702",
+		SourceDataset:  "strava",
+		SourceTable:    "activities",
+		TargetProject:  "desirelines-prod",
+		TargetDataset:  "desirelines",
+		TargetTable:    "activities",
+		DispatcherURL:  "https://us-central1-desirelines-prod.cloudfunctions.net/desirelines_dispatcher",
+		Topic:          "desirelines_activity_events",
+		SubscriptionID: 305683,
+	},
+	"dev": {
+		SourceProject: "progressor-341702",
+		SourceDataset: "strava",
+		SourceTable:   "activities",
+		TargetProject: "desirelines-dev",
+		TargetDataset: "desirelines",
+		TargetTable:   "activities",
+		DispatcherURL: "https://us-central1-desirelines-dev.cloudfunctions.net/desirelines_dispatcher",
+		Topic:         "desirelines_activity_events",
+	},
+}
+
+// Backfill targets, selecting where events are sent.
+const (
+	// TargetDispatcher posts events to the dispatcher like Strava does.
+	TargetDispatcher = "dispatcher"
+	// TargetPubSub publishes events straight to the dispatcher's topic.
+	TargetPubSub = "pubsub"
+)
+
+var (
+	// identifierPattern matches project, dataset and table names, which are
+	// interpolated into the query.
+	identifierPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	// topicPattern matches Pub/Sub topic IDs.
+	topicPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._~+%-]{2,254}$`)
+)
+
+// Commands, selecting where events come from.
+const (
+	// CommandBackfill replays activities missing from the target table.
+	CommandBackfill = "backfill"
+	// CommandArchive replays events from the dispatcher's GCS archives.
+	CommandArchive = "archive"
 )
 
 // Config holds the script configuration
 type Config struct {
+	Environment
+	// Command is CommandBackfill or CommandArchive.
+	Command string
+	Env     string
+	// Target is TargetDispatcher or TargetPubSub.
+	Target string
+	// Batch is BatchMonth, BatchYear or BatchNone.
+	Batch     string
 	StartDate string
 	EndDate   string
 	Limit     int
 	DryRun    bool
 	Verbose   bool
+	// Progress draws a progress bar when stderr is a terminal.
+	Progress  bool
 	RateLimit float64
+	// Workers is how many requests may be in flight at once; RateLimit
+	// still caps how fast they start.
+	Workers int
+	// RetryAttempts is how many times failed events are retried after the
+	// first pass, waiting RetryBackoff before the first retry and twice as
+	// long before each next one.
+	RetryAttempts int
+	RetryBackoff  time.Duration
+	// Checkpoint is a local path or gs:// URL where progress is saved so an
+	// interrupted run can resume; empty disables checkpointing.
+	Checkpoint string
+	// ReplayToken authenticates to the dispatcher's X-Replay-Token check.
+	ReplayToken string
+	// ArchiveBucket, ArchivePrefix and ArchiveKind locate the archive the
+	// archive command reads; ArchiveKind is ArchiveAudit or
+	// ArchiveDeadLetter.
+	ArchiveBucket string
+	ArchivePrefix string
+	ArchiveKind   string
 }
 
 // StravaWebhookEvent represents the webhook payload format
@@ -48,137 +147,343 @@ type StravaWebhookEvent struct {
 	Updates        map[string]any `json:"updates"` // Empty dict for "create" events
 }
 
-// ActivityRow represents an activity from BigQuery
-type ActivityRow struct {
-	ID        int64     `bigquery:"missing_activity_id"`
-	AthleteID int64     `bigquery:"athlete_id"`
-	StartDate time.Time `bigquery:"start_date"`
-}
-
 func main() {
-	config := parseFlags()
-
-	ctx := context.Background()
-
-	// Phase 1: Query for missing activities (gets all details in one query)
-	log.Println("Phase 1: Querying for missing activities...")
-	activities, err := queryMissingActivities(ctx, config)
+	config, err := parseFlags(os.Args[1:])
 	if err != nil {
-		log.Fatalf("Failed to query missing activities: %v", err)
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// An interrupt stops at the end of the current batch, or at once
+	// without batches or on a second interrupt; in-flight posts finish and
+	// the checkpoint is saved on the way out
+	ctx, stopping := handleInterrupts(config.Batch != BatchNone)
+
+	var events []StravaWebhookEvent
+	var state *checkpoint
+	if config.Command == CommandArchive {
+		log.Printf("Replaying %s archive gs://%s/%s to %s", config.ArchiveKind, config.ArchiveBucket, config.ArchivePrefix, config.destination())
+		log.Println("Phase 1: Reading archived events...")
+		events, err = readArchive(ctx, config)
+		if err != nil {
+			log.Fatalf("Failed to read archive: %v", err)
+		}
+	} else {
+		log.Printf("Backfilling %s: %s.%s.%s -> %s.%s.%s via %s (subscription %d)",
+			config.Env, config.SourceProject, config.SourceDataset, config.SourceTable,
+			config.TargetProject, config.TargetDataset, config.TargetTable,
+			config.destination(), config.SubscriptionID)
+		log.Println("Phase 1: Querying for missing activities...")
+		events, state, err = missingActivityEvents(ctx, config)
+		if err != nil {
+			log.Fatalf("Failed to query missing activities: %v", err)
+		}
 	}
 
-	if len(activities) == 0 {
-		log.Println("No missing activities found. Exiting.")
+	if len(events) == 0 {
+		log.Println("No events to replay. Exiting.")
 		return
 	}
 
-	log.Printf("Found %d missing activities", len(activities))
+	batches := batchEvents(events, config.Batch)
 
 	if config.DryRun {
-		log.Println("Dry run mode - would process the following activities:")
-		for i, activity := range activities {
+		log.Printf("Dry run mode - would replay the following events in %d batches:", len(batches))
+		for _, batch := range batches {
+			log.Printf("  %s: %d events", batch.Label, len(batch.Events))
+		}
+		for i, event := range events {
 			if i >= 10 {
-				log.Printf("... and %d more", len(activities)-10)
+				log.Printf("... and %d more", len(events)-10)
 				break
 			}
-			log.Printf("  - Activity ID %d (athlete %d, date %s)",
-				activity.ID, activity.AthleteID, activity.StartDate.Format("2006-01-02"))
+			log.Printf("  - %s %s %d (athlete %d, %s)", event.AspectType, event.ObjectType,
+				event.ObjectID, event.OwnerID, time.Unix(event.EventTime, 0).UTC().Format("2006-01-02"))
 		}
 		return
 	}
 
-	// Phase 2: Transform to webhook events
-	log.Println("Phase 2: Transforming to webhook events...")
-	events := transformToWebhookEvents(activities)
-
-	// Phase 3: Replay webhooks
-	log.Println("Phase 3: Replaying webhook events...")
-	if err := replayWebhooks(ctx, config, events); err != nil {
+	// Phase 2: Replay webhooks batch by batch
+	log.Printf("Phase 2: Replaying webhook events in %d batches...", len(batches))
+	send, err := newSender(ctx, config)
+	if err != nil {
+		log.Fatalf("Failed to create %s sender: %v", config.Target, err)
+	}
+	r := &replayer{
+		config:     config,
+		limiter:    rate.NewLimiter(rate.Limit(config.RateLimit), 1),
+		send:       send,
+		checkpoint: state,
+		bar:        newProgressBar(config, len(events)),
+	}
+	err = r.runBatches(ctx, stopping, batches)
+	r.bar.finish()
+	if err := send.Close(); err != nil {
+		log.Printf("Failed to close %s sender: %v", config.Target, err)
+	}
+	if state != nil {
+		if err := state.Close(context.WithoutCancel(ctx)); err != nil {
+			log.Printf("Failed to close checkpoint: %v", err)
+		}
+	}
+	if err != nil {
 		log.Fatalf("Failed to replay webhooks: %v", err)
 	}
 
 	log.Printf("Successfully replayed %d webhook events", len(events))
 }
 
-func parseFlags() *Config {
-	config := &Config{}
-
-	flag.StringVar(&config.StartDate, "start-date", "", "Start date (YYYY-MM-DD)")
-	flag.StringVar(&config.EndDate, "end-date", "", "End date (YYYY-MM-DD)")
-	flag.IntVar(&config.Limit, "limit", 0, "Max activities to process (0 = unlimited)")
-	flag.BoolVar(&config.DryRun, "dry-run", false, "Preview without executing")
-	flag.BoolVar(&config.Verbose, "verbose", false, "Verbose logging")
-	flag.Float64Var(&config.RateLimit, "rate-limit", defaultRateLimit, "Requests per second")
-
-	flag.Parse()
+// missingActivityEvents returns create events for the activities missing
+// from the target table, less those the checkpoint has seen replayed. The
+// checkpoint is nil when disabled.
+func missingActivityEvents(ctx context.Context, config *Config) ([]StravaWebhookEvent, *checkpoint, error) {
+	activities, err := queryMissingActivities(ctx, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("Found %d missing activities", len(activities))
 
-	return config
+	var state *checkpoint
+	if config.Checkpoint != "" && len(activities) > 0 {
+		state, err = openCheckpoint(ctx, config.Checkpoint, config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open checkpoint: %w", err)
+		}
+		activities = state.remaining(activities)
+		log.Printf("%d activities left after checkpoint", len(activities))
+	}
+	return transformToWebhookEvents(activities, config.SubscriptionID), state, nil
 }
 
-func queryMissingActivities(ctx context.Context, config *Config) ([]ActivityRow, error) {
-	client, err := bigquery.NewClient(ctx, sourceProject)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create BigQuery client: %w", err)
+// parseFlags reads the configuration from args, which start with the
+// command name for commands other than CommandBackfill. Each environment
+// setting comes from its flag, then its BACKFILL_* variable, then the -env
+// preset.
+func parseFlags(args []string) (*Config, error) {
+	config := &Config{Command: CommandBackfill}
+	if len(args) > 0 && args[0] == CommandArchive {
+		config.Command, args = CommandArchive, args[1:]
 	}
-	defer client.Close()
 
-	// Single query gets all needed data: ID, athlete_id, start_date
-	query := fmt.Sprintf(`
-		SELECT
-			pr.id as missing_activity_id,
-			pr.athlete.id as athlete_id,
-			pr.start_date
-		FROM %s.%s.%s AS pr
-		LEFT JOIN %s.%s.%s AS de
-		ON pr.id = de.id
-		WHERE de.id IS NULL
-	`, sourceProject, sourceDataset, sourceTable,
-		targetProject, targetDataset, targetTable)
-
-	// Add date filters if provided
-	if config.StartDate != "" && config.EndDate != "" {
-		query += fmt.Sprintf(`
-			AND pr.start_date >= '%s'
-			AND pr.start_date < '%s'
-		`, config.StartDate, config.EndDate)
-	} else if config.StartDate != "" {
-		query += fmt.Sprintf("\n\t\t\tAND pr.start_date >= '%s'", config.StartDate)
-	} else if config.EndDate != "" {
-		query += fmt.Sprintf("\n\t\t\tAND pr.start_date < '%s'", config.EndDate)
-	}
-
-	// Add limit if provided
-	if config.Limit > 0 {
-		query += fmt.Sprintf("\n\t\tLIMIT %d", config.Limit)
+	flags := flag.NewFlagSet(config.Command, flag.ExitOnError)
+	flags.StringVar(&config.Env, "env", envOrDefault("BACKFILL_ENV", "prod"), "Preset for the settings below: dev or prod (default $BACKFILL_ENV or prod)")
+	flags.StringVar(&config.TargetProject, "target-project", os.Getenv("BACKFILL_TARGET_PROJECT"), "Project of the desirelines activities table and topic")
+	flags.StringVar(&config.Target, "target", envOrDefault("BACKFILL_TARGET", TargetDispatcher), "Where to send events: dispatcher or pubsub (default $BACKFILL_TARGET or dispatcher)")
+	flags.StringVar(&config.DispatcherURL, "dispatcher-url", os.Getenv("BACKFILL_DISPATCHER_URL"), "Dispatcher webhook URL")
+	flags.StringVar(&config.Topic, "topic", os.Getenv("BACKFILL_TOPIC"), "Pub/Sub topic in -target-project for -target pubsub")
+	var subscriptionID string
+	flags.StringVar(&subscriptionID, "subscription-id", os.Getenv("BACKFILL_SUBSCRIPTION_ID"), "Strava webhook subscription ID the dispatcher accepts")
+	flags.BoolVar(&config.DryRun, "dry-run", false, "Preview without executing")
+	flags.BoolVar(&config.Verbose, "verbose", false, "Verbose logging")
+	flags.StringVar(&config.Batch, "batch", envOrDefault("BACKFILL_BATCH", BatchMonth), "Replay in batches of events by event date: month, year or none (default $BACKFILL_BATCH or month)")
+	flags.BoolVar(&config.Progress, "progress", true, "Show a progress bar when stderr is a terminal")
+	flags.Float64Var(&config.RateLimit, "rate-limit", defaultRateLimit, "Requests per second")
+	flags.IntVar(&config.Workers, "workers", defaultWorkers, "Concurrent requests")
+	flags.IntVar(&config.RetryAttempts, "retry-attempts", defaultRetryAttempts, "Times to retry failed events after the first pass (0 = no retries)")
+	flags.DurationVar(&config.RetryBackoff, "retry-backoff", defaultRetryBackoff, "Wait before the first retry, doubling for each next one")
+	flags.StringVar(&config.ReplayToken, "replay-token", os.Getenv("REPLAY_TOKEN"), "Dispatcher replay token (default $REPLAY_TOKEN)")
+
+	if config.Command == CommandArchive {
+		flags.StringVar(&config.StartDate, "start-date", "", "First day of the archive to read (YYYY-MM-DD, UTC)")
+		flags.StringVar(&config.EndDate, "end-date", "", "Day after the last day to read (YYYY-MM-DD, UTC; default tomorrow)")
+		flags.StringVar(&config.ArchiveBucket, "bucket", os.Getenv("BACKFILL_ARCHIVE_BUCKET"), "Bucket of the dispatcher's AUDIT_BUCKET or DEAD_LETTER_BUCKET")
+		flags.StringVar(&config.ArchiveKind, "kind", envOrDefault("BACKFILL_ARCHIVE_KIND", ArchiveAudit), "Archive to read: audit or dead-letter (default $BACKFILL_ARCHIVE_KIND or audit)")
+		flags.StringVar(&config.ArchivePrefix, "prefix", os.Getenv("BACKFILL_ARCHIVE_PREFIX"), "Object prefix of the archive (default audit/ or dead-letter/)")
+	} else {
+		flags.StringVar(&config.SourceProject, "source-project", os.Getenv("BACKFILL_SOURCE_PROJECT"), "Project of the legacy activities table")
+		flags.StringVar(&config.SourceDataset, "source-dataset", os.Getenv("BACKFILL_SOURCE_DATASET"), "Dataset of the legacy activities table")
+		flags.StringVar(&config.SourceTable, "source-table", os.Getenv("BACKFILL_SOURCE_TABLE"), "Legacy activities table")
+		flags.StringVar(&config.TargetDataset, "target-dataset", os.Getenv("BACKFILL_TARGET_DATASET"), "Dataset of the desirelines activities table")
+		flags.StringVar(&config.TargetTable, "target-table", os.Getenv("BACKFILL_TARGET_TABLE"), "Desirelines activities table")
+		flags.StringVar(&config.StartDate, "start-date", "", "Start date (YYYY-MM-DD)")
+		flags.StringVar(&config.EndDate, "end-date", "", "End date (YYYY-MM-DD)")
+		flags.IntVar(&config.Limit, "limit", 0, "Max activities to process (0 = unlimited)")
+		flags.StringVar(&config.Checkpoint, "checkpoint", os.Getenv("BACKFILL_CHECKPOINT"), "Local path or gs://bucket/object to save progress to and resume from")
 	}
 
-	if config.Verbose {
-		log.Printf("Query:\n%s\n", query)
+	_ = flags.Parse(args)
+
+	preset, ok := presets[config.Env]
+	if !ok {
+		return nil, fmt.Errorf("unknown -env %q (want dev or prod)", config.Env)
+	}
+	if subscriptionID != "" {
+		id, err := strconv.Atoi(subscriptionID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -subscription-id %q", subscriptionID)
+		}
+		config.SubscriptionID = id
+	}
+	config.Environment = config.withDefaults(preset)
+	if config.Command == CommandArchive {
+		if config.ArchivePrefix == "" {
+			config.ArchivePrefix = config.ArchiveKind + "/"
+		}
+		if config.EndDate == "" {
+			config.EndDate = time.Now().UTC().AddDate(0, 0, 1).Format(time.DateOnly)
+		}
 	}
 
-	q := client.Query(query)
-	it, err := q.Read(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %w", err)
+	return config, config.validate()
+}
+
+// withDefaults returns the configured environment with unset settings taken
+// from preset.
+func (c *Config) withDefaults(preset Environment) Environment {
+	env := c.Environment
+	for _, field := range []struct {
+		value    *string
+		fallback string
+	}{
+		{&env.SourceProject, preset.SourceProject},
+		{&env.SourceDataset, preset.SourceDataset},
+		{&env.SourceTable, preset.SourceTable},
+		{&env.TargetProject, preset.TargetProject},
+		{&env.TargetDataset, preset.TargetDataset},
+		{&env.TargetTable, preset.TargetTable},
+		{&env.DispatcherURL, preset.DispatcherURL},
+		{&env.Topic, preset.Topic},
+	} {
+		if *field.value == "" {
+			*field.value = field.fallback
+		}
 	}
+	if env.SubscriptionID == 0 {
+		env.SubscriptionID = preset.SubscriptionID
+	}
+	return env
+}
 
-	var activities []ActivityRow
-	for {
-		var row ActivityRow
-		err := it.Next(&row)
-		if err == iterator.Done {
-			break
+// validate reports every invalid setting at once.
+func (c *Config) validate() error {
+	var errs []error
+	if c.Command == CommandArchive {
+		errs = append(errs, c.validateArchive()...)
+	} else {
+		errs = append(errs, c.validateBackfill()...)
+	}
+	for _, setting := range []struct{ name, value string }{
+		{"start-date", c.StartDate},
+		{"end-date", c.EndDate},
+	} {
+		if _, err := time.Parse(time.DateOnly, setting.value); setting.value != "" && err != nil {
+			errs = append(errs, fmt.Errorf("invalid -%s %q (want YYYY-MM-DD)", setting.name, setting.value))
 		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to read query results: %w", err)
+	}
+	if !identifierPattern.MatchString(c.TargetProject) {
+		errs = append(errs, fmt.Errorf("invalid -target-project %q", c.TargetProject))
+	}
+	switch c.Target {
+	case TargetDispatcher:
+		if u, err := url.Parse(c.DispatcherURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("invalid -dispatcher-url %q", c.DispatcherURL))
 		}
-		activities = append(activities, row)
+	case TargetPubSub:
+		if !topicPattern.MatchString(c.Topic) {
+			errs = append(errs, fmt.Errorf("invalid -topic %q", c.Topic))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown -target %q (want %s or %s)", c.Target, TargetDispatcher, TargetPubSub))
+	}
+	if c.RateLimit <= 0 {
+		errs = append(errs, fmt.Errorf("-rate-limit must be positive"))
+	}
+	if c.Workers <= 0 {
+		errs = append(errs, fmt.Errorf("-workers must be positive"))
+	}
+	switch c.Batch {
+	case BatchMonth, BatchYear, BatchNone:
+	default:
+		errs = append(errs, fmt.Errorf("unknown -batch %q (want %s, %s or %s)", c.Batch, BatchMonth, BatchYear, BatchNone))
+	}
+	if c.RetryAttempts < 0 {
+		errs = append(errs, fmt.Errorf("-retry-attempts must not be negative"))
+	}
+	if c.RetryBackoff <= 0 {
+		errs = append(errs, fmt.Errorf("-retry-backoff must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// validateBackfill checks the settings of CommandBackfill.
+func (c *Config) validateBackfill() []error {
+	var errs []error
+	for _, setting := range []struct{ name, value string }{
+		{"source-project", c.SourceProject},
+		{"source-dataset", c.SourceDataset},
+		{"source-table", c.SourceTable},
+		{"target-dataset", c.TargetDataset},
+		{"target-table", c.TargetTable},
+	} {
+		if !identifierPattern.MatchString(setting.value) {
+			errs = append(errs, fmt.Errorf("invalid -%s %q", setting.name, setting.value))
+		}
+	}
+	if c.SubscriptionID <= 0 {
+		errs = append(errs, fmt.Errorf("-subscription-id is required for -env %s", c.Env))
+	}
+	return errs
+}
+
+// validateArchive checks the settings of CommandArchive. Archived events
+// carry their own subscription ID, so none is required.
+func (c *Config) validateArchive() []error {
+	var errs []error
+	if c.ArchiveBucket == "" {
+		errs = append(errs, fmt.Errorf("-bucket is required"))
+	}
+	switch c.ArchiveKind {
+	case ArchiveAudit, ArchiveDeadLetter:
+	default:
+		errs = append(errs, fmt.Errorf("unknown -kind %q (want %s or %s)", c.ArchiveKind, ArchiveAudit, ArchiveDeadLetter))
+	}
+	if c.StartDate == "" {
+		errs = append(errs, fmt.Errorf("-start-date is required"))
+	} else if c.EndDate <= c.StartDate {
+		// Both are YYYY-MM-DD, so they order as strings
+		errs = append(errs, fmt.Errorf("-end-date must be after -start-date"))
+	}
+	return errs
+}
+
+// destination names where events are sent.
+func (c *Config) destination() string {
+	if c.Target == TargetPubSub {
+		return fmt.Sprintf("projects/%s/topics/%s", c.TargetProject, c.Topic)
+	}
+	return c.DispatcherURL
+}
+
+// envOrDefault returns the environment variable key, or fallback when unset.
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// queryMissingActivities returns the source activities missing from the
+// target table, oldest first.
+func queryMissingActivities(ctx context.Context, config *Config) ([]reconcile.Activity, error) {
+	client, err := bigquery.NewClient(ctx, config.SourceProject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create BigQuery client: %w", err)
 	}
+	defer client.Close()
 
-	return activities, nil
+	query := reconcile.Query{
+		Source:    reconcile.Table{Project: config.SourceProject, Dataset: config.SourceDataset, Table: config.SourceTable},
+		Target:    reconcile.Table{Project: config.TargetProject, Dataset: config.TargetDataset, Table: config.TargetTable},
+		StartDate: config.StartDate,
+		EndDate:   config.EndDate,
+		Limit:     config.Limit,
+	}
+	if config.Verbose {
+		log.Printf("Query:\n%s\n", query.SQL())
+	}
+	return reconcile.MissingActivities(ctx, client, query)
 }
 
-func transformToWebhookEvents(activities []ActivityRow) []StravaWebhookEvent {
+func transformToWebhookEvents(activities []reconcile.Activity, subscriptionID int) []StravaWebhookEvent {
 	events := make([]StravaWebhookEvent, len(activities))
 	for i, activity := range activities {
 		events[i] = StravaWebhookEvent{
@@ -194,57 +499,152 @@ func transformToWebhookEvents(activities []ActivityRow) []StravaWebhookEvent {
 	return events
 }
 
-func replayWebhooks(ctx context.Context, config *Config, events []StravaWebhookEvent) error {
-	// Calculate delay between requests based on rate limit
-	delayBetweenRequests := time.Duration(float64(time.Second) / config.RateLimit)
-
-	successCount := 0
-	errorCount := 0
-
-	for i, event := range events {
-		if err := postWebhook(ctx, event); err != nil {
-			log.Printf("Error posting webhook for activity %d: %v", event.ObjectID, err)
-			errorCount++
-		} else {
-			successCount++
-			if config.Verbose {
-				log.Printf("[%d/%d] Posted webhook for activity %d", i+1, len(events), event.ObjectID)
-			}
+// sender delivers replayed events to the backfill target.
+type sender interface {
+	Send(ctx context.Context, event StravaWebhookEvent) error
+	Close() error
+}
+
+// newSender returns the sender for config.Target.
+func newSender(ctx context.Context, config *Config) (sender, error) {
+	if config.Target == TargetPubSub {
+		return newPubSubSender(ctx, config.TargetProject, config.Topic)
+	}
+	return &dispatcherSender{
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		url:         config.DispatcherURL,
+		replayToken: config.ReplayToken,
+	}, nil
+}
+
+// replayer sends events to the backfill target. The rate limiter is shared
+// by every batch and retry.
+type replayer struct {
+	config  *Config
+	limiter *rate.Limiter
+	send    sender
+	// checkpoint and bar are nil when disabled.
+	checkpoint *checkpoint
+	bar        *progressBar
+}
+
+// replay sends events, then retries the failures up to
+// config.RetryAttempts times with exponential backoff, so a transient outage
+// doesn't fail the run. Events that still fail are listed at the end.
+func (r *replayer) replay(ctx context.Context, events []StravaWebhookEvent) error {
+	config := r.config
+	failed := r.sendAll(ctx, events)
+	log.Printf("Replay complete: %d successful, %d errors", len(events)-len(failed), len(failed))
+
+	backoff := config.RetryBackoff
+	for attempt := 1; attempt <= config.RetryAttempts && len(failed) > 0 && ctx.Err() == nil; attempt++ {
+		log.Printf("Retry %d/%d: %d failed events in %s", attempt, config.RetryAttempts, len(failed), backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
 		}
+		retried := len(failed)
+		failed = r.sendAll(ctx, failed)
+		log.Printf("Retry %d/%d: %d recovered, %d still failing", attempt, config.RetryAttempts, retried-len(failed), len(failed))
+		backoff = min(2*backoff, maxRetryBackoff)
+	}
 
-		// Rate limiting: sleep between requests (except after last one)
-		if i < len(events)-1 {
-			time.Sleep(delayBetweenRequests)
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("interrupted with %d failed events: %w", len(failed), err)
+	}
+	if len(failed) > 0 {
+		log.Printf("Still failed after %d retries:", config.RetryAttempts)
+		for _, event := range failed {
+			log.Printf("  - %s %s %d (athlete %d)", event.AspectType, event.ObjectType, event.ObjectID, event.OwnerID)
 		}
+		return fmt.Errorf("%d of %d events still failed", len(failed), len(events))
 	}
 
-	log.Printf("Replay complete: %d successful, %d errors", successCount, errorCount)
+	return nil
+}
 
-	if errorCount > 0 {
-		return fmt.Errorf("encountered %d errors during replay", errorCount)
+// sendAll sends events from a pool of workers, so slow responses overlap
+// instead of adding to the run time. It returns the events that failed;
+// events left unsent by an interrupt are not included.
+func (r *replayer) sendAll(ctx context.Context, events []StravaWebhookEvent) []StravaWebhookEvent {
+	var successCount atomic.Int64
+	var failed []StravaWebhookEvent
+	var mu sync.Mutex
+	jobs := make(chan StravaWebhookEvent)
+	var wg sync.WaitGroup
+	for range min(r.config.Workers, len(events)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for event := range jobs {
+				if err := r.limiter.Wait(ctx); err != nil {
+					return
+				}
+				if err := r.send.Send(ctx, event); err != nil {
+					log.Printf("Error sending webhook for %s %d: %v", event.ObjectType, event.ObjectID, err)
+					r.bar.failed()
+					mu.Lock()
+					failed = append(failed, event)
+					mu.Unlock()
+					continue
+				}
+				if r.checkpoint != nil {
+					r.checkpoint.done(ctx, event.ObjectID)
+				}
+				r.bar.succeeded()
+				done := successCount.Add(1)
+				if r.config.Verbose {
+					log.Printf("[%d/%d] Sent webhook for %s %d", done, len(events), event.ObjectType, event.ObjectID)
+				}
+			}
+		}()
 	}
 
-	return nil
+feed:
+	for _, event := range events {
+		select {
+		case jobs <- event:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return failed
+}
+
+// dispatcherSender posts events to the dispatcher's webhook endpoint.
+type dispatcherSender struct {
+	client      *http.Client
+	url         string
+	replayToken string
 }
 
-func postWebhook(ctx context.Context, event StravaWebhookEvent) error {
+// Send implements the sender interface.
+func (d *dispatcherSender) Send(ctx context.Context, event StravaWebhookEvent) error {
 	payload, err := json.Marshal(event)
 	if err != nil {
 		return fmt.Errorf("failed to marshal webhook event: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", dispatcherURL, bytes.NewBuffer(payload))
+	req, err := http.NewRequestWithContext(ctx, "POST", d.url, bytes.NewBuffer(payload))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+	if d.replayToken != "" {
+		// Tags the events source=replay and lets them past the IP allowlist
+		req.Header.Set("X-Replay-Token", d.replayToken)
 	}
 
-	resp, err := client.Do(req)
+	resp, err := d.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to post webhook: %w", err)
 	}
@@ -256,3 +656,8 @@ func postWebhook(ctx context.Context, event StravaWebhookEvent) error {
 
 	return nil
 }
+
+// Close implements the sender interface.
+func (d *dispatcherSender) Close() error {
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// fakeSender fails the first failures sends of each event, or only of the
+// events in failing when set, and records every event it delivers.
+type fakeSender struct {
+	failures int
+	failing  map[int64]bool
+	attempts map[int64]int
+	sent     []int64
+	mu       sync.Mutex
+}
+
+func (f *fakeSender) Send(ctx context.Context, event StravaWebhookEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.attempts == nil {
+		f.attempts = map[int64]int{}
+	}
+	f.attempts[event.ObjectID]++
+	if (f.failing == nil || f.failing[event.ObjectID]) && f.attempts[event.ObjectID] <= f.failures {
+		return errors.New("service unavailable")
+	}
+	f.sent = append(f.sent, event.ObjectID)
+	return nil
+}
+
+func (f *fakeSender) Close() error {
+	return nil
+}
+
+func newTestReplayer(send sender, retryAttempts int) *replayer {
+	return &replayer{
+		config: &Config{
+			Workers:       2,
+			RetryAttempts: retryAttempts,
+			RetryBackoff:  time.Millisecond,
+		},
+		limiter: rate.NewLimiter(rate.Inf, 1),
+		send:    send,
+	}
+}
+
+func testEvents(ids ...int64) []StravaWebhookEvent {
+	events := make([]StravaWebhookEvent, len(ids))
+	for i, id := range ids {
+		events[i] = StravaWebhookEvent{ObjectType: "activity", ObjectID: id, AspectType: "create"}
+	}
+	return events
+}
+
+func TestReplayerReplay(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      int
+		retryAttempts int
+		wantErr       string
+		wantAttempts  int
+	}{
+		{name: "no failures", retryAttempts: 3, wantAttempts: 1},
+		{name: "recovered on retry", failures: 2, retryAttempts: 3, wantAttempts: 3},
+		{name: "recovered on the last retry", failures: 3, retryAttempts: 3, wantAttempts: 4},
+		{name: "still failing after retries", failures: 4, retryAttempts: 3, wantErr: "3 of 3 events still failed", wantAttempts: 4},
+		{name: "retries disabled", failures: 1, retryAttempts: 0, wantErr: "3 of 3 events still failed", wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			send := &fakeSender{failures: tt.failures}
+			r := newTestReplayer(send, tt.retryAttempts)
+
+			err := r.replay(context.Background(), testEvents(1, 2, 3))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("replay failed: %v", err)
+			}
+
+			for id := int64(1); id <= 3; id++ {
+				if got := send.attempts[id]; got != tt.wantAttempts {
+					t.Errorf("expected %d attempts for event %d, got %d", tt.wantAttempts, id, got)
+				}
+			}
+			if tt.wantErr == "" && len(send.sent) != 3 {
+				t.Errorf("expected 3 events delivered, got %v", send.sent)
+			}
+		})
+	}
+}
+
+func TestReplayerReplay_RetriesOnlyFailures(t *testing.T) {
+	send := &fakeSender{failures: 1, failing: map[int64]bool{2: true}}
+	r := newTestReplayer(send, 2)
+
+	if err := r.replay(context.Background(), testEvents(1, 2, 3)); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if send.attempts[1] != 1 || send.attempts[3] != 1 {
+		t.Errorf("expected delivered events to be sent once, got %v", send.attempts)
+	}
+	if send.attempts[2] != 2 {
+		t.Errorf("expected the failed event to be retried once, got %d attempts", send.attempts[2])
+	}
+}
+
+func TestReplayerReplay_InterruptedDuringBackoff(t *testing.T) {
+	send := &fakeSender{failures: 1}
+	r := newTestReplayer(send, 3)
+	r.config.RetryBackoff = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	err := r.replay(ctx, testEvents(1))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancelled replay, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "interrupted with 1 failed events") {
+		t.Errorf("expected the failed count in the error, got %v", err)
+	}
+	if send.attempts[1] != 1 {
+		t.Errorf("expected no retry after the interrupt, got %d attempts", send.attempts[1])
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Batch sizes, grouping events by event time, which for backfilled
+// activities is the start date.
+const (
+	BatchMonth = "month"
+	BatchYear  = "year"
+	// BatchNone replays every event in one batch.
+	BatchNone = "none"
+)
+
+// eventBatch is a run of events replayed together, e.g. one month.
+type eventBatch struct {
+	Label  string
+	Events []StravaWebhookEvent
+}
+
+// batchEvents splits events, ordered by event time, into batches of size.
+func batchEvents(events []StravaWebhookEvent, size string) []eventBatch {
+	var batches []eventBatch
+	for _, event := range events {
+		label := "all"
+		switch size {
+		case BatchMonth:
+			label = time.Unix(event.EventTime, 0).UTC().Format("2006-01")
+		case BatchYear:
+			label = time.Unix(event.EventTime, 0).UTC().Format("2006")
+		}
+		if n := len(batches); n > 0 && batches[n-1].Label == label {
+			batches[n-1].Events = append(batches[n-1].Events, event)
+			continue
+		}
+		batches = append(batches, eventBatch{Label: label, Events: []StravaWebhookEvent{event}})
+	}
+	return batches
+}
+
+// runBatches replays batches in order, logging a summary of each. Batches
+// after a failed one still run; once stopping is closed no further batch
+// starts.
+func (r *replayer) runBatches(ctx context.Context, stopping <-chan struct{}, batches []eventBatch) error {
+	var errs []error
+	for i, batch := range batches {
+		select {
+		case <-stopping:
+			return errors.Join(append(errs, fmt.Errorf("stopped after %d of %d batches", i, len(batches)))...)
+		default:
+		}
+
+		started := time.Now()
+		log.Printf("Batch %s (%d/%d): %d events", batch.Label, i+1, len(batches), len(batch.Events))
+		err := r.replay(ctx, batch.Events)
+		log.Printf("Batch %s done in %s", batch.Label, time.Since(started).Round(time.Second))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("batch %s: %w", batch.Label, err))
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// handleInterrupts returns a context cancelled by an interrupt and a
+// channel closed by one. With batches the first interrupt only closes the
+// channel, so the current batch can finish, and a second cancels the
+// context.
+func handleInterrupts(batched bool) (context.Context, <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopping := make(chan struct{})
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stopping)
+		if batched {
+			log.Println("Interrupted: stopping after the current batch (interrupt again to stop now)")
+			<-signals
+		}
+		log.Println("Interrupted: stopping now")
+		cancel()
+	}()
+	return ctx, stopping
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func eventAt(id int64, date string) StravaWebhookEvent {
+	eventTime, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		panic(err)
+	}
+	return StravaWebhookEvent{ObjectType: "activity", ObjectID: id, AspectType: "create", EventTime: eventTime.Unix()}
+}
+
+func TestBatchEvents(t *testing.T) {
+	events := []StravaWebhookEvent{
+		eventAt(1, "2023-12-31"),
+		eventAt(2, "2024-01-01"),
+		eventAt(3, "2024-01-31"),
+		eventAt(4, "2024-02-01"),
+	}
+
+	tests := []struct {
+		name string
+		size string
+		want map[string][]int64
+		// labels is the expected batch order.
+		labels []string
+	}{
+		{
+			name:   "month",
+			size:   BatchMonth,
+			labels: []string{"2023-12", "2024-01", "2024-02"},
+			want:   map[string][]int64{"2023-12": {1}, "2024-01": {2, 3}, "2024-02": {4}},
+		},
+		{
+			name:   "year",
+			size:   BatchYear,
+			labels: []string{"2023", "2024"},
+			want:   map[string][]int64{"2023": {1}, "2024": {2, 3, 4}},
+		},
+		{
+			name:   "none",
+			size:   BatchNone,
+			labels: []string{"all"},
+			want:   map[string][]int64{"all": {1, 2, 3, 4}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batches := batchEvents(events, tt.size)
+
+			var labels []string
+			for _, batch := range batches {
+				labels = append(labels, batch.Label)
+				var ids []int64
+				for _, event := range batch.Events {
+					ids = append(ids, event.ObjectID)
+				}
+				if !slices.Equal(ids, tt.want[batch.Label]) {
+					t.Errorf("batch %s: expected events %v, got %v", batch.Label, tt.want[batch.Label], ids)
+				}
+			}
+			if !slices.Equal(labels, tt.labels) {
+				t.Errorf("expected batches %v, got %v", tt.labels, labels)
+			}
+		})
+	}
+
+	if batches := batchEvents(nil, BatchMonth); len(batches) != 0 {
+		t.Errorf("expected no batches without events, got %v", batches)
+	}
+}
+
+func TestRunBatches(t *testing.T) {
+	batches := []eventBatch{
+		{Label: "2024-01", Events: testEvents(1)},
+		{Label: "2024-02", Events: testEvents(2)},
+		{Label: "2024-03", Events: testEvents(3)},
+	}
+
+	t.Run("all batches succeed", func(t *testing.T) {
+		send := &fakeSender{}
+		r := newTestReplayer(send, 0)
+
+		if err := r.runBatches(context.Background(), make(chan struct{}), batches); err != nil {
+			t.Fatalf("runBatches failed: %v", err)
+		}
+		if !slices.Equal(send.sent, []int64{1, 2, 3}) {
+			t.Errorf("expected events sent in batch order, got %v", send.sent)
+		}
+	})
+
+	t.Run("later batches run after a failed one", func(t *testing.T) {
+		send := &fakeSender{failures: 1, failing: map[int64]bool{2: true}}
+		r := newTestReplayer(send, 0)
+
+		err := r.runBatches(context.Background(), make(chan struct{}), batches)
+		if err == nil || !strings.Contains(err.Error(), "batch 2024-02") {
+			t.Fatalf("expected the failed batch in the error, got %v", err)
+		}
+		if !slices.Equal(send.sent, []int64{1, 3}) {
+			t.Errorf("expected the other batches to be sent, got %v", send.sent)
+		}
+	})
+
+	t.Run("no batch starts once stopping", func(t *testing.T) {
+		stopping := make(chan struct{})
+		send := &stoppingSender{stopping: stopping}
+		r := newTestReplayer(send, 0)
+
+		err := r.runBatches(context.Background(), stopping, batches)
+		if err == nil || !strings.Contains(err.Error(), "stopped after 1 of 3 batches") {
+			t.Fatalf("expected a stop after the first batch, got %v", err)
+		}
+		if !slices.Equal(send.sent, []int64{1}) {
+			t.Errorf("expected only the first batch to finish, got %v", send.sent)
+		}
+	})
+
+	t.Run("cancelled context stops the run", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		r := newTestReplayer(&fakeSender{}, 0)
+
+		err := r.runBatches(ctx, make(chan struct{}), batches)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected a cancelled run, got %v", err)
+		}
+		if strings.Count(err.Error(), "batch ") != 1 {
+			t.Errorf("expected only the first batch to be attempted, got %v", err)
+		}
+	})
+}
+
+// stoppingSender delivers every event and closes stopping after the first,
+// as an interrupt during the first batch would.
+type stoppingSender struct {
+	fakeSender
+	stopping chan struct{}
+}
+
+func (s *stoppingSender) Send(ctx context.Context, event StravaWebhookEvent) error {
+	if err := s.fakeSender.Send(ctx, event); err != nil {
+		return err
+	}
+	select {
+	case <-s.stopping:
+	default:
+		close(s.stopping)
+	}
+	return nil
+}
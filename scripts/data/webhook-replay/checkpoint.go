@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/andy-esch/desirelines/packages/reconcile"
+)
+
+// checkpointEvery is how many successful posts may go unsaved between
+// checkpoint writes.
+const checkpointEvery = 25
+
+// checkpointState is the saved progress of a backfill. Target and
+// Destination identify the run so a state file is never applied to another
+// environment's backfill.
+type checkpointState struct {
+	UpdatedAt   time.Time `json:"updated_at"`
+	Target      string    `json:"target"`
+	Destination string    `json:"destination"`
+	Replayed    []int64   `json:"replayed"`
+}
+
+// checkpointStore reads and writes the saved state.
+type checkpointStore interface {
+	// Read returns os.ErrNotExist when nothing has been saved yet.
+	Read(ctx context.Context) ([]byte, error)
+	Write(ctx context.Context, data []byte) error
+	Close() error
+}
+
+// checkpoint tracks which activities a backfill has replayed, so an
+// interrupted run re-posts only the events that never got through.
+type checkpoint struct {
+	store    checkpointStore
+	replayed map[int64]bool
+	target   string
+	dest     string
+	unsaved  int
+	mu       sync.Mutex
+}
+
+// openCheckpoint loads the progress saved at location, a local path or a
+// gs://bucket/object URL.
+func openCheckpoint(ctx context.Context, location string, config *Config) (*checkpoint, error) {
+	store, err := newCheckpointStore(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &checkpoint{
+		store:    store,
+		replayed: map[int64]bool{},
+		target:   fmt.Sprintf("%s.%s.%s", config.TargetProject, config.TargetDataset, config.TargetTable),
+		dest:     config.destination(),
+	}
+
+	data, err := store.Read(ctx)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		_ = store.Close()
+		return nil, fmt.Errorf("failed to read checkpoint %s: %w", location, err)
+	}
+
+	var state checkpointState
+	if err := json.Unmarshal(data, &state); err != nil {
+		_ = store.Close()
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", location, err)
+	}
+	if state.Target != c.target || state.Destination != c.dest {
+		_ = store.Close()
+		return nil, fmt.Errorf("checkpoint %s is for %s via %s, not this run", location, state.Target, state.Destination)
+	}
+	for _, id := range state.Replayed {
+		c.replayed[id] = true
+	}
+	log.Printf("Resuming from checkpoint %s: %d activities already replayed", location, len(state.Replayed))
+	return c, nil
+}
+
+// remaining returns the activities the checkpoint has not seen replayed.
+func (c *checkpoint) remaining(activities []reconcile.Activity) []reconcile.Activity {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.DeleteFunc(slices.Clone(activities), func(activity reconcile.Activity) bool {
+		return c.replayed[activity.ID]
+	})
+}
+
+// done records a successful post, saving every checkpointEvery posts.
+func (c *checkpoint) done(ctx context.Context, activityID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.replayed[activityID] = true
+	c.unsaved++
+	if c.unsaved >= checkpointEvery {
+		c.saveLocked(ctx)
+	}
+}
+
+// Close saves any unsaved progress and releases the store.
+func (c *checkpoint) Close(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.unsaved > 0 {
+		c.saveLocked(ctx)
+	}
+	return c.store.Close()
+}
+
+// saveLocked writes the state. A failed save is logged and retried with the
+// next one; at worst a resumed run re-posts the unsaved events.
+func (c *checkpoint) saveLocked(ctx context.Context) {
+	state := checkpointState{
+		UpdatedAt:   time.Now().UTC(),
+		Target:      c.target,
+		Destination: c.dest,
+		Replayed:    make([]int64, 0, len(c.replayed)),
+	}
+	for id := range c.replayed {
+		state.Replayed = append(state.Replayed, id)
+	}
+	slices.Sort(state.Replayed)
+
+	data, err := json.Marshal(state)
+	if err == nil {
+		err = c.store.Write(ctx, data)
+	}
+	if err != nil {
+		log.Printf("Failed to save checkpoint: %v", err)
+		return
+	}
+	c.unsaved = 0
+}
+
+// newCheckpointStore returns the store for a local path or gs:// URL.
+func newCheckpointStore(ctx context.Context, location string) (checkpointStore, error) {
+	rest, ok := strings.CutPrefix(location, "gs://")
+	if !ok {
+		return fileCheckpoint{path: location}, nil
+	}
+
+	bucket, object, _ := strings.Cut(rest, "/")
+	if bucket == "" || object == "" {
+		return nil, fmt.Errorf("invalid checkpoint %q (want gs://bucket/object)", location)
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client: %w", err)
+	}
+	return &gcsCheckpoint{client: client, object: client.Bucket(bucket).Object(object)}, nil
+}
+
+// fileCheckpoint keeps the state in a local file.
+type fileCheckpoint struct {
+	path string
+}
+
+func (f fileCheckpoint) Read(ctx context.Context) ([]byte, error) {
+	return os.ReadFile(f.path)
+}
+
+// Write replaces the file via a temp file and rename, so an interrupted
+// write leaves the previous state intact.
+func (f fileCheckpoint) Write(ctx context.Context, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".checkpoint-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// No-op once the rename has succeeded
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+func (f fileCheckpoint) Close() error {
+	return nil
+}
+
+// gcsCheckpoint keeps the state in a Cloud Storage object, so a backfill
+// can resume from another machine.
+type gcsCheckpoint struct {
+	client *storage.Client
+	object *storage.ObjectHandle
+}
+
+func (g *gcsCheckpoint) Read(ctx context.Context) ([]byte, error) {
+	r, err := g.object.NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (g *gcsCheckpoint) Write(ctx context.Context, data []byte) error {
+	w := g.object.NewWriter(ctx)
+	w.ContentType = "application/json"
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (g *gcsCheckpoint) Close() error {
+	return g.client.Close()
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/andy-esch/desirelines/packages/reconcile"
+)
+
+func testCheckpointConfig() *Config {
+	return &Config{
+		Environment: Environment{
+			TargetProject: "project",
+			TargetDataset: "dataset",
+			TargetTable:   "activities",
+			DispatcherURL: "https://dispatcher.example.com/webhook",
+		},
+		Target: TargetDispatcher,
+	}
+}
+
+// writeCheckpointState saves state as a checkpoint file in a temp dir and
+// returns its path.
+func writeCheckpointState(t *testing.T, state checkpointState) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("failed to marshal state: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write state: %v", err)
+	}
+	return path
+}
+
+// readCheckpointState reads the checkpoint file at path.
+func readCheckpointState(t *testing.T, path string) checkpointState {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read checkpoint: %v", err)
+	}
+	var state checkpointState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatalf("failed to parse checkpoint: %v", err)
+	}
+	return state
+}
+
+func TestOpenCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	config := testCheckpointConfig()
+
+	tests := []struct {
+		name     string
+		state    *checkpointState
+		raw      string
+		wantErr  string
+		replayed []int64
+	}{
+		{
+			name: "no saved state",
+		},
+		{
+			name: "matching run",
+			state: &checkpointState{
+				Target:      "project.dataset.activities",
+				Destination: "https://dispatcher.example.com/webhook",
+				Replayed:    []int64{1, 2},
+			},
+			replayed: []int64{1, 2},
+		},
+		{
+			name: "other target",
+			state: &checkpointState{
+				Target:      "project.other.activities",
+				Destination: "https://dispatcher.example.com/webhook",
+				Replayed:    []int64{1},
+			},
+			wantErr: "is for project.other.activities",
+		},
+		{
+			name: "other destination",
+			state: &checkpointState{
+				Target:      "project.dataset.activities",
+				Destination: "projects/project/topics/activity-events",
+				Replayed:    []int64{1},
+			},
+			wantErr: "via projects/project/topics/activity-events",
+		},
+		{
+			name:    "corrupt state",
+			raw:     "{not json",
+			wantErr: "failed to parse checkpoint",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "checkpoint.json")
+			switch {
+			case tt.state != nil:
+				path = writeCheckpointState(t, *tt.state)
+			case tt.raw != "":
+				if err := os.WriteFile(path, []byte(tt.raw), 0o600); err != nil {
+					t.Fatalf("failed to write state: %v", err)
+				}
+			}
+
+			c, err := openCheckpoint(ctx, path, config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("openCheckpoint failed: %v", err)
+			}
+			if len(c.replayed) != len(tt.replayed) {
+				t.Errorf("expected %d replayed activities, got %d", len(tt.replayed), len(c.replayed))
+			}
+			for _, id := range tt.replayed {
+				if !c.replayed[id] {
+					t.Errorf("expected activity %d to be replayed", id)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckpointRemaining(t *testing.T) {
+	activities := []reconcile.Activity{{ID: 1}, {ID: 2}, {ID: 3}}
+
+	tests := []struct {
+		name     string
+		replayed []int64
+		want     []int64
+	}{
+		{name: "nothing replayed", want: []int64{1, 2, 3}},
+		{name: "some replayed", replayed: []int64{2}, want: []int64{1, 3}},
+		{name: "all replayed", replayed: []int64{1, 2, 3}, want: []int64{}},
+		{name: "unknown activities replayed", replayed: []int64{4}, want: []int64{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &checkpoint{replayed: map[int64]bool{}}
+			for _, id := range tt.replayed {
+				c.replayed[id] = true
+			}
+
+			got := []int64{}
+			for _, activity := range c.remaining(activities) {
+				got = append(got, activity.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected remaining %v, got %v", tt.want, got)
+			}
+		})
+	}
+	if activities[0].ID != 1 || activities[1].ID != 2 || activities[2].ID != 3 {
+		t.Errorf("remaining modified its input: %v", activities)
+	}
+}
+
+func TestCheckpointDone(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		done      int
+		wantSaved int
+	}{
+		{name: "below the save interval", done: checkpointEvery - 1, wantSaved: 0},
+		{name: "at the save interval", done: checkpointEvery, wantSaved: checkpointEvery},
+		{name: "past the save interval", done: checkpointEvery + 1, wantSaved: checkpointEvery},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "checkpoint.json")
+			c, err := openCheckpoint(ctx, path, testCheckpointConfig())
+			if err != nil {
+				t.Fatalf("openCheckpoint failed: %v", err)
+			}
+
+			for id := range tt.done {
+				c.done(ctx, int64(id))
+			}
+
+			if tt.wantSaved == 0 {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("expected no checkpoint before %d posts, got %v", checkpointEvery, err)
+				}
+			} else if state := readCheckpointState(t, path); len(state.Replayed) != tt.wantSaved {
+				t.Errorf("expected %d saved activities, got %d", tt.wantSaved, len(state.Replayed))
+			}
+
+			// Close saves whatever is left
+			if err := c.Close(ctx); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+			state := readCheckpointState(t, path)
+			if len(state.Replayed) != tt.done {
+				t.Errorf("expected %d saved activities after Close, got %d", tt.done, len(state.Replayed))
+			}
+			if !slices.IsSorted(state.Replayed) {
+				t.Errorf("expected sorted activity IDs, got %v", state.Replayed)
+			}
+			if state.Target != "project.dataset.activities" || state.Destination != "https://dispatcher.example.com/webhook" {
+				t.Errorf("expected the run's target and destination, got %s via %s", state.Target, state.Destination)
+			}
+		})
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	ctx := context.Background()
+	config := testCheckpointConfig()
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	c, err := openCheckpoint(ctx, path, config)
+	if err != nil {
+		t.Fatalf("openCheckpoint failed: %v", err)
+	}
+	c.done(ctx, 2)
+	if err := c.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	resumed, err := openCheckpoint(ctx, path, config)
+	if err != nil {
+		t.Fatalf("openCheckpoint failed on resume: %v", err)
+	}
+	remaining := resumed.remaining([]reconcile.Activity{{ID: 1}, {ID: 2}, {ID: 3}})
+	if len(remaining) != 2 || remaining[0].ID != 1 || remaining[1].ID != 3 {
+		t.Errorf("expected activities 1 and 3 to remain, got %v", remaining)
+	}
+}
+
+func TestNewCheckpointStore(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		wantErr  bool
+	}{
+		{name: "local path", location: "checkpoint.json"},
+		{name: "missing object", location: "gs://bucket", wantErr: true},
+		{name: "missing bucket", location: "gs:///checkpoint.json", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := newCheckpointStore(context.Background(), tt.location)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if store != nil {
+				_ = store.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// progressBarWidth is the number of cells in the bar.
+	progressBarWidth = 30
+	// progressRedraw throttles redraws between log lines.
+	progressRedraw = 200 * time.Millisecond
+)
+
+// progressBar draws a one-line bar with counts and an ETA at the bottom of
+// the terminal. Log lines are written above it. A nil bar draws nothing.
+type progressBar struct {
+	started time.Time
+	drawn   time.Time
+	out     *os.File
+	total   int
+	sent    int
+	errors  int
+	mu      sync.Mutex
+}
+
+// newProgressBar returns a bar for total events, or nil when disabled or
+// stderr isn't a terminal. It takes over the log output so log lines don't
+// tear the bar.
+func newProgressBar(config *Config, total int) *progressBar {
+	if !config.Progress || !isTerminal(os.Stderr) {
+		return nil
+	}
+	b := &progressBar{started: time.Now(), out: os.Stderr, total: total}
+	log.SetOutput(b)
+	return b
+}
+
+// isTerminal reports whether f is a character device, e.g. a terminal
+// rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// succeeded counts a sent event.
+func (b *progressBar) succeeded() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sent++
+	b.drawLocked(false)
+}
+
+// failed counts a failed send, which may later succeed on retry.
+func (b *progressBar) failed() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.errors++
+	b.drawLocked(false)
+}
+
+// Write implements io.Writer for the log package, clearing the bar,
+// writing p and redrawing the bar below it.
+func (b *progressBar) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	fmt.Fprint(b.out, "\r\033[K")
+	n, err := b.out.Write(p)
+	b.drawLocked(true)
+	return n, err
+}
+
+// finish draws the final state and restores the log output.
+func (b *progressBar) finish() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.drawLocked(true)
+	fmt.Fprintln(b.out)
+	b.mu.Unlock()
+	log.SetOutput(os.Stderr)
+}
+
+// drawLocked redraws the bar, at most every progressRedraw unless force.
+func (b *progressBar) drawLocked(force bool) {
+	now := time.Now()
+	if !force && now.Sub(b.drawn) < progressRedraw {
+		return
+	}
+	b.drawn = now
+
+	fraction := 1.0
+	if b.total > 0 {
+		fraction = float64(b.sent) / float64(b.total)
+	}
+	filled := int(fraction * progressBarWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+
+	eta := "--"
+	if elapsed := now.Sub(b.started); b.sent > 0 && b.sent < b.total {
+		perEvent := elapsed / time.Duration(b.sent)
+		eta = (perEvent * time.Duration(b.total-b.sent)).Round(time.Second).String()
+	}
+	fmt.Fprintf(b.out, "\r\033[K[%s] %d/%d %3.0f%% %d errors ETA %s", bar, b.sent, b.total, 100*fraction, b.errors, eta)
+}
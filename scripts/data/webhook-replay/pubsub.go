@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"cloud.google.com/go/pubsub/v2"
+	"github.com/google/uuid"
+)
+
+// sourceBackfill is the source attribute of events published by
+// -target pubsub, so consumers can tell them from Strava's and from events
+// replayed through the dispatcher (source=replay).
+const sourceBackfill = "backfill"
+
+// pubSubSender publishes events to the dispatcher's topic in the shape the
+// dispatcher publishes them, without the dispatcher's HTTP round trip or
+// per-event Strava checks.
+type pubSubSender struct {
+	client    *pubsub.Client
+	publisher *pubsub.Publisher
+}
+
+// newPubSubSender creates a sender publishing to topicID in projectID.
+func newPubSubSender(ctx context.Context, projectID, topicID string) (*pubSubSender, error) {
+	client, err := pubsub.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PubSub client: %w", err)
+	}
+	publisher := client.Publisher(fmt.Sprintf("projects/%s/topics/%s", projectID, topicID))
+	// Match the dispatcher's per-athlete ordering keys
+	publisher.EnableMessageOrdering = true
+	return &pubSubSender{client: client, publisher: publisher}, nil
+}
+
+// Send implements the sender interface, waiting for the publish to be
+// acknowledged so checkpoints only record delivered events.
+func (p *pubSubSender) Send(ctx context.Context, event StravaWebhookEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	orderingKey := strconv.FormatInt(event.OwnerID, 10)
+	result := p.publisher.Publish(ctx, &pubsub.Message{
+		Data: data,
+		Attributes: map[string]string{
+			"correlation_id":  uuid.NewString(),
+			"aspect_type":     event.AspectType,
+			"object_type":     event.ObjectType,
+			"owner_id":        orderingKey,
+			"subscription_id": strconv.Itoa(event.SubscriptionID),
+			"source":          sourceBackfill,
+		},
+		OrderingKey: orderingKey,
+	})
+	if _, err := result.Get(ctx); err != nil {
+		// A failed ordered publish pauses its key until resumed
+		p.publisher.ResumePublish(orderingKey)
+		return fmt.Errorf("failed to publish to PubSub: %w", err)
+	}
+	return nil
+}
+
+// Close implements the sender interface.
+func (p *pubSubSender) Close() error {
+	p.publisher.Stop()
+	return p.client.Close()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"cloud.google.com/go/pubsub/v2"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/v2/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestPubSubSender_Send(t *testing.T) {
+	ctx := context.Background()
+	srv := pstest.NewServer()
+	t.Cleanup(func() { _ = srv.Close() })
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial fake server: %v", err)
+	}
+	client, err := pubsub.NewClient(ctx, "test-project", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	topicName := "projects/test-project/topics/activity-events"
+	if _, err := client.TopicAdminClient.CreateTopic(ctx, &pubsubpb.Topic{Name: topicName}); err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+	publisher := client.Publisher(topicName)
+	publisher.EnableMessageOrdering = true
+	send := &pubSubSender{client: client, publisher: publisher}
+	t.Cleanup(func() { _ = send.Close() })
+
+	event := StravaWebhookEvent{
+		ObjectType:     "activity",
+		ObjectID:       123,
+		AspectType:     "create",
+		OwnerID:        456,
+		SubscriptionID: 789,
+		EventTime:      1700000000,
+		Updates:        map[string]any{},
+	}
+	if err := send.Send(ctx, event); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	messages := srv.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 published message, got %d", len(messages))
+	}
+	message := messages[0]
+
+	var published StravaWebhookEvent
+	if err := json.Unmarshal(message.Data, &published); err != nil {
+		t.Fatalf("failed to decode message: %v", err)
+	}
+	if published.ObjectID != event.ObjectID || published.OwnerID != event.OwnerID {
+		t.Errorf("expected the event in the message, got %+v", published)
+	}
+	if message.OrderingKey != "456" {
+		t.Errorf("expected ordering key 456, got %q", message.OrderingKey)
+	}
+
+	wantAttributes := map[string]string{
+		"aspect_type":     "create",
+		"object_type":     "activity",
+		"owner_id":        "456",
+		"subscription_id": "789",
+		"source":          sourceBackfill,
+	}
+	for key, want := range wantAttributes {
+		if got := message.Attributes[key]; got != want {
+			t.Errorf("expected attribute %s=%q, got %q", key, want, got)
+		}
+	}
+	if message.Attributes["correlation_id"] == "" {
+		t.Error("expected a correlation_id attribute")
+	}
+}
+
+func TestPubSubSender_SendToMissingTopic(t *testing.T) {
+	ctx := context.Background()
+	srv := pstest.NewServer()
+	t.Cleanup(func() { _ = srv.Close() })
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial fake server: %v", err)
+	}
+	client, err := pubsub.NewClient(ctx, "test-project", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	topicName := "projects/test-project/topics/activity-events"
+	publisher := client.Publisher(topicName)
+	publisher.EnableMessageOrdering = true
+	send := &pubSubSender{client: client, publisher: publisher}
+	t.Cleanup(func() { _ = send.Close() })
+
+	event := StravaWebhookEvent{ObjectType: "activity", ObjectID: 1, AspectType: "create", OwnerID: 2}
+	if err := send.Send(ctx, event); err == nil {
+		t.Fatal("expected an error publishing to a missing topic")
+	}
+
+	// The failed publish must not leave the athlete's ordering key paused,
+	// or retries of their events would fail without being published
+	if _, err := client.TopicAdminClient.CreateTopic(ctx, &pubsubpb.Topic{Name: topicName}); err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+	if err := send.Send(ctx, event); err != nil {
+		t.Fatalf("expected the retry to publish, got %v", err)
+	}
+	if got := len(srv.Messages()); got != 1 {
+		t.Errorf("expected 1 published message, got %d", got)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// composeFile is the subset of the Compose specification the dev stack
+// uses.
+type composeFile struct {
+	Name     string             `yaml:"name"`
+	Services map[string]service `yaml:"services"`
+}
+
+type service struct {
+	Image       string                `yaml:"image,omitempty"`
+	Build       *build                `yaml:"build,omitempty"`
+	Command     []string              `yaml:"command,omitempty"`
+	Ports       []string              `yaml:"ports,omitempty"`
+	Environment map[string]string     `yaml:"environment,omitempty"`
+	Volumes     []string              `yaml:"volumes,omitempty"`
+	DependsOn   map[string]dependency `yaml:"depends_on,omitempty"`
+	Healthcheck *healthcheck          `yaml:"healthcheck,omitempty"`
+}
+
+type build struct {
+	Context    string            `yaml:"context"`
+	Dockerfile string            `yaml:"dockerfile"`
+	Args       map[string]string `yaml:"args,omitempty"`
+}
+
+type dependency struct {
+	Condition string `yaml:"condition"`
+}
+
+type healthcheck struct {
+	Test     []string `yaml:"test"`
+	Interval string   `yaml:"interval"`
+	Timeout  string   `yaml:"timeout"`
+	Retries  int      `yaml:"retries"`
+}
+
+// Dependency conditions.
+const (
+	serviceStarted   = "service_started"
+	serviceHealthy   = "service_healthy"
+	serviceCompleted = "service_completed_successfully"
+)
+
+// Service names, which are also their hostnames inside the stack.
+const (
+	pubSubEmulator  = "pubsub-emulator"
+	pubSubBootstrap = "pubsub-bootstrap"
+	fakeGCS         = "fake-gcs"
+	dispatcher      = "dispatcher"
+	apiGateway      = "api-gateway"
+)
+
+const (
+	// pubSubPort and gcsPort are the emulators' ports, the same inside the
+	// stack and on the host.
+	pubSubPort = 8085
+	gcsPort    = 4443
+	// servicePort is the port the Go services listen on in their
+	// containers.
+	servicePort = 8080
+	// inspectSubscription is a pull subscription on the topic for looking
+	// at what the dispatcher published.
+	inspectSubscription = "desirelines_dev_inspect"
+)
+
+// stackConfig holds the settings of the generated stack.
+type stackConfig struct {
+	Project        string
+	GCPProject     string
+	Topic          string
+	Bucket         string
+	SubscriptionID int
+	VerifyToken    string
+	AdminToken     string
+	DispatcherPort int
+	GatewayPort    int
+}
+
+// defaultStackConfig returns the settings of `desirelines dev` without
+// flags.
+func defaultStackConfig() stackConfig {
+	return stackConfig{
+		Project:        "desirelines-dev",
+		GCPProject:     "local-dev",
+		Topic:          "desirelines_activity_events",
+		Bucket:         "desirelines-local",
+		SubscriptionID: 123456,
+		VerifyToken:    "local-verify-token",
+		AdminToken:     "local-admin-token",
+		DispatcherPort: 8081,
+		GatewayPort:    8084,
+	}
+}
+
+// encodeStack returns the Compose definition of the stack for c as YAML.
+func encodeStack(c stackConfig) ([]byte, error) {
+	var definition bytes.Buffer
+	encoder := yaml.NewEncoder(&definition)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(devStack(c)); err != nil {
+		return nil, fmt.Errorf("failed to encode the compose definition: %w", err)
+	}
+	return definition.Bytes(), nil
+}
+
+// devStack returns the Compose definition of the local pipeline: the Pub/Sub
+// emulator with the topic created, a fake Cloud Storage server seeded with
+// data/fixtures, and the dispatcher and API gateway wired to both. Paths are
+// relative to the repository root, which is the Compose project directory.
+func devStack(c stackConfig) composeFile {
+	topicPath := fmt.Sprintf("projects/%s/topics/%s", c.GCPProject, c.Topic)
+	pubSubURL := fmt.Sprintf("http://%s:%d/v1/", pubSubEmulator, pubSubPort)
+
+	return composeFile{
+		Name: c.Project,
+		Services: map[string]service{
+			pubSubEmulator: {
+				Image:   "gcr.io/google.com/cloudsdktool/cloud-sdk:emulators",
+				Command: []string{"gcloud", "beta", "emulators", "pubsub", "start", fmt.Sprintf("--host-port=0.0.0.0:%d", pubSubPort), "--project=" + c.GCPProject},
+				Ports:   []string{fmt.Sprintf("%d:%d", pubSubPort, pubSubPort)},
+				Healthcheck: &healthcheck{
+					Test:     []string{"CMD", "curl", "-sf", fmt.Sprintf("http://localhost:%d", pubSubPort)},
+					Interval: "2s",
+					Timeout:  "2s",
+					Retries:  30,
+				},
+			},
+			// Creates the topic the dispatcher publishes to, and a pull
+			// subscription to read its messages back from
+			pubSubBootstrap: {
+				Image: "curlimages/curl:latest",
+				Command: []string{"sh", "-ec", strings.Join([]string{
+					fmt.Sprintf("curl -sf -X PUT %s%s -H 'Content-Type: application/json' -d '{}'", pubSubURL, topicPath),
+					fmt.Sprintf(`curl -sf -X PUT %sprojects/%s/subscriptions/%s -H 'Content-Type: application/json' -d '{"topic":"%s"}'`, pubSubURL, c.GCPProject, inspectSubscription, topicPath),
+				}, "\n")},
+				DependsOn: map[string]dependency{pubSubEmulator: {Condition: serviceHealthy}},
+			},
+			// Serves data/fixtures as the bucket's initial objects; writes,
+			// e.g. goals, stay in memory so the fixtures are never modified
+			fakeGCS: {
+				Image:   "fsouza/fake-gcs-server:latest",
+				Command: []string{"-scheme", "http", "-port", fmt.Sprint(gcsPort), "-backend", "memory", "-data", "/data", "-public-host", fmt.Sprintf("localhost:%d", gcsPort)},
+				Ports:   []string{fmt.Sprintf("%d:%d", gcsPort, gcsPort)},
+				Volumes: []string{"./data/fixtures:/data/" + c.Bucket + ":ro"},
+			},
+			dispatcher: {
+				Build: &build{
+					Context:    ".",
+					Dockerfile: "packages/dispatcher/Dockerfile",
+					Args:       buildArgs(),
+				},
+				Ports: []string{fmt.Sprintf("%d:%d", c.DispatcherPort, servicePort)},
+				Environment: map[string]string{
+					"PORT":                           fmt.Sprint(servicePort),
+					"GCP_PROJECT_ID":                 c.GCPProject,
+					"GCP_PUBSUB_TOPIC":               c.Topic,
+					"PUBSUB_EMULATOR_HOST":           fmt.Sprintf("%s:%d", pubSubEmulator, pubSubPort),
+					"SECRET_SOURCE":                  "env",
+					"STRAVA_WEBHOOK_SUBSCRIPTION_ID": fmt.Sprint(c.SubscriptionID),
+					"STRAVA_WEBHOOK_VERIFY_TOKEN":    c.VerifyToken,
+				},
+				DependsOn:   map[string]dependency{pubSubBootstrap: {Condition: serviceCompleted}},
+				Healthcheck: httpHealthcheck("/ready"),
+			},
+			apiGateway: {
+				Build: &build{
+					Context:    ".",
+					Dockerfile: "functions/Dockerfile.apigateway",
+					Args:       buildArgs(),
+				},
+				Ports: []string{fmt.Sprintf("%d:%d", c.GatewayPort, servicePort)},
+				Environment: map[string]string{
+					"PORT":                  fmt.Sprint(servicePort),
+					"DATA_SOURCE":           "cloud-storage",
+					"GCP_PROJECT_ID":        c.GCPProject,
+					"GCP_BUCKET_NAME":       c.Bucket,
+					"STORAGE_EMULATOR_HOST": fmt.Sprintf("http://%s:%d", fakeGCS, gcsPort),
+					"ALLOWED_ORIGINS":       "http://localhost:*",
+					"ADMIN_TOKEN":           c.AdminToken,
+				},
+				DependsOn:   map[string]dependency{fakeGCS: {Condition: serviceStarted}},
+				Healthcheck: httpHealthcheck("/health"),
+			},
+		},
+	}
+}
+
+// buildArgs passes the build metadata the Dockerfiles stamp into /version
+// through from the environment, as the Makefile exports it.
+func buildArgs() map[string]string {
+	return map[string]string{
+		"GIT_COMMIT": "${GIT_COMMIT:-}",
+		"BUILD_TIME": "${BUILD_TIME:-}",
+	}
+}
+
+// httpHealthcheck probes path on the service's port with the wget in its
+// image.
+func httpHealthcheck(path string) *healthcheck {
+	return &healthcheck{
+		Test:     []string{"CMD", "wget", "--quiet", "--tries=1", "--spider", fmt.Sprintf("http://localhost:%d%s", servicePort, path)},
+		Interval: "10s",
+		Timeout:  "5s",
+		Retries:  3,
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestEncodeStack_Golden compares the generated Compose definition with the
+// golden files. Run with -update after intended changes to the stack and
+// review the diff.
+func TestEncodeStack_Golden(t *testing.T) {
+	custom := stackConfig{
+		Project:        "desirelines-test",
+		GCPProject:     "test-project",
+		Topic:          "test_activity_events",
+		Bucket:         "desirelines-test",
+		SubscriptionID: 999,
+		VerifyToken:    "test-verify-token",
+		AdminToken:     "test-admin-token",
+		DispatcherPort: 9081,
+		GatewayPort:    9084,
+	}
+
+	tests := []struct {
+		name   string
+		config stackConfig
+	}{
+		{name: "default", config: defaultStackConfig()},
+		{name: "custom", config: custom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeStack(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join("testdata", tt.name+".golden.yaml")
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("compose definition differs from %s; run with -update and review the diff", path)
+			}
+		})
+	}
+}
+
+// TestDevStack_Dependencies checks that every depends_on names a service in
+// the stack, which docker compose would otherwise reject at startup.
+func TestDevStack_Dependencies(t *testing.T) {
+	stack := devStack(defaultStackConfig())
+	for name, service := range stack.Services {
+		for dependency := range service.DependsOn {
+			if _, ok := stack.Services[dependency]; !ok {
+				t.Errorf("%s depends on unknown service %s", name, dependency)
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Command desirelines runs developer workflows. `desirelines dev` manages a
+// local stack of the dispatcher, API gateway, Pub/Sub emulator and a fake
+// Cloud Storage server seeded with data/fixtures, generated as a Compose
+// definition and handed to docker compose:
+//
+//	desirelines dev up [-build] [-detach]
+//	desirelines dev down [-volumes]
+//	desirelines dev logs [-follow] [service...]
+//	desirelines dev ps
+//	desirelines dev config
+//
+// Flags shared by the dev commands go before the command name, e.g.
+// `desirelines dev -gateway-port 9084 up`.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 || os.Args[1] != "dev" {
+		fmt.Fprintf(os.Stderr, "Usage: %s dev [flags] up|down|logs|ps|config [command flags]\n", filepath.Base(os.Args[0]))
+		os.Exit(2)
+	}
+	if err := dev(os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func dev(args []string) error {
+	config := defaultStackConfig()
+	flags := flag.NewFlagSet("dev", flag.ExitOnError)
+	root := flags.String("root", "", "Repository root (default: found from the working directory)")
+	flags.StringVar(&config.Project, "project", config.Project, "Compose project name")
+	flags.IntVar(&config.SubscriptionID, "subscription-id", config.SubscriptionID, "Subscription ID the dispatcher accepts")
+	flags.StringVar(&config.VerifyToken, "verify-token", config.VerifyToken, "Webhook verify token the dispatcher accepts")
+	flags.StringVar(&config.AdminToken, "admin-token", config.AdminToken, "API gateway ADMIN_TOKEN, e.g. for PUT /goals")
+	flags.IntVar(&config.DispatcherPort, "dispatcher-port", config.DispatcherPort, "Host port of the dispatcher")
+	flags.IntVar(&config.GatewayPort, "gateway-port", config.GatewayPort, "Host port of the API gateway")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: desirelines dev [flags] up|down|logs|ps|config [command flags]\n")
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if *root == "" {
+		var err error
+		if *root, err = findRoot(); err != nil {
+			return err
+		}
+	}
+	definition, err := encodeStack(config)
+	if err != nil {
+		return err
+	}
+
+	command, args := flags.Arg(0), flags.Args()[1:]
+	switch command {
+	case "up":
+		up := flag.NewFlagSet("up", flag.ExitOnError)
+		rebuild := up.Bool("build", false, "Rebuild the dispatcher and API gateway images first")
+		detach := up.Bool("detach", false, "Run in the background")
+		_ = up.Parse(args)
+		composeArgs := []string{"up", "--remove-orphans"}
+		if *rebuild {
+			composeArgs = append(composeArgs, "--build")
+		}
+		if *detach {
+			composeArgs = append(composeArgs, "--detach", "--wait")
+		}
+		if err := compose(*root, definition, composeArgs...); err != nil {
+			return err
+		}
+		if *detach {
+			printEndpoints(config)
+		}
+		return nil
+	case "down":
+		down := flag.NewFlagSet("down", flag.ExitOnError)
+		volumes := down.Bool("volumes", false, "Also remove volumes")
+		_ = down.Parse(args)
+		composeArgs := []string{"down", "--remove-orphans"}
+		if *volumes {
+			composeArgs = append(composeArgs, "--volumes")
+		}
+		return compose(*root, definition, composeArgs...)
+	case "logs":
+		logs := flag.NewFlagSet("logs", flag.ExitOnError)
+		follow := logs.Bool("follow", false, "Follow log output")
+		_ = logs.Parse(args)
+		composeArgs := []string{"logs"}
+		if *follow {
+			composeArgs = append(composeArgs, "--follow")
+		}
+		return compose(*root, definition, append(composeArgs, logs.Args()...)...)
+	case "ps":
+		return compose(*root, definition, "ps")
+	case "config":
+		_, err := os.Stdout.Write(definition)
+		return err
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	return nil
+}
+
+// compose runs docker compose with the definition on stdin and root as
+// the project directory, so relative paths resolve against the repository.
+func compose(root string, definition []byte, args ...string) error {
+	cmd := exec.Command("docker", append([]string{"compose", "--project-directory", root, "-f", "-"}, args...)...)
+	cmd.Stdin = bytes.NewReader(definition)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return errors.New("docker not found; install Docker with the compose plugin")
+		}
+		return fmt.Errorf("docker compose %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// findRoot walks up from the working directory to the repository root,
+// recognized by data/fixtures and packages/dispatcher.
+func findRoot() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if isDir(filepath.Join(dir, "data", "fixtures")) && isDir(filepath.Join(dir, "packages", "dispatcher")) {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("not inside the desirelines repository; pass -root")
+		}
+		dir = parent
+	}
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// printEndpoints lists where the running stack can be reached.
+func printEndpoints(c stackConfig) {
+	fmt.Printf(`
+Dispatcher:  http://localhost:%d/ (subscription %d, verify token %q)
+API gateway: http://localhost:%d/ (admin token %q)
+Pub/Sub:     PUBSUB_EMULATOR_HOST=localhost:%d, project %s, topic %s, pull subscription %s
+Storage:     STORAGE_EMULATOR_HOST=http://localhost:%d, bucket %s
+`, c.DispatcherPort, c.SubscriptionID, c.VerifyToken, c.GatewayPort, c.AdminToken,
+		pubSubPort, c.GCPProject, c.Topic, inspectSubscription, gcsPort, c.Bucket)
+}
@@ -30,7 +30,9 @@ trap "rm -rf $TEMP_GO" EXIT
 # 1. Copy function wrapper (as function.go for Cloud Functions)
 cp functions/activity_dispatcher/main.go "$TEMP_GO/function.go"
 
-# 2. Copy complete business logic package
+# 2. Copy complete business logic package and the shared alert, athlete
+#    registry, config, IP filter, logging, Strava client and token store
+#    packages
 mkdir -p "$TEMP_GO/packages"
 rsync -av --exclude='__pycache__' --exclude='*.pyc' --exclude='.DS_Store' \
       --exclude='*.egg-info' --exclude='.pytest_cache' --exclude='.git' \
@@ -39,6 +41,13 @@ rsync -av --exclude='__pycache__' --exclude='*.pyc' --exclude='.DS_Store' \
       --exclude='local_dispatcher' --exclude='activity_dispatcher_function' \
       --exclude='Makefile' --exclude='README.md' \
       packages/dispatcher/ "$TEMP_GO/packages/dispatcher/"
+rsync -av --exclude='*_test.go' packages/alert/ "$TEMP_GO/packages/alert/"
+rsync -av --exclude='*_test.go' packages/athletes/ "$TEMP_GO/packages/athletes/"
+rsync -av --exclude='*_test.go' packages/config/ "$TEMP_GO/packages/config/"
+rsync -av --exclude='*_test.go' packages/ipfilter/ "$TEMP_GO/packages/ipfilter/"
+rsync -av --exclude='*_test.go' packages/logging/ "$TEMP_GO/packages/logging/"
+rsync -av --exclude='*_test.go' packages/strava/ "$TEMP_GO/packages/strava/"
+rsync -av --exclude='*_test.go' packages/tokenstore/ "$TEMP_GO/packages/tokenstore/"
 
 # 3. Create go.mod with correct replace directive
 cat > "$TEMP_GO/go.mod" << 'EOF'
@@ -54,12 +63,247 @@ require (
 )
 
 replace github.com/andy-esch/desirelines/packages/dispatcher => ./packages/dispatcher
+
+replace github.com/andy-esch/desirelines/packages/alert => ./packages/alert
+
+replace github.com/andy-esch/desirelines/packages/athletes => ./packages/athletes
+
+replace github.com/andy-esch/desirelines/packages/config => ./packages/config
+
+replace github.com/andy-esch/desirelines/packages/ipfilter => ./packages/ipfilter
+
+replace github.com/andy-esch/desirelines/packages/logging => ./packages/logging
+
+replace github.com/andy-esch/desirelines/packages/strava => ./packages/strava
+
+replace github.com/andy-esch/desirelines/packages/tokenstore => ./packages/tokenstore
 EOF
 
 # Create the zip from temp directory
 cd "$TEMP_GO" && zip -r - . > "$OLDPWD/$DIST_DIR/dispatcher-$SHA.zip"
 cd "$OLDPWD"
 
+# =============================================================================
+# Go Processor Function
+# =============================================================================
+echo "  → processor-$SHA.zip"
+
+# Create temporary directory for Go processor
+TEMP_PROC_GO=$(mktemp -d)
+trap "rm -rf $TEMP_PROC_GO" EXIT
+
+# 1. Copy function wrapper (as function.go for Cloud Functions)
+cp functions/activity_processor/main.go "$TEMP_PROC_GO/function.go"
+
+# 2. Copy complete business logic package and the shared aggregation,
+#    alert, athlete registry, config, logging, notification, Strava client
+#    and token store packages
+mkdir -p "$TEMP_PROC_GO/packages"
+rsync -av --exclude='.DS_Store' --exclude='.git' \
+      --exclude='coverage.html' --exclude='coverage.out' \
+      --exclude='*_test.go' --exclude='cmd' \
+      --exclude='Makefile' --exclude='README.md' \
+      packages/processor/ "$TEMP_PROC_GO/packages/processor/"
+rsync -av --exclude='*_test.go' --exclude='testdata' packages/aggregation/ "$TEMP_PROC_GO/packages/aggregation/"
+rsync -av --exclude='*_test.go' packages/alert/ "$TEMP_PROC_GO/packages/alert/"
+rsync -av --exclude='*_test.go' packages/athletes/ "$TEMP_PROC_GO/packages/athletes/"
+rsync -av --exclude='*_test.go' packages/config/ "$TEMP_PROC_GO/packages/config/"
+rsync -av --exclude='*_test.go' packages/logging/ "$TEMP_PROC_GO/packages/logging/"
+rsync -av --exclude='*_test.go' packages/notify/ "$TEMP_PROC_GO/packages/notify/"
+rsync -av --exclude='*_test.go' packages/strava/ "$TEMP_PROC_GO/packages/strava/"
+rsync -av --exclude='*_test.go' packages/tokenstore/ "$TEMP_PROC_GO/packages/tokenstore/"
+
+# 3. Create go.mod with correct replace directive
+cat > "$TEMP_PROC_GO/go.mod" << 'EOF'
+module github.com/andy-esch/desirelines/processor-function
+
+go 1.25
+
+require (
+	github.com/GoogleCloudPlatform/functions-framework-go v1.9.2
+	github.com/andy-esch/desirelines/packages/processor v0.0.0
+)
+
+replace github.com/andy-esch/desirelines/packages/processor => ./packages/processor
+
+replace github.com/andy-esch/desirelines/packages/aggregation => ./packages/aggregation
+
+replace github.com/andy-esch/desirelines/packages/alert => ./packages/alert
+
+replace github.com/andy-esch/desirelines/packages/athletes => ./packages/athletes
+
+replace github.com/andy-esch/desirelines/packages/config => ./packages/config
+
+replace github.com/andy-esch/desirelines/packages/logging => ./packages/logging
+
+replace github.com/andy-esch/desirelines/packages/notify => ./packages/notify
+
+replace github.com/andy-esch/desirelines/packages/strava => ./packages/strava
+
+replace github.com/andy-esch/desirelines/packages/tokenstore => ./packages/tokenstore
+EOF
+
+# Create the zip from temp directory
+cd "$TEMP_PROC_GO" && zip -r - . > "$OLDPWD/$DIST_DIR/processor-$SHA.zip"
+cd "$OLDPWD"
+
+# =============================================================================
+# Go Token Refresher Function
+# =============================================================================
+echo "  → token-refresher-$SHA.zip"
+
+# Create temporary directory for Go token refresher
+TEMP_TOKEN_GO=$(mktemp -d)
+trap "rm -rf $TEMP_TOKEN_GO" EXIT
+
+# 1. Copy function wrapper (as function.go for Cloud Functions)
+cp functions/token_refresher/main.go "$TEMP_TOKEN_GO/function.go"
+
+# 2. Copy complete business logic package and the shared athlete registry,
+#    config, logging, Strava client and token store packages
+mkdir -p "$TEMP_TOKEN_GO/packages"
+rsync -av --exclude='.DS_Store' --exclude='.git' \
+      --exclude='coverage.html' --exclude='coverage.out' \
+      --exclude='*_test.go' \
+      --exclude='Makefile' --exclude='README.md' \
+      packages/tokenrefresh/ "$TEMP_TOKEN_GO/packages/tokenrefresh/"
+rsync -av --exclude='*_test.go' packages/athletes/ "$TEMP_TOKEN_GO/packages/athletes/"
+rsync -av --exclude='*_test.go' packages/config/ "$TEMP_TOKEN_GO/packages/config/"
+rsync -av --exclude='*_test.go' packages/logging/ "$TEMP_TOKEN_GO/packages/logging/"
+rsync -av --exclude='*_test.go' packages/strava/ "$TEMP_TOKEN_GO/packages/strava/"
+rsync -av --exclude='*_test.go' packages/tokenstore/ "$TEMP_TOKEN_GO/packages/tokenstore/"
+
+# 3. Create go.mod with correct replace directive
+cat > "$TEMP_TOKEN_GO/go.mod" << 'EOF'
+module github.com/andy-esch/desirelines/token-refresher-function
+
+go 1.25
+
+require (
+	github.com/GoogleCloudPlatform/functions-framework-go v1.9.2
+	github.com/andy-esch/desirelines/packages/tokenrefresh v0.0.0
+)
+
+replace github.com/andy-esch/desirelines/packages/tokenrefresh => ./packages/tokenrefresh
+
+replace github.com/andy-esch/desirelines/packages/athletes => ./packages/athletes
+
+replace github.com/andy-esch/desirelines/packages/config => ./packages/config
+
+replace github.com/andy-esch/desirelines/packages/logging => ./packages/logging
+
+replace github.com/andy-esch/desirelines/packages/strava => ./packages/strava
+
+replace github.com/andy-esch/desirelines/packages/tokenstore => ./packages/tokenstore
+EOF
+
+# Create the zip from temp directory
+cd "$TEMP_TOKEN_GO" && zip -r - . > "$OLDPWD/$DIST_DIR/token-refresher-$SHA.zip"
+cd "$OLDPWD"
+
+# =============================================================================
+# Go Reconciler Function
+# =============================================================================
+echo "  → reconciler-$SHA.zip"
+
+# Create temporary directory for Go reconciler
+TEMP_RECONCILE_GO=$(mktemp -d)
+trap "rm -rf $TEMP_RECONCILE_GO" EXIT
+
+# 1. Copy function wrapper (as function.go for Cloud Functions)
+cp functions/reconciler/main.go "$TEMP_RECONCILE_GO/function.go"
+
+# 2. Copy complete business logic package and the shared alert, config,
+#    logging and gap detection packages
+mkdir -p "$TEMP_RECONCILE_GO/packages"
+rsync -av --exclude='.DS_Store' --exclude='.git' \
+      --exclude='coverage.html' --exclude='coverage.out' \
+      --exclude='*_test.go' \
+      --exclude='Makefile' --exclude='README.md' \
+      packages/reconciler/ "$TEMP_RECONCILE_GO/packages/reconciler/"
+rsync -av --exclude='*_test.go' packages/alert/ "$TEMP_RECONCILE_GO/packages/alert/"
+rsync -av --exclude='*_test.go' packages/config/ "$TEMP_RECONCILE_GO/packages/config/"
+rsync -av --exclude='*_test.go' packages/logging/ "$TEMP_RECONCILE_GO/packages/logging/"
+rsync -av --exclude='*_test.go' --exclude='cmd' packages/reconcile/ "$TEMP_RECONCILE_GO/packages/reconcile/"
+
+# 3. Create go.mod with correct replace directive
+cat > "$TEMP_RECONCILE_GO/go.mod" << 'EOF'
+module github.com/andy-esch/desirelines/reconciler-function
+
+go 1.25
+
+require (
+	github.com/GoogleCloudPlatform/functions-framework-go v1.9.2
+	github.com/andy-esch/desirelines/packages/reconciler v0.0.0
+)
+
+replace github.com/andy-esch/desirelines/packages/reconciler => ./packages/reconciler
+
+replace github.com/andy-esch/desirelines/packages/alert => ./packages/alert
+
+replace github.com/andy-esch/desirelines/packages/config => ./packages/config
+
+replace github.com/andy-esch/desirelines/packages/logging => ./packages/logging
+
+replace github.com/andy-esch/desirelines/packages/reconcile => ./packages/reconcile
+EOF
+
+# Create the zip from temp directory
+cd "$TEMP_RECONCILE_GO" && zip -r - . > "$OLDPWD/$DIST_DIR/reconciler-$SHA.zip"
+cd "$OLDPWD"
+
+# =============================================================================
+# Go Weekly Digest Function
+# =============================================================================
+echo "  → weekly-digest-$SHA.zip"
+
+# Create temporary directory for Go weekly digest
+TEMP_DIGEST_GO=$(mktemp -d)
+trap "rm -rf $TEMP_DIGEST_GO" EXIT
+
+# 1. Copy function wrapper (as function.go for Cloud Functions)
+cp functions/weekly_digest/main.go "$TEMP_DIGEST_GO/function.go"
+
+# 2. Copy complete business logic package and the shared packages it
+#    depends on
+mkdir -p "$TEMP_DIGEST_GO/packages"
+rsync -av --exclude='.DS_Store' --exclude='.git' \
+      --exclude='coverage.html' --exclude='coverage.out' \
+      --exclude='*_test.go' \
+      --exclude='Makefile' --exclude='README.md' \
+      packages/digest/ "$TEMP_DIGEST_GO/packages/digest/"
+for pkg in aggregation athletes config logging strava; do
+    rsync -av --exclude='*_test.go' --exclude='testdata' packages/$pkg/ "$TEMP_DIGEST_GO/packages/$pkg/"
+done
+
+# 3. Create go.mod with correct replace directive
+cat > "$TEMP_DIGEST_GO/go.mod" << 'EOF'
+module github.com/andy-esch/desirelines/weekly-digest-function
+
+go 1.25
+
+require (
+	github.com/GoogleCloudPlatform/functions-framework-go v1.9.2
+	github.com/andy-esch/desirelines/packages/digest v0.0.0
+)
+
+replace github.com/andy-esch/desirelines/packages/digest => ./packages/digest
+
+replace github.com/andy-esch/desirelines/packages/aggregation => ./packages/aggregation
+
+replace github.com/andy-esch/desirelines/packages/athletes => ./packages/athletes
+
+replace github.com/andy-esch/desirelines/packages/config => ./packages/config
+
+replace github.com/andy-esch/desirelines/packages/logging => ./packages/logging
+
+replace github.com/andy-esch/desirelines/packages/strava => ./packages/strava
+EOF
+
+# Create the zip from temp directory
+cd "$TEMP_DIGEST_GO" && zip -r - . > "$OLDPWD/$DIST_DIR/weekly-digest-$SHA.zip"
+cd "$OLDPWD"
+
 # =============================================================================
 # Python BQ Inserter Function
 # =============================================================================
@@ -116,7 +360,9 @@ trap "rm -rf $TEMP_API_GO" EXIT
 # 1. Copy function wrapper
 cp functions/apigateway/main.go "$TEMP_API_GO/function.go"
 
-# 2. Copy complete business logic package
+# 2. Copy complete business logic package and the shared aggregation,
+#    athlete registry, config, IP filter, Strava client and token store
+#    packages
 mkdir -p "$TEMP_API_GO/packages"
 rsync -av --exclude='__pycache__' --exclude='*.pyc' --exclude='.DS_Store' \
       --exclude='*.egg-info' --exclude='.pytest_cache' --exclude='.git' \
@@ -124,6 +370,12 @@ rsync -av --exclude='__pycache__' --exclude='*.pyc' --exclude='.DS_Store' \
       --exclude='*_test.go' --exclude='test_*.sh' \
       --exclude='Makefile' --exclude='README.md' \
       packages/apigateway/ "$TEMP_API_GO/packages/apigateway/"
+rsync -av --exclude='*_test.go' --exclude='testdata' packages/aggregation/ "$TEMP_API_GO/packages/aggregation/"
+rsync -av --exclude='*_test.go' packages/athletes/ "$TEMP_API_GO/packages/athletes/"
+rsync -av --exclude='*_test.go' packages/config/ "$TEMP_API_GO/packages/config/"
+rsync -av --exclude='*_test.go' packages/ipfilter/ "$TEMP_API_GO/packages/ipfilter/"
+rsync -av --exclude='*_test.go' packages/strava/ "$TEMP_API_GO/packages/strava/"
+rsync -av --exclude='*_test.go' packages/tokenstore/ "$TEMP_API_GO/packages/tokenstore/"
 
 # 3. Create go.mod with correct replace directive
 cat > "$TEMP_API_GO/go.mod" << 'EOF'
@@ -132,12 +384,24 @@ module github.com/andy-esch/desirelines/api-gateway-function
 go 1.25
 
 require (
-	cloud.google.com/go/storage v1.49.0
+	cloud.google.com/go/storage v1.55.0
 	github.com/GoogleCloudPlatform/functions-framework-go v1.9.2
 	github.com/andy-esch/desirelines/packages/apigateway v0.0.0
 )
 
 replace github.com/andy-esch/desirelines/packages/apigateway => ./packages/apigateway
+
+replace github.com/andy-esch/desirelines/packages/aggregation => ./packages/aggregation
+
+replace github.com/andy-esch/desirelines/packages/athletes => ./packages/athletes
+
+replace github.com/andy-esch/desirelines/packages/config => ./packages/config
+
+replace github.com/andy-esch/desirelines/packages/ipfilter => ./packages/ipfilter
+
+replace github.com/andy-esch/desirelines/packages/strava => ./packages/strava
+
+replace github.com/andy-esch/desirelines/packages/tokenstore => ./packages/tokenstore
 EOF
 
 # Create the zip from temp directory
@@ -151,6 +415,10 @@ echo "📦 Creating 'latest' tagged packages..."
 
 # Copy SHA packages to "latest" versions for terraform default support
 cp "$DIST_DIR/dispatcher-$SHA.zip" "$DIST_DIR/dispatcher-latest.zip"
+cp "$DIST_DIR/processor-$SHA.zip" "$DIST_DIR/processor-latest.zip"
+cp "$DIST_DIR/token-refresher-$SHA.zip" "$DIST_DIR/token-refresher-latest.zip"
+cp "$DIST_DIR/reconciler-$SHA.zip" "$DIST_DIR/reconciler-latest.zip"
+cp "$DIST_DIR/weekly-digest-$SHA.zip" "$DIST_DIR/weekly-digest-latest.zip"
 cp "$DIST_DIR/bq-inserter-$SHA.zip" "$DIST_DIR/bq-inserter-latest.zip"
 cp "$DIST_DIR/aggregator-$SHA.zip" "$DIST_DIR/aggregator-latest.zip"
 cp "$DIST_DIR/api-gateway-$SHA.zip" "$DIST_DIR/api-gateway-latest.zip"
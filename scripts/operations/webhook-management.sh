@@ -28,6 +28,13 @@ fi
 # Get GCP project configuration
 GCP_PROJECT_ID=$(gcloud config get-value project)
 REGION="us-central1"
+DISPATCHER_DIR="$(cd "$(dirname "$0")/../../packages/dispatcher" && pwd)"
+
+# Runs the subscription tool against this environment's Strava secrets,
+# which it also updates with the subscription ID
+subscription() {
+    (cd "$DISPATCHER_DIR" && go run ./cmd/subscription -secret "strava-auth-$ENV_NAME" -project "$GCP_PROJECT_ID" "$@")
+}
 
 if [ -z "$GCP_PROJECT_ID" ]; then
     echo "❌ Error: No GCP project set in gcloud config"
@@ -48,45 +55,23 @@ case "$COMMAND" in
         fi
 
         echo "📍 Found dispatcher function: $FUNCTION_NAME"
-        STRAVA_AUTH=$(gcloud secrets versions access latest --secret="strava-auth-$ENV_NAME" --project="$GCP_PROJECT_ID")
-        CLIENT_ID=$(echo "$STRAVA_AUTH" | jq -r '.client_id')
-        CLIENT_SECRET=$(echo "$STRAVA_AUTH" | jq -r '.client_secret')
-        VERIFY_TOKEN=$(echo "$STRAVA_AUTH" | jq -r '.webhook_verify_token')
         CALLBACK_URL="https://$REGION-$GCP_PROJECT_ID.cloudfunctions.net/$FUNCTION_NAME"
 
         echo "📍 Using callback URL: $CALLBACK_URL"
 
-        curl -X POST \
-            https://www.strava.com/api/v3/push_subscriptions \
-            -F client_id="$CLIENT_ID" \
-            -F client_secret="$CLIENT_SECRET" \
-            -F callback_url="$CALLBACK_URL" \
-            -F verify_token="$VERIFY_TOKEN"
+        subscription create -callback-url "$CALLBACK_URL"
         ;;
 
     "view")
         echo "🔍 Viewing Strava webhook subscriptions for $ENV_NAME environment..."
 
-        STRAVA_AUTH=$(gcloud secrets versions access latest --secret="strava-auth-$ENV_NAME" --project="$GCP_PROJECT_ID")
-        CLIENT_ID=$(echo "$STRAVA_AUTH" | jq -r '.client_id')
-        CLIENT_SECRET=$(echo "$STRAVA_AUTH" | jq -r '.client_secret')
-
-        curl -sG \
-            -d client_id="$CLIENT_ID" \
-            -d client_secret="$CLIENT_SECRET" \
-            https://www.strava.com/api/v3/push_subscriptions
+        subscription view
         ;;
 
     "delete")
         echo "🗑️ Deleting Strava webhook subscription for $ENV_NAME environment..."
 
-        STRAVA_AUTH=$(gcloud secrets versions access latest --secret="strava-auth-$ENV_NAME" --project="$GCP_PROJECT_ID")
-        SUBSCRIPTION_ID=$(echo "$STRAVA_AUTH" | jq -r '.webhook_subscription_id')
-        CLIENT_ID=$(echo "$STRAVA_AUTH" | jq -r '.client_id')
-        CLIENT_SECRET=$(echo "$STRAVA_AUTH" | jq -r '.client_secret')
-
-        curl -X DELETE \
-            "https://www.strava.com/api/v3/push_subscriptions/$SUBSCRIPTION_ID?client_id=$CLIENT_ID&client_secret=$CLIENT_SECRET"
+        subscription delete
         ;;
 
     "generate-token")
@@ -284,6 +284,17 @@ resource "google_firestore_database" "user_configs" {
 # PUBSUB RESOURCES
 # ==============================================================================
 
+# Schema for activity events (see schemas/proto/webhook_event.proto)
+resource "google_pubsub_schema" "activity_events" {
+  count = var.enable_activity_event_schema ? 1 : 0
+
+  name       = "${var.project_name}_activity_events"
+  type       = "PROTOCOL_BUFFER"
+  definition = file("${path.module}/../../../schemas/proto/webhook_event.proto")
+
+  depends_on = [google_project_service.required_apis]
+}
+
 # PubSub Topic for activity events
 resource "google_pubsub_topic" "activity_events" {
   name = "${var.project_name}_activity_events"
@@ -292,6 +303,16 @@ resource "google_pubsub_topic" "activity_events" {
 
   # Message retention for 7 days
   message_retention_duration = "604800s"
+
+  # JSON encoding keeps the existing wire format, so publishers and
+  # consumers are unchanged while Pub/Sub rejects non-conforming messages
+  dynamic "schema_settings" {
+    for_each = google_pubsub_schema.activity_events
+    content {
+      schema   = schema_settings.value.id
+      encoding = "JSON"
+    }
+  }
 }
 
 # Eventarc-created subscriptions are managed at the root module level
@@ -363,6 +384,14 @@ resource "google_pubsub_topic_iam_member" "dispatcher_publisher" {
   member = "serviceAccount:${google_service_account.dispatcher_dev[0].email}"
 }
 
+# Dispatcher writes events it fails to publish under dead-letter/ in the aggregation bucket
+resource "google_storage_bucket_iam_member" "dispatcher_dead_letter" {
+  count  = var.create_dev_service_accounts ? 1 : 0
+  bucket = google_storage_bucket.aggregation_bucket.name
+  role   = "roles/storage.objectCreator"
+  member = "serviceAccount:${google_service_account.dispatcher_dev[0].email}"
+}
+
 # IAM permissions for aggregator (Storage Admin + BigQuery read access - PubSub permissions handled by Eventarc)
 
 resource "google_storage_bucket_iam_member" "aggregator_storage" {
@@ -552,6 +581,11 @@ resource "google_cloudfunctions2_function" "activity_dispatcher" {
     entry_point       = "ActivityDispatcher"
     docker_repository = google_artifact_registry_repository.functions.id
 
+    # Served at /version; "latest" packages don't identify a commit
+    environment_variables = var.function_source_tag == "latest" ? {} : {
+      GOOGLE_GOLDFLAGS = "-X github.com/andy-esch/desirelines/packages/dispatcher.Commit=${var.function_source_tag}"
+    }
+
     source {
       storage_source {
         bucket = local.function_source_bucket
@@ -569,10 +603,13 @@ resource "google_cloudfunctions2_function" "activity_dispatcher" {
 
     environment_variables = {
       GCP_PROJECT_ID   = var.gcp_project_id
-      GCP_PUBSUB_TOPIC = google_pubsub_topic.activity_events.name
-      ENVIRONMENT      = var.environment
-      LOG_LEVEL        = "INFO"
-      FORCE_DEPLOY     = "20250925-secret-update-v1"
+      GCP_PUBSUB_TOPIC   = google_pubsub_topic.activity_events.name
+      DEAD_LETTER_BUCKET = google_storage_bucket.aggregation_bucket.name
+      ENVIRONMENT        = var.environment
+      LOG_LEVEL          = "INFO"
+      # Requests arrive from Google's front end on a link-local address
+      TRUSTED_PROXIES    = "169.254.0.0/16"
+      FORCE_DEPLOY       = "20250925-secret-update-v1"
     }
 
     # Mount Strava secrets as volume
@@ -744,6 +781,11 @@ resource "google_cloudfunctions2_function" "api_gateway" {
     entry_point       = "APIGateway"
     docker_repository = google_artifact_registry_repository.functions.id
 
+    # Served at /version; "latest" packages don't identify a commit
+    environment_variables = var.function_source_tag == "latest" ? {} : {
+      GOOGLE_GOLDFLAGS = "-X github.com/andy-esch/desirelines/packages/apigateway.Commit=${var.function_source_tag}"
+    }
+
     source {
       storage_source {
         bucket = local.function_source_bucket
@@ -766,6 +808,8 @@ resource "google_cloudfunctions2_function" "api_gateway" {
       GCP_BUCKET_NAME = google_storage_bucket.aggregation_bucket.name
       ENVIRONMENT     = var.environment
       ALLOWED_ORIGINS = var.api_gateway_allowed_origins
+      # Requests arrive from Google's front end on a link-local address
+      TRUSTED_PROXIES = "169.254.0.0/16"
     }
   }
 
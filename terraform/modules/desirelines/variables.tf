@@ -109,6 +109,12 @@ variable "create_dev_service_accounts" {
   default     = false
 }
 
+variable "enable_activity_event_schema" {
+  description = "Whether to attach the webhook_event.proto schema to the activity events topic (incompatible with ENRICH_ACTIVITIES and MESSAGE_FORMAT=cloudevents)"
+  type        = bool
+  default     = false
+}
+
 # Container image variables for Cloud Functions
 variable "function_image_tag" {
   description = "Tag for function container images (e.g., 'latest', 'v1.0.0', git SHA) - DEPRECATED: Use function_source_tag"
@@ -135,7 +141,7 @@ variable "artifact_registry_location" {
 }
 
 variable "api_gateway_allowed_origins" {
-  description = "Comma-separated list of allowed CORS origins for API Gateway (e.g., 'https://example.com,http://localhost:5173')"
+  description = "Comma-separated list of allowed CORS origins for API Gateway. Supports '*.' subdomain and ':*' port wildcards (e.g., 'https://example.com,https://*.web.app,http://localhost:*')"
   type        = string
   default     = ""
 }
@@ -0,0 +1,15 @@
+// Package integration holds end-to-end tests that run the dispatcher and
+// API gateway handlers in-process against a Pub/Sub emulator and a fake
+// Cloud Storage server, checking what unit tests mock out: messages
+// actually landing on the topic and objects actually written to and read
+// from a bucket.
+//
+// The tests are behind the integration build tag:
+//
+//	go test -tags integration ./...
+//
+// They start Google's in-process Pub/Sub fake (pstest) unless
+// PUBSUB_EMULATOR_HOST already points at an emulator, e.g. the one in
+// docker-compose.yml, and always start their own storage server, which
+// the Cloud Storage clients reach through STORAGE_EMULATOR_HOST.
+package integration
@@ -0,0 +1,257 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"cloud.google.com/go/pubsub/v2/pstest"
+)
+
+// testProject is the project the handlers and emulators use.
+const testProject = "desirelines-integration"
+
+// startPubSub points PUBSUB_EMULATOR_HOST at an emulator, starting pstest
+// unless one is already configured, and returns a client for it.
+func startPubSub(t *testing.T) *pubsub.Client {
+	t.Helper()
+	if os.Getenv("PUBSUB_EMULATOR_HOST") == "" {
+		server := pstest.NewServer()
+		t.Cleanup(func() { _ = server.Close() })
+		t.Setenv("PUBSUB_EMULATOR_HOST", server.Addr)
+	}
+
+	client, err := pubsub.NewClient(context.Background(), testProject)
+	if err != nil {
+		t.Fatalf("failed to connect to the Pub/Sub emulator: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+// createTopic creates a topic named after the test with one subscription,
+// so tests sharing an external emulator don't see each other's messages.
+func createTopic(t *testing.T, client *pubsub.Client) (topicID string, sub *pubsub.Subscriber) {
+	t.Helper()
+	ctx := context.Background()
+	topicID = fmt.Sprintf("%s-%d", strings.ToLower(t.Name()), time.Now().UnixNano())
+	topic, err := client.TopicAdminClient.CreateTopic(ctx, &pubsubpb.Topic{
+		Name: fmt.Sprintf("projects/%s/topics/%s", testProject, topicID),
+	})
+	if err != nil {
+		t.Fatalf("failed to create topic: %v", err)
+	}
+	subscription, err := client.SubscriptionAdminClient.CreateSubscription(ctx, &pubsubpb.Subscription{
+		Name:                  fmt.Sprintf("projects/%s/subscriptions/%s", testProject, topicID),
+		Topic:                 topic.GetName(),
+		EnableMessageOrdering: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create subscription: %v", err)
+	}
+	return topicID, client.Subscriber(subscription.GetName())
+}
+
+// receiveOne returns the next message on sub, failing after timeout.
+func receiveOne(t *testing.T, sub *pubsub.Subscriber, timeout time.Duration) *pubsub.Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var received *pubsub.Message
+	var once sync.Once
+	err := sub.Receive(ctx, func(_ context.Context, msg *pubsub.Message) {
+		msg.Ack()
+		once.Do(func() {
+			received = msg
+			cancel()
+		})
+	})
+	if err != nil {
+		t.Fatalf("failed to receive: %v", err)
+	}
+	if received == nil {
+		t.Fatalf("no message within %s", timeout)
+	}
+	return received
+}
+
+// fakeGCS is an in-memory stand-in for the Cloud Storage JSON and XML APIs,
+// covering the calls the handlers make: bucket and object metadata,
+// listing by prefix, reads and multipart uploads.
+type fakeGCS struct {
+	server *httptest.Server
+	mu     sync.Mutex
+	// objects maps "bucket/name" to content.
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	updated     time.Time
+}
+
+// startGCS starts a fake storage server and points STORAGE_EMULATOR_HOST
+// at it.
+func startGCS(t *testing.T) *fakeGCS {
+	t.Helper()
+	f := &fakeGCS{objects: map[string]fakeObject{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /storage/v1/b/{bucket}", f.getBucket)
+	mux.HandleFunc("GET /storage/v1/b/{bucket}/o", f.list)
+	mux.HandleFunc("GET /storage/v1/b/{bucket}/o/{object...}", f.getObject)
+	mux.HandleFunc("POST /upload/storage/v1/b/{bucket}/o", f.upload)
+	mux.HandleFunc("GET /{bucket}/{object...}", f.download)
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	t.Setenv("STORAGE_EMULATOR_HOST", f.server.URL)
+	return f
+}
+
+// put stores an object, as if uploaded.
+func (f *fakeGCS) put(bucket, name string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[bucket+"/"+name] = fakeObject{data: data, contentType: "application/json", updated: time.Now().UTC()}
+}
+
+// get returns an object's content.
+func (f *fakeGCS) get(bucket, name string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	object, ok := f.objects[bucket+"/"+name]
+	return object.data, ok
+}
+
+// names lists the objects in bucket under prefix.
+func (f *fakeGCS) names(bucket, prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	for key := range f.objects {
+		if name, ok := strings.CutPrefix(key, bucket+"/"); ok && strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (f *fakeGCS) getBucket(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]string{"kind": "storage#bucket", "name": r.PathValue("bucket")})
+}
+
+func (f *fakeGCS) list(w http.ResponseWriter, r *http.Request) {
+	bucket := r.PathValue("bucket")
+	items := []map[string]string{}
+	for _, name := range f.names(bucket, r.URL.Query().Get("prefix")) {
+		data, _ := f.get(bucket, name)
+		items = append(items, map[string]string{"kind": "storage#object", "bucket": bucket, "name": name, "size": strconv.Itoa(len(data))})
+	}
+	writeJSON(w, map[string]any{"kind": "storage#objects", "items": items})
+}
+
+func (f *fakeGCS) getObject(w http.ResponseWriter, r *http.Request) {
+	bucket, name := r.PathValue("bucket"), r.PathValue("object")
+	f.mu.Lock()
+	object, ok := f.objects[bucket+"/"+name]
+	f.mu.Unlock()
+	if !ok {
+		http.Error(w, `{"error":{"code":404,"message":"Not Found"}}`, http.StatusNotFound)
+		return
+	}
+	if r.URL.Query().Get("alt") == "media" {
+		w.Header().Set("Content-Type", object.contentType)
+		_, _ = w.Write(object.data)
+		return
+	}
+	writeJSON(w, objectMetadata(bucket, name, object))
+}
+
+func (f *fakeGCS) download(w http.ResponseWriter, r *http.Request) {
+	bucket, name := r.PathValue("bucket"), r.PathValue("object")
+	f.mu.Lock()
+	object, ok := f.objects[bucket+"/"+name]
+	f.mu.Unlock()
+	if !ok {
+		http.Error(w, "NoSuchKey", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", object.contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(object.data)))
+	w.Header().Set("Last-Modified", object.updated.Format(http.TimeFormat))
+	_, _ = w.Write(object.data)
+}
+
+// upload accepts uploadType=multipart uploads: a JSON metadata part
+// followed by the content.
+func (f *fakeGCS) upload(w http.ResponseWriter, r *http.Request) {
+	bucket := r.PathValue("bucket")
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		http.Error(w, "only multipart uploads are supported", http.StatusBadRequest)
+		return
+	}
+	reader := multipart.NewReader(r.Body, params["boundary"])
+
+	var metadata struct {
+		Name        string `json:"name"`
+		ContentType string `json:"contentType"`
+	}
+	part, err := reader.NextPart()
+	if err == nil {
+		err = json.NewDecoder(part).Decode(&metadata)
+	}
+	if err != nil {
+		http.Error(w, "invalid metadata part", http.StatusBadRequest)
+		return
+	}
+	part, err = reader.NextPart()
+	if err != nil {
+		http.Error(w, "missing media part", http.StatusBadRequest)
+		return
+	}
+	data, err := io.ReadAll(part)
+	if err != nil {
+		http.Error(w, "invalid media part", http.StatusBadRequest)
+		return
+	}
+
+	object := fakeObject{data: data, contentType: metadata.ContentType, updated: time.Now().UTC()}
+	f.mu.Lock()
+	f.objects[bucket+"/"+metadata.Name] = object
+	f.mu.Unlock()
+	writeJSON(w, objectMetadata(bucket, metadata.Name, object))
+}
+
+func objectMetadata(bucket, name string, object fakeObject) map[string]string {
+	return map[string]string{
+		"kind":        "storage#object",
+		"bucket":      bucket,
+		"name":        name,
+		"size":        strconv.Itoa(len(object.data)),
+		"contentType": object.contentType,
+		"updated":     object.updated.Format(time.RFC3339Nano),
+		"generation":  strconv.FormatInt(object.updated.UnixNano(), 10),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,215 @@
+//go:build integration
+
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andy-esch/desirelines/packages/aggregation"
+	"github.com/andy-esch/desirelines/packages/apigateway"
+	"github.com/andy-esch/desirelines/packages/dispatcher"
+)
+
+const (
+	subscriptionID = "123456"
+	auditBucket    = "desirelines-audit"
+	gatewayBucket  = "desirelines-aggregations"
+)
+
+func TestDispatcherPublishesWebhookToTopic(t *testing.T) {
+	client := startPubSub(t)
+	gcs := startGCS(t)
+	topicID, sub := createTopic(t, client)
+
+	t.Setenv("GCP_PROJECT_ID", testProject)
+	t.Setenv("GCP_PUBSUB_TOPIC", topicID)
+	t.Setenv("STRAVA_WEBHOOK_SUBSCRIPTION_ID", subscriptionID)
+	t.Setenv("STRAVA_WEBHOOK_VERIFY_TOKEN", "verify-token")
+	t.Setenv("AUDIT_BUCKET", auditBucket)
+
+	handler, err := dispatcher.NewHandler(context.Background())
+	if err != nil {
+		t.Fatalf("failed to create dispatcher: %v", err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	post := func(body string) int {
+		resp, err := http.Post(server.URL+"/", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("failed to post webhook: %v", err)
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	eventTime := time.Now().Unix()
+	webhook := dispatcher.WebhookRequest{
+		AspectType:     dispatcher.AspectCreate,
+		ObjectType:     dispatcher.ObjectActivity,
+		ObjectID:       987654321,
+		OwnerID:        67890,
+		SubscriptionID: 123456,
+		EventTime:      eventTime,
+		Updates:        map[string]any{},
+	}
+	body, _ := json.Marshal(webhook)
+	if status := post(string(body)); status != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", status)
+	}
+
+	t.Run("the event lands on the topic", func(t *testing.T) {
+		msg := receiveOne(t, sub, 30*time.Second)
+
+		var published dispatcher.WebhookRequest
+		if err := json.Unmarshal(msg.Data, &published); err != nil {
+			t.Fatalf("failed to decode message: %v", err)
+		}
+		if published.ObjectID != webhook.ObjectID || published.AspectType != webhook.AspectType || published.EventTime != eventTime {
+			t.Errorf("expected the posted webhook, got %+v", published)
+		}
+		for key, want := range map[string]string{
+			"aspect_type":     "create",
+			"object_type":     "activity",
+			"owner_id":        "67890",
+			"subscription_id": subscriptionID,
+		} {
+			if got := msg.Attributes[key]; got != want {
+				t.Errorf("expected attribute %s=%q, got %q", key, want, got)
+			}
+		}
+		if msg.Attributes["correlation_id"] == "" {
+			t.Error("expected a correlation_id attribute")
+		}
+		if msg.OrderingKey != "67890" {
+			t.Errorf("expected ordering key 67890, got %q", msg.OrderingKey)
+		}
+	})
+
+	t.Run("events from another subscription are rejected", func(t *testing.T) {
+		wrong := webhook
+		wrong.SubscriptionID = 999
+		body, _ := json.Marshal(wrong)
+		if status := post(string(body)); status != http.StatusUnauthorized {
+			t.Errorf("expected status 401, got %d", status)
+		}
+	})
+
+	t.Run("closing flushes the audit log to the bucket", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := handler.Close(ctx); err != nil {
+			t.Fatalf("failed to close dispatcher: %v", err)
+		}
+
+		outcomes := map[string]int{}
+		for _, name := range gcs.names(auditBucket, dispatcher.DefaultAuditPrefix) {
+			data, _ := gcs.get(auditBucket, name)
+			scanner := bufio.NewScanner(bytes.NewReader(data))
+			for scanner.Scan() {
+				var record dispatcher.AuditRecord
+				if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+					t.Fatalf("invalid audit record in %s: %v", name, err)
+				}
+				if record.ObjectID == webhook.ObjectID {
+					outcomes[record.Outcome]++
+				}
+			}
+		}
+		want := map[string]int{dispatcher.AuditPublished: 1, dispatcher.AuditRejected: 1}
+		if !reflect.DeepEqual(outcomes, want) {
+			t.Errorf("expected audit outcomes %v, got %v", want, outcomes)
+		}
+	})
+}
+
+func TestGatewayServesBucketData(t *testing.T) {
+	gcs := startGCS(t)
+	fixture, err := os.ReadFile("../../data/fixtures/activities/2025/summary_activities.json")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	gcs.put(gatewayBucket, "activities/2025/summary_activities.json", fixture)
+
+	t.Setenv("DATA_SOURCE", "cloud-storage")
+	t.Setenv("GCP_BUCKET_NAME", gatewayBucket)
+	t.Setenv("ADMIN_TOKEN", "admin-secret")
+
+	handler, err := apigateway.NewHandler(context.Background())
+	if err != nil {
+		t.Fatalf("failed to create API gateway: %v", err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	do := func(method, path, body, token string) (int, []byte) {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		var buf bytes.Buffer
+		_, _ = buf.ReadFrom(resp.Body)
+		return resp.StatusCode, buf.Bytes()
+	}
+
+	t.Run("serves an object from the bucket", func(t *testing.T) {
+		status, body := do(http.MethodGet, "/activities/2025/summary", "", "")
+		if status != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", status, body)
+		}
+		var got, want any
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		_ = json.Unmarshal(fixture, &want)
+		if !reflect.DeepEqual(got, want) {
+			t.Error("expected the response to match the stored object")
+		}
+	})
+
+	t.Run("a missing year is not found", func(t *testing.T) {
+		if status, body := do(http.MethodGet, "/activities/2019/summary", "", ""); status != http.StatusNotFound {
+			t.Errorf("expected status 404, got %d: %s", status, body)
+		}
+	})
+
+	t.Run("stored goals are written to the bucket and read back", func(t *testing.T) {
+		status, body := do(http.MethodPut, "/goals/7/2025", `{"goals":[{"id":"year","label":"2,500 miles","target":2500}]}`, "admin-secret")
+		if status != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", status, body)
+		}
+		if _, ok := gcs.get(gatewayBucket, aggregation.GoalsBlob(7, 2025)); !ok {
+			t.Fatalf("expected %s in the bucket", aggregation.GoalsBlob(7, 2025))
+		}
+
+		status, body = do(http.MethodGet, "/goals/7/2025", "", "")
+		if status != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", status, body)
+		}
+		var goals aggregation.GoalSet
+		if err := json.Unmarshal(body, &goals); err != nil {
+			t.Fatalf("failed to decode goals: %v", err)
+		}
+		if len(goals.Goals) != 1 || goals.Goals[0].Target != 2500 {
+			t.Errorf("expected the stored goal, got %+v", goals.Goals)
+		}
+	})
+}